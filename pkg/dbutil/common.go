@@ -73,6 +73,12 @@ type DBConfig struct {
 	Schema string `toml:"schema" json:"schema"`
 
 	Snapshot string `toml:"snapshot" json:"snapshot"`
+
+	// the timeouts of the connections, no timeout if 0, see `timeout`, `readTimeout` and `writeTimeout`
+	// in https://github.com/go-sql-driver/mysql#parameters.
+	DialTimeout  time.Duration `toml:"-" json:"-"`
+	ReadTimeout  time.Duration `toml:"-" json:"-"`
+	WriteTimeout time.Duration `toml:"-" json:"-"`
 }
 
 // String returns native format of database configuration
@@ -111,20 +117,10 @@ func GetDBConfigFromEnv(schema string) DBConfig {
 
 // OpenDB opens a mysql connection FD
 func OpenDB(cfg DBConfig, vars map[string]string) (*sql.DB, error) {
-	var dbDSN string
 	if len(cfg.Snapshot) != 0 {
 		log.Info("create connection with snapshot", zap.String("snapshot", cfg.Snapshot))
-		dbDSN = fmt.Sprintf("%s:%s@tcp(%s:%d)/?charset=utf8mb4&tidb_snapshot=%s", cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Snapshot)
-	} else {
-		dbDSN = fmt.Sprintf("%s:%s@tcp(%s:%d)/?charset=utf8mb4", cfg.User, cfg.Password, cfg.Host, cfg.Port)
-	}
-
-	for key, val := range vars {
-		// key='val'. add single quote for better compatibility.
-		dbDSN += fmt.Sprintf("&%s=%%27%s%%27", key, url.QueryEscape(val))
 	}
-
-	dbConn, err := sql.Open("mysql", dbDSN)
+	dbConn, err := sql.Open("mysql", GetDSN(cfg, vars))
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -133,6 +129,29 @@ func OpenDB(cfg DBConfig, vars map[string]string) (*sql.DB, error) {
 	return dbConn, errors.Trace(err)
 }
 
+// GetDSN returns the DSN to connect to the database, the session variables in vars are set for each connection.
+func GetDSN(cfg DBConfig, vars map[string]string) string {
+	dbDSN := fmt.Sprintf("%s:%s@tcp(%s:%d)/?charset=utf8mb4", cfg.User, cfg.Password, cfg.Host, cfg.Port)
+	if len(cfg.Snapshot) != 0 {
+		dbDSN += fmt.Sprintf("&tidb_snapshot=%s", cfg.Snapshot)
+	}
+	if cfg.DialTimeout > 0 {
+		dbDSN += fmt.Sprintf("&timeout=%s", cfg.DialTimeout)
+	}
+	if cfg.ReadTimeout > 0 {
+		dbDSN += fmt.Sprintf("&readTimeout=%s", cfg.ReadTimeout)
+	}
+	if cfg.WriteTimeout > 0 {
+		dbDSN += fmt.Sprintf("&writeTimeout=%s", cfg.WriteTimeout)
+	}
+
+	for key, val := range vars {
+		// key='val'. add single quote for better compatibility.
+		dbDSN += fmt.Sprintf("&%s=%%27%s%%27", key, url.QueryEscape(val))
+	}
+	return dbDSN
+}
+
 // CloseDB closes the mysql fd
 func CloseDB(db *sql.DB) error {
 	if db == nil {
@@ -374,13 +393,13 @@ func GetTables(ctx context.Context, db QueryExecutor, schemaName string) (tables
 		| NTEST          | BASE TABLE |
 		+----------------+------------+
 	*/
-	query := fmt.Sprintf("SHOW FULL TABLES IN `%s` WHERE Table_Type != 'VIEW';", escapeName(schemaName))
+	query := fmt.Sprintf("SHOW FULL TABLES IN %s WHERE Table_Type != 'VIEW';", QuoteName(schemaName))
 	return queryTables(ctx, db, query)
 }
 
 // GetViews returns names of all views in the specified schema
 func GetViews(ctx context.Context, db QueryExecutor, schemaName string) (tables []string, err error) {
-	query := fmt.Sprintf("SHOW FULL TABLES IN `%s` WHERE Table_Type = 'VIEW';", escapeName(schemaName))
+	query := fmt.Sprintf("SHOW FULL TABLES IN %s WHERE Table_Type = 'VIEW';", QuoteName(schemaName))
 	return queryTables(ctx, db, query)
 }
 
@@ -721,16 +740,19 @@ func IsTiDB(ctx context.Context, db QueryExecutor) (bool, error) {
 
 // TableName returns `schema`.`table`
 func TableName(schema, table string) string {
-	return fmt.Sprintf("`%s`.`%s`", escapeName(schema), escapeName(table))
+	return QuoteName(schema) + "." + QuoteName(table)
 }
 
 // ColumnName returns `column`
 func ColumnName(column string) string {
-	return fmt.Sprintf("`%s`", escapeName(column))
+	return QuoteName(column)
 }
 
-func escapeName(name string) string {
-	return strings.Replace(name, "`", "``", -1)
+// QuoteName returns the quoted identifier `name`, e.g. the name of a schema, a table, a column, an index or
+// a partition. The embedded backticks are escaped, and the other characters, e.g. the spaces and the reserved
+// words, are kept as is, so it's the only way the identifiers are written in the SQL.
+func QuoteName(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
 }
 
 // ReplacePlaceholder will use args to replace '?', used for log.
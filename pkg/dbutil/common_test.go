@@ -51,6 +51,25 @@ func (*testDBSuite) TestReplacePlaceholder(c *C) {
 
 }
 
+func (*testDBSuite) TestGetDSN(c *C) {
+	cfg := DBConfig{Host: "127.0.0.1", Port: 4000, User: "root", Password: "123"}
+	c.Assert(GetDSN(cfg, nil), Equals, "root:123@tcp(127.0.0.1:4000)/?charset=utf8mb4")
+
+	cfg.Snapshot = "386902609362944000"
+	cfg.DialTimeout = 30 * time.Second
+	cfg.ReadTimeout = 10 * time.Minute
+	cfg.WriteTimeout = 90 * time.Second
+	c.Assert(GetDSN(cfg, map[string]string{"time_zone": "+00:00"}), Equals,
+		"root:123@tcp(127.0.0.1:4000)/?charset=utf8mb4&tidb_snapshot=386902609362944000&timeout=30s&readTimeout=10m0s&writeTimeout=1m30s&time_zone=%27%2B00%3A00%27")
+
+	// the DSN is parsed by the driver with the timeouts.
+	dsn, err := mysql.ParseDSN(GetDSN(cfg, nil))
+	c.Assert(err, IsNil)
+	c.Assert(dsn.Timeout, Equals, 30*time.Second)
+	c.Assert(dsn.ReadTimeout, Equals, 10*time.Minute)
+	c.Assert(dsn.WriteTimeout, Equals, 90*time.Second)
+}
+
 func (*testDBSuite) TestTableName(c *C) {
 	testCases := []struct {
 		schema          string
@@ -72,6 +91,11 @@ func (*testDBSuite) TestTableName(c *C) {
 			"t`esta",
 			"`test`.`t``esta`",
 		},
+		{
+			"s`.`x",
+			"select",
+			"`s``.``x`.`select`",
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -97,6 +121,18 @@ func (*testDBSuite) TestColumnName(c *C) {
 			"t`esta",
 			"`t``esta`",
 		},
+		{
+			"order",
+			"`order`",
+		},
+		{
+			"``",
+			"``````",
+		},
+		{
+			"列 ",
+			"`列 `",
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -14,11 +14,17 @@
 package dbutil
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
 	"strings"
+	"time"
 
 	"github.com/go-sql-driver/mysql"
 	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
 	"github.com/pingcap/tidb/errno"
+	"go.uber.org/zap"
 )
 
 var (
@@ -69,3 +75,128 @@ func IsRetryableError(err error) bool {
 
 	return false
 }
+
+// IsTransientError checks whether the error is transient, so that a read-only query or an idempotent statement can be
+// retried after a while. Besides the errors of IsRetryableError, the lock wait timeouts, the `1105` region errors and
+// the bad connections are transient, while the syntax and the permission errors are fatal.
+func IsTransientError(err error) bool {
+	err = errors.Cause(err) // check the original error
+	if err == driver.ErrBadConn || err == mysql.ErrInvalidConn {
+		return true
+	}
+	mysqlErr, ok := err.(*mysql.MySQLError)
+	if !ok {
+		return false
+	}
+
+	switch mysqlErr.Number {
+	case errno.ErrParse,
+		errno.ErrSyntax,
+		errno.ErrAccessDenied,
+		errno.ErrDBaccessDenied,
+		errno.ErrTableaccessDenied,
+		errno.ErrColumnaccessDenied,
+		errno.ErrSpecificAccessDenied:
+		return false
+	case errno.ErrLockWaitTimeout,
+		errno.ErrRegionUnavailable:
+		return true
+	case errno.ErrUnknown:
+		if strings.Contains(strings.ToLower(mysqlErr.Message), "region") {
+			return true
+		}
+	}
+
+	return IsRetryableError(err)
+}
+
+// RetryPolicy is the policy to retry a failed statement.
+type RetryPolicy struct {
+	// MaxAttempts is the max number of the attempts, including the first one.
+	MaxAttempts int
+	// Backoff is the time to wait before the first retry, which is doubled before each of the next retries,
+	// but no more than MaxBackoff if it's set.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// IsRetryable classifies the errors, IsTransientError is used if it's nil.
+	IsRetryable func(error) bool
+	// OnRetry is called with the failed attempt and its error before each retry, e.g. to collect the metrics.
+	OnRetry func(attempt int, err error)
+}
+
+// DefaultRetryPolicy returns the policy which retries the transient errors at most DefaultRetryTime times.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: DefaultRetryTime,
+		Backoff:     100 * time.Millisecond,
+		MaxBackoff:  5 * time.Second,
+	}
+}
+
+// Do calls fn until it succeeds, it returns an error which isn't retryable, or the attempts are exhausted.
+// A nil policy calls fn only once.
+func (p *RetryPolicy) Do(ctx context.Context, fn func() error) error {
+	if p == nil {
+		return fn()
+	}
+	isRetryable := p.IsRetryable
+	if isRetryable == nil {
+		isRetryable = IsTransientError
+	}
+	backoff := p.Backoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if !isRetryable(err) {
+			return err
+		}
+		if attempt >= p.MaxAttempts {
+			return errors.Annotatef(err, "retry exhausted after %d attempts", attempt)
+		}
+		log.Warn("retry the failed statement", zap.Int("attempt", attempt), zap.Duration("backoff", backoff), zap.Error(err))
+		if p.OnRetry != nil {
+			p.OnRetry(attempt, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Trace(ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+	}
+}
+
+// QueryWithRetry executes the query by the policy, see `RetryPolicy.Do`. Only the errors of executing the query are
+// retried, the errors of scanning the rows are returned by the rows.
+func QueryWithRetry(ctx context.Context, db QueryExecutor, policy *RetryPolicy, query string, args ...interface{}) (*sql.Rows, error) {
+	var rows *sql.Rows
+	err := policy.Do(ctx, func() error {
+		var err error
+		rows, err = db.QueryContext(ctx, query, args...)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return rows, nil
+}
+
+// ExecWithRetry executes the statement by the policy, see `RetryPolicy.Do`. The statement should be idempotent.
+func ExecWithRetry(ctx context.Context, db DBExecutor, policy *RetryPolicy, query string, args ...interface{}) (sql.Result, error) {
+	var result sql.Result
+	err := policy.Do(ctx, func() error {
+		var err error
+		result, err = db.ExecContext(ctx, query, args...)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return result, nil
+}
@@ -14,9 +14,12 @@
 package dbutil
 
 import (
+	"context"
 	"database/sql/driver"
 	"errors"
+	"time"
 
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
 	"github.com/go-sql-driver/mysql"
 	. "github.com/pingcap/check"
 	"github.com/pingcap/tidb/errno"
@@ -128,3 +131,107 @@ func (t *testRetrySuite) TestIsRetryableError(c *C) {
 		c.Assert(IsRetryableError(cs.err), Equals, cs.retryable)
 	}
 }
+
+func (t *testRetrySuite) TestIsTransientError(c *C) {
+	cases := []struct {
+		err       error
+		transient bool
+	}{
+		{nil, false},
+		{errors.New("custom error"), false},
+		{driver.ErrBadConn, true},
+		{mysql.ErrInvalidConn, true},
+		{newMysqlErr(errno.ErrLockWaitTimeout, "Lock wait timeout exceeded; try restarting transaction"), true},
+		{newMysqlErr(errno.ErrUnknown, "Region is unavailable"), true},
+		{newMysqlErr(errno.ErrUnknown, "region epoch not match"), true},
+		{newMysqlErr(errno.ErrRegionUnavailable, "Region is unavailable"), true},
+		{newMysqlErr(errno.ErrLockDeadlock, "Deadlock found when trying to get lock; try restarting transaction"), true},
+		{newMysqlErr(errno.ErrUnknown, "i/o timeout"), false},
+		{newMysqlErr(errno.ErrParse, "You have an error in your SQL syntax"), false},
+		{newMysqlErr(errno.ErrTableaccessDenied, "SELECT command denied to user 'u'@'%' for table 't'"), false},
+		{newMysqlErr(errno.ErrSpecificAccessDenied, "Access denied; you need (at least one of) the PROCESS privilege(s) for this operation"), false},
+	}
+
+	for _, cs := range cases {
+		c.Assert(IsTransientError(cs.err), Equals, cs.transient, Commentf("err %v", cs.err))
+	}
+}
+
+func (t *testRetrySuite) TestQueryWithRetry(c *C) {
+	db, mock, err := sqlmock.New()
+	c.Assert(err, IsNil)
+	defer db.Close()
+
+	retries := 0
+	policy := &RetryPolicy{
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+		OnRetry:     func(attempt int, err error) { retries++ },
+	}
+	ctx := context.Background()
+	lockWaitTimeout := newMysqlErr(errno.ErrLockWaitTimeout, "Lock wait timeout exceeded; try restarting transaction")
+
+	// success after retry
+	mock.ExpectQuery("SELECT a FROM t").WillReturnError(lockWaitTimeout)
+	mock.ExpectQuery("SELECT a FROM t").WillReturnError(newMysqlErr(errno.ErrUnknown, "Region is unavailable"))
+	mock.ExpectQuery("SELECT a FROM t").WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow(1))
+	rows, err := QueryWithRetry(ctx, db, policy, "SELECT a FROM t")
+	c.Assert(err, IsNil)
+	c.Assert(rows.Next(), IsTrue)
+	c.Assert(rows.Close(), IsNil)
+	c.Assert(retries, Equals, 2)
+
+	// exhausted
+	retries = 0
+	for i := 0; i < 3; i++ {
+		mock.ExpectQuery("SELECT a FROM t").WillReturnError(lockWaitTimeout)
+	}
+	_, err = QueryWithRetry(ctx, db, policy, "SELECT a FROM t")
+	c.Assert(err, ErrorMatches, ".*retry exhausted after 3 attempts.*")
+	c.Assert(IsTransientError(err), IsTrue)
+	c.Assert(retries, Equals, 2)
+
+	// fatal
+	retries = 0
+	mock.ExpectQuery("SELECT a FROM t").WillReturnError(newMysqlErr(errno.ErrParse, "You have an error in your SQL syntax"))
+	_, err = QueryWithRetry(ctx, db, policy, "SELECT a FROM t")
+	c.Assert(err, ErrorMatches, ".*SQL syntax.*")
+	c.Assert(retries, Equals, 0)
+
+	c.Assert(mock.ExpectationsWereMet(), IsNil)
+}
+
+func (t *testRetrySuite) TestExecWithRetry(c *C) {
+	db, mock, err := sqlmock.New()
+	c.Assert(err, IsNil)
+	defer db.Close()
+
+	policy := &RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond}
+	ctx := context.Background()
+	lockWaitTimeout := newMysqlErr(errno.ErrLockWaitTimeout, "Lock wait timeout exceeded; try restarting transaction")
+
+	// success after retry
+	mock.ExpectExec("DELETE FROM t").WillReturnError(lockWaitTimeout)
+	mock.ExpectExec("DELETE FROM t").WillReturnResult(sqlmock.NewResult(0, 1))
+	result, err := ExecWithRetry(ctx, db, policy, "DELETE FROM t")
+	c.Assert(err, IsNil)
+	affected, err := result.RowsAffected()
+	c.Assert(err, IsNil)
+	c.Assert(affected, Equals, int64(1))
+
+	// exhausted
+	mock.ExpectExec("DELETE FROM t").WillReturnError(lockWaitTimeout)
+	mock.ExpectExec("DELETE FROM t").WillReturnError(lockWaitTimeout)
+	_, err = ExecWithRetry(ctx, db, policy, "DELETE FROM t")
+	c.Assert(err, ErrorMatches, ".*retry exhausted after 2 attempts.*")
+
+	// canceled while waiting for the backoff
+	cancelCtx, cancel := context.WithCancel(ctx)
+	policy.OnRetry = func(attempt int, err error) { cancel() }
+	policy.Backoff = time.Minute
+	mock.ExpectExec("DELETE FROM t").WillReturnError(lockWaitTimeout)
+	_, err = ExecWithRetry(cancelCtx, db, policy, "DELETE FROM t")
+	c.Assert(errors.Is(err, context.Canceled), IsTrue)
+
+	c.Assert(mock.ExpectationsWereMet(), IsNil)
+}
@@ -0,0 +1,313 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package structdiff
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tidb/parser/types"
+)
+
+// Category is the category of a difference between two table structures.
+type Category string
+
+const (
+	// ColumnAdded means the column only exists in the second table.
+	ColumnAdded Category = "column-added"
+	// ColumnRemoved means the column only exists in the first table.
+	ColumnRemoved Category = "column-removed"
+	// ColumnMoved means the column is in the different orders among the columns existing in both tables.
+	ColumnMoved Category = "column-moved"
+	// ColumnType means the types of the column are different, e.g. `varchar(10)` and `varchar(20)`.
+	ColumnType Category = "column-type"
+	// ColumnNullability means the column is nullable in only one table.
+	ColumnNullability Category = "column-nullability"
+	// ColumnDefault means the default values of the column are different.
+	ColumnDefault Category = "column-default"
+	// ColumnCharset means the charsets or the collations of the string column are different.
+	ColumnCharset Category = "column-charset"
+	// IndexAdded means the index only exists in the second table.
+	IndexAdded Category = "index-added"
+	// IndexRemoved means the index only exists in the first table.
+	IndexRemoved Category = "index-removed"
+	// IndexUniqueness means the index is the primary key, a unique key or a normal key differently.
+	IndexUniqueness Category = "index-uniqueness"
+	// IndexColumns means the columns or the prefix lengths of the index are different.
+	IndexColumns Category = "index-columns"
+	// TableCharset means the default charsets or collations of the tables are different.
+	TableCharset Category = "table-charset"
+)
+
+// Kind returns what the category is about, which is "column", "index" or "table".
+func (c Category) Kind() string {
+	return string(c)[:strings.Index(string(c), "-")]
+}
+
+// Difference is a difference between two table structures.
+type Difference struct {
+	Category Category
+	// Name is the name of the column or the index, which is empty for `TableCharset`.
+	Name string
+	// A and B are the definitions in each table, e.g. "varchar(10)" of `ColumnType` and "KEY" of `IndexUniqueness`,
+	// which are empty in the table without the column or the index. The definitions of `ColumnAdded` and
+	// `ColumnRemoved` are described by `DescribeColumn`, and the ones of `IndexAdded` and `IndexRemoved` are
+	// described by `DescribeIndex`.
+	A string
+	B string
+	// Description is the human-readable description, e.g. "changed the type of column `b`: varchar(10) -> varchar(20)".
+	Description string
+}
+
+// String implements fmt.Stringer.
+func (d Difference) String() string {
+	return d.Description
+}
+
+// Options decides the categories of the differences ignored by `DiffTableInfo`.
+type Options struct {
+	// IgnoreColumnOrder ignores `ColumnMoved`.
+	IgnoreColumnOrder bool
+	// IgnoreNullability ignores `ColumnNullability`.
+	IgnoreNullability bool
+	// IgnoreDefaultValue ignores `ColumnDefault`.
+	IgnoreDefaultValue bool
+	// IgnoreCharset ignores `ColumnCharset` and `TableCharset`.
+	IgnoreCharset bool
+	// IgnoreIndices ignores all the differences of the indices.
+	IgnoreIndices bool
+}
+
+// DiffTableInfo returns the differences from the table structure `a` to `b`, e.g. the column only in `b` is added.
+// The columns and the indices are matched by name case-insensitively, and the names of the tables are not compared.
+// The differences of the columns are listed in the order of `b` followed by the removed columns in the order of `a`,
+// and so are the indices after the columns, then the difference of the tables. The primary key of `PKIsHandle` is
+// compared as the index `PRIMARY`.
+func DiffTableInfo(a, b *model.TableInfo, opts Options) []Difference {
+	diffs := make([]Difference, 0)
+	diffs = append(diffs, diffColumns(a, b, opts)...)
+	if !opts.IgnoreIndices {
+		diffs = append(diffs, diffIndices(a, b)...)
+	}
+	if !opts.IgnoreCharset {
+		if charsetA, charsetB := describeCharset(a.Charset, a.Collate), describeCharset(b.Charset, b.Collate); charsetA != charsetB {
+			diffs = append(diffs, Difference{Category: TableCharset, A: charsetA, B: charsetB,
+				Description: fmt.Sprintf("changed the charset of the table: %s -> %s", charsetA, charsetB)})
+		}
+	}
+	return diffs
+}
+
+func diffColumns(a, b *model.TableInfo, opts Options) []Difference {
+	diffs := make([]Difference, 0)
+	changed := func(category Category, col *model.ColumnInfo, what, definitionA, definitionB string) {
+		if definitionA != definitionB {
+			diffs = append(diffs, Difference{Category: category, Name: col.Name.O, A: definitionA, B: definitionB,
+				Description: fmt.Sprintf("changed the %s of column %s: %s -> %s", what, dbutil.QuoteName(col.Name.O), orNone(definitionA), orNone(definitionB))})
+		}
+	}
+	// the columns are moved if their orders are different, in which the columns only in one table are omitted.
+	ordersA := make(map[string]int, len(a.Columns))
+	positionsA := make(map[string]int, len(a.Columns))
+	for i, col := range a.Columns {
+		if dbutil.FindColumnByName(b.Columns, col.Name.O) != nil {
+			ordersA[col.Name.L] = len(ordersA)
+			positionsA[col.Name.L] = i
+		}
+	}
+	orderB := 0
+	for i, colB := range b.Columns {
+		colA := dbutil.FindColumnByName(a.Columns, colB.Name.O)
+		if colA == nil {
+			diffs = append(diffs, Difference{Category: ColumnAdded, Name: colB.Name.O, B: DescribeColumn(colB),
+				Description: fmt.Sprintf("added column %s: %s", dbutil.QuoteName(colB.Name.O), DescribeColumn(colB))})
+			continue
+		}
+		changed(ColumnType, colB, "type", colA.GetTypeDesc(), colB.GetTypeDesc())
+		if !opts.IgnoreNullability {
+			changed(ColumnNullability, colB, "nullability", describeNullability(colA), describeNullability(colB))
+		}
+		if !opts.IgnoreDefaultValue {
+			changed(ColumnDefault, colB, "default value", DescribeDefaultValue(colA), DescribeDefaultValue(colB))
+		}
+		if !opts.IgnoreCharset && isStringColumn(colA) && isStringColumn(colB) {
+			changed(ColumnCharset, colB, "charset", describeColumnCharset(colA, a), describeColumnCharset(colB, b))
+		}
+		if !opts.IgnoreColumnOrder && ordersA[colB.Name.L] != orderB {
+			positionA, positionB := fmt.Sprintf("position %d", positionsA[colB.Name.L]+1), fmt.Sprintf("position %d", i+1)
+			diffs = append(diffs, Difference{Category: ColumnMoved, Name: colB.Name.O, A: positionA, B: positionB,
+				Description: fmt.Sprintf("moved column %s: %s -> %s", dbutil.QuoteName(colB.Name.O), positionA, positionB)})
+		}
+		orderB++
+	}
+	for _, colA := range a.Columns {
+		if dbutil.FindColumnByName(b.Columns, colA.Name.O) == nil {
+			diffs = append(diffs, Difference{Category: ColumnRemoved, Name: colA.Name.O, A: DescribeColumn(colA),
+				Description: fmt.Sprintf("removed column %s: %s", dbutil.QuoteName(colA.Name.O), DescribeColumn(colA))})
+		}
+	}
+	return diffs
+}
+
+func diffIndices(a, b *model.TableInfo) []Difference {
+	diffs := make([]Difference, 0)
+	indicesA, indicesB := TableIndices(a), TableIndices(b)
+	for _, indexB := range indicesB {
+		indexA := FindIndex(indicesA, indexB.Name.O)
+		if indexA == nil {
+			diffs = append(diffs, Difference{Category: IndexAdded, Name: indexB.Name.O, B: DescribeIndex(indexB),
+				Description: fmt.Sprintf("added index %s: %s", dbutil.QuoteName(indexB.Name.O), DescribeIndex(indexB))})
+			continue
+		}
+		if keyTypeA, keyTypeB := indexKeyType(indexA), indexKeyType(indexB); keyTypeA != keyTypeB {
+			diffs = append(diffs, Difference{Category: IndexUniqueness, Name: indexB.Name.O, A: keyTypeA, B: keyTypeB,
+				Description: fmt.Sprintf("changed the uniqueness of index %s: %s -> %s", dbutil.QuoteName(indexB.Name.O), keyTypeA, keyTypeB)})
+		}
+		if columnsA, columnsB := FormatIndexColumns(indexA), FormatIndexColumns(indexB); !strings.EqualFold(columnsA, columnsB) {
+			diffs = append(diffs, Difference{Category: IndexColumns, Name: indexB.Name.O, A: columnsA, B: columnsB,
+				Description: fmt.Sprintf("changed the columns of index %s: (%s) -> (%s)", dbutil.QuoteName(indexB.Name.O), columnsA, columnsB)})
+		}
+	}
+	for _, indexA := range indicesA {
+		if FindIndex(indicesB, indexA.Name.O) == nil {
+			diffs = append(diffs, Difference{Category: IndexRemoved, Name: indexA.Name.O, A: DescribeIndex(indexA),
+				Description: fmt.Sprintf("removed index %s: %s", dbutil.QuoteName(indexA.Name.O), DescribeIndex(indexA))})
+		}
+	}
+	return diffs
+}
+
+// DescribeColumn returns the type and the nullability of the column, e.g. "varchar(10) NOT NULL".
+func DescribeColumn(col *model.ColumnInfo) string {
+	return col.GetTypeDesc() + " " + describeNullability(col)
+}
+
+// DescribeDefaultValue returns the default value of the column in sql, e.g. "'a'", "CURRENT_TIMESTAMP" and "NULL",
+// which is empty if the column has no default value, i.e. it's NOT NULL or generated without a default value.
+func DescribeDefaultValue(col *model.ColumnInfo) string {
+	if defaultValue := col.GetDefaultValue(); defaultValue != nil {
+		value := fmt.Sprintf("%v", defaultValue)
+		if strings.HasPrefix(strings.ToUpper(value), "CURRENT_TIMESTAMP") {
+			return value
+		}
+		return QuoteString(value)
+	}
+	if !mysql.HasNotNullFlag(col.Flag) && !col.IsGenerated() {
+		return "NULL"
+	}
+	return ""
+}
+
+// DescribeIndex returns the uniqueness and the columns of the index, e.g. "UNIQUE KEY (`a`,`b`)".
+func DescribeIndex(index *model.IndexInfo) string {
+	return fmt.Sprintf("%s (%s)", indexKeyType(index), FormatIndexColumns(index))
+}
+
+// FormatIndexColumns returns the quoted columns of the index with the prefix lengths, e.g. "`a`,`b`(10)".
+func FormatIndexColumns(index *model.IndexInfo) string {
+	cols := make([]string, 0, len(index.Columns))
+	for _, col := range index.Columns {
+		if col.Length > 0 {
+			cols = append(cols, fmt.Sprintf("%s(%d)", dbutil.QuoteName(col.Name.O), col.Length))
+		} else {
+			cols = append(cols, dbutil.QuoteName(col.Name.O))
+		}
+	}
+	return strings.Join(cols, ",")
+}
+
+// QuoteString quotes the string in sql, the single quotes are doubled.
+func QuoteString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// TableIndices returns the indices of the table, including the primary key of `PKIsHandle`, which isn't in the
+// indices.
+func TableIndices(tableInfo *model.TableInfo) []*model.IndexInfo {
+	if !tableInfo.PKIsHandle || FindIndex(tableInfo.Indices, mysql.PrimaryKeyName) != nil {
+		return tableInfo.Indices
+	}
+	pkCol := tableInfo.GetPkColInfo()
+	if pkCol == nil {
+		return tableInfo.Indices
+	}
+	primary := &model.IndexInfo{
+		Name:    model.NewCIStr(mysql.PrimaryKeyName),
+		Primary: true,
+		Unique:  true,
+		Columns: []*model.IndexColumn{{Name: pkCol.Name, Offset: pkCol.Offset, Length: types.UnspecifiedLength}},
+	}
+	return append([]*model.IndexInfo{primary}, tableInfo.Indices...)
+}
+
+// FindIndex returns the index named `name` case-insensitively, or nil if it doesn't exist.
+func FindIndex(indices []*model.IndexInfo, name string) *model.IndexInfo {
+	for _, index := range indices {
+		if strings.EqualFold(index.Name.O, name) {
+			return index
+		}
+	}
+	return nil
+}
+
+func indexKeyType(index *model.IndexInfo) string {
+	switch {
+	case index.Primary:
+		return "PRIMARY KEY"
+	case index.Unique:
+		return "UNIQUE KEY"
+	}
+	return "KEY"
+}
+
+func describeNullability(col *model.ColumnInfo) string {
+	if mysql.HasNotNullFlag(col.Flag) {
+		return "NOT NULL"
+	}
+	return "NULL"
+}
+
+func isStringColumn(col *model.ColumnInfo) bool {
+	return types.IsTypeChar(col.Tp) || types.IsTypeBlob(col.Tp)
+}
+
+// describeColumnCharset returns the charset and the collation of the column, which are the table's if unset.
+func describeColumnCharset(col *model.ColumnInfo, tableInfo *model.TableInfo) string {
+	charset, collate := col.Charset, col.Collate
+	if len(charset) == 0 {
+		charset = tableInfo.Charset
+	}
+	if len(collate) == 0 {
+		collate = tableInfo.Collate
+	}
+	return describeCharset(charset, collate)
+}
+
+// orNone returns "none" for the empty definition in the description, e.g. the column without a default value.
+func orNone(definition string) string {
+	if len(definition) == 0 {
+		return "none"
+	}
+	return definition
+}
+
+func describeCharset(charset, collate string) string {
+	if len(collate) == 0 {
+		return charset
+	}
+	return charset + " COLLATE " + collate
+}
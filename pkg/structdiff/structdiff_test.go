@@ -0,0 +1,136 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package structdiff
+
+import (
+	"testing"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb/parser"
+	"github.com/pingcap/tidb/parser/model"
+)
+
+func TestClient(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&testStructDiffSuite{})
+
+type testStructDiffSuite struct{}
+
+func newTableInfo(c *C, createTableSQL string) *model.TableInfo {
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	c.Assert(err, IsNil)
+	return tableInfo
+}
+
+func descriptions(diffs []Difference) []string {
+	lines := make([]string, 0, len(diffs))
+	for _, diff := range diffs {
+		lines = append(lines, diff.String())
+	}
+	return lines
+}
+
+func (*testStructDiffSuite) TestSameStructure(c *C) {
+	a := newTableInfo(c, "create table `t_1`(`id` int not null, `name` varchar(20) default 'x', primary key(`id`), key idx_name(`name`(5))) default charset=utf8mb4")
+	// the table names, the case of the column and index names and the orders of the indices are not compared.
+	b := newTableInfo(c, "create table `t`(`ID` int not null, `Name` varchar(20) default 'x', key IDX_NAME(`name`(5)), primary key(`id`)) default charset=utf8mb4")
+	c.Assert(DiffTableInfo(a, b, Options{}), HasLen, 0)
+}
+
+func (*testStructDiffSuite) TestColumns(c *C) {
+	a := newTableInfo(c, "create table `t`(`id` int not null, `name` varchar(20), `c` text, `d` int default 1, primary key(`id`))")
+	b := newTableInfo(c, "create table `t`(`id` int not null, `d` int default 2, `name` varchar(30) not null, `e` int, primary key(`id`))")
+	diffs := DiffTableInfo(a, b, Options{})
+	c.Assert(descriptions(diffs), DeepEquals, []string{
+		"changed the default value of column `d`: '1' -> '2'",
+		"moved column `d`: position 4 -> position 2",
+		"changed the type of column `name`: varchar(20) -> varchar(30)",
+		"changed the nullability of column `name`: NULL -> NOT NULL",
+		"changed the default value of column `name`: NULL -> none",
+		"moved column `name`: position 2 -> position 3",
+		"added column `e`: int(11) NULL",
+		"removed column `c`: text NULL",
+	})
+	c.Assert(diffs[2], DeepEquals, Difference{Category: ColumnType, Name: "name", A: "varchar(20)", B: "varchar(30)",
+		Description: "changed the type of column `name`: varchar(20) -> varchar(30)"})
+	c.Assert(diffs[6].Category, Equals, ColumnAdded)
+	c.Assert(diffs[6].Category.Kind(), Equals, "column")
+	c.Assert(diffs[7].Category, Equals, ColumnRemoved)
+	c.Assert(diffs[7].A, Equals, "text NULL")
+
+	// the categories are ignored by the options.
+	diffs = DiffTableInfo(a, b, Options{IgnoreColumnOrder: true, IgnoreNullability: true, IgnoreDefaultValue: true})
+	c.Assert(descriptions(diffs), DeepEquals, []string{
+		"changed the type of column `name`: varchar(20) -> varchar(30)",
+		"added column `e`: int(11) NULL",
+		"removed column `c`: text NULL",
+	})
+}
+
+func (*testStructDiffSuite) TestIndices(c *C) {
+	a := newTableInfo(c, "create table `t`(`id` int not null, `a` int, `b` varchar(20), `c` int, primary key(`id`), key idx_a(`a`), unique key uk_b(`b`), key idx_c(`c`))")
+	b := newTableInfo(c, "create table `t`(`id` int not null, `a` int, `b` varchar(20), `c` int, primary key(`id`, `a`), unique key idx_a(`a`), key uk_b(`b`(10)), key idx_ac(`a`, `c`))")
+	diffs := DiffTableInfo(a, b, Options{})
+	c.Assert(descriptions(diffs), DeepEquals, []string{
+		"changed the nullability of column `a`: NULL -> NOT NULL",
+		"changed the default value of column `a`: NULL -> none",
+		"changed the columns of index `PRIMARY`: (`id`) -> (`id`,`a`)",
+		"changed the uniqueness of index `idx_a`: KEY -> UNIQUE KEY",
+		"changed the uniqueness of index `uk_b`: UNIQUE KEY -> KEY",
+		"changed the columns of index `uk_b`: (`b`) -> (`b`(10))",
+		"added index `idx_ac`: KEY (`a`,`c`)",
+		"removed index `idx_c`: KEY (`c`)",
+	})
+	c.Assert(diffs[3], DeepEquals, Difference{Category: IndexUniqueness, Name: "idx_a", A: "KEY", B: "UNIQUE KEY",
+		Description: "changed the uniqueness of index `idx_a`: KEY -> UNIQUE KEY"})
+	c.Assert(diffs[6].Category.Kind(), Equals, "index")
+	c.Assert(diffs[7].A, Equals, "KEY (`c`)")
+
+	// the primary key of `PKIsHandle` is compared as the index `PRIMARY`.
+	c.Assert(descriptions(DiffTableInfo(newTableInfo(c, "create table `t`(`id` int primary key)"), newTableInfo(c, "create table `t`(`id` int not null)"), Options{})), DeepEquals,
+		[]string{"removed index `PRIMARY`: PRIMARY KEY (`id`)"})
+
+	c.Assert(descriptions(DiffTableInfo(a, b, Options{IgnoreIndices: true, IgnoreNullability: true, IgnoreDefaultValue: true})), HasLen, 0)
+}
+
+func (*testStructDiffSuite) TestCharset(c *C) {
+	a := newTableInfo(c, "create table `t`(`a` varchar(20), `b` varchar(20) charset latin1, `c` int, `d` blob) default charset=utf8mb4")
+	b := newTableInfo(c, "create table `t`(`a` varchar(20) collate utf8mb4_general_ci, `b` varchar(20), `c` int, `d` blob) default charset=latin1")
+	diffs := DiffTableInfo(a, b, Options{})
+	c.Assert(descriptions(diffs), DeepEquals, []string{
+		"changed the charset of column `a`: utf8mb4 COLLATE utf8mb4_bin -> utf8mb4 COLLATE utf8mb4_general_ci",
+		"changed the charset of the table: utf8mb4 COLLATE utf8mb4_bin -> latin1 COLLATE latin1_bin",
+	})
+	c.Assert(diffs[1], DeepEquals, Difference{Category: TableCharset, A: "utf8mb4 COLLATE utf8mb4_bin", B: "latin1 COLLATE latin1_bin",
+		Description: "changed the charset of the table: utf8mb4 COLLATE utf8mb4_bin -> latin1 COLLATE latin1_bin"})
+	c.Assert(diffs[1].Category.Kind(), Equals, "table")
+	c.Assert(DiffTableInfo(a, b, Options{IgnoreCharset: true}), HasLen, 0)
+}
+
+func (*testStructDiffSuite) TestDescribe(c *C) {
+	tableInfo := newTableInfo(c, "create table `t`(`a` int not null, `b` varchar(10) default 'it''s', `c` timestamp default current_timestamp, `d` int, `e` int as (`a` + 1), unique key uk(`a`, `b`(5)))")
+	c.Assert(DescribeColumn(tableInfo.Columns[0]), Equals, "int(11) NOT NULL")
+	c.Assert(DescribeColumn(tableInfo.Columns[1]), Equals, "varchar(10) NULL")
+	c.Assert(DescribeDefaultValue(tableInfo.Columns[0]), Equals, "")
+	c.Assert(DescribeDefaultValue(tableInfo.Columns[1]), Equals, "'it''s'")
+	c.Assert(DescribeDefaultValue(tableInfo.Columns[2]), Equals, "CURRENT_TIMESTAMP")
+	c.Assert(DescribeDefaultValue(tableInfo.Columns[3]), Equals, "NULL")
+	c.Assert(DescribeDefaultValue(tableInfo.Columns[4]), Equals, "")
+	c.Assert(DescribeIndex(tableInfo.Indices[0]), Equals, "UNIQUE KEY (`a`,`b`(5))")
+	c.Assert(FindIndex(TableIndices(tableInfo), "UK"), Equals, tableInfo.Indices[0])
+	c.Assert(FindIndex(TableIndices(tableInfo), "PRIMARY"), IsNil)
+}
@@ -0,0 +1,379 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apply
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/fixsql"
+	"go.uber.org/zap"
+)
+
+const (
+	// StateFile records how many statements of each file have been applied.
+	StateFile = "apply_state.json"
+	// FailedFile collects the statements failed to apply.
+	FailedFile = "failed.sql"
+
+	// DefaultBatchSize is the default number of statements in one transaction.
+	DefaultBatchSize = 100
+)
+
+// State is the apply progress saved in `StateFile`, which makes the apply resumable.
+type State struct {
+	// Files is the map of `file name` => `number of statements handled`.
+	Files map[string]int `json:"files"`
+}
+
+// FileResult is the result of applying one fix sql file.
+type FileResult struct {
+	Name string
+	// Statements is the number of statements in the file.
+	Statements int
+	// Skipped is the number of statements handled by the previous apply.
+	Skipped int
+	Applied int
+	Failed  int
+	// ExpectedRows is the number of rows the pending statements should affect.
+	ExpectedRows int64
+	AffectedRows int64
+}
+
+// Applier applies the fix sql files in the directory to the database.
+type Applier struct {
+	db        *sql.DB
+	dir       string
+	batchSize int
+	dryRun    bool
+	// force applies the files failed to be verified by the manifest.
+	force bool
+
+	state  *State
+	output io.Writer
+	failed *os.File
+}
+
+// NewApplier returns an Applier. The `db` can be nil if `dryRun` is true.
+func NewApplier(db *sql.DB, dir string, batchSize int, dryRun bool) *Applier {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	return &Applier{
+		db:        db,
+		dir:       dir,
+		batchSize: batchSize,
+		dryRun:    dryRun,
+		output:    os.Stdout,
+	}
+}
+
+// SetOutput sets the writer of the progress.
+func (a *Applier) SetOutput(output io.Writer) {
+	a.output = output
+}
+
+// SetForce sets whether to apply the files failed to be verified by the manifest.
+func (a *Applier) SetForce(force bool) {
+	a.force = force
+}
+
+// Apply applies the fix sql files in order of the file name.
+// The failed statements are collected into `FailedFile` instead of aborting the apply.
+func (a *Applier) Apply(ctx context.Context) ([]*FileResult, error) {
+	defer func() {
+		if a.failed != nil {
+			a.failed.Close()
+			a.failed = nil
+		}
+	}()
+	files, err := a.listFiles()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	mismatches, err := a.verifyFiles(files)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, mismatch := range mismatches {
+		fmt.Fprintf(a.output, "%s is not verified: %s\n", mismatch.Name, mismatch.Reason)
+	}
+	if len(mismatches) > 0 && !a.dryRun {
+		if !a.force {
+			return nil, errors.Errorf("%d fix sql files are not verified by %s, use --force to apply them anyway", len(mismatches), fixsql.ManifestFile)
+		}
+		log.Warn("apply the fix sql files not verified by the manifest", zap.Int("count", len(mismatches)))
+	}
+	if err = a.loadState(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	results := make([]*FileResult, 0, len(files))
+	for i, name := range files {
+		content, err := fixsql.ReadFile(filepath.Join(a.dir, name))
+		if err != nil {
+			return results, errors.Trace(err)
+		}
+		stmts := fixsql.SplitStatements(string(content))
+		offset := a.state.Files[name]
+		if offset > len(stmts) {
+			return results, errors.Errorf("the file %s is changed after the last apply, please remove %s and start over again", name, StateFile)
+		}
+		result := &FileResult{
+			Name:       name,
+			Statements: len(stmts),
+			Skipped:    offset,
+		}
+		for _, stmt := range stmts[offset:] {
+			result.ExpectedRows += expectedRows(stmt)
+		}
+		results = append(results, result)
+
+		if a.dryRun || offset == len(stmts) {
+			fmt.Fprintf(a.output, "[%d/%d] %s: %d statements, %d pending\n", i+1, len(files), name, len(stmts), len(stmts)-offset)
+			continue
+		}
+		if err = a.applyFile(ctx, stmts, result, fmt.Sprintf("[%d/%d]", i+1, len(files))); err != nil {
+			return results, errors.Trace(err)
+		}
+	}
+	return results, nil
+}
+
+// listFiles returns the fix sql files in the directory, sorted by name.
+// The compressed files are included, and the statements of generate-struct-fix are excluded.
+func (a *Applier) listFiles() ([]string, error) {
+	entries, err := os.ReadDir(a.dir)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	files := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if _, ok := fixsql.TrimExt(name); entry.IsDir() || !ok || name == FailedFile || name == fixsql.StructFixFile {
+			continue
+		}
+		files = append(files, name)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// verifyFiles returns the files failed to be verified by the manifest.
+func (a *Applier) verifyFiles(files []string) ([]*fixsql.Mismatch, error) {
+	mismatches := make([]*fixsql.Mismatch, 0)
+	manifest, err := fixsql.LoadManifest(a.dir)
+	if err != nil {
+		if !os.IsNotExist(errors.Cause(err)) {
+			return nil, errors.Trace(err)
+		}
+		for _, name := range files {
+			mismatches = append(mismatches, &fixsql.Mismatch{Name: name, Reason: fmt.Sprintf("%s is not found", fixsql.ManifestFile)})
+		}
+		return mismatches, nil
+	}
+	listed := make(map[string]struct{}, len(manifest.Files))
+	for _, meta := range manifest.Files {
+		listed[meta.Name] = struct{}{}
+	}
+	toApply := make(map[string]struct{}, len(files))
+	for _, name := range files {
+		toApply[name] = struct{}{}
+		if _, ok := listed[name]; !ok {
+			mismatches = append(mismatches, &fixsql.Mismatch{Name: name, Reason: fmt.Sprintf("file is not listed in %s", fixsql.ManifestFile)})
+		}
+	}
+	verified, err := fixsql.VerifyManifest(a.dir, manifest)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, mismatch := range verified {
+		_, isListed := listed[mismatch.Name]
+		if _, ok := toApply[mismatch.Name]; ok && isListed {
+			mismatches = append(mismatches, mismatch)
+		}
+	}
+	return mismatches, nil
+}
+
+func (a *Applier) applyFile(ctx context.Context, stmts []string, result *FileResult, prefix string) error {
+	conn, err := a.db.Conn(ctx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer conn.Close()
+
+	// the session variables, e.g. time_zone, are needed by the rest statements.
+	for _, stmt := range stmts[:result.Skipped] {
+		if isSessionStatement(stmt) {
+			if _, err := conn.ExecContext(ctx, stmt); err != nil {
+				return errors.Trace(err)
+			}
+		}
+	}
+
+	for start := result.Skipped; start < len(stmts); start += a.batchSize {
+		end := start + a.batchSize
+		if end > len(stmts) {
+			end = len(stmts)
+		}
+		batch := stmts[start:end]
+		affected, err := execBatch(ctx, conn, batch)
+		if err != nil {
+			if ctx.Err() != nil {
+				return errors.Trace(ctx.Err())
+			}
+			log.Warn("apply batch failed, retry the statements one by one", zap.String("file", result.Name), zap.Int("offset", start), zap.Error(err))
+			if err = a.applyOneByOne(ctx, conn, batch, result); err != nil {
+				return errors.Trace(err)
+			}
+		} else {
+			result.Applied += len(batch)
+			result.AffectedRows += affected
+		}
+
+		a.state.Files[result.Name] = end
+		if err = a.saveState(); err != nil {
+			return errors.Trace(err)
+		}
+		fmt.Fprintf(a.output, "%s %s: %d/%d statements applied, %d failed\n", prefix, result.Name, result.Skipped+result.Applied+result.Failed, result.Statements, result.Failed)
+	}
+	return nil
+}
+
+// execBatch executes the statements in one transaction.
+func execBatch(ctx context.Context, conn *sql.Conn, stmts []string) (int64, error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	affected := int64(0)
+	for _, stmt := range stmts {
+		res, err := tx.ExecContext(ctx, stmt)
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				log.Warn("failed to rollback", zap.Error(rbErr))
+			}
+			return 0, errors.Trace(err)
+		}
+		if n, err := res.RowsAffected(); err == nil {
+			affected += n
+		}
+	}
+	return affected, errors.Trace(tx.Commit())
+}
+
+// applyOneByOne executes the statements one by one, and collects the failed ones.
+func (a *Applier) applyOneByOne(ctx context.Context, conn *sql.Conn, stmts []string, result *FileResult) error {
+	for _, stmt := range stmts {
+		res, err := conn.ExecContext(ctx, stmt)
+		if err != nil {
+			if ctx.Err() != nil {
+				return errors.Trace(ctx.Err())
+			}
+			log.Error("apply statement failed", zap.String("file", result.Name), zap.String("sql", stmt), zap.Error(err))
+			result.Failed++
+			if err = a.writeFailed(result.Name, stmt, err); err != nil {
+				return errors.Trace(err)
+			}
+			continue
+		}
+		result.Applied++
+		if n, err := res.RowsAffected(); err == nil {
+			result.AffectedRows += n
+		}
+	}
+	return nil
+}
+
+func (a *Applier) writeFailed(name, stmt string, stmtErr error) error {
+	if a.failed == nil {
+		f, err := os.OpenFile(filepath.Join(a.dir, FailedFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		a.failed = f
+	}
+	errMsg := strings.ReplaceAll(stmtErr.Error(), "\n", " ")
+	_, err := fmt.Fprintf(a.failed, "-- file: %s, error: %s\n%s\n", name, errMsg, stmt)
+	return errors.Trace(err)
+}
+
+func (a *Applier) loadState() error {
+	a.state = &State{Files: make(map[string]int)}
+	data, err := os.ReadFile(filepath.Join(a.dir, StateFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Trace(err)
+	}
+	if err = json.Unmarshal(data, a.state); err != nil {
+		return errors.Annotatef(err, "failed to parse %s", StateFile)
+	}
+	if a.state.Files == nil {
+		a.state.Files = make(map[string]int)
+	}
+	return nil
+}
+
+// saveState writes the state into a temporary file then renames it,
+// so that the state file is always complete.
+func (a *Applier) saveState() error {
+	data, err := json.Marshal(a.state)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	tmpFile := filepath.Join(a.dir, StateFile+".tmp")
+	if err = os.WriteFile(tmpFile, data, 0644); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(os.Rename(tmpFile, filepath.Join(a.dir, StateFile)))
+}
+
+// PrintResults prints the final report of the apply.
+func PrintResults(w io.Writer, results []*FileResult, dryRun bool) {
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	if dryRun {
+		table.SetHeader([]string{"File", "Statements", "Pending", "Expected rows"})
+	} else {
+		table.SetHeader([]string{"File", "Statements", "Applied", "Failed", "Expected rows", "Affected rows"})
+	}
+	failed := 0
+	for _, r := range results {
+		failed += r.Failed
+		if dryRun {
+			table.Append([]string{r.Name, strconv.Itoa(r.Statements), strconv.Itoa(r.Statements - r.Skipped), strconv.FormatInt(r.ExpectedRows, 10)})
+		} else {
+			table.Append([]string{r.Name, strconv.Itoa(r.Statements), strconv.Itoa(r.Applied), strconv.Itoa(r.Failed), strconv.FormatInt(r.ExpectedRows, 10), strconv.FormatInt(r.AffectedRows, 10)})
+		}
+	}
+	table.Render()
+	fmt.Fprint(w, tableString.String())
+	if failed > 0 {
+		fmt.Fprintf(w, "%d statements failed to apply, they are collected into %s\n", failed, FailedFile)
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apply
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/fixsql"
+	"github.com/stretchr/testify/require"
+)
+
+const fixSQL = "-- table: test.t\n" +
+	"-- range in sequence: Full\n" +
+	"set @@session.time_zone = \"+0:00\";\n" +
+	"REPLACE INTO `test`.`t`(`a`,`b`) VALUES (1,'x;\\'y');\n" +
+	"/*\n  DIFF COLUMNS ╏ `B`\n  source data  ╏ 'a;'\n*/\n" +
+	"REPLACE INTO `test`.`t`(`a`,`b`) VALUES (2,'a;');\n" +
+	"DELETE FROM `test`.`t` WHERE `a` = 3 AND `b` = 'c' LIMIT 1;\n"
+
+func TestStatements(t *testing.T) {
+	stmts := fixsql.SplitStatements(fixSQL)
+	require.Equal(t, []string{
+		"set @@session.time_zone = \"+0:00\";",
+		"REPLACE INTO `test`.`t`(`a`,`b`) VALUES (1,'x;\\'y');",
+		"/*\n  DIFF COLUMNS ╏ `B`\n  source data  ╏ 'a;'\n*/\nREPLACE INTO `test`.`t`(`a`,`b`) VALUES (2,'a;');",
+		"DELETE FROM `test`.`t` WHERE `a` = 3 AND `b` = 'c' LIMIT 1;",
+	}, stmts)
+
+	require.True(t, isSessionStatement(stmts[0]))
+	require.False(t, isSessionStatement(stmts[2]))
+	expected := []int64{0, 1, 2, 1}
+	for i, stmt := range stmts {
+		require.Equal(t, expected[i], expectedRows(stmt))
+	}
+
+	require.Equal(t, []string{"select 1"}, fixsql.SplitStatements("select 1\n-- comment"))
+	require.Equal(t, int64(2), expectedRows("/* a */\nINSERT INTO `test`.`t`(`a`) VALUES (1) ON DUPLICATE KEY UPDATE `a` = VALUES(`a`);"))
+	require.Equal(t, int64(1), expectedRows("INSERT INTO `test`.`t`(`a`) VALUES (1);"))
+}
+
+func TestApply(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "test:t:0:0-0:0:1.sql"), []byte(fixSQL), 0644))
+	// the statements of generate-struct-fix are never applied.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, fixsql.StructFixFile), []byte("ALTER TABLE `test`.`t` DROP COLUMN `c`;\n"), 0644))
+	stmts := fixsql.SplitStatements(fixSQL)
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+
+	// the files are not verified without the manifest.
+	applier := NewApplier(db, dir, 2, false)
+	applier.SetOutput(&bytes.Buffer{})
+	_, err = applier.Apply(context.Background())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "use --force")
+
+	require.NoError(t, fixsql.AppendCompleted(dir, "test:t:0:0-0:0:1.sql", int64(len(fixSQL))))
+	manifest, err := fixsql.GenerateManifest(dir, nil)
+	require.NoError(t, err)
+	require.NoError(t, fixsql.WriteManifest(dir, manifest))
+
+	// the first batch succeeds, the second batch fails on the last statement.
+	mock.ExpectBegin()
+	mock.ExpectExec(stmts[0]).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(stmts[1]).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(stmts[2]).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(stmts[3]).WillReturnError(errors.New("mock error"))
+	mock.ExpectRollback()
+	mock.ExpectExec(stmts[2]).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(stmts[3]).WillReturnError(errors.New("mock error"))
+
+	results, err := applier.Apply(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, results, 1)
+	require.Equal(t, &FileResult{
+		Name:         "test:t:0:0-0:0:1.sql",
+		Statements:   4,
+		Applied:      3,
+		Failed:       1,
+		ExpectedRows: 4,
+		AffectedRows: 3,
+	}, results[0])
+
+	failed, err := os.ReadFile(filepath.Join(dir, FailedFile))
+	require.NoError(t, err)
+	require.Equal(t, "-- file: test:t:0:0-0:0:1.sql, error: mock error\n"+stmts[3]+"\n", string(failed))
+
+	// all the statements are handled, apply again does nothing.
+	results, err = applier.Apply(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Equal(t, 4, results[0].Skipped)
+
+	// resume from the middle of the file, the session statements are replayed.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, StateFile), []byte(`{"files":{"test:t:0:0-0:0:1.sql":3}}`), 0644))
+	mock.ExpectExec(stmts[0]).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectBegin()
+	mock.ExpectExec(stmts[3]).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	results, err = applier.Apply(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Equal(t, 1, results[0].Applied)
+	require.Equal(t, int64(1), results[0].AffectedRows)
+
+	// dry run doesn't need the database.
+	require.NoError(t, os.Remove(filepath.Join(dir, StateFile)))
+	applier = NewApplier(nil, dir, 2, true)
+	applier.SetOutput(&bytes.Buffer{})
+	results, err = applier.Apply(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 0, results[0].Applied)
+	require.Equal(t, int64(4), results[0].ExpectedRows)
+	buf := &bytes.Buffer{}
+	PrintResults(buf, results, true)
+	require.Contains(t, buf.String(), "| test:t:0:0-0:0:1.sql |          4 |       4 |             4 |")
+}
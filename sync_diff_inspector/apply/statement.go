@@ -0,0 +1,57 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apply
+
+import (
+	"strings"
+)
+
+// stripLeadingComments returns the statement without the leading block comments.
+func stripLeadingComments(stmt string) string {
+	stmt = strings.TrimSpace(stmt)
+	for strings.HasPrefix(stmt, "/*") {
+		end := strings.Index(stmt, "*/")
+		if end < 0 {
+			return ""
+		}
+		stmt = strings.TrimSpace(stmt[end+2:])
+	}
+	return stmt
+}
+
+// isSessionStatement returns true if the statement only changes the session,
+// e.g. `set @@session.time_zone = "+0:00";`.
+func isSessionStatement(stmt string) bool {
+	return strings.HasPrefix(strings.ToUpper(stripLeadingComments(stmt)), "SET ")
+}
+
+// expectedRows returns the number of rows the fix sql is expected to affect.
+// `REPLACE` and `INSERT ... ON DUPLICATE KEY UPDATE` with the annotation update
+// an existing row, which is counted as 2 rows by MySQL.
+func expectedRows(stmt string) int64 {
+	body := strings.ToUpper(stripLeadingComments(stmt))
+	switch {
+	case strings.HasPrefix(body, "DELETE "):
+		return 1
+	case strings.HasPrefix(body, "REPLACE "), strings.HasPrefix(body, "INSERT ") && strings.Contains(body, " ON DUPLICATE KEY UPDATE "):
+		if strings.HasPrefix(strings.TrimSpace(stmt), "/*") {
+			return 2
+		}
+		return 1
+	case strings.HasPrefix(body, "INSERT "):
+		return 1
+	default:
+		return 0
+	}
+}
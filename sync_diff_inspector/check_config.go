@@ -0,0 +1,227 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/utils"
+	"github.com/pingcap/tidb/parser/model"
+	"go.uber.org/zap"
+)
+
+// tablePlan is the plan to compare a table, which is printed by `--dry-run`.
+type tablePlan struct {
+	table        string
+	sourceTables []string
+	// structure is the result of comparing the structures.
+	structure string
+	// index is the index used to split the chunks, empty if no index.
+	index           string
+	estimatedRows   int64
+	chunkSize       int64
+	estimatedChunks int64
+}
+
+// checkConfig connects to all the databases and resolves the tables to compare by the config,
+// then prints the plan of the comparison without reading any data.
+// It returns false if any database is unreachable or any table can't be resolved.
+func checkConfig(ctx context.Context, cfg *config.Config, w io.Writer) bool {
+	downstream, upstream, missingTables, skippedObjects, err := source.NewSources(ctx, cfg)
+	if err != nil {
+		fmt.Fprintf(w, "Fail to connect to the databases or resolve the tables to compare.\n%s\n", err.Error())
+		log.Error("failed to initialize the sources", zap.Error(err))
+		return false
+	}
+	defer upstream.Close()
+	defer downstream.Close()
+
+	printDataSources(w, cfg)
+	tables := downstream.GetTables()
+	plans := make([]*tablePlan, 0, len(tables))
+	for i, table := range tables {
+		sourceTableInfos, err := upstream.GetSourceStructInfo(ctx, i)
+		if err != nil {
+			fmt.Fprintf(w, "Fail to get the structure of the source tables of %s.\n%s\n", dbutil.TableName(table.Schema, table.Table), err.Error())
+			log.Error("failed to get the source table structure", zap.String("table", dbutil.TableName(table.Schema, table.Table)), zap.Error(err))
+			return false
+		}
+		rows, err := utils.GetTableRowsEstimate(ctx, downstream.GetDB(), table.Schema, table.Table)
+		if err != nil {
+			log.Warn("failed to estimate the row count", zap.String("table", dbutil.TableName(table.Schema, table.Table)), zap.Error(err))
+		}
+		plans = append(plans, newTablePlan(sourceTableInfos, table, rows, cfg.SkipNoPKTables || !cfg.CompareNoIndexTables))
+	}
+	printTablePlans(w, plans)
+	if len(skippedObjects) > 0 {
+		printSkippedObjects(w, skippedObjects)
+	}
+	if len(missingTables) > 0 {
+		printMissingTables(w, missingTables)
+		// the missing tables are resolved failed only if they cause Fail.
+		return !cfg.FailOnMissingTables
+	}
+	return true
+}
+
+// printDataSources prints the effective connection settings of the data sources, the connection limits are read
+// from the connections, which are decided by `check-thread-count`, `max-open-conns` and the data sources.
+func printDataSources(w io.Writer, cfg *config.Config) {
+	names := append([]string{cfg.Task.Target}, cfg.Task.Source...)
+	instances := append([]*config.DataSource{cfg.Task.TargetInstance}, cfg.Task.SourceInstances...)
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetHeader([]string{"Data Source", "Address", "Dial Timeout", "Read Timeout", "Write Timeout", "Max Open Conns", "Max Idle Conns", "Conn Max Lifetime"})
+	for i, instance := range instances {
+		// the settings are checked by `CheckConfig`.
+		dialTimeout, _ := instance.GetDialTimeout()
+		readTimeout, _ := instance.GetReadTimeout()
+		writeTimeout, _ := instance.GetWriteTimeout()
+		lifetime, _ := instance.GetConnMaxLifetime()
+		maxOpenConns, maxIdleConns := "unlimited", "unlimited"
+		if n := instance.Conn.Stats().MaxOpenConnections; n > 0 {
+			maxOpenConns = fmt.Sprint(n)
+			// the idle connections are limited to the open connections by sql.DB.
+			maxIdleConns = maxOpenConns
+			if instance.MaxIdleConns > 0 && instance.MaxIdleConns < n {
+				maxIdleConns = fmt.Sprint(instance.MaxIdleConns)
+			}
+		} else if instance.MaxIdleConns > 0 {
+			maxIdleConns = fmt.Sprint(instance.MaxIdleConns)
+		}
+		table.Append([]string{names[i], fmt.Sprintf("%s:%d", instance.Host, instance.Port), dialTimeout.String(),
+			readTimeout.String(), writeTimeout.String(), maxOpenConns, maxIdleConns, lifetime.String()})
+	}
+	table.Render()
+	fmt.Fprint(w, tableString.String())
+}
+
+func printMissingTables(w io.Writer, missingTables []*common.MissingTable) {
+	fmt.Fprintf(w, "The following tables only exist on one side, and they will not be compared\n")
+	for _, table := range missingTables {
+		side := "target"
+		if table.MissingOnTarget {
+			side = "sources"
+		}
+		fmt.Fprintf(w, "%s only exists on the %s\n", dbutil.TableName(table.Schema, table.Table), side)
+	}
+}
+
+func printSkippedObjects(w io.Writer, skippedObjects []*common.SkippedObject) {
+	fmt.Fprintf(w, "The following objects can't be compared, and they will be skipped\n")
+	for _, object := range skippedObjects {
+		side := "target"
+		if object.OnSource {
+			side = "sources"
+		}
+		fmt.Fprintf(w, "%s on the %s: %s\n", dbutil.TableName(object.Schema, object.Table), side, object.Reason)
+	}
+}
+
+// newTablePlan makes the plan to compare the table, the chunk size is calculated in the same way as the random splitter.
+// The tables without primary key or unique key are skipped if skipNoPKTables is true, or compared in one chunk.
+func newTablePlan(sourceTableInfos []*model.TableInfo, table *common.TableDiff, estimatedRows int64, skipNoPKTables bool) *tablePlan {
+	plan := &tablePlan{
+		table:         dbutil.TableName(table.Schema, table.Table),
+		sourceTables:  make([]string, 0, len(sourceTableInfos)),
+		estimatedRows: estimatedRows,
+	}
+	for _, tableInfo := range sourceTableInfos {
+		plan.sourceTables = append(plan.sourceTables, tableInfo.Name.O)
+	}
+
+	isEqual, isSkip := utils.CompareStruct(sourceTableInfos, table.Info)
+	switch {
+	case isSkip:
+		plan.structure = "not comparable"
+		return plan
+	case !isEqual:
+		plan.structure = "not equal"
+	default:
+		plan.structure = "equal"
+	}
+	if table.NoPKFallback && skipNoPKTables {
+		plan.structure += ", no pk (skipped)"
+		return plan
+	}
+	if table.NoPKFallback {
+		// the whole table is compared in one chunk.
+		plan.chunkSize = estimatedRows
+		plan.estimatedChunks = 1
+		return plan
+	}
+
+	indices := dbutil.FindAllIndex(table.Info)
+	if fields := utils.ParseIndexFields(table.Fields); len(fields) > 0 {
+		// the index is specified by `index-fields`.
+		index, err := utils.FindIndexByFields(table.Info, fields)
+		switch {
+		case err != nil:
+			plan.index = fmt.Sprintf("%s (not found)", strings.Join(fields, ","))
+		case index == nil:
+			plan.index = fmt.Sprintf("%s (no index)", strings.Join(fields, ","))
+		default:
+			plan.index = index.Name.O
+		}
+	} else if len(indices) > 0 {
+		plan.index = indices[0].Name.O
+	}
+	plan.chunkSize = table.ChunkSize
+	if plan.chunkSize <= 0 {
+		if len(indices) > 0 {
+			plan.chunkSize = utils.CalculateChunkSize(estimatedRows)
+		} else {
+			// no index, the table is compared in one chunk.
+			plan.chunkSize = estimatedRows
+		}
+	}
+	plan.estimatedChunks = 1
+	if plan.chunkSize > 0 && estimatedRows > plan.chunkSize {
+		plan.estimatedChunks = (estimatedRows + plan.chunkSize - 1) / plan.chunkSize
+	}
+	return plan
+}
+
+func printTablePlans(w io.Writer, plans []*tablePlan) {
+	fmt.Fprintf(w, "A total of %d tables will be compared\n", len(plans))
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetHeader([]string{"Table", "Source Tables", "Structure", "Index", "Estimated Rows", "Chunk Size", "Estimated Chunks"})
+	totalChunks := int64(0)
+	for _, plan := range plans {
+		if plan.estimatedChunks == 0 {
+			table.Append([]string{plan.table, strings.Join(plan.sourceTables, ","), plan.structure, "-", fmt.Sprint(plan.estimatedRows), "-", "-"})
+			continue
+		}
+		index := plan.index
+		if len(index) == 0 {
+			index = "-"
+		}
+		table.Append([]string{plan.table, strings.Join(plan.sourceTables, ","), plan.structure, index,
+			fmt.Sprint(plan.estimatedRows), fmt.Sprint(plan.chunkSize), fmt.Sprint(plan.estimatedChunks)})
+		totalChunks += plan.estimatedChunks
+	}
+	table.Render()
+	fmt.Fprint(w, tableString.String())
+	fmt.Fprintf(w, "A total of about %d chunks will be compared, the rows are estimated from information_schema\n", totalChunks)
+}
@@ -0,0 +1,107 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"testing"
+
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/pingcap/tidb/parser"
+	"github.com/pingcap/tidb/parser/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTablePlan(t *testing.T) {
+	tableInfo, err := dbutil.GetTableInfoBySQL("create table `test`.`t`(`a` int, `b` int, primary key(`a`))", parser.New())
+	require.NoError(t, err)
+	noPKTableInfo, err := dbutil.GetTableInfoBySQL("create table `test`.`t2`(`a` int, `b` int)", parser.New())
+	require.NoError(t, err)
+	otherTableInfo, err := dbutil.GetTableInfoBySQL("create table `test`.`t3`(`a` int)", parser.New())
+	require.NoError(t, err)
+
+	table := &common.TableDiff{Schema: "test", Table: "t", Info: tableInfo}
+	plan := newTablePlan([]*model.TableInfo{tableInfo, tableInfo}, table, 120000, false)
+	require.Equal(t, &tablePlan{
+		table:           "`test`.`t`",
+		sourceTables:    []string{"t", "t"},
+		structure:       "equal",
+		index:           "PRIMARY",
+		estimatedRows:   120000,
+		chunkSize:       50000,
+		estimatedChunks: 3,
+	}, plan)
+
+	// the chunk size in the table config is used.
+	table.ChunkSize = 1000
+	plan = newTablePlan([]*model.TableInfo{tableInfo}, table, 120000, false)
+	require.Equal(t, int64(120), plan.estimatedChunks)
+
+	// the index specified by index-fields is used, and the invalid one is flagged.
+	table.Fields = "a"
+	require.Equal(t, "PRIMARY", newTablePlan([]*model.TableInfo{tableInfo}, table, 120000, false).index)
+	table.Fields = "b"
+	require.Equal(t, "b (no index)", newTablePlan([]*model.TableInfo{tableInfo}, table, 120000, false).index)
+	table.Fields = "idx_c"
+	require.Equal(t, "idx_c (not found)", newTablePlan([]*model.TableInfo{tableInfo}, table, 120000, false).index)
+	table.Fields = ""
+
+	// the table without index is compared in one chunk.
+	noPKTable := &common.TableDiff{Schema: "test", Table: "t2", Info: noPKTableInfo, NoPKFallback: true}
+	plan = newTablePlan([]*model.TableInfo{noPKTableInfo}, noPKTable, 120000, false)
+	require.Equal(t, "", plan.index)
+	require.Equal(t, int64(1), plan.estimatedChunks)
+	plan = newTablePlan([]*model.TableInfo{noPKTableInfo}, noPKTable, 120000, true)
+	require.Equal(t, "equal, no pk (skipped)", plan.structure)
+	require.Equal(t, int64(0), plan.estimatedChunks)
+
+	// the structures are not comparable.
+	plan = newTablePlan([]*model.TableInfo{otherTableInfo}, table, 100, false)
+	require.Equal(t, "not comparable", plan.structure)
+	require.Equal(t, int64(0), plan.estimatedChunks)
+
+	buf := new(bytes.Buffer)
+	printTablePlans(buf, []*tablePlan{newTablePlan([]*model.TableInfo{tableInfo}, table, 120000, false), plan})
+	require.Contains(t, buf.String(), "A total of 2 tables will be compared\n")
+	require.Contains(t, buf.String(), "| `test`.`t` | t             | equal          | PRIMARY |         120000 |       1000 |              120 |\n")
+	require.Contains(t, buf.String(), "| `test`.`t` | t3            | not comparable | -       |            100 | -          | -                |\n")
+	require.Contains(t, buf.String(), "A total of about 120 chunks will be compared")
+}
+
+func TestPrintDataSources(t *testing.T) {
+	openDB := func(maxOpenConns int) *sql.DB {
+		// the connections are not established until they are used.
+		db, err := sql.Open("mysql", "root:@tcp(127.0.0.1:4000)/")
+		require.NoError(t, err)
+		db.SetMaxOpenConns(maxOpenConns)
+		return db
+	}
+	cfg := config.NewConfig()
+	cfg.Task.Target = "tidb0"
+	cfg.Task.TargetInstance = &config.DataSource{Host: "127.0.0.1", Port: 4000, Conn: openDB(7)}
+	cfg.Task.Source = []string{"mysql1"}
+	cfg.Task.SourceInstances = []*config.DataSource{{
+		Host: "127.0.0.1", Port: 3306, DialTimeout: "5s", ReadTimeout: "1m", MaxIdleConns: 2, ConnMaxLifetime: "10m", Conn: openDB(5),
+	}}
+	defer cfg.Task.TargetInstance.Conn.Close()
+	defer cfg.Task.SourceInstances[0].Conn.Close()
+
+	buf := new(bytes.Buffer)
+	printDataSources(buf, cfg)
+	require.Contains(t, buf.String(), "| tidb0       | 127.0.0.1:4000 | 30s          | 30m0s        | 30m0s         |              7 |              7 | 1h0m0s            |\n")
+	require.Contains(t, buf.String(), "| mysql1      | 127.0.0.1:3306 | 5s           | 1m0s         | 30m0s         |              5 |              2 | 10m0s             |\n")
+}
@@ -17,11 +17,16 @@ import (
 	"container/heap"
 	"context"
 	"encoding/json"
+	"fmt"
 	"os"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
 	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
 	"github.com/pingcap/tidb-tools/sync_diff_inspector/report"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
 
 	"github.com/pingcap/tidb-tools/sync_diff_inspector/chunk"
 	"github.com/siddontang/go/ioutil2"
@@ -48,6 +53,10 @@ const (
 	IgnoreState = "ignore"
 )
 
+// fullSnapshotInterval is the number of the increments of the report saved after a full snapshot,
+// then the next one is a full snapshot, so the increments to apply when loading the checkpoint are bounded.
+const fullSnapshotInterval = 30
+
 type Node struct {
 	State string `json:"state"` // indicate the state ("success" or "failed") of the chunk
 
@@ -121,6 +130,14 @@ type nodeHeap struct {
 // latest previous exit point (due to error or intention).
 type Checkpoint struct {
 	hp *nodeHeap
+	// chunkingParams are the chunking parameters of the tables in the current comparison,
+	// which are saved with the chunk and checked when loading the chunk.
+	chunkingParams []*ChunkingParams
+	// snapshotID is the id of the full snapshot saved in this run, which the increments saved after it refer to.
+	// It's 0 if the next one should be a full snapshot, e.g. none is saved in this run or saving an increment failed.
+	snapshotID int64
+	// increments is the number of the increments saved after the full snapshot.
+	increments int
 }
 
 // SaveState contains the information of the latest checked chunk and state of `report`
@@ -128,6 +145,102 @@ type Checkpoint struct {
 type SavedState struct {
 	Chunk  *Node          `json:"chunk-info"`
 	Report *report.Report `json:"report-info"`
+	// ChunkingParams is nil in the checkpoint saved by the older versions.
+	ChunkingParams []*ChunkingParams `json:"chunking-params,omitempty"`
+	// SnapshotID is the id of the full snapshot of the report, which is 0 in the checkpoint saved by the older versions.
+	SnapshotID int64 `json:"snapshot-id,omitempty"`
+}
+
+// SavedIncrement is an increment of the report saved after the full snapshot `SnapshotID`, which is appended to
+// the increment file of the checkpoint, see `report.Report.GetIncrement`. The chunk replaces the chunk of the
+// full snapshot when it's loaded.
+type SavedIncrement struct {
+	SnapshotID int64          `json:"snapshot-id"`
+	Chunk      *Node          `json:"chunk-info"`
+	Report     *report.Report `json:"report-info"`
+}
+
+// IncrementFileName returns the name of the file which the increments of the checkpoint `fileName` are appended to.
+func IncrementFileName(fileName string) string {
+	return fileName + ".inc"
+}
+
+// ChunkingParams are the parameters to split the chunks of a table, the ids of the chunks in the checkpoint
+// don't match the chunks split again if they are changed.
+type ChunkingParams struct {
+	Table       string `json:"table"`
+	ChunkSize   int64  `json:"chunk-size"`
+	IndexFields string `json:"index-fields"`
+	Range       string `json:"range"`
+	Collation   string `json:"collation"`
+	// SplitByPartition is omitted for the checkpoint saved by the older versions.
+	SplitByPartition bool `json:"split-by-partition,omitempty"`
+	AdaptiveChunking bool `json:"adaptive-chunking,omitempty"`
+}
+
+// NewChunkingParams returns the chunking parameters of the table.
+func NewChunkingParams(table *common.TableDiff) *ChunkingParams {
+	return &ChunkingParams{
+		Table:       dbutil.TableName(table.Schema, table.Table),
+		ChunkSize:   table.ChunkSize,
+		IndexFields: table.Fields,
+		Range:       table.Range,
+		Collation:   table.Collation,
+
+		SplitByPartition: table.SplitByPartition,
+		AdaptiveChunking: table.AdaptiveChunking,
+	}
+}
+
+// diff returns the changed parameters from `p` to `other`, e.g. "chunk-size: 1000 -> 2000".
+func (p *ChunkingParams) diff(other *ChunkingParams) []string {
+	changes := make([]string, 0)
+	if p.ChunkSize != other.ChunkSize {
+		changes = append(changes, fmt.Sprintf("chunk-size: %d -> %d", p.ChunkSize, other.ChunkSize))
+	}
+	if p.IndexFields != other.IndexFields {
+		changes = append(changes, fmt.Sprintf("index-fields: %q -> %q", p.IndexFields, other.IndexFields))
+	}
+	if p.Range != other.Range {
+		changes = append(changes, fmt.Sprintf("range: %q -> %q", p.Range, other.Range))
+	}
+	if p.Collation != other.Collation {
+		changes = append(changes, fmt.Sprintf("collation: %q -> %q", p.Collation, other.Collation))
+	}
+	if p.SplitByPartition != other.SplitByPartition {
+		changes = append(changes, fmt.Sprintf("split-by-partition: %t -> %t", p.SplitByPartition, other.SplitByPartition))
+	}
+	if p.AdaptiveChunking != other.AdaptiveChunking {
+		changes = append(changes, fmt.Sprintf("adaptive-chunking: %t -> %t", p.AdaptiveChunking, other.AdaptiveChunking))
+	}
+	return changes
+}
+
+// SetChunkingParams sets the chunking parameters of the tables in the current comparison.
+func (cp *Checkpoint) SetChunkingParams(params []*ChunkingParams) {
+	cp.chunkingParams = params
+}
+
+// checkChunkingParams checks the tables up to the table of the saved chunk are the same, and the chunking parameters
+// of the table of the saved chunk are not changed, the tables after it are not split yet so they can be changed.
+func (cp *Checkpoint) checkChunkingParams(saved []*ChunkingParams, tableIndex int) error {
+	if tableIndex < 0 {
+		return nil
+	}
+	if saved == nil || cp.chunkingParams == nil {
+		log.Warn("the chunking parameters are not found in the checkpoint, the chunk size and the index to split chunks should not be changed since the checkpoint")
+		return nil
+	}
+	for i := 0; i <= tableIndex; i++ {
+		if i >= len(saved) || i >= len(cp.chunkingParams) || saved[i].Table != cp.chunkingParams[i].Table {
+			return errors.Errorf("the tables to compare are changed since the checkpoint, please use another output-dir and start over again")
+		}
+	}
+	if changes := saved[tableIndex].diff(cp.chunkingParams[tableIndex]); len(changes) > 0 {
+		return errors.Errorf("the chunking parameters of table %s are changed since the checkpoint (%s), please restore them, or use another output-dir and start over again",
+			saved[tableIndex].Table, strings.Join(changes, ", "))
+	}
+	return nil
 }
 
 // InitCurrentSavedID the method is only used in initialization without lock, be cautious
@@ -203,15 +316,29 @@ func (cp *Checkpoint) GetChunkSnapshot() (cur *Node) {
 	return cur
 }
 
-// SaveChunk saves the chunk to file.
+// NeedFullSnapshot returns whether the next report saved should be a full snapshot by `SaveChunk`
+// rather than an increment by `SaveIncrement`.
+func (cp *Checkpoint) NeedFullSnapshot() bool {
+	return cp.snapshotID == 0 || cp.increments >= fullSnapshotInterval
+}
+
+// SaveChunk saves the chunk to file with the full snapshot of the report, the increments saved before are removed.
 func (cp *Checkpoint) SaveChunk(ctx context.Context, fileName string, cur *Node, reportInfo *report.Report) (*chunk.ChunkID, error) {
 	if cur == nil {
 		return nil, nil
 	}
 
+	// the id is unique across the runs, so the increments left by a crash after saving the snapshot are ignored.
+	snapshotID := time.Now().UnixNano()
+	if snapshotID <= cp.snapshotID {
+		snapshotID = cp.snapshotID + 1
+	}
+	cp.snapshotID = 0
 	savedState := &SavedState{
-		Chunk:  cur,
-		Report: reportInfo,
+		Chunk:          cur,
+		Report:         reportInfo,
+		ChunkingParams: cp.chunkingParams,
+		SnapshotID:     snapshotID,
 	}
 	checkpointData, err := json.Marshal(savedState)
 	if err != nil {
@@ -222,13 +349,64 @@ func (cp *Checkpoint) SaveChunk(ctx context.Context, fileName string, cur *Node,
 	if err = ioutil2.WriteFileAtomic(fileName, checkpointData, config.LocalFilePerm); err != nil {
 		return nil, err
 	}
+	if err = os.Remove(IncrementFileName(fileName)); err != nil && !os.IsNotExist(err) {
+		log.Warn("fail to remove the increments of the checkpoint", zap.Error(err))
+	}
+	cp.snapshotID = snapshotID
+	cp.increments = 0
 	log.Info("save checkpoint",
 		zap.Any("chunk", cur),
 		zap.String("state", cur.GetState()))
 	return cur.GetID(), nil
 }
 
-// LoadChunk loads chunk info from file `chunk`
+// SaveIncrement appends the chunk with the increment of the report to the increment file of the checkpoint `fileName`.
+// If it fails, the next report saved should be a full snapshot, because the increment is lost.
+func (cp *Checkpoint) SaveIncrement(ctx context.Context, fileName string, cur *Node, increment *report.Report) (*chunk.ChunkID, error) {
+	if cur == nil {
+		return nil, nil
+	}
+	if cp.snapshotID == 0 {
+		return nil, errors.New("no full snapshot of the report is saved before the increment")
+	}
+
+	savedIncrement := &SavedIncrement{
+		SnapshotID: cp.snapshotID,
+		Chunk:      cur,
+		Report:     increment,
+	}
+	data, err := json.Marshal(savedIncrement)
+	if err != nil {
+		cp.snapshotID = 0
+		log.Warn("fail to save the chunk to the file", zap.Any("chunk index", cur.GetID()), zap.Error(err))
+		return nil, errors.Trace(err)
+	}
+	if err = appendFile(IncrementFileName(fileName), append(data, '\n')); err != nil {
+		cp.snapshotID = 0
+		return nil, errors.Trace(err)
+	}
+	cp.increments++
+	log.Info("save checkpoint increment",
+		zap.Any("chunk", cur),
+		zap.String("state", cur.GetState()),
+		zap.Int("increments", cp.increments))
+	return cur.GetID(), nil
+}
+
+func appendFile(fileName string, data []byte) error {
+	f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_APPEND, config.LocalFilePerm)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		return errors.Trace(err)
+	}
+	return errors.Trace(f.Close())
+}
+
+// LoadChunk loads chunk info from file `chunk`, it fails if the chunking parameters are changed since the checkpoint.
+// The increments saved after the full snapshot are applied in order, and the chunk of the last one is returned.
 func (cp *Checkpoint) LoadChunk(fileName string) (*Node, *report.Report, error) {
 	bytes, err := os.ReadFile(fileName)
 	if err != nil {
@@ -239,5 +417,71 @@ func (cp *Checkpoint) LoadChunk(fileName string) (*Node, *report.Report, error)
 	if err != nil {
 		return nil, nil, errors.Trace(err)
 	}
+	if n.SnapshotID != 0 {
+		if err := loadIncrements(IncrementFileName(fileName), n); err != nil {
+			return nil, nil, errors.Trace(err)
+		}
+	}
+	if n.Chunk != nil {
+		if err := cp.checkChunkingParams(n.ChunkingParams, n.Chunk.GetTableIndex()); err != nil {
+			return nil, nil, errors.Trace(err)
+		}
+	}
 	return n.Chunk, n.Report, nil
 }
+
+// LoadTableOrder loads the names of the tables in the order of their indices from the checkpoint `fileName`, which
+// are saved with the chunking parameters. It's nil for the checkpoint saved by the older versions.
+func LoadTableOrder(fileName string) ([]string, error) {
+	bytes, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	n := &struct {
+		ChunkingParams []*ChunkingParams `json:"chunking-params,omitempty"`
+	}{}
+	if err = json.Unmarshal(bytes, n); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if n.ChunkingParams == nil {
+		return nil, nil
+	}
+	tables := make([]string, 0, len(n.ChunkingParams))
+	for _, params := range n.ChunkingParams {
+		tables = append(tables, params.Table)
+	}
+	return tables, nil
+}
+
+// loadIncrements applies the increments of the full snapshot in the file to the saved state. The increments of the
+// other snapshots are left by a crash before they are removed, so they are ignored. The increment partially written
+// by a crash and the ones after it are ignored, then the state is the same as the last increment written.
+func loadIncrements(fileName string, state *SavedState) error {
+	f, err := os.Open(fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Trace(err)
+	}
+	defer f.Close()
+	decoder := json.NewDecoder(f)
+	applied := 0
+	for decoder.More() {
+		increment := &SavedIncrement{}
+		if err := decoder.Decode(increment); err != nil {
+			log.Warn("fail to load the increment of the checkpoint, the increments after it are ignored", zap.Int("applied", applied), zap.Error(err))
+			break
+		}
+		if increment.SnapshotID != state.SnapshotID || increment.Chunk == nil {
+			continue
+		}
+		state.Chunk = increment.Chunk
+		if state.Report != nil && increment.Report != nil {
+			state.Report.ApplyIncrement(increment.Report)
+		}
+		applied++
+	}
+	log.Info("load the increments of the checkpoint", zap.Int("applied", applied))
+	return nil
+}
@@ -17,12 +17,16 @@ import (
 	"context"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"strconv"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/pingcap/tidb-tools/sync_diff_inspector/chunk"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/report"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
 	"github.com/stretchr/testify/require"
 )
 
@@ -118,3 +122,141 @@ func TestLoadChunk(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, node.GetID().Compare(id), 0)
 }
+
+func TestLoadChunkWithChangedChunkingParams(t *testing.T) {
+	tables := []*common.TableDiff{
+		{Schema: "test", Table: "t1", ChunkSize: 1000},
+		{Schema: "test", Table: "t2", ChunkSize: 1000, Fields: "a"},
+		{Schema: "test", Table: "t3", ChunkSize: 1000},
+	}
+	newChunkingParams := func(tables []*common.TableDiff) []*ChunkingParams {
+		params := make([]*ChunkingParams, 0, len(tables))
+		for _, table := range tables {
+			params = append(params, NewChunkingParams(table))
+		}
+		return params
+	}
+	checker := new(Checkpoint)
+	checker.Init()
+	checker.SetChunkingParams(newChunkingParams(tables))
+	fileName := filepath.Join(t.TempDir(), "TestLoadChunkWithChangedChunkingParams")
+	node := &Node{
+		ChunkRange: &chunk.Range{
+			Index: &chunk.ChunkID{TableIndex: 1, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 3, ChunkCnt: 10},
+		},
+		State: SuccessState,
+	}
+	_, err := checker.SaveChunk(context.Background(), fileName, node, nil)
+	require.NoError(t, err)
+
+	loadChunk := func(tables []*common.TableDiff) error {
+		checker := new(Checkpoint)
+		checker.Init()
+		checker.SetChunkingParams(newChunkingParams(tables))
+		_, _, err := checker.LoadChunk(fileName)
+		return err
+	}
+	require.NoError(t, loadChunk(tables))
+	// the tables after the table of the checkpoint are not split yet.
+	require.NoError(t, loadChunk([]*common.TableDiff{tables[0], tables[1], {Schema: "test", Table: "t3", ChunkSize: 2000}}))
+	require.NoError(t, loadChunk(tables[:2]))
+
+	err = loadChunk([]*common.TableDiff{tables[0], {Schema: "test", Table: "t2", ChunkSize: 2000, Fields: "b"}, tables[2]})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "the chunking parameters of table `test`.`t2` are changed since the checkpoint (chunk-size: 1000 -> 2000, index-fields: \"a\" -> \"b\")")
+	err = loadChunk([]*common.TableDiff{tables[0], {Schema: "test", Table: "t2", ChunkSize: 1000, Fields: "a", SplitByPartition: true}, tables[2]})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "(split-by-partition: false -> true)")
+	err = loadChunk([]*common.TableDiff{tables[1], tables[2]})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "the tables to compare are changed since the checkpoint")
+
+	// the checkpoint saved without the chunking parameters
+	checker.SetChunkingParams(nil)
+	_, err = checker.SaveChunk(context.Background(), fileName, node, nil)
+	require.NoError(t, err)
+	require.NoError(t, loadChunk([]*common.TableDiff{tables[1], tables[2]}))
+}
+
+func TestSaveIncrement(t *testing.T) {
+	tables := []*common.TableDiff{{Schema: "test", Table: "t"}}
+	newNode := func(chunkIndex int) *Node {
+		return &Node{
+			ChunkRange: &chunk.Range{
+				Index: &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: chunkIndex, ChunkCnt: 10},
+			},
+			State: SuccessState,
+		}
+	}
+	r := report.NewReport(&config.TaskConfig{OutputDir: t.TempDir()})
+	r.Init(tables, nil, nil)
+	ctx := context.Background()
+	fileName := filepath.Join(t.TempDir(), "TestSaveIncrement")
+
+	checker := new(Checkpoint)
+	checker.Init()
+	require.True(t, checker.NeedFullSnapshot())
+	_, err := checker.SaveIncrement(ctx, fileName, newNode(0), nil)
+	require.Error(t, err)
+	r.SetTableDataCheckResult("test", "t", false, 1, 0, newNode(0).GetID())
+	snapshot, err := r.GetSnapshot(newNode(0).GetID(), "test", "t")
+	require.NoError(t, err)
+	_, err = checker.SaveChunk(ctx, fileName, newNode(0), snapshot)
+	require.NoError(t, err)
+	require.False(t, checker.NeedFullSnapshot())
+	for i := 1; i <= 2; i++ {
+		r.SetTableDataCheckResult("test", "t", false, 0, i, newNode(i).GetID())
+		_, err = checker.SaveIncrement(ctx, fileName, newNode(i), r.GetIncrement(newNode(i).GetID(), "test", "t"))
+		require.NoError(t, err)
+	}
+
+	// the increments are applied to the full snapshot.
+	node, loaded, err := new(Checkpoint).LoadChunk(fileName)
+	require.NoError(t, err)
+	require.Equal(t, 2, node.GetChunkIndex())
+	require.Len(t, loaded.TableResults["test"]["t"].ChunkMap, 3)
+	require.Equal(t, 2, loaded.TableResults["test"]["t"].ChunkMap[newNode(2).GetID().Encode()].RowsDelete)
+
+	// the increment partially written is ignored.
+	f, err := os.OpenFile(IncrementFileName(fileName), os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"snapshot-id":1,"chunk-info":{"sta`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	node, _, err = new(Checkpoint).LoadChunk(fileName)
+	require.NoError(t, err)
+	require.Equal(t, 2, node.GetChunkIndex())
+
+	// the full snapshot is saved periodically, and the increments saved before are removed.
+	for i := 0; i < fullSnapshotInterval-2; i++ {
+		_, err = checker.SaveIncrement(ctx, fileName, newNode(3), r.GetIncrement(newNode(3).GetID(), "test", "t"))
+		require.NoError(t, err)
+	}
+	require.True(t, checker.NeedFullSnapshot())
+	_, err = checker.SaveChunk(ctx, fileName, newNode(3), snapshot)
+	require.NoError(t, err)
+	require.NoFileExists(t, IncrementFileName(fileName))
+
+	// the increments of the other snapshots are ignored.
+	require.NoError(t, os.WriteFile(IncrementFileName(fileName), []byte(`{"snapshot-id":1,"chunk-info":{"state":"success","chunk-range":{"index":"v2:g0.g0.g9.g0.ga"}}}`+"\n"), 0o644))
+	node, _, err = new(Checkpoint).LoadChunk(fileName)
+	require.NoError(t, err)
+	require.Equal(t, 3, node.GetChunkIndex())
+}
+
+func TestNullChunkAdjacent(t *testing.T) {
+	last := &Node{ChunkRange: &chunk.Range{Index: &chunk.ChunkID{TableIndex: 0, ChunkCnt: 1}, IsLast: true}}
+	nullChunk := chunk.NewNullChunkRange("a", chunk.Random, "", "TRUE")
+	nullChunk.Index.TableIndex = 1
+	first := chunk.NewChunkRange()
+	first.Update("a", "", "1", false, true)
+	chunk.InitChunks([]*chunk.Range{first}, chunk.Random, 0, 0, 0, "", "TRUE", 2)
+	first.Index.TableIndex = 1
+	first.AfterNull = true
+
+	// the first chunk of the table can't be saved before the NULL chunk.
+	require.True(t, last.IsAdjacent(&Node{ChunkRange: nullChunk}))
+	require.False(t, last.IsAdjacent(&Node{ChunkRange: first}))
+	require.True(t, (&Node{ChunkRange: nullChunk}).IsAdjacent(&Node{ChunkRange: first}))
+	require.True(t, (&Node{ChunkRange: nullChunk}).IsLess(&Node{ChunkRange: first}))
+}
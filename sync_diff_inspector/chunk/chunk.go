@@ -41,6 +41,10 @@ const (
 	Empty
 )
 
+// NullBucketIndex is the bucket index of the NULL chunk of a table, see `NewNullChunkRange`, which is ordered before
+// the buckets of the other chunks.
+const NullBucketIndex = -1
+
 // Bound represents a bound for a column
 type Bound struct {
 	Column string `json:"column"`
@@ -106,23 +110,142 @@ func (c *ChunkID) Copy() *ChunkID {
 	return &cp
 }
 
+// ToString returns the human-readable form of the chunk id like `0:0-0:2:10`, which is printed in the logs.
+// It's the version 1 of the encoding, use `Encode` to get the key of the chunk.
 func (c *ChunkID) ToString() string {
 	return fmt.Sprintf("%d:%d-%d:%d:%d", c.TableIndex, c.BucketIndexLeft, c.BucketIndexRight, c.ChunkIndex, c.ChunkCnt)
 }
 
+// chunkIDVersionPrefix is the prefix of the encoding returned by `Encode`.
+const chunkIDVersionPrefix = "v2:"
+
+const hexDigits = "0123456789abcdef"
+
+// Encode returns the compact form of the chunk id like `v2:g0.g0.g2.g0.ga`, which is the key of the chunk in
+// the report and the checkpoint.
+//
+// The fields are encoded in the order of `Compare`, followed by `BucketIndexRight` and `ChunkCnt`. A non-negative
+// field is encoded as its hex digits prefixed by a letter from `g` to `v` for the number of the digits, and a
+// negative one is encoded as the complemented hex digits of its absolute value prefixed by a hex digit from `f`
+// to `0`, so the encodings of two chunk ids are in the same lexical order as `Compare` if it doesn't return 0.
+func (c *ChunkID) Encode() string {
+	buf := make([]byte, 0, 24)
+	buf = append(buf, chunkIDVersionPrefix...)
+	for i, v := range []int{c.TableIndex, c.BucketIndexLeft, c.ChunkIndex, c.BucketIndexRight, c.ChunkCnt} {
+		if i > 0 {
+			buf = append(buf, '.')
+		}
+		buf = appendIndex(buf, v)
+	}
+	return string(buf)
+}
+
+func appendIndex(buf []byte, v int) []byte {
+	if v >= 0 {
+		digits := strconv.FormatUint(uint64(v), 16)
+		buf = append(buf, byte('g'+len(digits)-1))
+		return append(buf, digits...)
+	}
+	// the larger the absolute value is, the smaller the encoding is.
+	digits := strconv.FormatUint(uint64(-int64(v)), 16)
+	buf = append(buf, hexDigits[16-len(digits)])
+	for i := 0; i < len(digits); i++ {
+		buf = append(buf, hexDigits[15-strings.IndexByte(hexDigits, digits[i])])
+	}
+	return buf
+}
+
+const maxInt = int64(^uint(0) >> 1)
+
+func parseIndex(s string) (int, error) {
+	if len(s) < 2 {
+		return 0, errors.Errorf("invalid index %q", s)
+	}
+	digits := []byte(s[1:])
+	negative := false
+	if s[0] >= 'g' && s[0] <= 'v' {
+		if int(s[0]-'g')+1 != len(digits) || (digits[0] == '0' && len(digits) > 1) {
+			return 0, errors.Errorf("invalid index %q", s)
+		}
+		// the digits are lower case, so the encoding of a chunk id is unique.
+		for _, d := range digits {
+			if strings.IndexByte(hexDigits, d) < 0 {
+				return 0, errors.Errorf("invalid index %q", s)
+			}
+		}
+	} else if p := strings.IndexByte(hexDigits, s[0]); p >= 0 && 16-p == len(digits) && digits[0] != 'f' {
+		negative = true
+		for i, d := range digits {
+			n := strings.IndexByte(hexDigits, d)
+			if n < 0 {
+				return 0, errors.Errorf("invalid index %q", s)
+			}
+			digits[i] = hexDigits[15-n]
+		}
+	} else {
+		return 0, errors.Errorf("invalid index %q", s)
+	}
+	u, err := strconv.ParseUint(string(digits), 16, 64)
+	if err != nil {
+		return 0, errors.Annotatef(err, "invalid index %q", s)
+	}
+	if negative {
+		if u > uint64(maxInt)+1 {
+			return 0, errors.Errorf("index %q is out of range", s)
+		}
+		return int(-int64(u-1) - 1), nil
+	}
+	if u > uint64(maxInt) {
+		return 0, errors.Errorf("index %q is out of range", s)
+	}
+	return int(u), nil
+}
+
+// FromString parses the chunk id from both the encoding of `Encode` and the form of `ToString`,
+// the latter is the key of the chunk in the reports and the checkpoints saved by the old versions.
 func (c *ChunkID) FromString(s string) error {
+	if strings.HasPrefix(s, chunkIDVersionPrefix) {
+		fields := strings.Split(s[len(chunkIDVersionPrefix):], ".")
+		if len(fields) != 5 {
+			return errors.Errorf("invalid chunk id %q", s)
+		}
+		indexes := make([]int, 0, len(fields))
+		for _, field := range fields {
+			index, err := parseIndex(field)
+			if err != nil {
+				return errors.Annotatef(err, "invalid chunk id %q", s)
+			}
+			indexes = append(indexes, index)
+		}
+		c.TableIndex, c.BucketIndexLeft, c.ChunkIndex, c.BucketIndexRight, c.ChunkCnt = indexes[0], indexes[1], indexes[2], indexes[3], indexes[4]
+		return nil
+	}
+	if strings.HasPrefix(s, "v") {
+		return errors.Errorf("unsupported version of chunk id %q", s)
+	}
+
 	ids := strings.Split(s, ":")
+	if len(ids) != 4 {
+		return errors.Errorf("invalid chunk id %q", s)
+	}
 	tableIndex, err := strconv.Atoi(ids[0])
 	if err != nil {
 		return errors.Trace(err)
 	}
 
-	bucketIndex := strings.Split(ids[1], "-")
-	bucketIndexLeft, err := strconv.Atoi(bucketIndex[0])
+	// the left index may be negative, so the separator is searched after its first character.
+	sep := -1
+	if len(ids[1]) > 1 {
+		sep = strings.IndexByte(ids[1][1:], '-') + 1
+	}
+	if sep < 1 {
+		return errors.Errorf("invalid chunk id %q", s)
+	}
+	bucketIndexLeft, err := strconv.Atoi(ids[1][:sep])
 	if err != nil {
 		return errors.Trace(err)
 	}
-	bucketIndexRight, err := strconv.Atoi(bucketIndex[1])
+	bucketIndexRight, err := strconv.Atoi(ids[1][sep+1:])
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -139,6 +262,26 @@ func (c *ChunkID) FromString(s string) error {
 	return nil
 }
 
+// MarshalJSON implements the json.Marshaler interface, the chunk id is serialized as the string of `Encode`.
+func (c *ChunkID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.Encode())
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface, both the string of `Encode` and `ToString`
+// and the object saved in the checkpoints by the old versions are accepted.
+func (c *ChunkID) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return errors.Trace(err)
+		}
+		return c.FromString(s)
+	}
+	// the alias type doesn't have the methods, so the fields are unmarshaled by default.
+	type chunkIDObject ChunkID
+	return errors.Trace(json.Unmarshal(data, (*chunkIDObject)(c)))
+}
+
 // Range represents chunk range
 type Range struct {
 	Index   *ChunkID  `json:"index"`
@@ -146,6 +289,15 @@ type Range struct {
 	Bounds  []*Bound  `json:"bounds"`
 	IsFirst bool      `json:"is-first"`
 	IsLast  bool      `json:"is-last"`
+	// Partition is the partition of the table the rows of the chunk are selected from by `PARTITION (p)`,
+	// empty if the chunk isn't split by partition.
+	Partition string `json:"partition,omitempty"`
+	// NullColumn is the leading column to split the chunks if the chunk only contains the rows whose value of the
+	// column is NULL, which are excluded by the bounds of the other chunks, see `NewNullChunkRange`.
+	NullColumn string `json:"null-column,omitempty"`
+	// AfterNull is true if the chunk follows the NULL chunk of the table, so it isn't the first chunk of the table
+	// even if it has no lower bound.
+	AfterNull bool `json:"after-null,omitempty"`
 
 	Where string        `json:"where"`
 	Args  []interface{} `json:"args"`
@@ -170,6 +322,21 @@ func NewChunkRange() *Range {
 	}
 }
 
+// NewNullChunkRange returns the chunk of the rows whose column is NULL, which are excluded by the bounds on the
+// column. It's the first chunk of the table, and its bucket index is `NullBucketIndex`.
+func NewNullChunkRange(column string, t ChunkType, collation, limits string) *Range {
+	c := NewChunkRange()
+	c.NullColumn = column
+	c.IsFirst = true
+	InitChunk(c, t, NullBucketIndex, NullBucketIndex, collation, limits)
+	return c
+}
+
+// IsNullChunk returns whether the chunk is the NULL chunk of the table, see `NewNullChunkRange`.
+func (c *Range) IsNullChunk() bool {
+	return len(c.NullColumn) > 0
+}
+
 // NewChunkRangeOffset return a Range in sequence
 func NewChunkRangeOffset(columnOffset map[string]int) *Range {
 	bounds := make([]*Bound, len(columnOffset))
@@ -190,6 +357,14 @@ func (c *Range) IsLastChunkForTable() bool {
 	if c.IsLast {
 		return true
 	}
+	if c.IsNullChunk() {
+		// the other chunks follow the NULL chunk.
+		return false
+	}
+	if len(c.Partition) > 0 {
+		// the bounds of the chunks restart in each partition.
+		return false
+	}
 	// calculate from bounds
 	for _, b := range c.Bounds {
 		if b.HasUpper {
@@ -203,6 +378,12 @@ func (c *Range) IsFirstChunkForTable() bool {
 	if c.IsFirst {
 		return true
 	}
+	if c.AfterNull {
+		return false
+	}
+	if len(c.Partition) > 0 {
+		return false
+	}
 	// calculate from bounds
 	for _, b := range c.Bounds {
 		if b.HasLower {
@@ -227,6 +408,9 @@ func (c *Range) ToString(collation string) (string, []interface{}) {
 	if collation != "" {
 		collation = fmt.Sprintf(" COLLATE '%s'", collation)
 	}
+	if c.IsNullChunk() {
+		return fmt.Sprintf("%s IS NULL", dbutil.ColumnName(c.NullColumn)), nil
+	}
 
 	/* for example:
 	there is a bucket in TiDB, and the lowerbound and upperbound are (A, B1, C1), (A, B2, C2), and the columns are `a`, `b` and `c`,
@@ -325,6 +509,9 @@ func (c *Range) ToString(collation string) (string, []interface{}) {
 }
 
 func (c *Range) ToMeta() string {
+	if c.IsNullChunk() {
+		return fmt.Sprintf("range in sequence: (%s) IS NULL", c.NullColumn)
+	}
 	lowerCondition := make([]string, 0, 1)
 	upperCondition := make([]string, 0, 1)
 	columnName := make([]string, 0, 1)
@@ -349,6 +536,11 @@ func (c *Range) ToMeta() string {
 	return fmt.Sprintf("range in sequence: (%s) < (%s) <= (%s)", strings.Join(lowerCondition, ","), strings.Join(columnName, ","), strings.Join(upperCondition, ","))
 }
 
+// BoundString returns the bound of the chunk without the prefix of `ToMeta`, e.g. "(1) < (a) <= (2)".
+func (c *Range) BoundString() string {
+	return strings.TrimPrefix(c.ToMeta(), "range in sequence: ")
+}
+
 func (c *Range) addBound(bound *Bound) {
 	c.Bounds = append(c.Bounds, bound)
 	c.columnOffset[bound.Column] = len(c.Bounds) - 1
@@ -414,6 +606,9 @@ func (c *Range) Clone() *Range {
 	newChunk.Index = c.Index.Copy()
 	newChunk.IsFirst = c.IsFirst
 	newChunk.IsLast = c.IsLast
+	newChunk.Partition = c.Partition
+	newChunk.NullColumn = c.NullColumn
+	newChunk.AfterNull = c.AfterNull
 	return newChunk
 }
 
@@ -14,6 +14,9 @@
 package chunk
 
 import (
+	"encoding/json"
+	"math"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -80,6 +83,7 @@ func TestChunkUpdate(t *testing.T) {
 	chunkRange.Update("a", "1", "2", true, true)
 	chunkRange.Update("b", "3", "4", true, true)
 	require.Equal(t, chunkRange.ToMeta(), "range in sequence: (3,1) < (b,a) <= (4,2)")
+	require.Equal(t, chunkRange.BoundString(), "(3,1) < (b,a) <= (4,2)")
 }
 
 func TestChunkToString(t *testing.T) {
@@ -386,6 +390,19 @@ func TestChunkToString(t *testing.T) {
 	}
 	require.Equal(t, chunk.String(), `{"index":null,"type":0,"bounds":[{"column":"a","lower":"1","upper":"1","has-lower":false,"has-upper":false},{"column":"b","lower":"3","upper":"4","has-lower":false,"has-upper":false},{"column":"c","lower":"5","upper":"6","has-lower":false,"has-upper":false}],"is-first":false,"is-last":false,"where":"","args":null}`)
 	require.Equal(t, chunk.ToMeta(), "range in sequence: Full")
+
+	// the hostile column names are quoted, and the values are the args.
+	chunk = &Range{
+		Bounds: []*Bound{
+			{Column: "order", Lower: "1", Upper: "2", HasLower: true, HasUpper: true},
+			{Column: "a`b", Lower: "x`y", Upper: "*/", HasLower: true, HasUpper: true},
+			{Column: "列 名 ", Lower: "a\nb", Upper: "c", HasLower: true, HasUpper: true},
+		},
+	}
+	conditions, args = chunk.ToString("utf8mb4_bin")
+	require.Equal(t, "((`order` COLLATE 'utf8mb4_bin' > ?) OR (`order` COLLATE 'utf8mb4_bin' = ? AND `a``b` COLLATE 'utf8mb4_bin' > ?) OR (`order` COLLATE 'utf8mb4_bin' = ? AND `a``b` COLLATE 'utf8mb4_bin' = ? AND `列 名 ` COLLATE 'utf8mb4_bin' > ?)) AND "+
+		"((`order` COLLATE 'utf8mb4_bin' < ?) OR (`order` COLLATE 'utf8mb4_bin' = ? AND `a``b` COLLATE 'utf8mb4_bin' < ?) OR (`order` COLLATE 'utf8mb4_bin' = ? AND `a``b` COLLATE 'utf8mb4_bin' = ? AND `列 名 ` COLLATE 'utf8mb4_bin' <= ?))", conditions)
+	require.Equal(t, []interface{}{"1", "1", "x`y", "1", "x`y", "a\nb", "2", "2", "*/", "2", "*/", "c"}, args)
 }
 
 func TestChunkInit(t *testing.T) {
@@ -568,6 +585,109 @@ func TestChunkID(t *testing.T) {
 
 }
 
+func TestChunkIDEncode(t *testing.T) {
+	cases := []struct {
+		id      ChunkID
+		encoded string
+		legacy  string
+	}{
+		{ChunkID{0, 0, 0, 2, 10}, "v2:g0.g0.g2.g0.ga", "0:0-0:2:10"},
+		{ChunkID{3, 1, 2, 255, 256}, "v2:g3.g1.hff.g2.i100", "3:1-2:255:256"},
+		{ChunkID{-1, -1, -1, -1, 0}, "v2:fe.fe.fe.fe.g0", "-1:-1--1:-1:0"},
+		{ChunkID{-16, 0, 0, 0, 1}, "v2:eef.g0.g0.g0.g1", "-16:0-0:0:1"},
+		{ChunkID{math.MaxInt64, math.MinInt64, 0, 0, 0}, "v2:v7fffffffffffffff.07fffffffffffffff.g0.g0.g0", "9223372036854775807:-9223372036854775808-0:0:0"},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.encoded, c.id.Encode())
+		require.Equal(t, c.legacy, c.id.ToString())
+		for _, s := range []string{c.encoded, c.legacy} {
+			id := new(ChunkID)
+			require.NoError(t, id.FromString(s), s)
+			require.Equal(t, c.id, *id)
+		}
+	}
+
+	for _, s := range []string{
+		"", "0:0-0:2", "0:0:2:10", "0:-0:2:10", "a:0-0:2:10", "v3:g0.g0.g2.g0.ga",
+		"v2:g0.g0.g2.g0", "v2:g0.g0.g2.g0.ga.g0", "v2:g0.g0.g2.g0.h0a", "v2:g0.g0.g2.g0.gg", "v2:g0.g0.g2.g0.g",
+		"v2:ff.g0.g0.g0.g0", "v2:g0.g0.g2.g0.w0", "v2:g0.g0.g2.g0.gaa", "v2:g0.gA.g0.g0.g0", "v2:080000000000000000.g0.g0.g0.g0",
+	} {
+		require.Error(t, new(ChunkID).FromString(s), s)
+	}
+}
+
+// FuzzChunkID checks the chunk ids parsed from the fuzzed strings are encoded back to the same ids in both forms,
+// and the encodings of two chunk ids are in the same lexical order as `Compare`.
+func FuzzChunkID(f *testing.F) {
+	for _, seed := range [][2]string{
+		{"0:0-0:2:10", "v2:g0.g0.g2.g0.ga"},
+		{"3:1-2:255:256", "v2:g3.g1.hff.g2.i100"},
+		{"-1:-1--1:-1:0", "v2:fe.fe.fe.fe.g0"},
+		{"-16:0-0:0:1", "v2:eef.g0.g0.g0.g1"},
+		{"9223372036854775807:-9223372036854775808-0:0:0", "v2:v7fffffffffffffff.07fffffffffffffff.g0.g0.g0"},
+		{"1:2-3:4:5", "v2:g1.g2.g4.g3.g6"},
+		{"0:0-0:15:16", "v2:g0.g0.h10.g0.h10"},
+		{"0:4096-4096:0:1", "v2:g0.g0.g0.g0.g1"},
+	} {
+		f.Add(seed[0], seed[1])
+		f.Add(seed[1], seed[0])
+	}
+	f.Fuzz(func(t *testing.T, a, b string) {
+		ids := make([]*ChunkID, 0, 2)
+		for _, s := range []string{a, b} {
+			id := new(ChunkID)
+			if err := id.FromString(s); err != nil {
+				continue
+			}
+			if strings.HasPrefix(s, chunkIDVersionPrefix) {
+				// the encoding is canonical.
+				require.Equal(t, s, id.Encode())
+			}
+			for _, encoded := range []string{id.Encode(), id.ToString()} {
+				decoded := new(ChunkID)
+				require.NoError(t, decoded.FromString(encoded), encoded)
+				require.Equal(t, id, decoded)
+			}
+			data, err := json.Marshal(id)
+			require.NoError(t, err)
+			decoded := new(ChunkID)
+			require.NoError(t, json.Unmarshal(data, decoded))
+			require.Equal(t, id, decoded)
+			ids = append(ids, id)
+		}
+		if len(ids) < 2 {
+			return
+		}
+		cmp := ids[0].Compare(ids[1])
+		lexical := strings.Compare(ids[0].Encode(), ids[1].Encode())
+		if cmp != 0 {
+			require.Equal(t, cmp, lexical, "%s %s", ids[0].ToString(), ids[1].ToString())
+		}
+	})
+}
+
+func TestChunkIDJSON(t *testing.T) {
+	chunkRange := NewChunkRange()
+	chunkRange.Index = &ChunkID{1, 2, 3, 4, 5}
+	data, err := json.Marshal(chunkRange)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"index":"v2:g1.g2.g4.g3.g5"`)
+	decoded := new(Range)
+	require.NoError(t, json.Unmarshal(data, decoded))
+	require.Equal(t, chunkRange.Index, decoded.Index)
+
+	// the checkpoints saved by the old versions.
+	for _, data := range []string{
+		`{"index":{"table-index":1,"bucket-index-left":2,"bucket-index-right":3,"chunk-index":4,"chunk-count":5}}`,
+		`{"index":"1:2-3:4:5"}`,
+	} {
+		decoded = new(Range)
+		require.NoError(t, json.Unmarshal([]byte(data), decoded))
+		require.Equal(t, chunkRange.Index, decoded.Index)
+	}
+	require.Error(t, json.Unmarshal([]byte(`{"index":"v2:g1"}`), new(Range)))
+}
+
 func TestChunkIndex(t *testing.T) {
 	chunkRange := NewChunkRange()
 	chunkRange.Index.ChunkIndex = 0
@@ -611,4 +731,47 @@ func TestChunkIndex(t *testing.T) {
 	}
 	require.False(t, chunkRange.IsLastChunkForTable())
 	require.False(t, chunkRange.IsFirstChunkForTable())
+
+	// the chunks of the partitions are marked, because the bounds restart in each partition.
+	chunkRange = NewChunkRange()
+	chunkRange.Partition = "p0"
+	require.False(t, chunkRange.IsLastChunkForTable())
+	require.False(t, chunkRange.IsFirstChunkForTable())
+	chunkRange.IsFirst = true
+	chunkRange.IsLast = true
+	require.True(t, chunkRange.Clone().IsLastChunkForTable())
+	require.True(t, chunkRange.Clone().IsFirstChunkForTable())
+	require.Equal(t, "p0", chunkRange.Clone().Partition)
+}
+
+func TestNullChunk(t *testing.T) {
+	nullChunk := NewNullChunkRange("a", Random, "", "`b` > 0")
+	require.True(t, nullChunk.IsNullChunk())
+	conditions, args := nullChunk.ToString("utf8mb4_bin")
+	require.Equal(t, "`a` IS NULL", conditions)
+	require.Nil(t, args)
+	require.Equal(t, "((`a` IS NULL) AND (`b` > 0))", nullChunk.Where)
+	require.Equal(t, "(a) IS NULL", nullChunk.BoundString())
+	require.True(t, nullChunk.IsFirstChunkForTable())
+	require.False(t, nullChunk.IsLastChunkForTable())
+	require.True(t, nullChunk.IsFirstChunkForBucket())
+	require.True(t, nullChunk.IsLastChunkForBucket())
+	require.Equal(t, "a", nullChunk.Clone().NullColumn)
+
+	// the NULL chunk is ordered before the other chunks of the table.
+	first := NewChunkRange()
+	first.Update("a", "", "1", false, true)
+	InitChunks([]*Range{first}, Random, 0, 0, 0, "", "TRUE", 2)
+	first.AfterNull = true
+	require.Equal(t, -1, nullChunk.Index.Compare(first.Index))
+	require.Less(t, nullChunk.Index.Encode(), first.Index.Encode())
+	require.False(t, first.IsFirstChunkForTable())
+	require.False(t, first.Clone().IsFirstChunkForTable())
+
+	data, err := json.Marshal(nullChunk)
+	require.NoError(t, err)
+	decoded := new(Range)
+	require.NoError(t, json.Unmarshal(data, decoded))
+	require.True(t, decoded.IsNullChunk())
+	require.Equal(t, nullChunk.Index, decoded.Index)
 }
@@ -18,10 +18,15 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"net/mail"
 	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/pingcap/errors"
@@ -29,6 +34,7 @@ import (
 	"github.com/pingcap/tidb-tools/pkg/dbutil"
 	filter "github.com/pingcap/tidb-tools/pkg/table-filter"
 	router "github.com/pingcap/tidb-tools/pkg/table-router"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/chunk"
 	"github.com/pingcap/tidb/parser/model"
 	flag "github.com/spf13/pflag"
 	"go.uber.org/zap"
@@ -39,6 +45,121 @@ const (
 	LocalFilePerm os.FileMode = 0o644
 
 	LogFileName = "sync_diff.log"
+	// LatestLinkName is the symlink in output-dir to the newest run with timestamped-output.
+	LatestLinkName = "latest"
+	// HistoryDirName is the directory in output-dir where the runs are archived by keep-history.
+	HistoryDirName = "history"
+	// DefaultKeepLast is the number of the runs of a task kept in the history by default.
+	DefaultKeepLast = 20
+	// RunDirTimeLayout is the layout of the UTC time naming the directories of the runs by timestamped-output and
+	// keep-history, which has no colons, so the names are valid on all the file systems and sorted by the time.
+	RunDirTimeLayout = "20060102T150405Z"
+	// ConfigHashFileName is the file in output-dir with the hash of the config which saves the checkpoint.
+	ConfigHashFileName = "config.hash"
+	// checkpointDirName is the directory in output-dir with the checkpoint files.
+	checkpointDirName = "checkpoint"
+
+	// OutputDirTaskName in output-dir is expanded to task-name.
+	OutputDirTaskName = "{task-name}"
+	// OutputDirDate in output-dir is expanded to the date the run starts, like `2021-10-01`.
+	OutputDirDate = "{date}"
+
+	// FixTargetSource means the fix sql is generated to make the source match the target.
+	FixTargetSource = "source"
+	// FixTargetTarget means the fix sql is generated to make the target match the source.
+	FixTargetTarget = "target"
+
+	// FixSQLModeReplace fixes the different rows by `REPLACE INTO`.
+	FixSQLModeReplace = "replace"
+	// FixSQLModeInsertOnDuplicate fixes the different rows by `INSERT ... ON DUPLICATE KEY UPDATE`.
+	FixSQLModeInsertOnDuplicate = "insert-on-duplicate"
+	// FixSQLModeDeleteInsert fixes the different rows by `DELETE` and then `INSERT`.
+	FixSQLModeDeleteInsert = "delete-insert"
+
+	// FixFileLayoutChunk writes the fix sql of each chunk into its own files in the fix dir by one writer.
+	FixFileLayoutChunk = "chunk"
+	// FixFileLayoutTable writes the fix sql of each table into one file by the writer of the table.
+	FixFileLayoutTable = "table"
+
+	// OutputFormatJSON streams `report.json` to stdout by output-to-stdout.
+	OutputFormatJSON = "json"
+	// OutputFormatText streams `summary.txt` to stdout by output-to-stdout.
+	OutputFormatText = "text"
+
+	// CheckModeFull compares the data of the tables chunk by chunk.
+	CheckModeFull = "full"
+	// CheckModeCount only compares the row counts of the tables.
+	CheckModeCount = "count"
+	// CheckModeCountThenFull compares the row counts of the tables first, and only the tables whose
+	// row counts are equal are compared chunk by chunk.
+	CheckModeCountThenFull = "count-then-full"
+
+	// AdminChecksumAuto verifies the tables by `ADMIN CHECKSUM TABLE` first if the source and the target are TiDB
+	// of the compatible versions.
+	AdminChecksumAuto = "auto"
+	// AdminChecksumOn always verifies the tables by `ADMIN CHECKSUM TABLE` first.
+	AdminChecksumOn = "on"
+	// AdminChecksumOff never uses `ADMIN CHECKSUM TABLE`.
+	AdminChecksumOff = "off"
+
+	// RecheckSnapshotKeep rechecks the failed chunks at the snapshots of the data sources.
+	RecheckSnapshotKeep = "keep"
+	// RecheckSnapshotLatest rechecks the failed chunks at the latest data regardless of the snapshots.
+	RecheckSnapshotLatest = "latest"
+
+	// ChunkOrderPKAsc compares the tables in the order of the config, and the chunks of each table in the order of
+	// the index they are split by.
+	ChunkOrderPKAsc = "pk-asc"
+	// ChunkOrderSizeDesc compares the tables in the descending order of their sizes estimated by the target, so the
+	// chunks of the largest tables start first.
+	ChunkOrderSizeDesc = "size-desc"
+
+	// ZeroSizePolicyInclude checks the data of the tables whose size is 0 in the statistics.
+	ZeroSizePolicyInclude = "include"
+	// ZeroSizePolicyExclude skips the data check of the tables whose size is 0 in the statistics.
+	ZeroSizePolicyExclude = "exclude"
+	// ZeroSizePolicyWarnAndInclude checks the data of the tables whose size is 0 in the statistics with a warning.
+	ZeroSizePolicyWarnAndInclude = "warn-and-include"
+
+	// ColumnTransformLower converts the source value to lowercase.
+	ColumnTransformLower = "lower"
+	// ColumnTransformTrim removes the leading and trailing spaces of the source value.
+	ColumnTransformTrim = "trim"
+	// ColumnTransformUnhex converts the hexadecimal source value to the bytes.
+	ColumnTransformUnhex = "unhex"
+	// ColumnTransformMaskLast4 masks the source value with `*` except the last 4 characters, e.g. "*******5678".
+	ColumnTransformMaskLast4 = "mask_last4"
+	// ColumnTransformRTrim removes the trailing spaces of the value, which is applied to the CHAR and VARCHAR columns
+	// on both sides by trim-char-padding rather than set in column-transforms.
+	ColumnTransformRTrim = "rtrim"
+	// ColumnTransformSetValue sorts the members of the SET value in the order of the target's definition,
+	// ColumnTransformEnumValue converts the ENUM value to the member string in utf8mb4, and ColumnTransformEnumIndex
+	// converts the ENUM or SET value to the stored index. They are applied to the ENUM and SET columns whose members
+	// are in different orders on both sides by compare-enum-by-value rather than set in column-transforms.
+	ColumnTransformSetValue  = "set-value"
+	ColumnTransformEnumValue = "enum-value"
+	ColumnTransformEnumIndex = "enum-index"
+	// ColumnTransformGeometryWKB converts the GEOMETRY value to the SRID and the WKB like "4326:<WKB>", which is applied
+	// to the GEOMETRY columns on both sides by compare-geometry rather than set in column-transforms.
+	ColumnTransformGeometryWKB = "geometry-wkb"
+	// ColumnTransformUTF8MB4 converts the value to utf8mb4, which is applied to the columns transcoded by charset-map
+	// on both sides rather than set in column-transforms, so the values of the different charsets are compared
+	// in the same charset.
+	ColumnTransformUTF8MB4 = "utf8mb4"
+	// ColumnTransformChar converts the value to the string, which is applied to the UUID and INET columns of MariaDB
+	// on both sides rather than set in column-transforms, so they are compared with the string columns of the target.
+	ColumnTransformChar = "char"
+
+	// DefaultNoIndexTableMaxRows is the default max rows of the tables without primary key or unique key to compare,
+	// the rows of these tables are held in memory to be compared.
+	DefaultNoIndexTableMaxRows = 100000
+	// DefaultRowsEstimateWarnFactor is the default factor of the divergence between the estimated row count
+	// and the actual rows of a table to warn about the stale statistics.
+	DefaultRowsEstimateWarnFactor = 2
+	// DefaultMaxExportRows is the default max rows exported of each table by export-diff-rows.
+	DefaultMaxExportRows = 10000
+	// DefaultMaxRowDiffs is the default max rows exported of each table by export-row-diffs.
+	DefaultMaxRowDiffs = 1000
 )
 
 // TableConfig is the config of table.
@@ -52,6 +173,8 @@ type TableConfig struct {
 	ConfigIndex int
 	// Internally used to valid config.
 	HasMatched bool
+	// Internally used to indicate the table is an updatable view on the target compared by check-view-data.
+	IsView bool `json:"-"`
 
 	// columns be ignored, will not check this column's data
 	IgnoreColumns []string `toml:"ignore-columns"`
@@ -67,6 +190,21 @@ type TableConfig struct {
 
 	// specify the chunksize for the table
 	ChunkSize int64 `toml:"chunk-size" json:"chunk-size"`
+
+	// specify the fix sql mode for the table, use the global `fix-sql-mode` if empty
+	FixSQLMode string `toml:"fix-sql-mode" json:"fix-sql-mode,omitempty"`
+
+	// specify the number of chunks of the table compared concurrently, which can't exceed the global `check-thread-count`,
+	// use the global `check-thread-count` if 0
+	Concurrency int `toml:"concurrency" json:"concurrency,omitempty"`
+
+	// the built-in transforms applied to the source values of the columns before comparison, `column` => `transform`,
+	// e.g. the columns masked intentionally during the migration.
+	ColumnTransforms map[string]string `toml:"column-transforms" json:"column-transforms,omitempty"`
+
+	// the names of the secondary indices whose checksums are compared chunk by chunk besides the data, e.g. the
+	// indices may be inconsistent with the data after the import with the checks disabled.
+	CheckIndex []string `toml:"check-index" json:"check-index,omitempty"`
 }
 
 // Valid returns true if table's config is valide.
@@ -91,18 +229,298 @@ type DataSource struct {
 	RouteRules []string `toml:"route-rules" json:"route-rules"`
 	Router     *router.Table
 
+	// the timeouts of connecting, reading and writing the connections, e.g. "30s", see `GetDialTimeout`,
+	// `GetReadTimeout` and `GetWriteTimeout` for the defaults.
+	DialTimeout  string `toml:"dial-timeout" json:"dial-timeout,omitempty"`
+	ReadTimeout  string `toml:"read-timeout" json:"read-timeout,omitempty"`
+	WriteTimeout string `toml:"write-timeout" json:"write-timeout,omitempty"`
+	// the maximum number of the open and the idle connections, which override the limits decided by
+	// `check-thread-count` and the global `max-open-conns` if they are greater than 0.
+	MaxOpenConns int `toml:"max-open-conns" json:"max-open-conns,omitempty"`
+	MaxIdleConns int `toml:"max-idle-conns" json:"max-idle-conns,omitempty"`
+	// the maximum amount of time a connection may be reused, e.g. "1h", see `GetConnMaxLifetime` for the default.
+	ConnMaxLifetime string `toml:"conn-max-lifetime" json:"conn-max-lifetime,omitempty"`
+
 	Conn *sql.DB
 	// SourceType string `toml:"source-type" json:"source-type"`
 }
 
+const (
+	defaultDialTimeout     = 30 * time.Second
+	defaultReadTimeout     = 30 * time.Minute
+	defaultWriteTimeout    = 30 * time.Minute
+	defaultConnMaxLifetime = time.Hour
+)
+
 func (d *DataSource) ToDBConfig() *dbutil.DBConfig {
+	// the timeouts are checked by `Valid`.
+	dialTimeout, _ := d.GetDialTimeout()
+	readTimeout, _ := d.GetReadTimeout()
+	writeTimeout, _ := d.GetWriteTimeout()
 	return &dbutil.DBConfig{
-		Host:     d.Host,
-		Port:     d.Port,
-		User:     d.User,
-		Password: d.Password,
-		Snapshot: d.Snapshot,
+		Host:         d.Host,
+		Port:         d.Port,
+		User:         d.User,
+		Password:     d.Password,
+		Snapshot:     d.Snapshot,
+		DialTimeout:  dialTimeout,
+		ReadTimeout:  readTimeout,
+		WriteTimeout: writeTimeout,
+	}
+}
+
+// GetDialTimeout returns the timeout of connecting, 30s if it's not set.
+func (d *DataSource) GetDialTimeout() (time.Duration, error) {
+	return parseDurationOrDefault(d.DialTimeout, defaultDialTimeout)
+}
+
+// GetReadTimeout returns the timeout of reading the connections, 30m if it's not set.
+// It's long enough for the checksum of a chunk, but a dead connection doesn't hang the comparison forever.
+func (d *DataSource) GetReadTimeout() (time.Duration, error) {
+	return parseDurationOrDefault(d.ReadTimeout, defaultReadTimeout)
+}
+
+// GetWriteTimeout returns the timeout of writing the connections, 30m if it's not set.
+func (d *DataSource) GetWriteTimeout() (time.Duration, error) {
+	return parseDurationOrDefault(d.WriteTimeout, defaultWriteTimeout)
+}
+
+// GetConnMaxLifetime returns the maximum amount of time a connection may be reused, 1h if it's not set.
+func (d *DataSource) GetConnMaxLifetime() (time.Duration, error) {
+	return parseDurationOrDefault(d.ConnMaxLifetime, defaultConnMaxLifetime)
+}
+
+func parseDurationOrDefault(s string, defaultDuration time.Duration) (time.Duration, error) {
+	if len(s) == 0 {
+		return defaultDuration, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// Valid returns true if the connection settings of the data source are valid.
+func (d *DataSource) Valid() bool {
+	for _, timeout := range []struct {
+		name  string
+		value string
+		get   func() (time.Duration, error)
+	}{
+		{"dial-timeout", d.DialTimeout, d.GetDialTimeout},
+		{"read-timeout", d.ReadTimeout, d.GetReadTimeout},
+		{"write-timeout", d.WriteTimeout, d.GetWriteTimeout},
+		{"conn-max-lifetime", d.ConnMaxLifetime, d.GetConnMaxLifetime},
+	} {
+		if duration, err := timeout.get(); err != nil || duration <= 0 {
+			log.Error(timeout.name+" should be a positive duration like \"30s\"", zap.String(timeout.name, timeout.value))
+			return false
+		}
+	}
+	if d.MaxOpenConns < 0 {
+		log.Error("max-open-conns must not be less than 0!")
+		return false
+	}
+	if d.MaxIdleConns < 0 {
+		log.Error("max-idle-conns must not be less than 0!")
+		return false
+	}
+	return true
+}
+
+const (
+	// WaitSyncDM waits for the binlog positions of the subtasks of a DM task.
+	WaitSyncDM = "dm"
+	// WaitSyncTiCDC waits for the checkpoint of a TiCDC changefeed.
+	WaitSyncTiCDC = "ticdc"
+
+	defaultWaitSyncTimeout  = 10 * time.Minute
+	defaultWaitSyncInterval = 5 * time.Second
+)
+
+// WaitSyncConfig is the config to wait until the replication from the sources to the target catches up before
+// comparing the data, so that the diffs of the replication lag aren't reported.
+type WaitSyncConfig struct {
+	// the replication tool, "dm" or "ticdc".
+	Type string `toml:"type" json:"type"`
+	// the address of the DM-master or the TiCDC server, e.g. "http://127.0.0.1:8261".
+	Addr string `toml:"addr" json:"addr"`
+	// the name of the DM task or the id of the TiCDC changefeed.
+	Task string `toml:"task" json:"task"`
+	// how long to wait at most, e.g. "10m", the data check of the tables is skipped when it elapses.
+	Timeout string `toml:"timeout" json:"timeout"`
+	// the interval to poll the replication checkpoint, e.g. "5s".
+	Interval string `toml:"interval" json:"interval"`
+}
+
+// GetTimeout returns the timeout of waiting, 10m if it's not set.
+func (w *WaitSyncConfig) GetTimeout() (time.Duration, error) {
+	if len(w.Timeout) == 0 {
+		return defaultWaitSyncTimeout, nil
 	}
+	return time.ParseDuration(w.Timeout)
+}
+
+// GetInterval returns the interval of polling, 5s if it's not set.
+func (w *WaitSyncConfig) GetInterval() (time.Duration, error) {
+	if len(w.Interval) == 0 {
+		return defaultWaitSyncInterval, nil
+	}
+	return time.ParseDuration(w.Interval)
+}
+
+// Valid returns true if the config of waiting is valid.
+func (w *WaitSyncConfig) Valid() bool {
+	switch w.Type {
+	case WaitSyncDM, WaitSyncTiCDC:
+	default:
+		log.Error("wait-sync.type should be \"dm\" or \"ticdc\"", zap.String("type", w.Type))
+		return false
+	}
+	if u, err := url.Parse(w.Addr); err != nil || u.Scheme == "" || u.Host == "" {
+		log.Error("wait-sync.addr's format should like 'http://127.0.0.1:8261'", zap.String("addr", w.Addr))
+		return false
+	}
+	if len(w.Task) == 0 {
+		log.Error("wait-sync.task should be the name of the DM task or the id of the TiCDC changefeed")
+		return false
+	}
+	if timeout, err := w.GetTimeout(); err != nil || timeout <= 0 {
+		log.Error("wait-sync.timeout should be a positive duration like \"10m\"", zap.String("timeout", w.Timeout))
+		return false
+	}
+	if interval, err := w.GetInterval(); err != nil || interval <= 0 {
+		log.Error("wait-sync.interval should be a positive duration like \"5s\"", zap.String("interval", w.Interval))
+		return false
+	}
+	return true
+}
+
+const (
+	// EmailTLSStartTLS upgrades the plain connection to the SMTP server by STARTTLS, which is usually on the port 587.
+	EmailTLSStartTLS = "starttls"
+	// EmailTLSImplicit connects to the SMTP server over TLS, which is usually on the port 465.
+	EmailTLSImplicit = "tls"
+	// EmailTLSNone sends the email over the plain connection, which is only for the SMTP relays in the trusted network.
+	EmailTLSNone = "none"
+)
+
+// NotifyConfig is the config to notify the result when the comparison ends.
+type NotifyConfig struct {
+	// Email mails the summary and the report, nil means no email.
+	Email *EmailConfig `toml:"email" json:"email"`
+}
+
+// EmailConfig is the config to mail `summary.txt` inline and `report.json` as the attachment by SMTP when the
+// comparison ends.
+type EmailConfig struct {
+	// the host and the port of the SMTP server.
+	Host string `toml:"host" json:"host"`
+	Port int    `toml:"port" json:"port"`
+	// the user and the password to authenticate by PLAIN, empty user means no authentication. the password is
+	// omitted in the log.
+	User     string `toml:"user" json:"user"`
+	Password string `toml:"password" json:"password"`
+	// the sender and the recipients of the email.
+	From string   `toml:"from" json:"from"`
+	To   []string `toml:"to" json:"to"`
+	// how to secure the connection to the SMTP server, "starttls", "tls" or "none".
+	TLS string `toml:"tls" json:"tls"`
+	// attach the summary in markdown, i.e. `summary.md`, besides `report.json`.
+	AttachMarkdown bool `toml:"attach-markdown" json:"attach-markdown"`
+}
+
+// GetTLS returns how to secure the connection, "starttls" if it's not set.
+func (e *EmailConfig) GetTLS() string {
+	if len(e.TLS) == 0 {
+		return EmailTLSStartTLS
+	}
+	return e.TLS
+}
+
+// Valid returns true if the config of the email is valid.
+func (e *EmailConfig) Valid() bool {
+	if len(e.Host) == 0 {
+		log.Error("notify.email.host should be the host of the SMTP server")
+		return false
+	}
+	if e.Port <= 0 || e.Port > 65535 {
+		log.Error("notify.email.port should be the port of the SMTP server", zap.Int("port", e.Port))
+		return false
+	}
+	switch e.GetTLS() {
+	case EmailTLSStartTLS, EmailTLSImplicit, EmailTLSNone:
+	default:
+		log.Error("notify.email.tls should be \"starttls\", \"tls\" or \"none\"", zap.String("tls", e.TLS))
+		return false
+	}
+	if _, err := mail.ParseAddress(e.From); err != nil {
+		log.Error("notify.email.from should be an email address", zap.String("from", e.From), zap.Error(err))
+		return false
+	}
+	if len(e.To) == 0 {
+		log.Error("notify.email.to should be the email addresses of the recipients")
+		return false
+	}
+	for _, to := range e.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			log.Error("notify.email.to should be the email addresses of the recipients", zap.String("to", to), zap.Error(err))
+			return false
+		}
+	}
+	return true
+}
+
+// QueryPairConfig is the config to compare the result sets of two queries rather than the tables, e.g. the joins
+// and the aggregations. The result sets are compared row by row like the chunks, so the queries should be ordered by
+// the key columns.
+type QueryPairConfig struct {
+	// the query run on the source instance and the query run on the target instance, which should return the same
+	// columns ordered by key-columns, e.g. "SELECT id, SUM(amount) AS total FROM orders GROUP BY id ORDER BY id".
+	SourceQuery string `toml:"source-query" json:"source-query"`
+	TargetQuery string `toml:"target-query" json:"target-query"`
+	// the columns to align the rows of the result sets, whose values should be unique.
+	KeyColumns []string `toml:"key-columns" json:"key-columns"`
+	// the source instance to run source-query, the first one of task.source-instances by default.
+	SourceInstance string `toml:"source-instance" json:"source-instance"`
+}
+
+// GetSourceInstance returns the source instance to run source-query in the source instances of the task.
+func (q *QueryPairConfig) GetSourceInstance(task *TaskConfig) (*DataSource, error) {
+	if len(q.SourceInstance) == 0 {
+		if len(task.SourceInstances) == 0 {
+			return nil, errors.New("no source instance to run source-query")
+		}
+		return task.SourceInstances[0], nil
+	}
+	for i, name := range task.Source {
+		if name == q.SourceInstance && i < len(task.SourceInstances) {
+			return task.SourceInstances[i], nil
+		}
+	}
+	return nil, errors.Errorf("source-instance %s is not in task.source-instances", q.SourceInstance)
+}
+
+// Valid returns true if the config of the query pair labeled label is valid.
+func (q *QueryPairConfig) Valid(label string, task *TaskConfig) bool {
+	if len(strings.TrimSpace(q.SourceQuery)) == 0 || len(strings.TrimSpace(q.TargetQuery)) == 0 {
+		log.Error("query-pairs.source-query and query-pairs.target-query can't be empty", zap.String("label", label))
+		return false
+	}
+	if len(q.KeyColumns) == 0 {
+		log.Error("query-pairs.key-columns should be the columns to align the rows of the result sets", zap.String("label", label))
+		return false
+	}
+	columns := make(map[string]struct{}, len(q.KeyColumns))
+	for _, column := range q.KeyColumns {
+		if _, ok := columns[column]; ok || len(column) == 0 {
+			log.Error("query-pairs.key-columns should be the distinct names of the columns", zap.String("label", label), zap.Strings("key-columns", q.KeyColumns))
+			return false
+		}
+		columns[column] = struct{}{}
+	}
+	if _, err := q.GetSourceInstance(task); err != nil {
+		log.Error("query-pairs.source-instance should be one of task.source-instances", zap.String("label", label), zap.Error(err))
+		return false
+	}
+	return true
 }
 
 type TaskConfig struct {
@@ -127,6 +545,79 @@ type TaskConfig struct {
 	FixDir        string
 	CheckpointDir string
 	HashFile      string
+
+	// fixTarget is set by `Config.Init`, which decides the name of FixDir.
+	fixTarget string
+	// rootOutputDir is the output-dir configured when timestamped-output is set, and OutputDir is the directory of
+	// this run in it, see `resolveTimestampedOutput`.
+	rootOutputDir string
+	// dirPerm is the permission of the directories created by output-dir-perm, see `DirPerm`.
+	dirPerm os.FileMode
+	// discardCheckpoint discards the checkpoint saved with a different config, which is set by `Config.Init`.
+	discardCheckpoint bool
+	// createdOutputDir is the OutputDir created by `MkdirOutputDir`.
+	createdOutputDir string
+	// resumed is whether OutputDir is the directory of the interrupted run resumed with timestamped-output.
+	resumed bool
+}
+
+// outputDirPlaceholder matches the placeholders in output-dir like `{task-name}`.
+var outputDirPlaceholder = regexp.MustCompile(`\{[^{}]*\}`)
+
+// expandOutputDir expands the placeholders in OutputDir, `{task-name}` to taskName and `{date}` to the date of now,
+// so that the tasks sharing a base directory don't collide.
+func (t *TaskConfig) expandOutputDir(taskName string, now time.Time) error {
+	var err error
+	t.OutputDir = outputDirPlaceholder.ReplaceAllStringFunc(t.OutputDir, func(placeholder string) string {
+		switch placeholder {
+		case OutputDirTaskName:
+			return taskName
+		case OutputDirDate:
+			return now.Format("2006-01-02")
+		}
+		if err == nil {
+			err = errors.Errorf("unknown placeholder %s in output-dir, only %s and %s are supported", placeholder, OutputDirTaskName, OutputDirDate)
+		}
+		return placeholder
+	})
+	return err
+}
+
+// DirPerm returns the permission of the directories created in OutputDir.
+func (t *TaskConfig) DirPerm() os.FileMode {
+	if t.dirPerm == 0 {
+		return LocalDirPerm
+	}
+	return t.dirPerm
+}
+
+// resolveTimestampedOutput nests the outputs of this run under `OutputDir/<timestamp>/`, see `RunDirName`,
+// so the outputs of the previous runs are kept. If the run `latest` links to is interrupted, i.e. its checkpoint
+// is unfinished, this run resumes it in its directory instead.
+func (t *TaskConfig) resolveTimestampedOutput(now time.Time) error {
+	t.rootOutputDir = t.OutputDir
+	// the directory of this run isn't created yet.
+	t.createdOutputDir = ""
+	t.resumed = false
+	latest, err := os.Readlink(filepath.Join(t.rootOutputDir, LatestLinkName))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Annotate(err, "failed to read the latest link")
+	}
+	if err == nil {
+		latestDir := filepath.Join(t.rootOutputDir, latest)
+		// the checkpoint files are removed when the run finishes.
+		entries, err := os.ReadDir(filepath.Join(latestDir, checkpointDirName))
+		if err != nil && !os.IsNotExist(err) {
+			return errors.Trace(err)
+		}
+		if len(entries) > 0 {
+			t.OutputDir = latestDir
+			t.resumed = true
+			return nil
+		}
+	}
+	t.OutputDir = filepath.Join(t.rootOutputDir, RunDirName(now))
+	return nil
 }
 
 func (t *TaskConfig) Init(
@@ -180,51 +671,95 @@ func (t *TaskConfig) Init(
 	}
 
 	// Create output Dir if not exists
-	ok, err = pathExists(t.OutputDir)
-	if err != nil {
+	if err = t.MkdirOutputDir(); err != nil {
 		return errors.Trace(err)
 	}
-	if !ok {
-		if err = mkdirAll(t.OutputDir); err != nil {
-			return errors.Trace(err)
-		}
-	}
 	// outputDir exists, we need to check the config hash for checkpoint.
-	t.CheckpointDir = filepath.Join(t.OutputDir, "checkpoint")
+	t.HashFile = filepath.Join(t.OutputDir, ConfigHashFileName)
+	t.CheckpointDir = filepath.Join(t.OutputDir, checkpointDirName)
 	ok, err = pathExists(t.CheckpointDir)
 	if err != nil {
 		return errors.Trace(err)
 	}
-	if !ok {
-		// no checkpoint, we can use this outputDir directly.
-		if err = mkdirAll(t.CheckpointDir); err != nil {
-			return errors.Trace(err)
-		}
-		// create config hash in checkpointDir.
-		err = os.WriteFile(filepath.Join(t.CheckpointDir, hash), []byte{}, LocalFilePerm)
-		if err != nil {
-			return errors.Trace(err)
-		}
-	} else {
+	if ok {
 		// checkpoint exists, we need compare the config hash.
-		ok, err = pathExists(filepath.Join(t.CheckpointDir, hash))
+		ok, err = t.matchConfigHash(hash)
 		if err != nil {
 			return errors.Trace(err)
 		}
-		if !ok {
+		if !ok && !t.discardCheckpoint {
 			// not match, raise error
-			return errors.Errorf("config changes breaking the checkpoint, please use another outputDir and start over again!")
+			return errors.Errorf("config changes breaking the checkpoint, please use another outputDir and start over again! or use --discard-checkpoint to discard the checkpoint")
+		}
+		if !ok {
+			log.Warn("config changes breaking the checkpoint, discard the checkpoint and start over", zap.String("checkpoint", t.CheckpointDir))
+			if err = os.RemoveAll(t.CheckpointDir); err != nil {
+				return errors.Trace(err)
+			}
 		}
 	}
+	if err = mkdirAll(t.CheckpointDir, t.DirPerm()); err != nil {
+		return errors.Trace(err)
+	}
+	// the config hash is written into outputDir for the next run to check.
+	if err = os.WriteFile(t.HashFile, []byte(hash+"\n"), LocalFilePerm); err != nil {
+		return errors.Trace(err)
+	}
 
-	t.FixDir = filepath.Join(t.OutputDir, fmt.Sprintf("fix-on-%s", t.Target))
-	if err = mkdirAll(t.FixDir); err != nil {
+	fixOn := t.Target
+	if t.fixTarget == FixTargetSource && len(t.Source) > 0 {
+		fixOn = t.Source[0]
+	}
+	t.FixDir = filepath.Join(t.OutputDir, fmt.Sprintf("fix-on-%s", fixOn))
+	if err = mkdirAll(t.FixDir, t.DirPerm()); err != nil {
 		return errors.Trace(err)
 	}
 
 	return nil
 }
 
+// MkdirOutputDir creates OutputDir if not exists, which is called before the log file is created in it. With
+// timestamped-output, the directory of this run shouldn't exist unless the run is resumed, and `latest` is linked
+// to it when the run starts, so `latest` may link to a run which is not finished yet.
+func (t *TaskConfig) MkdirOutputDir() error {
+	if t.createdOutputDir == t.OutputDir {
+		return nil
+	}
+	ok, err := pathExists(t.OutputDir)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if ok && len(t.rootOutputDir) > 0 && !t.resumed {
+		// another run started in the same second, the outputs shouldn't be mixed.
+		return errors.Errorf("the output directory of this run %s already exists, please try again later", t.OutputDir)
+	}
+	if !ok {
+		if err = mkdirAll(t.OutputDir, t.DirPerm()); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if len(t.rootOutputDir) > 0 {
+		if err = UpdateLatestLink(t.rootOutputDir, filepath.Base(t.OutputDir)); err != nil {
+			return errors.Annotate(err, "failed to update the latest link")
+		}
+	}
+	t.createdOutputDir = t.OutputDir
+	return nil
+}
+
+// matchConfigHash returns whether the checkpoint in OutputDir is saved with the config of the hash. The hash is
+// in the file of checkpoint named by it in the old versions.
+func (t *TaskConfig) matchConfigHash(hash string) (bool, error) {
+	data, err := os.ReadFile(t.HashFile)
+	if err == nil {
+		return strings.TrimSpace(string(data)) == hash, nil
+	}
+	if !os.IsNotExist(err) {
+		return false, errors.Trace(err)
+	}
+	return pathExists(filepath.Join(t.CheckpointDir, hash))
+}
+
 // ComputeConfigHash compute the hash according to the task
 // if ConfigHash is as same as checkpoint.hash
 // we think the second sync diff can use the checkpoint.
@@ -266,17 +801,186 @@ type Config struct {
 
 	// log level
 	LogLevel string `toml:"-" json:"-"`
+	// log format, "text" or "json"
+	LogFormat string `toml:"-" json:"-"`
 	// how many goroutines are created to check data
 	CheckThreadCount int `toml:"check-thread-count" json:"check-thread-count"`
+	// how many goroutines are created to check the table structures, use `check-thread-count` if 0.
+	StructThreadCount int `toml:"struct-thread-count" json:"struct-thread-count"`
+	// only check the structures of shard-struct-sample shards of each table in the shard merging, and the structures of
+	// the other shards are assumed to be equal, 0 means all the shards are checked.
+	ShardStructSample int `toml:"shard-struct-sample" json:"shard-struct-sample"`
+	// the maximum number of the open connections to each database, 0 means it's decided by `check-thread-count`.
+	MaxOpenConns int `toml:"max-open-conns" json:"max-open-conns"`
 	// set true if want to compare rows
 	// set false won't compare rows.
 	ExportFixSQL bool `toml:"export-fix-sql" json:"export-fix-sql"`
 	// only check table struct without table data.
 	CheckStructOnly bool `toml:"check-struct-only" json:"check-struct-only"`
+	// how to check the data, "full", "count" or "count-then-full".
+	CheckMode string `toml:"check-mode" json:"check-mode"`
+	// compare the row counts of the tables before comparing them by chunks, and flag the tables whose counts are
+	// different, which are still compared by chunks. it's implied by check-mode "count" and "count-then-full".
+	CountPrecheck bool `toml:"count-precheck" json:"count-precheck"`
+	// whether to verify the tables by `ADMIN CHECKSUM TABLE` before comparing them by chunks, "auto", "on" or "off".
+	AdminChecksum string `toml:"admin-checksum" json:"admin-checksum"`
+	// only compare the fraction sample-rate of the chunks selected randomly, 1 means all the chunks.
+	SampleRate float64 `toml:"sample-rate" json:"sample-rate"`
+	// the seed to select the chunks by sample-rate, the same seed selects the same chunks, 0 means a random seed.
+	SampleSeed int64 `toml:"sample-seed" json:"sample-seed"`
+	// FixTarget decides which side the fix sql is generated for, "target" or "source".
+	FixTarget string `toml:"fix-target" json:"fix-target"`
+	// skip the data check of the tables without primary key or unique key even if compare-no-index-tables is set.
+	SkipNoPKTables bool `toml:"skip-no-pk-tables" json:"skip-no-pk-tables"`
+	// compare the tables without primary key or unique key by the whole table, whose rows are compared as multisets,
+	// the tables are skipped by default.
+	CompareNoIndexTables bool `toml:"compare-no-index-tables" json:"compare-no-index-tables"`
+	// the max rows of the tables without primary key or unique key to compare, the tables with more rows are skipped.
+	NoIndexTableMaxRows int64 `toml:"no-index-table-max-rows" json:"no-index-table-max-rows"`
+	// check whether the values of the primary key or the unique key used to compare the rows are unique on both sides
+	// before comparing the data, the tables with duplicate keys are reported as errored and their data is not compared.
+	CheckPKUniqueness bool `toml:"check-pk-uniqueness" json:"check-pk-uniqueness"`
+	// still check the data when the column orders or the indices are different,
+	// only skip the data check when the column sets or the column types are different.
+	DataCheckOnStructMismatch bool `toml:"data-check-on-struct-mismatch" json:"data-check-on-struct-mismatch"`
+	// match the columns by name rather than position, the columns of the target are reordered
+	// in the order of the source if they only differ in the order.
+	MatchColumnsByName bool `toml:"match-columns-by-name" json:"match-columns-by-name"`
+	// compare the ENUM and SET columns whose members are in different orders on both sides by the member values,
+	// otherwise they are compared by the stored indexes.
+	CompareEnumByValue bool `toml:"compare-enum-by-value" json:"compare-enum-by-value"`
+	// fail the struct check of the tables whose ENUM and SET members are in different orders on both sides, which
+	// are only warned by default if they are compared by value.
+	FailOnEnumMemberOrder bool `toml:"fail-on-enum-member-order" json:"fail-on-enum-member-order"`
+	// ignore the trailing spaces of the CHAR and VARCHAR values on both sides, the binary columns are not trimmed.
+	TrimCharPadding bool `toml:"trim-char-padding" json:"trim-char-padding"`
+	// compare the GEOMETRY columns by the SRID and the WKB of the values, otherwise the data check of the tables
+	// with the GEOMETRY columns is skipped.
+	CompareGeometry bool `toml:"compare-geometry" json:"compare-geometry"`
+	// transcode the source values of the columns in the charset of the key to the charset of the value on the target
+	// before comparison, e.g. {latin1 = "utf8mb4"} after the charset migration, and the characters which can't be
+	// mapped to the charset of the target are reported.
+	CharsetMap map[string]string `toml:"charset-map" json:"charset-map"`
+	// compare the definitions of the views, and the views different or missing on the target cause Fail.
+	CheckViews bool `toml:"check-views" json:"check-views"`
+	// compare the data of the updatable views on the target like the tables without primary key or unique key,
+	// which requires compare-no-index-tables, otherwise the data of the views is never compared.
+	CheckViewData bool `toml:"check-view-data" json:"check-view-data"`
+	// the tables only exist on one side cause Fail, otherwise they are only listed in the summary.
+	FailOnMissingTables bool `toml:"fail-on-missing-tables" json:"fail-on-missing-tables"`
+	// the engines of the tables to compare besides the InnoDB-like engines, e.g. ["MyISAM"], the tables of the other engines
+	// and the objects like the sequences are skipped, and they are listed in the summary.
+	ForceIncludeEngines []string `toml:"force-include-engines" json:"force-include-engines"`
+	// compare the partition definitions of the tables, which are ignored by default because they often differ
+	// between TiDB and MySQL. the data is still checked if only the partition definitions are different.
+	CheckPartitionDefinition bool `toml:"check-partition-definition" json:"check-partition-definition"`
+	// split the chunks of the partitioned tables partition by partition, and the rows are selected by `PARTITION (p)`,
+	// so the diffs are localized to the partitions.
+	SplitByPartition bool `toml:"split-by-partition" json:"split-by-partition"`
+	// split the chunks split by random to hold roughly equal rows by sampling the distribution of the split fields,
+	// it falls back to split by random if the rows can't be sampled.
+	AdaptiveChunking bool `toml:"adaptive-chunking" json:"adaptive-chunking"`
+	// abort the comparison when the number of diff rows exceeds it, 0 means no limit.
+	MaxDiffRows int64 `toml:"max-diff-rows" json:"max-diff-rows"`
+	// stop comparing the rest chunks of a table when the number of its diff rows exceeds it, 0 means no limit.
+	MaxDiffRowsPerTable int64 `toml:"max-diff-rows-per-table" json:"max-diff-rows-per-table"`
+	// stop the comparison once the first table is found different, the results are partial then.
+	FailFast bool `toml:"fail-fast" json:"fail-fast"`
+	// the order the chunks are compared in, "pk-asc" or "size-desc" to start the largest tables first, which makes the
+	// partial results of fail-fast and run-timeout more useful.
+	ChunkOrder string `toml:"chunk-order" json:"chunk-order"`
+	// rotate to a new fix sql file when the size exceeds it, 0 means no limit.
+	FixFileMaxSize int64 `toml:"fix-file-max-size" json:"fix-file-max-size"`
+	// compress the fix sql files, "gzip" or "zstd", empty means no compression.
+	FixFileCompression string `toml:"fix-file-compression" json:"fix-file-compression"`
+	// how the fix sql files are laid out, "chunk" for the files of each chunk, or "table" for one file of each table
+	// written in parallel.
+	FixFileLayout string `toml:"fix-file-layout" json:"fix-file-layout"`
+	// the statements to fix the different rows, "replace", "insert-on-duplicate" or "delete-insert".
+	FixSQLMode string `toml:"fix-sql-mode" json:"fix-sql-mode"`
+	// generate the ALTER TABLE statements to make the structures of the target match the source on the struct
+	// mismatch, and CREATE TABLE and DROP TABLE for the tables only on one side, which are written to the fix dir
+	// but never applied.
+	GenerateStructFix bool `toml:"generate-struct-fix" json:"generate-struct-fix"`
+	// export the rows only on the sources or the target of each failing table to a CSV file with the side of the rows,
+	// which are the rows the fix sql is generated from.
+	ExportDiffRows bool `toml:"export-diff-rows" json:"export-diff-rows"`
+	// the max rows exported of each table by export-diff-rows, 0 means no limit.
+	MaxExportRows int64 `toml:"max-export-rows" json:"max-export-rows"`
+	// export the rows different on both sides of each failing chunk to a NDJSON file, where the updated rows have the
+	// source and target values of the different columns, and the rows only on one side have the side and all the values.
+	ExportRowDiffs bool `toml:"export-row-diffs" json:"export-row-diffs"`
+	// the max rows exported of each table by export-row-diffs, the rest are counted as suppressed, 0 means no limit.
+	MaxRowDiffs int64 `toml:"max-row-diffs" json:"max-row-diffs"`
+	// log the checksum sql and the row comparison sql of each chunk with the bound values, which is verbose.
+	LogSQL bool `toml:"log-sql" json:"log-sql"`
+	// the interval to log the heartbeat with the progress of the comparison, e.g. "30s", "0s" means no heartbeat.
+	HeartbeatInterval string `toml:"heartbeat-interval" json:"heartbeat-interval"`
+	// compare the checksum of the failed chunks again after recheck-delay, and only record the chunks still different,
+	// which avoids the transient diffs when the target is a lagging replica.
+	RecheckFailedChunks bool `toml:"recheck-failed-chunks" json:"recheck-failed-chunks"`
+	// the delay before rechecking the failed chunks, e.g. "10s".
+	RecheckDelay string `toml:"recheck-delay" json:"recheck-delay"`
+	// how many times the failed chunks are rechecked after recheck-delay each until they become equal.
+	RecheckTimes int `toml:"recheck-times" json:"recheck-times"`
+	// where the failed chunks are rechecked, "keep" for the snapshots of the data sources, or "latest" for the latest data.
+	RecheckSnapshot string `toml:"recheck-snapshot" json:"recheck-snapshot"`
+	// stop the comparison and save the checkpoint when the whole run exceeds it, e.g. "2h", "0s" means no timeout.
+	RunTimeout string `toml:"run-timeout" json:"run-timeout"`
+	// compare the tables meeting errors in the last run again when resuming from the checkpoint,
+	// otherwise they are skipped and still reported as errored.
+	RetryErroredTables bool `toml:"retry-errored-tables" json:"retry-errored-tables"`
+	// skip the data check of the tables whose size in bytes is less than table-size-min or greater than table-size-max,
+	// 0 means no limit. the size is estimated by `information_schema.tables`.
+	TableSizeMin int64 `toml:"table-size-min" json:"table-size-min"`
+	TableSizeMax int64 `toml:"table-size-max" json:"table-size-max"`
+	// what to do with the tables whose size is 0 in the statistics when table-size-min or table-size-max is set,
+	// "include", "exclude" or "warn-and-include".
+	ZeroSizePolicy string `toml:"zero-size-policy" json:"zero-size-policy"`
+	// warn about the tables whose estimated row count in the statistics of the target diverges from the actual rows
+	// by more than rows-estimate-warn-factor times, 0 means no warning.
+	RowsEstimateWarnFactor float64 `toml:"rows-estimate-warn-factor" json:"rows-estimate-warn-factor"`
+	// nest the outputs of each run under `output-dir/<timestamp>/`, and link `output-dir/latest` to the newest run
+	// when it starts. The interrupted run `latest` links to is resumed in its directory.
+	TimestampedOutput bool `toml:"timestamped-output" json:"timestamped-output"`
+	// the name of the task expanded in `{task-name}` of output-dir, the name of the config file without the extension
+	// by default.
+	TaskName string `toml:"task-name" json:"task-name"`
+	// archive the summary, the report and the config of each run under `history-dir/<task-name>/<timestamp>/`,
+	// and link `history-dir/<task-name>/latest` to the newest run.
+	KeepHistory bool `toml:"keep-history" json:"keep-history"`
+	// the directory of the history, `output-dir/history` by default.
+	HistoryDir string `toml:"history-dir" json:"history-dir"`
+	// the number of the newest runs of the task kept in the history, the older ones are pruned, 0 means no limit.
+	KeepLast int `toml:"keep-last" json:"keep-last"`
+	// the permission of the directories created in output-dir in octal, e.g. "0750".
+	OutputDirPerm string `toml:"output-dir-perm" json:"output-dir-perm"`
+	// write the sizes in the summary in bytes and the row counts as is for machine consumption, rather than humanized
+	// like "1.5GB" and "1,234,567".
+	RawUnits bool `toml:"raw-units" json:"raw-units"`
+	// stream the report of output-format to stdout besides writing it into output-dir, and the other outputs for
+	// humans, e.g. the progress and the summary, are written to stderr, so that the report can be piped to other tools.
+	OutputToStdout bool `toml:"output-to-stdout" json:"output-to-stdout"`
+	// the report streamed to stdout by output-to-stdout, "json" for `report.json` or "text" for `summary.txt`.
+	OutputFormat string `toml:"output-format" json:"output-format"`
+	// serve the status of the comparison over HTTP on status-addr, e.g. "127.0.0.1:8288", empty means no status server.
+	StatusAddr string `toml:"status-addr" json:"status-addr"`
+	// the bearer token required by the status server, empty means no authentication. it's omitted in the log.
+	StatusToken string `toml:"status-token" json:"-"`
+	// write the flat metrics in the OpenMetrics format into the `.prom` file when the comparison ends for the textfile
+	// collector of node_exporter, empty means no textfile.
+	TextfileMetricsPath string `toml:"textfile-metrics-path" json:"textfile-metrics-path"`
+	// the config keys whose values are masked in the summary, the report and the log besides password and status-token,
+	// e.g. ["user", "host"], which are matched by the last segment of the keys.
+	RedactKeys []string `toml:"redact-keys" json:"redact-keys"`
 	// DMAddr is dm-master's address, the format should like "http://127.0.0.1:8261"
 	DMAddr string `toml:"dm-addr" json:"dm-addr"`
 	// DMTask string `toml:"dm-task" json:"dm-task"`
 	DMTask string `toml:"dm-task" json:"dm-task"`
+	// WaitSync waits until the replication by DM or TiCDC catches up before comparing the data, nil means no waiting.
+	WaitSync *WaitSyncConfig `toml:"wait-sync" json:"wait-sync"`
+	// Notify notifies the result when the comparison ends, e.g. by email, nil means no notification.
+	Notify *NotifyConfig `toml:"notify" json:"notify"`
 
 	DataSources map[string]*DataSource `toml:"data-sources" json:"data-sources"`
 
@@ -284,12 +988,98 @@ type Config struct {
 
 	TableConfigs map[string]*TableConfig `toml:"table-configs" json:"table-configs"`
 
+	// QueryPairs are the pairs of the queries whose result sets are compared besides the tables, labeled by the keys.
+	QueryPairs map[string]*QueryPairConfig `toml:"query-pairs" json:"query-pairs"`
+
 	Task TaskConfig `toml:"task" json:"task"`
 	// config file
 	ConfigFile string
+	// the format of the config file, "toml" or "yaml", decided by the extension if empty.
+	ConfigFormat string `toml:"-" json:"-"`
+	// Overrides are the config values overriding the config file, like `data-sources.target.host=prod-tidb`.
+	Overrides []string `toml:"-" json:"-"`
+	// AppliedOverrides are the overrides applied from the environment variables and `--override`,
+	// in the order of applying and with the values of the redacted keys masked.
+	AppliedOverrides []string `toml:"-" json:"-"`
 
 	// print version if set true
 	PrintVersion bool
+
+	// ApplyFixDir is the directory of the fix sql files to apply, the data check is skipped if set.
+	ApplyFixDir string `toml:"-" json:"-"`
+	// ApplyBatchSize is the number of statements applied in one transaction.
+	ApplyBatchSize int `toml:"-" json:"-"`
+	// ApplyDryRun only prints the statement counts of the fix sql files.
+	ApplyDryRun bool `toml:"-" json:"-"`
+	// Force applies the fix sql files failed to be verified by the manifest.
+	Force bool `toml:"-" json:"-"`
+	// DiscardCheckpoint discards the checkpoint in output-dir saved with a different config rather than refusing to start.
+	DiscardCheckpoint bool `toml:"-" json:"-"`
+	// VerifyFixDir is the directory of the fix sql files to verify by the manifest.
+	VerifyFixDir string `toml:"-" json:"-"`
+	// CompareReports is the old and new `report.json` to compare, the config is not needed.
+	CompareReports []string `toml:"-" json:"-"`
+	// ListHistory lists the runs of HistoryTask in the history instead of comparing.
+	ListHistory bool `toml:"-" json:"-"`
+	// HistoryTask is the task whose history is listed, task-name if empty.
+	HistoryTask string `toml:"-" json:"-"`
+	// CompareWithPrevious prints the tables newly unequal or newly healed in the newest run of the history
+	// compared with the previous run.
+	CompareWithPrevious bool `toml:"-" json:"-"`
+	// DryRun checks the config and prints the plan of the comparison without reading any data.
+	DryRun bool `toml:"-" json:"-"`
+	// ListTables prints the tables to compare resolved by the config with the estimated sizes.
+	ListTables bool `toml:"-" json:"-"`
+	// Yes confirms the config printed before the comparison without the prompt, which is required if
+	// the standard input is not a terminal.
+	Yes bool `toml:"-" json:"-"`
+	// DebugChunkID compares only the chunk of the id in DebugReportFile for debugging, e.g. the chunk reported
+	// inconsistent by the last run, see `IsDebugRange`.
+	DebugChunkID string `toml:"-" json:"-"`
+	// DebugReportFile is the `report.json` the chunk of DebugChunkID is found in, `report.json` in output-dir if empty.
+	DebugReportFile string `toml:"-" json:"-"`
+	// DebugRange compares only the rows of DebugTable matching the condition for debugging, e.g. "id between 1 and 10".
+	DebugRange string `toml:"-" json:"-"`
+	// DebugTable is the target table like "schema.table" compared in DebugRange.
+	DebugTable string `toml:"-" json:"-"`
+	// DebugMaxRows is the maximum number of the different rows printed for debugging, 0 means no limit.
+	DebugMaxRows int `toml:"-" json:"-"`
+	// DebugFixSQLFile is the file the fix sql of the debugged chunk or range is written to, empty means no fix sql.
+	DebugFixSQLFile string `toml:"-" json:"-"`
+}
+
+// ReportFileName returns the name of the report streamed to stdout by output-to-stdout, which is decided by output-format.
+func (c *Config) ReportFileName() (string, error) {
+	switch c.OutputFormat {
+	case OutputFormatJSON:
+		return "report.json", nil
+	case OutputFormatText:
+		return "summary.txt", nil
+	default:
+		return "", errors.Errorf("unknown output-format %q", c.OutputFormat)
+	}
+}
+
+// GetTaskName returns task-name, or the name of the config file without the extension if it's not set.
+func (c *Config) GetTaskName() string {
+	if len(c.TaskName) == 0 {
+		return strings.TrimSuffix(filepath.Base(c.ConfigFile), filepath.Ext(c.ConfigFile))
+	}
+	return c.TaskName
+}
+
+// IsDebugRange returns true if only a chunk or a range is compared for debugging by `--chunk-id` or `--range`.
+func (c *Config) IsDebugRange() bool {
+	return len(c.DebugChunkID) > 0 || len(c.DebugRange) > 0
+}
+
+// GetDebugTable returns the schema and the table of DebugTable like "schema.table".
+func (c *Config) GetDebugTable() (schema, table string, err error) {
+	fields := strings.SplitN(c.DebugTable, ".", 2)
+	if len(fields) != 2 || len(fields[0]) == 0 || len(fields[1]) == 0 {
+		return "", "", errors.Errorf("table should be like \"schema.table\", but got %q", c.DebugTable)
+	}
+	return fields[0], fields[1], nil
 }
 
 // NewConfig creates a new config.
@@ -300,12 +1090,96 @@ func NewConfig() *Config {
 
 	fs.BoolVarP(&cfg.PrintVersion, "version", "V", false, "print version of sync_diff_inspector")
 	fs.StringVarP(&cfg.LogLevel, "log-level", "L", "info", "log level: debug, info, warn, error, fatal")
+	fs.StringVar(&cfg.LogFormat, "log-format", "text", "log format: text, json")
 	fs.StringVarP(&cfg.ConfigFile, "config", "C", "", "Config file")
+	fs.StringArrayVar(&cfg.Overrides, "override", nil, "override the scalar config value by `key=value`, e.g. data-sources.target.host=prod-tidb, can be specified multiple times")
+	fs.StringVar(&cfg.ConfigFormat, "config-format", "", "the format of the config file, \"toml\" or \"yaml\", decided by the extension if not set")
 	fs.StringVar(&cfg.DMAddr, "dm-addr", "", "the address of DM")
 	fs.StringVar(&cfg.DMTask, "dm-task", "", "identifier of dm task")
 	fs.IntVar(&cfg.CheckThreadCount, "check-thread-count", 1, "how many goroutines are created to check data")
+	fs.IntVar(&cfg.StructThreadCount, "struct-thread-count", 0, "how many goroutines are created to check the table structures, use check-thread-count if 0")
+	fs.IntVar(&cfg.ShardStructSample, "shard-struct-sample", 0, "only check the structures of the number of shards of each table in the shard merging, 0 means all the shards are checked")
+	fs.IntVar(&cfg.MaxOpenConns, "max-open-conns", 0, "the maximum number of the open connections to each database, 0 means it's decided by check-thread-count")
 	fs.BoolVar(&cfg.ExportFixSQL, "export-fix-sql", true, "set true if want to compare rows or set to false will only compare checksum")
 	fs.BoolVar(&cfg.CheckStructOnly, "check-struct-only", false, "ignore check table's data")
+	fs.StringVar(&cfg.CheckMode, "check-mode", CheckModeFull, "how to check the data: full, count, count-then-full")
+	fs.BoolVar(&cfg.CountPrecheck, "count-precheck", false, "compare the row counts of the tables before comparing them by chunks, and flag the tables whose counts are different")
+	fs.Float64Var(&cfg.SampleRate, "sample-rate", 1, "only compare the fraction of the chunks selected randomly, 1 means all the chunks")
+	fs.Int64Var(&cfg.SampleSeed, "sample-seed", 0, "the seed to select the chunks by sample-rate, 0 means a random seed")
+	fs.StringVar(&cfg.AdminChecksum, "admin-checksum", AdminChecksumAuto, "whether to verify the tables by ADMIN CHECKSUM TABLE before comparing them by chunks: auto, on, off")
+	fs.StringVar(&cfg.FixTarget, "fix-target", FixTargetTarget, "the side the fix sql is generated for: target, source")
+	fs.BoolVar(&cfg.SkipNoPKTables, "skip-no-pk-tables", false, "skip the data check of the tables without primary key or unique key even if compare-no-index-tables is set")
+	fs.BoolVar(&cfg.CompareNoIndexTables, "compare-no-index-tables", false, "compare the tables without primary key or unique key by the whole table, which are skipped by default")
+	fs.Int64Var(&cfg.NoIndexTableMaxRows, "no-index-table-max-rows", DefaultNoIndexTableMaxRows, "the max rows of the tables without primary key or unique key to compare")
+	fs.BoolVar(&cfg.CheckPKUniqueness, "check-pk-uniqueness", false, "check whether the values of the primary key or the unique key are unique on both sides before comparing the data")
+	fs.BoolVar(&cfg.DataCheckOnStructMismatch, "data-check-on-struct-mismatch", false, "still check the data when the struct mismatch is non-breaking, e.g. the column orders or the indices are different")
+	fs.BoolVar(&cfg.MatchColumnsByName, "match-columns-by-name", false, "match the columns by name rather than position, so that the tables only differ in the column order are compared")
+	fs.BoolVar(&cfg.CompareEnumByValue, "compare-enum-by-value", true, "compare the ENUM and SET columns whose members are in different orders on both sides by value rather than the stored index")
+	fs.BoolVar(&cfg.FailOnEnumMemberOrder, "fail-on-enum-member-order", false, "fail the struct check of the tables whose ENUM and SET members are in different orders on both sides even if they are compared by value")
+	fs.BoolVar(&cfg.TrimCharPadding, "trim-char-padding", false, "ignore the trailing spaces of the CHAR and VARCHAR values, the binary columns are not trimmed")
+	fs.BoolVar(&cfg.CompareGeometry, "compare-geometry", false, "compare the GEOMETRY columns by the SRID and the WKB of the values, otherwise the data check of the tables with the GEOMETRY columns is skipped")
+	fs.BoolVar(&cfg.CheckViews, "check-views", false, "compare the definitions of the views, and fail if they are different or missing on the target")
+	fs.BoolVar(&cfg.CheckViewData, "check-view-data", false, "compare the data of the updatable views on the target like the tables without primary key or unique key")
+	fs.BoolVar(&cfg.FailOnMissingTables, "fail-on-missing-tables", false, "fail if any table only exists on the sources or the target")
+	fs.BoolVar(&cfg.CheckPartitionDefinition, "check-partition-definition", false, "compare the partition definitions of the tables")
+	fs.BoolVar(&cfg.SplitByPartition, "split-by-partition", false, "split the chunks of the partitioned tables partition by partition")
+	fs.BoolVar(&cfg.AdaptiveChunking, "adaptive-chunking", false, "split the chunks to hold roughly equal rows by sampling the distribution of the split fields")
+	fs.Int64Var(&cfg.MaxDiffRows, "max-diff-rows", 0, "abort the comparison when the number of diff rows exceeds it, 0 means no limit")
+	fs.Int64Var(&cfg.MaxDiffRowsPerTable, "max-diff-rows-per-table", 0, "stop comparing the rest chunks of a table when the number of its diff rows exceeds it, 0 means no limit")
+	fs.BoolVar(&cfg.FailFast, "fail-fast", false, "stop the comparison once the first table is found different")
+	fs.StringVar(&cfg.ChunkOrder, "chunk-order", ChunkOrderPKAsc, "the order the chunks are compared in: pk-asc, size-desc")
+	fs.Int64Var(&cfg.FixFileMaxSize, "fix-file-max-size", 0, "rotate to a new fix sql file when the size in bytes exceeds it, 0 means no limit")
+	fs.StringVar(&cfg.FixFileCompression, "fix-file-compression", "", "compress the fix sql files: gzip, zstd")
+	fs.StringVar(&cfg.FixFileLayout, "fix-file-layout", FixFileLayoutChunk, "how the fix sql files are laid out: chunk, table")
+	fs.StringVar(&cfg.FixSQLMode, "fix-sql-mode", FixSQLModeReplace, "the statements to fix the different rows: replace, insert-on-duplicate, delete-insert")
+	fs.BoolVar(&cfg.GenerateStructFix, "generate-struct-fix", false, "generate the statements to make the structures of the target match the source, which are not applied")
+	fs.BoolVar(&cfg.ExportDiffRows, "export-diff-rows", false, "export the rows only on the sources or the target of each failing table to a CSV file")
+	fs.Int64Var(&cfg.MaxExportRows, "max-export-rows", DefaultMaxExportRows, "the max rows exported of each table by export-diff-rows, 0 means no limit")
+	fs.BoolVar(&cfg.ExportRowDiffs, "export-row-diffs", false, "export the rows different on both sides of each failing chunk with the different column values to a NDJSON file")
+	fs.Int64Var(&cfg.MaxRowDiffs, "max-row-diffs", DefaultMaxRowDiffs, "the max rows exported of each table by export-row-diffs, 0 means no limit")
+	fs.BoolVar(&cfg.LogSQL, "log-sql", false, "log the checksum sql and the row comparison sql of each chunk with the bound values")
+	fs.StringVar(&cfg.HeartbeatInterval, "heartbeat-interval", "30s", "the interval to log the heartbeat with the progress of the comparison, 0s means no heartbeat")
+	fs.BoolVar(&cfg.RecheckFailedChunks, "recheck-failed-chunks", false, "compare the failed chunks again after recheck-delay, and only record the chunks still different")
+	fs.StringVar(&cfg.RecheckDelay, "recheck-delay", "10s", "the delay before rechecking the failed chunks")
+	fs.IntVar(&cfg.RecheckTimes, "recheck-times", 1, "how many times the failed chunks are rechecked after recheck-delay each")
+	fs.StringVar(&cfg.RecheckSnapshot, "recheck-snapshot", RecheckSnapshotKeep, "where the failed chunks are rechecked: keep for the snapshots, latest for the latest data")
+	fs.StringVar(&cfg.RunTimeout, "run-timeout", "0s", "stop the comparison and save the checkpoint when the whole run exceeds it, 0s means no timeout")
+	fs.BoolVar(&cfg.RetryErroredTables, "retry-errored-tables", true, "compare the tables meeting errors in the last run again when resuming from the checkpoint")
+	fs.Int64Var(&cfg.TableSizeMin, "table-size-min", 0, "skip the data check of the tables whose size in bytes is less than it, 0 means no limit")
+	fs.Int64Var(&cfg.TableSizeMax, "table-size-max", 0, "skip the data check of the tables whose size in bytes is greater than it, 0 means no limit")
+	fs.StringVar(&cfg.ZeroSizePolicy, "zero-size-policy", ZeroSizePolicyWarnAndInclude, "what to do with the tables whose size is 0 in the statistics when the table size is limited: include, exclude, warn-and-include")
+	fs.Float64Var(&cfg.RowsEstimateWarnFactor, "rows-estimate-warn-factor", DefaultRowsEstimateWarnFactor, "warn about the tables whose estimated row count diverges from the actual rows by more than it times, 0 means no warning")
+	fs.BoolVar(&cfg.TimestampedOutput, "timestamped-output", false, "nest the outputs of each run under output-dir/<UTC timestamp like 20211001T010000Z>/, and link output-dir/latest to the newest run")
+	fs.StringVar(&cfg.TaskName, "task-name", "", "the name of the task expanded in {task-name} of output-dir, the name of the config file without the extension by default")
+	fs.BoolVar(&cfg.KeepHistory, "keep-history", false, "archive the summary, the report and the config of each run under history-dir/<task-name>/<UTC timestamp like 20211001T010000Z>/")
+	fs.StringVar(&cfg.HistoryDir, "history-dir", "", "the directory of the history, output-dir/history by default")
+	fs.IntVar(&cfg.KeepLast, "keep-last", DefaultKeepLast, "the number of the newest runs of the task kept in the history, 0 means no limit")
+	fs.StringVar(&cfg.OutputDirPerm, "output-dir-perm", "0755", "the permission of the directories created in output-dir in octal")
+	fs.BoolVar(&cfg.RawUnits, "raw-units", false, "write the sizes in the summary in bytes and the row counts as is for machine consumption, rather than humanized like 1.5GB and 1,234,567")
+	fs.BoolVar(&cfg.OutputToStdout, "output-to-stdout", false, "stream the report of output-format to stdout besides output-dir, and write the progress and the summary to stderr")
+	fs.StringVar(&cfg.OutputFormat, "output-format", OutputFormatJSON, "the report streamed to stdout by output-to-stdout: json for report.json, text for summary.txt")
+	fs.StringVar(&cfg.StatusAddr, "status-addr", "", "serve the status of the comparison over HTTP on the address, e.g. 127.0.0.1:8288")
+	fs.StringVar(&cfg.TextfileMetricsPath, "textfile-metrics-path", "", "write the metrics in the OpenMetrics format into the .prom file for the textfile collector of node_exporter when the comparison ends")
+	fs.StringSliceVar(&cfg.RedactKeys, "redact-keys", nil, "the config keys whose values are masked in the outputs besides password and status-token, e.g. user,host")
+	fs.StringVar(&cfg.ApplyFixDir, "apply-fix", "", "apply the fix sql files in the directory to the fix-target instead of checking data")
+	fs.IntVar(&cfg.ApplyBatchSize, "apply-batch-size", 100, "the number of fix sql statements applied in one transaction")
+	fs.BoolVar(&cfg.ApplyDryRun, "apply-dry-run", false, "only print the statement counts of the fix sql files to apply")
+	fs.BoolVar(&cfg.Force, "force", false, "apply the fix sql files even if they are not verified by the manifest")
+	fs.BoolVar(&cfg.DiscardCheckpoint, "discard-checkpoint", false, "discard the checkpoint in output-dir saved with a different config and start over rather than refusing to start")
+	fs.StringVar(&cfg.VerifyFixDir, "verify-fix-dir", "", "verify the fix sql files in the directory by the manifest, the config is not needed")
+	fs.StringSliceVar(&cfg.CompareReports, "compare-reports", nil, "compare the old and new report.json, e.g. old/report.json,new/report.json, the config is not needed")
+	fs.BoolVar(&cfg.ListHistory, "history", false, "list the runs of the task in the history with the results, the durations and the diff rows")
+	fs.StringVar(&cfg.HistoryTask, "task", "", "the task whose history is listed by --history, task-name by default")
+	fs.BoolVar(&cfg.CompareWithPrevious, "compare-with-previous", false, "print the tables newly unequal or newly healed in the newest run of the history compared with the previous run")
+	fs.BoolVar(&cfg.DryRun, "dry-run", false, "check the config and the databases, and print the plan of the comparison without reading any data")
+	fs.BoolVar(&cfg.ListTables, "list-tables", false, "print the tables to compare resolved by the filters and the routes with the estimated sizes, without comparing")
+	fs.StringVar(&cfg.DebugChunkID, "chunk-id", "", "only compare the chunk of the id in the report for debugging, e.g. 0:0-0:2:10, which is printed with the different rows")
+	fs.StringVar(&cfg.DebugReportFile, "report-file", "", "the report.json to find the chunk of --chunk-id in, report.json in output-dir by default")
+	fs.StringVar(&cfg.DebugRange, "range", "", "only compare the rows of --table matching the condition for debugging, e.g. \"id between 1000 and 2000\"")
+	fs.StringVar(&cfg.DebugTable, "table", "", "the target table like schema.table compared by --range")
+	fs.IntVar(&cfg.DebugMaxRows, "max-print-rows", 100, "the maximum number of the different rows printed by --chunk-id and --range, 0 means no limit")
+	fs.StringVar(&cfg.DebugFixSQLFile, "fix-sql-file", "", "write the fix sql of --chunk-id or --range to the file")
+	fs.BoolVar(&cfg.Yes, "yes", false, "confirm the config printed before the comparison without the prompt, required if the standard input is not a terminal")
 
 	fs.SortFlags = false
 	return cfg
@@ -319,7 +1193,10 @@ func (c *Config) Parse(arguments []string) error {
 		return errors.Trace(err)
 	}
 
-	if c.PrintVersion {
+	if len(c.CompareReports) > 0 && len(c.CompareReports) != 2 {
+		return errors.Errorf("compare-reports needs the old and new report.json, but got %v", c.CompareReports)
+	}
+	if c.PrintVersion || len(c.VerifyFixDir) > 0 || len(c.CompareReports) > 0 {
 		return nil
 	}
 
@@ -331,6 +1208,10 @@ func (c *Config) Parse(arguments []string) error {
 	if err != nil {
 		return errors.Trace(err)
 	}
+	err = c.applyOverrides(os.Environ(), c.Overrides)
+	if err != nil {
+		return errors.Trace(err)
+	}
 
 	// Parse again to replace with command line options.
 	err = c.FlagSet.Parse(arguments)
@@ -342,23 +1223,79 @@ func (c *Config) Parse(arguments []string) error {
 		return errors.Errorf("'%s' is an invalid flag", c.FlagSet.Arg(0))
 	}
 
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		return errors.Errorf("log-format should be \"text\" or \"json\", but got %s", c.LogFormat)
+	}
+
+	// the directory is resolved before the log file is created in it.
+	return errors.Trace(c.resolveOutputDir(time.Now()))
+}
+
+// resolveOutputDir expands the placeholders in output-dir and nests it by timestamped-output, and parses the
+// permission of the directories to create.
+func (c *Config) resolveOutputDir(now time.Time) error {
+	perm, err := strconv.ParseUint(c.OutputDirPerm, 8, 32)
+	if err != nil || perm > 0o777 || perm&0o700 != 0o700 {
+		return errors.Errorf("output-dir-perm should be an octal permission allowing the owner to read, write and search like \"0755\", but got %q", c.OutputDirPerm)
+	}
+	c.Task.dirPerm = os.FileMode(perm)
+	taskName := c.GetTaskName()
+	if len(taskName) == 0 || taskName == "." || taskName == ".." || strings.ContainsAny(taskName, `/\`) {
+		return errors.Errorf("task-name should be a valid directory name, but got %q", taskName)
+	}
+	if err = c.Task.expandOutputDir(taskName, now); err != nil {
+		return errors.Trace(err)
+	}
+	if c.TimestampedOutput {
+		if err = c.Task.resolveTimestampedOutput(now); err != nil {
+			return errors.Trace(err)
+		}
+	}
 	return nil
 }
 
+// GetHistoryDir returns the directory of the history of the task, which is `history-dir/<task-name>`.
+func (c *Config) GetHistoryDir(taskName string) string {
+	dir := c.HistoryDir
+	if len(dir) == 0 {
+		root := c.Task.OutputDir
+		if len(c.Task.rootOutputDir) > 0 {
+			root = c.Task.rootOutputDir
+		}
+		dir = filepath.Join(root, HistoryDirName)
+	}
+	return filepath.Join(dir, taskName)
+}
+
+// String returns the config in JSON with the values of the redacted keys masked, see `IsRedactedKey`.
 func (c *Config) String() string {
 	cfg, err := json.Marshal(c)
 	if err != nil {
 		return "<nil>"
 	}
-	return string(cfg)
+	return string(c.redactJSON(cfg))
 }
 
-// configFromFile loads config from file.
+// configFromFile loads config from the toml or yaml file.
 func (c *Config) configFromFile(path string) error {
-	meta, err := toml.DecodeFile(path, c)
+	format, err := configFormat(path, c.ConfigFormat)
 	if err != nil {
 		return errors.Trace(err)
 	}
+	var meta toml.MetaData
+	if format == ConfigFormatYAML {
+		var data []byte
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		meta, err = decodeYAML(data, c)
+	} else {
+		meta, err = toml.DecodeFile(path, c)
+	}
+	if err != nil {
+		return errors.Annotatef(err, "failed to decode config file %s", path)
+	}
 	if len(meta.Undecoded()) > 0 {
 		return errors.Errorf("unknown keys in config file %s: %v", path, meta.Undecoded())
 	}
@@ -416,6 +1353,8 @@ func (c *Config) adjustConfigByDMSubTasks() (err error) {
 }
 
 func (c *Config) Init() (err error) {
+	c.Task.fixTarget = c.FixTarget
+	c.Task.discardCheckpoint = c.DiscardCheckpoint
 	if len(c.DMAddr) > 0 {
 		err := c.adjustConfigByDMSubTasks()
 		if err != nil {
@@ -456,6 +1395,215 @@ func (c *Config) CheckConfig() bool {
 		log.Error("check-thread-count must greater than 0!")
 		return false
 	}
+	if c.StructThreadCount < 0 {
+		log.Error("struct-thread-count must not be less than 0!")
+		return false
+	}
+	if c.ShardStructSample < 0 {
+		log.Error("shard-struct-sample must not be less than 0!")
+		return false
+	}
+	if c.MaxOpenConns < 0 {
+		log.Error("max-open-conns must not be less than 0!")
+		return false
+	}
+	for name, dataSource := range c.DataSources {
+		if !dataSource.Valid() {
+			log.Error("the connection settings of the data source are invalid", zap.String("data source", name))
+			return false
+		}
+	}
+	if len(c.DebugChunkID) > 0 && len(c.DebugRange) > 0 {
+		log.Error("chunk-id and range can't be set at the same time")
+		return false
+	}
+	if len(c.DebugChunkID) > 0 {
+		if err := new(chunk.ChunkID).FromString(c.DebugChunkID); err != nil {
+			log.Error("chunk-id should be like \"0:0-0:2:10\"", zap.String("chunk-id", c.DebugChunkID), zap.Error(err))
+			return false
+		}
+	}
+	if len(c.DebugRange) > 0 {
+		if _, _, err := c.GetDebugTable(); err != nil {
+			log.Error("range needs the table to compare", zap.Error(err))
+			return false
+		}
+	}
+	if c.DebugMaxRows < 0 {
+		log.Error("max-print-rows must not be less than 0!")
+		return false
+	}
+	if c.MaxDiffRows < 0 {
+		log.Error("max-diff-rows must not be less than 0!")
+		return false
+	}
+	if c.MaxDiffRowsPerTable < 0 {
+		log.Error("max-diff-rows-per-table must not be less than 0!")
+		return false
+	}
+	if c.FixFileMaxSize < 0 {
+		log.Error("fix-file-max-size must not be less than 0!")
+		return false
+	}
+	if c.MaxExportRows < 0 {
+		log.Error("max-export-rows must not be less than 0!")
+		return false
+	}
+	if c.MaxRowDiffs < 0 {
+		log.Error("max-row-diffs must not be less than 0!")
+		return false
+	}
+	for _, key := range c.RedactKeys {
+		if len(strings.TrimSpace(key)) == 0 {
+			log.Error("redact-keys should not contain the empty keys", zap.Strings("redact-keys", c.RedactKeys))
+			return false
+		}
+	}
+	if c.CheckViewData && !c.CompareNoIndexTables {
+		log.Error("check-view-data needs compare-no-index-tables, because the views have no primary key or unique key")
+		return false
+	}
+	if c.ExportDiffRows && !c.ExportFixSQL {
+		log.Error("export-diff-rows needs export-fix-sql, because the rows are only compared when the fix sql is exported")
+		return false
+	}
+	if c.ExportRowDiffs && !c.ExportFixSQL {
+		log.Error("export-row-diffs needs export-fix-sql, because the rows are only compared when the fix sql is exported")
+		return false
+	}
+	switch c.FixFileCompression {
+	case "", "gzip", "zstd":
+	default:
+		log.Error("fix-file-compression should be \"gzip\" or \"zstd\"", zap.String("fix-file-compression", c.FixFileCompression))
+		return false
+	}
+	if _, err := c.ReportFileName(); err != nil {
+		log.Error("output-format should be \"json\" or \"text\"", zap.String("output-format", c.OutputFormat))
+		return false
+	}
+	if len(c.TextfileMetricsPath) > 0 && !strings.HasSuffix(c.TextfileMetricsPath, ".prom") {
+		// the textfile collector only reads the files with the extension `.prom`.
+		log.Error("textfile-metrics-path should end with \".prom\"", zap.String("textfile-metrics-path", c.TextfileMetricsPath))
+		return false
+	}
+	switch c.FixFileLayout {
+	case FixFileLayoutChunk:
+	case FixFileLayoutTable:
+		if c.FixFileMaxSize > 0 {
+			log.Error("fix-file-max-size is not supported when fix-file-layout is \"table\"")
+			return false
+		}
+	default:
+		log.Error("fix-file-layout should be \"chunk\" or \"table\"", zap.String("fix-file-layout", c.FixFileLayout))
+		return false
+	}
+	if !isValidFixSQLMode(c.FixSQLMode) {
+		log.Error("fix-sql-mode should be \"replace\", \"insert-on-duplicate\" or \"delete-insert\"", zap.String("fix-sql-mode", c.FixSQLMode))
+		return false
+	}
+	for name, tableConfig := range c.TableConfigs {
+		if len(tableConfig.FixSQLMode) > 0 && !isValidFixSQLMode(tableConfig.FixSQLMode) {
+			log.Error("fix-sql-mode should be \"replace\", \"insert-on-duplicate\" or \"delete-insert\"", zap.String("table config", name), zap.String("fix-sql-mode", tableConfig.FixSQLMode))
+			return false
+		}
+		if tableConfig.Concurrency < 0 {
+			log.Error("concurrency must not be less than 0!", zap.String("table config", name))
+			return false
+		}
+		for column, transform := range tableConfig.ColumnTransforms {
+			if !isValidColumnTransform(transform) {
+				log.Error("column-transforms should be \"lower\", \"trim\", \"unhex\" or \"mask_last4\"", zap.String("table config", name), zap.String("column", column), zap.String("transform", transform))
+				return false
+			}
+		}
+	}
+	if c.CompareNoIndexTables && c.NoIndexTableMaxRows <= 0 {
+		log.Error("no-index-table-max-rows must be greater than 0!", zap.Int64("no-index-table-max-rows", c.NoIndexTableMaxRows))
+		return false
+	}
+	if interval, err := time.ParseDuration(c.HeartbeatInterval); err != nil || interval < 0 {
+		log.Error("heartbeat-interval should be a non-negative duration like \"30s\"", zap.String("heartbeat-interval", c.HeartbeatInterval))
+		return false
+	}
+	if c.RecheckFailedChunks {
+		if delay, err := time.ParseDuration(c.RecheckDelay); err != nil || delay < 0 {
+			log.Error("recheck-delay should be a non-negative duration like \"10s\"", zap.String("recheck-delay", c.RecheckDelay))
+			return false
+		}
+		if c.RecheckTimes <= 0 {
+			log.Error("recheck-times must be greater than 0!", zap.Int("recheck-times", c.RecheckTimes))
+			return false
+		}
+		switch c.RecheckSnapshot {
+		case RecheckSnapshotKeep, RecheckSnapshotLatest:
+		default:
+			log.Error("recheck-snapshot should be \"keep\" or \"latest\"", zap.String("recheck-snapshot", c.RecheckSnapshot))
+			return false
+		}
+	}
+	if timeout, err := time.ParseDuration(c.RunTimeout); err != nil || timeout < 0 {
+		log.Error("run-timeout should be a non-negative duration like \"2h\"", zap.String("run-timeout", c.RunTimeout))
+		return false
+	}
+	switch c.CheckMode {
+	case CheckModeFull, CheckModeCount, CheckModeCountThenFull:
+	default:
+		log.Error("check-mode should be \"full\", \"count\" or \"count-then-full\"", zap.String("check-mode", c.CheckMode))
+		return false
+	}
+	switch c.AdminChecksum {
+	case AdminChecksumAuto, AdminChecksumOn, AdminChecksumOff:
+	default:
+		log.Error("admin-checksum should be \"auto\", \"on\" or \"off\"", zap.String("admin-checksum", c.AdminChecksum))
+		return false
+	}
+	if c.TableSizeMin < 0 || c.TableSizeMax < 0 {
+		log.Error("table-size-min and table-size-max must not be less than 0!")
+		return false
+	}
+	if c.TableSizeMax > 0 && c.TableSizeMin > c.TableSizeMax {
+		log.Error("table-size-min must not be greater than table-size-max!", zap.Int64("table-size-min", c.TableSizeMin), zap.Int64("table-size-max", c.TableSizeMax))
+		return false
+	}
+	switch c.ChunkOrder {
+	case ChunkOrderPKAsc, ChunkOrderSizeDesc:
+	default:
+		log.Error("chunk-order should be \"pk-asc\" or \"size-desc\"", zap.String("chunk-order", c.ChunkOrder))
+		return false
+	}
+	switch c.ZeroSizePolicy {
+	case ZeroSizePolicyInclude, ZeroSizePolicyExclude, ZeroSizePolicyWarnAndInclude:
+	default:
+		log.Error("zero-size-policy should be \"include\", \"exclude\" or \"warn-and-include\"", zap.String("zero-size-policy", c.ZeroSizePolicy))
+		return false
+	}
+	if c.SampleRate <= 0 || c.SampleRate > 1 {
+		log.Error("sample-rate must be greater than 0 and not greater than 1!", zap.Float64("sample-rate", c.SampleRate))
+		return false
+	}
+	if c.RowsEstimateWarnFactor != 0 && c.RowsEstimateWarnFactor <= 1 {
+		log.Error("rows-estimate-warn-factor must be greater than 1, or 0 to disable the warning!", zap.Float64("rows-estimate-warn-factor", c.RowsEstimateWarnFactor))
+		return false
+	}
+	if c.ApplyBatchSize <= 0 {
+		log.Error("apply-batch-size must greater than 0!")
+		return false
+	}
+	switch c.FixTarget {
+	case FixTargetTarget:
+	case FixTargetSource:
+		if len(c.Task.SourceInstances) > 1 {
+			log.Error("fix-target = \"source\" doesn't support the shard merge sources, because the destination shard is ambiguous")
+			return false
+		}
+		if c.GenerateStructFix {
+			log.Error("generate-struct-fix only makes the structures of the target match the source, which doesn't support fix-target = \"source\"")
+			return false
+		}
+	default:
+		log.Error("fix-target should be \"target\" or \"source\"", zap.String("fix-target", c.FixTarget))
+		return false
+	}
 	if len(c.DMAddr) != 0 {
 		u, err := url.Parse(c.DMAddr)
 		if err != nil || u.Scheme == "" || u.Host == "" {
@@ -468,6 +1616,27 @@ func (c *Config) CheckConfig() bool {
 			return false
 		}
 	}
+	if c.WaitSync != nil {
+		if !c.WaitSync.Valid() {
+			return false
+		}
+		if c.WaitSync.Type == WaitSyncTiCDC && len(c.Task.SourceInstances) > 1 {
+			log.Error("wait-sync.type = \"ticdc\" doesn't support the shard merge sources, because a changefeed replicates one TiDB cluster")
+			return false
+		}
+	}
+	if c.Notify != nil && c.Notify.Email != nil && !c.Notify.Email.Valid() {
+		return false
+	}
+	if c.KeepLast < 0 {
+		log.Error("keep-last must not be less than 0!")
+		return false
+	}
+	for label, pair := range c.QueryPairs {
+		if pair == nil || !pair.Valid(label, &c.Task) {
+			return false
+		}
+	}
 	return true
 }
 
@@ -482,9 +1651,62 @@ func pathExists(_path string) (bool, error) {
 	return true, nil
 }
 
-func mkdirAll(base string) error {
+func mkdirAll(base string, perm os.FileMode) error {
 	mask := syscall.Umask(0)
-	err := os.MkdirAll(base, LocalDirPerm)
+	err := os.MkdirAll(base, perm)
 	syscall.Umask(mask)
 	return errors.Trace(err)
 }
+
+// RunDirName returns the name of the directory of the run started at the time.
+func RunDirName(startTime time.Time) string {
+	return startTime.UTC().Format(RunDirTimeLayout)
+}
+
+// ParseRunDirName returns the start time of the run named by `RunDirName`, and the RFC3339 names of the runs by the
+// old versions are parsed too.
+func ParseRunDirName(name string) (time.Time, error) {
+	startTime, err := time.Parse(RunDirTimeLayout, name)
+	if err == nil {
+		return startTime, nil
+	}
+	if startTime, rfcErr := time.Parse(time.RFC3339, name); rfcErr == nil {
+		return startTime, nil
+	}
+	return time.Time{}, errors.Trace(err)
+}
+
+// UpdateLatestLink points the symlink `dir/latest` to `target` atomically, by renaming a new symlink over it,
+// so the readers never see the link missing.
+func UpdateLatestLink(dir, target string) error {
+	tmpLink := filepath.Join(dir, fmt.Sprintf(".%s-%d.tmp", LatestLinkName, os.Getpid()))
+	if err := os.Remove(tmpLink); err != nil && !os.IsNotExist(err) {
+		return errors.Trace(err)
+	}
+	if err := os.Symlink(target, tmpLink); err != nil {
+		return errors.Trace(err)
+	}
+	if err := os.Rename(tmpLink, filepath.Join(dir, LatestLinkName)); err != nil {
+		os.Remove(tmpLink)
+		return errors.Trace(err)
+	}
+	return nil
+}
+
+func isValidColumnTransform(transform string) bool {
+	switch transform {
+	case ColumnTransformLower, ColumnTransformTrim, ColumnTransformUnhex, ColumnTransformMaskLast4:
+		return true
+	default:
+		return false
+	}
+}
+
+func isValidFixSQLMode(mode string) bool {
+	switch mode {
+	case FixSQLModeReplace, FixSQLModeInsertOnDuplicate, FixSQLModeDeleteInsert:
+		return true
+	default:
+		return false
+	}
+}
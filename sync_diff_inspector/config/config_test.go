@@ -15,8 +15,11 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
 	"github.com/stretchr/testify/require"
 )
 
@@ -48,7 +51,7 @@ func TestParseConfig(t *testing.T) {
 	require.True(t, cfg.CheckConfig())
 
 	// we might not use the same config to run this test. e.g. MYSQL_PORT can be 4000
-	require.Equal(t, cfg.String(), "{\"check-thread-count\":4,\"export-fix-sql\":true,\"check-struct-only\":false,\"dm-addr\":\"\",\"dm-task\":\"\",\"data-sources\":{\"mysql1\":{\"host\":\"127.0.0.1\",\"port\":3306,\"user\":\"root\",\"password\":\"\",\"sql-mode\":\"\",\"snapshot\":\"\",\"route-rules\":[\"rule1\",\"rule2\"],\"Router\":{\"Selector\":{}},\"Conn\":null},\"mysql2\":{\"host\":\"127.0.0.1\",\"port\":3306,\"user\":\"root\",\"password\":\"\",\"sql-mode\":\"\",\"snapshot\":\"\",\"route-rules\":[\"rule1\",\"rule2\"],\"Router\":{\"Selector\":{}},\"Conn\":null},\"mysql3\":{\"host\":\"127.0.0.1\",\"port\":3306,\"user\":\"root\",\"password\":\"\",\"sql-mode\":\"\",\"snapshot\":\"\",\"route-rules\":[\"rule1\",\"rule3\"],\"Router\":{\"Selector\":{}},\"Conn\":null},\"tidb0\":{\"host\":\"127.0.0.1\",\"port\":4000,\"user\":\"root\",\"password\":\"\",\"sql-mode\":\"\",\"snapshot\":\"\",\"route-rules\":null,\"Router\":{\"Selector\":{}},\"Conn\":null}},\"routes\":{\"rule1\":{\"schema-pattern\":\"test_*\",\"table-pattern\":\"t_*\",\"target-schema\":\"test\",\"target-table\":\"t\"},\"rule2\":{\"schema-pattern\":\"test2_*\",\"table-pattern\":\"t2_*\",\"target-schema\":\"test2\",\"target-table\":\"t2\"},\"rule3\":{\"schema-pattern\":\"test2_*\",\"table-pattern\":\"t2_*\",\"target-schema\":\"test\",\"target-table\":\"t\"}},\"table-configs\":{\"config1\":{\"target-tables\":[\"schema*.table*\",\"test2.t2\"],\"Schema\":\"\",\"Table\":\"\",\"ConfigIndex\":0,\"HasMatched\":false,\"IgnoreColumns\":[\"\",\"\"],\"Fields\":[\"\"],\"Range\":\"age \\u003e 10 AND age \\u003c 20\",\"TargetTableInfo\":null,\"Collation\":\"\",\"chunk-size\":0}},\"task\":{\"source-instances\":[\"mysql1\",\"mysql2\",\"mysql3\"],\"source-routes\":null,\"target-instance\":\"tidb0\",\"target-check-tables\":[\"schema*.table*\",\"!c.*\",\"test2.t2\"],\"target-configs\":[\"config1\"],\"output-dir\":\"/tmp/output/config\",\"SourceInstances\":[{\"host\":\"127.0.0.1\",\"port\":3306,\"user\":\"root\",\"password\":\"\",\"sql-mode\":\"\",\"snapshot\":\"\",\"route-rules\":[\"rule1\",\"rule2\"],\"Router\":{\"Selector\":{}},\"Conn\":null},{\"host\":\"127.0.0.1\",\"port\":3306,\"user\":\"root\",\"password\":\"\",\"sql-mode\":\"\",\"snapshot\":\"\",\"route-rules\":[\"rule1\",\"rule2\"],\"Router\":{\"Selector\":{}},\"Conn\":null},{\"host\":\"127.0.0.1\",\"port\":3306,\"user\":\"root\",\"password\":\"\",\"sql-mode\":\"\",\"snapshot\":\"\",\"route-rules\":[\"rule1\",\"rule3\"],\"Router\":{\"Selector\":{}},\"Conn\":null}],\"TargetInstance\":{\"host\":\"127.0.0.1\",\"port\":4000,\"user\":\"root\",\"password\":\"\",\"sql-mode\":\"\",\"snapshot\":\"\",\"route-rules\":null,\"Router\":{\"Selector\":{}},\"Conn\":null},\"TargetTableConfigs\":[{\"target-tables\":[\"schema*.table*\",\"test2.t2\"],\"Schema\":\"\",\"Table\":\"\",\"ConfigIndex\":0,\"HasMatched\":false,\"IgnoreColumns\":[\"\",\"\"],\"Fields\":[\"\"],\"Range\":\"age \\u003e 10 AND age \\u003c 20\",\"TargetTableInfo\":null,\"Collation\":\"\",\"chunk-size\":0}],\"TargetCheckTables\":[{},{},{}],\"FixDir\":\"/tmp/output/config/fix-on-tidb0\",\"CheckpointDir\":\"/tmp/output/config/checkpoint\",\"HashFile\":\"\"},\"ConfigFile\":\"config_sharding.toml\",\"PrintVersion\":false}")
+	require.Equal(t, cfg.String(), "{\"check-thread-count\":4,\"struct-thread-count\":0,\"shard-struct-sample\":0,\"max-open-conns\":0,\"export-fix-sql\":true,\"check-struct-only\":false,\"check-mode\":\"full\",\"count-precheck\":false,\"admin-checksum\":\"auto\",\"sample-rate\":1,\"sample-seed\":0,\"fix-target\":\"target\",\"skip-no-pk-tables\":false,\"compare-no-index-tables\":false,\"no-index-table-max-rows\":100000,\"check-pk-uniqueness\":false,\"data-check-on-struct-mismatch\":false,\"match-columns-by-name\":false,\"compare-enum-by-value\":true,\"fail-on-enum-member-order\":false,\"trim-char-padding\":false,\"compare-geometry\":false,\"charset-map\":null,\"check-views\":false,\"check-view-data\":false,\"fail-on-missing-tables\":false,\"force-include-engines\":[],\"check-partition-definition\":false,\"split-by-partition\":false,\"adaptive-chunking\":false,\"max-diff-rows\":0,\"max-diff-rows-per-table\":0,\"fail-fast\":false,\"chunk-order\":\"pk-asc\",\"fix-file-max-size\":0,\"fix-file-compression\":\"\",\"fix-file-layout\":\"chunk\",\"fix-sql-mode\":\"replace\",\"generate-struct-fix\":false,\"export-diff-rows\":false,\"max-export-rows\":10000,\"export-row-diffs\":false,\"max-row-diffs\":1000,\"log-sql\":false,\"heartbeat-interval\":\"30s\",\"recheck-failed-chunks\":false,\"recheck-delay\":\"10s\",\"recheck-times\":1,\"recheck-snapshot\":\"keep\",\"run-timeout\":\"0s\",\"retry-errored-tables\":true,\"table-size-min\":0,\"table-size-max\":0,\"zero-size-policy\":\"warn-and-include\",\"rows-estimate-warn-factor\":2,\"timestamped-output\":false,\"task-name\":\"\",\"keep-history\":false,\"history-dir\":\"\",\"keep-last\":20,\"output-dir-perm\":\"0755\",\"raw-units\":false,\"output-to-stdout\":false,\"output-format\":\"json\",\"status-addr\":\"\",\"textfile-metrics-path\":\"\",\"redact-keys\":null,\"dm-addr\":\"\",\"dm-task\":\"\",\"wait-sync\":null,\"notify\":null,\"data-sources\":{\"mysql1\":{\"host\":\"127.0.0.1\",\"port\":3306,\"user\":\"root\",\"password\":\"\",\"sql-mode\":\"\",\"snapshot\":\"\",\"route-rules\":[\"rule1\",\"rule2\"],\"Router\":{\"Selector\":{}},\"Conn\":null},\"mysql2\":{\"host\":\"127.0.0.1\",\"port\":3306,\"user\":\"root\",\"password\":\"\",\"sql-mode\":\"\",\"snapshot\":\"\",\"route-rules\":[\"rule1\",\"rule2\"],\"Router\":{\"Selector\":{}},\"Conn\":null},\"mysql3\":{\"host\":\"127.0.0.1\",\"port\":3306,\"user\":\"root\",\"password\":\"\",\"sql-mode\":\"\",\"snapshot\":\"\",\"route-rules\":[\"rule1\",\"rule3\"],\"Router\":{\"Selector\":{}},\"Conn\":null},\"tidb0\":{\"host\":\"127.0.0.1\",\"port\":4000,\"user\":\"root\",\"password\":\"\",\"sql-mode\":\"\",\"snapshot\":\"\",\"route-rules\":null,\"Router\":{\"Selector\":{}},\"Conn\":null}},\"routes\":{\"rule1\":{\"schema-pattern\":\"test_*\",\"table-pattern\":\"t_*\",\"target-schema\":\"test\",\"target-table\":\"t\"},\"rule2\":{\"schema-pattern\":\"test2_*\",\"table-pattern\":\"t2_*\",\"target-schema\":\"test2\",\"target-table\":\"t2\"},\"rule3\":{\"schema-pattern\":\"test2_*\",\"table-pattern\":\"t2_*\",\"target-schema\":\"test\",\"target-table\":\"t\"}},\"table-configs\":{\"config1\":{\"target-tables\":[\"schema*.table*\",\"test2.t2\"],\"Schema\":\"\",\"Table\":\"\",\"ConfigIndex\":0,\"HasMatched\":false,\"IgnoreColumns\":[\"\",\"\"],\"Fields\":[\"\"],\"Range\":\"age \\u003e 10 AND age \\u003c 20\",\"TargetTableInfo\":null,\"Collation\":\"\",\"chunk-size\":0}},\"query-pairs\":null,\"task\":{\"source-instances\":[\"mysql1\",\"mysql2\",\"mysql3\"],\"source-routes\":null,\"target-instance\":\"tidb0\",\"target-check-tables\":[\"schema*.table*\",\"!c.*\",\"test2.t2\"],\"target-configs\":[\"config1\"],\"output-dir\":\"/tmp/output/config\",\"SourceInstances\":[{\"host\":\"127.0.0.1\",\"port\":3306,\"user\":\"root\",\"password\":\"\",\"sql-mode\":\"\",\"snapshot\":\"\",\"route-rules\":[\"rule1\",\"rule2\"],\"Router\":{\"Selector\":{}},\"Conn\":null},{\"host\":\"127.0.0.1\",\"port\":3306,\"user\":\"root\",\"password\":\"\",\"sql-mode\":\"\",\"snapshot\":\"\",\"route-rules\":[\"rule1\",\"rule2\"],\"Router\":{\"Selector\":{}},\"Conn\":null},{\"host\":\"127.0.0.1\",\"port\":3306,\"user\":\"root\",\"password\":\"\",\"sql-mode\":\"\",\"snapshot\":\"\",\"route-rules\":[\"rule1\",\"rule3\"],\"Router\":{\"Selector\":{}},\"Conn\":null}],\"TargetInstance\":{\"host\":\"127.0.0.1\",\"port\":4000,\"user\":\"root\",\"password\":\"\",\"sql-mode\":\"\",\"snapshot\":\"\",\"route-rules\":null,\"Router\":{\"Selector\":{}},\"Conn\":null},\"TargetTableConfigs\":[{\"target-tables\":[\"schema*.table*\",\"test2.t2\"],\"Schema\":\"\",\"Table\":\"\",\"ConfigIndex\":0,\"HasMatched\":false,\"IgnoreColumns\":[\"\",\"\"],\"Fields\":[\"\"],\"Range\":\"age \\u003e 10 AND age \\u003c 20\",\"TargetTableInfo\":null,\"Collation\":\"\",\"chunk-size\":0}],\"TargetCheckTables\":[{},{},{}],\"FixDir\":\"/tmp/output/config/fix-on-tidb0\",\"CheckpointDir\":\"/tmp/output/config/checkpoint\",\"HashFile\":\"/tmp/output/config/config.hash\"},\"ConfigFile\":\"config_sharding.toml\",\"PrintVersion\":false}")
 	hash, err := cfg.Task.ComputeConfigHash()
 	require.NoError(t, err)
 	require.Equal(t, hash, "e03a88f9270c3906739d3f51b54d5011d7f04d55f8e14f4a3add59c93b3e877f")
@@ -68,18 +71,482 @@ func TestError(t *testing.T) {
 	cfg := NewConfig()
 	// Parse
 	require.Contains(t, cfg.Parse([]string{"--config", "no_exist.toml"}).Error(), "no_exist.toml: no such file or directory")
+	require.Contains(t, NewConfig().Parse([]string{"--config", "config.toml", "--log-format", "xml"}).Error(), "log-format should be")
+	cfg = NewConfig()
+	require.NoError(t, cfg.Parse([]string{"--config", "config.toml", "--log-format", "json", "--log-sql"}))
+	require.Equal(t, "json", cfg.LogFormat)
+	require.True(t, cfg.LogSQL)
+	cfg = NewConfig()
 
 	// CheckConfig
 	cfg.CheckThreadCount = 0
 	require.False(t, cfg.CheckConfig())
 	cfg.CheckThreadCount = 1
 	require.True(t, cfg.CheckConfig())
+	cfg.StructThreadCount = -1
+	require.False(t, cfg.CheckConfig())
+	cfg.StructThreadCount = 0
+	cfg.ShardStructSample = -1
+	require.False(t, cfg.CheckConfig())
+	cfg.ShardStructSample = 0
+	cfg.MaxOpenConns = -1
+	require.False(t, cfg.CheckConfig())
+	cfg.MaxOpenConns = 0
+	cfg.TableConfigs = map[string]*TableConfig{"config1": {ColumnTransforms: map[string]string{"phone": "mask_last4", "email": "upper"}}}
+	require.False(t, cfg.CheckConfig())
+	cfg.TableConfigs["config1"].ColumnTransforms["email"] = "lower"
+	require.True(t, cfg.CheckConfig())
+	cfg.TableConfigs = nil
+	cfg.HeartbeatInterval = "-30s"
+	require.False(t, cfg.CheckConfig())
+	cfg.HeartbeatInterval = "0s"
+	require.True(t, cfg.CheckConfig())
+	cfg.RunTimeout = "2 hours"
+	require.False(t, cfg.CheckConfig())
+	cfg.RunTimeout = "2h"
+	require.True(t, cfg.CheckConfig())
+	cfg.CompareNoIndexTables = true
+	cfg.NoIndexTableMaxRows = 0
+	require.False(t, cfg.CheckConfig())
+	cfg.NoIndexTableMaxRows = DefaultNoIndexTableMaxRows
+	require.True(t, cfg.CheckConfig())
+	cfg.RowsEstimateWarnFactor = 0.5
+	require.False(t, cfg.CheckConfig())
+	cfg.RowsEstimateWarnFactor = 0
+	require.True(t, cfg.CheckConfig())
+	cfg.RowsEstimateWarnFactor = DefaultRowsEstimateWarnFactor
+	cfg.CompareNoIndexTables = false
+	cfg.MaxExportRows = -1
+	require.False(t, cfg.CheckConfig())
+	cfg.MaxExportRows = DefaultMaxExportRows
+	cfg.ExportDiffRows = true
+	cfg.ExportFixSQL = false
+	require.False(t, cfg.CheckConfig())
+	cfg.ExportFixSQL = true
+	require.True(t, cfg.CheckConfig())
+	cfg.ExportDiffRows = false
+	cfg.ExportRowDiffs = true
+	cfg.ExportFixSQL = false
+	require.False(t, cfg.CheckConfig())
+	cfg.ExportFixSQL = true
+	cfg.MaxRowDiffs = -1
+	require.False(t, cfg.CheckConfig())
+	cfg.MaxRowDiffs = DefaultMaxRowDiffs
+	require.True(t, cfg.CheckConfig())
+	cfg.ExportRowDiffs = false
+	cfg.CheckViewData = true
+	require.False(t, cfg.CheckConfig())
+	cfg.CompareNoIndexTables = true
+	require.True(t, cfg.CheckConfig())
+	cfg.CheckViewData = false
+	cfg.CompareNoIndexTables = false
+	cfg.RedactKeys = []string{"user", " "}
+	require.False(t, cfg.CheckConfig())
+	cfg.RedactKeys = []string{"user"}
+	require.True(t, cfg.CheckConfig())
+	cfg.FixTarget = FixTargetSource
+	require.True(t, cfg.CheckConfig())
+	cfg.GenerateStructFix = true
+	require.False(t, cfg.CheckConfig())
+	cfg.FixTarget = FixTargetTarget
+	require.True(t, cfg.CheckConfig())
+	cfg.GenerateStructFix = false
+	cfg.FixFileLayout = "schema"
+	require.False(t, cfg.CheckConfig())
+	cfg.FixFileLayout = FixFileLayoutTable
+	require.True(t, cfg.CheckConfig())
+	cfg.FixFileMaxSize = 1024
+	require.False(t, cfg.CheckConfig())
+	cfg.FixFileMaxSize = 0
+	cfg.FixFileLayout = FixFileLayoutChunk
+	cfg.OutputFormat = "yaml"
+	require.False(t, cfg.CheckConfig())
+	cfg.OutputFormat = OutputFormatText
+	require.True(t, cfg.CheckConfig())
+	cfg.MaxDiffRowsPerTable = -1
+	require.False(t, cfg.CheckConfig())
+	cfg.MaxDiffRowsPerTable = 0
+	cfg.ChunkOrder = "size-asc"
+	require.False(t, cfg.CheckConfig())
+	cfg.ChunkOrder = ChunkOrderSizeDesc
+	require.True(t, cfg.CheckConfig())
+	cfg.TextfileMetricsPath = "/var/lib/node_exporter/sync_diff.txt"
+	require.False(t, cfg.CheckConfig())
+	cfg.TextfileMetricsPath = "/var/lib/node_exporter/sync_diff.prom"
+	require.True(t, cfg.CheckConfig())
+	name, err := cfg.ReportFileName()
+	require.NoError(t, err)
+	require.Equal(t, "summary.txt", name)
+	cfg.OutputFormat = OutputFormatJSON
+	cfg.Notify = &NotifyConfig{Email: &EmailConfig{Host: "smtp.example.com", Port: 587, From: "diff@example.com"}}
+	require.False(t, cfg.CheckConfig())
+	cfg.Notify.Email.To = []string{"dba@example.com", "not an address"}
+	require.False(t, cfg.CheckConfig())
+	cfg.Notify.Email.To = []string{"dba@example.com", "DBA <dba2@example.com>"}
+	require.True(t, cfg.CheckConfig())
+	require.Equal(t, EmailTLSStartTLS, cfg.Notify.Email.GetTLS())
+	cfg.Notify.Email.TLS = "ssl"
+	require.False(t, cfg.CheckConfig())
+	cfg.Notify.Email.TLS = EmailTLSImplicit
+	cfg.Notify.Email.Port = 0
+	require.False(t, cfg.CheckConfig())
+	cfg.Notify = nil
+	cfg.QueryPairs = map[string]*QueryPairConfig{"orders": {SourceQuery: "SELECT id FROM t ORDER BY id", TargetQuery: "SELECT id FROM t ORDER BY id"}}
+	require.False(t, cfg.CheckConfig())
+	cfg.QueryPairs["orders"].KeyColumns = []string{"id", "id"}
+	require.False(t, cfg.CheckConfig())
+	cfg.QueryPairs["orders"].KeyColumns = []string{"id"}
+	require.False(t, cfg.CheckConfig())
+	cfg.Task.Source = []string{"mysql1", "mysql2"}
+	cfg.Task.SourceInstances = []*DataSource{{Host: "127.0.0.1"}, {Host: "127.0.0.2"}}
+	require.True(t, cfg.CheckConfig())
+	source, err := cfg.QueryPairs["orders"].GetSourceInstance(&cfg.Task)
+	require.NoError(t, err)
+	require.Equal(t, "127.0.0.1", source.Host)
+	cfg.QueryPairs["orders"].SourceInstance = "mysql3"
+	require.False(t, cfg.CheckConfig())
+	cfg.QueryPairs["orders"].SourceInstance = "mysql2"
+	source, err = cfg.QueryPairs["orders"].GetSourceInstance(&cfg.Task)
+	require.NoError(t, err)
+	require.Equal(t, "127.0.0.2", source.Host)
+	cfg.QueryPairs = nil
+	cfg.DebugChunkID = "0:0-0:2"
+	require.False(t, cfg.CheckConfig())
+	cfg.DebugChunkID = "0:0-0:2:10"
+	require.True(t, cfg.CheckConfig())
+	require.True(t, cfg.IsDebugRange())
+	cfg.DebugRange = "id between 1 and 10"
+	cfg.DebugTable = "test.t"
+	require.False(t, cfg.CheckConfig())
+	cfg.DebugChunkID = ""
+	require.True(t, cfg.CheckConfig())
+	cfg.DebugTable = "t"
+	require.False(t, cfg.CheckConfig())
+	cfg.DebugTable = "test.t"
+	cfg.DebugMaxRows = -1
+	require.False(t, cfg.CheckConfig())
+	cfg.DebugMaxRows = 0
+	cfg.DebugRange = ""
+	require.False(t, cfg.IsDebugRange())
 
 	// Init
 	cfg.DataSources = make(map[string]*DataSource)
 	cfg.DataSources["123"] = &DataSource{
 		RouteRules: []string{"111"},
 	}
-	err := cfg.Init()
+	err = cfg.Init()
 	require.Contains(t, err.Error(), "not found source routes for rule 111, please correct the config")
 }
+
+func TestDataSourceConnSettings(t *testing.T) {
+	ds := &DataSource{Host: "127.0.0.1", Port: 4000, User: "root"}
+	require.True(t, ds.Valid())
+	dsn := dbutil.GetDSN(*ds.ToDBConfig(), nil)
+	require.Contains(t, dsn, "&timeout=30s&readTimeout=30m0s&writeTimeout=30m0s")
+	lifetime, err := ds.GetConnMaxLifetime()
+	require.NoError(t, err)
+	require.Equal(t, time.Hour, lifetime)
+
+	ds.DialTimeout = "5s"
+	ds.ReadTimeout = "1m"
+	ds.WriteTimeout = "2m"
+	require.True(t, ds.Valid())
+	dsn = dbutil.GetDSN(*ds.ToDBConfig(), nil)
+	require.Contains(t, dsn, "&timeout=5s&readTimeout=1m0s&writeTimeout=2m0s")
+
+	ds.DialTimeout = "5"
+	require.False(t, ds.Valid())
+	ds.DialTimeout = "5s"
+	ds.ConnMaxLifetime = "0s"
+	require.False(t, ds.Valid())
+	ds.ConnMaxLifetime = ""
+	ds.MaxIdleConns = -1
+	require.False(t, ds.Valid())
+	ds.MaxIdleConns = 0
+
+	cfg := NewConfig()
+	require.NoError(t, cfg.Parse([]string{"--config", "config_sharding.toml"}))
+	require.True(t, cfg.CheckConfig())
+	cfg.DataSources["tidb0"].ReadTimeout = "-1s"
+	require.False(t, cfg.CheckConfig())
+}
+
+func TestYAMLConfig(t *testing.T) {
+	parse := func(args ...string) (*Config, error) {
+		cfg := NewConfig()
+		if err := cfg.Parse(args); err != nil {
+			return nil, err
+		}
+		cfg.FlagSet = nil
+		cfg.ConfigFile = ""
+		cfg.ConfigFormat = ""
+		return cfg, nil
+	}
+	tomlCfg, err := parse("--config", "config_sharding.toml")
+	require.NoError(t, err)
+	yamlCfg, err := parse("--config", "config_sharding.yaml")
+	require.NoError(t, err)
+	require.Equal(t, tomlCfg, yamlCfg)
+
+	// the format is decided by --config-format regardless of the extension.
+	dir := t.TempDir()
+	data, err := os.ReadFile("config_sharding.yaml")
+	require.NoError(t, err)
+	path := filepath.Join(dir, "config.conf")
+	require.NoError(t, os.WriteFile(path, data, 0644))
+	_, err = parse("--config", path)
+	require.Error(t, err)
+	cfg, err := parse("--config", path, "--config-format", "yaml")
+	require.NoError(t, err)
+	require.Equal(t, tomlCfg, cfg)
+	_, err = parse("--config", path, "--config-format", "json")
+	require.Contains(t, err.Error(), "config-format should be")
+
+	// the unknown keys are rejected in both formats.
+	path = filepath.Join(dir, "typo.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("chcek-thread-count: 4\ntask:\n  output-dir: /tmp\n"), 0644))
+	_, err = parse("--config", path)
+	require.Contains(t, err.Error(), "unknown keys in config file")
+	require.Contains(t, err.Error(), "chcek-thread-count")
+	path = filepath.Join(dir, "typo.toml")
+	require.NoError(t, os.WriteFile(path, []byte("chcek-thread-count = 4\n"), 0644))
+	_, err = parse("--config", path)
+	require.Contains(t, err.Error(), "chcek-thread-count")
+
+	// the type mismatch is rejected.
+	path = filepath.Join(dir, "type.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("check-thread-count: four\n"), 0644))
+	_, err = parse("--config", path)
+	require.Contains(t, err.Error(), "failed to decode config file")
+}
+
+func TestOverrides(t *testing.T) {
+	// file < env < flag
+	t.Setenv("SYNC_DIFF__DATA_SOURCES__TIDB0__HOST", "staging-tidb")
+	t.Setenv("SYNC_DIFF__DATA_SOURCES__TIDB0__PASSWORD", "secret")
+	t.Setenv("SYNC_DIFF__CHECK_THREAD_COUNT", "8")
+	t.Setenv("SYNC_DIFF__EXPORT_FIX_SQL", "false")
+	cfg := NewConfig()
+	require.NoError(t, cfg.Parse([]string{"--config", "config_sharding.toml",
+		"--override", "data-sources.tidb0.host=prod-tidb",
+		"--override", "table-configs.config1.chunk-size=100",
+		"--override", "task.output-dir=/tmp/output/override"}))
+	require.Equal(t, "prod-tidb", cfg.DataSources["tidb0"].Host)
+	require.Equal(t, "secret", cfg.DataSources["tidb0"].Password)
+	require.Equal(t, 3306, cfg.DataSources["mysql1"].Port)
+	require.Equal(t, 8, cfg.CheckThreadCount)
+	require.False(t, cfg.ExportFixSQL)
+	require.Equal(t, int64(100), cfg.TableConfigs["config1"].ChunkSize)
+	require.Equal(t, "/tmp/output/override", cfg.Task.OutputDir)
+	require.Equal(t, []string{
+		"check-thread-count=8",
+		"data-sources.tidb0.host=staging-tidb",
+		"data-sources.tidb0.password=******",
+		"export-fix-sql=false",
+		"data-sources.tidb0.host=prod-tidb",
+		"table-configs.config1.chunk-size=100",
+		"task.output-dir=/tmp/output/override",
+	}, cfg.AppliedOverrides)
+
+	// the bad values are rejected with clear errors.
+	err := NewConfig().Parse([]string{"--config", "config_sharding.toml", "--override", "check-thread-count=many"})
+	require.Contains(t, err.Error(), "failed to override by --override check-thread-count: invalid value of check-thread-count: expect an integer, but got \"many\"")
+	t.Setenv("SYNC_DIFF__CHECK_STRUCT_ONLY", "yes")
+	err = NewConfig().Parse([]string{"--config", "config_sharding.toml"})
+	require.Contains(t, err.Error(), "failed to override by the environment variable SYNC_DIFF__CHECK_STRUCT_ONLY")
+	require.Contains(t, err.Error(), "expect a boolean, but got \"yes\"")
+	t.Setenv("SYNC_DIFF__CHECK_STRUCT_ONLY", "true")
+
+	// the unknown keys and the non-scalar values are rejected.
+	err = NewConfig().Parse([]string{"--config", "config_sharding.toml", "--override", "data-sources.tidb1.host=prod-tidb"})
+	require.Contains(t, err.Error(), "invalid config key data-sources.tidb1.host: tidb1 is not found")
+	err = NewConfig().Parse([]string{"--config", "config_sharding.toml", "--override", "task.source-instances=mysql1"})
+	require.Contains(t, err.Error(), "only the scalar values can be overridden")
+	err = NewConfig().Parse([]string{"--config", "config_sharding.toml", "--override", "check-thread-count"})
+	require.Contains(t, err.Error(), "should be like key=value")
+}
+
+func TestTimestampedOutput(t *testing.T) {
+	dir := t.TempDir()
+	cfg := NewConfig()
+	require.NoError(t, cfg.Parse([]string{"--config", "config.toml", "--timestamped-output", "--override", "task.output-dir=" + dir}))
+	require.Equal(t, dir, filepath.Dir(cfg.Task.OutputDir))
+	// the name has no colons, which aren't allowed on some file systems.
+	require.NotContains(t, filepath.Base(cfg.Task.OutputDir), ":")
+	_, err := time.Parse(RunDirTimeLayout, filepath.Base(cfg.Task.OutputDir))
+	require.NoError(t, err)
+
+	// the outputs of each run are nested under its own directory, and latest links to the newest run.
+	for _, now := range []time.Time{
+		time.Date(2021, 10, 1, 1, 0, 0, 0, time.UTC),
+		time.Date(2021, 10, 2, 1, 0, 0, 0, time.UTC),
+	} {
+		cfg.Task.OutputDir = dir
+		require.NoError(t, cfg.Task.resolveTimestampedOutput(now))
+		require.NoError(t, cfg.Init())
+		runDir := filepath.Join(dir, RunDirName(now))
+		require.Equal(t, runDir, cfg.Task.OutputDir)
+		require.Equal(t, filepath.Join(runDir, "checkpoint"), cfg.Task.CheckpointDir)
+		require.Equal(t, filepath.Join(runDir, "fix-on-tidb0"), cfg.Task.FixDir)
+		link, err := os.Readlink(filepath.Join(dir, LatestLinkName))
+		require.NoError(t, err)
+		require.Equal(t, RunDirName(now), link)
+		require.DirExists(t, filepath.Join(dir, LatestLinkName, "fix-on-tidb0"))
+	}
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	// the outputs of the runs started in the same second are not mixed.
+	cfg.Task.OutputDir = dir
+	require.NoError(t, cfg.Task.resolveTimestampedOutput(time.Date(2021, 10, 2, 1, 0, 0, 0, time.UTC)))
+	require.Contains(t, cfg.Init().Error(), "already exists")
+
+	// the interrupted run is resumed in its directory rather than starting over in a new one.
+	runDir := filepath.Join(dir, RunDirName(time.Date(2021, 10, 2, 1, 0, 0, 0, time.UTC)))
+	require.NoError(t, os.WriteFile(filepath.Join(runDir, "checkpoint", "sync_diff_checkpoints.pb"), nil, LocalFilePerm))
+	cfg.Task.OutputDir = dir
+	require.NoError(t, cfg.Task.resolveTimestampedOutput(time.Date(2021, 10, 3, 1, 0, 0, 0, time.UTC)))
+	require.NoError(t, cfg.Init())
+	require.Equal(t, runDir, cfg.Task.OutputDir)
+	require.Equal(t, filepath.Join(runDir, "checkpoint"), cfg.Task.CheckpointDir)
+	require.FileExists(t, filepath.Join(cfg.Task.CheckpointDir, "sync_diff_checkpoints.pb"))
+	entries, err = os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	// the history is kept in the root output-dir rather than the directory of each run.
+	require.Equal(t, filepath.Join(dir, HistoryDirName, "config"), cfg.GetHistoryDir(cfg.GetTaskName()))
+	cfg.HistoryDir = "/data/history"
+	require.Equal(t, "/data/history/nightly", cfg.GetHistoryDir("nightly"))
+	cfg.KeepLast = -1
+	require.False(t, cfg.CheckConfig())
+}
+
+func TestRunDirName(t *testing.T) {
+	// the name is in UTC whatever the time zone of the start time is.
+	startTime := time.Date(2021, 10, 1, 9, 0, 0, 0, time.FixedZone("CST", 8*3600))
+	require.Equal(t, "20211001T010000Z", RunDirName(startTime))
+	parsed, err := ParseRunDirName("20211001T010000Z")
+	require.NoError(t, err)
+	require.True(t, startTime.Equal(parsed))
+	// the RFC3339 names of the runs by the old versions are still parsed.
+	parsed, err = ParseRunDirName("2021-10-01T09:00:00+08:00")
+	require.NoError(t, err)
+	require.True(t, startTime.Equal(parsed))
+	_, err = ParseRunDirName("latest")
+	require.Error(t, err)
+}
+
+func TestOutputDirTemplate(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2021, 10, 1, 1, 0, 0, 0, time.UTC)
+	cfg := NewConfig()
+	require.NoError(t, cfg.Parse([]string{"--config", "config.toml", "--override", "task.output-dir=" + filepath.Join(dir, "{task-name}", "{date}")}))
+	// the task name is the name of the config file by default.
+	require.Equal(t, filepath.Join(dir, "config"), filepath.Dir(cfg.Task.OutputDir))
+	_, err := time.Parse("2006-01-02", filepath.Base(cfg.Task.OutputDir))
+	require.NoError(t, err)
+
+	cfg.TaskName = "nightly"
+	cfg.TimestampedOutput = true
+	cfg.Task.OutputDir = filepath.Join(dir, "{task-name}", "{date}")
+	require.NoError(t, cfg.resolveOutputDir(now))
+	require.Equal(t, filepath.Join(dir, "nightly", "2021-10-01", "20211001T010000Z"), cfg.Task.OutputDir)
+
+	cfg.TimestampedOutput = false
+	cfg.Task.OutputDir = filepath.Join(dir, "{task}")
+	require.Contains(t, cfg.resolveOutputDir(now).Error(), "unknown placeholder {task} in output-dir")
+	cfg.TaskName = "../nightly"
+	require.Contains(t, cfg.resolveOutputDir(now).Error(), "task-name should be a valid directory name")
+	cfg.TaskName = "nightly"
+	for _, perm := range []string{"0855", "0644", "1777", "rwx"} {
+		cfg.OutputDirPerm = perm
+		require.Contains(t, cfg.resolveOutputDir(now).Error(), "output-dir-perm should be an octal permission")
+	}
+
+	// the directories are created with output-dir-perm, and all the outputs are in the expanded directory.
+	cfg.OutputDirPerm = "0750"
+	cfg.Task.OutputDir = filepath.Join(dir, "{task-name}", "{date}")
+	require.NoError(t, cfg.resolveOutputDir(now))
+	require.NoError(t, cfg.Init())
+	runDir := filepath.Join(dir, "nightly", "2021-10-01")
+	for _, d := range []string{filepath.Join(dir, "nightly"), runDir, cfg.Task.CheckpointDir, cfg.Task.FixDir} {
+		info, err := os.Stat(d)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0o750), info.Mode().Perm(), d)
+	}
+	require.Equal(t, filepath.Join(runDir, "checkpoint"), cfg.Task.CheckpointDir)
+	require.Equal(t, filepath.Join(runDir, "fix-on-tidb0"), cfg.Task.FixDir)
+}
+
+func TestConfigHashMismatch(t *testing.T) {
+	dir := t.TempDir()
+	cfg := NewConfig()
+	require.NoError(t, cfg.Parse([]string{"--config", "config.toml", "--override", "task.output-dir=" + dir}))
+	require.NoError(t, cfg.Init())
+	hash, err := cfg.Task.ComputeConfigHash()
+	require.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(dir, ConfigHashFileName))
+	require.NoError(t, err)
+	require.Equal(t, hash+"\n", string(data))
+	require.NoError(t, os.WriteFile(filepath.Join(cfg.Task.CheckpointDir, "sync_diff_checkpoints.pb"), []byte("{}"), LocalFilePerm))
+
+	// the same config resumes from the checkpoint.
+	cfg = NewConfig()
+	require.NoError(t, cfg.Parse([]string{"--config", "config.toml", "--override", "task.output-dir=" + dir}))
+	require.NoError(t, cfg.Init())
+	require.FileExists(t, filepath.Join(cfg.Task.CheckpointDir, "sync_diff_checkpoints.pb"))
+
+	// the checkpoint saved with a different config is refused.
+	cfg = NewConfig()
+	require.NoError(t, cfg.Parse([]string{"--config", "config_sharding.toml", "--override", "task.output-dir=" + dir}))
+	require.Contains(t, cfg.Init().Error(), "config changes breaking the checkpoint")
+	require.FileExists(t, filepath.Join(dir, "checkpoint", "sync_diff_checkpoints.pb"))
+
+	// --force only applies the fix sql files, which keeps the checkpoint.
+	cfg = NewConfig()
+	require.NoError(t, cfg.Parse([]string{"--config", "config_sharding.toml", "--override", "task.output-dir=" + dir, "--force"}))
+	require.Contains(t, cfg.Init().Error(), "use --discard-checkpoint")
+	require.FileExists(t, filepath.Join(dir, "checkpoint", "sync_diff_checkpoints.pb"))
+
+	// the checkpoint is discarded with --discard-checkpoint, and the hash of the new config is written.
+	cfg = NewConfig()
+	require.NoError(t, cfg.Parse([]string{"--config", "config_sharding.toml", "--override", "task.output-dir=" + dir, "--discard-checkpoint"}))
+	require.NoError(t, cfg.Init())
+	require.NoFileExists(t, filepath.Join(cfg.Task.CheckpointDir, "sync_diff_checkpoints.pb"))
+	require.DirExists(t, cfg.Task.CheckpointDir)
+	newHash, err := cfg.Task.ComputeConfigHash()
+	require.NoError(t, err)
+	require.NotEqual(t, hash, newHash)
+	data, err = os.ReadFile(filepath.Join(dir, ConfigHashFileName))
+	require.NoError(t, err)
+	require.Equal(t, newHash+"\n", string(data))
+
+	// the hash in the checkpoint saved by the old versions is still checked.
+	require.NoError(t, os.Remove(filepath.Join(dir, ConfigHashFileName)))
+	require.NoError(t, os.WriteFile(filepath.Join(cfg.Task.CheckpointDir, hash), nil, LocalFilePerm))
+	cfg = NewConfig()
+	require.NoError(t, cfg.Parse([]string{"--config", "config.toml", "--override", "task.output-dir=" + dir}))
+	require.NoError(t, cfg.Init())
+}
+
+func TestRedactKeys(t *testing.T) {
+	cfg := NewConfig()
+	require.True(t, cfg.IsRedactedKey("data-sources.target.password"))
+	require.True(t, cfg.IsRedactedKey("status-token"))
+	require.False(t, cfg.IsRedactedKey("data-sources.target.user"))
+	require.Equal(t, "", cfg.RedactValue("password", ""))
+
+	// the keys are extended by redact-keys.
+	cfg.RedactKeys = []string{"user", "sql_mode"}
+	require.True(t, cfg.IsRedactedKey("data-sources.target.user"))
+	require.True(t, cfg.IsRedactedKey("data-sources.target.sql-mode"))
+	require.False(t, cfg.IsRedactedKey("data-sources.target.user-name"))
+	require.Equal(t, redactedValue, cfg.RedactValue("user", "root"))
+
+	// the string values are masked in the JSON, and the fields are kept in order.
+	require.Equal(t, `{"user":"******","port":3306,"password":"******","name":"a \"user\":\"root\"","sql-mode":""}`,
+		string(cfg.redactJSON([]byte(`{"user":"root","port":3306,"password":"p@ss\"word","name":"a \"user\":\"root\"","sql-mode":""}`))))
+
+}
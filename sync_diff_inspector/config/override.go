@@ -0,0 +1,157 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/errors"
+)
+
+// OverrideEnvPrefix is the prefix of the environment variables overriding the config values,
+// e.g. `SYNC_DIFF__DATA_SOURCES__TARGET__HOST` overrides `data-sources.target.host`.
+const OverrideEnvPrefix = "SYNC_DIFF__"
+
+const redactedValue = "******"
+
+// applyOverrides overrides the config values by the environment variables and then the `--override` flags,
+// so the precedence is file < env < flag. The applied overrides are recorded with the values of the
+// redacted keys masked, see `IsRedactedKey`.
+func (c *Config) applyOverrides(environ []string, overrides []string) error {
+	envs := make([]string, 0)
+	for _, env := range environ {
+		if strings.HasPrefix(env, OverrideEnvPrefix) {
+			envs = append(envs, env)
+		}
+	}
+	sort.Strings(envs)
+	for _, env := range envs {
+		kv := strings.SplitN(env, "=", 2)
+		name, value := kv[0], kv[1]
+		segments := strings.Split(strings.TrimPrefix(name, OverrideEnvPrefix), "__")
+		for i, segment := range segments {
+			segments[i] = strings.ReplaceAll(strings.ToLower(segment), "_", "-")
+		}
+		key := strings.Join(segments, ".")
+		if err := c.override(key, value); err != nil {
+			return errors.Annotatef(err, "failed to override by the environment variable %s", name)
+		}
+	}
+	for _, override := range overrides {
+		kv := strings.SplitN(override, "=", 2)
+		if len(kv) != 2 {
+			return errors.Errorf("invalid override %s, should be like key=value", override)
+		}
+		key, value := kv[0], kv[1]
+		if err := c.override(strings.TrimSpace(key), value); err != nil {
+			return errors.Annotatef(err, "failed to override by --override %s", key)
+		}
+	}
+	return nil
+}
+
+// override sets the scalar config value of the key, which is the toml keys joined by `.`, e.g. `task.output-dir`.
+func (c *Config) override(key, value string) error {
+	field, err := lookupConfigField(reflect.ValueOf(c).Elem(), strings.Split(key, "."))
+	if err != nil {
+		return errors.Annotatef(err, "invalid config key %s", key)
+	}
+	if err = setScalar(field, value); err != nil {
+		return errors.Annotatef(err, "invalid value of %s", key)
+	}
+	c.AppliedOverrides = append(c.AppliedOverrides, fmt.Sprintf("%s=%s", key, c.RedactValue(key, value)))
+	return nil
+}
+
+// lookupConfigField returns the field of the path, the structs are matched by the toml tags
+// and the maps are matched by the existing keys, `-` and `_` in the names are treated as the same.
+func lookupConfigField(v reflect.Value, path []string) (reflect.Value, error) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return reflect.Value{}, errors.Errorf("%s is not set", path[0])
+		}
+		v = v.Elem()
+	}
+	if len(path) == 0 {
+		return v, nil
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			name := strings.Split(v.Type().Field(i).Tag.Get("toml"), ",")[0]
+			if name == "" || name == "-" || !sameConfigName(name, path[0]) {
+				continue
+			}
+			return lookupConfigField(v.Field(i), path[1:])
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			break
+		}
+		for _, mapKey := range v.MapKeys() {
+			if !sameConfigName(mapKey.String(), path[0]) {
+				continue
+			}
+			elem := v.MapIndex(mapKey)
+			if elem.Kind() != reflect.Ptr {
+				return reflect.Value{}, errors.Errorf("%s can't be overridden", path[0])
+			}
+			return lookupConfigField(elem, path[1:])
+		}
+	}
+	return reflect.Value{}, errors.Errorf("%s is not found", path[0])
+}
+
+func sameConfigName(name, key string) bool {
+	return strings.EqualFold(strings.ReplaceAll(name, "_", "-"), strings.ReplaceAll(key, "_", "-"))
+}
+
+// setScalar sets the string, bool, integer or float field by parsing the value.
+func setScalar(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.Errorf("expect a boolean, but got %q", value)
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return errors.Errorf("expect an integer, but got %q", value)
+		}
+		field.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return errors.Errorf("expect an unsigned integer, but got %q", value)
+		}
+		field.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return errors.Errorf("expect a float, but got %q", value)
+		}
+		field.SetFloat(f)
+	default:
+		return errors.Errorf("only the scalar values can be overridden")
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"regexp"
+	"strings"
+)
+
+// defaultRedactKeys are the config keys whose values are always redacted in the outputs, which are extended by
+// redact-keys.
+var defaultRedactKeys = []string{"password", "status-token"}
+
+// jsonStringFieldRegexp matches the string fields of the JSON objects, e.g. `"password":"secret"`, the quotes in the
+// keys and the values are escaped.
+var jsonStringFieldRegexp = regexp.MustCompile(`"((?:[^"\\]|\\.)*)":"((?:[^"\\]|\\.)*)"`)
+
+// IsRedactedKey returns whether the value of the config key is redacted in the summary, the report and the log. The
+// key is the toml keys joined by `.` like `data-sources.target.password`, whose last one is matched against the
+// default keys and redact-keys.
+func (c *Config) IsRedactedKey(key string) bool {
+	name := key[strings.LastIndex(key, ".")+1:]
+	for _, keys := range [][]string{defaultRedactKeys, c.RedactKeys} {
+		for _, redactKey := range keys {
+			if sameConfigName(strings.TrimSpace(redactKey), name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RedactValue returns the value of the config key, which is masked if the key is redacted, see `IsRedactedKey`.
+// The empty value is returned as is, which shows the value isn't set.
+func (c *Config) RedactValue(key, value string) string {
+	if len(value) == 0 || !c.IsRedactedKey(key) {
+		return value
+	}
+	return redactedValue
+}
+
+// redactJSON masks the string values of the redacted keys in the JSON objects, see `IsRedactedKey`. The fields are
+// kept in order.
+func (c *Config) redactJSON(data []byte) []byte {
+	return jsonStringFieldRegexp.ReplaceAllFunc(data, func(field []byte) []byte {
+		match := jsonStringFieldRegexp.FindSubmatch(field)
+		if len(match[2]) == 0 || !c.IsRedactedKey(string(match[1])) {
+			return field
+		}
+		return []byte(`"` + string(match[1]) + `":"` + redactedValue + `"`)
+	})
+}
@@ -0,0 +1,121 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/ghodss/yaml"
+	"github.com/pingcap/errors"
+)
+
+const (
+	// ConfigFormatTOML is the format of the toml config file.
+	ConfigFormatTOML = "toml"
+	// ConfigFormatYAML is the format of the yaml config file.
+	ConfigFormatYAML = "yaml"
+)
+
+// configFormat returns the format of the config file, decided by `format` if set, otherwise by the extension.
+func configFormat(path, format string) (string, error) {
+	switch strings.ToLower(format) {
+	case ConfigFormatTOML:
+		return ConfigFormatTOML, nil
+	case ConfigFormatYAML, "yml":
+		return ConfigFormatYAML, nil
+	case "":
+	default:
+		return "", errors.Errorf("config-format should be \"toml\" or \"yaml\", but got %s", format)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return ConfigFormatYAML, nil
+	default:
+		return ConfigFormatTOML, nil
+	}
+}
+
+// decodeYAML decodes the yaml config into `v`. The yaml is converted into toml first,
+// so that it shares the same keys and the strict decoding with the toml config.
+func decodeYAML(data []byte, v interface{}) (toml.MetaData, error) {
+	jsonData, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return toml.MetaData{}, errors.Trace(err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(jsonData))
+	decoder.UseNumber()
+	var value interface{}
+	if err = decoder.Decode(&value); err != nil {
+		return toml.MetaData{}, errors.Trace(err)
+	}
+	value, err = normalizeYAMLValue(value)
+	if err != nil {
+		return toml.MetaData{}, errors.Trace(err)
+	}
+	if value == nil {
+		// an empty file.
+		value = map[string]interface{}{}
+	}
+	if _, ok := value.(map[string]interface{}); !ok {
+		return toml.MetaData{}, errors.Errorf("the yaml config should be a mapping")
+	}
+
+	buf := new(bytes.Buffer)
+	if err = toml.NewEncoder(buf).Encode(value); err != nil {
+		return toml.MetaData{}, errors.Annotate(err, "unsupported value in the yaml config")
+	}
+	meta, err := toml.Decode(buf.String(), v)
+	return meta, errors.Trace(err)
+}
+
+// normalizeYAMLValue converts the numbers into integers or floats and removes the null values,
+// which are treated as the keys not set.
+func normalizeYAMLValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		f, err := v.Float64()
+		return f, errors.Trace(err)
+	case map[string]interface{}:
+		for key, item := range v {
+			if item == nil {
+				delete(v, key)
+				continue
+			}
+			normalized, err := normalizeYAMLValue(item)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			v[key] = normalized
+		}
+		return v, nil
+	case []interface{}:
+		for i, item := range v {
+			normalized, err := normalizeYAMLValue(item)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			v[i] = normalized
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/report"
+)
+
+// confirmConfig prints the config of the comparison, and returns true if it's confirmed by `--yes` or by the answer
+// read from prompt. The prompt is nil if the standard input is not a terminal, then `--yes` is required.
+func confirmConfig(ctx context.Context, w io.Writer, r *report.Report, totalSize int64, yes bool, prompt io.Reader) bool {
+	r.PrintConfig(w, totalSize)
+	if yes {
+		return true
+	}
+	if prompt == nil {
+		fmt.Fprintln(w, "The standard input is not a terminal, please confirm the config by --yes")
+		return false
+	}
+	fmt.Fprint(w, "Start the comparison? [y/N]: ")
+	answerCh := make(chan string, 1)
+	go func() {
+		answer, _ := bufio.NewReader(prompt).ReadString('\n')
+		answerCh <- answer
+	}()
+	select {
+	case <-ctx.Done():
+		// the signal to exit is received while waiting for the answer.
+		fmt.Fprintln(w)
+		return false
+	case answer := <-answerCh:
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		return answer == "y" || answer == "yes"
+	}
+}
+
+// stdinPrompt returns the standard input to read the answer of the confirmation, nil if it's not a terminal.
+func stdinPrompt() io.Reader {
+	info, err := os.Stdin.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return nil
+	}
+	return os.Stdin
+}
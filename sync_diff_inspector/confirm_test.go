@@ -0,0 +1,64 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/report"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfirmConfig(t *testing.T) {
+	r := report.NewReport(&config.TaskConfig{OutputDir: "/tmp/output", FixDir: "/tmp/output/fix-on-tidb", CheckpointDir: "/tmp/output/checkpoint"})
+	r.Init([]*common.TableDiff{{Schema: "test", Table: "t1"}, {Schema: "test", Table: "t2"}},
+		[][]byte{[]byte("host = \"127.0.0.1\"\nport = 3306\nuser = \"root\"\nsnapshot = \"2021-07-01 00:00:00\"\n")},
+		[]byte("host = \"127.0.0.1\"\nport = 4000\nuser = \"root\"\n"))
+	r.SetConfigOverrides([]string{"data-sources.target.password=******"})
+
+	buf := new(bytes.Buffer)
+	require.True(t, confirmConfig(context.Background(), buf, r, 3*1024*1024, true, nil))
+	output := buf.String()
+	require.Contains(t, output, "Source Database\n\nhost = \"127.0.0.1\"\nport = 3306\nuser = \"root\"\nsnapshot = \"2021-07-01 00:00:00\"\n")
+	require.Contains(t, output, "Target Database\n\nhost = \"127.0.0.1\"\nport = 4000\n")
+	require.Contains(t, output, "data-sources.target.password=******\n")
+	require.Contains(t, output, "A total of 2 tables will be compared, the estimated total size is 3MB\n")
+	require.Contains(t, output, "The output dir is '/tmp/output'\n")
+	require.Contains(t, output, "The fix sql is written to '/tmp/output/fix-on-tidb', and the checkpoint is saved in '/tmp/output/checkpoint'\n")
+	require.NotContains(t, output, "[y/N]")
+
+	// --yes is required if the standard input is not a terminal.
+	buf.Reset()
+	require.False(t, confirmConfig(context.Background(), buf, r, 0, false, nil))
+	require.Contains(t, buf.String(), "please confirm the config by --yes")
+
+	for answer, confirmed := range map[string]bool{"y\n": true, "YES\n": true, "n\n": false, "\n": false, "": false} {
+		buf.Reset()
+		require.Equal(t, confirmed, confirmConfig(context.Background(), buf, r, 0, false, strings.NewReader(answer)), answer)
+		require.Contains(t, buf.String(), "Start the comparison? [y/N]: ")
+	}
+
+	// the prompt is canceled by the signal to exit.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	require.False(t, confirmConfig(ctx, io.Discard, r, 0, false, pr))
+}
@@ -0,0 +1,83 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/diff"
+	"go.uber.org/zap"
+)
+
+// compareDebugRange compares only the chunk of `--chunk-id` or the rows in `--range` of a table, and prints the
+// result with the different rows. It returns false if the rows are different or they can't be compared.
+func compareDebugRange(ctx context.Context, cfg *config.Config, w io.Writer) bool {
+	d, err := diff.New(cfg)
+	if err != nil {
+		fmt.Fprintf(w, "There is something error when initialize diff, please check log info in %s\n", filepath.Join(cfg.Task.OutputDir, config.LogFileName))
+		log.Error("failed to initialize diff process", zap.Error(err))
+		return false
+	}
+	result, err := d.CompareDebugRange(ctx)
+	if err != nil {
+		fmt.Fprintf(w, "Fail to compare the range.\n%s\n", err.Error())
+		log.Error("failed to compare the range", zap.Error(err))
+		return false
+	}
+	printDebugResult(w, result, cfg.DebugMaxRows)
+	return result.Equal
+}
+
+// printDebugResult prints the result of comparing the range, the different rows are printed in a table with the
+// names of the columns, whose first column is the side the row is on.
+func printDebugResult(w io.Writer, result *diff.DebugResult, maxRows int) {
+	fmt.Fprintf(w, "Table: %s\n", dbutil.TableName(result.Schema, result.Table))
+	fmt.Fprintf(w, "Where: %s\n", result.Where)
+	if len(result.Args) > 0 {
+		fmt.Fprintf(w, "Args: %v\n", result.Args)
+	}
+	checksum := "equal"
+	if !result.ChecksumEqual {
+		checksum = "different"
+	}
+	fmt.Fprintf(w, "Checksum: %s\n", checksum)
+	if result.Equal {
+		fmt.Fprintf(w, "The rows are equal\n")
+		return
+	}
+	fmt.Fprintf(w, "The rows are different, +%d/-%d rows to fix\n", result.RowsAdd, result.RowsDelete)
+	if len(result.Rows) > 0 {
+		tableString := &strings.Builder{}
+		table := tablewriter.NewWriter(tableString)
+		table.SetAutoFormatHeaders(false)
+		table.SetHeader(append([]string{"side"}, result.Columns...))
+		table.AppendBulk(result.Rows)
+		table.Render()
+		fmt.Fprint(w, tableString.String())
+		if maxRows > 0 && len(result.Rows) >= maxRows {
+			fmt.Fprintf(w, "Only the first %d different rows are printed, see --max-print-rows\n", maxRows)
+		}
+	}
+	if len(result.FixSQLFile) > 0 {
+		fmt.Fprintf(w, "The fix sql is written to %s\n", result.FixSQLFile)
+	}
+}
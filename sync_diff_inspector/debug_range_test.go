@@ -0,0 +1,58 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/diff"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintDebugResult(t *testing.T) {
+	buf := &bytes.Buffer{}
+	printDebugResult(buf, &diff.DebugResult{
+		Schema:        "test",
+		Table:         "t",
+		Where:         "((`id` > ?)) AND ((`id` <= ?))",
+		Args:          []interface{}{"1", "10"},
+		ChecksumEqual: true,
+		Equal:         true,
+	}, 100)
+	require.Equal(t, "Table: `test`.`t`\n"+
+		"Where: ((`id` > ?)) AND ((`id` <= ?))\n"+
+		"Args: [1 10]\n"+
+		"Checksum: equal\n"+
+		"The rows are equal\n", buf.String())
+
+	buf.Reset()
+	printDebugResult(buf, &diff.DebugResult{
+		Schema:     "test",
+		Table:      "t",
+		Where:      "TRUE",
+		RowsAdd:    1,
+		RowsDelete: 1,
+		Columns:    []string{"id", "name"},
+		Rows:       [][]string{{"source", "2", "a"}, {"target", "3", "NULL"}},
+		FixSQLFile: "/tmp/fix.sql",
+	}, 2)
+	out := buf.String()
+	require.Contains(t, out, "Checksum: different\n")
+	require.Contains(t, out, "The rows are different, +1/-1 rows to fix\n")
+	require.Contains(t, out, "|  side  | id | name |")
+	require.Contains(t, out, "| target |  3 | NULL |")
+	require.Contains(t, out, "Only the first 2 different rows are printed")
+	require.Contains(t, out, "The fix sql is written to /tmp/fix.sql\n")
+}
@@ -0,0 +1,147 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/report"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/utils"
+	"go.uber.org/zap"
+)
+
+// useAdminChecksum decides whether to verify the tables by `ADMIN CHECKSUM TABLE` by admin-checksum, "auto" means
+// only if the sources and the target are TiDB of the compatible versions, see `utils.IsAdminChecksumCompatible`.
+func useAdminChecksum(mode string, sourceVersions []*report.ServerVersion, targetVersion *report.ServerVersion) bool {
+	switch mode {
+	case config.AdminChecksumOn:
+		return true
+	case config.AdminChecksumOff:
+		return false
+	}
+	// the versions are nil if they can't be fetched.
+	if targetVersion == nil || len(sourceVersions) != 1 || sourceVersions[0] == nil {
+		return false
+	}
+	return utils.IsAdminChecksumCompatible([]string{sourceVersions[0].Version}, targetVersion.Version)
+}
+
+// adminChecksumFallbackReason returns why the data of the table can't be verified by `ADMIN CHECKSUM TABLE`,
+// which covers all the key-value pairs of the whole table, empty if it can.
+func adminChecksumFallbackReason(table *common.TableDiff) string {
+	switch {
+	case table.HasRange():
+		return "the range is set"
+	case len(table.IgnoreColumns) > 0:
+		return "some columns are ignored"
+	case len(table.ColumnTransforms) > 0:
+		return "some columns are transformed"
+	case len(table.TrimmedColumns) > 0:
+		return "the trailing spaces of some columns are ignored"
+	case len(table.ReorderedEnumColumns) > 0:
+		// the stored indices of the same values are different.
+		return "the members of some ENUM or SET columns are in different orders"
+	case len(table.TranscodedColumns) > 0:
+		return "the source values of some columns are transcoded by charset-map"
+	}
+	return ""
+}
+
+// compareAdminChecksum verifies the tables by `ADMIN CHECKSUM TABLE` on both sides at the snapshots, which is the
+// first pass of the data check if `useAdminChecksum`. The tables whose checksums are equal are marked
+// `IgnoreDataCheck` with no chunk, and the others are compared by chunks then to find the different rows.
+func (df *Diff) compareAdminChecksum(ctx context.Context) {
+	tables := df.downstream.GetTables()
+	tableIndex := 0
+	if df.startRange != nil {
+		// the table of the checkpoint has been verified by the checksum before it's compared by chunks.
+		tableIndex = df.startRange.ChunkRange.Index.TableIndex + 1
+	}
+	pool := utils.NewWorkerPool(uint(df.checkThreadCount), "admin checksum")
+	for ; tableIndex < len(tables) && !df.report.IsFailedFast(); tableIndex++ {
+		if tables[tableIndex].IgnoreDataCheck {
+			continue
+		}
+		i := tableIndex
+		pool.Apply(func() {
+			df.compareTableAdminChecksum(ctx, i)
+		})
+	}
+	pool.WaitFinished()
+}
+
+// compareTableAdminChecksum compares the results of `ADMIN CHECKSUM TABLE` of the table. The checksums are only
+// comparable if the tables have the same columns, and the different orders of the columns don't matter because the
+// values are encoded by the column ids. Any failure falls back to the comparison by chunks instead of an error.
+func (df *Diff) compareTableAdminChecksum(ctx context.Context, tableIndex int) {
+	table := df.downstream.GetTables()[tableIndex]
+	tableName := dbutil.TableName(table.Schema, table.Table)
+	fallback := func(reason string, fields ...zap.Field) {
+		log.Info("fall back to compare the table by chunks", append([]zap.Field{zap.String("table", tableName), zap.String("reason", reason)}, fields...)...)
+		df.report.SetTableCheckPath(table.Schema, table.Table, report.CheckPathChunks)
+	}
+	if reason := adminChecksumFallbackReason(table); len(reason) > 0 {
+		fallback(reason)
+		return
+	}
+	upstreamInfos, err := df.upstream.GetSourceStructInfo(ctx, tableIndex)
+	if err != nil {
+		fallback("fail to get the source table structure", zap.Error(err))
+		return
+	}
+	downstreamInfos, err := df.downstream.GetSourceStructInfo(ctx, tableIndex)
+	if err != nil {
+		fallback("fail to get the target table structure", zap.Error(err))
+		return
+	}
+	if !utils.HasSameColumnSet(upstreamInfos, downstreamInfos[0]) {
+		fallback("the columns are different")
+		return
+	}
+
+	var wg sync.WaitGroup
+	var upstreamChecksum, downstreamChecksum *utils.TableChecksum
+	var upstreamErr, downstreamErr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		upstreamChecksum, upstreamErr = df.upstream.GetAdminChecksum(ctx, tableIndex)
+	}()
+	downstreamChecksum, downstreamErr = df.downstream.GetAdminChecksum(ctx, tableIndex)
+	wg.Wait()
+	if ctx.Err() != nil {
+		return
+	}
+	if upstreamErr != nil {
+		fallback("fail to get the source checksum", zap.Error(upstreamErr))
+		return
+	}
+	if downstreamErr != nil {
+		fallback("fail to get the target checksum", zap.Error(downstreamErr))
+		return
+	}
+	if *upstreamChecksum != *downstreamChecksum {
+		fallback("the checksums are different", zap.Reflect("source checksum", upstreamChecksum), zap.Reflect("target checksum", downstreamChecksum))
+		return
+	}
+	log.Info("admin checksum compared", zap.String("table", tableName), zap.Reflect("checksum", downstreamChecksum))
+	df.report.SetTableCheckPath(table.Schema, table.Table, report.CheckPathAdminChecksum)
+	df.report.AddTableVerificationMethod(table.Schema, table.Table, report.VerificationChecksumOnly)
+	table.IgnoreDataCheck = true
+}
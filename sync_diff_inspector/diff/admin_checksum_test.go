@@ -0,0 +1,100 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/report"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/utils"
+	"github.com/pingcap/tidb/parser"
+	"github.com/pingcap/tidb/parser/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUseAdminChecksum(t *testing.T) {
+	tidb := &report.ServerVersion{Version: "5.7.25-TiDB-v6.5.0"}
+	mysql := &report.ServerVersion{Version: "8.0.11"}
+	require.True(t, useAdminChecksum(config.AdminChecksumAuto, []*report.ServerVersion{tidb}, tidb))
+	require.False(t, useAdminChecksum(config.AdminChecksumAuto, []*report.ServerVersion{mysql}, tidb))
+	// the versions can't be fetched.
+	require.False(t, useAdminChecksum(config.AdminChecksumAuto, []*report.ServerVersion{nil}, tidb))
+	require.False(t, useAdminChecksum(config.AdminChecksumAuto, []*report.ServerVersion{tidb, tidb}, tidb))
+	require.True(t, useAdminChecksum(config.AdminChecksumOn, []*report.ServerVersion{mysql}, nil))
+	require.False(t, useAdminChecksum(config.AdminChecksumOff, []*report.ServerVersion{tidb}, tidb))
+}
+
+func TestCompareAdminChecksum(t *testing.T) {
+	getTableInfo := func(createTableSQL string) *model.TableInfo {
+		tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+		require.NoError(t, err)
+		return tableInfo
+	}
+	// the columns in different orders don't matter.
+	upstreamInfo := getTableInfo("create table `test`.`t`(`b` int, `a` int, primary key(`a`))")
+	downstreamInfo := getTableInfo("create table `test`.`t`(`a` int, `b` int, primary key(`a`))")
+	tables := []*common.TableDiff{
+		{Schema: "test", Table: "t1"},
+		// the checksums are different.
+		{Schema: "test", Table: "t2"},
+		// the range is set.
+		{Schema: "test", Table: "t3", Range: "`a` > 1"},
+		// skipped by the struct check
+		{Schema: "test", Table: "t4", IgnoreDataCheck: true},
+	}
+	checksums := map[int]*utils.TableChecksum{
+		0: {Checksum: 1, TotalKVs: 10, TotalBytes: 100},
+		1: {Checksum: 2, TotalKVs: 10, TotalBytes: 100},
+		2: {Checksum: 1, TotalKVs: 10, TotalBytes: 100},
+	}
+	upstream := &mockSource{tables: tables, structInfos: []*model.TableInfo{upstreamInfo}, adminChecksums: map[int]*utils.TableChecksum{
+		0: {Checksum: 1, TotalKVs: 10, TotalBytes: 100},
+		1: {Checksum: 3, TotalKVs: 10, TotalBytes: 100},
+		2: {Checksum: 1, TotalKVs: 10, TotalBytes: 100},
+	}}
+	downstream := &mockSource{tables: tables, structInfos: []*model.TableInfo{downstreamInfo}, adminChecksums: checksums}
+	df := newTestDiff(t, upstream, downstream, func(df *Diff) { df.checkThreadCount = 2 })
+	df.compareAdminChecksum(context.Background())
+
+	for i, ignored := range []bool{true, false, false, true} {
+		require.Equal(t, ignored, tables[i].IgnoreDataCheck, i)
+	}
+	result := df.report.TableResults["test"]["t1"]
+	require.True(t, result.DataEqual)
+	require.Empty(t, result.ChunkMap)
+	require.Equal(t, report.CheckPathAdminChecksum, result.CheckPath)
+	require.Equal(t, report.CheckPathChunks, df.report.TableResults["test"]["t2"].CheckPath)
+	require.Equal(t, report.CheckPathChunks, df.report.TableResults["test"]["t3"].CheckPath)
+	require.Empty(t, df.report.TableResults["test"]["t4"].CheckPath)
+
+	// the tables with the different columns and the failures fall back to the chunks without errors.
+	for _, upstream := range []*mockSource{
+		{structInfos: []*model.TableInfo{getTableInfo("create table `test`.`t`(`a` int, `c` int, primary key(`a`))")}, adminChecksums: checksums},
+		{structInfos: []*model.TableInfo{upstreamInfo}, adminChecksumErr: errors.New("ADMIN CHECKSUM TABLE is not supported")},
+	} {
+		tables := []*common.TableDiff{{Schema: "test", Table: "t1"}}
+		upstream.tables = tables
+		df := newTestDiff(t, upstream, &mockSource{tables: tables, structInfos: []*model.TableInfo{downstreamInfo}, adminChecksums: checksums})
+		df.compareAdminChecksum(context.Background())
+		require.False(t, tables[0].IgnoreDataCheck)
+		result := df.report.TableResults["test"]["t1"]
+		require.Equal(t, report.CheckPathChunks, result.CheckPath)
+		require.Nil(t, result.MeetError)
+	}
+}
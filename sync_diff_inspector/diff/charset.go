@@ -0,0 +1,80 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/report"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/utils"
+	"github.com/pingcap/tidb/parser/model"
+	"go.uber.org/zap"
+)
+
+// setTranscodedColumns finds the columns of table whose source values are transcoded to the charsets of the target
+// by charset-map, which are compared in utf8mb4 on both sides.
+func (df *Diff) setTranscodedColumns(table *common.TableDiff, sourceTableInfos []*model.TableInfo) {
+	columns := utils.GetTranscodedColumns(sourceTableInfos, table.Info, df.charsetMap)
+	if len(columns) == 0 {
+		return
+	}
+	table.TranscodedColumns = make(map[string]string, len(columns))
+	results := make([]*report.TranscodedColumn, 0, len(columns))
+	for _, column := range columns {
+		table.TranscodedColumns[column.Column] = column.To
+		results = append(results, &report.TranscodedColumn{Column: column.Column, From: column.From, To: column.To})
+		log.Info("the source values of the column are transcoded by charset-map", zap.String("table", dbutil.TableName(table.Schema, table.Table)),
+			zap.String("column", column.Column), zap.String("from", column.From), zap.String("to", column.To))
+	}
+	df.report.SetTableTranscodedColumns(table.Schema, table.Table, results)
+}
+
+// transcodedRowsIterator transcodes the values of the columns of the source rows to the charsets of the target, and
+// counts the values with the un-mappable characters in the report.
+type transcodedRowsIterator struct {
+	source.RowDataIterator
+	table  *common.TableDiff
+	report *report.Report
+}
+
+// transcodeRows returns the iterator of the source rows of table transcoded by charset-map, which is iter itself if
+// no column of table is transcoded.
+func (df *Diff) transcodeRows(iter source.RowDataIterator, table *common.TableDiff) source.RowDataIterator {
+	if len(table.TranscodedColumns) == 0 {
+		return iter
+	}
+	return &transcodedRowsIterator{RowDataIterator: iter, table: table, report: df.report}
+}
+
+// Next implements source.RowDataIterator.
+func (it *transcodedRowsIterator) Next() (map[string]*dbutil.ColumnData, error) {
+	data, err := it.RowDataIterator.Next()
+	if err != nil || data == nil {
+		return data, err
+	}
+	for column, charset := range it.table.TranscodedColumns {
+		value, ok := data[column]
+		if !ok || value.IsNull {
+			continue
+		}
+		transcoded, unmappable := utils.TranscodeValue(value.Data, charset)
+		if len(unmappable) > 0 {
+			value.Data = transcoded
+			it.report.AddTableUnmappableChars(it.table.Schema, it.table.Table, column, unmappable)
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,59 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/chunk"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/report"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/splitter"
+	"github.com/pingcap/tidb/parser"
+	"github.com/pingcap/tidb/parser/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranscodeRows(t *testing.T) {
+	getTableInfo := func(createTableSQL string) *model.TableInfo {
+		tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+		require.NoError(t, err)
+		return tableInfo
+	}
+	upstreamInfo := getTableInfo("create table `test`.`t`(`a` int, `b` varchar(10)) charset utf8mb4")
+	downstreamInfo := getTableInfo("create table `test`.`t`(`a` int, `b` varchar(10)) charset latin1")
+	tables := []*common.TableDiff{{Schema: "test", Table: "t", Info: downstreamInfo, NoPKFallback: true}}
+	upstream := &mockRowsSource{mockSource: mockSource{tables: tables}, rows: [][]string{{"1", "a€😀"}, {"2", "b"}}}
+	downstream := &mockRowsSource{mockSource: mockSource{tables: tables}, rows: [][]string{{"1", "a€?"}, {"2", "b"}}}
+	df := newTestDiff(t, upstream, downstream, func(df *Diff) {
+		df.charsetMap = map[string]string{"utf8mb4": "latin1"}
+		df.noIndexTableMaxRows = 10
+	})
+	df.setTranscodedColumns(tables[0], []*model.TableInfo{upstreamInfo})
+	require.Equal(t, map[string]string{"b": "latin1"}, tables[0].TranscodedColumns)
+	require.Equal(t, "the source values of some columns are transcoded by charset-map", adminChecksumFallbackReason(tables[0]))
+
+	c := chunk.NewChunkRange()
+	c.Index = &chunk.ChunkID{TableIndex: 0, ChunkCnt: 1}
+	rangeInfo := &splitter.RangeInfo{ChunkRange: c}
+	// the emoji un-mappable to latin1 is replaced by "?" on the source.
+	dml := &ChunkDML{}
+	isEqual, err := df.compareRowsAsMultisets(context.Background(), rangeInfo, dml, newChunkLogger(tables[0], rangeInfo))
+	require.NoError(t, err)
+	require.True(t, isEqual)
+	require.Equal(t, []*report.TranscodedColumn{{Column: "b", From: "utf8mb4", To: "latin1", UnmappableValues: 1, UnmappableChars: []string{"😀"}}},
+		df.report.TableResults["test"]["t"].TranscodedColumns)
+}
@@ -0,0 +1,55 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/splitter"
+	"go.uber.org/zap"
+)
+
+// compareIndices compares the checksums of the indices of check-index in the chunk on both sides, which are read
+// from the indices, and the inconsistent ones are added to the report. It's only called if the data of the chunk
+// is equal, otherwise the checksums of the indices are different with the data.
+func (df *Diff) compareIndices(ctx context.Context, rangeInfo *splitter.RangeInfo, logger *chunkLogger) error {
+	tableDiff := df.workSource.GetTables()[rangeInfo.GetTableIndex()]
+	for _, index := range tableDiff.CheckIndices {
+		var wg sync.WaitGroup
+		var upstreamInfo, downstreamInfo *source.ChecksumInfo
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			upstreamInfo = df.upstream.GetIndexCountAndCrc32(ctx, rangeInfo, index)
+		}()
+		downstreamInfo = df.downstream.GetIndexCountAndCrc32(ctx, rangeInfo, index)
+		wg.Wait()
+
+		if upstreamInfo.Err != nil {
+			return errors.Annotatef(upstreamInfo.Err, "failed to get the upstream checksum of index %s", index.Name.O)
+		}
+		if downstreamInfo.Err != nil {
+			return errors.Annotatef(downstreamInfo.Err, "failed to get the downstream checksum of index %s", index.Name.O)
+		}
+		if upstreamInfo.Count != downstreamInfo.Count || upstreamInfo.Checksum != downstreamInfo.Checksum {
+			logger.Warn("the checksums of the index are different", zap.String("index", index.Name.O),
+				zap.Int64("upstream count", upstreamInfo.Count), zap.Int64("downstream count", downstreamInfo.Count))
+			df.report.AddTableIndexMismatch(tableDiff.Schema, tableDiff.Table, index.Name.O, rangeInfo.ChunkRange.Index)
+		}
+	}
+	return nil
+}
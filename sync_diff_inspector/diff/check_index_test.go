@@ -0,0 +1,57 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/chunk"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/report"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/splitter"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/utils"
+	"github.com/pingcap/tidb/parser"
+	"github.com/pingcap/tidb/parser/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareIndices(t *testing.T) {
+	tableInfo, err := dbutil.GetTableInfoBySQL("create table `test`.`t`(`a` int primary key, `b` int, `c` int, key `idx_b`(`b`), key `idx_c`(`c`))", parser.New())
+	require.NoError(t, err)
+	tables := []*common.TableDiff{{
+		Schema:       "test",
+		Table:        "t",
+		Info:         tableInfo,
+		CheckIndices: []*model.IndexInfo{utils.FindIndexByName(tableInfo, "idx_b"), utils.FindIndexByName(tableInfo, "IDX_C")},
+	}}
+	upstream := &mockSource{tables: tables, indexChecksums: map[string]int64{"idx_b": 1, "idx_c": 2}}
+	downstream := &mockSource{tables: tables, indexChecksums: map[string]int64{"idx_b": 1, "idx_c": 3}}
+	df := newTestDiff(t, upstream, downstream)
+	c := chunk.NewChunkRange()
+	c.Index = &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 1, ChunkCnt: 2}
+	rangeInfo := &splitter.RangeInfo{ChunkRange: c}
+	require.NoError(t, df.compareIndices(context.Background(), rangeInfo, newChunkLogger(tables[0], rangeInfo)))
+
+	result := df.report.TableResults["test"]["t"]
+	require.True(t, result.DataEqual)
+	require.Empty(t, result.IndexResults["idx_b"].MismatchChunks)
+	require.Equal(t, []string{c.Index.Encode()}, result.IndexResults["idx_c"].MismatchChunks)
+	require.Equal(t, report.Fail, df.report.GetResult())
+
+	downstream.indexErr = errors.New("connection refused")
+	require.Error(t, df.compareIndices(context.Background(), rangeInfo, newChunkLogger(tables[0], rangeInfo)))
+}
@@ -0,0 +1,118 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/report"
+	"go.uber.org/zap"
+)
+
+// connPoolSampleInterval is the interval to sample the statistics of the connection pools during the comparison.
+const connPoolSampleInterval = time.Second
+
+// connPool is the connection pool of a database whose statistics are sampled during the comparison.
+type connPool struct {
+	name string
+	db   *sql.DB
+	// start is the statistics when the sampling starts, which are subtracted since the pool is used before comparing,
+	// e.g. to get the versions of the servers.
+	start     sql.DBStats
+	peakInUse int
+}
+
+// newConnPools returns the connection pools of the sources and the target, named like the versions in the summary.
+func newConnPools(cfg *config.Config) []*connPool {
+	pools := make([]*connPool, 0, len(cfg.Task.SourceInstances)+1)
+	for i, instance := range cfg.Task.SourceInstances {
+		if instance.Conn != nil {
+			pools = append(pools, &connPool{name: fmt.Sprintf("Source Database %d", i), db: instance.Conn})
+		}
+	}
+	if cfg.Task.TargetInstance.Conn != nil {
+		pools = append(pools, &connPool{name: "Target Database", db: cfg.Task.TargetInstance.Conn})
+	}
+	return pools
+}
+
+func (p *connPool) sample() sql.DBStats {
+	stats := p.db.Stats()
+	if stats.InUse > p.peakInUse {
+		p.peakInUse = stats.InUse
+	}
+	return stats
+}
+
+// startSamplingConnPools takes the first samples of the connection pools and starts sampling them, and returns the function to stop it, which waits until
+// the statistics are recorded in the report and can be called more than once.
+func (df *Diff) startSamplingConnPools() func() {
+	for _, pool := range df.connPools {
+		pool.start = pool.sample()
+	}
+	stopCh, doneCh := make(chan struct{}), make(chan struct{})
+	go df.sampleConnPools(time.Now(), stopCh, doneCh)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stopCh)
+			<-doneCh
+		})
+	}
+}
+
+// sampleConnPools samples the connection pools every connPoolSampleInterval until stopCh is closed, then records
+// the statistics since startTime in the report, and warns the pools waited long, which slow down the comparison.
+func (df *Diff) sampleConnPools(startTime time.Time, stopCh chan struct{}, doneCh chan struct{}) {
+	defer close(doneCh)
+	ticker := time.NewTicker(connPoolSampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			df.setConnPoolStats(time.Since(startTime))
+			return
+		case <-ticker.C:
+			for _, pool := range df.connPools {
+				pool.sample()
+			}
+		}
+	}
+}
+
+func (df *Diff) setConnPoolStats(elapsed time.Duration) {
+	allStats := make([]*report.ConnPoolStats, 0, len(df.connPools))
+	for _, pool := range df.connPools {
+		end := pool.sample()
+		stats := &report.ConnPoolStats{
+			Name:               pool.name,
+			MaxOpenConnections: end.MaxOpenConnections,
+			PeakInUse:          pool.peakInUse,
+			WaitCount:          end.WaitCount - pool.start.WaitCount,
+			WaitDuration:       end.WaitDuration - pool.start.WaitDuration,
+		}
+		if stats.IsWaitSignificant(elapsed) {
+			log.Warn("the comparison waits long for the connections, consider raising max-open-conns",
+				zap.String("database", stats.Name), zap.Int("max open connections", stats.MaxOpenConnections),
+				zap.Int64("wait count", stats.WaitCount), zap.Duration("wait duration", stats.WaitDuration), zap.Duration("elapsed", elapsed))
+		}
+		allStats = append(allStats, stats)
+	}
+	df.report.SetConnPoolStats(allStats)
+}
@@ -0,0 +1,108 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/chunk"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/progress"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/utils"
+	"go.uber.org/zap"
+)
+
+// compareCount compares the row counts of the tables in the range of the table configs, which is the first pass
+// of `check-mode = "count"` and `"count-then-full"`, and count-precheck. The tables whose results are decided by
+// the row counts are marked `IgnoreDataCheck`, so that they are not compared by chunks then.
+func (df *Diff) compareCount(ctx context.Context) {
+	tables := df.downstream.GetTables()
+	tableIndex := 0
+	if df.startRange != nil {
+		// the table of the checkpoint has passed the count check before it's compared by chunks.
+		tableIndex = df.startRange.ChunkRange.Index.TableIndex + 1
+	}
+	pool := utils.NewWorkerPool(uint(df.checkThreadCount), "count")
+	// the tables being compared are finished by fail-fast, but the rest are not dispatched.
+	for ; tableIndex < len(tables) && !df.report.IsFailedFast(); tableIndex++ {
+		if tables[tableIndex].IgnoreDataCheck {
+			continue
+		}
+		i := tableIndex
+		pool.Apply(func() {
+			df.compareTableCount(ctx, i)
+		})
+	}
+	pool.WaitFinished()
+}
+
+// compareTableCount compares the row count of the table, and the row counts of both sides are recorded in the report.
+// The count delta is recorded as the diff rows of the table if the data is only verified by the row count, and
+// the table is always compared by chunks then by count-precheck in check-mode "full".
+func (df *Diff) compareTableCount(ctx context.Context, tableIndex int) {
+	table := df.downstream.GetTables()[tableIndex]
+	tableName := dbutil.TableName(table.Schema, table.Table)
+	upstreamCount, err := df.upstream.GetCount(ctx, tableIndex)
+	var downstreamCount int64
+	if err == nil {
+		downstreamCount, err = df.downstream.GetCount(ctx, tableIndex)
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil && df.checkMode == config.CheckModeFull {
+		// the precheck is only a sanity check, the rows are still compared by chunks.
+		log.Warn("fail to precheck the row count", zap.String("table", tableName), zap.Error(err))
+		return
+	}
+	if err != nil {
+		log.Warn("fail to compare the row count", zap.String("table", tableName), zap.Error(err))
+		df.report.SetTableMeetError(table.Schema, table.Table, err, nil, "")
+		table.IgnoreDataCheck = true
+		return
+	}
+	isEqual := upstreamCount == downstreamCount
+	log.Info("row count compared", zap.String("table", tableName), zap.Bool("equal", isEqual),
+		zap.Int64("upstream count", upstreamCount), zap.Int64("downstream count", downstreamCount))
+	df.report.SetTableRowCounts(table.Schema, table.Table, upstreamCount, downstreamCount)
+	if df.checkMode == config.CheckModeFull {
+		if !isEqual {
+			log.Warn("the row counts are different by count-precheck, the table is still compared by chunks", zap.String("table", tableName),
+				zap.Int64("upstream count", upstreamCount), zap.Int64("downstream count", downstreamCount))
+		}
+		return
+	}
+	if isEqual && df.checkMode == config.CheckModeCountThenFull {
+		// the table is compared by chunks then.
+		return
+	}
+	rowsAdd, rowsDelete := 0, 0
+	if delta := int(upstreamCount - downstreamCount); delta > 0 {
+		rowsAdd = delta
+	} else {
+		rowsDelete = -delta
+	}
+	if df.fixTarget == config.FixTargetSource {
+		// the rows added to the target are the rows deleted from the source.
+		rowsAdd, rowsDelete = rowsDelete, rowsAdd
+	}
+	id := &chunk.ChunkID{TableIndex: tableIndex, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1}
+	df.report.SetTableCountCheckResult(table.Schema, table.Table, isEqual, rowsAdd, rowsDelete, id)
+	if !isEqual {
+		progress.FailTable(tableName)
+	}
+	table.IgnoreDataCheck = true
+}
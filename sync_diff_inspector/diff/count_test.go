@@ -0,0 +1,95 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/report"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareCount(t *testing.T) {
+	for _, c := range []struct {
+		checkMode string
+		fixTarget string
+		// whether the data of each table is checked by chunks after comparing the row counts.
+		fullChecks []bool
+		rowsAdd    int
+		rowsDelete int
+	}{
+		{config.CheckModeCount, config.FixTargetTarget, []bool{false, false, false}, 3, 0},
+		{config.CheckModeCount, config.FixTargetSource, []bool{false, false, false}, 0, 3},
+		{config.CheckModeCountThenFull, config.FixTargetTarget, []bool{true, false, false}, 3, 0},
+	} {
+		tables := []*common.TableDiff{
+			{Schema: "test", Table: "t1"},
+			{Schema: "test", Table: "t2"},
+			// skipped by the struct check
+			{Schema: "test", Table: "t3", IgnoreDataCheck: true},
+		}
+		df := newTestDiff(t, &mockSource{tables: tables, counts: []int64{10, 10, 10}}, &mockSource{tables: tables, counts: []int64{10, 7, 0}}, func(df *Diff) {
+			df.checkThreadCount = 2
+			df.fixTarget = c.fixTarget
+			df.checkMode = c.checkMode
+		})
+		df.compareCount(context.Background())
+
+		for i, table := range tables {
+			require.Equal(t, !c.fullChecks[i], table.IgnoreDataCheck, c.checkMode)
+		}
+		result := df.report.TableResults["test"]["t1"]
+		require.True(t, result.DataEqual)
+		require.Equal(t, c.checkMode == config.CheckModeCount, result.CountOnly)
+		result = df.report.TableResults["test"]["t2"]
+		require.False(t, result.DataEqual)
+		require.True(t, result.CountOnly)
+		require.Equal(t, c.rowsAdd, result.ChunkMap["v2:g1.g0.g0.g0.g1"].RowsAdd)
+		require.Equal(t, c.rowsDelete, result.ChunkMap["v2:g1.g0.g0.g0.g1"].RowsDelete)
+		// the table skipped isn't counted.
+		result = df.report.TableResults["test"]["t3"]
+		require.False(t, result.CountOnly)
+		require.Equal(t, report.Fail, df.report.Result)
+	}
+}
+
+func TestCountPrecheck(t *testing.T) {
+	tables := []*common.TableDiff{
+		{Schema: "test", Table: "t1"},
+		{Schema: "test", Table: "t2"},
+	}
+	df := newTestDiff(t, &mockSource{tables: tables, counts: []int64{10, 10}}, &mockSource{tables: tables, counts: []int64{10, 7}}, func(df *Diff) {
+		df.checkThreadCount = 2
+		df.fixTarget = config.FixTargetTarget
+		df.checkMode = config.CheckModeFull
+		df.countPrecheck = true
+	})
+	df.compareCount(context.Background())
+
+	// the tables are still compared by chunks whatever the row counts are.
+	for _, table := range tables {
+		require.False(t, table.IgnoreDataCheck)
+	}
+	result := df.report.TableResults["test"]["t2"]
+	require.False(t, result.CountOnly)
+	require.Empty(t, result.ChunkMap)
+	require.Equal(t, int64(10), *result.SourceRows)
+	require.Equal(t, int64(7), *result.TargetRows)
+	result = df.report.TableResults["test"]["t1"]
+	require.Equal(t, int64(10), *result.SourceRows)
+	require.Equal(t, int64(10), *result.TargetRows)
+}
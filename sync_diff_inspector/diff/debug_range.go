@@ -0,0 +1,205 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	filter "github.com/pingcap/tidb-tools/pkg/table-filter"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/chunk"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/report"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/splitter"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/utils"
+	"go.uber.org/zap"
+)
+
+// DebugResult is the result of comparing a chunk or a range of a table alone, see `CompareDebugRange`.
+type DebugResult struct {
+	Schema string
+	Table  string
+	// Where is the condition of the rows compared, and Args are the values in it.
+	Where string
+	Args  []interface{}
+	// ChecksumEqual is true if the checksums and the row counts on both sides are equal, and Equal is true if the rows
+	// are equal too.
+	ChecksumEqual bool
+	Equal         bool
+	// RowsAdd and RowsDelete are relative to the fix target like `report.ChunkResult`.
+	RowsAdd    int
+	RowsDelete int
+	// Columns are the columns of the table, and each of Rows is the side followed by the values of a row only on that
+	// side, which are limited by max-print-rows. NULL is "NULL".
+	Columns []string
+	Rows    [][]string
+	// FixSQLFile is the file the fix sql is written to, empty if no fix sql is written.
+	FixSQLFile string
+}
+
+// CompareDebugRange compares the chunk of `--chunk-id` or the rows of the table in `--range` alone without splitting
+// the table, which is used to examine the inconsistent chunk reported by the last run. The sources are built by the
+// same config as the whole comparison, so the snapshots, the collations and the ignored columns are the same. The
+// checksum and the rows are both compared, and neither the checkpoint nor the report is written. It can't be called
+// with Run.
+func (df *Diff) CompareDebugRange(ctx context.Context) (*DebugResult, error) {
+	defer df.closeSources()
+	cfg := df.cfg
+	schema, table, chunkRange, err := getDebugRange(cfg)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if !cfg.Task.TargetCheckTables.MatchTable(schema, table) {
+		return nil, errors.Errorf("%s is not matched by target-check-tables", dbutil.TableName(schema, table))
+	}
+	// only the table is resolved on both sides.
+	cfg.Task.TargetCheckTables = filter.NewTablesFilter(filter.Table{Schema: schema, Name: table})
+
+	setTiDBCfg()
+	df.downstream, df.upstream, _, _, err = source.NewSources(ctx, cfg)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	df.workSource = df.pickSource(ctx)
+	tables := df.downstream.GetTables()
+	if len(tables) == 0 {
+		return nil, errors.Errorf("%s is not found on both sides", dbutil.TableName(schema, table))
+	}
+	tableDiff := tables[0]
+	df.report.Init(tables, nil, nil)
+	df.tableSizes = utils.NewTableSizeCache(df.downstream.GetDB())
+	if _, isSkip, err := df.compareStruct(ctx, 0); err != nil {
+		return nil, errors.Trace(err)
+	} else if isSkip {
+		return nil, errors.Errorf("the data of %s can't be compared, please check the log for details", dbutil.TableName(schema, table))
+	}
+
+	limits := tableDiff.Range
+	if len(cfg.DebugRange) > 0 {
+		limits = fmt.Sprintf("(%s) AND (%s)", limits, cfg.DebugRange)
+	}
+	id := chunkRange.Index
+	chunk.InitChunk(chunkRange, chunk.Others, id.BucketIndexLeft, id.BucketIndexRight, tableDiff.Collation, limits)
+	// the table is the only one resolved, whose index may be different from the one in the report.
+	chunkRange.Index = id
+	chunkRange.Index.TableIndex = 0
+	rangeInfo := &splitter.RangeInfo{ChunkRange: chunkRange}
+	result := &DebugResult{
+		Schema:  schema,
+		Table:   table,
+		Where:   chunkRange.Where,
+		Args:    chunkRange.Args,
+		Columns: make([]string, 0, len(tableDiff.Info.Columns)),
+	}
+	for _, column := range tableDiff.Info.Columns {
+		result.Columns = append(result.Columns, column.Name.O)
+	}
+
+	logger := newChunkLogger(tableDiff, rangeInfo)
+	result.ChecksumEqual, _, err = df.compareChecksumAndGetCount(ctx, df.upstream, df.downstream, rangeInfo)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	// the different rows are collected to print rather than exported.
+	df.diffRowsExporter = newDiffRowsExporter("", 0, int64(cfg.DebugMaxRows), df.report)
+	dml := &ChunkDML{node: rangeInfo.ToNode()}
+	result.Equal, err = df.compareRows(ctx, rangeInfo, dml, logger)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	result.Equal = result.Equal && result.ChecksumEqual
+	result.RowsAdd, result.RowsDelete = dml.rowAdd, dml.rowDelete
+	for _, row := range dml.diffRows {
+		values := make([]string, 0, len(tableDiff.Info.Columns)+1)
+		values = append(values, row.side)
+		for _, column := range tableDiff.Info.Columns {
+			data, ok := row.data[column.Name.O]
+			if !ok || data.IsNull {
+				values = append(values, "NULL")
+			} else {
+				values = append(values, string(data.Data))
+			}
+		}
+		result.Rows = append(result.Rows, values)
+	}
+	logger.Info("debug range compared", zap.Bool("checksum equal", result.ChecksumEqual), zap.Bool("equal", result.Equal),
+		zap.Int("rows add", result.RowsAdd), zap.Int("rows delete", result.RowsDelete))
+
+	if len(cfg.DebugFixSQLFile) > 0 && len(dml.sqls) > 0 {
+		var sb strings.Builder
+		sb.WriteString(df.fixSQLHeader(tableDiff, chunkRange))
+		for _, sql := range dml.sqls {
+			sb.WriteString(sql)
+			sb.WriteString("\n")
+		}
+		if err := os.MkdirAll(filepath.Dir(cfg.DebugFixSQLFile), cfg.Task.DirPerm()); err != nil {
+			return nil, errors.Trace(err)
+		}
+		if err := os.WriteFile(cfg.DebugFixSQLFile, []byte(sb.String()), config.LocalFilePerm); err != nil {
+			return nil, errors.Annotate(err, "failed to write the fix sql")
+		}
+		result.FixSQLFile = cfg.DebugFixSQLFile
+	}
+	return result, nil
+}
+
+// getDebugRange returns the table and the chunk to compare by `--chunk-id` or `--range`. The chunk of `--chunk-id` is
+// built by the bounds recorded in the report, and the chunk of `--range` covers the whole table, whose rows are
+// limited by the condition. The conditions of the chunk are not initialized.
+func getDebugRange(cfg *config.Config) (schema, table string, chunkRange *chunk.Range, err error) {
+	if len(cfg.DebugRange) > 0 {
+		schema, table, err = cfg.GetDebugTable()
+		if err != nil {
+			return "", "", nil, errors.Trace(err)
+		}
+		chunkRange = chunk.NewChunkRange()
+		chunkRange.Index = &chunk.ChunkID{ChunkCnt: 1}
+		return schema, table, chunkRange, nil
+	}
+
+	id := new(chunk.ChunkID)
+	if err = id.FromString(cfg.DebugChunkID); err != nil {
+		return "", "", nil, errors.Trace(err)
+	}
+	reportFile := cfg.DebugReportFile
+	if len(reportFile) == 0 {
+		reportFile = filepath.Join(cfg.Task.OutputDir, "report.json")
+	}
+	r, err := report.LoadReportFile(reportFile)
+	if err != nil {
+		return "", "", nil, errors.Annotate(err, "failed to load the report to find the chunk")
+	}
+	schema, table, chunkResult, err := r.FindChunk(id)
+	if err != nil {
+		return "", "", nil, errors.Trace(err)
+	}
+	if chunkResult == nil {
+		return "", "", nil, errors.Errorf("chunk %s is not found in %s", cfg.DebugChunkID, reportFile)
+	}
+	chunkRange = chunkResult.ChunkRange(id)
+	if chunkRange == nil {
+		// the bounds of the equal chunks and the ones compared by the old versions are not recorded.
+		return "", "", nil, errors.Errorf("the bounds of chunk %s are not recorded in %s, only the inconsistent chunks can be compared", cfg.DebugChunkID, reportFile)
+	}
+	log.Info("found the chunk in the report", zap.String("table", dbutil.TableName(schema, table)), zap.String("chunk id", cfg.DebugChunkID),
+		zap.String("bound", chunkRange.BoundString()))
+	return schema, table, chunkRange, nil
+}
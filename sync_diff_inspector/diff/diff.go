@@ -0,0 +1,2227 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/failpoint"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/checkpoints"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/chunk"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/fixsql"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/history"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/progress"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/report"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/splitter"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/utils"
+	tidbconfig "github.com/pingcap/tidb/config"
+	"github.com/pingcap/tidb/parser/model"
+	"github.com/siddontang/go/ioutil2"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// checkpointFile represents the checkpoints' file name which used for save and loads chunks
+	checkpointFile = "sync_diff_checkpoints.pb"
+	// progressCallbackInterval is the interval to call the progress callbacks registered by OnProgress.
+	progressCallbackInterval = time.Second
+)
+
+// ErrNotConfirmed is returned by Run if the comparison is declined by the callback of SetConfirm.
+var ErrNotConfirmed = errors.New("the comparison is not confirmed")
+
+// ErrConcurrentRun is returned by Run if another Diff is running in the process, see Run.
+var ErrConcurrentRun = errors.New("another comparison is running in the process")
+
+// running is set while a Diff is running, the progress and the config of the TiDB parser are global in the process.
+var running int32
+
+// ChunkDML SQL struct for each chunk
+type ChunkDML struct {
+	node      *checkpoints.Node
+	sqls      []string
+	rowAdd    int
+	rowDelete int
+	// diffRows are the rows only on one side, which are exported if export-diff-rows is true.
+	diffRows []*diffRow
+	// rowDiffs are the different rows exported if export-row-diffs is true, and rowDiffsSuppressed is the number of
+	// the ones beyond max-row-diffs, which are not kept.
+	rowDiffs           []*rowDiff
+	rowDiffsSuppressed int64
+}
+
+// Diff contains two sql DB, used for comparing.
+type Diff struct {
+	cfg *config.Config
+
+	// we may have multiple sources in dm sharding sync.
+	upstream   source.Source
+	downstream source.Source
+
+	// workSource is one of upstream/downstream by some policy in #pickSource.
+	workSource source.Source
+
+	sample           int
+	checkThreadCount int
+	exportFixSQL     bool
+	useCheckpoint    bool
+	ignoreDataCheck  bool
+	fixTarget        string
+	skipNoPKTables   bool
+	sqlWg            sync.WaitGroup
+	checkpointWg     sync.WaitGroup
+
+	// structThreadCount is the number of tables whose structures are checked concurrently.
+	structThreadCount int
+
+	// compare the tables without primary key or unique key whose rows are no more than noIndexTableMaxRows.
+	compareNoIndexTables bool
+	noIndexTableMaxRows  int64
+	// check whether the values of the unique keys are unique on both sides before comparing the data.
+	checkPKUniqueness bool
+
+	// check the data when the struct mismatch is non-breaking.
+	dataCheckOnStructMismatch bool
+	// match the columns by name rather than position.
+	matchColumnsByName bool
+	// compare the ENUM and SET columns by value rather than stored index.
+	compareEnumByValue bool
+	// fail the struct check if the members of the ENUM and SET columns are in different orders.
+	failOnEnumMemberOrder bool
+	// compare the GEOMETRY columns by the SRID and the WKB, otherwise the tables with them are skipped.
+	compareGeometry bool
+	// transcode the source values of the columns whose charset is mapped to the one of the target by charsetMap,
+	// `source charset` => `target charset` in lower case, see `utils.TranscodeValue`.
+	charsetMap map[string]string
+	// compare the row counts before or instead of comparing by chunks, see `config.CheckModeCount`.
+	checkMode string
+	// compare the row counts of the tables before comparing them by chunks in check-mode "full", and the tables whose
+	// row counts are different are still compared by chunks.
+	countPrecheck bool
+	// verify the tables by `ADMIN CHECKSUM TABLE` before comparing them by chunks, which is decided by
+	// admin-checksum and the versions of the servers, see `useAdminChecksum`.
+	useAdminChecksum bool
+	// only compare the fraction sampleRate of the chunks selected by sampleSeed, see `isChunkSampled`.
+	sampleRate float64
+	sampleSeed int64
+	// recheck the failed chunks recheckTimes times after recheckDelay each, see `scheduleRecheck`.
+	recheckFailedChunks bool
+	recheckDelay        time.Duration
+	recheckTimes        int
+	// the sources the failed chunks are rechecked on, which read the latest data with recheck-snapshot = "latest",
+	// and they are upstream and downstream if nil.
+	recheckUpstream   source.Source
+	recheckDownstream source.Source
+	// recheckWg waits for the rechecks scheduled.
+	recheckWg sync.WaitGroup
+	// wait until the replication positions got by waitSyncClient catch up before comparing the data, polling every
+	// waitSyncInterval for waitSyncTimeout at most, see `waitSync`. The client is nil if wait-sync isn't set.
+	waitSyncClient   utils.ReplicationClient
+	waitSyncTimeout  time.Duration
+	waitSyncInterval time.Duration
+	// connPools are the connection pools of the sources and the target sampled during the comparison, see
+	// `sampleConnPools`.
+	connPools []*connPool
+	// log the heartbeat every heartbeatInterval during the comparison, 0 means no heartbeat.
+	heartbeatInterval time.Duration
+	// stop the comparison when Run exceeds runTimeout, 0 means no timeout.
+	runTimeout time.Duration
+	// cancel the comparison once the first table is found different, see `report.Report.SetFailFast`.
+	failFast bool
+	// the order the chunks are compared in, see `config.ChunkOrderSizeDesc`.
+	chunkOrder string
+	// compare the errored tables again when resuming from the checkpoint, see `rewindToErroredTable`.
+	retryErroredTables bool
+	// skip the data check of the tables out of [tableSizeMin, tableSizeMax], 0 means no limit.
+	tableSizeMin   int64
+	tableSizeMax   int64
+	zeroSizePolicy string
+	// tableSizes caches the sizes of the tables on the target, which are got in batches by schema.
+	tableSizes *utils.TableSizeCache
+	// warn about the tables whose estimated row count diverges from the actual rows by more than
+	// rowsEstimateWarnFactor times, 0 means no warning.
+	rowsEstimateWarnFactor float64
+	// the views on the both sides, which are only compared by the definitions if checkViews is true.
+	views      []*common.ViewDiff
+	checkViews bool
+	// compare the partition definitions of the tables in the struct check.
+	checkPartitionDefinition bool
+	// generate the statements to make the structures of the target match the source, see `writeStructFix`.
+	generateStructFix bool
+	// the pairs of the queries whose result sets are compared after the tables, see `compareQueryPairs`.
+	queryPairs map[string]*config.QueryPairConfig
+
+	FixSQLDir     string
+	CheckpointDir string
+
+	// fixSQLSink is where the fix sql files are written to, rooted at `FixSQLDir` by default.
+	fixSQLSink report.ReportSink
+	// fixSQLFiles records the fix sql files written through fixSQLSink in the run.
+	fixSQLFiles        fixsql.Records
+	fixFileMaxSize     int64
+	fixFileCompression string
+	// fixFileLayout is `config.FixFileLayoutChunk` or `config.FixFileLayoutTable`, the files of the tables are written
+	// by tableFixSQLWriters rather than fixSQLSink with the latter.
+	fixFileLayout string
+	// diffRowsExporter exports the rows only on one side of the failing tables, which is nil if
+	// export-diff-rows is false.
+	diffRowsExporter *diffRowsExporter
+	// rowDiffsExporter exports the different rows of the failing chunks, which is nil if export-row-diffs is false.
+	rowDiffsExporter *rowDiffsExporter
+	// fixSQLErr is the first error writing the fix sql, which stops the comparison and is returned by Run. The chunks
+	// after it are not inserted into the checkpoint, so they are compared again after resuming.
+	fixSQLErrMu sync.Mutex
+	fixSQLErr   error
+
+	// the progress is rendered to progressOutput, and reported to progressCallbacks.
+	progressOutput    io.Writer
+	progressCallbacks []func(progress.State)
+	progressStarted   bool
+
+	// confirm is called before the comparison starts, which is declined if it returns false,
+	// and the checkpoint of the previous run is kept.
+	confirm  func(ctx context.Context, r *report.Report, totalSize int64) bool
+	declined bool
+
+	// resumeCh is closed by Resume, which is nil if the comparison isn't paused.
+	pauseMu  sync.Mutex
+	resumeCh chan struct{}
+
+	sqlCh      chan *ChunkDML
+	cp         *checkpoints.Checkpoint
+	startRange *splitter.RangeInfo
+	report     *report.Report
+}
+
+// New returns a Diff to compare the sources and the target of cfg, which is initialized by `config.Config.Init`.
+// No connection is made until Run.
+func New(cfg *config.Config) (diff *Diff, err error) {
+	if cfg.Task.TargetInstance == nil {
+		return nil, errors.New("the config is not initialized")
+	}
+	if !cfg.CheckConfig() {
+		return nil, errors.New("the config is invalid, please check the log for details")
+	}
+	diff = &Diff{
+		cfg:              cfg,
+		checkThreadCount: cfg.CheckThreadCount,
+		exportFixSQL:     cfg.ExportFixSQL,
+		ignoreDataCheck:  cfg.CheckStructOnly,
+		fixTarget:        cfg.FixTarget,
+		skipNoPKTables:   cfg.SkipNoPKTables,
+		sqlCh:            make(chan *ChunkDML, splitter.DefaultChannelBuffer),
+		cp:               new(checkpoints.Checkpoint),
+		report:           report.NewReport(&cfg.Task),
+		progressOutput:   io.Discard,
+
+		dataCheckOnStructMismatch: cfg.DataCheckOnStructMismatch,
+		matchColumnsByName:        cfg.MatchColumnsByName,
+		compareEnumByValue:        cfg.CompareEnumByValue,
+		failOnEnumMemberOrder:     cfg.FailOnEnumMemberOrder,
+		compareGeometry:           cfg.CompareGeometry,
+		checkMode:                 cfg.CheckMode,
+		countPrecheck:             cfg.CountPrecheck,
+		sampleRate:                cfg.SampleRate,
+		sampleSeed:                cfg.SampleSeed,
+		queryPairs:                cfg.QueryPairs,
+		recheckFailedChunks:       cfg.RecheckFailedChunks,
+		recheckTimes:              cfg.RecheckTimes,
+		retryErroredTables:        cfg.RetryErroredTables,
+		failFast:                  cfg.FailFast,
+		chunkOrder:                cfg.ChunkOrder,
+		tableSizeMin:              cfg.TableSizeMin,
+		tableSizeMax:              cfg.TableSizeMax,
+		zeroSizePolicy:            cfg.ZeroSizePolicy,
+		rowsEstimateWarnFactor:    cfg.RowsEstimateWarnFactor,
+		checkViews:                cfg.CheckViews,
+		checkPartitionDefinition:  cfg.CheckPartitionDefinition,
+		generateStructFix:         cfg.GenerateStructFix,
+		compareNoIndexTables:      cfg.CompareNoIndexTables,
+		noIndexTableMaxRows:       cfg.NoIndexTableMaxRows,
+		checkPKUniqueness:         cfg.CheckPKUniqueness,
+
+		fixFileMaxSize:     cfg.FixFileMaxSize,
+		fixFileCompression: cfg.FixFileCompression,
+		fixFileLayout:      cfg.FixFileLayout,
+	}
+	if cfg.ExportDiffRows {
+		diff.diffRowsExporter = newDiffRowsExporter(filepath.Join(cfg.Task.OutputDir, diffRowsDir), cfg.Task.DirPerm(), cfg.MaxExportRows, diff.report)
+	}
+	if cfg.ExportRowDiffs {
+		diff.rowDiffsExporter = newRowDiffsExporter(filepath.Join(cfg.Task.OutputDir, rowDiffsDir), cfg.Task.DirPerm(), cfg.MaxRowDiffs, diff.report)
+	}
+	diff.structThreadCount = cfg.StructThreadCount
+	if diff.structThreadCount == 0 {
+		diff.structThreadCount = cfg.CheckThreadCount
+	}
+	diff.report.FixTarget = cfg.FixTarget
+	diff.report.SetMaxDiffRows(cfg.MaxDiffRows)
+	diff.report.SetMaxDiffRowsPerTable(cfg.MaxDiffRowsPerTable)
+	diff.report.SetRowsEstimateWarnFactor(cfg.RowsEstimateWarnFactor)
+	diff.report.SetRawUnits(cfg.RawUnits)
+	if diff.heartbeatInterval, err = time.ParseDuration(cfg.HeartbeatInterval); err != nil {
+		return nil, errors.Annotate(err, "invalid heartbeat-interval")
+	}
+	if diff.recheckFailedChunks {
+		if diff.recheckDelay, err = time.ParseDuration(cfg.RecheckDelay); err != nil {
+			return nil, errors.Annotate(err, "invalid recheck-delay")
+		}
+	}
+	if diff.runTimeout, err = time.ParseDuration(cfg.RunTimeout); err != nil {
+		return nil, errors.Annotate(err, "invalid run-timeout")
+	}
+	if cfg.WaitSync != nil {
+		if diff.waitSyncTimeout, err = cfg.WaitSync.GetTimeout(); err != nil {
+			return nil, errors.Annotate(err, "invalid wait-sync.timeout")
+		}
+		if diff.waitSyncInterval, err = cfg.WaitSync.GetInterval(); err != nil {
+			return nil, errors.Annotate(err, "invalid wait-sync.interval")
+		}
+	}
+	if len(cfg.CharsetMap) > 0 {
+		diff.charsetMap = make(map[string]string, len(cfg.CharsetMap))
+		for from, to := range cfg.CharsetMap {
+			if !utils.IsSupportedCharset(to) {
+				return nil, errors.Errorf("invalid charset-map, the charset %s of the target is not supported", to)
+			}
+			diff.charsetMap[strings.ToLower(from)] = strings.ToLower(to)
+		}
+	}
+	return diff, nil
+}
+
+// Run compares the structures and then the data of the tables, and returns the report whose summary is committed.
+// The comparison stops when ctx is done. If the comparison is interrupted, the report of the partial results is
+// returned with the error of ctx, and the checkpoint is kept to resume the comparison. If the run exceeds run-timeout,
+// the report is also marked timed out. Run can only be called once.
+//
+// Run isn't safe to call concurrently with another Diff in the same process, because the progress printed and
+// returned by `progress.GetState` and the config of the TiDB parser are global. It returns ErrConcurrentRun if
+// another Diff is running, run the comparisons one by one or in different processes instead.
+func (df *Diff) Run(ctx context.Context) (*report.Report, error) {
+	if !atomic.CompareAndSwapInt32(&running, 0, 1) {
+		return nil, ErrConcurrentRun
+	}
+	defer atomic.StoreInt32(&running, 0)
+	if df.runTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, df.runTimeout)
+		defer cancel()
+	}
+	defer df.close()
+	if err := df.init(ctx, df.cfg); err != nil {
+		return nil, errors.Annotate(err, "failed to initialize diff process")
+	}
+	if len(df.progressCallbacks) > 0 {
+		stopCh, doneCh := make(chan struct{}), make(chan struct{})
+		go df.notifyProgress(stopCh, doneCh)
+		defer func() {
+			close(stopCh)
+			<-doneCh
+		}()
+	}
+	return df.compare(ctx)
+}
+
+// compare checks the structures and then the data of the initialized tables, and commits the summary.
+func (df *Diff) compare(ctx context.Context) (*report.Report, error) {
+	df.report.SetFailFast(df.failFast)
+	stopSampling := df.startSamplingConnPools()
+	defer stopSampling()
+	if err := df.StructEqual(ctx); err != nil {
+		if ctx.Err() == nil {
+			df.closeProgress()
+			return nil, errors.Annotate(err, "failed to check structure difference")
+		}
+		// the tables are compared again after resuming from the checkpoint.
+		log.Warn("the comparison is interrupted when checking the structures", zap.Error(ctx.Err()))
+		df.report.SetInterrupted()
+	}
+	if !df.ignoreDataCheck && !df.report.IsInterrupted() && !df.report.IsFailedFast() {
+		if err := df.Equal(ctx); err != nil {
+			if ctx.Err() == nil {
+				df.closeProgress()
+				return nil, errors.Annotate(err, "failed to check data difference")
+			}
+			log.Warn("the comparison is interrupted when checking the data", zap.Error(err))
+			df.report.SetInterrupted()
+		}
+	}
+	if len(df.queryPairs) > 0 && !df.ignoreDataCheck && !df.report.IsInterrupted() && !df.report.IsFailedFast() {
+		if err := df.compareQueryPairs(ctx); err != nil {
+			log.Warn("the comparison is interrupted when comparing the query pairs", zap.Error(err))
+			df.report.SetInterrupted()
+		}
+	}
+	// Stop updating progress bar so that summary won't be flushed.
+	df.closeProgress()
+	stopSampling()
+	if df.report.IsInterrupted() && df.runTimeout > 0 && ctx.Err() == context.DeadlineExceeded {
+		log.Warn("the comparison exceeds run-timeout, the results are truncated", zap.Duration("run-timeout", df.runTimeout))
+		df.report.SetTimedOut(df.runTimeout)
+	}
+	sizeCtx := ctx
+	if df.report.IsInterrupted() {
+		// the ctx is canceled, but the summary of the partial results is still written.
+		sizeCtx = context.Background()
+	}
+	df.report.CalculateTotalSize(sizeCtx, df.tableSizes)
+	if df.generateStructFix {
+		if err := df.writeStructFix(); err != nil {
+			// the statements are still recorded in the report.
+			log.Warn("failed to write the statements of generate-struct-fix", zap.Error(err))
+		}
+	}
+	if err := df.report.CommitSummary(); err != nil {
+		return nil, errors.Annotate(err, "failed to commit report")
+	}
+	if df.report.IsInterrupted() {
+		return df.report, errors.Trace(ctx.Err())
+	}
+	return df.report, nil
+}
+
+// OnProgress registers the callback which is called with the progress of the comparison every second during Run,
+// and once more with the final progress before Run returns. The callbacks are called in order in one goroutine.
+func (df *Diff) OnProgress(callback func(progress.State)) {
+	df.progressCallbacks = append(df.progressCallbacks, callback)
+}
+
+// SetConfirm registers the callback to confirm the config before the comparison starts. It's called after the tables
+// to compare are resolved, with the report holding the databases and the tables, and the estimated total size of the
+// tables. If it returns false, nothing is compared and Run returns ErrNotConfirmed.
+func (df *Diff) SetConfirm(confirm func(ctx context.Context, r *report.Report, totalSize int64) bool) {
+	df.confirm = confirm
+}
+
+// SetProgressOutput sets where the progress bar is rendered to, the progress isn't rendered by default.
+func (df *Diff) SetProgressOutput(output io.Writer) {
+	df.progressOutput = output
+}
+
+// SetFixSQLSink replaces the sink where the fix sql files are written to. The files in a sink other than the fix sql
+// directory aren't removed when resuming, and the manifest only lists the files written by the run.
+func (df *Diff) SetFixSQLSink(sink report.ReportSink) {
+	df.fixSQLSink = sink
+}
+
+// SetReportSink replaces the sink where the summary is written to.
+func (df *Diff) SetReportSink(sink report.ReportSink) {
+	df.report.SetSink(sink)
+}
+
+// Report returns the report of the comparison, which is updated during Run.
+func (df *Diff) Report() *report.Report {
+	return df.report
+}
+
+// Pause stops starting to compare the new chunks until Resume, and the chunks being compared are completed.
+func (df *Diff) Pause() {
+	df.pauseMu.Lock()
+	defer df.pauseMu.Unlock()
+	if df.resumeCh == nil {
+		log.Info("pause the comparison")
+		df.resumeCh = make(chan struct{})
+	}
+}
+
+// Resume continues the comparison paused by Pause.
+func (df *Diff) Resume() {
+	df.pauseMu.Lock()
+	defer df.pauseMu.Unlock()
+	if df.resumeCh != nil {
+		log.Info("resume the comparison")
+		close(df.resumeCh)
+		df.resumeCh = nil
+	}
+}
+
+// IsPaused returns true if the comparison is paused.
+func (df *Diff) IsPaused() bool {
+	df.pauseMu.Lock()
+	defer df.pauseMu.Unlock()
+	return df.resumeCh != nil
+}
+
+// waitIfPaused waits until the comparison is resumed or ctx is done.
+func (df *Diff) waitIfPaused(ctx context.Context) {
+	df.pauseMu.Lock()
+	resumeCh := df.resumeCh
+	df.pauseMu.Unlock()
+	if resumeCh == nil {
+		return
+	}
+	if df.progressStarted {
+		progress.SetPaused(true)
+		defer progress.SetPaused(false)
+	}
+	select {
+	case <-resumeCh:
+	case <-ctx.Done():
+	}
+}
+
+// notifyProgress calls the progress callbacks every progressCallbackInterval until stopCh is closed,
+// and closes doneCh after the last call.
+func (df *Diff) notifyProgress(stopCh chan struct{}, doneCh chan struct{}) {
+	defer close(doneCh)
+	ticker := time.NewTicker(progressCallbackInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			df.callProgressCallbacks()
+			return
+		case <-ticker.C:
+			df.callProgressCallbacks()
+		}
+	}
+}
+
+func (df *Diff) callProgressCallbacks() {
+	state := progress.GetState()
+	for _, callback := range df.progressCallbacks {
+		callback(state)
+	}
+}
+
+// initProgress starts the progress printer of the tables to compare.
+func (df *Diff) initProgress(finishTableNums int) {
+	progress.InitWithOutput(len(df.workSource.GetTables()), finishTableNums, df.progressOutput)
+	df.progressStarted = true
+}
+
+// closeProgress stops the progress printer if it's started.
+func (df *Diff) closeProgress() {
+	if df.progressStarted {
+		progress.Close()
+		df.progressStarted = false
+	}
+}
+
+// closeSources closes the sources and the target.
+func (df *Diff) closeSources() {
+	if df.upstream != nil {
+		df.upstream.Close()
+	}
+	if df.downstream != nil {
+		df.downstream.Close()
+	}
+	if df.recheckUpstream != nil && df.recheckUpstream != df.upstream {
+		df.recheckUpstream.Close()
+	}
+	if df.recheckDownstream != nil && df.recheckDownstream != df.downstream {
+		df.recheckDownstream.Close()
+	}
+}
+
+func (df *Diff) close() {
+	df.closeProgress()
+	df.closeSources()
+
+	if df.report.IsInterrupted() {
+		log.Info("the comparison is interrupted, keep the checkpoint file to resume.")
+		return
+	}
+	if df.report.IsFailedFast() {
+		log.Info("the comparison is stopped by fail-fast, keep the checkpoint file to resume.")
+		return
+	}
+	if df.declined {
+		log.Info("the comparison is not confirmed, keep the checkpoint file to resume.")
+		return
+	}
+	if df.getFixSQLErr() != nil {
+		log.Info("the comparison is stopped by the failure to write the fix sql, keep the checkpoint file to resume.")
+		return
+	}
+	if df.ignoreDataCheck {
+		// the checkpoint file belongs to the data check, which is not run in the struct-only mode.
+		return
+	}
+
+	failpoint.Inject("wait-for-checkpoint", func() {
+		log.Info("failpoint wait-for-checkpoint injected, skip delete checkpoint file.")
+		failpoint.Return()
+	})
+
+	path := filepath.Join(df.CheckpointDir, checkpointFile)
+	for _, fileName := range []string{path, checkpoints.IncrementFileName(path)} {
+		if err := os.Remove(fileName); err != nil && !os.IsNotExist(err) {
+			log.Error("fail to remove the checkpoint file", zap.String("error", err.Error()))
+		}
+	}
+}
+
+func (df *Diff) init(ctx context.Context, cfg *config.Config) (err error) {
+	// TODO adjust config
+	setTiDBCfg()
+
+	var (
+		missingTables  []*common.MissingTable
+		skippedObjects []*common.SkippedObject
+	)
+	df.downstream, df.upstream, missingTables, skippedObjects, err = source.NewSources(ctx, cfg)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	df.views, err = source.GetViewDiffs(ctx, cfg)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	if df.ignoreDataCheck {
+		// no chunk is split or compared in the struct-only mode, so the snapshot needn't be kept.
+		df.workSource = df.downstream
+	} else {
+		df.workSource = df.pickSource(ctx)
+		if df.recheckFailedChunks {
+			if err := df.initRecheckSources(ctx, cfg); err != nil {
+				return errors.Trace(err)
+			}
+		}
+		if cfg.WaitSync != nil {
+			if err := df.initWaitSync(ctx, cfg); err != nil {
+				return errors.Trace(err)
+			}
+		}
+	}
+	df.FixSQLDir = cfg.Task.FixDir
+	df.CheckpointDir = cfg.Task.CheckpointDir
+	if df.fixSQLSink == nil {
+		df.fixSQLSink = report.NewFileSink(df.FixSQLDir)
+	}
+	if df.fixFileLayout == config.FixFileLayoutTable && !df.isLocalFixSQLSink() {
+		return errors.New("fix-file-layout = \"table\" doesn't support the custom sink of the fix sql files, because the files are appended across the chunks")
+	}
+
+	sourceConfigs, targetConfig, err := getConfigsForReport(cfg)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	df.report.Init(df.downstream.GetTables(), sourceConfigs, targetConfig)
+	df.tableSizes = utils.NewTableSizeCache(df.downstream.GetDB())
+	for _, table := range df.downstream.GetTables() {
+		df.tableSizes.Register(table.Schema, table.Table)
+	}
+	sourceVersions, targetVersion := getServerVersions(ctx, cfg)
+	df.report.SetServerVersions(sourceVersions, targetVersion)
+	if cfg.KeepHistory {
+		setPreviousRun(cfg, df.report)
+	}
+	df.connPools = newConnPools(cfg)
+	df.useAdminChecksum = useAdminChecksum(cfg.AdminChecksum, sourceVersions, targetVersion)
+	df.report.SetConfigOverrides(cfg.AppliedOverrides)
+	if df.checkViews {
+		df.report.SetCheckViews()
+	}
+	if cfg.FailOnMissingTables {
+		df.report.SetFailOnMissingTables()
+	}
+	for _, table := range missingTables {
+		missingOn := report.MissingOnSource
+		if table.MissingOnTarget {
+			missingOn = report.MissingOnTarget
+		}
+		df.report.AddMissingTable(table.Schema, table.Table, missingOn)
+		if df.generateStructFix {
+			df.setMissingTableStructFix(ctx, cfg, table)
+		}
+	}
+	for _, object := range skippedObjects {
+		on := report.ObjectOnTarget
+		if object.OnSource {
+			on = report.ObjectOnSource
+		}
+		df.report.AddSkippedObject(&report.SkippedObject{
+			Schema: object.Schema,
+			Table:  object.Table,
+			On:     on,
+			Type:   object.Type,
+			Engine: object.Engine,
+			Reason: object.Reason,
+		})
+	}
+	if df.confirm != nil && !df.confirm(ctx, df.report, df.report.EstimateTotalSize(ctx, df.tableSizes)) {
+		df.declined = true
+		return errors.Trace(ErrNotConfirmed)
+	}
+	if df.ignoreDataCheck {
+		// the checkpoint is neither loaded nor saved in the struct-only mode, all the tables are compared.
+		df.report.SetCheckStructOnly()
+		df.initProgress(0)
+	} else {
+		if df.chunkOrder == config.ChunkOrderSizeDesc {
+			if err := df.orderTables(ctx); err != nil {
+				return errors.Trace(err)
+			}
+		}
+		if err := df.initCheckpoint(); err != nil {
+			return errors.Trace(err)
+		}
+		df.initSampling()
+	}
+	for _, table := range df.downstream.GetTables() {
+		df.report.SetTableConcurrency(table.Schema, table.Table, df.getTableConcurrency(table))
+	}
+	return nil
+}
+
+func (df *Diff) initCheckpoint() error {
+	df.cp.Init()
+	chunkingParams := make([]*checkpoints.ChunkingParams, 0, len(df.workSource.GetTables()))
+	for _, table := range df.workSource.GetTables() {
+		chunkingParams = append(chunkingParams, checkpoints.NewChunkingParams(table))
+	}
+	df.cp.SetChunkingParams(chunkingParams)
+
+	finishTableNums := 0
+	// the chunks of the table being compared that are completed before the checkpoint
+	resumedChunks := 0
+	path := filepath.Join(df.CheckpointDir, checkpointFile)
+	var (
+		node       *checkpoints.Node
+		reportInfo *report.Report
+		err        error
+	)
+	if ioutil2.FileExists(path) {
+		node, reportInfo, err = df.cp.LoadChunk(path)
+		if err != nil {
+			return errors.Annotate(err, "the checkpoint load process failed")
+		}
+		// this need not be synchronized, because at the moment, the is only one thread access the section
+		log.Info("load checkpoint",
+			zap.Any("chunk index", node.GetID()),
+			zap.Reflect("chunk", node),
+			zap.String("state", node.GetState()))
+		if df.retryErroredTables {
+			node = df.rewindToErroredTable(node, reportInfo)
+		}
+	} else {
+		log.Info("not found checkpoint file, start from beginning")
+	}
+
+	if node != nil {
+		df.cp.InitCurrentSavedID(node)
+		// remove the sql file that ID bigger than node.
+		// cause we will generate these sql again.
+		err = df.removeSQLFiles(node.GetID())
+		if err != nil {
+			return errors.Trace(err)
+		}
+		df.startRange = splitter.FromNode(node)
+		df.report.LoadReport(reportInfo)
+		finishTableNums = df.startRange.GetTableIndex()
+		if df.startRange.ChunkRange.Type == chunk.Empty {
+			// chunk_iter will skip this table directly
+			finishTableNums++
+		} else {
+			resumedChunks = df.startRange.GetChunkIndex() + 1
+		}
+	} else {
+		err = df.removeSQLFiles(chunk.GetInitChunkID())
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if df.diffRowsExporter != nil {
+			if err := df.diffRowsExporter.removeFiles(); err != nil {
+				return errors.Trace(err)
+			}
+		}
+		if df.rowDiffsExporter != nil {
+			if err := df.rowDiffsExporter.removeFiles(); err != nil {
+				return errors.Trace(err)
+			}
+		}
+	}
+	df.initProgress(finishTableNums)
+	progress.AddResumedChunks(resumedChunks)
+	return nil
+}
+
+// rewindToErroredTable moves the checkpoint back to the end of the table before the first table meeting errors in the
+// report loaded from the checkpoint, so that the errored tables are compared again. The tables compared after the
+// first errored table are compared again too, because the checkpoint is saved in the order of the tables, and their
+// results are dropped from the report. nil is returned if the first table meets errors, i.e. start from the beginning.
+func (df *Diff) rewindToErroredTable(node *checkpoints.Node, reportInfo *report.Report) *checkpoints.Node {
+	tables := df.workSource.GetTables()
+	for tableIndex := 0; tableIndex <= node.GetTableIndex() && tableIndex < len(tables); tableIndex++ {
+		table := tables[tableIndex]
+		result, ok := reportInfo.TableResults[table.Schema][table.Table]
+		if !ok || len(result.ErrorMessage) == 0 {
+			continue
+		}
+		log.Info("compare the errored tables again from the table",
+			zap.String("table", dbutil.TableName(table.Schema, table.Table)),
+			zap.String("error", result.ErrorMessage))
+		reportInfo.DropTablesSince(table.Schema, table.Table)
+		if tableIndex == 0 {
+			return nil
+		}
+		// the previous table is completed, and chunk_iter starts from the errored table. The id is after all the
+		// chunks of the previous table, so that their fix sql files are kept.
+		return &checkpoints.Node{
+			State: checkpoints.IgnoreState,
+			ChunkRange: &chunk.Range{
+				Index: &chunk.ChunkID{
+					TableIndex:       tableIndex - 1,
+					BucketIndexLeft:  math.MaxInt32,
+					BucketIndexRight: math.MaxInt32,
+					ChunkIndex:       math.MaxInt32,
+				},
+				Type:    chunk.Empty,
+				IsFirst: true,
+				IsLast:  true,
+			},
+		}
+	}
+	return node
+}
+
+func encodeReportConfig(config *report.ReportConfig) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := toml.NewEncoder(buf).Encode(config); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// setPreviousRun sets the newest run of the task in the history as the previous run of the report, which the diff
+// rows of the tables are compared with. The trend is skipped if there is no previous run or it fails to load.
+func setPreviousRun(cfg *config.Config, r *report.Report) {
+	run, err := history.Latest(cfg.GetHistoryDir(cfg.GetTaskName()))
+	if err != nil {
+		log.Warn("failed to load the previous run in the history", zap.Error(err))
+		return
+	}
+	if run == nil {
+		log.Info("there is no previous run in the history to compare the diff rows with")
+		return
+	}
+	log.Info("compare the diff rows with the previous run in the history", zap.String("run", run.Name))
+	r.SetPreviousRun(run.Name, run.Report())
+}
+
+// getServerVersions gets the versions and the auto_increment settings of the source and target database servers for
+// the report, the version is nil if failed to get, which doesn't stop the comparison.
+func getServerVersions(ctx context.Context, cfg *config.Config) ([]*report.ServerVersion, *report.ServerVersion) {
+	getVersion := func(instance *config.DataSource) *report.ServerVersion {
+		if instance.Conn == nil {
+			return nil
+		}
+		version, tidbVersion, err := utils.GetServerVersion(ctx, instance.Conn)
+		if err != nil {
+			log.Warn("fail to get the version of the database", zap.String("host", instance.Host), zap.Int("port", instance.Port), zap.Error(err))
+			return nil
+		}
+		flavor, _ := utils.ParseServerVersion(version)
+		serverVersion := &report.ServerVersion{Version: version, Flavor: flavor, TiDBVersion: tidbVersion}
+		serverVersion.AutoIncrementIncrement, serverVersion.AutoIncrementOffset, err = utils.GetAutoIncrementSettings(ctx, instance.Conn)
+		if err != nil {
+			log.Warn("fail to get the auto_increment settings of the database", zap.String("host", instance.Host), zap.Int("port", instance.Port), zap.Error(err))
+		}
+		return serverVersion
+	}
+	sourceVersions := make([]*report.ServerVersion, 0, len(cfg.Task.SourceInstances))
+	for _, instance := range cfg.Task.SourceInstances {
+		sourceVersions = append(sourceVersions, getVersion(instance))
+	}
+	return sourceVersions, getVersion(cfg.Task.TargetInstance)
+}
+
+// getConfigsForReport returns the configs of the sources and the target written in the summary, the passwords are
+// omitted and the values of the redacted keys are masked, see `config.Config.IsRedactedKey`.
+func getConfigsForReport(cfg *config.Config) ([][]byte, []byte, error) {
+	reportConfig := func(instance *config.DataSource) *report.ReportConfig {
+		return &report.ReportConfig{
+			Host:     cfg.RedactValue("host", instance.Host),
+			Port:     instance.Port,
+			User:     cfg.RedactValue("user", instance.User),
+			Snapshot: cfg.RedactValue("snapshot", instance.Snapshot),
+			SqlMode:  cfg.RedactValue("sql-mode", instance.SqlMode),
+		}
+	}
+	sourceConfigs := make([]*report.ReportConfig, len(cfg.Task.SourceInstances))
+	for i := 0; i < len(cfg.Task.SourceInstances); i++ {
+		sourceConfigs[i] = reportConfig(cfg.Task.SourceInstances[i])
+	}
+	targetConfig := reportConfig(cfg.Task.TargetInstance)
+	sourceBytes := make([][]byte, len(sourceConfigs))
+	var err error
+	for i := range sourceBytes {
+		sourceBytes[i], err = encodeReportConfig(sourceConfigs[i])
+		if err != nil {
+			return nil, nil, errors.Trace(err)
+		}
+	}
+	targetBytes, err := encodeReportConfig(targetConfig)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	return sourceBytes, targetBytes, nil
+}
+
+// getTableConcurrency returns the effective number of chunks of the table compared concurrently.
+func (df *Diff) getTableConcurrency(table *common.TableDiff) int {
+	if table.Concurrency > 0 && table.Concurrency < df.checkThreadCount {
+		return table.Concurrency
+	}
+	return df.checkThreadCount
+}
+
+// Equal tests whether two database have same data and schema. It returns the error writing the fix sql if any.
+func (df *Diff) Equal(ctx context.Context) (err error) {
+	if df.heartbeatInterval > 0 {
+		heartbeatCh := make(chan struct{})
+		defer close(heartbeatCh)
+		go df.heartbeat(heartbeatCh)
+	}
+	if df.waitSyncClient != nil {
+		df.waitSync(ctx)
+		if ctx.Err() != nil {
+			log.Warn("the comparison is interrupted when waiting for the replication", zap.Error(ctx.Err()))
+			df.report.SetInterrupted()
+			return nil
+		}
+	}
+	if df.checkPKUniqueness {
+		df.checkKeyUniqueness(ctx)
+		if ctx.Err() != nil {
+			log.Warn("the comparison is interrupted when checking the uniqueness of the keys", zap.Error(ctx.Err()))
+			df.report.SetInterrupted()
+			return nil
+		}
+	}
+	if df.checkMode == config.CheckModeCount || df.checkMode == config.CheckModeCountThenFull || df.countPrecheck {
+		df.compareCount(ctx)
+		if ctx.Err() != nil {
+			log.Warn("the comparison is interrupted when comparing the row counts", zap.Error(ctx.Err()))
+			df.report.SetInterrupted()
+			return nil
+		}
+	}
+	if df.useAdminChecksum {
+		df.compareAdminChecksum(ctx)
+		if ctx.Err() != nil {
+			log.Warn("the comparison is interrupted when comparing the admin checksums", zap.Error(ctx.Err()))
+			df.report.SetInterrupted()
+			return nil
+		}
+	}
+	if df.report.IsFailedFast() {
+		log.Warn("the comparison is stopped by fail-fast before comparing the chunks")
+		return nil
+	}
+	chunksIter, err := df.generateChunksIterator(ctx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer chunksIter.Close()
+	pool := utils.NewWorkerPool(uint(df.checkThreadCount), "consumer")
+	recheckPool := utils.NewWorkerPool(uint(df.checkThreadCount), "recheck")
+	stopCh := make(chan struct{})
+	// the tables whose concurrency is limited, `progress id` => the slots of the chunks being compared, a slot is
+	// taken before the chunk is dispatched, and released after the last attempt of the chunk including the rechecks.
+	tableSlots := make(map[string]chan struct{})
+	for _, table := range df.workSource.GetTables() {
+		if concurrency := df.getTableConcurrency(table); concurrency < df.checkThreadCount {
+			tableSlots[dbutil.TableName(table.Schema, table.Table)] = make(chan struct{}, concurrency)
+		}
+	}
+
+	df.checkpointWg.Add(1)
+	go df.handleCheckpoints(ctx, stopCh)
+	var tableWriters *tableFixSQLWriters
+	if df.fixFileLayout == config.FixFileLayoutTable {
+		tableWriters = newTableFixSQLWriters(df)
+	}
+	df.sqlWg.Add(1)
+	go df.writeSQLs(ctx, tableWriters)
+
+	defer func() {
+		pool.WaitFinished()
+		// the rechecks are scheduled by the tasks of the pool, and so are the rechecks after them.
+		df.recheckWg.Wait()
+		log.Debug("all consume tasks finished")
+		// close the sql channel
+		close(df.sqlCh)
+		df.sqlWg.Wait()
+		df.writeFixSQLManifest()
+		stopCh <- struct{}{}
+		df.checkpointWg.Wait()
+		if fixSQLErr := df.getFixSQLErr(); fixSQLErr != nil && err == nil {
+			err = errors.Annotate(fixSQLErr, "failed to write the fix sql")
+		}
+	}()
+
+	for {
+		if df.report.IsAborted() {
+			log.Warn("the comparison is aborted, stop consuming the rest chunks")
+			break
+		}
+		if df.report.IsFailedFast() {
+			// the chunks being compared are finished, and the checkpoint is kept to resume the rest chunks.
+			log.Warn("the comparison is stopped by fail-fast, stop consuming the rest chunks")
+			break
+		}
+		if df.getFixSQLErr() != nil {
+			log.Warn("failed to write the fix sql, stop consuming the rest chunks", zap.Error(df.getFixSQLErr()))
+			break
+		}
+		df.waitIfPaused(ctx)
+		c, err := chunksIter.Next(ctx)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if ctx.Err() != nil {
+			// the chunks being compared are dropped, and compared again after resuming from the checkpoint.
+			log.Warn("the comparison is interrupted, stop consuming the rest chunks", zap.Error(ctx.Err()))
+			df.report.SetInterrupted()
+			break
+		}
+		if c == nil {
+			// finish read the tables
+			break
+		}
+		log.Info("global consume chunk info", source.ChunkLogFields(df.workSource.GetTables()[c.GetTableIndex()], c)...)
+		tableDiff := df.workSource.GetTables()[c.GetTableIndex()]
+		// the dispatch waits for a chunk of the limited table to finish, so its chunks aren't buffered in memory.
+		slots := tableSlots[c.ProgressID]
+		if slots != nil && !acquireSlot(ctx, slots) {
+			log.Warn("the comparison is interrupted, stop consuming the rest chunks", zap.Error(ctx.Err()))
+			df.report.SetInterrupted()
+			break
+		}
+		if c.Chunking != nil {
+			df.report.SetTableChunking(tableDiff.Schema, tableDiff.Table, c.Chunking.Chunks, c.Chunking.SkewFactor)
+		}
+		df.report.StartChunk(tableDiff.Schema, tableDiff.Table, c.ChunkRange.IsLastChunkForTable())
+		if tableWriters != nil {
+			tableWriters.dispatch(c.GetTableIndex(), c.ChunkRange.IsLastChunkForTable())
+		}
+		var consumeChunk func(attempt int)
+		consumeChunk = func(attempt int) {
+			isEqual, recheck := df.consume(ctx, c, attempt)
+			if recheck {
+				df.scheduleRecheck(ctx, recheckPool, func() { consumeChunk(attempt + 1) })
+				return
+			}
+			df.report.FinishChunk(tableDiff.Schema, tableDiff.Table)
+			if !isEqual {
+				progress.FailTable(c.ProgressID)
+			}
+			progress.Inc(c.ProgressID)
+			if slots != nil {
+				<-slots
+			}
+		}
+		pool.Apply(func() { consumeChunk(0) })
+	}
+
+	return nil
+}
+
+// acquireSlot takes a slot of the chunks of a table being compared, which blocks until a slot is released. It returns
+// false if the context is canceled before a slot is taken.
+func acquireSlot(ctx context.Context, slots chan struct{}) bool {
+	select {
+	case slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// heartbeat logs the progress of the comparison every heartbeatInterval until stopCh is closed,
+// so that the long-running chunks aren't taken as hung.
+func (df *Diff) heartbeat(stopCh chan struct{}) {
+	ticker := time.NewTicker(df.heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			heartbeat := df.report.GetHeartbeat()
+			log.Info("heartbeat",
+				zap.Int("completed tables", heartbeat.CompletedTables),
+				zap.Int("total tables", heartbeat.TotalTables),
+				zap.Int("completed chunks", heartbeat.CompletedChunks),
+				zap.String("current table", heartbeat.CurrentTable),
+				zap.Int("rows add", heartbeat.RowsAdd),
+				zap.Int("rows delete", heartbeat.RowsDelete))
+		}
+	}
+}
+
+func (df *Diff) StructEqual(ctx context.Context) error {
+	df.compareViews()
+	tables := df.downstream.GetTables()
+	tableIndex := 0
+	if df.startRange != nil {
+		tableIndex = df.startRange.ChunkRange.Index.TableIndex
+	}
+	// the structures are checked concurrently, and the first error cancels the others.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		mu       sync.Mutex
+		firstErr error
+	)
+	results := make([][2]bool, len(tables))
+	// checked is whether the structure of the table is checked, the rest are left by fail-fast.
+	checked := make([]bool, len(tables))
+	progress.StartStructCheck(len(tables) - tableIndex)
+	pool := utils.NewWorkerPool(uint(df.structThreadCount), "struct checker")
+	for i := tableIndex; i < len(tables) && ctx.Err() == nil && !df.report.IsFailedFast(); i++ {
+		i := i
+		pool.Apply(func() {
+			isEqual, isSkip, err := df.compareStruct(ctx, i)
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = errors.Trace(err)
+					cancel()
+				}
+				mu.Unlock()
+				return
+			}
+			results[i] = [2]bool{isEqual, isSkip}
+			checked[i] = true
+			df.report.SetTableStructCheckResult(tables[i].Schema, tables[i].Table, isEqual, isSkip)
+			progress.IncStructCheck()
+		})
+	}
+	pool.WaitFinished()
+	if firstErr != nil {
+		return firstErr
+	}
+	if ctx.Err() != nil {
+		return errors.Trace(ctx.Err())
+	}
+	// the tables are registered in order, so they are compared and shown in the same order as before. The tables
+	// left by fail-fast are not compared, so they aren't registered as the structure failures.
+	for ; tableIndex < len(tables); tableIndex++ {
+		if !checked[tableIndex] {
+			continue
+		}
+		isEqual, isSkip := results[tableIndex][0], results[tableIndex][1]
+		progress.RegisterTable(dbutil.TableName(tables[tableIndex].Schema, tables[tableIndex].Table), !isEqual, isSkip)
+	}
+	return nil
+}
+
+func (df *Diff) compareStruct(ctx context.Context, tableIndex int) (isEqual bool, isSkip bool, err error) {
+	sourceTableInfos, err := df.upstream.GetSourceStructInfo(ctx, tableIndex)
+	if err != nil {
+		return false, true, errors.Trace(err)
+	}
+	table := df.downstream.GetTables()[tableIndex]
+	if table.StructCheckedShards > 0 {
+		df.report.SetTableStructCheckedShards(table.Schema, table.Table, table.StructCheckedShards, table.Shards)
+	}
+	if df.matchColumnsByName && utils.MatchColumnsByName(sourceTableInfos, table.Info) {
+		log.Info("the columns of the target are reordered in the order of the source", zap.String("table", dbutil.TableName(table.Schema, table.Table)))
+		df.report.SetTableColumnsReordered(table.Schema, table.Table)
+	}
+	if df.dataCheckOnStructMismatch {
+		isEqual, isSkip = utils.CompareStructByColumnSet(sourceTableInfos, table.Info)
+	} else {
+		isEqual, isSkip = utils.CompareStruct(sourceTableInfos, table.Info)
+	}
+	reorderedEnumColumns, isEnumEqual := utils.CompareEnumMembers(sourceTableInfos, table.Info)
+	if !isEnumEqual {
+		// the values out of the members on either side are reported by the data check, so the mismatch is non-breaking.
+		isEqual = false
+	}
+	if len(reorderedEnumColumns) > 0 {
+		table.ReorderedEnumColumns = reorderedEnumColumns
+		if df.compareEnumByValue {
+			log.Warn("the members of the ENUM and SET columns are in different orders, and they are compared by value",
+				zap.String("table", dbutil.TableName(table.Schema, table.Table)), zap.Strings("columns", reorderedEnumColumns))
+			df.report.SetTableReorderedEnumColumns(table.Schema, table.Table, reorderedEnumColumns)
+			if df.failOnEnumMemberOrder {
+				isEqual = false
+			}
+		} else {
+			// the same value is stored as the different indexes on both sides.
+			table.EnumByIndex = true
+			table.SourceEnumMembers = make(map[string][]string, len(reorderedEnumColumns))
+			for _, column := range reorderedEnumColumns {
+				if col := dbutil.FindColumnByName(sourceTableInfos[0].Columns, column); col != nil {
+					table.SourceEnumMembers[column] = col.Elems
+				}
+			}
+			isEqual = false
+		}
+	}
+	if len(df.charsetMap) > 0 {
+		df.setTranscodedColumns(table, sourceTableInfos)
+	}
+	if df.checkPartitionDefinition && !utils.ComparePartitions(sourceTableInfos, table.Info) {
+		// the rows can still be compared, so the partition mismatch is non-breaking.
+		log.Info("the partition definitions are different", zap.String("table", dbutil.TableName(table.Schema, table.Table)))
+		isEqual = false
+	}
+	if table.SplitByPartition && !utils.HasSamePartitionNames(sourceTableInfos, table.Info) {
+		log.Warn("the partitions of the source and the target are different, the table is not split by partition", zap.String("table", dbutil.TableName(table.Schema, table.Table)))
+		table.SplitByPartition = false
+	}
+	if !isEqual {
+		// the data check is skipped only for the breaking mismatch.
+		structDiff := report.StructDiffNonBreaking
+		if isSkip {
+			structDiff = report.StructDiffBreaking
+		}
+		df.report.SetTableStructDiff(table.Schema, table.Table, structDiff)
+		df.report.SetTableStructChanges(table.Schema, table.Table, utils.GetStructChanges(sourceTableInfos, table.Info))
+		if df.generateStructFix {
+			df.setTableStructFix(table, sourceTableInfos)
+		}
+	}
+	if df.ignoreDataCheck {
+		if !isEqual {
+			schemaDiff, err := utils.DiffCreateTable(table.Table, sourceTableInfos, table.Info)
+			if err != nil {
+				return false, true, errors.Trace(err)
+			}
+			df.report.SetTableSchemaDiff(table.Schema, table.Table, schemaDiff)
+		}
+		table.IgnoreDataCheck = true
+		return isEqual, true, nil
+	}
+	if !isSkip && !df.compareGeometry {
+		if columns := utils.GetGeometryColumns(table.Info); len(columns) > 0 {
+			df.report.SetTableSkipReason(table.Schema, table.Table, fmt.Sprintf("GEOMETRY columns %s, set compare-geometry to compare them", strings.Join(columns, ", ")))
+			isSkip = true
+		}
+	}
+	if !isSkip && table.NoPKFallback {
+		if reason := df.checkNoIndexTable(ctx, tableIndex); len(reason) > 0 {
+			df.report.SetTableSkipReason(table.Schema, table.Table, reason)
+			isSkip = true
+		}
+	}
+	if !isSkip {
+		if reason := df.checkTableSize(ctx, table); len(reason) > 0 {
+			df.report.SetTableSkipReason(table.Schema, table.Table, reason)
+			isSkip = true
+		}
+	}
+	if !isSkip {
+		df.setTableEstimatedRows(ctx, table)
+	}
+	table.IgnoreDataCheck = isSkip
+	return isEqual, isSkip, nil
+}
+
+func (df *Diff) startGCKeeperForTiDB(ctx context.Context, db *sql.DB, snap string) {
+	pdCli, _ := utils.GetPDClientForGC(ctx, db)
+	if pdCli != nil {
+		// Get latest snapshot
+		latestSnap, err := utils.GetSnapshot(ctx, db)
+		if err != nil {
+			log.Info("failed to get snapshot, user should guarantee the GC stopped during diff progress.")
+			return
+		}
+
+		if len(latestSnap) == 1 {
+			if len(snap) == 0 {
+				snap = latestSnap[0]
+			}
+			// compare the snapshot and choose the small one to lock
+			if strings.Compare(latestSnap[0], snap) < 0 {
+				snap = latestSnap[0]
+			}
+		}
+
+		err = utils.StartGCSavepointUpdateService(ctx, pdCli, db, snap)
+		if err != nil {
+			log.Info("failed to keep snapshot, user should guarantee the GC stopped during diff progress.")
+		} else {
+			log.Info("start update service to keep GC stopped automatically")
+		}
+	}
+}
+
+// pickSource pick one proper source to do some work. e.g. generate chunks
+func (df *Diff) pickSource(ctx context.Context) source.Source {
+	workSource := df.downstream
+	if ok, _ := dbutil.IsTiDB(ctx, df.upstream.GetDB()); ok {
+		log.Info("The upstream is TiDB. pick it as work source candidate")
+		df.startGCKeeperForTiDB(ctx, df.upstream.GetDB(), df.upstream.GetSnapshot())
+		workSource = df.upstream
+	}
+	if ok, _ := dbutil.IsTiDB(ctx, df.downstream.GetDB()); ok {
+		log.Info("The downstream is TiDB. pick it as work source first")
+		df.startGCKeeperForTiDB(ctx, df.downstream.GetDB(), df.downstream.GetSnapshot())
+		workSource = df.downstream
+	}
+	return workSource
+}
+
+func (df *Diff) generateChunksIterator(ctx context.Context) (source.RangeIterator, error) {
+	return df.workSource.GetRangeIterator(ctx, df.startRange, df.workSource.GetTableAnalyzer())
+}
+
+func (df *Diff) handleCheckpoints(ctx context.Context, stopCh chan struct{}) {
+	// a background goroutine which will insert the verified chunk,
+	// and periodically save checkpoint
+	log.Info("start handleCheckpoint goroutine")
+	defer func() {
+		log.Info("close handleCheckpoint goroutine")
+		df.checkpointWg.Done()
+	}()
+	flush := func() {
+		chunk := df.cp.GetChunkSnapshot()
+		if chunk != nil {
+			tableDiff := df.downstream.GetTables()[chunk.GetTableIndex()]
+			schema, table := tableDiff.Schema, tableDiff.Table
+			path := filepath.Join(df.CheckpointDir, checkpointFile)
+			var err error
+			// only the results mutated since the last flush are saved, and the full snapshot is saved periodically.
+			if df.cp.NeedFullSnapshot() {
+				var r *report.Report
+				r, err = df.report.GetSnapshot(chunk.GetID(), schema, table)
+				if err != nil {
+					log.Warn("fail to save the report", zap.Error(err))
+				}
+				_, err = df.cp.SaveChunk(ctx, path, chunk, r)
+			} else {
+				_, err = df.cp.SaveIncrement(ctx, path, chunk, df.report.GetIncrement(chunk.GetID(), schema, table))
+			}
+			if err != nil {
+				log.Warn("fail to save the chunk", zap.Error(err))
+				// maybe we should panic, because SaveChunk method should not failed.
+			}
+		}
+	}
+	defer flush()
+	// it's stopped by `stopCh` after all the chunks are consumed rather than `ctx.Done()`,
+	// so the chunks compared before canceling are saved.
+	for {
+		select {
+		case <-stopCh:
+			log.Info("Stop do checkpoint")
+			return
+		case <-time.After(10 * time.Second):
+			flush()
+		}
+	}
+}
+
+// consume compares the chunk, which is the `attempt`th recheck of the failed chunk if attempt > 0, and returns whether
+// the data is equal. recheck means the checksum is still different and the chunk should be rechecked later, then
+// nothing of the chunk is recorded until the last recheck.
+func (df *Diff) consume(ctx context.Context, rangeInfo *splitter.RangeInfo, attempt int) (bool, bool) {
+	dml := &ChunkDML{
+		node: rangeInfo.ToNode(),
+	}
+	interrupted, recheck := false, false
+	defer func() {
+		if interrupted {
+			// the chunk is not inserted into the checkpoint, so it will be compared again after resuming.
+			return
+		}
+		if recheck {
+			// the chunk is inserted after the last recheck.
+			return
+		}
+		df.sqlCh <- dml
+	}()
+	if attempt == 0 && df.report.IsFailedFast() {
+		// the chunk waits for a worker when fail-fast is triggered, it's left to the run resuming from the checkpoint.
+		interrupted = true
+		return true, false
+	}
+	if rangeInfo.ChunkRange.Type == chunk.Empty {
+		dml.node.State = checkpoints.IgnoreState
+		return true, false
+	}
+	tableDiff := df.downstream.GetTables()[rangeInfo.GetTableIndex()]
+	schema, table := tableDiff.Schema, tableDiff.Table
+	if df.isSampling() && !df.isChunkSampled(rangeInfo.ChunkRange.Index) {
+		dml.node.State = checkpoints.IgnoreState
+		df.report.AddSampledChunk(schema, table, false, false)
+		df.report.AddTableVerificationMethod(schema, table, report.VerificationSampled)
+		return true, false
+	}
+	if df.report.IsTableDiffLimitExceeded(schema, table) {
+		// the table has failed, so the rest of its chunks are neither compared nor fixed to bound the runtime.
+		dml.node.State = checkpoints.IgnoreState
+		df.report.AddTruncatedChunk(schema, table)
+		return true, false
+	}
+	var state string = checkpoints.SuccessState
+	logger := newChunkLogger(tableDiff, rangeInfo)
+
+	var isEqual bool
+	var count int64
+	var err error
+	// rowsCompared means the rows of the chunk are compared besides the checksum.
+	rowsCompared := false
+	if attempt == 0 {
+		isEqual, count, err = df.compareChecksumAndGetCount(ctx, df.upstream, df.downstream, rangeInfo)
+	} else {
+		isEqual, count, err = df.recheckChunk(ctx, rangeInfo, attempt, logger)
+	}
+	// the logger is created just before the checksum, so it's the time of the checksum query.
+	checksumDuration := time.Since(logger.start)
+	if err == nil && !isEqual && df.recheckFailedChunks && attempt < df.recheckTimes && ctx.Err() == nil {
+		logger.Debug("checksum failed, recheck the chunk later", zap.Duration("delay", df.recheckDelay), zap.Int("recheck", attempt+1))
+		recheck = true
+		return false, true
+	}
+	if err == nil && isEqual && tableDiff.NoPKFallback {
+		// the duplicate rows cancel out in the checksum by `BIT_XOR`, so the equal checksum is confirmed by the rows.
+		isEqual, err = df.compareRowsAsMultisets(ctx, rangeInfo, &ChunkDML{}, logger)
+		rowsCompared = err == nil
+	}
+	if err == nil && isEqual && len(tableDiff.CheckIndices) > 0 {
+		err = df.compareIndices(ctx, rangeInfo, logger)
+	}
+	if ctx.Err() != nil {
+		interrupted = true
+		return true, false
+	}
+	if err == nil {
+		df.report.SetChunkChecksumDuration(schema, table, rangeInfo.ChunkRange, checksumDuration)
+	}
+	if err != nil {
+		// If an error occurs during the checksum phase, skip the data compare phase.
+		state = checkpoints.FailedState
+		df.report.SetTableMeetError(schema, table, err, rangeInfo.ChunkRange.Index, rangeInfo.ChunkRange.BoundString())
+	} else if !isEqual && df.exportFixSQL {
+		logger.Debug("checksum failed", zap.Int64("chunk size", count))
+		state = checkpoints.FailedState
+		// if the chunk's checksum differ, try to do binary check
+		info := rangeInfo
+		if count > splitter.SplitThreshold {
+			logger.Debug("count greater than threshold, start do bingenerate", zap.Int64("chunk size", count))
+			info, err = df.BinGenerate(ctx, df.workSource, rangeInfo, count)
+			if ctx.Err() != nil {
+				interrupted = true
+				return true, false
+			}
+			if err != nil {
+				logger.Error("fail to do binary search.", zap.Error(err))
+				df.report.SetTableMeetError(schema, table, err, rangeInfo.ChunkRange.Index, rangeInfo.ChunkRange.BoundString())
+				// reuse rangeInfo to compare data
+				info = rangeInfo
+			} else {
+				logger.Debug("bin generate finished", zap.Reflect("chunk", info.ChunkRange))
+			}
+		}
+		isDataEqual, err := df.compareRows(ctx, info, dml, logger)
+		if ctx.Err() != nil {
+			interrupted = true
+			return true, false
+		}
+		if err != nil {
+			logger.Warn("fail to compare the rows", zap.Error(err))
+			df.report.SetTableMeetError(schema, table, err, info.ChunkRange.Index, info.ChunkRange.BoundString())
+		}
+		rowsCompared = err == nil
+		isEqual = isEqual && isDataEqual
+	}
+	dml.node.State = state
+	df.report.AddTableDuration(schema, table, time.Since(logger.start))
+	if count > 0 {
+		df.report.AddTableActualRows(schema, table, count)
+	}
+	id := rangeInfo.ChunkRange.Index
+	df.report.SetTableDataCheckResult(schema, table, isEqual, dml.rowAdd, dml.rowDelete, id)
+	if df.isSampling() {
+		df.report.AddSampledChunk(schema, table, true, !isEqual)
+	}
+	if err == nil {
+		if rowsCompared {
+			df.report.AddTableVerificationMethod(schema, table, report.VerificationRowByRow)
+		} else {
+			df.report.AddTableVerificationMethod(schema, table, report.VerificationChecksumOnly)
+		}
+	}
+	if !isEqual && len(rangeInfo.ChunkRange.Partition) > 0 {
+		df.report.SetChunkPartition(schema, table, id, rangeInfo.ChunkRange.Partition)
+	}
+	if !isEqual {
+		df.report.SetChunkBounds(schema, table, rangeInfo.ChunkRange)
+	}
+	logger.Debug("chunk compared", zap.Bool("equal", isEqual), zap.String("state", state), zap.Int("rows add", dml.rowAdd), zap.Int("rows delete", dml.rowDelete))
+	return isEqual, false
+}
+
+// recheckChunk compares the checksum of the failed chunk again with the same boundaries, which is the `attempt`th
+// recheck after recheck-delay, because the diff may be transient when the target is a lagging replica. The chunk
+// is counted as transient once it becomes equal, or confirmed if it's still different after the last recheck.
+func (df *Diff) recheckChunk(ctx context.Context, rangeInfo *splitter.RangeInfo, attempt int, logger *chunkLogger) (bool, int64, error) {
+	logger.attempt += attempt
+	upstream, downstream := df.getRecheckSources()
+	isEqual, count, err := df.compareChecksumAndGetCount(ctx, upstream, downstream, rangeInfo)
+	if err != nil {
+		return isEqual, count, errors.Trace(err)
+	}
+	if isEqual {
+		logger.Info("the diff of the chunk is transient", zap.Int("recheck", attempt))
+		df.report.AddRecheckedChunk(attempt, true)
+		return isEqual, count, nil
+	}
+	if attempt < df.recheckTimes {
+		return isEqual, count, nil
+	}
+	df.report.AddRecheckedChunk(attempt, false)
+	if upstream != df.upstream || downstream != df.downstream {
+		// the rows are compared at the snapshots, and so is the count to split the chunk.
+		isEqual, count, err = df.compareChecksumAndGetCount(ctx, df.upstream, df.downstream, rangeInfo)
+		return isEqual, count, errors.Trace(err)
+	}
+	return isEqual, count, nil
+}
+
+// initRecheckSources initializes the sources the failed chunks are rechecked on by recheck-snapshot. The rechecks
+// at the same snapshots can't become equal, so they read the latest data by the new connections with "latest".
+func (df *Diff) initRecheckSources(ctx context.Context, cfg *config.Config) error {
+	if cfg.RecheckSnapshot != config.RecheckSnapshotLatest {
+		hasSnapshot := len(cfg.Task.TargetInstance.Snapshot) > 0
+		for _, instance := range cfg.Task.SourceInstances {
+			hasSnapshot = hasSnapshot || len(instance.Snapshot) > 0
+		}
+		if hasSnapshot {
+			log.Warn("the failed chunks are rechecked at the snapshots, which can't become equal, set recheck-snapshot = \"latest\" to recheck the latest data")
+		}
+		return nil
+	}
+	var err error
+	if df.recheckUpstream, err = df.upstream.WithoutSnapshot(ctx); err != nil {
+		return errors.Annotate(err, "fail to connect the source without the snapshot")
+	}
+	if df.recheckDownstream, err = df.downstream.WithoutSnapshot(ctx); err != nil {
+		return errors.Annotate(err, "fail to connect the target without the snapshot")
+	}
+	return nil
+}
+
+// getRecheckSources returns the sources the failed chunks are rechecked on, see `recheckUpstream`.
+func (df *Diff) getRecheckSources() (source.Source, source.Source) {
+	upstream, downstream := df.upstream, df.downstream
+	if df.recheckUpstream != nil {
+		upstream = df.recheckUpstream
+	}
+	if df.recheckDownstream != nil {
+		downstream = df.recheckDownstream
+	}
+	return upstream, downstream
+}
+
+// scheduleRecheck runs recheck in the pool after recheck-delay, so the worker isn't occupied during the delay. It's
+// called by the task comparing the chunk, so all the rechecks are scheduled once the tasks of the chunks finish.
+func (df *Diff) scheduleRecheck(ctx context.Context, pool *utils.WorkerPool, recheck func()) {
+	df.recheckWg.Add(1)
+	go func() {
+		select {
+		case <-ctx.Done():
+			// the chunk is interrupted by rechecking it with the context canceled.
+		case <-time.After(df.recheckDelay):
+		}
+		pool.Apply(func() {
+			defer df.recheckWg.Done()
+			recheck()
+		})
+	}()
+}
+
+// chunkLogger logs the comparison of a chunk with the structured fields, so that the logs of
+// a chunk can be filtered, see `source.ChunkLogFields`. The `attempt` and `duration_ms` are appended.
+type chunkLogger struct {
+	logger *zap.Logger
+	fields []zap.Field
+	// attempt is the number of times the chunk is compared in this run.
+	attempt int
+	start   time.Time
+}
+
+func newChunkLogger(table *common.TableDiff, rangeInfo *splitter.RangeInfo) *chunkLogger {
+	return &chunkLogger{
+		// skip `chunkLogger.log` and the level method to get the caller.
+		logger:  log.L().WithOptions(zap.AddCallerSkip(2)),
+		fields:  source.ChunkLogFields(table, rangeInfo),
+		attempt: 1,
+		start:   time.Now(),
+	}
+}
+
+func (l *chunkLogger) log(level zapcore.Level, msg string, fields []zap.Field) {
+	ce := l.logger.Check(level, msg)
+	if ce == nil {
+		return
+	}
+	chunkFields := make([]zap.Field, 0, len(l.fields)+2+len(fields))
+	chunkFields = append(chunkFields, l.fields...)
+	chunkFields = append(chunkFields, zap.Int("attempt", l.attempt), zap.Int64("duration_ms", time.Since(l.start).Milliseconds()))
+	ce.Write(append(chunkFields, fields...)...)
+}
+
+func (l *chunkLogger) Debug(msg string, fields ...zap.Field) { l.log(zapcore.DebugLevel, msg, fields) }
+
+func (l *chunkLogger) Info(msg string, fields ...zap.Field) { l.log(zapcore.InfoLevel, msg, fields) }
+
+func (l *chunkLogger) Warn(msg string, fields ...zap.Field) { l.log(zapcore.WarnLevel, msg, fields) }
+
+func (l *chunkLogger) Error(msg string, fields ...zap.Field) { l.log(zapcore.ErrorLevel, msg, fields) }
+
+func (df *Diff) BinGenerate(ctx context.Context, targetSource source.Source, tableRange *splitter.RangeInfo, count int64) (*splitter.RangeInfo, error) {
+	if count <= splitter.SplitThreshold {
+		return tableRange, nil
+	}
+	if tableRange.ChunkRange.IsNullChunk() {
+		// the rows of the NULL chunk have the same value of the leading index column, which can't be split by the bounds.
+		return tableRange, nil
+	}
+	tableDiff := targetSource.GetTables()[tableRange.GetTableIndex()]
+	indices := dbutil.FindAllIndex(tableDiff.Info)
+	// if no index, do not split
+	if len(indices) == 0 {
+		log.Warn("cannot found an index to split and disable the BinGenerate",
+			zap.String("table", dbutil.TableName(tableDiff.Schema, tableDiff.Table)))
+		return tableRange, nil
+	}
+	var index *model.IndexInfo
+	// using the index
+	for _, i := range indices {
+		if tableRange.IndexID == i.ID {
+			index = i
+			break
+		}
+	}
+	if index == nil {
+		log.Warn("have indices but cannot found a proper index to split and disable the BinGenerate",
+			zap.String("table", dbutil.TableName(tableDiff.Schema, tableDiff.Table)))
+		return tableRange, nil
+	}
+	// TODO use selectivity from utils.GetBetterIndex
+	// only support PK/UK
+	if !(index.Primary || index.Unique) {
+		log.Warn("BinGenerate only support PK/UK")
+		return tableRange, nil
+	}
+
+	log.Debug("index for BinGenerate", zap.String("index", index.Name.O))
+	indexColumns := utils.GetColumnsFromIndex(index, tableDiff.Info)
+	if len(indexColumns) == 0 {
+		log.Warn("fail to get columns of the selected index, directly return the origin chunk")
+		return tableRange, nil
+	}
+
+	return df.binSearch(ctx, targetSource, tableRange, count, tableDiff, indexColumns)
+}
+
+func (df *Diff) binSearch(ctx context.Context, targetSource source.Source, tableRange *splitter.RangeInfo, count int64, tableDiff *common.TableDiff, indexColumns []*model.ColumnInfo) (*splitter.RangeInfo, error) {
+	if count <= splitter.SplitThreshold {
+		return tableRange, nil
+	}
+	var (
+		isEqual1, isEqual2 bool
+		count1, count2     int64
+	)
+	tableRange1 := tableRange.Copy()
+	tableRange2 := tableRange.Copy()
+
+	chunkLimits, args := tableRange.ChunkRange.ToString(tableDiff.Collation)
+	limitRange := fmt.Sprintf("(%s) AND (%s)", chunkLimits, tableDiff.Range)
+	midValues, err := utils.GetApproximateMidBySize(ctx, targetSource.GetDB(), tableDiff.Schema, tableDiff.Table, tableRange.ChunkRange.Partition, indexColumns, limitRange, args, count)
+	log.Debug("mid values", zap.Reflect("mid values", midValues), zap.Reflect("indices", indexColumns), zap.Reflect("bounds", tableRange.ChunkRange.Bounds))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	log.Debug("table ranges", zap.Reflect("original range", tableRange))
+	for i := range indexColumns {
+		log.Debug("update tableRange", zap.String("field", indexColumns[i].Name.O), zap.String("value", midValues[indexColumns[i].Name.O]))
+		tableRange1.Update(indexColumns[i].Name.O, "", midValues[indexColumns[i].Name.O], false, true, tableDiff.Collation, tableDiff.Range)
+		tableRange2.Update(indexColumns[i].Name.O, midValues[indexColumns[i].Name.O], "", true, false, tableDiff.Collation, tableDiff.Range)
+	}
+	log.Debug("table ranges", zap.Reflect("tableRange 1", tableRange1), zap.Reflect("tableRange 2", tableRange2))
+	isEqual1, count1, err = df.compareChecksumAndGetCount(ctx, df.upstream, df.downstream, tableRange1)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	isEqual2, count2, err = df.compareChecksumAndGetCount(ctx, df.upstream, df.downstream, tableRange2)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if count1+count2 != count {
+		// the rows are changed during the comparison, e.g. the snapshot isn't set.
+		return nil, errors.Errorf("the counts of the split chunks %d and %d don't add up to the count %d of the chunk", count1, count2, count)
+	}
+	log.Info("chunk split successfully",
+		zap.Any("chunk id", tableRange.ChunkRange.Index),
+		zap.Int64("count1", count1),
+		zap.Int64("count2", count2))
+
+	if !isEqual1 && !isEqual2 {
+		return tableRange, nil
+	} else if !isEqual1 {
+		c, err := df.binSearch(ctx, targetSource, tableRange1, count1, tableDiff, indexColumns)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return c, nil
+	} else if !isEqual2 {
+		c, err := df.binSearch(ctx, targetSource, tableRange2, count2, tableDiff, indexColumns)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return c, nil
+	} else {
+		return nil, errors.New("the checksums of both the split chunks are equal but the one of the chunk isn't")
+	}
+}
+
+func (df *Diff) compareChecksumAndGetCount(ctx context.Context, upstream, downstream source.Source, tableRange *splitter.RangeInfo) (bool, int64, error) {
+	var wg sync.WaitGroup
+	var upstreamInfo, downstreamInfo *source.ChecksumInfo
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		upstreamInfo = upstream.GetCountAndCrc32(ctx, tableRange)
+	}()
+	downstreamInfo = downstream.GetCountAndCrc32(ctx, tableRange)
+	wg.Wait()
+
+	if upstreamInfo.Err != nil {
+		log.Warn("failed to compare upstream checksum", source.ChunkLogFields(df.workSource.GetTables()[tableRange.GetTableIndex()], tableRange, zap.Error(upstreamInfo.Err))...)
+		return false, -1, errors.Trace(upstreamInfo.Err)
+	}
+	if downstreamInfo.Err != nil {
+		log.Warn("failed to compare downstream checksum", source.ChunkLogFields(df.workSource.GetTables()[tableRange.GetTableIndex()], tableRange, zap.Error(downstreamInfo.Err))...)
+		return false, -1, errors.Trace(downstreamInfo.Err)
+
+	}
+	// TODO two counts are not necessary equal
+	if upstreamInfo.Count == downstreamInfo.Count && upstreamInfo.Checksum == downstreamInfo.Checksum {
+		return true, upstreamInfo.Count, nil
+	}
+	return false, upstreamInfo.Count, nil
+}
+
+func (df *Diff) compareRows(ctx context.Context, rangeInfo *splitter.RangeInfo, dml *ChunkDML, logger *chunkLogger) (bool, error) {
+	if df.workSource.GetTables()[rangeInfo.GetTableIndex()].NoPKFallback {
+		return df.compareRowsAsMultisets(ctx, rangeInfo, dml, logger)
+	}
+	rowsAdd, rowsDelete := 0, 0
+	upstreamRowsIterator, err := df.upstream.GetRowsIterator(ctx, rangeInfo)
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	defer upstreamRowsIterator.Close()
+	upstreamRowsIterator = df.transcodeRows(upstreamRowsIterator, df.workSource.GetTables()[rangeInfo.GetTableIndex()])
+	downstreamRowsIterator, err := df.downstream.GetRowsIterator(ctx, rangeInfo)
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	defer downstreamRowsIterator.Close()
+
+	var lastUpstreamData, lastDownstreamData map[string]*dbutil.ColumnData
+	equal := true
+
+	tableInfo := df.workSource.GetTables()[rangeInfo.GetTableIndex()].Info
+	_, orderKeyCols := dbutil.SelectUniqueOrderKey(tableInfo)
+	for {
+		if err := ctx.Err(); err != nil {
+			return false, errors.Trace(err)
+		}
+		if lastUpstreamData == nil {
+			lastUpstreamData, err = upstreamRowsIterator.Next()
+			if err != nil {
+				return false, err
+			}
+		}
+
+		if lastDownstreamData == nil {
+			lastDownstreamData, err = downstreamRowsIterator.Next()
+			if err != nil {
+				return false, err
+			}
+		}
+
+		if lastUpstreamData == nil {
+			// don't have source data, so all the targetRows's data is redundant, should be deleted
+			for lastDownstreamData != nil {
+				sql, err := df.generateFixSQL(source.Delete, lastUpstreamData, lastDownstreamData, rangeInfo.GetTableIndex())
+				if err != nil {
+					return false, errors.Trace(err)
+				}
+				rowsDelete++
+				logger.Debug("[delete]", zap.String("sql", sql))
+
+				dml.sqls = append(dml.sqls, sql)
+				df.appendDiffRow(dml, diffRowSideTarget, lastDownstreamData)
+				df.appendRowDiff(dml, tableInfo, orderKeyCols, nil, lastDownstreamData)
+				equal = false
+				lastDownstreamData, err = downstreamRowsIterator.Next()
+				if err != nil {
+					return false, err
+				}
+			}
+			break
+		}
+
+		if lastDownstreamData == nil {
+			// target lack some data, should insert the last source datas
+			for lastUpstreamData != nil {
+				sql, err := df.generateFixSQL(source.Insert, lastUpstreamData, lastDownstreamData, rangeInfo.GetTableIndex())
+				if err != nil {
+					return false, errors.Trace(err)
+				}
+				rowsAdd++
+				logger.Debug("[insert]", zap.String("sql", sql))
+
+				dml.sqls = append(dml.sqls, sql)
+				df.appendDiffRow(dml, diffRowSideSource, lastUpstreamData)
+				df.appendRowDiff(dml, tableInfo, orderKeyCols, lastUpstreamData, nil)
+				equal = false
+
+				lastUpstreamData, err = upstreamRowsIterator.Next()
+				if err != nil {
+					return false, err
+				}
+			}
+			break
+		}
+
+		eq, cmp, err := utils.CompareData(lastUpstreamData, lastDownstreamData, orderKeyCols, tableInfo.Columns)
+		if err != nil {
+			return false, errors.Trace(err)
+		}
+		if eq {
+			lastDownstreamData = nil
+			lastUpstreamData = nil
+			continue
+		}
+
+		equal = false
+		var sql string
+
+		switch cmp {
+		case 1:
+			// delete
+			if sql, err = df.generateFixSQL(source.Delete, lastUpstreamData, lastDownstreamData, rangeInfo.GetTableIndex()); err != nil {
+				return false, errors.Trace(err)
+			}
+			rowsDelete++
+			logger.Debug("[delete]", zap.String("sql", sql))
+			df.appendDiffRow(dml, diffRowSideTarget, lastDownstreamData)
+			df.appendRowDiff(dml, tableInfo, orderKeyCols, nil, lastDownstreamData)
+			lastDownstreamData = nil
+		case -1:
+			// insert
+			if sql, err = df.generateFixSQL(source.Insert, lastUpstreamData, lastDownstreamData, rangeInfo.GetTableIndex()); err != nil {
+				return false, errors.Trace(err)
+			}
+			rowsAdd++
+			logger.Debug("[insert]", zap.String("sql", sql))
+			df.appendDiffRow(dml, diffRowSideSource, lastUpstreamData)
+			df.appendRowDiff(dml, tableInfo, orderKeyCols, lastUpstreamData, nil)
+			lastUpstreamData = nil
+		case 0:
+			// update
+			if columns := utils.GetSRIDMismatchColumns(lastUpstreamData, lastDownstreamData, tableInfo); len(columns) > 0 {
+				table := df.downstream.GetTables()[rangeInfo.GetTableIndex()]
+				df.report.AddTableSRIDMismatchColumns(table.Schema, table.Table, columns)
+			}
+			if sql, err = df.generateFixSQL(source.Replace, lastUpstreamData, lastDownstreamData, rangeInfo.GetTableIndex()); err != nil {
+				return false, errors.Trace(err)
+			}
+			rowsAdd++
+			rowsDelete++
+			logger.Debug("[update]", zap.String("sql", sql))
+			df.appendDiffRow(dml, diffRowSideSource, lastUpstreamData)
+			df.appendDiffRow(dml, diffRowSideTarget, lastDownstreamData)
+			df.appendRowDiff(dml, tableInfo, orderKeyCols, lastUpstreamData, lastDownstreamData)
+			lastUpstreamData = nil
+			lastDownstreamData = nil
+		}
+
+		dml.sqls = append(dml.sqls, sql)
+	}
+	if df.fixTarget == config.FixTargetSource {
+		// the rows added to the target are the rows deleted from the source.
+		rowsAdd, rowsDelete = rowsDelete, rowsAdd
+	}
+	dml.rowAdd = rowsAdd
+	dml.rowDelete = rowsDelete
+	return equal, nil
+}
+
+// generateFixSQL generates the fix sql on the fix target,
+// the `dmlType` is the operation needed to make the target match the source.
+func (df *Diff) generateFixSQL(dmlType source.DMLType, upstreamData, downstreamData map[string]*dbutil.ColumnData, tableIndex int) (string, error) {
+	if table := df.downstream.GetTables()[tableIndex]; table.EnumByIndex {
+		// the fix sql sets the ENUM and SET values rather than the indexes, which differ on both sides.
+		upstreamData = enumValueRow(upstreamData, table, table.SourceEnumMembers)
+		downstreamData = enumValueRow(downstreamData, table, nil)
+	}
+	if df.fixTarget != config.FixTargetSource {
+		return df.downstream.GenerateFixSQL(dmlType, upstreamData, downstreamData, tableIndex)
+	}
+	// make the source match the target, so the roles of source and target are reversed.
+	switch dmlType {
+	case source.Insert:
+		return df.upstream.GenerateFixSQL(source.Delete, downstreamData, upstreamData, tableIndex)
+	case source.Delete:
+		return df.upstream.GenerateFixSQL(source.Insert, downstreamData, upstreamData, tableIndex)
+	default:
+		return df.upstream.GenerateFixSQL(dmlType, downstreamData, upstreamData, tableIndex)
+	}
+}
+
+// enumValueRow returns the row whose indexes of the reordered ENUM and SET columns are converted back to the values
+// by the members, which are the ones of the target if `members` is nil. The row isn't modified, and the index is
+// kept if it can't be converted.
+func enumValueRow(data map[string]*dbutil.ColumnData, table *common.TableDiff, members map[string][]string) map[string]*dbutil.ColumnData {
+	if data == nil {
+		return nil
+	}
+	row := make(map[string]*dbutil.ColumnData, len(data))
+	for column, value := range data {
+		row[column] = value
+	}
+	for _, column := range table.ReorderedEnumColumns {
+		value, ok := data[column]
+		col := dbutil.FindColumnByName(table.Info.Columns, column)
+		if !ok || value.IsNull || col == nil {
+			continue
+		}
+		columnMembers := col.Elems
+		if members != nil {
+			columnMembers = members[column]
+		}
+		enumValue, err := utils.EnumIndexValue(col.Tp, columnMembers, string(value.Data))
+		if err != nil {
+			log.Warn("failed to convert the index of the ENUM or SET value in the fix sql", zap.String("table", dbutil.TableName(table.Schema, table.Table)),
+				zap.String("column", column), zap.Error(err))
+			continue
+		}
+		row[column] = &dbutil.ColumnData{Data: []byte(enumValue)}
+	}
+	return row
+}
+
+// WriteSQLs write sqls to file. The fix sql of the chunks with fix-file-layout = "table" is written by tableWriters.
+// Once writing fails, the error is recorded by setFixSQLErr and the rest chunks are drained without being written.
+func (df *Diff) writeSQLs(ctx context.Context, tableWriters *tableFixSQLWriters) {
+	log.Info("start writeSQLs goroutine")
+	defer func() {
+		log.Info("close writeSQLs goroutine")
+		df.sqlWg.Done()
+	}()
+	// it exits after `sqlCh` is closed rather than `ctx.Done()`, so the consumers never block on `sqlCh`.
+	for {
+		select {
+		case dml, ok := <-df.sqlCh:
+			if !ok && dml == nil {
+				log.Info("write sql channel closed")
+				if tableWriters != nil {
+					tableWriters.close()
+				}
+				if df.diffRowsExporter != nil {
+					if err := df.diffRowsExporter.close(); err != nil {
+						log.Warn("failed to close the files of the diff rows", zap.Error(err))
+					}
+				}
+				return
+			}
+			if df.getFixSQLErr() != nil {
+				// the chunk isn't inserted into the checkpoint, so it's compared again after resuming.
+				continue
+			}
+			if len(dml.diffRows) > 0 {
+				tableDiff := df.workSource.GetTables()[dml.node.GetTableIndex()]
+				if err := df.diffRowsExporter.export(tableDiff, dml.diffRows); err != nil {
+					// the diff rows are only for investigation, so the comparison goes on.
+					log.Warn("failed to export the diff rows", zap.String("table", dbutil.TableName(tableDiff.Schema, tableDiff.Table)), zap.Error(err))
+				}
+			}
+			if len(dml.rowDiffs) > 0 || dml.rowDiffsSuppressed > 0 {
+				tableDiff := df.workSource.GetTables()[dml.node.GetTableIndex()]
+				if err := df.rowDiffsExporter.export(tableDiff, dml.node.GetID(), dml.rowDiffs, dml.rowDiffsSuppressed); err != nil {
+					// the row diffs are only for investigation, so the comparison goes on.
+					log.Warn("failed to export the row diffs", zap.String("table", dbutil.TableName(tableDiff.Schema, tableDiff.Table)), zap.Error(err))
+				}
+			}
+			if tableWriters != nil {
+				// the node is inserted into the checkpoint by the writer of the table after the sql is written.
+				tableWriters.write(dml)
+				continue
+			}
+			if len(dml.sqls) > 0 {
+				if err := df.writeChunkFixSQL(dml); err != nil {
+					log.Error("write sql failed", zap.Any("chunk index", dml.node.GetID()), zap.Error(err))
+					df.setFixSQLErr(err)
+					continue
+				}
+			}
+			log.Debug("insert node", zap.Any("chunk index", dml.node.GetID()))
+			df.cp.Insert(dml.node)
+		}
+	}
+}
+
+// writeChunkFixSQL writes the fix sql of the chunk into its own files through fixSQLSink.
+func (df *Diff) writeChunkFixSQL(dml *ChunkDML) error {
+	tableDiff := df.downstream.GetTables()[dml.node.GetTableIndex()]
+	prefix := fixsql.TablePrefix(tableDiff.Schema, tableDiff.Table) + ":" + utils.GetSQLFileName(dml.node.GetID())
+	fileName := fixsql.FileName(prefix, 0, df.fixFileCompression)
+	if df.fixSQLFiles.Has(fileName) {
+		// unreachable
+		return errors.Errorf("the fix sql file %s is written repeatedly", fileName)
+	}
+	// write chunk meta, which is repeated in each rotated file.
+	writer := fixsql.NewWriter(df.fixSQLSink, prefix, df.fixSQLHeader(tableDiff, dml.node.ChunkRange), fixsql.WriterConfig{
+		MaxSize:     df.fixFileMaxSize,
+		Compression: df.fixFileCompression,
+		OnFileClosed: func(meta *fixsql.FileMeta) error {
+			df.fixSQLFiles.Add(meta)
+			if !df.isLocalFixSQLSink() {
+				return nil
+			}
+			// the completed files are recorded in the directory to drop the partially written ones when resuming.
+			return fixsql.AppendCompleted(df.FixSQLDir, meta.Name, meta.Size)
+		},
+	})
+	for _, sql := range dml.sqls {
+		if err := writer.WriteStatement(sql); err != nil {
+			writer.Abort()
+			return errors.Annotatef(err, "write the fix sql file %s", fileName)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return errors.Annotatef(err, "close the fix sql file %s", fileName)
+	}
+	df.report.AddFixSQLBytes(tableDiff.Schema, tableDiff.Table, dml.node.GetID(), writer.TotalBytes())
+	return nil
+}
+
+// setFixSQLErr records the first error writing the fix sql, see `fixSQLErr`.
+func (df *Diff) setFixSQLErr(err error) {
+	df.fixSQLErrMu.Lock()
+	defer df.fixSQLErrMu.Unlock()
+	if df.fixSQLErr == nil {
+		df.fixSQLErr = err
+	}
+}
+
+// getFixSQLErr returns the first error writing the fix sql, or nil if none.
+func (df *Diff) getFixSQLErr() error {
+	df.fixSQLErrMu.Lock()
+	defer df.fixSQLErrMu.Unlock()
+	return df.fixSQLErr
+}
+
+// isLocalFixSQLSink returns true if the fix sql files are written into `FixSQLDir`, which is false with a sink set
+// by SetFixSQLSink other than the local directory, e.g. the external storage.
+func (df *Diff) isLocalFixSQLSink() bool {
+	sink, ok := df.fixSQLSink.(*report.FileSink)
+	return ok && sink.Dir() == df.FixSQLDir
+}
+
+// fixSQLHeader returns the header of the fix sql of the chunk, with the table and the chunk meta.
+func (df *Diff) fixSQLHeader(tableDiff *common.TableDiff, chunkRange *chunk.Range) string {
+	header := fmt.Sprintf("-- table: %s.%s\n-- %s\n", escapeComment(tableDiff.Schema), escapeComment(tableDiff.Table), escapeComment(chunkRange.ToMeta()))
+	if tableDiff.NoPKFallback {
+		header += noIndexTableFixSQLWarning
+	}
+	if tableDiff.NeedUnifiedTimeZone {
+		header += fmt.Sprintf("set @@session.time_zone = \"%s\";\n", source.UnifiedTimeZone)
+	}
+	return header
+}
+
+// commentEscaper escapes the line breaks in the line comments of the fix sql.
+var commentEscaper = strings.NewReplacer("\r", `\r`, "\n", `\n`)
+
+// escapeComment escapes the line breaks of s in a line comment, e.g. in the names or the bounds of the chunk,
+// which would end the comment and make the rest of s a statement.
+func escapeComment(s string) string {
+	return commentEscaper.Replace(s)
+}
+
+// writeFixSQLManifest writes the manifest of the fix sql files after all the files are written. The files written
+// through a custom sink are listed by their meta recorded while being written, and only the ones of the run are listed,
+// because the files of the previous runs can't be listed from the sink.
+func (df *Diff) writeFixSQLManifest() {
+	if !df.isLocalFixSQLSink() {
+		manifest := df.fixSQLFiles.Manifest()
+		if err := fixsql.WriteManifestTo(df.fixSQLSink, manifest); err != nil {
+			log.Warn("failed to write the manifest of the fix sql files", zap.Error(err))
+			return
+		}
+		log.Info("write the manifest of the fix sql files", zap.Int("file count", len(manifest.Files)))
+		return
+	}
+	// the files of the previous runs and of fix-file-layout = "table" are read from the directory.
+	manifest, err := fixsql.GenerateManifest(df.FixSQLDir, &df.fixSQLFiles)
+	if err != nil {
+		log.Warn("failed to generate the manifest of the fix sql files", zap.Error(err))
+		return
+	}
+	if err = fixsql.WriteManifest(df.FixSQLDir, manifest); err != nil {
+		log.Warn("failed to write the manifest of the fix sql files", zap.Error(err))
+		return
+	}
+	log.Info("write the manifest of the fix sql files", zap.Int("file count", len(manifest.Files)))
+}
+
+func (df *Diff) removeSQLFiles(checkPointId *chunk.ChunkID) error {
+	if !df.isLocalFixSQLSink() {
+		// the files in a custom sink can't be listed, the ones of the chunks after the checkpoint are written again
+		// with the same names.
+		log.Info("the fix sql files are written into a custom sink, skip removing the files of the previous run")
+		return nil
+	}
+	ts := time.Now().Format("2006-01-02T15:04:05Z07:00")
+	dirName := fmt.Sprintf(".trash-%s", ts)
+	folderPath := filepath.Join(df.FixSQLDir, dirName)
+
+	if _, err := os.Stat(folderPath); os.IsNotExist(err) {
+		err = os.MkdirAll(folderPath, os.ModePerm)
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	// the files not recorded as completed are partially written by the interrupted run.
+	completedFiles, hasCompleted, err := fixsql.LoadCompleted(df.FixSQLDir)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if checkPointId.TableIndex < 0 && hasCompleted {
+		// start from beginning, all the files are moved to trash.
+		err = os.Rename(filepath.Join(df.FixSQLDir, fixsql.CompletedFile), filepath.Join(folderPath, fixsql.CompletedFile))
+		if err != nil {
+			return errors.Trace(err)
+		}
+		hasCompleted = false
+	}
+	// keptFiles are the completed files kept in the directory, the records of the files moved to trash are dropped,
+	// so the files written again after resuming are recorded only by themselves.
+	keptFiles := make(map[string]int64, len(completedFiles))
+
+	err = filepath.Walk(df.FixSQLDir, func(path string, f fs.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			// if path not exists, we should return nil to continue.
+			return nil
+		}
+		if err != nil {
+			return errors.Trace(err)
+		}
+
+		if f == nil || f.IsDir() {
+			return nil
+		}
+
+		name := f.Name()
+		// in mac osx, the path parameter is absolute path; in linux, the path is relative path to execution base dir,
+		// so use Rel to convert to relative path to l.base
+		relPath, _ := filepath.Rel(df.FixSQLDir, path)
+		oldPath := filepath.Join(df.FixSQLDir, relPath)
+		newPath := filepath.Join(folderPath, relPath)
+		if strings.Contains(oldPath, ".trash") {
+			return nil
+		}
+
+		if fixsql.IsTableFile(name) {
+			size := int64(-1)
+			if hasCompleted {
+				// the size is 0 if no chunk is completed.
+				size = completedFiles[relPath]
+			}
+			size, err := df.removeTableFixSQL(oldPath, newPath, checkPointId, size)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			if size > 0 {
+				keptFiles[relPath] = size
+			}
+			return nil
+		}
+		if fileIDStr, ok := fixsql.TrimExt(name); ok {
+			fileIDSubstrs := strings.SplitN(fileIDStr, ":", 3)
+			if len(fileIDSubstrs) != 3 {
+				return nil
+			}
+			tableIndex, bucketIndexLeft, bucketIndexRight, chunkIndex, err := utils.GetChunkIDFromSQLFileName(fileIDSubstrs[2])
+			if err != nil {
+				return errors.Trace(err)
+			}
+			fileID := &chunk.ChunkID{
+				TableIndex: tableIndex, BucketIndexLeft: bucketIndexLeft, BucketIndexRight: bucketIndexRight, ChunkIndex: chunkIndex, ChunkCnt: 0,
+			}
+			if err != nil {
+				return errors.Trace(err)
+			}
+			size, completed := completedFiles[relPath]
+			if hasCompleted && (!completed || size != f.Size()) {
+				log.Warn("the fix sql file is partially written, move it to trash", zap.String("file", relPath))
+				completed = false
+			}
+			if fileID.Compare(checkPointId) > 0 || (hasCompleted && !completed) {
+				// move to trash
+				err = os.Rename(oldPath, newPath)
+				if err != nil {
+					return errors.Trace(err)
+				}
+			} else if completed {
+				keptFiles[relPath] = size
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if hasCompleted {
+		return errors.Trace(fixsql.WriteCompleted(df.FixSQLDir, keptFiles))
+	}
+	return nil
+}
+
+func setTiDBCfg() {
+	// to support long index key in TiDB
+	tidbCfg := tidbconfig.GetGlobalConfig()
+	// 3027 * 4 is the max value the MaxIndexLength can be set
+	tidbCfg.MaxIndexLength = 3027 * 4
+	tidbconfig.StoreGlobalConfig(tidbCfg)
+
+	log.Info("set tidb cfg")
+}
@@ -0,0 +1,185 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/report"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+)
+
+const (
+	// diffRowsDir is the directory in output-dir where the diff rows are exported by export-diff-rows.
+	diffRowsDir = "diff-rows"
+	// diffRowNull is the value of NULL in the CSV files, like `LOAD DATA`.
+	diffRowNull = `\N`
+
+	// the sides of the diff rows, the updated row is exported on both sides.
+	diffRowSideSource = "source"
+	diffRowSideTarget = "target"
+)
+
+// diffRow is a row only on one side, which is exported to the CSV file of the table.
+type diffRow struct {
+	side string
+	data map[string]*dbutil.ColumnData
+}
+
+// diffRowsFile is the CSV file of the diff rows of a table.
+type diffRowsFile struct {
+	path   string
+	file   *os.File
+	writer *csv.Writer
+	// exported is the rows exported to the file, including the ones exported before resuming from the checkpoint.
+	exported int64
+}
+
+// diffRowsExporter exports the diff rows of each table to `<schema>.<table>.csv` in dir, with the column `side`
+// followed by the columns of the table. At most maxRows rows are exported of each table, 0 means no limit.
+// It's only used by the goroutine writing the fix sql, so it's not thread-safe.
+type diffRowsExporter struct {
+	dir     string
+	dirPerm os.FileMode
+	maxRows int64
+	report  *report.Report
+	files   map[string]*diffRowsFile
+}
+
+func newDiffRowsExporter(dir string, dirPerm os.FileMode, maxRows int64, r *report.Report) *diffRowsExporter {
+	return &diffRowsExporter{
+		dir:     dir,
+		dirPerm: dirPerm,
+		maxRows: maxRows,
+		report:  r,
+		files:   make(map[string]*diffRowsFile),
+	}
+}
+
+// removeFiles removes the files exported by the previous run, which is called when starting from the beginning.
+// The files are kept when resuming from the checkpoint, and the rows are appended to them.
+func (e *diffRowsExporter) removeFiles() error {
+	return errors.Trace(os.RemoveAll(e.dir))
+}
+
+// export appends the rows to the file of the table, the rows beyond maxRows are dropped.
+func (e *diffRowsExporter) export(table *common.TableDiff, rows []*diffRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	f, err := e.getFile(table)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if e.maxRows > 0 && f.exported+int64(len(rows)) > e.maxRows {
+		if f.exported >= e.maxRows {
+			return nil
+		}
+		rows = rows[:e.maxRows-f.exported]
+	}
+	record := make([]string, len(table.Info.Columns)+1)
+	for _, row := range rows {
+		record[0] = row.side
+		for i, col := range table.Info.Columns {
+			data, ok := row.data[col.Name.O]
+			if !ok || data.IsNull {
+				record[i+1] = diffRowNull
+			} else {
+				record[i+1] = string(data.Data)
+			}
+		}
+		if err := f.writer.Write(record); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	f.writer.Flush()
+	if err := f.writer.Error(); err != nil {
+		return errors.Trace(err)
+	}
+	f.exported += int64(len(rows))
+	e.report.SetTableDiffRowsFile(table.Schema, table.Table, f.path, f.exported)
+	return nil
+}
+
+// getFile returns the file of the table, which is opened for appending, and the header is written to the new file.
+func (e *diffRowsExporter) getFile(table *common.TableDiff) (*diffRowsFile, error) {
+	name := dbutil.TableName(table.Schema, table.Table)
+	if f, ok := e.files[name]; ok {
+		return f, nil
+	}
+	if err := os.MkdirAll(e.dir, e.dirPerm); err != nil {
+		return nil, errors.Trace(err)
+	}
+	path := filepath.Join(e.dir, fmt.Sprintf("%s.%s.csv", table.Schema, table.Table))
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, config.LocalFilePerm)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	f := &diffRowsFile{
+		path:     path,
+		file:     file,
+		writer:   csv.NewWriter(file),
+		exported: e.report.GetTableDiffRowsExported(table.Schema, table.Table),
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, errors.Trace(err)
+	}
+	if info.Size() == 0 {
+		header := make([]string, 0, len(table.Info.Columns)+1)
+		header = append(header, "side")
+		for _, col := range table.Info.Columns {
+			header = append(header, col.Name.O)
+		}
+		if err := f.writer.Write(header); err != nil {
+			file.Close()
+			return nil, errors.Trace(err)
+		}
+	}
+	e.files[name] = f
+	return f, nil
+}
+
+// close closes the files of all the tables.
+func (e *diffRowsExporter) close() error {
+	var firstErr error
+	for _, f := range e.files {
+		f.writer.Flush()
+		if err := f.writer.Error(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if err := f.file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	e.files = make(map[string]*diffRowsFile)
+	return errors.Trace(firstErr)
+}
+
+// appendDiffRow appends the row only on one side to the chunk if export-diff-rows is true, the rows of a chunk are
+// no more than max-export-rows, so that the rows of a chunk with too many diffs aren't held in memory.
+func (df *Diff) appendDiffRow(dml *ChunkDML, side string, data map[string]*dbutil.ColumnData) {
+	e := df.diffRowsExporter
+	if e == nil || (e.maxRows > 0 && int64(len(dml.diffRows)) >= e.maxRows) {
+		return
+	}
+	dml.diffRows = append(dml.diffRows, &diffRow{side: side, data: data})
+}
@@ -0,0 +1,87 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/chunk"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/splitter"
+	"github.com/pingcap/tidb/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportDiffRows(t *testing.T) {
+	tableInfo, err := dbutil.GetTableInfoBySQL("create table `test`.`t`(`a` int, `b` varchar(10), primary key(`a`))", parser.New())
+	require.NoError(t, err)
+	tables := []*common.TableDiff{{Schema: "test", Table: "t", Info: tableInfo}}
+	upstream := &mockRowsSource{mockSource: mockSource{tables: tables}, rows: [][]string{{"1", "a"}, {"2", "b"}, {"3", "c"}}}
+	downstream := &mockRowsSource{mockSource: mockSource{tables: tables}, rows: [][]string{{"1", "a"}, {"2", "x"}, {"4", "d"}}}
+	df := newTestDiff(t, upstream, downstream)
+	dir, r := df.FixSQLDir, df.report
+	df.diffRowsExporter = newDiffRowsExporter(filepath.Join(dir, diffRowsDir), config.LocalDirPerm, 0, r)
+	c := chunk.NewChunkRange()
+	c.Index = &chunk.ChunkID{TableIndex: 0, ChunkCnt: 1}
+	rangeInfo := &splitter.RangeInfo{ChunkRange: c}
+
+	// the updated row is exported on both sides.
+	dml := &ChunkDML{}
+	isEqual, err := df.compareRows(context.Background(), rangeInfo, dml, newChunkLogger(tables[0], rangeInfo))
+	require.NoError(t, err)
+	require.False(t, isEqual)
+	require.Len(t, dml.diffRows, 4)
+	require.NoError(t, df.diffRowsExporter.export(tables[0], dml.diffRows))
+	require.NoError(t, df.diffRowsExporter.export(tables[0], []*diffRow{
+		{side: diffRowSideSource, data: map[string]*dbutil.ColumnData{"a": {Data: []byte("5")}, "b": {IsNull: true}}},
+	}))
+	require.NoError(t, df.diffRowsExporter.close())
+
+	path := filepath.Join(dir, diffRowsDir, "test.t.csv")
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "side,a,b\nsource,2,b\ntarget,2,x\nsource,3,c\ntarget,4,d\nsource,5,\\N\n", string(data))
+	require.Equal(t, int64(5), r.GetTableDiffRowsExported("test", "t"))
+	require.Equal(t, path, r.TableResults["test"]["t"].DiffRowsFile)
+
+	// the rows of a chunk are capped by max-export-rows, and so are the rows of the table after resuming.
+	df.diffRowsExporter = newDiffRowsExporter(filepath.Join(dir, diffRowsDir), config.LocalDirPerm, 6, r)
+	dml = &ChunkDML{}
+	_, err = df.compareRows(context.Background(), rangeInfo, dml, newChunkLogger(tables[0], rangeInfo))
+	require.NoError(t, err)
+	require.Len(t, dml.diffRows, 4)
+	require.NoError(t, df.diffRowsExporter.export(tables[0], dml.diffRows))
+	require.NoError(t, df.diffRowsExporter.export(tables[0], dml.diffRows))
+	require.NoError(t, df.diffRowsExporter.close())
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "side,a,b\nsource,2,b\ntarget,2,x\nsource,3,c\ntarget,4,d\nsource,5,\\N\nsource,2,b\n", string(data))
+	require.Equal(t, int64(6), r.GetTableDiffRowsExported("test", "t"))
+
+	df.diffRowsExporter = newDiffRowsExporter(filepath.Join(dir, diffRowsDir), config.LocalDirPerm, 2, r)
+	dml = &ChunkDML{}
+	_, err = df.compareRows(context.Background(), rangeInfo, dml, newChunkLogger(tables[0], rangeInfo))
+	require.NoError(t, err)
+	require.Len(t, dml.diffRows, 2)
+
+	// the files are removed when starting from the beginning.
+	require.NoError(t, df.diffRowsExporter.removeFiles())
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+}
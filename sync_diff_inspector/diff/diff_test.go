@@ -0,0 +1,1014 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/checkpoints"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/chunk"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/fixsql"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/progress"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/report"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/splitter"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/utils"
+	"github.com/pingcap/tidb/parser"
+	"github.com/pingcap/tidb/parser/model"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+const mockChunkCnt = 100
+
+// mockSource is a source whose checksum of the chunks from `blockFrom` blocks until the context is canceled.
+type mockSource struct {
+	source.Source
+
+	tables    []*common.TableDiff
+	blockFrom int
+	blockedCh chan struct{}
+
+	// the number of chunks being compared and the max of it.
+	inflight    int32
+	maxInflight int32
+
+	// diffs is the chunk index => the times the checksum of the chunk is different, -1 means always, and started is
+	// the indexes of the chunks in the order their checksums start.
+	mu      sync.Mutex
+	diffs   map[int]int
+	started []int
+
+	db          *sql.DB
+	structInfos []*model.TableInfo
+	// structDelay is the latency to get the structures, and structErrs is the table index => the error to get them.
+	structDelay time.Duration
+	structErrs  map[int]error
+	// counts is the row count of each table.
+	counts []int64
+	// duplicateKeys is the table index => the duplicate keys of the table, and keyErrs is the table index => the error
+	// to get them.
+	duplicateKeys map[int][]*utils.DuplicateKey
+	keyErrs       map[int]error
+	// indexChecksums is the index name => the checksum of the index in each chunk, and indexErr is the error to get it.
+	indexChecksums map[string]int64
+	indexErr       error
+	// adminChecksums is the table index => the result of `ADMIN CHECKSUM TABLE`, and adminChecksumErr is the error
+	// to get it.
+	adminChecksums   map[int]*utils.TableChecksum
+	adminChecksumErr error
+}
+
+func (s *mockSource) GetTables() []*common.TableDiff { return s.tables }
+
+func (s *mockSource) GetTableAnalyzer() source.TableAnalyzer { return nil }
+
+func (s *mockSource) GetRangeIterator(context.Context, *splitter.RangeInfo, source.TableAnalyzer) (source.RangeIterator, error) {
+	return &mockRangeIterator{tables: s.tables[:1]}, nil
+}
+
+func (s *mockSource) GetCountAndCrc32(ctx context.Context, r *splitter.RangeInfo) *source.ChecksumInfo {
+	if r.GetChunkIndex() >= s.blockFrom {
+		s.blockedCh <- struct{}{}
+		<-ctx.Done()
+		return &source.ChecksumInfo{Err: ctx.Err()}
+	}
+	inflight := atomic.AddInt32(&s.inflight, 1)
+	defer atomic.AddInt32(&s.inflight, -1)
+	for {
+		maxInflight := atomic.LoadInt32(&s.maxInflight)
+		if inflight <= maxInflight || atomic.CompareAndSwapInt32(&s.maxInflight, maxInflight, inflight) {
+			break
+		}
+	}
+	time.Sleep(time.Millisecond)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.started = append(s.started, r.GetChunkIndex())
+	if times := s.diffs[r.GetChunkIndex()]; times != 0 {
+		s.diffs[r.GetChunkIndex()] = times - 1
+		return &source.ChecksumInfo{Count: 1, Checksum: 2}
+	}
+	return &source.ChecksumInfo{Count: 1, Checksum: 1}
+}
+
+func (s *mockSource) GetDB() *sql.DB { return s.db }
+
+func (s *mockSource) GetCount(_ context.Context, tableIndex int) (int64, error) {
+	return s.counts[tableIndex], nil
+}
+
+func (s *mockSource) GetDuplicateKeys(_ context.Context, tableIndex int, limit int) ([]*utils.DuplicateKey, error) {
+	if err := s.keyErrs[tableIndex]; err != nil {
+		return nil, err
+	}
+	return s.duplicateKeys[tableIndex], nil
+}
+
+func (s *mockSource) GetAdminChecksum(_ context.Context, tableIndex int) (*utils.TableChecksum, error) {
+	if s.adminChecksumErr != nil {
+		return nil, s.adminChecksumErr
+	}
+	return s.adminChecksums[tableIndex], nil
+}
+
+func (s *mockSource) GetIndexCountAndCrc32(_ context.Context, _ *splitter.RangeInfo, index *model.IndexInfo) *source.ChecksumInfo {
+	return &source.ChecksumInfo{Count: 1, Checksum: s.indexChecksums[index.Name.O], Err: s.indexErr}
+}
+
+func (s *mockSource) GetSourceStructInfo(_ context.Context, tableIndex int) ([]*model.TableInfo, error) {
+	if s.structDelay > 0 {
+		inflight := atomic.AddInt32(&s.inflight, 1)
+		defer atomic.AddInt32(&s.inflight, -1)
+		for {
+			maxInflight := atomic.LoadInt32(&s.maxInflight)
+			if inflight <= maxInflight || atomic.CompareAndSwapInt32(&s.maxInflight, maxInflight, inflight) {
+				break
+			}
+		}
+		time.Sleep(s.structDelay)
+	}
+	if err := s.structErrs[tableIndex]; err != nil {
+		return nil, err
+	}
+	return s.structInfos, nil
+}
+
+func (s *mockSource) Close() {}
+
+type mockRangeIterator struct {
+	tables []*common.TableDiff
+	next   int
+}
+
+func (it *mockRangeIterator) Next(ctx context.Context) (*splitter.RangeInfo, error) {
+	if it.next >= mockChunkCnt*len(it.tables) {
+		return nil, nil
+	}
+	tableIndex, chunkIndex := it.next/mockChunkCnt, it.next%mockChunkCnt
+	c := chunk.NewChunkRange()
+	c.Type = chunk.Others
+	c.IsFirst = chunkIndex == 0
+	c.IsLast = chunkIndex == mockChunkCnt-1
+	c.Index = &chunk.ChunkID{TableIndex: tableIndex, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: chunkIndex, ChunkCnt: mockChunkCnt}
+	it.next++
+	table := it.tables[tableIndex]
+	return &splitter.RangeInfo{ChunkRange: c, ProgressID: dbutil.TableName(table.Schema, table.Table)}, nil
+}
+
+func (it *mockRangeIterator) Close() {}
+
+// newTestDiff returns a Diff comparing the tables of downstream, whose report, checkpoint and fix sql are written into
+// a temporary directory. The options set the rest fields before the report is initialized by the tables.
+func newTestDiff(t testing.TB, upstream, downstream source.Source, opts ...func(*Diff)) *Diff {
+	dir := t.TempDir()
+	df := &Diff{
+		upstream:          upstream,
+		downstream:        downstream,
+		workSource:        downstream,
+		checkThreadCount:  1,
+		structThreadCount: 1,
+		sqlCh:             make(chan *ChunkDML, splitter.DefaultChannelBuffer),
+		cp:                new(checkpoints.Checkpoint),
+		report:            report.NewReport(&config.TaskConfig{OutputDir: dir}),
+		progressOutput:    io.Discard,
+		FixSQLDir:         dir,
+		CheckpointDir:     dir,
+		fixSQLSink:        report.NewFileSink(dir),
+	}
+	for _, opt := range opts {
+		opt(df)
+	}
+	df.cp.Init()
+	if df.downstream != nil {
+		df.report.Init(df.downstream.GetTables(), nil, nil)
+	}
+	return df
+}
+
+func TestEqualCancel(t *testing.T) {
+	tables := []*common.TableDiff{{Schema: "test", Table: "t"}}
+	upstream := &mockSource{tables: tables, blockFrom: mockChunkCnt}
+	downstream := &mockSource{tables: tables, blockFrom: 5, blockedCh: make(chan struct{}, 2)}
+	df := newTestDiff(t, upstream, downstream, func(df *Diff) {
+		df.checkThreadCount = 2
+		df.exportFixSQL = true
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- df.Equal(ctx)
+	}()
+	// both the workers are blocked, so the chunks before are all compared.
+	<-downstream.blockedCh
+	<-downstream.blockedCh
+	cancel()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the comparison doesn't exit after canceling")
+	}
+
+	require.True(t, df.report.IsInterrupted())
+	require.Nil(t, df.report.TableResults["test"]["t"].MeetError)
+	require.True(t, df.report.TableResults["test"]["t"].DataEqual)
+
+	// the checkpoint is flushed to the last compared chunk, and kept after closing.
+	df.close()
+	node, _, err := df.cp.LoadChunk(filepath.Join(df.CheckpointDir, checkpointFile))
+	require.NoError(t, err)
+	require.Equal(t, 4, node.GetChunkIndex())
+}
+
+func TestCompareTimeout(t *testing.T) {
+	info, err := dbutil.GetTableInfoBySQL("create table `test`.`t`(`a` int, `b` int, primary key(`a`))", parser.New())
+	require.NoError(t, err)
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	mock.ExpectQuery("sum\\(data_length\\)").WillReturnRows(sqlmock.NewRows([]string{"table_name", "data"}).AddRow("t", 16384))
+	tables := []*common.TableDiff{{Schema: "test", Table: "t", Info: info}}
+	upstream := &mockSource{tables: tables, blockFrom: mockChunkCnt, structInfos: []*model.TableInfo{info}}
+	downstream := &mockSource{tables: tables, blockFrom: 5, blockedCh: make(chan struct{}, mockChunkCnt), db: db}
+	df := newTestDiff(t, upstream, downstream, func(df *Diff) {
+		df.checkThreadCount = 2
+		df.tableSizes = utils.NewTableSizeCache(db)
+		df.runTimeout = 100 * time.Millisecond
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), df.runTimeout)
+	defer cancel()
+	r, err := df.compare(ctx)
+	// the report of the partial results is returned with the timeout, which is neither pass nor fail.
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.True(t, r.IsInterrupted())
+	require.True(t, r.TimedOut)
+	require.Equal(t, df.runTimeout, r.RunTimeout)
+	require.True(t, r.TableResults["test"]["t"].DataEqual)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// the checkpoint is kept to resume.
+	df.close()
+	node, _, err := df.cp.LoadChunk(filepath.Join(df.CheckpointDir, checkpointFile))
+	require.NoError(t, err)
+	require.Equal(t, 4, node.GetChunkIndex())
+}
+
+func TestCompareFailFast(t *testing.T) {
+	info, err := dbutil.GetTableInfoBySQL("create table `test`.`t`(`a` int, `b` int, primary key(`a`))", parser.New())
+	require.NoError(t, err)
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	mock.ExpectQuery("sum\\(data_length\\)").WillReturnRows(sqlmock.NewRows([]string{"table_name", "data"}).AddRow("t", 16384))
+	tables := []*common.TableDiff{{Schema: "test", Table: "t", Info: info}}
+	upstream := &mockSource{tables: tables, blockFrom: mockChunkCnt, structInfos: []*model.TableInfo{info}}
+	downstream := &mockSource{tables: tables, blockFrom: mockChunkCnt, diffs: map[int]int{3: -1}, db: db}
+	// one worker makes the chunks started before the trigger deterministic.
+	df := newTestDiff(t, upstream, downstream, func(df *Diff) {
+		df.failFast = true
+		df.tableSizes = utils.NewTableSizeCache(db)
+	})
+
+	// the comparison stops after the different chunk without an error, and the verdict is fail.
+	r, err := df.compare(context.Background())
+	require.NoError(t, err)
+	require.True(t, r.FailedFast)
+	require.Equal(t, "`test`.`t`", r.FailedFastTable)
+	require.False(t, r.IsInterrupted())
+	require.Equal(t, report.Fail, r.Result)
+	result := r.TableResults["test"]["t"]
+	require.False(t, result.DataEqual)
+	require.Len(t, result.ChunkMap, 4)
+	// no chunk starts after the different chunk, including the one waiting for the worker then.
+	require.Equal(t, []int{0, 1, 2, 3}, downstream.started)
+	require.NoError(t, mock.ExpectationsWereMet())
+	summary, err := os.ReadFile(filepath.Join(df.CheckpointDir, "summary.txt"))
+	require.NoError(t, err)
+	require.Contains(t, string(summary), "The comparison is stopped early by fail-fast after the table `test`.`t` is found different")
+
+	// the checkpoint is kept to resume from the different chunk.
+	df.close()
+	node, _, err := df.cp.LoadChunk(filepath.Join(df.CheckpointDir, checkpointFile))
+	require.NoError(t, err)
+	require.Equal(t, 3, node.GetChunkIndex())
+}
+
+// failingSink fails to create any file.
+type failingSink struct{}
+
+func (failingSink) Create(string) (io.WriteCloser, error) { return nil, errors.New("disk full") }
+
+func TestCompareFixSQLWriteFailure(t *testing.T) {
+	info, err := dbutil.GetTableInfoBySQL("create table `test`.`t`(`a` int, `b` varchar(10), primary key(`a`))", parser.New())
+	require.NoError(t, err)
+	tables := []*common.TableDiff{{Schema: "test", Table: "t", Info: info}}
+	upstream := &mockRowsSource{mockSource: mockSource{tables: tables, blockFrom: mockChunkCnt, structInfos: []*model.TableInfo{info}}, rows: [][]string{{"1", "a"}}}
+	downstream := &mockRowsSource{mockSource: mockSource{tables: tables, blockFrom: mockChunkCnt, diffs: map[int]int{3: -1}}, rows: [][]string{{"2", "b"}}}
+	df := newTestDiff(t, upstream, downstream, func(df *Diff) {
+		df.exportFixSQL = true
+		df.fixTarget = config.FixTargetTarget
+		df.fixSQLSink = failingSink{}
+	})
+
+	// the error writing the fix sql is returned rather than exiting the process.
+	r, err := df.compare(context.Background())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "disk full")
+	require.Nil(t, r)
+
+	// the checkpoint is kept before the chunk failing to write, which is compared again after resuming.
+	df.close()
+	node, _, err := df.cp.LoadChunk(filepath.Join(df.CheckpointDir, checkpointFile))
+	require.NoError(t, err)
+	require.Equal(t, 2, node.GetChunkIndex())
+}
+
+func TestPause(t *testing.T) {
+	tables := []*common.TableDiff{{Schema: "test", Table: "t"}}
+	upstream := &mockSource{tables: tables, blockFrom: mockChunkCnt}
+	downstream := &mockSource{tables: tables, blockFrom: mockChunkCnt}
+	df := newTestDiff(t, upstream, downstream, func(df *Diff) { df.checkThreadCount = 2 })
+
+	df.Pause()
+	df.Pause()
+	require.True(t, df.IsPaused())
+	done := make(chan error, 1)
+	go func() {
+		done <- df.Equal(context.Background())
+	}()
+	// no chunk is compared while paused.
+	time.Sleep(100 * time.Millisecond)
+	require.Zero(t, df.report.GetHeartbeat().CompletedChunks)
+	df.Resume()
+	df.Resume()
+	require.False(t, df.IsPaused())
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the comparison doesn't continue after resuming")
+	}
+	require.Equal(t, mockChunkCnt, df.report.GetHeartbeat().CompletedChunks)
+}
+
+func TestEqualTableConcurrency(t *testing.T) {
+	tables := []*common.TableDiff{{Schema: "test", Table: "t"}}
+	newDiff := func(upstream, downstream *mockSource, checkThreadCount int) *Diff {
+		return newTestDiff(t, upstream, downstream, func(df *Diff) { df.checkThreadCount = checkThreadCount })
+	}
+
+	// the concurrency of the table is limited.
+	tables[0].Concurrency = 2
+	upstream := &mockSource{tables: tables, blockFrom: mockChunkCnt}
+	downstream := &mockSource{tables: tables, blockFrom: mockChunkCnt}
+	df := newDiff(upstream, downstream, 8)
+	require.Equal(t, 2, df.getTableConcurrency(tables[0]))
+	require.NoError(t, df.Equal(context.Background()))
+	require.LessOrEqual(t, atomic.LoadInt32(&downstream.maxInflight), int32(2))
+	require.True(t, df.report.TableResults["test"]["t"].DataEqual)
+
+	// the concurrency can't exceed check-thread-count.
+	tables[0].Concurrency = 16
+	df = newDiff(upstream, downstream, 8)
+	require.Equal(t, 8, df.getTableConcurrency(tables[0]))
+	tables[0].Concurrency = 0
+	require.Equal(t, 8, df.getTableConcurrency(tables[0]))
+}
+
+func TestTableConcurrencyWithRechecks(t *testing.T) {
+	tables := []*common.TableDiff{{Schema: "test", Table: "t", Concurrency: 2}}
+	upstream := &mockSource{tables: tables, blockFrom: mockChunkCnt}
+	// the diffs of all the chunks are transient, so each chunk is compared twice.
+	diffs := make(map[int]int, mockChunkCnt)
+	for i := 0; i < mockChunkCnt; i++ {
+		diffs[i] = 1
+	}
+	downstream := &mockSource{tables: tables, blockFrom: mockChunkCnt, diffs: diffs}
+	df := newTestDiff(t, upstream, downstream, func(df *Diff) {
+		df.checkThreadCount = 8
+		df.recheckFailedChunks = true
+		df.recheckDelay = 5 * time.Millisecond
+		df.recheckTimes = 1
+	})
+	require.NoError(t, df.Equal(context.Background()))
+	require.True(t, df.report.TableResults["test"]["t"].DataEqual)
+	require.Equal(t, int64(mockChunkCnt), df.report.TransientChunks)
+
+	// a chunk is in flight from its first checksum to its recheck, and the recheck keeps the slot of the table.
+	require.Len(t, downstream.started, 2*mockChunkCnt)
+	first, last := make(map[int]int), make(map[int]int)
+	for i, chunk := range downstream.started {
+		if _, ok := first[chunk]; !ok {
+			first[chunk] = i
+		}
+		last[chunk] = i
+	}
+	inflight, maxInflight := 0, 0
+	for i, chunk := range downstream.started {
+		if first[chunk] == i {
+			inflight++
+			if inflight > maxInflight {
+				maxInflight = inflight
+			}
+		}
+		if last[chunk] == i {
+			inflight--
+		}
+	}
+	require.LessOrEqual(t, maxInflight, 2)
+}
+
+func TestHeartbeat(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	defer log.ReplaceGlobals(zap.New(core), &log.ZapProperties{Core: core, Level: zap.NewAtomicLevelAt(zapcore.InfoLevel)})()
+
+	tables := []*common.TableDiff{{Schema: "test", Table: "t"}}
+	upstream := &mockSource{tables: tables, blockFrom: mockChunkCnt}
+	downstream := &mockSource{tables: tables, blockFrom: mockChunkCnt}
+	df := newTestDiff(t, upstream, downstream, func(df *Diff) {
+		df.checkThreadCount = 2
+		df.heartbeatInterval = time.Millisecond
+	})
+	require.NoError(t, df.Equal(context.Background()))
+
+	entries := logs.FilterMessage("heartbeat").All()
+	require.NotEmpty(t, entries)
+	fields := entries[0].ContextMap()
+	require.Equal(t, int64(1), fields["total tables"])
+	require.Equal(t, "`test`.`t`", fields["current table"])
+	heartbeat := df.report.GetHeartbeat()
+	require.Equal(t, 1, heartbeat.CompletedTables)
+	require.Equal(t, mockChunkCnt, heartbeat.CompletedChunks)
+
+	// the heartbeat stops after the comparison.
+	time.Sleep(10 * time.Millisecond)
+	count := logs.FilterMessage("heartbeat").Len()
+	time.Sleep(20 * time.Millisecond)
+	require.Equal(t, count, logs.FilterMessage("heartbeat").Len())
+}
+
+func TestSampleConnPools(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	defer log.ReplaceGlobals(zap.New(core), &log.ZapProperties{Core: core, Level: zap.NewAtomicLevelAt(zapcore.WarnLevel)})()
+
+	db, _, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	ctx := context.Background()
+	// the pool is used before the sampling starts, which isn't counted.
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		conn.Close()
+	}()
+	conn, err = db.Conn(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), db.Stats().WaitCount)
+
+	df := newTestDiff(t, nil, nil, func(df *Diff) {
+		df.connPools = newConnPools(&config.Config{Task: config.TaskConfig{
+			SourceInstances: []*config.DataSource{{}},
+			TargetInstance:  &config.DataSource{Conn: db},
+		}})
+	})
+	stopSampling := df.startSamplingConnPools()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		conn.Close()
+	}()
+	conn, err = db.Conn(ctx)
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+	stopSampling()
+	stopSampling()
+
+	stats := df.report.ConnPoolStats
+	require.Len(t, stats, 1)
+	require.Equal(t, "Target Database", stats[0].Name)
+	require.Equal(t, 1, stats[0].MaxOpenConnections)
+	require.Equal(t, 1, stats[0].PeakInUse)
+	require.Equal(t, int64(1), stats[0].WaitCount)
+	require.GreaterOrEqual(t, stats[0].WaitDuration, 40*time.Millisecond)
+	require.Equal(t, 1, logs.FilterMessage("the comparison waits long for the connections, consider raising max-open-conns").Len())
+}
+
+func TestChunkLog(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	defer log.ReplaceGlobals(zap.New(core), &log.ZapProperties{Core: core, Level: zap.NewAtomicLevelAt(zapcore.DebugLevel)})()
+
+	tables := []*common.TableDiff{{Schema: "test", Table: "t"}}
+	logger := newChunkLogger(tables[0], &splitter.RangeInfo{ChunkRange: &chunk.Range{
+		Index:  &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 1, BucketIndexRight: 2, ChunkIndex: 3, ChunkCnt: 4},
+		Bounds: []*chunk.Bound{{Column: "a", Lower: "1", Upper: "10", HasLower: true, HasUpper: true}},
+	}})
+	logger.Debug("chunk compared", zap.Bool("equal", true))
+	entries := logs.FilterMessage("chunk compared").All()
+	require.Len(t, entries, 1)
+	fields := entries[0].ContextMap()
+	require.Equal(t, "0:1-2:3:4", fields["chunk_id"])
+	require.Equal(t, "test", fields["schema"])
+	require.Equal(t, "t", fields["table"])
+	require.Equal(t, "(1) < (a) <= (10)", fields["range"])
+	require.Equal(t, int64(1), fields["attempt"])
+	require.Contains(t, fields, "duration_ms")
+	require.Equal(t, true, fields["equal"])
+}
+
+func TestRecheckFailedChunks(t *testing.T) {
+	tables := []*common.TableDiff{{Schema: "test", Table: "t"}}
+	upstream := &mockSource{tables: tables, blockFrom: mockChunkCnt}
+	// the diffs of chunk 1 and 2 are transient, and the diff of chunk 3 is confirmed.
+	downstream := &mockSource{tables: tables, blockFrom: mockChunkCnt, diffs: map[int]int{1: 1, 2: 1, 3: -1}}
+	df := newTestDiff(t, upstream, downstream, func(df *Diff) {
+		df.checkThreadCount = 4
+		df.recheckFailedChunks = true
+		df.recheckDelay = time.Millisecond
+		df.recheckTimes = 1
+	})
+	require.NoError(t, df.Equal(context.Background()))
+
+	result := df.report.TableResults["test"]["t"]
+	require.False(t, result.DataEqual)
+	// the checksum durations of the equal chunks are recorded too.
+	require.Len(t, result.ChunkMap, mockChunkCnt)
+	require.Contains(t, result.ChunkMap, "v2:g0.g0.g3.g0.h64")
+	for _, chunkResult := range result.ChunkMap {
+		require.GreaterOrEqual(t, chunkResult.ChecksumDuration, time.Millisecond)
+	}
+	require.Len(t, df.report.SlowChunks, 10)
+	require.Equal(t, int64(1), df.report.ConfirmedChunks)
+	require.Equal(t, int64(2), df.report.TransientChunks)
+	// the checksum of each chunk takes at least 1ms.
+	require.GreaterOrEqual(t, result.Duration, mockChunkCnt*time.Millisecond)
+}
+
+func TestRecheckTimes(t *testing.T) {
+	tables := []*common.TableDiff{{Schema: "test", Table: "t"}}
+	upstream := &mockSource{tables: tables, blockFrom: mockChunkCnt}
+	// the diff of chunk 1 heals on the first recheck, the diff of chunk 2 heals on the second recheck,
+	// and the diff of chunk 3 is confirmed.
+	downstream := &mockSource{tables: tables, blockFrom: mockChunkCnt, diffs: map[int]int{2: 1, 3: -1}}
+	// the rechecks read the latest data on both sides.
+	latestUpstream := &mockSource{tables: tables, blockFrom: mockChunkCnt}
+	df := newTestDiff(t, upstream, &mockSource{tables: tables, blockFrom: mockChunkCnt, diffs: map[int]int{1: -1, 2: -1, 3: -1}}, func(df *Diff) {
+		df.workSource = downstream
+		df.checkThreadCount = 2
+		df.recheckFailedChunks = true
+		// the workers aren't occupied during the delay, so the other chunks are compared meanwhile.
+		df.recheckDelay = 100 * time.Millisecond
+		df.recheckTimes = 2
+		df.recheckUpstream = latestUpstream
+		df.recheckDownstream = downstream
+	})
+	start := time.Now()
+	require.NoError(t, df.Equal(context.Background()))
+	// the chunks are compared by 2 workers in at least 50ms, and the rechecks are in parallel with them.
+	require.Less(t, time.Since(start), 50*time.Millisecond+2*100*time.Millisecond+100*time.Millisecond)
+
+	result := df.report.TableResults["test"]["t"]
+	require.False(t, result.DataEqual)
+	// only the confirmed chunk is recorded as different.
+	require.Equal(t, report.Fail, df.report.Result)
+	require.Equal(t, int64(1), df.report.ConfirmedChunks)
+	require.Equal(t, int64(2), df.report.TransientChunks)
+	require.Equal(t, []int64{1, 1}, df.report.HealedOnRecheck)
+}
+
+func TestMatchColumnsByName(t *testing.T) {
+	upstreamInfo, err := dbutil.GetTableInfoBySQL("create table `test`.`t`(`a` int, `c` int, `b` int, primary key(`a`))", parser.New())
+	require.NoError(t, err)
+	newTables := func() []*common.TableDiff {
+		downstreamInfo, err := dbutil.GetTableInfoBySQL("create table `test`.`t`(`a` int, `b` int, `c` int, primary key(`a`))", parser.New())
+		require.NoError(t, err)
+		return []*common.TableDiff{{Schema: "test", Table: "t", Info: downstreamInfo}}
+	}
+
+	for _, matchColumnsByName := range []bool{false, true} {
+		tables := newTables()
+		df := newTestDiff(t, &mockSource{tables: tables, structInfos: []*model.TableInfo{upstreamInfo}}, &mockSource{tables: tables}, func(df *Diff) {
+			df.matchColumnsByName = matchColumnsByName
+		})
+		isEqual, isSkip, err := df.compareStruct(context.Background(), 0)
+		require.NoError(t, err)
+		// the columns only differ in the order are compared if they are matched by name.
+		require.Equal(t, matchColumnsByName, isEqual)
+		require.Equal(t, !matchColumnsByName, isSkip)
+		require.Equal(t, matchColumnsByName, df.report.TableResults["test"]["t"].ColumnsReordered)
+		if matchColumnsByName {
+			require.Equal(t, "c", tables[0].Info.Columns[1].Name.O)
+			require.Equal(t, "b", tables[0].Info.Columns[2].Name.O)
+		}
+	}
+}
+
+func TestCompareEnumByValue(t *testing.T) {
+	upstreamInfo, err := dbutil.GetTableInfoBySQL("create table `test`.`t`(`a` int, `b` enum('x','y'), `c` set('x','y'), primary key(`a`))", parser.New())
+	require.NoError(t, err)
+	downstreamInfo, err := dbutil.GetTableInfoBySQL("create table `test`.`t`(`a` int, `b` enum('y','x'), `c` set('x','y'), primary key(`a`))", parser.New())
+	require.NoError(t, err)
+
+	for _, c := range []struct {
+		compareEnumByValue    bool
+		failOnEnumMemberOrder bool
+	}{{false, false}, {true, false}, {true, true}} {
+		tables := []*common.TableDiff{{Schema: "test", Table: "t", Info: downstreamInfo}}
+		df := newTestDiff(t, &mockSource{tables: tables, structInfos: []*model.TableInfo{upstreamInfo}}, &mockSource{tables: tables}, func(df *Diff) {
+			df.compareEnumByValue = c.compareEnumByValue
+			df.failOnEnumMemberOrder = c.failOnEnumMemberOrder
+		})
+		isEqual, isSkip, err := df.compareStruct(context.Background(), 0)
+		require.NoError(t, err)
+		// the data is compared either way, and the reordered members are a mismatch if they are compared by index
+		// or fail-on-enum-member-order is set.
+		require.Equal(t, c.compareEnumByValue && !c.failOnEnumMemberOrder, isEqual)
+		require.False(t, isSkip)
+		require.Equal(t, []string{"b"}, tables[0].ReorderedEnumColumns)
+		require.Equal(t, !c.compareEnumByValue, tables[0].EnumByIndex)
+		if c.compareEnumByValue {
+			require.Equal(t, []string{"b"}, df.report.TableResults["test"]["t"].ReorderedEnumColumns)
+			require.Nil(t, tables[0].SourceEnumMembers)
+		} else {
+			require.Empty(t, df.report.TableResults["test"]["t"].ReorderedEnumColumns)
+			require.Equal(t, map[string][]string{"b": {"x", "y"}}, tables[0].SourceEnumMembers)
+		}
+		if !isEqual {
+			require.Equal(t, report.StructDiffNonBreaking, df.report.TableResults["test"]["t"].StructDiff)
+			require.Equal(t, []*utils.StructChange{{Kind: "column", Name: "b", Change: utils.StructChangeChanged, Source: "enum('x','y') NULL", Target: "enum('y','x') NULL"}},
+				df.report.TableResults["test"]["t"].StructChanges)
+		}
+	}
+}
+
+func TestEnumByIndexFixSQL(t *testing.T) {
+	tableInfo, err := dbutil.GetTableInfoBySQL("create table `test`.`t`(`a` int, `b` set('z','x','y'), primary key(`a`))", parser.New())
+	require.NoError(t, err)
+	tables := []*common.TableDiff{{Schema: "test", Table: "t", Info: tableInfo, ReorderedEnumColumns: []string{"b"}, EnumByIndex: true,
+		SourceEnumMembers: map[string][]string{"b": {"x", "y", "z"}}}}
+	df := newTestDiff(t, &mockRowsSource{mockSource: mockSource{tables: tables}}, &mockRowsSource{mockSource: mockSource{tables: tables}})
+	// the indexes are converted to the values by the members of the side where the rows are read.
+	upstreamData := map[string]*dbutil.ColumnData{"a": {Data: []byte("1")}, "b": {Data: []byte("5")}}
+	downstreamData := map[string]*dbutil.ColumnData{"a": {Data: []byte("1")}, "b": {Data: []byte("3")}}
+	generateFixSQL := func(dmlType source.DMLType) string {
+		sql, err := df.generateFixSQL(dmlType, upstreamData, downstreamData, 0)
+		require.NoError(t, err)
+		return sql
+	}
+	require.Equal(t, "INSERT INTO `test`.`t`(`a`,`b`) VALUES (1,'x,z');", generateFixSQL(source.Insert))
+	require.Equal(t, "DELETE FROM `test`.`t` WHERE `a` = 1 AND `b` = 'z,x' LIMIT 1;", generateFixSQL(source.Delete))
+	// the rows are kept for the comparison.
+	require.Equal(t, "5", string(upstreamData["b"].Data))
+
+	// the NULL and the invalid index are kept.
+	upstreamData["b"] = &dbutil.ColumnData{IsNull: true}
+	require.Equal(t, "INSERT INTO `test`.`t`(`a`,`b`) VALUES (1,NULL);", generateFixSQL(source.Insert))
+	upstreamData["b"] = &dbutil.ColumnData{Data: []byte("8")}
+	require.Equal(t, "INSERT INTO `test`.`t`(`a`,`b`) VALUES (1,'8');", generateFixSQL(source.Insert))
+}
+
+func TestCompareEnumOrderKeyByValue(t *testing.T) {
+	tableInfo, err := dbutil.GetTableInfoBySQL("create table `test`.`t`(`a` enum('a','B','c'), `b` int, primary key(`a`))", parser.New())
+	require.NoError(t, err)
+	tables := []*common.TableDiff{{Schema: "test", Table: "t", Info: tableInfo, ReorderedEnumColumns: []string{"a"}}}
+	// the rows are ordered by the member strings in utf8mb4_bin, where "B" is before "a", as the order keys are
+	// compared, so only the missing row is different.
+	upstream := &mockRowsSource{mockSource: mockSource{tables: tables}, rows: [][]string{{"B", "1"}, {"a", "2"}, {"c", "3"}}}
+	downstream := &mockRowsSource{mockSource: mockSource{tables: tables}, rows: [][]string{{"B", "1"}, {"c", "3"}}}
+	df := newTestDiff(t, upstream, downstream)
+	c := chunk.NewChunkRange()
+	c.Index = &chunk.ChunkID{TableIndex: 0, ChunkCnt: 1}
+	rangeInfo := &splitter.RangeInfo{ChunkRange: c}
+	dml := &ChunkDML{}
+	isEqual, err := df.compareRows(context.Background(), rangeInfo, dml, newChunkLogger(tables[0], rangeInfo))
+	require.NoError(t, err)
+	require.False(t, isEqual)
+	require.Equal(t, 1, dml.rowAdd)
+	require.Equal(t, 0, dml.rowDelete)
+	require.Len(t, dml.sqls, 1)
+}
+
+func TestCompareStructOnly(t *testing.T) {
+	upstreamInfo, err := dbutil.GetTableInfoBySQL("create table `test`.`t`(`a` int, `b` int, primary key(`a`))", parser.New())
+	require.NoError(t, err)
+	for _, createTableSQL := range []string{
+		"create table `test`.`t`(`a` int, `b` int, primary key(`a`))",
+		"create table `test`.`t`(`a` int, `b` int, `c` int, primary key(`a`))",
+	} {
+		downstreamInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+		require.NoError(t, err)
+		tables := []*common.TableDiff{{Schema: "test", Table: "t", Info: downstreamInfo}}
+		df := newTestDiff(t, &mockSource{tables: tables, structInfos: []*model.TableInfo{upstreamInfo}}, &mockSource{tables: tables}, func(df *Diff) {
+			df.ignoreDataCheck = true
+		})
+		isEqual, isSkip, err := df.compareStruct(context.Background(), 0)
+		require.NoError(t, err)
+		// the data check is always skipped, and the schema diff is only set for the different structures.
+		require.True(t, isSkip)
+		require.True(t, tables[0].IgnoreDataCheck)
+		schemaDiff := df.report.TableResults["test"]["t"].SchemaDiff
+		if len(downstreamInfo.Columns) == len(upstreamInfo.Columns) {
+			require.True(t, isEqual)
+			require.Empty(t, schemaDiff)
+		} else {
+			require.False(t, isEqual)
+			require.Contains(t, schemaDiff, "   `b` int(11) DEFAULT NULL,\n+  `c` int(11) DEFAULT NULL,\n")
+		}
+	}
+}
+
+func TestComparePartitionDefinition(t *testing.T) {
+	upstreamInfo, err := dbutil.GetTableInfoBySQL("create table `test`.`t`(`a` int, `b` int, primary key(`a`)) partition by range (`a`) (partition p0 values less than (10), partition p1 values less than (maxvalue))", parser.New())
+	require.NoError(t, err)
+	downstreamInfo, err := dbutil.GetTableInfoBySQL("create table `test`.`t`(`a` int, `b` int, primary key(`a`)) partition by hash (`a`) partitions 2", parser.New())
+	require.NoError(t, err)
+
+	for _, checkPartitionDefinition := range []bool{false, true} {
+		tables := []*common.TableDiff{{Schema: "test", Table: "t", Info: downstreamInfo, SplitByPartition: true}}
+		df := newTestDiff(t, &mockSource{tables: tables, structInfos: []*model.TableInfo{upstreamInfo}}, &mockSource{tables: tables}, func(df *Diff) {
+			df.checkPartitionDefinition = checkPartitionDefinition
+		})
+		isEqual, isSkip, err := df.compareStruct(context.Background(), 0)
+		require.NoError(t, err)
+		// the data is still compared if the partition definitions are different.
+		require.Equal(t, !checkPartitionDefinition, isEqual)
+		require.False(t, isSkip)
+		// the partitions are named p0 and p1 on the both sides, so the table is still split by partition.
+		require.True(t, tables[0].SplitByPartition)
+	}
+
+	downstreamInfo, err = dbutil.GetTableInfoBySQL("create table `test`.`t`(`a` int, `b` int, primary key(`a`))", parser.New())
+	require.NoError(t, err)
+	tables := []*common.TableDiff{{Schema: "test", Table: "t", Info: downstreamInfo, SplitByPartition: true}}
+	df := newTestDiff(t, &mockSource{tables: tables, structInfos: []*model.TableInfo{upstreamInfo}}, &mockSource{tables: tables})
+	isEqual, _, err := df.compareStruct(context.Background(), 0)
+	require.NoError(t, err)
+	require.True(t, isEqual)
+	require.False(t, tables[0].SplitByPartition)
+}
+
+func TestCompareViews(t *testing.T) {
+	views := []*common.ViewDiff{
+		{Schema: "test", View: "v1", SourceDefinitions: []string{"SELECT `a` FROM `t`", "SELECT `a` FROM `t`"}, TargetDefinition: "SELECT `a` FROM `t`"},
+		{Schema: "test", View: "v2", SourceDefinitions: []string{"SELECT `a` FROM `t`", "SELECT `b` FROM `t`"}, TargetDefinition: "SELECT `a` FROM `t`"},
+		{Schema: "test", View: "v3", SourceDefinitions: []string{""}, MissingOnTarget: true},
+		{Schema: "test", View: "v4"},
+	}
+	for _, checkViews := range []bool{false, true} {
+		df := newTestDiff(t, nil, nil, func(df *Diff) {
+			df.views = views
+			df.checkViews = checkViews
+		})
+		if checkViews {
+			df.report.SetCheckViews()
+		}
+		df.compareViews()
+		statuses := make([]string, 0, len(df.report.ViewResults))
+		for _, result := range df.report.ViewResults {
+			statuses = append(statuses, result.Status)
+		}
+		if checkViews {
+			require.Equal(t, []string{report.ViewEqual, report.ViewUnequal, report.ViewMissingOnTarget, report.ViewMissingOnSource}, statuses)
+			require.Equal(t, report.Fail, df.report.Result)
+		} else {
+			require.Equal(t, []string{report.ViewNotCompared, report.ViewNotCompared, report.ViewMissingOnTarget, report.ViewMissingOnSource}, statuses)
+			require.Equal(t, report.Pass, df.report.Result)
+		}
+	}
+}
+
+func newStructCheckDiff(t testing.TB, tableCnt int, structThreadCount int, structDelay time.Duration) *Diff {
+	info, err := dbutil.GetTableInfoBySQL("create table `test`.`t`(`a` int, `b` int, primary key(`a`))", parser.New())
+	require.NoError(t, err)
+	tables := make([]*common.TableDiff, 0, tableCnt)
+	for i := 0; i < tableCnt; i++ {
+		tables = append(tables, &common.TableDiff{Schema: "test", Table: fmt.Sprintf("t%d", i), Info: info})
+	}
+	return newTestDiff(t, &mockSource{tables: tables, structInfos: []*model.TableInfo{info}, structDelay: structDelay}, &mockSource{tables: tables}, func(df *Diff) {
+		df.structThreadCount = structThreadCount
+	})
+}
+
+func TestStructEqualConcurrency(t *testing.T) {
+	df := newStructCheckDiff(t, 20, 4, 10*time.Millisecond)
+	require.NoError(t, df.StructEqual(context.Background()))
+	// the structures are checked by at most `structThreadCount` workers.
+	require.Equal(t, int32(4), atomic.LoadInt32(&df.upstream.(*mockSource).maxInflight))
+	for _, table := range df.downstream.GetTables() {
+		require.True(t, df.report.TableResults["test"][table.Table].StructEqual)
+	}
+
+	// the first error is returned, and the rest tables are not checked.
+	df = newStructCheckDiff(t, 20, 4, time.Millisecond)
+	df.upstream.(*mockSource).structErrs = map[int]error{2: errors.New("mock error")}
+	require.Regexp(t, "mock error", df.StructEqual(context.Background()))
+}
+
+func TestStructEqualFailFast(t *testing.T) {
+	df := newStructCheckDiff(t, 10, 1, 0)
+	df.report.SetFailFast(true)
+	tables := df.downstream.GetTables()
+	info, err := dbutil.GetTableInfoBySQL("create table `test`.`t`(`a` int, `c` int, primary key(`a`))", parser.New())
+	require.NoError(t, err)
+	tables[2].Info = info
+	progress.InitWithOutput(len(tables), 0, new(bytes.Buffer))
+	defer progress.Close()
+	require.NoError(t, df.StructEqual(context.Background()))
+	require.True(t, df.report.IsFailedFast())
+	require.False(t, df.report.TableResults["test"]["t2"].StructEqual)
+
+	// only the checked tables are registered, the rest aren't shown as the structure failures.
+	var state progress.State
+	require.Eventually(t, func() bool {
+		state = progress.GetState()
+		_, ok := state.TableChunks["`test`.`t2`"]
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Contains(t, state.TableChunks, "`test`.`t0`")
+	require.NotContains(t, state.TableChunks, "`test`.`t9`")
+	require.Less(t, len(state.TableChunks), len(tables))
+}
+
+// BenchmarkStructEqual checks the structures of 10k tables whose structures take 100µs to get,
+// the time is near linear to 1/struct-thread-count.
+func BenchmarkStructEqual(b *testing.B) {
+	for _, threads := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("threads=%d", threads), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				df := newStructCheckDiff(b, 10000, threads, 100*time.Microsecond)
+				b.StartTimer()
+				require.NoError(b, df.StructEqual(context.Background()))
+			}
+		})
+	}
+}
+
+func TestRewindToErroredTable(t *testing.T) {
+	// the tables are compared in the order of t3, t2 and t1.
+	tables := []*common.TableDiff{{Schema: "test", Table: "t3"}, {Schema: "test", Table: "t2"}, {Schema: "test", Table: "t1"}}
+	newReport := func(errored ...int) *report.Report {
+		r := report.NewReport(&config.TaskConfig{})
+		r.Init(tables, nil, nil)
+		for i, table := range tables {
+			r.SetTableDataCheckResult(table.Schema, table.Table, true, 0, 0, &chunk.ChunkID{TableIndex: i, ChunkCnt: 1})
+		}
+		for _, i := range errored {
+			r.SetTableMeetError(tables[i].Schema, tables[i].Table, errors.New("connection refused"), nil, "")
+		}
+		return r
+	}
+	df := newTestDiff(t, nil, &mockSource{tables: tables})
+	node := &checkpoints.Node{
+		State:      checkpoints.SuccessState,
+		ChunkRange: &chunk.Range{Index: &chunk.ChunkID{TableIndex: 2, ChunkCnt: 1}, IsFirst: true, IsLast: true},
+	}
+
+	// the checkpoint is kept if no table meets errors.
+	r := newReport()
+	require.Equal(t, node, df.rewindToErroredTable(node, r))
+	require.Len(t, r.TableResults["test"], 3)
+
+	// the checkpoint is moved to the end of the table before the first errored table.
+	r = newReport(1, 2)
+	rewound := df.rewindToErroredTable(node, r)
+	require.Equal(t, 0, rewound.GetTableIndex())
+	require.Equal(t, chunk.Empty, rewound.ChunkRange.Type)
+	require.True(t, rewound.ChunkRange.IsLastChunkForTable())
+	require.True(t, rewound.IsAdjacent(&checkpoints.Node{ChunkRange: &chunk.Range{Index: &chunk.ChunkID{TableIndex: 1}, IsFirst: true}}))
+	// the fix sql files of the table before are kept.
+	require.Equal(t, 1, rewound.GetID().Compare(&chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 3, ChunkIndex: 10}))
+	require.Equal(t, -1, rewound.GetID().Compare(&chunk.ChunkID{TableIndex: 1}))
+	require.Len(t, r.TableResults["test"], 1)
+	require.Contains(t, r.TableResults["test"], "t3")
+
+	// start from the beginning if the first table meets errors.
+	require.Nil(t, df.rewindToErroredTable(node, newReport(0)))
+}
+
+func TestRedactConfigs(t *testing.T) {
+	const password = "p@ssw0rd-1234"
+	target := &config.DataSource{Host: "127.0.0.1", Port: 4000, User: "sync_user_42", Password: password}
+	cfg := &config.Config{RedactKeys: []string{"user"}, StatusToken: password}
+	cfg.DataSources = map[string]*config.DataSource{"target": target}
+	cfg.Task.SourceInstances = []*config.DataSource{{Host: "127.0.0.1", Port: 3306, User: "root", Password: password}}
+	cfg.Task.TargetInstance = target
+	sourceConfigs, targetConfig, err := getConfigsForReport(cfg)
+	require.NoError(t, err)
+	require.Equal(t, "host = \"127.0.0.1\"\nport = 4000\nuser = \"******\"\n", string(targetConfig))
+
+	// the password never appears in the summary, the report and the log.
+	outputDir := t.TempDir()
+	r := report.NewReport(&config.TaskConfig{OutputDir: outputDir})
+	r.Init(nil, sourceConfigs, targetConfig)
+	r.SetConfigOverrides([]string{"data-sources.target.password=" + cfg.RedactValue("data-sources.target.password", password)})
+	require.NoError(t, r.CommitSummary())
+	for _, name := range []string{"summary.txt", "report.json"} {
+		data, err := os.ReadFile(filepath.Join(outputDir, name))
+		require.NoError(t, err)
+		require.NotContains(t, string(data), password)
+		require.NotContains(t, string(data), "sync_user_42")
+		require.Contains(t, string(data), "data-sources.target.password=******")
+	}
+	require.NotContains(t, cfg.String(), password)
+	require.NotContains(t, cfg.String(), "sync_user_42")
+}
+
+func TestWriteStructFix(t *testing.T) {
+	upstreamInfo, err := dbutil.GetTableInfoBySQL("create table `test`.`t`(`a` int, `b` int, primary key(`a`))", parser.New())
+	require.NoError(t, err)
+	downstreamInfo, err := dbutil.GetTableInfoBySQL("create table `test`.`t`(`a` int, `c` int, primary key(`a`))", parser.New())
+	require.NoError(t, err)
+	tables := []*common.TableDiff{{Schema: "test", Table: "t", Info: downstreamInfo}}
+	df := newTestDiff(t, &mockSource{tables: tables, structInfos: []*model.TableInfo{upstreamInfo}}, &mockSource{tables: tables}, func(df *Diff) {
+		df.generateStructFix = true
+	})
+	isEqual, _, err := df.compareStruct(context.Background(), 0)
+	require.NoError(t, err)
+	require.False(t, isEqual)
+	df.report.AddMissingTable("test", "only_target", "source")
+	df.setMissingTableStructFix(context.Background(), nil, &common.MissingTable{Schema: "test", Table: "only_target"})
+	require.NoError(t, df.writeStructFix())
+
+	path := filepath.Join(df.FixSQLDir, fixsql.StructFixFile)
+	require.Equal(t, path, df.report.StructFixFile)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, structFixHeader+
+		"\n-- table: test.only_target\n"+
+		"-- WARNING: the table `test`.`only_target` only on the target and its data are dropped\n"+
+		"DROP TABLE `test`.`only_target`;\n"+
+		"\n-- table: test.t\n"+
+		"-- WARNING: the column `c` and its data are dropped\n"+
+		"ALTER TABLE `test`.`t` DROP COLUMN `c`;\n"+
+		"ALTER TABLE `test`.`t` ADD COLUMN `b` int(11) DEFAULT NULL AFTER `a`;\n", string(data))
+
+	// the file of the previous run is removed if the structures are the same.
+	df.report = report.NewReport(&config.TaskConfig{OutputDir: t.TempDir()})
+	df.report.Init(tables, nil, nil)
+	require.NoError(t, df.writeStructFix())
+	require.NoFileExists(t, path)
+	require.Empty(t, df.report.StructFixFile)
+}
+
+func TestMaxDiffRowsPerTable(t *testing.T) {
+	tables := []*common.TableDiff{{Schema: "test", Table: "t"}}
+	downstream := &mockSource{tables: tables, blockFrom: mockChunkCnt}
+	df := newTestDiff(t, &mockSource{tables: tables, blockFrom: mockChunkCnt}, downstream, func(df *Diff) { df.checkThreadCount = 4 })
+	df.report.SetMaxDiffRowsPerTable(5)
+	// the diff rows of the table found before, e.g. loaded from the checkpoint, exceed the limit.
+	df.report.SetTableDataCheckResult("test", "t", false, 4, 2, &chunk.ChunkID{TableIndex: 0, ChunkIndex: 0, ChunkCnt: mockChunkCnt})
+	require.True(t, df.report.IsTableDiffLimitExceeded("test", "t"))
+
+	require.NoError(t, df.Equal(context.Background()))
+	// no chunk is compared after the limit is exceeded, but all of them are still in the checkpoint.
+	require.Equal(t, int32(0), downstream.maxInflight)
+	result := df.report.TableResults["test"]["t"]
+	require.Equal(t, mockChunkCnt, result.TruncatedChunks)
+	require.False(t, result.DataEqual)
+	node, _, err := df.cp.LoadChunk(filepath.Join(df.CheckpointDir, checkpointFile))
+	require.NoError(t, err)
+	require.Equal(t, mockChunkCnt-1, node.GetChunkIndex())
+}
+
+func TestConcurrentRun(t *testing.T) {
+	// another Diff is running in the process.
+	atomic.StoreInt32(&running, 1)
+	defer atomic.StoreInt32(&running, 0)
+	r, err := new(Diff).Run(context.Background())
+	require.Nil(t, r)
+	require.Equal(t, ErrConcurrentRun, err)
+	require.Equal(t, int32(1), atomic.LoadInt32(&running))
+}
@@ -0,0 +1,86 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/utils"
+	"go.uber.org/zap"
+)
+
+// maxDuplicateKeys is the max number of the duplicate values of the unique key found on each side of a table.
+const maxDuplicateKeys = 10
+
+// checkKeyUniqueness checks whether the values of the unique key used to compare the rows are unique on both sides
+// by check-pk-uniqueness. The rows sharing the same key can't be matched by the key, which makes the row diffs
+// confusing, so the tables with the duplicate keys are marked `IgnoreDataCheck` with an error.
+func (df *Diff) checkKeyUniqueness(ctx context.Context) {
+	tables := df.downstream.GetTables()
+	tableIndex := 0
+	if df.startRange != nil {
+		// the table of the checkpoint has passed the check before it's compared by chunks.
+		tableIndex = df.startRange.ChunkRange.Index.TableIndex + 1
+	}
+	pool := utils.NewWorkerPool(uint(df.checkThreadCount), "duplicate key")
+	for ; tableIndex < len(tables); tableIndex++ {
+		// the tables without primary key or unique key are compared as multisets, whose rows may be duplicate.
+		if tables[tableIndex].IgnoreDataCheck || tables[tableIndex].NoPKFallback {
+			continue
+		}
+		i := tableIndex
+		pool.Apply(func() {
+			df.checkTableKeyUniqueness(ctx, i)
+		})
+	}
+	pool.WaitFinished()
+}
+
+// checkTableKeyUniqueness finds the duplicate values of the unique key of the table on both sides.
+func (df *Diff) checkTableKeyUniqueness(ctx context.Context, tableIndex int) {
+	table := df.downstream.GetTables()[tableIndex]
+	tableName := dbutil.TableName(table.Schema, table.Table)
+	upstreamKeys, err := df.upstream.GetDuplicateKeys(ctx, tableIndex, maxDuplicateKeys)
+	var downstreamKeys []*utils.DuplicateKey
+	if err == nil {
+		downstreamKeys, err = df.downstream.GetDuplicateKeys(ctx, tableIndex, maxDuplicateKeys)
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		log.Warn("fail to check the uniqueness of the key", zap.String("table", tableName), zap.Error(err))
+		df.report.SetTableMeetError(table.Schema, table.Table, err, nil, "")
+		table.IgnoreDataCheck = true
+		return
+	}
+	if len(upstreamKeys) == 0 && len(downstreamKeys) == 0 {
+		return
+	}
+	keys, _ := dbutil.SelectUniqueOrderKey(table.Info)
+	columns := make([]string, 0, len(keys))
+	for _, key := range keys {
+		columns = append(columns, dbutil.ColumnName(key))
+	}
+	log.Warn("the unique key has duplicate values, skip the data check", zap.String("table", tableName),
+		zap.Strings("key", keys), zap.Int("source", len(upstreamKeys)), zap.Int("target", len(downstreamKeys)))
+	df.report.SetTableDuplicateKeys(table.Schema, table.Table, keys, upstreamKeys, downstreamKeys)
+	df.report.SetTableMeetError(table.Schema, table.Table,
+		errors.Errorf("the unique key (%s) has duplicate values", strings.Join(columns, ", ")), nil, "")
+	table.IgnoreDataCheck = true
+}
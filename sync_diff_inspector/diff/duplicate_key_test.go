@@ -0,0 +1,64 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/utils"
+	"github.com/pingcap/tidb/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckKeyUniqueness(t *testing.T) {
+	tableInfo, err := dbutil.GetTableInfoBySQL("create table `test`.`t`(`a` int, `b` int, `c` int, unique key(`a`, `b`))", parser.New())
+	require.NoError(t, err)
+	tables := []*common.TableDiff{
+		{Schema: "test", Table: "t1", Info: tableInfo},
+		{Schema: "test", Table: "t2", Info: tableInfo},
+		{Schema: "test", Table: "t3", Info: tableInfo},
+		// the rows of the tables without unique key may be duplicate.
+		{Schema: "test", Table: "t4", Info: tableInfo, NoPKFallback: true},
+		// skipped by the struct check
+		{Schema: "test", Table: "t5", Info: tableInfo, IgnoreDataCheck: true},
+	}
+	duplicateKey := []*utils.DuplicateKey{{Values: []string{"1", "2"}, Rows: 2}}
+	upstream := &mockSource{tables: tables, duplicateKeys: map[int][]*utils.DuplicateKey{1: duplicateKey, 3: duplicateKey, 4: duplicateKey}}
+	downstream := &mockSource{tables: tables, duplicateKeys: map[int][]*utils.DuplicateKey{3: duplicateKey, 4: duplicateKey},
+		keyErrs: map[int]error{2: errors.New("connection refused")}}
+	df := newTestDiff(t, upstream, downstream, func(df *Diff) { df.checkThreadCount = 2 })
+	df.checkKeyUniqueness(context.Background())
+
+	for i, ignore := range []bool{false, true, true, false, true} {
+		require.Equal(t, ignore, tables[i].IgnoreDataCheck, i)
+	}
+	result := df.report.TableResults["test"]["t1"]
+	require.NoError(t, result.MeetError)
+	require.Empty(t, result.DuplicateKeyColumns)
+	result = df.report.TableResults["test"]["t2"]
+	require.EqualError(t, result.MeetError, "the unique key (`a`, `b`) has duplicate values")
+	require.Equal(t, []string{"a", "b"}, result.DuplicateKeyColumns)
+	require.Equal(t, duplicateKey, result.SourceDuplicateKeys)
+	require.Empty(t, result.TargetDuplicateKeys)
+	result = df.report.TableResults["test"]["t3"]
+	require.EqualError(t, result.MeetError, "connection refused")
+	require.Empty(t, result.DuplicateKeyColumns)
+	for _, table := range []string{"t4", "t5"} {
+		require.Empty(t, df.report.TableResults["test"][table].DuplicateKeyColumns)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/diff"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/progress"
+)
+
+// newMockDB returns a MySQL database with the table `test`.`t` of the rows, whose checksum is `checksum`.
+func newMockDB(checksum int, rows [][2]interface{}, isTarget bool) (*sql.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		panic(err)
+	}
+	// the queries are sent concurrently by the sources and the checkers.
+	mock.MatchExpectationsInOrder(false)
+	mock.ExpectQuery("TABLE_TYPE <> 'VIEW'").WillReturnRows(sqlmock.NewRows([]string{"TABLE_SCHEMA", "TABLE_NAME", "TABLE_TYPE", "ENGINE"}).
+		AddRow("test", "t", "BASE TABLE", "InnoDB"))
+	for i := 0; i < 2; i++ {
+		mock.ExpectQuery("SHOW DATABASES").WillReturnRows(sqlmock.NewRows([]string{"Database"}).AddRow("test"))
+		mock.ExpectQuery("SHOW FULL TABLES").WillReturnRows(sqlmock.NewRows([]string{"Tables_in_test", "Table_type"}).AddRow("t", "BASE TABLE"))
+	}
+	for i := 0; i < 3; i++ {
+		mock.ExpectQuery("SELECT version()").WillReturnRows(sqlmock.NewRows([]string{"version()"}).AddRow("8.0.36"))
+	}
+	mock.ExpectQuery("SHOW CREATE TABLE").WillReturnRows(sqlmock.NewRows([]string{"Table", "Create Table"}).
+		AddRow("t", "CREATE TABLE `t` (\n  `id` int(11) NOT NULL,\n  `name` varchar(24) DEFAULT NULL,\n  PRIMARY KEY (`id`)\n) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin"))
+	mock.ExpectQuery("SHOW VARIABLES LIKE 'sql_mode'").WillReturnRows(sqlmock.NewRows([]string{"Variable_name", "Value"}).AddRow("sql_mode", ""))
+	mock.ExpectQuery("TABLE_TYPE = 'VIEW'").WillReturnRows(sqlmock.NewRows([]string{"TABLE_SCHEMA", "TABLE_NAME"}))
+	mock.ExpectQuery("BIT_XOR").WillReturnRows(sqlmock.NewRows([]string{"CNT", "CHECKSUM"}).AddRow(len(rows), checksum))
+	data := sqlmock.NewRows([]string{"id", "name"})
+	for _, row := range rows {
+		data.AddRow(row[0], row[1])
+	}
+	mock.ExpectQuery("SQL_NO_CACHE").WillReturnRows(data)
+	if isTarget {
+		// the chunks are split on the target, and the sizes of the tables are reported.
+		mock.ExpectQuery("SELECT table_rows").WillReturnRows(sqlmock.NewRows([]string{"table_rows"}).AddRow(len(rows)))
+		mock.ExpectQuery("SELECT COUNT\\(1\\) cnt").WillReturnRows(sqlmock.NewRows([]string{"cnt"}).AddRow(len(rows)))
+		mock.ExpectQuery("sum\\(data_length\\)").WillReturnRows(sqlmock.NewRows([]string{"table_name", "data"}).AddRow("t", 16384))
+	}
+	return db, mock
+}
+
+// This example compares a MySQL source with a target of one different row. The config is built programmatically
+// like `sync_diff_inspector --config`, and the connections are passed in instead of connecting by the addresses.
+func Example() {
+	upstream, upstreamMock := newMockDB(1, [][2]interface{}{{1, "a"}, {2, "b"}}, false)
+	downstream, downstreamMock := newMockDB(2, [][2]interface{}{{1, "a"}, {2, "c"}}, true)
+
+	outputDir, err := os.MkdirTemp("", "sync-diff-example")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(outputDir)
+	// the logs are written to stdout by default, write them to the output directory like the binary.
+	logger, props, err := log.InitLogger(&log.Config{Level: "info", File: log.FileLogConfig{Filename: filepath.Join(outputDir, config.LogFileName)}})
+	if err != nil {
+		panic(err)
+	}
+	defer log.ReplaceGlobals(logger, props)()
+
+	cfg := config.NewConfig()
+	cfg.DataSources = map[string]*config.DataSource{
+		"mysql1": {Conn: upstream},
+		"tidb0":  {Conn: downstream},
+	}
+	cfg.Task.Source = []string{"mysql1"}
+	cfg.Task.Target = "tidb0"
+	cfg.Task.CheckTables = []string{"test.*"}
+	cfg.Task.OutputDir = outputDir
+	if err := cfg.Init(); err != nil {
+		panic(err)
+	}
+
+	d, err := diff.New(cfg)
+	if err != nil {
+		panic(err)
+	}
+	var state progress.State
+	d.OnProgress(func(s progress.State) {
+		state = s
+	})
+	r, err := d.Run(context.Background())
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("progress: %d/%d chunks\n", state.CompletedChunks, state.TotalChunks)
+	fmt.Println("result:", r.Result)
+	result := r.TableResults["test"]["t"]
+	fmt.Println("struct equal:", result.StructEqual, "data equal:", result.DataEqual)
+	for _, chunk := range result.ChunkMap {
+		fmt.Println("rows add:", chunk.RowsAdd, "rows delete:", chunk.RowsDelete)
+	}
+	if err := upstreamMock.ExpectationsWereMet(); err != nil {
+		panic(err)
+	}
+	if err := downstreamMock.ExpectationsWereMet(); err != nil {
+		panic(err)
+	}
+	// Output:
+	// progress: 1/1 chunks
+	// result: fail
+	// struct equal: true data equal: false
+	// rows add: 1 rows delete: 1
+}
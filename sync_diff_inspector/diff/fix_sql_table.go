@@ -0,0 +1,174 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/chunk"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/fixsql"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/utils"
+	"go.uber.org/zap"
+)
+
+// tableFixSQLBuffer is the number of the chunks buffered for the writer of each table.
+const tableFixSQLBuffer = 16
+
+// tableFixSQLWriters writes the fix sql of each table into its own file with fix-file-layout = "table". Each table
+// has its own writer goroutine started when its first fix sql comes, so the tables are written in parallel without
+// waiting for each other. The writer of a table is closed once all its chunks are written, see `dispatch`. Except
+// dispatch, it's only used by the goroutine writing the fix sql.
+type tableFixSQLWriters struct {
+	df      *Diff
+	writers map[int]chan *ChunkDML
+	wg      sync.WaitGroup
+
+	// the chunks of each table dispatched and written, and whether the last chunk of the table is dispatched.
+	mu          sync.Mutex
+	dispatched  map[int]int
+	written     map[int]int
+	lastStarted map[int]bool
+}
+
+func newTableFixSQLWriters(df *Diff) *tableFixSQLWriters {
+	return &tableFixSQLWriters{
+		df:          df,
+		writers:     make(map[int]chan *ChunkDML),
+		dispatched:  make(map[int]int),
+		written:     make(map[int]int),
+		lastStarted: make(map[int]bool),
+	}
+}
+
+// dispatch counts the chunk of the table dispatched to compare, which is called before the chunk is compared.
+func (w *tableFixSQLWriters) dispatch(tableIndex int, isLastChunk bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.dispatched[tableIndex]++
+	if isLastChunk {
+		w.lastStarted[tableIndex] = true
+	}
+}
+
+// finish counts the chunk of the table written, and returns true if all the chunks of the table are written.
+func (w *tableFixSQLWriters) finish(tableIndex int) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.written[tableIndex]++
+	return w.lastStarted[tableIndex] && w.written[tableIndex] == w.dispatched[tableIndex]
+}
+
+// write sends the fix sql of the chunk to the writer of its table, and closes the writer after the last chunk of the
+// table. The chunk without fix sql is inserted into the checkpoint by the writer too if the table has one, so the
+// checkpoint doesn't pass the chunks being written.
+func (w *tableFixSQLWriters) write(dml *ChunkDML) {
+	tableIndex := dml.node.GetTableIndex()
+	ch, ok := w.writers[tableIndex]
+	if !ok && len(dml.sqls) > 0 {
+		ch = make(chan *ChunkDML, tableFixSQLBuffer)
+		w.writers[tableIndex] = ch
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			for dml := range ch {
+				if w.df.getFixSQLErr() != nil {
+					// the chunk isn't inserted into the checkpoint, so it's compared again after resuming.
+					continue
+				}
+				if err := w.df.writeTableFixSQL(dml); err != nil {
+					log.Error("write sql failed", zap.Any("chunk index", dml.node.GetID()), zap.Error(err))
+					w.df.setFixSQLErr(err)
+				}
+			}
+		}()
+		ok = true
+	}
+	if ok {
+		ch <- dml
+	} else {
+		log.Debug("insert node", zap.Any("chunk index", dml.node.GetID()))
+		w.df.cp.Insert(dml.node)
+	}
+	if w.finish(tableIndex) && ok {
+		close(ch)
+		delete(w.writers, tableIndex)
+	}
+}
+
+// close waits for the writers to write all the fix sql sent.
+func (w *tableFixSQLWriters) close() {
+	for _, ch := range w.writers {
+		close(ch)
+	}
+	w.wg.Wait()
+	w.writers = make(map[int]chan *ChunkDML)
+}
+
+// writeTableFixSQL appends the fix sql of the chunk to the file of its table, then inserts the chunk into the
+// checkpoint. The completed size of the file is recorded after each chunk, so the chunk partially written is
+// dropped when resuming.
+func (df *Diff) writeTableFixSQL(dml *ChunkDML) error {
+	if len(dml.sqls) == 0 {
+		log.Debug("insert node", zap.Any("chunk index", dml.node.GetID()))
+		df.cp.Insert(dml.node)
+		return nil
+	}
+	tableDiff := df.downstream.GetTables()[dml.node.GetTableIndex()]
+	name := fixsql.TableFileName(tableDiff.Schema, tableDiff.Table, df.fixFileCompression)
+	path := filepath.Join(df.FixSQLDir, name)
+	size, n, err := fixsql.AppendChunk(path, utils.GetSQLFileName(dml.node.GetID()), df.fixSQLHeader(tableDiff, dml.node.ChunkRange), dml.sqls, df.fixFileCompression)
+	if err != nil {
+		return errors.Annotatef(err, "write the fix sql file %s", name)
+	}
+	// the line is appended by a single write, so the writers of the tables don't interleave.
+	if err = fixsql.AppendCompleted(df.FixSQLDir, name, size); err != nil {
+		return errors.Annotatef(err, "record the completed fix sql file %s", name)
+	}
+	df.report.AddFixSQLBytes(tableDiff.Schema, tableDiff.Table, dml.node.GetID(), n)
+	df.report.SetTableFixSQLFile(tableDiff.Schema, tableDiff.Table, path)
+	log.Debug("insert node", zap.Any("chunk index", dml.node.GetID()))
+	df.cp.Insert(dml.node)
+	return nil
+}
+
+// removeTableFixSQL removes the fix sql of the chunks after the checkpoint from the file of the table at `oldPath`
+// when resuming, and the file is moved to `trashPath` if no chunk is left. The chunk partially written after the
+// completed size is dropped too. It returns the size of the file kept, which is 0 if the file is moved.
+func (df *Diff) removeTableFixSQL(oldPath, trashPath string, checkPointID *chunk.ChunkID, size int64) (int64, error) {
+	if checkPointID.TableIndex < 0 {
+		// start from beginning.
+		size = 0
+	}
+	if size != 0 {
+		newSize, err := fixsql.FilterChunks(oldPath, size, func(id string) (bool, error) {
+			tableIndex, bucketIndexLeft, bucketIndexRight, chunkIndex, err := utils.GetChunkIDFromSQLFileName(id)
+			if err != nil {
+				return false, errors.Trace(err)
+			}
+			chunkID := &chunk.ChunkID{TableIndex: tableIndex, BucketIndexLeft: bucketIndexLeft, BucketIndexRight: bucketIndexRight, ChunkIndex: chunkIndex}
+			return chunkID.Compare(checkPointID) <= 0, nil
+		})
+		if err != nil {
+			return 0, errors.Trace(err)
+		}
+		if newSize > 0 {
+			return newSize, nil
+		}
+	}
+	return 0, errors.Trace(os.Rename(oldPath, trashPath))
+}
@@ -0,0 +1,247 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/checkpoints"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/chunk"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/fixsql"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableFixSQLLayout(t *testing.T) {
+	tables := []*common.TableDiff{{Schema: "test", Table: "t2"}, {Schema: "test", Table: "t1"}}
+	df := newTestDiff(t, nil, &mockSource{tables: tables}, func(df *Diff) {
+		df.fixFileCompression = fixsql.CompressionGzip
+		df.fixFileLayout = config.FixFileLayoutTable
+	})
+	dir := df.FixSQLDir
+	node := func(tableIndex, chunkIndex int) *checkpoints.Node {
+		c := chunk.NewChunkRange()
+		c.Index = &chunk.ChunkID{TableIndex: tableIndex, ChunkIndex: chunkIndex, ChunkCnt: 2}
+		c.IsFirst, c.IsLast = chunkIndex == 0, chunkIndex == 1
+		return &checkpoints.Node{State: checkpoints.SuccessState, ChunkRange: c}
+	}
+	// the chunks are written in the order they are completed.
+	df.sqlCh <- &ChunkDML{node: node(0, 1), sqls: []string{"DELETE FROM `test`.`t2` WHERE `a` = 2 LIMIT 1;"}}
+	df.sqlCh <- &ChunkDML{node: node(1, 0), sqls: []string{"DELETE FROM `test`.`t1` WHERE `a` = 1 LIMIT 1;"}}
+	df.sqlCh <- &ChunkDML{node: node(0, 0), sqls: []string{"DELETE FROM `test`.`t2` WHERE `a` = 1 LIMIT 1;"}}
+	df.sqlCh <- &ChunkDML{node: node(1, 1)}
+	close(df.sqlCh)
+	df.sqlWg.Add(1)
+	df.writeSQLs(context.Background(), newTableFixSQLWriters(df))
+
+	// all the chunks are inserted into the checkpoint after being written.
+	require.Equal(t, node(1, 1).GetID(), df.cp.GetChunkSnapshot().GetID())
+	path := filepath.Join(dir, "test:t2.sql.gz")
+	require.Equal(t, path, df.report.TableResults["test"]["t2"].FixSQLFile)
+	require.Equal(t, filepath.Join(dir, "test:t1.sql.gz"), df.report.TableResults["test"]["t1"].FixSQLFile)
+	data, err := fixsql.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, []string{"DELETE FROM `test`.`t2` WHERE `a` = 2 LIMIT 1;", "DELETE FROM `test`.`t2` WHERE `a` = 1 LIMIT 1;"}, fixsql.SplitStatements(string(data)))
+	manifest, err := fixsql.GenerateManifest(dir, nil)
+	require.NoError(t, err)
+	require.Len(t, manifest.Files, 2)
+	require.Equal(t, "test:t1.sql.gz", manifest.Files[0].Name)
+
+	// the chunks after the checkpoint are removed from the files when resuming.
+	require.NoError(t, df.removeSQLFiles(node(0, 0).GetID()))
+	data, err = fixsql.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, []string{"DELETE FROM `test`.`t2` WHERE `a` = 1 LIMIT 1;"}, fixsql.SplitStatements(string(data)))
+	_, err = os.Stat(filepath.Join(dir, "test:t1.sql.gz"))
+	require.True(t, os.IsNotExist(err))
+	manifest, err = fixsql.GenerateManifest(dir, nil)
+	require.NoError(t, err)
+	require.Len(t, manifest.Files, 1)
+	// only the file kept is recorded as completed, by the size after the chunks are removed.
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	completedFiles, _, err := fixsql.LoadCompleted(dir)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{"test:t2.sql.gz": info.Size()}, completedFiles)
+
+	// all the files are removed when starting from the beginning.
+	require.NoError(t, df.removeSQLFiles(chunk.GetInitChunkID()))
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestResumeChunkFixSQLFiles(t *testing.T) {
+	tables := []*common.TableDiff{{Schema: "test", Table: "t"}}
+	df := newTestDiff(t, nil, &mockSource{tables: tables})
+	dir := df.FixSQLDir
+	node := func(chunkIndex int) *checkpoints.Node {
+		c := chunk.NewChunkRange()
+		c.Index = &chunk.ChunkID{TableIndex: 0, ChunkIndex: chunkIndex, ChunkCnt: 3}
+		c.IsFirst, c.IsLast = chunkIndex == 0, chunkIndex == 2
+		return &checkpoints.Node{State: checkpoints.SuccessState, ChunkRange: c}
+	}
+	for i := 0; i < 3; i++ {
+		df.sqlCh <- &ChunkDML{node: node(i), sqls: []string{fmt.Sprintf("DELETE FROM `test`.`t` WHERE `a` = %d LIMIT 1;", i)}}
+	}
+	close(df.sqlCh)
+	df.sqlWg.Add(1)
+	df.writeSQLs(context.Background(), nil)
+	completedFiles, _, err := fixsql.LoadCompleted(dir)
+	require.NoError(t, err)
+	require.Len(t, completedFiles, 3)
+
+	// the run is resumed part-way through the table, the files of the chunks after the checkpoint are moved to trash,
+	// and so are their records.
+	require.NoError(t, df.removeSQLFiles(node(0).GetID()))
+	kept := "test:t:0:0-0:0.sql"
+	require.FileExists(t, filepath.Join(dir, kept))
+	require.NoFileExists(t, filepath.Join(dir, "test:t:0:0-0:1.sql"))
+	completedFiles, _, err = fixsql.LoadCompleted(dir)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{kept: completedFiles[kept]}, completedFiles)
+
+	// the file of the chunk written again is partial until it's recorded, though its size is the same as before.
+	trashed, err := filepath.Glob(filepath.Join(dir, ".trash-*", "test:t:0:0-0:1.sql"))
+	require.NoError(t, err)
+	require.Len(t, trashed, 1)
+	require.NoError(t, os.Rename(trashed[0], filepath.Join(dir, "test:t:0:0-0:1.sql")))
+	manifest, err := fixsql.GenerateManifest(dir, nil)
+	require.NoError(t, err)
+	require.Len(t, manifest.Files, 1)
+	require.Equal(t, kept, manifest.Files[0].Name)
+}
+
+func TestTableFixSQLWriters(t *testing.T) {
+	tables := []*common.TableDiff{{Schema: "test", Table: "t1"}, {Schema: "test", Table: "t2"}}
+	df := newTestDiff(t, nil, &mockSource{tables: tables})
+	node := func(tableIndex, chunkIndex int) *checkpoints.Node {
+		c := chunk.NewChunkRange()
+		c.Index = &chunk.ChunkID{TableIndex: tableIndex, ChunkIndex: chunkIndex, ChunkCnt: 2}
+		c.IsFirst, c.IsLast = chunkIndex == 0, chunkIndex == 1
+		return &checkpoints.Node{State: checkpoints.SuccessState, ChunkRange: c}
+	}
+	w := newTableFixSQLWriters(df)
+	w.dispatch(0, false)
+	w.dispatch(0, true)
+	w.dispatch(1, false)
+	w.write(&ChunkDML{node: node(0, 1), sqls: []string{"DELETE FROM `test`.`t1` WHERE `a` = 2 LIMIT 1;"}})
+	w.write(&ChunkDML{node: node(1, 0), sqls: []string{"DELETE FROM `test`.`t2` WHERE `a` = 1 LIMIT 1;"}})
+	require.Len(t, w.writers, 2)
+	// the writer of the table is closed after its last chunk, the one of the other table is kept.
+	w.write(&ChunkDML{node: node(0, 0)})
+	require.Len(t, w.writers, 1)
+	require.Contains(t, w.writers, 1)
+	w.close()
+	require.NoError(t, df.getFixSQLErr())
+	require.Equal(t, node(1, 0).GetID(), df.cp.GetChunkSnapshot().GetID())
+
+	// the error writing the file is recorded rather than exiting, and the chunk isn't inserted into the checkpoint.
+	df.FixSQLDir = filepath.Join(df.FixSQLDir, "missing")
+	w = newTableFixSQLWriters(df)
+	w.dispatch(1, true)
+	w.write(&ChunkDML{node: node(1, 1), sqls: []string{"DELETE FROM `test`.`t2` WHERE `a` = 2 LIMIT 1;"}})
+	w.close()
+	require.Error(t, df.getFixSQLErr())
+	require.Nil(t, df.cp.GetChunkSnapshot())
+}
+
+func TestHostileFixSQLNames(t *testing.T) {
+	tables := []*common.TableDiff{{Schema: "a/b", Table: "t:1\n"}}
+	df := newTestDiff(t, nil, &mockSource{tables: tables})
+	c := chunk.NewChunkRange()
+	c.Index = &chunk.ChunkID{TableIndex: 0, ChunkCnt: 1}
+	c.IsFirst, c.IsLast = true, true
+	c.Update("k", "1\nDELETE FROM `a/b`.`t:1\n`;", "2", true, true)
+	// the line breaks in the names and the bounds don't end the comments.
+	require.Equal(t, "-- table: a/b.t:1\\n\n-- range in sequence: (1\\nDELETE FROM `a/b`.`t:1\\n`;) < (k) <= (2)\n", df.fixSQLHeader(tables[0], c))
+
+	df.sqlCh <- &ChunkDML{node: &checkpoints.Node{State: checkpoints.SuccessState, ChunkRange: c}, sqls: []string{"DELETE FROM `a/b`.`t:1\n` WHERE `k` = 1 LIMIT 1;"}}
+	close(df.sqlCh)
+	df.sqlWg.Add(1)
+	df.writeSQLs(context.Background(), nil)
+
+	// the separators in the names are escaped in the name of the file.
+	data, err := fixsql.ReadFile(filepath.Join(df.FixSQLDir, "a%2Fb:t%3A1\n:0:0-0:0.sql"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"DELETE FROM `a/b`.`t:1\n` WHERE `k` = 1 LIMIT 1;"}, fixsql.SplitStatements(string(data)))
+	require.NoError(t, df.removeSQLFiles(chunk.GetInitChunkID()))
+}
+
+type memorySink struct {
+	files map[string]*bytes.Buffer
+}
+
+type memoryFile struct {
+	*bytes.Buffer
+}
+
+func (f *memoryFile) Close() error { return nil }
+
+func (s *memorySink) Create(name string) (io.WriteCloser, error) {
+	buf := new(bytes.Buffer)
+	s.files[name] = buf
+	return &memoryFile{buf}, nil
+}
+
+func TestCustomFixSQLSink(t *testing.T) {
+	// nothing is written into the directory with a custom sink.
+	dir := filepath.Join(t.TempDir(), "missing")
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	tables := []*common.TableDiff{{Schema: "test", Table: "t"}}
+	df := newTestDiff(t, nil, &mockSource{tables: tables}, func(df *Diff) {
+		df.FixSQLDir = dir
+		df.fixSQLSink = sink
+		df.fixFileMaxSize = 1
+		df.fixFileCompression = fixsql.CompressionGzip
+	})
+	c := chunk.NewChunkRange()
+	c.Index = &chunk.ChunkID{TableIndex: 0, ChunkCnt: 1}
+	c.IsFirst, c.IsLast = true, true
+	stmts := []string{"DELETE FROM `test`.`t` WHERE `a` = 1 LIMIT 1;", "DELETE FROM `test`.`t` WHERE `a` = 2 LIMIT 1;"}
+	df.sqlCh <- &ChunkDML{node: &checkpoints.Node{State: checkpoints.SuccessState, ChunkRange: c}, sqls: stmts}
+	close(df.sqlCh)
+	df.sqlWg.Add(1)
+	df.writeSQLs(context.Background(), nil)
+
+	// each statement is rotated into its own file, which is recorded without reading it back.
+	require.Len(t, sink.files, 2)
+	for i, stmt := range stmts {
+		name := fixsql.FileName("test:t:0:0-0:0", i, fixsql.CompressionGzip)
+		require.True(t, df.fixSQLFiles.Has(name))
+		r, err := fixsql.NewReader(name, bytes.NewReader(sink.files[name].Bytes()))
+		require.NoError(t, err)
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, []string{stmt}, fixsql.SplitStatements(string(data)))
+	}
+
+	// the manifest is written into the sink from the recorded files.
+	df.writeFixSQLManifest()
+	manifest := new(fixsql.Manifest)
+	require.NoError(t, json.Unmarshal(sink.files[fixsql.ManifestFile].Bytes(), manifest))
+	require.Equal(t, df.fixSQLFiles.Manifest(), manifest)
+	require.Len(t, manifest.Files, 2)
+	require.Equal(t, "0:0-0:0", manifest.Files[0].ChunkID)
+	require.Equal(t, 1, manifest.Files[1].Statements)
+	require.NoError(t, df.removeSQLFiles(chunk.GetInitChunkID()))
+	require.NoDirExists(t, dir)
+}
@@ -0,0 +1,184 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/splitter"
+	"github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"go.uber.org/zap"
+)
+
+// noUniqueKeySkipReason is the reason to skip the data check of the tables without primary key or unique key.
+const noUniqueKeySkipReason = "no usable unique key"
+
+// noIndexTableFixSQLWarning is the header of the fix sql files of the tables without primary key or unique key.
+const noIndexTableFixSQLWarning = "-- WARNING: the table has no primary key or unique key, the different rows are fixed by `DELETE ... LIMIT 1` and `INSERT`,\n" +
+	"-- which delete and insert one of the duplicate rows each time, please review the statements before applying them.\n"
+
+// checkNoIndexTable returns the reason to skip the data check of the table without primary key or unique key,
+// empty if the table should be compared. The table is skipped unless compare-no-index-tables is set, and the table
+// with more rows than no-index-table-max-rows on either side is skipped too.
+func (df *Diff) checkNoIndexTable(ctx context.Context, tableIndex int) string {
+	table := df.downstream.GetTables()[tableIndex]
+	tableName := dbutil.TableName(table.Schema, table.Table)
+	if !df.compareNoIndexTables || df.skipNoPKTables {
+		log.Info("skip the data check of the table without primary key or unique key", zap.String("table", tableName))
+		return noUniqueKeySkipReason
+	}
+	for _, src := range []source.Source{df.upstream, df.downstream} {
+		cnt, err := src.GetCount(ctx, tableIndex)
+		if err != nil {
+			// the rows are still limited by no-index-table-max-rows when they are compared.
+			log.Warn("failed to get the row count of the table without primary key or unique key", zap.String("table", tableName), zap.Error(err))
+			return ""
+		}
+		if cnt > df.noIndexTableMaxRows {
+			log.Info("skip the data check of the table without primary key or unique key by no-index-table-max-rows",
+				zap.String("table", tableName), zap.Int64("row count", cnt), zap.Int64("no-index-table-max-rows", df.noIndexTableMaxRows))
+			return fmt.Sprintf("%s, the row count %d > no-index-table-max-rows %d", noUniqueKeySkipReason, cnt, df.noIndexTableMaxRows)
+		}
+	}
+	return ""
+}
+
+// multisetRow is a distinct row and its count on the source minus its count on the target.
+type multisetRow struct {
+	data  map[string]*dbutil.ColumnData
+	count int
+}
+
+// compareRowsAsMultisets compares the rows of the table without primary key or unique key as multisets, i.e. a row
+// appearing twice on one side and once on the other counts as one diff. The rows of both sides are held in memory,
+// so the comparison fails if the rows of either side are more than no-index-table-max-rows.
+func (df *Diff) compareRowsAsMultisets(ctx context.Context, rangeInfo *splitter.RangeInfo, dml *ChunkDML, logger *chunkLogger) (bool, error) {
+	tableInfo := df.workSource.GetTables()[rangeInfo.GetTableIndex()].Info
+	rows := make(map[string]*multisetRow)
+	// the distinct rows in the order they are read, so that the fix sql is reproducible.
+	keys := make([]string, 0)
+	readRows := func(src source.Source, delta int) error {
+		iter, err := src.GetRowsIterator(ctx, rangeInfo)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		defer iter.Close()
+		if src == df.upstream {
+			iter = df.transcodeRows(iter, df.workSource.GetTables()[rangeInfo.GetTableIndex()])
+		}
+		var cnt int64
+		for {
+			if err := ctx.Err(); err != nil {
+				return errors.Trace(err)
+			}
+			data, err := iter.Next()
+			if err != nil {
+				return errors.Trace(err)
+			}
+			if data == nil {
+				return nil
+			}
+			cnt++
+			if cnt > df.noIndexTableMaxRows {
+				return errors.Errorf("the rows of the table without primary key or unique key are more than no-index-table-max-rows %d", df.noIndexTableMaxRows)
+			}
+			key := multisetRowKey(data, tableInfo.Columns)
+			row, ok := rows[key]
+			if !ok {
+				row = &multisetRow{data: data}
+				rows[key] = row
+				keys = append(keys, key)
+			}
+			row.count += delta
+		}
+	}
+	if err := readRows(df.upstream, 1); err != nil {
+		return false, errors.Trace(err)
+	}
+	if err := readRows(df.downstream, -1); err != nil {
+		return false, errors.Trace(err)
+	}
+
+	rowsAdd, rowsDelete := 0, 0
+	deletes := make([]string, 0)
+	inserts := make([]string, 0)
+	for _, key := range keys {
+		row := rows[key]
+		for ; row.count < 0; row.count++ {
+			sql, err := df.generateFixSQL(source.Delete, nil, row.data, rangeInfo.GetTableIndex())
+			if err != nil {
+				return false, errors.Trace(err)
+			}
+			logger.Debug("[delete]", zap.String("sql", sql))
+			deletes = append(deletes, sql)
+			df.appendDiffRow(dml, diffRowSideTarget, row.data)
+			df.appendRowDiff(dml, tableInfo, nil, nil, row.data)
+			rowsDelete++
+		}
+		for ; row.count > 0; row.count-- {
+			sql, err := df.generateFixSQL(source.Insert, row.data, nil, rangeInfo.GetTableIndex())
+			if err != nil {
+				return false, errors.Trace(err)
+			}
+			logger.Debug("[insert]", zap.String("sql", sql))
+			inserts = append(inserts, sql)
+			df.appendDiffRow(dml, diffRowSideSource, row.data)
+			df.appendRowDiff(dml, tableInfo, nil, row.data, nil)
+			rowsAdd++
+		}
+	}
+	// the extra rows are deleted before the missing rows are inserted.
+	if df.fixTarget == config.FixTargetSource {
+		dml.sqls = append(dml.sqls, inserts...)
+		dml.sqls = append(dml.sqls, deletes...)
+		rowsAdd, rowsDelete = rowsDelete, rowsAdd
+	} else {
+		dml.sqls = append(dml.sqls, deletes...)
+		dml.sqls = append(dml.sqls, inserts...)
+	}
+	dml.rowAdd = rowsAdd
+	dml.rowDelete = rowsDelete
+	return rowsAdd+rowsDelete == 0, nil
+}
+
+// multisetRowKey returns the key of the row made of all the column values, the float values are rounded
+// to 6 decimal places, which is the precision to compare them, see `utils.CompareData`.
+func multisetRowKey(data map[string]*dbutil.ColumnData, columns []*model.ColumnInfo) string {
+	var b strings.Builder
+	for _, col := range columns {
+		value, ok := data[col.Name.O]
+		if !ok || value.IsNull {
+			b.WriteString("N;")
+			continue
+		}
+		str := string(value.Data)
+		if col.FieldType.Tp == mysql.TypeFloat || col.FieldType.Tp == mysql.TypeDouble {
+			if num, err := strconv.ParseFloat(str, 64); err == nil {
+				str = strconv.FormatFloat(num, 'f', 6, 64)
+			}
+		}
+		// the values are prefixed by the length, so that they can't be mixed up with the separators.
+		fmt.Fprintf(&b, "%d:%s;", len(str), str)
+	}
+	return b.String()
+}
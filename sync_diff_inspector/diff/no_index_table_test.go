@@ -0,0 +1,133 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/chunk"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/splitter"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/utils"
+	"github.com/pingcap/tidb/parser"
+	"github.com/stretchr/testify/require"
+)
+
+// mockRowsSource is a source returning the rows of `rows` and generating the fix sql by the row data.
+type mockRowsSource struct {
+	mockSource
+
+	rows [][]string
+}
+
+type mockRowsIterator struct {
+	rows [][]string
+}
+
+func (it *mockRowsIterator) Next() (map[string]*dbutil.ColumnData, error) {
+	if len(it.rows) == 0 {
+		return nil, nil
+	}
+	row := it.rows[0]
+	it.rows = it.rows[1:]
+	// the values are of the columns `a`, `b`, `c` and so on.
+	data := make(map[string]*dbutil.ColumnData, len(row))
+	for i, value := range row {
+		data[string(rune('a'+i))] = &dbutil.ColumnData{Data: []byte(value)}
+	}
+	return data, nil
+}
+
+func (it *mockRowsIterator) Close() {}
+
+func (s *mockRowsSource) GetRowsIterator(context.Context, *splitter.RangeInfo) (source.RowDataIterator, error) {
+	return &mockRowsIterator{rows: s.rows}, nil
+}
+
+func (s *mockRowsSource) GenerateFixSQL(t source.DMLType, upstreamData, downstreamData map[string]*dbutil.ColumnData, tableIndex int) (string, error) {
+	table := s.tables[tableIndex]
+	if t == source.Delete {
+		return utils.GenerateDeleteDML(downstreamData, table.Info, table.Schema), nil
+	}
+	return utils.GenerateInsertDML(upstreamData, table.Info, table.Schema), nil
+}
+
+func TestCheckNoIndexTable(t *testing.T) {
+	tables := []*common.TableDiff{{Schema: "test", Table: "t", NoPKFallback: true}}
+	df := newTestDiff(t, &mockSource{tables: tables, counts: []int64{5}}, &mockSource{tables: tables, counts: []int64{20}}, func(df *Diff) {
+		df.noIndexTableMaxRows = 20
+	})
+	// skipped by default.
+	require.Equal(t, "no usable unique key", df.checkNoIndexTable(context.Background(), 0))
+	df.compareNoIndexTables = true
+	require.Equal(t, "", df.checkNoIndexTable(context.Background(), 0))
+	// the row count of either side is capped.
+	df.noIndexTableMaxRows = 10
+	require.Equal(t, "no usable unique key, the row count 20 > no-index-table-max-rows 10", df.checkNoIndexTable(context.Background(), 0))
+	df.skipNoPKTables = true
+	df.noIndexTableMaxRows = 20
+	require.Equal(t, "no usable unique key", df.checkNoIndexTable(context.Background(), 0))
+}
+
+func TestCompareRowsAsMultisets(t *testing.T) {
+	tableInfo, err := dbutil.GetTableInfoBySQL("create table `test`.`t`(`a` int, `b` varchar(10))", parser.New())
+	require.NoError(t, err)
+	tables := []*common.TableDiff{{Schema: "test", Table: "t", Info: tableInfo, NoPKFallback: true}}
+	newDiff := func(fixTarget string, maxRows int64) *Diff {
+		upstream := &mockRowsSource{mockSource: mockSource{tables: tables}, rows: [][]string{{"1", "a"}, {"1", "a"}, {"2", "b"}, {"3", "c"}}}
+		downstream := &mockRowsSource{mockSource: mockSource{tables: tables}, rows: [][]string{{"1", "a"}, {"3", "c"}, {"3", "c"}, {"4", "d"}}}
+		return newTestDiff(t, upstream, downstream, func(df *Diff) {
+			df.fixTarget = fixTarget
+			df.noIndexTableMaxRows = maxRows
+		})
+	}
+	c := chunk.NewChunkRange()
+	c.Index = &chunk.ChunkID{TableIndex: 0, ChunkCnt: 1}
+	rangeInfo := &splitter.RangeInfo{ChunkRange: c}
+
+	df := newDiff(config.FixTargetTarget, 4)
+	dml := &ChunkDML{}
+	isEqual, err := df.compareRowsAsMultisets(context.Background(), rangeInfo, dml, newChunkLogger(tables[0], rangeInfo))
+	require.NoError(t, err)
+	require.False(t, isEqual)
+	// the duplicate row appearing twice on one side and once on the other counts as one diff,
+	// and the extra rows are deleted one by one before the missing rows are inserted.
+	require.Equal(t, 2, dml.rowAdd)
+	require.Equal(t, 2, dml.rowDelete)
+	require.Equal(t, []string{
+		"DELETE FROM `test`.`t` WHERE `a` = 3 AND `b` = 'c' LIMIT 1;",
+		"DELETE FROM `test`.`t` WHERE `a` = 4 AND `b` = 'd' LIMIT 1;",
+		"INSERT INTO `test`.`t`(`a`,`b`) VALUES (1,'a');",
+		"INSERT INTO `test`.`t`(`a`,`b`) VALUES (2,'b');",
+	}, dml.sqls)
+
+	// the rows of the same multiset are equal in any order.
+	df = newDiff(config.FixTargetTarget, 4)
+	df.downstream.(*mockRowsSource).rows = [][]string{{"3", "c"}, {"1", "a"}, {"2", "b"}, {"1", "a"}}
+	dml = &ChunkDML{}
+	isEqual, err = df.compareRowsAsMultisets(context.Background(), rangeInfo, dml, newChunkLogger(tables[0], rangeInfo))
+	require.NoError(t, err)
+	require.True(t, isEqual)
+	require.Empty(t, dml.sqls)
+
+	// the rows more than no-index-table-max-rows are not compared.
+	df = newDiff(config.FixTargetTarget, 3)
+	_, err = df.compareRowsAsMultisets(context.Background(), rangeInfo, &ChunkDML{}, newChunkLogger(tables[0], rangeInfo))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "more than no-index-table-max-rows 3")
+}
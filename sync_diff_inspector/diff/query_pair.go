@@ -0,0 +1,264 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/report"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/utils"
+	"github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tidb/parser/types"
+	"go.uber.org/zap"
+)
+
+// compareQueryPairs compares the result sets of query-pairs in the order of the labels, and adds the results to the
+// report. The errors of the pairs are recorded in the report, and only the error of ctx is returned.
+func (df *Diff) compareQueryPairs(ctx context.Context) error {
+	labels := make([]string, 0, len(df.queryPairs))
+	for label := range df.queryPairs {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		result, err := df.compareQueryPair(ctx, label, df.queryPairs[label])
+		if ctx.Err() != nil {
+			return errors.Trace(ctx.Err())
+		}
+		if err != nil {
+			log.Warn("failed to compare the query pair", zap.String("label", label), zap.Error(err))
+			df.report.AddQueryPairError(label, err)
+			continue
+		}
+		log.Info("compare the query pair finished", zap.String("label", label), zap.Bool("equal", result.Equal))
+		df.report.AddQueryPairResult(result)
+	}
+	return nil
+}
+
+// compareQueryPair runs the queries of the pair on the source instance and the target instance, and compares the
+// result sets row by row like the chunks, the rows are aligned by the key columns.
+func (df *Diff) compareQueryPair(ctx context.Context, label string, pair *config.QueryPairConfig) (*report.QueryPairResult, error) {
+	sourceInstance, err := pair.GetSourceInstance(&df.cfg.Task)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	upstreamRows, columns, err := queryResultSet(ctx, sourceInstance.Conn, pair.SourceQuery)
+	if err != nil {
+		return nil, errors.Annotate(err, "failed to run source-query")
+	}
+	defer upstreamRows.Close()
+	downstreamRows, targetColumns, err := queryResultSet(ctx, df.cfg.Task.TargetInstance.Conn, pair.TargetQuery)
+	if err != nil {
+		return nil, errors.Annotate(err, "failed to run target-query")
+	}
+	defer downstreamRows.Close()
+	if err := checkQueryColumns(columns, targetColumns); err != nil {
+		return nil, errors.Trace(err)
+	}
+	keyColumns, err := getQueryKeyColumns(columns, pair.KeyColumns)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	upstream := &orderedRowsIterator{RowDataIterator: upstreamRows, keyColumns: keyColumns, side: "source"}
+	downstream := &orderedRowsIterator{RowDataIterator: downstreamRows, keyColumns: keyColumns, side: "target"}
+	logger := log.With(zap.String("label", label))
+	result := &report.QueryPairResult{Label: label}
+	var lastUpstreamData, lastDownstreamData map[string]*dbutil.ColumnData
+	for {
+		if lastUpstreamData == nil {
+			if lastUpstreamData, err = upstream.Next(); err != nil {
+				return nil, errors.Trace(err)
+			}
+		}
+		if lastDownstreamData == nil {
+			if lastDownstreamData, err = downstream.Next(); err != nil {
+				return nil, errors.Trace(err)
+			}
+		}
+		var cmp int32
+		switch {
+		case lastUpstreamData == nil && lastDownstreamData == nil:
+			result.Equal = result.RowsAdd == 0 && result.RowsDelete == 0
+			result.SourceRows, result.TargetRows = upstream.rows, downstream.rows
+			if df.fixTarget == config.FixTargetSource {
+				// the rows added to the target are the rows deleted from the source.
+				result.RowsAdd, result.RowsDelete = result.RowsDelete, result.RowsAdd
+			}
+			return result, nil
+		case lastDownstreamData == nil:
+			cmp = -1
+		case lastUpstreamData == nil:
+			cmp = 1
+		default:
+			eq, c, err := utils.CompareData(lastUpstreamData, lastDownstreamData, keyColumns, columns)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			if eq {
+				lastUpstreamData, lastDownstreamData = nil, nil
+				continue
+			}
+			cmp = c
+		}
+		switch cmp {
+		case 1:
+			logger.Debug("[delete]", zap.Reflect("row", lastDownstreamData))
+			result.RowsDelete++
+			lastDownstreamData = nil
+		case -1:
+			logger.Debug("[insert]", zap.Reflect("row", lastUpstreamData))
+			result.RowsAdd++
+			lastUpstreamData = nil
+		case 0:
+			result.RowsAdd++
+			result.RowsDelete++
+			lastUpstreamData, lastDownstreamData = nil, nil
+		}
+	}
+}
+
+// queryResultSet runs the query, and returns the iterator of the rows and the columns of the result set.
+func queryResultSet(ctx context.Context, db *sql.DB, query string) (source.RowDataIterator, []*model.ColumnInfo, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	columns, err := getQueryColumns(rows)
+	if err != nil {
+		rows.Close()
+		return nil, nil, errors.Trace(err)
+	}
+	return source.NewTiDBRowsIterator(rows), columns, nil
+}
+
+// getQueryColumns returns the columns of the result set, whose types are only used to compare the values, so the
+// types are simplified to the integers, the floats, the decimals and the strings.
+func getQueryColumns(rows *sql.Rows) ([]*model.ColumnInfo, error) {
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	columns := make([]*model.ColumnInfo, 0, len(columnTypes))
+	names := make(map[string]struct{}, len(columnTypes))
+	for i, columnType := range columnTypes {
+		if _, ok := names[columnType.Name()]; ok {
+			return nil, errors.Errorf("the column %s is duplicated in the result set, use the aliases to distinguish the columns", columnType.Name())
+		}
+		names[columnType.Name()] = struct{}{}
+		column := &model.ColumnInfo{
+			ID:        int64(i + 1),
+			Name:      model.NewCIStr(columnType.Name()),
+			Offset:    i,
+			FieldType: *types.NewFieldType(getQueryColumnType(columnType.DatabaseTypeName())),
+			State:     model.StatePublic,
+		}
+		if scanType := columnType.ScanType(); scanType != nil {
+			switch scanType.Kind() {
+			case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				column.Flag |= mysql.UnsignedFlag
+			}
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+func getQueryColumnType(databaseTypeName string) byte {
+	switch strings.TrimPrefix(strings.ToUpper(databaseTypeName), "UNSIGNED ") {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "BIGINT", "YEAR":
+		return mysql.TypeLonglong
+	case "FLOAT":
+		return mysql.TypeFloat
+	case "DOUBLE":
+		return mysql.TypeDouble
+	case "DECIMAL":
+		return mysql.TypeNewDecimal
+	default:
+		return mysql.TypeVarString
+	}
+}
+
+// checkQueryColumns returns an error if the columns of the result sets are different by the names, the order of the
+// columns doesn't matter because the values are compared by the names.
+func checkQueryColumns(sourceColumns, targetColumns []*model.ColumnInfo) error {
+	names := func(columns []*model.ColumnInfo) []string {
+		names := make([]string, 0, len(columns))
+		for _, column := range columns {
+			names = append(names, column.Name.O)
+		}
+		sort.Strings(names)
+		return names
+	}
+	sourceNames, targetNames := names(sourceColumns), names(targetColumns)
+	if !reflect.DeepEqual(sourceNames, targetNames) {
+		return errors.Errorf("the columns of the result sets are different, source-query returns %v, but target-query returns %v", sourceNames, targetNames)
+	}
+	return nil
+}
+
+// getQueryKeyColumns returns the columns of key-columns in the result set.
+func getQueryKeyColumns(columns []*model.ColumnInfo, keyColumns []string) ([]*model.ColumnInfo, error) {
+	keys := make([]*model.ColumnInfo, 0, len(keyColumns))
+	for _, name := range keyColumns {
+		column := dbutil.FindColumnByName(columns, name)
+		if column == nil {
+			return nil, errors.Errorf("the key column %s is not in the result set", name)
+		}
+		keys = append(keys, column)
+	}
+	return keys, nil
+}
+
+// orderedRowsIterator counts the rows of the result set, and fails if the rows are not in the ascending order of the
+// key columns or the keys are not unique, which breaks the comparison of the rows one by one.
+type orderedRowsIterator struct {
+	source.RowDataIterator
+	keyColumns []*model.ColumnInfo
+	// side is "source" or "target" in the error.
+	side string
+	last map[string]*dbutil.ColumnData
+	rows int64
+}
+
+func (it *orderedRowsIterator) Next() (map[string]*dbutil.ColumnData, error) {
+	row, err := it.RowDataIterator.Next()
+	if err != nil || row == nil {
+		return row, errors.Trace(err)
+	}
+	if it.last != nil {
+		cmp, err := utils.CompareOrderKeys(it.last, row, it.keyColumns)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if cmp >= 0 {
+			return nil, errors.Errorf("the rows of %s-query are not in the ascending order of key-columns or the keys are not unique, "+
+				"please order the rows by key-columns, and by the binary collation for the string columns", it.side)
+		}
+	}
+	it.last = row
+	it.rows++
+	return row, nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/report"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareQueryPairs(t *testing.T) {
+	sourceDB, sourceMock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sourceDB.Close()
+	targetDB, targetMock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer targetDB.Close()
+
+	cfg := &config.Config{
+		Task: config.TaskConfig{
+			Source:          []string{"mysql1"},
+			SourceInstances: []*config.DataSource{{Conn: sourceDB}},
+			TargetInstance:  &config.DataSource{Conn: targetDB},
+		},
+		QueryPairs: map[string]*config.QueryPairConfig{
+			"orders": {
+				SourceQuery: "SELECT id, SUM(amount) AS total FROM orders GROUP BY id ORDER BY id",
+				TargetQuery: "SELECT SUM(amount) AS total, id FROM orders_archive GROUP BY id ORDER BY id",
+				KeyColumns:  []string{"id"},
+			},
+			"users": {
+				SourceQuery: "SELECT id, name FROM users ORDER BY id",
+				TargetQuery: "SELECT id, name FROM users ORDER BY id",
+				KeyColumns:  []string{"id"},
+			},
+			"unordered": {
+				SourceQuery: "SELECT id FROM t",
+				TargetQuery: "SELECT id FROM t ORDER BY id",
+				KeyColumns:  []string{"id"},
+			},
+			"broken": {
+				SourceQuery: "SELECT id FROM t ORDER BY id",
+				TargetQuery: "SELECT id FROM t_missing ORDER BY id",
+				KeyColumns:  []string{"id"},
+			},
+		},
+	}
+	df := newTestDiff(t, nil, nil, func(df *Diff) {
+		df.cfg = cfg
+		df.queryPairs = cfg.QueryPairs
+	})
+	df.report.Init(nil, nil, nil)
+
+	idColumn := func() *sqlmock.Column { return sqlmock.NewColumn("id").OfType("BIGINT", uint64(0)) }
+	// broken
+	sourceMock.ExpectQuery("FROM t ORDER BY id").WillReturnRows(sqlmock.NewRowsWithColumnDefinition(idColumn()).AddRow(1))
+	targetMock.ExpectQuery("FROM t_missing").WillReturnError(&mysql.MySQLError{Number: 1146, Message: "Table 'test.t_missing' doesn't exist"})
+	// orders, the columns are matched by the names, and the doubles are equal within the precision.
+	sourceMock.ExpectQuery("FROM orders GROUP BY id").WillReturnRows(
+		sqlmock.NewRowsWithColumnDefinition(idColumn(), sqlmock.NewColumn("total").OfType("DOUBLE", float64(0))).
+			AddRow(2, "20").AddRow(10, "10.5"))
+	targetMock.ExpectQuery("FROM orders_archive").WillReturnRows(
+		sqlmock.NewRowsWithColumnDefinition(sqlmock.NewColumn("total").OfType("DOUBLE", float64(0)), idColumn()).
+			AddRow("20.0000000001", 2).AddRow("10.5", 10))
+	// unordered
+	sourceMock.ExpectQuery("SELECT id FROM t$").WillReturnRows(sqlmock.NewRowsWithColumnDefinition(idColumn()).AddRow(2).AddRow(1))
+	targetMock.ExpectQuery("FROM t ORDER BY id").WillReturnRows(sqlmock.NewRowsWithColumnDefinition(idColumn()).AddRow(1).AddRow(2))
+	// users, 1 is only on the source, 2 is updated and 18446744073709551615 is only on the target.
+	nameColumn := func() *sqlmock.Column { return sqlmock.NewColumn("name").OfType("VARCHAR", "") }
+	sourceMock.ExpectQuery("FROM users").WillReturnRows(sqlmock.NewRowsWithColumnDefinition(idColumn(), nameColumn()).
+		AddRow(1, "a").AddRow(2, "b").AddRow(3, "c"))
+	targetMock.ExpectQuery("FROM users").WillReturnRows(sqlmock.NewRowsWithColumnDefinition(idColumn(), nameColumn()).
+		AddRow(2, "B").AddRow(3, "c").AddRow("18446744073709551615", "d"))
+
+	require.NoError(t, df.compareQueryPairs(context.Background()))
+	require.NoError(t, sourceMock.ExpectationsWereMet())
+	require.NoError(t, targetMock.ExpectationsWereMet())
+
+	results := df.report.QueryPairResults
+	require.Len(t, results, 4)
+	require.Equal(t, "broken", results[0].Label)
+	require.Contains(t, results[0].ErrorMessage, "failed to run target-query")
+	require.Equal(t, report.ErrorCategorySchema, results[0].ErrorCategory)
+	require.Equal(t, &report.QueryPairResult{Label: "orders", Equal: true, SourceRows: 2, TargetRows: 2}, results[1])
+	require.Equal(t, "unordered", results[2].Label)
+	require.Contains(t, results[2].ErrorMessage, "the rows of source-query are not in the ascending order of key-columns")
+	require.Equal(t, &report.QueryPairResult{Label: "users", SourceRows: 3, TargetRows: 3, RowsAdd: 2, RowsDelete: 2}, results[3])
+	require.Equal(t, report.Error, df.report.Result)
+
+	require.NoError(t, df.report.CommitSummary())
+	summary, err := os.ReadFile(filepath.Join(df.FixSQLDir, "summary.txt"))
+	require.NoError(t, err)
+	require.Contains(t, string(summary), "\nQuery pairs\n\n")
+	require.Regexp(t, "\\| broken +\\| schema error +\\| +0 \\| +0 \\| - +\\|", string(summary))
+	require.Regexp(t, "\\| orders +\\| equal +\\| +2 \\| +2 \\| \\+0/-0 +\\|", string(summary))
+	require.Regexp(t, "\\| users +\\| unequal +\\| +3 \\| +3 \\| \\+2/-2 +\\|", string(summary))
+	require.Contains(t, string(summary), "broken: failed to run target-query: Error 1146: Table 'test.t_missing' doesn't exist\n")
+	buf := new(bytes.Buffer)
+	require.NoError(t, df.report.Print(buf))
+	require.Contains(t, buf.String(), "schema error occured in query pair broken: failed to run target-query")
+}
@@ -0,0 +1,226 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/chunk"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/fixsql"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/report"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/utils"
+	"github.com/pingcap/tidb/parser/charset"
+	"github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+)
+
+const (
+	// rowDiffsDir is the directory in output-dir where the different rows are exported by export-row-diffs.
+	rowDiffsDir = "row-diffs"
+
+	// rowDiffTypeUpdate is the row on both sides with the different values, and rowDiffTypeRow is the row only on
+	// one side.
+	rowDiffTypeUpdate = "update"
+	rowDiffTypeRow    = "row"
+)
+
+// rowDiff is a line of the NDJSON file exported by export-row-diffs. The values are nil for NULL, and the values of
+// the binary columns are hex-encoded.
+type rowDiff struct {
+	Type string `json:"type"`
+	// Side is the side of the row only on one side, "source" or "target".
+	Side string `json:"side,omitempty"`
+	// Key is the values of the columns of the primary key or the unique key, which is empty if the table has no key.
+	Key map[string]interface{} `json:"key,omitempty"`
+	// Columns are the source and the target values of the different columns of the updated row.
+	Columns map[string]*columnDiff `json:"columns,omitempty"`
+	// Row is all the values of the row only on one side.
+	Row map[string]interface{} `json:"row,omitempty"`
+}
+
+// columnDiff is the values of a column different on both sides.
+type columnDiff struct {
+	Source interface{} `json:"source"`
+	Target interface{} `json:"target"`
+}
+
+// rowDiffsExporter exports the different rows of each failing chunk to `<schema>:<table>:<chunk>.ndjson` in dir.
+// At most maxRows rows are exported of each table, 0 means no limit, and the rest are counted as suppressed.
+// It's only used by the goroutine writing the fix sql, so it's not thread-safe.
+type rowDiffsExporter struct {
+	dir     string
+	dirPerm os.FileMode
+	maxRows int64
+	report  *report.Report
+	// exported is the rows exported of each table, including the ones exported before resuming from the checkpoint.
+	exported map[string]int64
+}
+
+func newRowDiffsExporter(dir string, dirPerm os.FileMode, maxRows int64, r *report.Report) *rowDiffsExporter {
+	return &rowDiffsExporter{
+		dir:      dir,
+		dirPerm:  dirPerm,
+		maxRows:  maxRows,
+		report:   r,
+		exported: make(map[string]int64),
+	}
+}
+
+// removeFiles removes the files exported by the previous run, which is called when starting from the beginning.
+// The files of the chunks compared again after resuming from the checkpoint are overwritten.
+func (e *rowDiffsExporter) removeFiles() error {
+	return errors.Trace(os.RemoveAll(e.dir))
+}
+
+// export writes the different rows of the chunk to its file, the rows beyond maxRows of the table are suppressed.
+func (e *rowDiffsExporter) export(table *common.TableDiff, id *chunk.ChunkID, rows []*rowDiff, suppressed int64) error {
+	name := dbutil.TableName(table.Schema, table.Table)
+	exported, ok := e.exported[name]
+	if !ok {
+		exported = e.report.GetTableRowDiffsExported(table.Schema, table.Table)
+	}
+	if e.maxRows > 0 && exported+int64(len(rows)) > e.maxRows {
+		n := e.maxRows - exported
+		if n < 0 {
+			n = 0
+		}
+		suppressed += int64(len(rows)) - n
+		rows = rows[:n]
+	}
+	path := ""
+	if len(rows) > 0 {
+		if err := os.MkdirAll(e.dir, e.dirPerm); err != nil {
+			return errors.Trace(err)
+		}
+		path = filepath.Join(e.dir, fixsql.TablePrefix(table.Schema, table.Table)+":"+utils.GetSQLFileName(id)+".ndjson")
+		if err := writeRowDiffs(path, rows); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	e.exported[name] = exported + int64(len(rows))
+	e.report.AddChunkRowDiffs(table.Schema, table.Table, id, path, int64(len(rows)), suppressed)
+	return nil
+}
+
+// writeRowDiffs writes the rows to the file one JSON object per line, the file is overwritten if it exists.
+func writeRowDiffs(path string, rows []*rowDiff) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, config.LocalFilePerm)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	w := bufio.NewWriter(file)
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	for _, row := range rows {
+		if err := encoder.Encode(row); err != nil {
+			file.Close()
+			return errors.Trace(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return errors.Trace(err)
+	}
+	return errors.Trace(file.Close())
+}
+
+// appendRowDiff appends the different row to the chunk if export-row-diffs is true. The row is only on the source if
+// downstreamData is nil, only on the target if upstreamData is nil, and updated if neither is nil. The rows of a chunk
+// are no more than max-row-diffs, and the rest are only counted, so that the rows of a chunk with too many diffs
+// aren't held in memory.
+func (df *Diff) appendRowDiff(dml *ChunkDML, tableInfo *model.TableInfo, keyColumns []*model.ColumnInfo, upstreamData, downstreamData map[string]*dbutil.ColumnData) {
+	e := df.rowDiffsExporter
+	if e == nil {
+		return
+	}
+	if e.maxRows > 0 && int64(len(dml.rowDiffs)) >= e.maxRows {
+		dml.rowDiffsSuppressed++
+		return
+	}
+	row := &rowDiff{}
+	data := upstreamData
+	if data == nil {
+		data = downstreamData
+	}
+	if len(keyColumns) > 0 {
+		row.Key = make(map[string]interface{}, len(keyColumns))
+		for _, col := range keyColumns {
+			row.Key[col.Name.O] = rowDiffValue(col, data[col.Name.O])
+		}
+	}
+	switch {
+	case upstreamData != nil && downstreamData != nil:
+		row.Type = rowDiffTypeUpdate
+		row.Columns = make(map[string]*columnDiff)
+		for _, col := range tableInfo.Columns {
+			source, target := upstreamData[col.Name.O], downstreamData[col.Name.O]
+			if sameColumnData(source, target) {
+				continue
+			}
+			row.Columns[col.Name.O] = &columnDiff{Source: rowDiffValue(col, source), Target: rowDiffValue(col, target)}
+		}
+	default:
+		row.Type = rowDiffTypeRow
+		row.Side = diffRowSideSource
+		if upstreamData == nil {
+			row.Side = diffRowSideTarget
+		}
+		row.Row = make(map[string]interface{}, len(tableInfo.Columns))
+		for _, col := range tableInfo.Columns {
+			row.Row[col.Name.O] = rowDiffValue(col, data[col.Name.O])
+		}
+	}
+	dml.rowDiffs = append(dml.rowDiffs, row)
+}
+
+// rowDiffValue returns the value of the column in the NDJSON file, which is nil for NULL and hex-encoded for the
+// binary columns.
+func rowDiffValue(col *model.ColumnInfo, data *dbutil.ColumnData) interface{} {
+	if data == nil || data.IsNull {
+		return nil
+	}
+	if isBinaryColumn(col) {
+		return hex.EncodeToString(data.Data)
+	}
+	return string(data.Data)
+}
+
+// isBinaryColumn returns true if the column is BINARY, VARBINARY or BLOB, whose values may not be valid UTF-8.
+func isBinaryColumn(col *model.ColumnInfo) bool {
+	switch col.Tp {
+	case mysql.TypeString, mysql.TypeVarchar, mysql.TypeVarString,
+		mysql.TypeTinyBlob, mysql.TypeBlob, mysql.TypeMediumBlob, mysql.TypeLongBlob:
+		return col.Charset == charset.CharsetBin
+	default:
+		return false
+	}
+}
+
+func sameColumnData(a, b *dbutil.ColumnData) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.IsNull || b.IsNull {
+		return a.IsNull == b.IsNull
+	}
+	return string(a.Data) == string(b.Data)
+}
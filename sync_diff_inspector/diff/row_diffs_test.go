@@ -0,0 +1,97 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/chunk"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/splitter"
+	"github.com/pingcap/tidb/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportRowDiffs(t *testing.T) {
+	tableInfo, err := dbutil.GetTableInfoBySQL("create table `test`.`t`(`a` int, `b` varchar(10), `c` varbinary(10), primary key(`a`))", parser.New())
+	require.NoError(t, err)
+	tables := []*common.TableDiff{{Schema: "test", Table: "t", Info: tableInfo}}
+	upstream := &mockRowsSource{mockSource: mockSource{tables: tables}, rows: [][]string{{"1", "a", "\x01"}, {"2", "b", "\x03"}, {"3", "<c>", "\x00"}}}
+	downstream := &mockRowsSource{mockSource: mockSource{tables: tables}, rows: [][]string{{"1", "a", "\x01"}, {"2", "x", "\x02"}, {"4", "d", "\xff"}}}
+	df := newTestDiff(t, upstream, downstream)
+	dir, r := df.FixSQLDir, df.report
+	df.rowDiffsExporter = newRowDiffsExporter(filepath.Join(dir, rowDiffsDir), config.LocalDirPerm, 0, r)
+	c := chunk.NewChunkRange()
+	c.Index = &chunk.ChunkID{TableIndex: 0, ChunkCnt: 1}
+	rangeInfo := &splitter.RangeInfo{ChunkRange: c}
+
+	// the updated row has only the different columns, and the binary values are hex-encoded.
+	dml := &ChunkDML{}
+	isEqual, err := df.compareRows(context.Background(), rangeInfo, dml, newChunkLogger(tables[0], rangeInfo))
+	require.NoError(t, err)
+	require.False(t, isEqual)
+	require.Len(t, dml.rowDiffs, 3)
+	require.NoError(t, df.rowDiffsExporter.export(tables[0], c.Index, dml.rowDiffs, dml.rowDiffsSuppressed))
+
+	path := filepath.Join(dir, rowDiffsDir, "test:t:0:0-0:0.ndjson")
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, `{"type":"update","key":{"a":"2"},"columns":{"b":{"source":"b","target":"x"},"c":{"source":"03","target":"02"}}}
+{"type":"row","side":"source","key":{"a":"3"},"row":{"a":"3","b":"<c>","c":"00"}}
+{"type":"row","side":"target","key":{"a":"4"},"row":{"a":"4","b":"d","c":"ff"}}
+`, string(data))
+	result := r.TableResults["test"]["t"]
+	require.Equal(t, int64(3), result.RowDiffsExported)
+	require.Equal(t, int64(0), result.RowDiffsSuppressed)
+	require.Equal(t, path, result.ChunkMap[c.Index.Encode()].RowDiffsFile)
+
+	// the rows of a chunk are capped by max-row-diffs, and so are the rows of the table after resuming.
+	df.rowDiffsExporter = newRowDiffsExporter(filepath.Join(dir, rowDiffsDir), config.LocalDirPerm, 4, r)
+	dml = &ChunkDML{}
+	_, err = df.compareRows(context.Background(), rangeInfo, dml, newChunkLogger(tables[0], rangeInfo))
+	require.NoError(t, err)
+	require.Len(t, dml.rowDiffs, 3)
+	id := &chunk.ChunkID{TableIndex: 0, ChunkIndex: 1, ChunkCnt: 2}
+	require.NoError(t, df.rowDiffsExporter.export(tables[0], id, dml.rowDiffs, dml.rowDiffsSuppressed))
+	require.Equal(t, int64(4), result.RowDiffsExported)
+	require.Equal(t, int64(2), result.RowDiffsSuppressed)
+	data, err = os.ReadFile(filepath.Join(dir, rowDiffsDir, "test:t:0:0-0:1.ndjson"))
+	require.NoError(t, err)
+	require.Equal(t, `{"type":"update","key":{"a":"2"},"columns":{"b":{"source":"b","target":"x"},"c":{"source":"03","target":"02"}}}
+`, string(data))
+
+	// no file is written if all the rows are suppressed.
+	id = &chunk.ChunkID{TableIndex: 0, ChunkIndex: 2, ChunkCnt: 3}
+	require.NoError(t, df.rowDiffsExporter.export(tables[0], id, dml.rowDiffs, 0))
+	require.Equal(t, int64(5), result.RowDiffsSuppressed)
+	_, err = os.Stat(filepath.Join(dir, rowDiffsDir, "test:t:0:0-0:2.ndjson"))
+	require.True(t, os.IsNotExist(err))
+
+	df.rowDiffsExporter = newRowDiffsExporter(filepath.Join(dir, rowDiffsDir), config.LocalDirPerm, 2, r)
+	dml = &ChunkDML{}
+	_, err = df.compareRows(context.Background(), rangeInfo, dml, newChunkLogger(tables[0], rangeInfo))
+	require.NoError(t, err)
+	require.Len(t, dml.rowDiffs, 2)
+	require.Equal(t, int64(1), dml.rowDiffsSuppressed)
+
+	// the files are removed when starting from the beginning.
+	require.NoError(t, df.rowDiffsExporter.removeFiles())
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+}
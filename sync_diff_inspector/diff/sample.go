@@ -0,0 +1,58 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/chunk"
+	"go.uber.org/zap"
+)
+
+// isSampling returns whether only a fraction of the chunks are compared by sample-rate.
+func (df *Diff) isSampling() bool {
+	return df.sampleRate > 0 && df.sampleRate < 1
+}
+
+// initSampling decides the seed to select the chunks, which is sample-seed, the seed of the checkpoint, or a random
+// one in order, and records it in the report so that the run can be reproduced.
+func (df *Diff) initSampling() {
+	if !df.isSampling() {
+		return
+	}
+	if df.sampleSeed == 0 {
+		// the chunks after the checkpoint are selected by the same seed as the interrupted run.
+		df.sampleSeed = df.report.SampleSeed
+	}
+	if df.sampleSeed == 0 {
+		df.sampleSeed = time.Now().UnixNano()
+	}
+	log.Warn("only a fraction of the chunks are compared, it's not a full verification",
+		zap.Float64("sample-rate", df.sampleRate), zap.Int64("sample-seed", df.sampleSeed))
+	df.report.SetSampling(df.sampleRate, df.sampleSeed)
+}
+
+// isChunkSampled returns whether the chunk is selected to be compared. The chunk is selected by the seed and its id
+// rather than the order of the chunks, so the same seed selects the same chunks however they are compared
+// concurrently or resumed from the checkpoint.
+func (df *Diff) isChunkSampled(id *chunk.ChunkID) bool {
+	h := fnv.New64a()
+	// the hash is of the legacy form rather than `Encode`, which persists the chunk ids, so the chunks selected by a
+	// seed don't change after upgrading.
+	h.Write([]byte(id.ToString()))
+	return rand.New(rand.NewSource(df.sampleSeed^int64(h.Sum64()))).Float64() < df.sampleRate
+}
@@ -0,0 +1,68 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/report"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampleChunks(t *testing.T) {
+	// sampleChunks compares the chunks sampled by the seed, and returns the result of the table and the ids of
+	// the chunks compared, all of which are different.
+	sampleChunks := func(seed int64) (*report.TableResult, []string) {
+		tables := []*common.TableDiff{{Schema: "test", Table: "t"}}
+		diffs := make(map[int]int)
+		for i := 0; i < mockChunkCnt; i++ {
+			diffs[i] = -1
+		}
+		downstream := &mockSource{tables: tables, blockFrom: mockChunkCnt, diffs: diffs}
+		df := newTestDiff(t, &mockSource{tables: tables, blockFrom: mockChunkCnt}, downstream, func(df *Diff) {
+			df.checkThreadCount = 4
+			df.sampleRate = 0.3
+			df.sampleSeed = seed
+		})
+		df.initSampling()
+		require.NoError(t, df.Equal(context.Background()))
+
+		result := df.report.TableResults["test"]["t"]
+		ids := make([]string, 0, len(result.ChunkMap))
+		for id := range result.ChunkMap {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		return result, ids
+	}
+
+	result, ids := sampleChunks(42)
+	require.Equal(t, mockChunkCnt, result.ChunksTotal)
+	require.Greater(t, result.ChunksSampled, 0)
+	require.Less(t, result.ChunksSampled, mockChunkCnt)
+	require.Equal(t, result.ChunksSampled, result.ChunksDiffered)
+	require.Len(t, ids, result.ChunksSampled)
+	require.False(t, result.DataEqual)
+	// the table is verified by the weakest method of the chunks.
+	require.Equal(t, report.VerificationSampled, result.VerificationMethod)
+
+	// the same seed samples the same chunks however they are compared concurrently.
+	_, sameIDs := sampleChunks(42)
+	require.Equal(t, ids, sameIDs)
+	_, otherIDs := sampleChunks(7)
+	require.NotEqual(t, ids, otherIDs)
+}
@@ -0,0 +1,103 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/fixsql"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/utils"
+	"github.com/pingcap/tidb/parser/model"
+	"go.uber.org/zap"
+)
+
+// structFixHeader is the header of `fixsql.StructFixFile`.
+const structFixHeader = "-- the statements to make the structures of the target match the source generated by generate-struct-fix.\n" +
+	"-- they are never applied automatically, please review them before applying them, especially the ones with the warnings.\n"
+
+// setTableStructFix records the statements to make the structure of the target table match the source tables in
+// the report. The statements aren't generated if the source tables have the different structures.
+func (df *Diff) setTableStructFix(table *common.TableDiff, sourceTableInfos []*model.TableInfo) {
+	statements, err := utils.GenerateStructFix(table.Schema, table.Table, sourceTableInfos, table.Info)
+	if err != nil {
+		log.Warn("failed to generate the statements to make the structure of the target match the source",
+			zap.String("table", dbutil.TableName(table.Schema, table.Table)), zap.Error(err))
+		return
+	}
+	if len(statements) > 0 {
+		df.report.SetTableStructFix(table.Schema, table.Table, statements)
+	}
+}
+
+// setMissingTableStructFix records the statement to create the table only on the sources by the structure of its
+// first source table, or to drop the table only on the target in the report.
+func (df *Diff) setMissingTableStructFix(ctx context.Context, cfg *config.Config, table *common.MissingTable) {
+	if !table.MissingOnTarget {
+		df.report.SetMissingTableStructFix(table.Schema, table.Table, utils.GenerateDropTableFix(table.Schema, table.Table))
+		return
+	}
+	tableInfo, err := utils.GetTableInfo(ctx, cfg.Task.SourceInstances[table.SourceIndex].Conn, table.SourceSchema, table.SourceTable)
+	if err != nil {
+		log.Warn("failed to get the structure of the source table to create the table on the target",
+			zap.String("table", dbutil.TableName(table.Schema, table.Table)), zap.String("source table", dbutil.TableName(table.SourceSchema, table.SourceTable)), zap.Error(err))
+		return
+	}
+	df.report.SetMissingTableStructFix(table.Schema, table.Table, utils.GenerateCreateTableFix(table.Schema, table.Table, tableInfo))
+}
+
+// writeStructFix writes the statements of generate-struct-fix of all the tables into `fixsql.StructFixFile` in the
+// fix dir, including the tables compared before resuming from the checkpoint, and the file is recorded in the report.
+// The file of the previous run is removed if there is no statement.
+func (df *Diff) writeStructFix() error {
+	path := filepath.Join(df.FixSQLDir, fixsql.StructFixFile)
+	fixes := df.report.GetStructFixes()
+	if len(fixes) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return errors.Trace(err)
+		}
+		return nil
+	}
+	var b strings.Builder
+	b.WriteString(structFixHeader)
+	for _, fix := range fixes {
+		fmt.Fprintf(&b, "\n-- table: %s.%s\n", escapeComment(fix.Schema), escapeComment(fix.Table))
+		for _, statement := range fix.Statements {
+			b.WriteString(statement.String())
+			b.WriteString("\n")
+		}
+	}
+	w, err := df.fixSQLSink.Create(fixsql.StructFixFile)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if _, err := w.Write([]byte(b.String())); err != nil {
+		w.Close()
+		return errors.Trace(err)
+	}
+	if err := w.Close(); err != nil {
+		return errors.Trace(err)
+	}
+	df.report.SetStructFixFile(path)
+	log.Info("write the statements to make the structures of the target match the source", zap.String("file", path), zap.Int("table count", len(fixes)))
+	return nil
+}
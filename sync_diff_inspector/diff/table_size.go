@@ -0,0 +1,143 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/checkpoints"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/utils"
+	"github.com/siddontang/go/ioutil2"
+	"go.uber.org/zap"
+)
+
+// tableSizeSkipReason returns the reason to skip the data check of the table by its size, empty if the table
+// should be compared. The size is 0 if the table is not analyzed, which is decided by `zeroSizePolicy`.
+func tableSizeSkipReason(size, sizeMin, sizeMax int64, zeroSizePolicy string) string {
+	if size == 0 {
+		if zeroSizePolicy == config.ZeroSizePolicyExclude {
+			return "skipped: size is 0 in the statistics"
+		}
+		return ""
+	}
+	if sizeMax > 0 && size > sizeMax {
+		return fmt.Sprintf("skipped: size %s > max %s", utils.HumanizeBytes(size), utils.HumanizeBytes(sizeMax))
+	}
+	if size < sizeMin {
+		return fmt.Sprintf("skipped: size %s < min %s", utils.HumanizeBytes(size), utils.HumanizeBytes(sizeMin))
+	}
+	return ""
+}
+
+// checkTableSize returns the reason to skip the data check of the table by table-size-min and table-size-max,
+// empty if the table should be compared. The size is estimated by the target.
+func (df *Diff) checkTableSize(ctx context.Context, table *common.TableDiff) string {
+	if df.ignoreDataCheck || (df.tableSizeMin == 0 && df.tableSizeMax == 0) {
+		return ""
+	}
+	tableName := dbutil.TableName(table.Schema, table.Table)
+	size, err := df.tableSizes.GetTableSize(ctx, table.Schema, table.Table)
+	if err != nil {
+		// the size is only an estimation, so compare the table rather than skip it silently.
+		log.Warn("failed to get the table size, the table is compared", zap.String("table", tableName), zap.Error(err))
+		return ""
+	}
+	if size == 0 && df.zeroSizePolicy == config.ZeroSizePolicyWarnAndInclude {
+		log.Warn("the size of the table is 0 in the statistics, the table may be not analyzed, and it's compared", zap.String("table", tableName))
+	}
+	reason := tableSizeSkipReason(size, df.tableSizeMin, df.tableSizeMax, df.zeroSizePolicy)
+	if len(reason) > 0 {
+		log.Info("skip the data check of the table by the table size", zap.String("table", tableName), zap.String("reason", reason))
+	}
+	return reason
+}
+
+// setTableEstimatedRows sets the estimated row count of the table in the statistics of the target in the report,
+// which is compared with the actual rows to warn about the stale statistics. It's not set if the table is compared
+// in a range, because the actual rows are only a part of the table.
+func (df *Diff) setTableEstimatedRows(ctx context.Context, table *common.TableDiff) {
+	if df.rowsEstimateWarnFactor == 0 || table.HasRange() {
+		return
+	}
+	rows, err := utils.GetTableRowsEstimate(ctx, df.downstream.GetDB(), table.Schema, table.Table)
+	if err != nil {
+		log.Warn("failed to get the estimated row count of the table", zap.String("table", dbutil.TableName(table.Schema, table.Table)), zap.Error(err))
+		return
+	}
+	df.report.SetTableEstimatedRows(table.Schema, table.Table, rows)
+}
+
+// orderTables sorts the tables to compare by chunk-order = "size-desc" before the checkpoint is loaded, so the indices
+// of the tables, and then the chunk ids and the checkpoint, follow the order. The tables in the order saved in the
+// checkpoint keep it when resuming, because the sizes may change since then, and the rest follow them by size. The
+// sources share the tables, so they are sorted in place for both.
+func (df *Diff) orderTables(ctx context.Context) error {
+	var savedOrder []string
+	path := filepath.Join(df.CheckpointDir, checkpointFile)
+	if ioutil2.FileExists(path) {
+		var err error
+		if savedOrder, err = checkpoints.LoadTableOrder(path); err != nil {
+			return errors.Annotate(err, "the checkpoint load process failed")
+		}
+	}
+	df.setTableSizesForOrder(ctx)
+	sortTablesBySize(df.workSource.GetTables(), savedOrder)
+	return nil
+}
+
+// sortTablesBySize sorts the tables in the descending order of their sizes, the tables of the same size keep their
+// order. The tables in savedOrder are sorted in it before the others.
+func sortTablesBySize(tables []*common.TableDiff, savedOrder []string) {
+	rank := make(map[string]int, len(savedOrder))
+	for i, name := range savedOrder {
+		rank[name] = i
+	}
+	getRank := func(table *common.TableDiff) int {
+		if i, ok := rank[dbutil.TableName(table.Schema, table.Table)]; ok {
+			return i
+		}
+		return len(savedOrder)
+	}
+	sort.SliceStable(tables, func(i, j int) bool {
+		ri, rj := getRank(tables[i]), getRank(tables[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return tables[i].Size > tables[j].Size
+	})
+}
+
+// setTableSizesForOrder sets the sizes of the tables to compare estimated by the target by chunk-order=size-desc, so
+// the largest tables are compared first. The tables whose sizes fail to get are compared at last.
+func (df *Diff) setTableSizesForOrder(ctx context.Context) {
+	for _, table := range df.workSource.GetTables() {
+		if table.IgnoreDataCheck {
+			continue
+		}
+		size, err := df.tableSizes.GetTableSize(ctx, table.Schema, table.Table)
+		if err != nil {
+			log.Warn("failed to get the table size, the table is compared after the others by chunk-order", zap.String("table", dbutil.TableName(table.Schema, table.Table)), zap.Error(err))
+			continue
+		}
+		table.Size = size
+	}
+}
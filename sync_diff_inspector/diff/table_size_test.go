@@ -0,0 +1,193 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/checkpoints"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/chunk"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/report"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/utils"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestTableSizeSkipReason(t *testing.T) {
+	const gb = int64(1 << 30)
+	require.Equal(t, "skipped: size 52GB > max 10GB", tableSizeSkipReason(52*gb, 0, 10*gb, config.ZeroSizePolicyInclude))
+	require.Equal(t, "skipped: size 1GB < min 10GB", tableSizeSkipReason(gb, 10*gb, 0, config.ZeroSizePolicyInclude))
+	require.Equal(t, "", tableSizeSkipReason(5*gb, gb, 10*gb, config.ZeroSizePolicyInclude))
+	require.Equal(t, "", tableSizeSkipReason(10*gb, 10*gb, 10*gb, config.ZeroSizePolicyExclude))
+
+	// the tables not analyzed are decided by the policy rather than table-size-min.
+	require.Equal(t, "", tableSizeSkipReason(0, gb, 10*gb, config.ZeroSizePolicyInclude))
+	require.Equal(t, "skipped: size is 0 in the statistics", tableSizeSkipReason(0, gb, 10*gb, config.ZeroSizePolicyExclude))
+	require.Equal(t, "", tableSizeSkipReason(0, gb, 10*gb, config.ZeroSizePolicyWarnAndInclude))
+}
+
+func TestCheckTableSize(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	defer log.ReplaceGlobals(zap.New(core), &log.ZapProperties{Core: core, Level: zap.NewAtomicLevelAt(zapcore.InfoLevel)})()
+
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	table := &common.TableDiff{Schema: "test", Table: "t"}
+	df := newTestDiff(t, nil, &mockSource{db: db}, func(df *Diff) { df.tableSizeMax = 10 << 30 })
+
+	// no query if the table size is not limited.
+	df.tableSizeMax = 0
+	require.Equal(t, "", df.checkTableSize(ctx, table))
+	df.tableSizeMax = 10 << 30
+
+	df.tableSizes = utils.NewTableSizeCache(db)
+	mock.ExpectQuery("select table_name, sum\\(data_length\\)").WithArgs("test", "t").WillReturnRows(sqlmock.NewRows([]string{"table_name", "data"}).AddRow("t", 52<<30))
+	require.Equal(t, "skipped: size 52GB > max 10GB", df.checkTableSize(ctx, table))
+
+	for _, c := range []struct {
+		policy string
+		reason string
+		warned bool
+	}{
+		{config.ZeroSizePolicyInclude, "", false},
+		{config.ZeroSizePolicyExclude, "skipped: size is 0 in the statistics", false},
+		{config.ZeroSizePolicyWarnAndInclude, "", true},
+	} {
+		logs.TakeAll()
+		df.zeroSizePolicy = c.policy
+		// the size is cached in a run, so a new cache is used for each policy.
+		df.tableSizes = utils.NewTableSizeCache(db)
+		mock.ExpectQuery("select table_name, sum\\(data_length\\)").WithArgs("test", "t").WillReturnRows(sqlmock.NewRows([]string{"table_name", "data"}).AddRow("t", nil))
+		mock.ExpectQuery("SHOW TABLE STATUS FROM `test`").WillReturnRows(sqlmock.NewRows([]string{"Name", "Data_length"}).AddRow("t", nil))
+		require.Equal(t, c.reason, df.checkTableSize(ctx, table), c.policy)
+		require.Equal(t, c.warned, logs.FilterLevelExact(zapcore.WarnLevel).Len() > 0, c.policy)
+	}
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetTableEstimatedRows(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	table := &common.TableDiff{Schema: "test", Table: "t", Range: "TRUE"}
+	df := newTestDiff(t, nil, &mockSource{db: db, tables: []*common.TableDiff{table}})
+
+	// no query if the warning is disabled.
+	df.setTableEstimatedRows(ctx, table)
+	require.Nil(t, df.report.TableResults["test"]["t"].EstimatedRows)
+
+	// no query if the table is compared in a range.
+	df.rowsEstimateWarnFactor = 2
+	table.Range = "a > 10"
+	df.setTableEstimatedRows(ctx, table)
+	require.Nil(t, df.report.TableResults["test"]["t"].EstimatedRows)
+
+	table.Range = "TRUE"
+	mock.ExpectQuery("SELECT table_rows FROM `information_schema`.`tables`").WithArgs("test", "t").WillReturnRows(sqlmock.NewRows([]string{"table_rows"}).AddRow(1000))
+	df.setTableEstimatedRows(ctx, table)
+	require.Equal(t, int64(1000), *df.report.TableResults["test"]["t"].EstimatedRows)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetTableSizesForOrder(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	tables := []*common.TableDiff{
+		{Schema: "test", Table: "small"},
+		{Schema: "test", Table: "large"},
+		{Schema: "test", Table: "skipped", IgnoreDataCheck: true},
+	}
+	source := &mockSource{db: db, tables: tables}
+	df := newTestDiff(t, nil, source, func(df *Diff) { df.tableSizes = utils.NewTableSizeCache(db) })
+	df.tableSizes.Register("test", "small", "large")
+
+	mock.ExpectQuery("select table_name, sum\\(data_length\\)").WithArgs("test", "large", "small").WillReturnRows(
+		sqlmock.NewRows([]string{"table_name", "data"}).AddRow("small", 1<<20).AddRow("large", 1<<30))
+	df.setTableSizesForOrder(ctx)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Equal(t, int64(1<<20), tables[0].Size)
+	require.Equal(t, int64(1<<30), tables[1].Size)
+	require.Equal(t, int64(0), tables[2].Size)
+}
+
+func TestOrderTablesResume(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	names := func(tables []*common.TableDiff) []string {
+		names := make([]string, 0, len(tables))
+		for _, table := range tables {
+			names = append(names, table.Table)
+		}
+		return names
+	}
+	newDiff := func(sizes map[string]int, tables ...string) (*Diff, sqlmock.Sqlmock) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+		tableDiffs := make([]*common.TableDiff, 0, len(tables))
+		rows := sqlmock.NewRows([]string{"table_name", "data"})
+		for _, table := range tables {
+			tableDiffs = append(tableDiffs, &common.TableDiff{Schema: "test", Table: table})
+			rows.AddRow(table, sizes[table])
+		}
+		mock.ExpectQuery("select table_name, sum\\(data_length\\)").WillReturnRows(rows)
+		source := &mockSource{db: db, tables: tableDiffs}
+		// the checkpoint is shared by the runs.
+		df := newTestDiff(t, nil, source, func(df *Diff) {
+			df.tableSizes = utils.NewTableSizeCache(db)
+			df.report = report.NewReport(&config.TaskConfig{OutputDir: dir})
+			df.CheckpointDir = dir
+		})
+		df.tableSizes.Register("test", tables...)
+		return df, mock
+	}
+
+	// the tables are compared in the descending order of their sizes, so are their indices.
+	df, mock := newDiff(map[string]int{"t0": 10, "t1": 300, "t2": 20}, "t0", "t1", "t2")
+	require.NoError(t, df.orderTables(ctx))
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Equal(t, []string{"t1", "t2", "t0"}, names(df.workSource.GetTables()))
+	// the run is interrupted after the second table.
+	require.NoError(t, df.initCheckpoint())
+	df.closeProgress()
+	c := chunk.NewChunkRange()
+	c.Index = &chunk.ChunkID{TableIndex: 1, ChunkCnt: 1}
+	c.IsFirst, c.IsLast = true, true
+	_, err := df.cp.SaveChunk(ctx, filepath.Join(dir, checkpointFile), &checkpoints.Node{State: checkpoints.SuccessState, ChunkRange: c}, df.report)
+	require.NoError(t, err)
+
+	// the sizes are changed when resuming, but the order saved is kept, so only the last table is compared again.
+	// The table added since the checkpoint follows the saved ones.
+	df, mock = newDiff(map[string]int{"t0": 1000, "t1": 300, "t2": 20, "t3": 2000}, "t0", "t1", "t2", "t3")
+	require.NoError(t, df.orderTables(ctx))
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Equal(t, []string{"t1", "t2", "t0", "t3"}, names(df.workSource.GetTables()))
+	require.NoError(t, df.initCheckpoint())
+	df.closeProgress()
+	require.Equal(t, 1, df.startRange.GetTableIndex())
+	require.Equal(t, "t0", df.workSource.GetTables()[df.startRange.GetTableIndex()+1].Table)
+}
@@ -0,0 +1,48 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/report"
+	"go.uber.org/zap"
+)
+
+// compareViews compares the definitions of the views if `check-views` is enabled, and adds the results to the report.
+// The data of the views is only compared like the tables by check-view-data.
+func (df *Diff) compareViews() {
+	for _, view := range df.views {
+		status := report.ViewNotCompared
+		switch {
+		case view.MissingOnTarget:
+			status = report.ViewMissingOnTarget
+		case len(view.SourceDefinitions) == 0:
+			status = report.ViewMissingOnSource
+		case df.checkViews:
+			status = report.ViewEqual
+			for _, definition := range view.SourceDefinitions {
+				if definition != view.TargetDefinition {
+					log.Warn("the definition of the view is different",
+						zap.String("view", dbutil.TableName(view.Schema, view.View)),
+						zap.String("source", definition),
+						zap.String("target", view.TargetDefinition))
+					status = report.ViewUnequal
+					break
+				}
+			}
+		}
+		df.report.AddViewResult(view.Schema, view.View, status)
+	}
+}
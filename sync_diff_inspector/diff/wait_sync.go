@@ -0,0 +1,96 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/report"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/utils"
+	"go.uber.org/zap"
+)
+
+// initWaitSync builds the client to get the replication positions by wait-sync. The goal of TiCDC is the TSO of the
+// snapshot of the source, or the latest TSO of the source if no snapshot, and the goals of DM are the latest binlog
+// positions of the sources when they're first got.
+func (df *Diff) initWaitSync(ctx context.Context, cfg *config.Config) error {
+	if len(cfg.Task.TargetInstance.Snapshot) > 0 {
+		log.Warn("the target is read at the snapshot, so the data compared doesn't change when the replication catches up",
+			zap.String("snapshot", cfg.Task.TargetInstance.Snapshot))
+	}
+	switch cfg.WaitSync.Type {
+	case config.WaitSyncDM:
+		df.waitSyncClient = utils.NewDMClient(cfg.WaitSync.Addr, cfg.WaitSync.Task)
+	case config.WaitSyncTiCDC:
+		goalTS, err := getSourceTSO(ctx, cfg.Task.SourceInstances[0])
+		if err != nil {
+			return errors.Annotate(err, "failed to get the TSO of the source to wait for")
+		}
+		df.waitSyncClient = utils.NewTiCDCClient(cfg.WaitSync.Addr, cfg.WaitSync.Task, goalTS)
+	}
+	return nil
+}
+
+// getSourceTSO returns the TSO of the snapshot of the TiDB source, or the latest TSO if no snapshot.
+func getSourceTSO(ctx context.Context, source *config.DataSource) (uint64, error) {
+	if len(source.Snapshot) > 0 {
+		return utils.ParseSnapshotToTSO(source.Conn, source.Snapshot)
+	}
+	positions, err := utils.GetSnapshot(ctx, source.Conn)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	if len(positions) != 1 {
+		return 0, errors.Errorf("the source is not TiDB, whose position of `SHOW MASTER STATUS` is the TSO")
+	}
+	tso, err := strconv.ParseUint(positions[0], 10, 64)
+	return tso, errors.Trace(err)
+}
+
+// waitSync waits until the replication catches up by wait-sync, which is the first pass of the data check. If it
+// doesn't catch up before the timeout, the data check of the tables is skipped for the replication lag rather than
+// reporting the misleading diffs.
+func (df *Diff) waitSync(ctx context.Context) {
+	log.Info("wait for the replication to catch up", zap.Duration("timeout", df.waitSyncTimeout))
+	positions, caughtUp := utils.WaitReplication(ctx, df.waitSyncClient, df.waitSyncTimeout, df.waitSyncInterval)
+	if ctx.Err() != nil {
+		return
+	}
+	df.report.SetReplicationPositions(positions, caughtUp)
+	if caughtUp {
+		log.Info("the replication catches up", zap.Reflect("positions", positions))
+		return
+	}
+	log.Warn("the replication doesn't catch up before the timeout, skip the data check of the tables", zap.Reflect("positions", positions))
+	tables := df.downstream.GetTables()
+	tableIndex := 0
+	if df.startRange != nil {
+		// the chunks of the table of the checkpoint are resumed regardless of the skip.
+		tableIndex = df.startRange.ChunkRange.Index.TableIndex + 1
+	}
+	for ; tableIndex < len(tables); tableIndex++ {
+		table := tables[tableIndex]
+		if table.IgnoreDataCheck {
+			continue
+		}
+		log.Warn("skip the data check of the table for the replication lag", zap.String("table", dbutil.TableName(table.Schema, table.Table)))
+		df.report.SetTableDataSkip(table.Schema, table.Table, report.ReplicationLagSkipReason)
+		table.IgnoreDataCheck = true
+	}
+}
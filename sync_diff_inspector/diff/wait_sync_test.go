@@ -0,0 +1,65 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/chunk"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/report"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/splitter"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/utils"
+	"github.com/stretchr/testify/require"
+)
+
+type mockReplicationClient struct {
+	caughtUp bool
+}
+
+func (c *mockReplicationClient) GetPositions(ctx context.Context) ([]*utils.ReplicationPosition, error) {
+	return []*utils.ReplicationPosition{{Source: "test-cf", Goal: "200", Checkpoint: "100", CaughtUp: c.caughtUp}}, nil
+}
+
+func TestWaitSync(t *testing.T) {
+	for _, caughtUp := range []bool{true, false} {
+		tables := []*common.TableDiff{
+			// the table of the checkpoint
+			{Schema: "test", Table: "t1"},
+			{Schema: "test", Table: "t2"},
+			// skipped by the struct check
+			{Schema: "test", Table: "t3", IgnoreDataCheck: true},
+		}
+		df := newTestDiff(t, nil, &mockSource{tables: tables}, func(df *Diff) {
+			df.waitSyncClient = &mockReplicationClient{caughtUp: caughtUp}
+			df.waitSyncTimeout = 50 * time.Millisecond
+			df.waitSyncInterval = 10 * time.Millisecond
+			df.startRange = &splitter.RangeInfo{ChunkRange: &chunk.Range{Index: &chunk.ChunkID{TableIndex: 0}}}
+		})
+		df.waitSync(context.Background())
+
+		require.Equal(t, !caughtUp, df.report.ReplicationLag)
+		require.Equal(t, []*utils.ReplicationPosition{{Source: "test-cf", Goal: "200", Checkpoint: "100", CaughtUp: caughtUp}}, df.report.ReplicationPositions)
+		require.False(t, tables[0].IgnoreDataCheck)
+		require.Equal(t, !caughtUp, tables[1].IgnoreDataCheck)
+		result := df.report.TableResults["test"]["t2"]
+		require.Equal(t, !caughtUp, result.DataSkip)
+		if !caughtUp {
+			require.Equal(t, report.ReplicationLagSkipReason, result.SkipReason)
+		}
+		require.Empty(t, df.report.TableResults["test"]["t3"].SkipReason)
+	}
+}
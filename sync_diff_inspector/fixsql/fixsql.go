@@ -0,0 +1,187 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fixsql
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pingcap/errors"
+)
+
+const (
+	// CompressionNone writes the fix sql files in plain text.
+	CompressionNone = ""
+	// CompressionGzip compresses the fix sql files by gzip.
+	CompressionGzip = "gzip"
+	// CompressionZstd compresses the fix sql files by zstd.
+	CompressionZstd = "zstd"
+
+	// CompletedFile records the completed fix sql files and their sizes,
+	// the files not in it are partially written by an interrupted run.
+	CompletedFile = ".fix_sql_completed"
+
+	// StructFixFile collects the statements to make the structures of the target match the source generated by
+	// generate-struct-fix, which is never applied.
+	StructFixFile = "struct_fix.sql"
+)
+
+var fileExts = []string{".sql.gz", ".sql.zst", ".sql"}
+
+// FileExt returns the extension of the fix sql files for the compression.
+func FileExt(compression string) string {
+	switch compression {
+	case CompressionGzip:
+		return ".sql.gz"
+	case CompressionZstd:
+		return ".sql.zst"
+	default:
+		return ".sql"
+	}
+}
+
+// TrimExt returns the file name without the extension of the fix sql file,
+// and false if the file is not a fix sql file.
+func TrimExt(name string) (string, bool) {
+	for _, ext := range fileExts {
+		if strings.HasSuffix(name, ext) {
+			return strings.TrimSuffix(name, ext), true
+		}
+	}
+	return name, false
+}
+
+// fileNameEscaper escapes the characters of the schema and table names which can't be in the names of the fix sql
+// files, i.e. the path separator and the separator of the ids, so the names with them are still parsed.
+var fileNameEscaper = strings.NewReplacer("%", "%25", "/", "%2F", ":", "%3A")
+
+// TablePrefix returns the prefix of the names of the fix sql files of the table, e.g. `schema:table`. The names
+// with "/", ":" or "%" are escaped like `a%2Fb`, and the others are kept as is.
+func TablePrefix(schema, table string) string {
+	return fileNameEscaper.Replace(schema) + ":" + fileNameEscaper.Replace(table)
+}
+
+// FileName returns the name of the `seq`th fix sql file of the chunk, e.g.
+// `schema:table:0:0-0:1.sql` and `schema:table:0:0-0:1:1.sql.gz`. The first
+// file doesn't have the sequence, so it's compatible with the files without rotation.
+func FileName(prefix string, seq int, compression string) string {
+	if seq == 0 {
+		return prefix + FileExt(compression)
+	}
+	return fmt.Sprintf("%s:%d%s", prefix, seq, FileExt(compression))
+}
+
+// NewReader returns the reader which decompresses the fix sql file by the extension of the name.
+func NewReader(name string, r io.Reader) (io.ReadCloser, error) {
+	switch {
+	case strings.HasSuffix(name, ".sql.gz"):
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return gr, nil
+	case strings.HasSuffix(name, ".sql.zst"):
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return zr.IOReadCloser(), nil
+	default:
+		return io.NopCloser(r), nil
+	}
+}
+
+// ReadFile reads and decompresses the fix sql file.
+func ReadFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer f.Close()
+	r, err := NewReader(path, f)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	return data, errors.Trace(err)
+}
+
+// AppendCompleted records the completed fix sql file into `CompletedFile` in `dir`.
+func AppendCompleted(dir, name string, size int64) error {
+	f, err := os.OpenFile(filepath.Join(dir, CompletedFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	_, err = fmt.Fprintf(f, "%s\t%d\n", name, size)
+	if err != nil {
+		f.Close()
+		return errors.Trace(err)
+	}
+	return errors.Trace(f.Close())
+}
+
+// WriteCompleted replaces `CompletedFile` in `dir` with the completed fix sql files, e.g. the ones kept when resuming.
+func WriteCompleted(dir string, files map[string]int64) error {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var buf strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&buf, "%s\t%d\n", name, files[name])
+	}
+	tmpFile := filepath.Join(dir, CompletedFile+".tmp")
+	if err := os.WriteFile(tmpFile, []byte(buf.String()), 0644); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(os.Rename(tmpFile, filepath.Join(dir, CompletedFile)))
+}
+
+// LoadCompleted returns the map of `file name` => `size` of the completed fix sql files,
+// and false if `CompletedFile` doesn't exist.
+func LoadCompleted(dir string) (map[string]int64, bool, error) {
+	f, err := os.Open(filepath.Join(dir, CompletedFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, false, nil
+		}
+		return nil, false, errors.Trace(err)
+	}
+	defer f.Close()
+	files := make(map[string]int64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) != 2 {
+			// the last line may be partially written.
+			continue
+		}
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		files[fields[0]] = size
+	}
+	return files, true, errors.Trace(scanner.Err())
+}
@@ -0,0 +1,106 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fixsql
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fileSink struct {
+	dir string
+}
+
+func (s *fileSink) Create(name string) (io.WriteCloser, error) {
+	return os.Create(filepath.Join(s.dir, name))
+}
+
+func TestFileName(t *testing.T) {
+	require.Equal(t, "test:t:0:0-0:1.sql", FileName("test:t:0:0-0:1", 0, CompressionNone))
+	require.Equal(t, "test:t:0:0-0:1:2.sql.gz", FileName("test:t:0:0-0:1", 2, CompressionGzip))
+	require.Equal(t, "test:t:0:0-0:1:1.sql.zst", FileName("test:t:0:0-0:1", 1, CompressionZstd))
+
+	for _, name := range []string{"test:t:0:0-0:1.sql", "test:t:0:0-0:1.sql.gz", "test:t:0:0-0:1.sql.zst"} {
+		prefix, ok := TrimExt(name)
+		require.True(t, ok)
+		require.Equal(t, "test:t:0:0-0:1", prefix)
+	}
+	_, ok := TrimExt(CompletedFile)
+	require.False(t, ok)
+}
+
+func TestWriter(t *testing.T) {
+	header := "-- table: test.t\n"
+	stmts := []string{
+		"DELETE FROM `test`.`t` WHERE `a` = 1 LIMIT 1;",
+		"DELETE FROM `test`.`t` WHERE `a` = 2 LIMIT 1;",
+		"DELETE FROM `test`.`t` WHERE `a` = 3 LIMIT 1;",
+	}
+	for _, compression := range []string{CompressionNone, CompressionGzip, CompressionZstd} {
+		dir := t.TempDir()
+		closed := make(map[string]int64)
+		records := new(Records)
+		// the size limit can hold the header and 2 statements.
+		writer := NewWriter(&fileSink{dir: dir}, "test:t:0:0-0:1", header, WriterConfig{
+			MaxSize:     int64(len(header) + 2*(len(stmts[0])+1)),
+			Compression: compression,
+			OnFileClosed: func(meta *FileMeta) error {
+				closed[meta.Name] = meta.Size
+				records.Add(meta)
+				return AppendCompleted(dir, meta.Name, meta.Size)
+			},
+		})
+		for _, stmt := range stmts {
+			require.NoError(t, writer.WriteStatement(stmt))
+		}
+		require.NoError(t, writer.Close())
+
+		names := make([]string, 0, len(closed))
+		totalBytes := int64(0)
+		for name, size := range closed {
+			names = append(names, name)
+			totalBytes += size
+		}
+		sort.Strings(names)
+		require.Equal(t, []string{FileName("test:t:0:0-0:1", 0, compression), FileName("test:t:0:0-0:1", 1, compression)}, names)
+		require.Equal(t, totalBytes, writer.TotalBytes())
+
+		data, err := ReadFile(filepath.Join(dir, names[0]))
+		require.NoError(t, err)
+		require.Equal(t, header+stmts[0]+"\n"+stmts[1]+"\n", string(data))
+		data, err = ReadFile(filepath.Join(dir, names[1]))
+		require.NoError(t, err)
+		require.Equal(t, header+stmts[2]+"\n", string(data))
+
+		completedFiles, ok, err := LoadCompleted(dir)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, closed, completedFiles)
+
+		// the recorded files are the same as the ones read back from the directory.
+		require.True(t, records.Has(names[1]))
+		manifest, err := GenerateManifest(dir, nil)
+		require.NoError(t, err)
+		require.Equal(t, manifest, records.Manifest())
+	}
+
+	_, ok, err := LoadCompleted(t.TempDir())
+	require.NoError(t, err)
+	require.False(t, ok)
+}
@@ -0,0 +1,284 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fixsql
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"go.uber.org/zap"
+)
+
+// ManifestFile lists the fix sql files in the directory with their checksums,
+// so that the directory can be verified after being copied.
+const ManifestFile = "manifest.json"
+
+// FileMeta is the meta of one fix sql file in the manifest.
+type FileMeta struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	// ChunkID is the id of the chunk which generates the file, e.g. `0:0-0:1`,
+	// which is empty for the fix sql file of a table with fix-file-layout = "table".
+	ChunkID    string `json:"chunk-id,omitempty"`
+	Statements int    `json:"statements"`
+}
+
+// Manifest is the content of `ManifestFile`, the files are listed in the order they are applied, i.e. by the name.
+type Manifest struct {
+	Files []*FileMeta `json:"files"`
+}
+
+// Records records the meta of the fix sql files completed in the run, e.g. from `WriterConfig.OnFileClosed`,
+// so that the files written into a sink other than the local directory can be tracked. It's thread-safe, and
+// the zero value is ready to use.
+type Records struct {
+	sync.Mutex
+	files map[string]*FileMeta
+}
+
+// Add records the completed file, which replaces the meta recorded before with the same name.
+func (r *Records) Add(meta *FileMeta) {
+	r.Lock()
+	defer r.Unlock()
+	if r.files == nil {
+		r.files = make(map[string]*FileMeta)
+	}
+	r.files[meta.Name] = meta
+}
+
+// Has returns true if the file of the name is recorded.
+func (r *Records) Has(name string) bool {
+	r.Lock()
+	defer r.Unlock()
+	_, ok := r.files[name]
+	return ok
+}
+
+func (r *Records) get(name string) (*FileMeta, bool) {
+	if r == nil {
+		return nil, false
+	}
+	r.Lock()
+	defer r.Unlock()
+	meta, ok := r.files[name]
+	return meta, ok
+}
+
+// Manifest returns the manifest of the recorded files.
+func (r *Records) Manifest() *Manifest {
+	r.Lock()
+	defer r.Unlock()
+	manifest := &Manifest{Files: make([]*FileMeta, 0, len(r.files))}
+	for _, meta := range r.files {
+		manifest.Files = append(manifest.Files, meta)
+	}
+	sort.Slice(manifest.Files, func(i, j int) bool {
+		return manifest.Files[i].Name < manifest.Files[j].Name
+	})
+	return manifest
+}
+
+// Mismatch is a fix sql file failed to be verified by the manifest.
+type Mismatch struct {
+	Name   string
+	Reason string
+}
+
+// GenerateManifest generates the manifest of the completed fix sql files in `dir`,
+// the files partially written, e.g. still being rotated or compressed at shutdown, are excluded.
+// The files in `recorded`, which can be nil, are listed by their recorded meta without being read again.
+func GenerateManifest(dir string, recorded *Records) (*Manifest, error) {
+	completedFiles, _, err := LoadCompleted(dir)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	names, err := listFiles(dir)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	manifest := &Manifest{Files: make([]*FileMeta, 0, len(names))}
+	for _, name := range names {
+		size, ok := completedFiles[name]
+		if !ok {
+			log.Warn("the fix sql file is not completed, exclude it from the manifest", zap.String("file", name))
+			continue
+		}
+		meta, ok := recorded.get(name)
+		if !ok || meta.Size != size {
+			// the file is written by the previous run, or appended after being recorded.
+			if meta, err = fileMeta(dir, name); err != nil {
+				return nil, errors.Trace(err)
+			}
+		}
+		if meta.Size != size {
+			log.Warn("the size of the fix sql file is changed, exclude it from the manifest", zap.String("file", name), zap.Int64("expected size", size), zap.Int64("size", meta.Size))
+			continue
+		}
+		manifest.Files = append(manifest.Files, meta)
+	}
+	return manifest, nil
+}
+
+// WriteManifest writes the manifest into `ManifestFile` in `dir`.
+func WriteManifest(dir string, manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	tmpFile := filepath.Join(dir, ManifestFile+".tmp")
+	if err = os.WriteFile(tmpFile, data, 0644); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(os.Rename(tmpFile, filepath.Join(dir, ManifestFile)))
+}
+
+// WriteManifestTo writes the manifest into `ManifestFile` created by the sink.
+func WriteManifestTo(sink Sink, manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	w, err := sink.Create(ManifestFile)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if _, err = w.Write(data); err != nil {
+		w.Close()
+		return errors.Trace(err)
+	}
+	return errors.Trace(w.Close())
+}
+
+// LoadManifest loads the manifest from `ManifestFile` in `dir`.
+func LoadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	manifest := new(Manifest)
+	if err = json.Unmarshal(data, manifest); err != nil {
+		return nil, errors.Annotatef(err, "failed to parse %s", ManifestFile)
+	}
+	return manifest, nil
+}
+
+// VerifyManifest re-hashes the fix sql files in `dir` and returns the ones mismatching the manifest,
+// including the files missing in the directory and the files not listed in the manifest.
+func VerifyManifest(dir string, manifest *Manifest) ([]*Mismatch, error) {
+	names, err := listFiles(dir)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	exists := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		exists[name] = struct{}{}
+	}
+	mismatches := make([]*Mismatch, 0)
+	listed := make(map[string]struct{}, len(manifest.Files))
+	for _, expected := range manifest.Files {
+		listed[expected.Name] = struct{}{}
+		if _, ok := exists[expected.Name]; !ok {
+			mismatches = append(mismatches, &Mismatch{Name: expected.Name, Reason: "file is missing"})
+			continue
+		}
+		size, checksum, err := hashFile(dir, expected.Name)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		switch {
+		case size != expected.Size:
+			mismatches = append(mismatches, &Mismatch{Name: expected.Name, Reason: fmt.Sprintf("size is %d, expected %d", size, expected.Size)})
+		case checksum != expected.SHA256:
+			mismatches = append(mismatches, &Mismatch{Name: expected.Name, Reason: "sha256 mismatches"})
+		}
+	}
+	for _, name := range names {
+		if _, ok := listed[name]; !ok {
+			mismatches = append(mismatches, &Mismatch{Name: name, Reason: fmt.Sprintf("file is not listed in %s", ManifestFile)})
+		}
+	}
+	return mismatches, nil
+}
+
+// listFiles returns the fix sql files in `dir`, sorted by name.
+func listFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if prefix, ok := TrimExt(entry.Name()); entry.IsDir() || !ok || (len(strings.Split(prefix, ":")) < 5 && !IsTableFile(entry.Name())) {
+			// not a fix sql file, e.g. `failed.sql` of the apply.
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func fileMeta(dir, name string) (*FileMeta, error) {
+	size, checksum, err := hashFile(dir, name)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	content, err := ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &FileMeta{
+		Name:       name,
+		Size:       size,
+		SHA256:     checksum,
+		ChunkID:    chunkIDFromName(name),
+		Statements: len(SplitStatements(string(content))),
+	}, nil
+}
+
+// hashFile returns the size and the sha256 of the file.
+func hashFile(dir, name string) (int64, string, error) {
+	f, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return 0, "", errors.Trace(err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return 0, "", errors.Trace(err)
+	}
+	return size, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// chunkIDFromName returns the chunk id in the name like `schema:table:0:0-0:1:1.sql`.
+func chunkIDFromName(name string) string {
+	prefix, _ := TrimExt(name)
+	ids := strings.Split(prefix, ":")
+	if len(ids) < 5 {
+		return ""
+	}
+	return strings.Join(ids[2:5], ":")
+}
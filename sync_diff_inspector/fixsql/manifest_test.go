@@ -0,0 +1,80 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fixsql
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifest(t *testing.T) {
+	dir := t.TempDir()
+	content := "-- table: test.t\nDELETE FROM `test`.`t` WHERE `a` = 1 LIMIT 1;\nDELETE FROM `test`.`t` WHERE `a` = 2 LIMIT 1;\n"
+	for _, name := range []string{"test:t:0:0-0:1.sql", "test:t:0:0-0:1:1.sql", "test:t:0:1-0:1.sql"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	require.NoError(t, AppendCompleted(dir, "test:t:0:0-0:1.sql", int64(len(content))))
+	require.NoError(t, AppendCompleted(dir, "test:t:0:0-0:1:1.sql", int64(len(content))))
+	// the file is still being written at shutdown.
+	require.NoError(t, AppendCompleted(dir, "test:t:0:1-0:1.sql", int64(len(content)+1)))
+
+	manifest, err := GenerateManifest(dir, nil)
+	require.NoError(t, err)
+	require.Len(t, manifest.Files, 2)
+	require.Equal(t, "test:t:0:0-0:1.sql", manifest.Files[0].Name)
+	require.Equal(t, "0:0-0:1", manifest.Files[0].ChunkID)
+	require.Equal(t, "0:0-0:1", manifest.Files[1].ChunkID)
+	require.Equal(t, 2, manifest.Files[0].Statements)
+	require.Equal(t, int64(len(content)), manifest.Files[0].Size)
+
+	// the recorded file isn't read again.
+	recorded := new(Records)
+	recorded.Add(&FileMeta{Name: "test:t:0:0-0:1.sql", Size: int64(len(content)), SHA256: "recorded"})
+	recordedManifest, err := GenerateManifest(dir, recorded)
+	require.NoError(t, err)
+	require.Equal(t, "recorded", recordedManifest.Files[0].SHA256)
+	require.Equal(t, manifest.Files[1], recordedManifest.Files[1])
+	require.NoError(t, WriteManifest(dir, manifest))
+
+	loaded, err := LoadManifest(dir)
+	require.NoError(t, err)
+	require.Equal(t, manifest, loaded)
+	require.NoError(t, os.Remove(filepath.Join(dir, "test:t:0:1-0:1.sql")))
+	mismatches, err := VerifyManifest(dir, loaded)
+	require.NoError(t, err)
+	require.Empty(t, mismatches)
+
+	// tamper one file, truncate one file and add an unlisted file.
+	tampered := []byte(content)
+	tampered[len(tampered)-3] = '2'
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "test:t:0:0-0:1.sql"), tampered, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "test:t:0:0-0:1:1.sql"), []byte(content[:10]), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "test:t:0:2-0:1.sql"), []byte(content), 0644))
+	mismatches, err = VerifyManifest(dir, loaded)
+	require.NoError(t, err)
+	require.Equal(t, []*Mismatch{
+		{Name: "test:t:0:0-0:1.sql", Reason: "sha256 mismatches"},
+		{Name: "test:t:0:0-0:1:1.sql", Reason: fmt.Sprintf("size is 10, expected %d", len(content))},
+		{Name: "test:t:0:2-0:1.sql", Reason: "file is not listed in manifest.json"},
+	}, mismatches)
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "test:t:0:0-0:1.sql")))
+	mismatches, err = VerifyManifest(dir, loaded)
+	require.NoError(t, err)
+	require.Equal(t, "file is missing", mismatches[0].Reason)
+}
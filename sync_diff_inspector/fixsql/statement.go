@@ -0,0 +1,78 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fixsql
+
+import (
+	"strings"
+)
+
+// SplitStatements splits the content of a fix sql file into statements.
+// The line comments(`-- ...` and `# ...`) are dropped, the block comments
+// are kept with the following statement, e.g. the annotation of `REPLACE`.
+func SplitStatements(content string) []string {
+	var (
+		stmts []string
+		sb    strings.Builder
+
+		inQuote   byte
+		inComment bool
+	)
+	appendStmt := func() {
+		stmt := strings.TrimSpace(sb.String())
+		if len(stmt) > 0 {
+			stmts = append(stmts, stmt)
+		}
+		sb.Reset()
+	}
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case inComment:
+			sb.WriteByte(c)
+			if c == '*' && i+1 < len(content) && content[i+1] == '/' {
+				sb.WriteByte('/')
+				i++
+				inComment = false
+			}
+		case inQuote != 0:
+			sb.WriteByte(c)
+			if c == '\\' && inQuote != '`' && i+1 < len(content) {
+				sb.WriteByte(content[i+1])
+				i++
+			} else if c == inQuote {
+				inQuote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			sb.WriteByte(c)
+			inQuote = c
+		case c == '/' && i+1 < len(content) && content[i+1] == '*':
+			sb.WriteString("/*")
+			i++
+			inComment = true
+		case c == '#' || (c == '-' && strings.HasPrefix(content[i:], "-- ")) || (c == '-' && strings.HasPrefix(content[i:], "--\n")):
+			// skip the line comment
+			for i < len(content) && content[i] != '\n' {
+				i++
+			}
+			sb.WriteByte('\n')
+		case c == ';':
+			sb.WriteByte(c)
+			appendStmt()
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	appendStmt()
+	return stmts
+}
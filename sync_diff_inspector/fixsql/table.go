@@ -0,0 +1,189 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fixsql
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pingcap/errors"
+)
+
+// chunkBlockPrefix starts the block of each chunk in the fix sql file of the table, followed by the chunk id
+// and the size of the block, e.g. `-- chunk: 0:0-0:1, 128 bytes`.
+const chunkBlockPrefix = "-- chunk: "
+
+// TableFileName returns the name of the fix sql file of the table with fix-file-layout = "table",
+// e.g. `schema:table.sql`.
+func TableFileName(schema, table, compression string) string {
+	return TablePrefix(schema, table) + FileExt(compression)
+}
+
+// IsTableFile returns whether the name is the fix sql file of a table rather than a chunk.
+func IsTableFile(name string) bool {
+	prefix, ok := TrimExt(name)
+	return ok && len(strings.Split(prefix, ":")) == 2
+}
+
+// AppendChunk appends the fix sql of the chunk `chunkID` to the fix sql file of the table at `path` as a block,
+// with the header in the block. The block is compressed separately, so the file is still valid after being appended.
+// It returns the size of the file and the bytes appended.
+func AppendChunk(path, chunkID, header string, stmts []string, compression string) (int64, int64, error) {
+	body := &strings.Builder{}
+	body.WriteString(header)
+	for _, stmt := range stmts {
+		body.WriteString(stmt)
+		body.WriteString("\n")
+	}
+	block, err := compress(fmt.Sprintf("%s%s, %d bytes\n%s", chunkBlockPrefix, chunkID, body.Len(), body.String()), compression)
+	if err != nil {
+		return 0, 0, errors.Trace(err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, 0, errors.Trace(err)
+	}
+	if _, err = f.Write(block); err != nil {
+		f.Close()
+		return 0, 0, errors.Trace(err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return 0, 0, errors.Trace(err)
+	}
+	return info.Size(), int64(len(block)), errors.Trace(f.Close())
+}
+
+// FilterChunks keeps the blocks of the chunks which `keep` returns true in the fix sql file of the table, which is
+// used to remove the fix sql of the chunks after the checkpoint when resuming. The file is truncated to `size` first
+// to drop the block partially written, and -1 means the whole file. The new size is returned, and the file is
+// rewritten only if any block is removed. 0 is returned if no block is kept, and the file is left to the caller.
+func FilterChunks(path string, size int64, keep func(chunkID string) (bool, error)) (int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	if size >= 0 && info.Size() > size {
+		if err = os.Truncate(path, size); err != nil {
+			return 0, errors.Trace(err)
+		}
+	} else {
+		size = info.Size()
+	}
+	data, err := ReadFile(path)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	content := string(data)
+	kept := &strings.Builder{}
+	removed := false
+	for len(content) > 0 {
+		chunkID, n, err := parseChunkBlock(content)
+		if err != nil {
+			return 0, errors.Annotatef(err, "failed to parse the fix sql file %s", path)
+		}
+		ok, err := keep(chunkID)
+		if err != nil {
+			return 0, errors.Trace(err)
+		}
+		if ok {
+			kept.WriteString(content[:n])
+		} else {
+			removed = true
+		}
+		content = content[n:]
+	}
+	if !removed {
+		return size, nil
+	}
+	if kept.Len() == 0 {
+		return 0, nil
+	}
+	block, err := compress(kept.String(), compressionOf(path))
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	tmpFile := path + ".tmp"
+	if err = os.WriteFile(tmpFile, block, 0644); err != nil {
+		return 0, errors.Trace(err)
+	}
+	if err = os.Rename(tmpFile, path); err != nil {
+		return 0, errors.Trace(err)
+	}
+	return int64(len(block)), nil
+}
+
+// parseChunkBlock returns the chunk id and the length of the block at the beginning of the content.
+func parseChunkBlock(content string) (string, int, error) {
+	end := strings.IndexByte(content, '\n')
+	if !strings.HasPrefix(content, chunkBlockPrefix) || end < 0 {
+		return "", 0, errors.New("the block of the chunk is not found")
+	}
+	var (
+		chunkID string
+		n       int
+	)
+	header := strings.TrimPrefix(content[:end], chunkBlockPrefix)
+	if _, err := fmt.Sscanf(strings.Replace(header, ",", " ", 1), "%s %d bytes", &chunkID, &n); err != nil {
+		return "", 0, errors.Annotatef(err, "invalid block header %q", header)
+	}
+	if end+1+n > len(content) {
+		return "", 0, errors.Errorf("the block of the chunk %s is truncated", chunkID)
+	}
+	return chunkID, end + 1 + n, nil
+}
+
+// compress compresses the content as a whole gzip member or zstd frame, which can be concatenated.
+func compress(content string, compression string) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	var w io.WriteCloser
+	switch compression {
+	case CompressionGzip:
+		w = gzip.NewWriter(buf)
+	case CompressionZstd:
+		zw, err := zstd.NewWriter(buf)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		w = zw
+	default:
+		return []byte(content), nil
+	}
+	if _, err := io.WriteString(w, content); err != nil {
+		w.Close()
+		return nil, errors.Trace(err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// compressionOf returns the compression of the fix sql file by the extension of the name.
+func compressionOf(name string) string {
+	switch {
+	case strings.HasSuffix(name, ".sql.gz"):
+		return CompressionGzip
+	case strings.HasSuffix(name, ".sql.zst"):
+		return CompressionZstd
+	default:
+		return CompressionNone
+	}
+}
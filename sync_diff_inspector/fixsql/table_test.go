@@ -0,0 +1,80 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fixsql
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableFile(t *testing.T) {
+	require.Equal(t, "test:t.sql.gz", TableFileName("test", "t", CompressionGzip))
+	require.True(t, IsTableFile("test:t.sql.zst"))
+	require.False(t, IsTableFile("test:t:0:0-0:1.sql"))
+	require.False(t, IsTableFile("failed.sql"))
+	// the separators in the names are escaped.
+	require.Equal(t, "a%2Fb:t%3A1%25.sql", TableFileName("a/b", "t:1%", CompressionNone))
+	require.True(t, IsTableFile(TableFileName("a/b", "t:1%", CompressionNone)))
+	require.Equal(t, "0:0-0:1", chunkIDFromName(FileName(TablePrefix("a:b", "t`1")+":0:0-0:1:1", 0, CompressionNone)))
+
+	header := "-- table: test.t\n"
+	for _, compression := range []string{CompressionNone, CompressionGzip, CompressionZstd} {
+		dir := t.TempDir()
+		path := filepath.Join(dir, TableFileName("test", "t", compression))
+		// the chunks are appended in the order they are completed.
+		var sizes []int64
+		for _, id := range []string{"0:0-0:0", "0:0-0:2", "0:0-0:1"} {
+			size, n, err := AppendChunk(path, id, header, []string{"DELETE FROM `test`.`t` WHERE `a` = '" + id + "\n-- chunk: x';"}, compression)
+			require.NoError(t, err)
+			require.Greater(t, n, int64(0))
+			sizes = append(sizes, size)
+		}
+		data, err := ReadFile(path)
+		require.NoError(t, err)
+		stmts := SplitStatements(string(data))
+		require.Len(t, stmts, 3)
+		require.Equal(t, "DELETE FROM `test`.`t` WHERE `a` = '0:0-0:2\n-- chunk: x';", stmts[1])
+
+		// the chunk partially written is dropped, and so are the chunks after the checkpoint.
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+		require.NoError(t, err)
+		_, err = f.WriteString("partial")
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+		size, err := FilterChunks(path, sizes[2], func(id string) (bool, error) {
+			return id != "0:0-0:2", nil
+		})
+		require.NoError(t, err)
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		require.Equal(t, info.Size(), size)
+		data, err = ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			"DELETE FROM `test`.`t` WHERE `a` = '0:0-0:0\n-- chunk: x';",
+			"DELETE FROM `test`.`t` WHERE `a` = '0:0-0:1\n-- chunk: x';",
+		}, SplitStatements(string(data)))
+
+		// the file isn't rewritten if all the chunks are kept.
+		newSize, err := FilterChunks(path, -1, func(string) (bool, error) { return true, nil })
+		require.NoError(t, err)
+		require.Equal(t, size, newSize)
+		newSize, err = FilterChunks(path, -1, func(string) (bool, error) { return false, nil })
+		require.NoError(t, err)
+		require.Equal(t, int64(0), newSize)
+	}
+}
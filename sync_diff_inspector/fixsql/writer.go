@@ -0,0 +1,192 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fixsql
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pingcap/errors"
+)
+
+// Sink creates the fix sql files, e.g. `report.ReportSink`.
+type Sink interface {
+	Create(name string) (io.WriteCloser, error)
+}
+
+// WriterConfig is the config of the Writer.
+type WriterConfig struct {
+	// MaxSize is the max size of the uncompressed content of one file, 0 means no limit.
+	MaxSize int64
+	// Compression is one of `CompressionNone`, `CompressionGzip` and `CompressionZstd`.
+	Compression string
+	// OnFileClosed is called after each file is closed with the meta of the content written into the sink,
+	// so the file can be tracked without reading it back from the sink.
+	OnFileClosed func(meta *FileMeta) error
+}
+
+// Writer writes the fix sql of one chunk. It rotates to a new file when the
+// size exceeds `MaxSize`, and the header is written at the beginning of each file.
+type Writer struct {
+	sink   Sink
+	prefix string
+	header string
+	cfg    WriterConfig
+
+	seq  int
+	name string
+	// size is the uncompressed size of the current file.
+	size int64
+	// statements is the number of the statements in the current file.
+	statements int
+	file       io.WriteCloser
+	// counter counts and hashes the bytes written into the sink.
+	counter  *countingWriter
+	compress io.WriteCloser
+
+	totalBytes int64
+}
+
+// NewWriter returns a Writer writing the files named by `FileName(prefix, seq, compression)`.
+func NewWriter(sink Sink, prefix, header string, cfg WriterConfig) *Writer {
+	return &Writer{
+		sink:   sink,
+		prefix: prefix,
+		header: header,
+		cfg:    cfg,
+	}
+}
+
+// WriteStatement writes the statement followed by a newline.
+func (w *Writer) WriteStatement(stmt string) error {
+	if w.file != nil && w.cfg.MaxSize > 0 && w.size+int64(len(stmt))+1 > w.cfg.MaxSize && w.size > int64(len(w.header)) {
+		if err := w.closeFile(); err != nil {
+			return errors.Trace(err)
+		}
+		w.seq++
+	}
+	if w.file == nil {
+		if err := w.openFile(); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return errors.Trace(w.write(stmt + "\n"))
+}
+
+// Close closes the current file.
+func (w *Writer) Close() error {
+	if w.file == nil {
+		return nil
+	}
+	return errors.Trace(w.closeFile())
+}
+
+// Abort closes the current file after a failed write, which isn't recorded by OnFileClosed because it's partial.
+func (w *Writer) Abort() {
+	if w.file == nil {
+		return
+	}
+	if w.compress != nil {
+		w.compress.Close()
+	}
+	w.file.Close()
+	w.file = nil
+}
+
+// TotalBytes returns the bytes written into the sink, which are compressed if needed.
+func (w *Writer) TotalBytes() int64 {
+	return w.totalBytes
+}
+
+func (w *Writer) openFile() error {
+	w.name = FileName(w.prefix, w.seq, w.cfg.Compression)
+	file, err := w.sink.Create(w.name)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	w.file = file
+	w.counter = &countingWriter{w: file, h: sha256.New()}
+	switch w.cfg.Compression {
+	case CompressionGzip:
+		w.compress = gzip.NewWriter(w.counter)
+	case CompressionZstd:
+		zw, err := zstd.NewWriter(w.counter)
+		if err != nil {
+			file.Close()
+			w.file = nil
+			return errors.Trace(err)
+		}
+		w.compress = zw
+	default:
+		w.compress = nil
+	}
+	w.size = 0
+	w.statements = 0
+	return errors.Trace(w.write(w.header))
+}
+
+func (w *Writer) write(s string) error {
+	var err error
+	if w.compress != nil {
+		_, err = io.WriteString(w.compress, s)
+	} else {
+		_, err = io.WriteString(w.counter, s)
+	}
+	w.size += int64(len(s))
+	w.statements += len(SplitStatements(s))
+	return errors.Trace(err)
+}
+
+func (w *Writer) closeFile() error {
+	if w.compress != nil {
+		if err := w.compress.Close(); err != nil {
+			w.file.Close()
+			w.file = nil
+			return errors.Trace(err)
+		}
+	}
+	err := w.file.Close()
+	w.file = nil
+	if err != nil {
+		return errors.Trace(err)
+	}
+	w.totalBytes += w.counter.n
+	if w.cfg.OnFileClosed != nil {
+		return errors.Trace(w.cfg.OnFileClosed(&FileMeta{
+			Name:       w.name,
+			Size:       w.counter.n,
+			SHA256:     hex.EncodeToString(w.counter.h.Sum(nil)),
+			ChunkID:    chunkIDFromName(w.name),
+			Statements: w.statements,
+		}))
+	}
+	return nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	h hash.Hash
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.h.Write(p[:n])
+	return n, err
+}
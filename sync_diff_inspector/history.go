@@ -0,0 +1,82 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/history"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/report"
+	"go.uber.org/zap"
+)
+
+// listHistory prints the runs of the task in the history, and the tables newly unequal or newly healed in the
+// newest run by `--compare-with-previous`. It returns false if the history fails to load.
+func listHistory(cfg *config.Config, w io.Writer) bool {
+	taskName := cfg.HistoryTask
+	if len(taskName) == 0 {
+		taskName = cfg.GetTaskName()
+	}
+	dir := cfg.GetHistoryDir(taskName)
+	runs, err := history.List(dir)
+	if err != nil {
+		fmt.Fprintf(w, "Fail to load the history.\n%s\n", err.Error())
+		return false
+	}
+	if len(runs) == 0 {
+		fmt.Fprintf(w, "No run of the task %s in the history %s\n", taskName, dir)
+		return true
+	}
+	history.Print(w, runs)
+	if cfg.CompareWithPrevious {
+		printChangesFromPrevious(w, runs)
+	}
+	return true
+}
+
+// archiveRun archives the outputs of the run into the history by keep-history, the failure is only logged and
+// doesn't change the result.
+func archiveRun(cfg *config.Config, r *report.Report, w io.Writer) {
+	dir := cfg.GetHistoryDir(cfg.GetTaskName())
+	runDir, err := history.Archive(dir, cfg.GetTaskName(), r, []byte(cfg.String()), cfg.KeepLast, cfg.Task.DirPerm())
+	if err != nil {
+		fmt.Fprintf(w, "There is something error when archive the run into the history, please check log info in %s\n", filepath.Join(cfg.Task.OutputDir, config.LogFileName))
+		log.Warn("failed to archive the run into the history", zap.Error(err))
+		return
+	}
+	log.Info("the run is archived into the history", zap.String("dir", runDir))
+	if !cfg.CompareWithPrevious {
+		return
+	}
+	runs, err := history.List(dir)
+	if err != nil {
+		log.Warn("failed to load the history", zap.Error(err))
+		return
+	}
+	printChangesFromPrevious(w, runs)
+}
+
+func printChangesFromPrevious(w io.Writer, runs []*history.Run) {
+	delta := history.CompareWithPrevious(runs)
+	if delta == nil {
+		fmt.Fprintf(w, "There is no previous run to compare with\n")
+		return
+	}
+	fmt.Fprintf(w, "Compared with the previous run %s:\n", runs[len(runs)-2].Name)
+	delta.PrintNewlyChanged(w)
+}
@@ -0,0 +1,255 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/report"
+	"go.uber.org/zap"
+)
+
+const (
+	summaryFileName = "summary.txt"
+	reportFileName  = "report.json"
+	// ConfigFileName is the snapshot of the config of the run in JSON, whose secrets are masked.
+	ConfigFileName = "config.json"
+	// RunFileName is the metadata of the run, which keeps the result missing in `report.json`.
+	RunFileName = "run.json"
+)
+
+// Run is a run of the task archived in the history. Task and Result are saved in RunFileName, and the rest are
+// parsed from `report.json` of the run.
+type Run struct {
+	Task   string `json:"task"`
+	Result string `json:"result"`
+
+	// Name is the directory of the run, which is the time the run starts, see `config.RunDirName`.
+	Name      string        `json:"-"`
+	Dir       string        `json:"-"`
+	StartTime time.Time     `json:"-"`
+	Duration  time.Duration `json:"-"`
+	// Interrupted means the results of the run are partial.
+	Interrupted  bool  `json:"-"`
+	FailedTables int32 `json:"-"`
+	ErrorTables  int32 `json:"-"`
+	RowsAdd      int   `json:"-"`
+	RowsDelete   int   `json:"-"`
+
+	report *report.Report
+}
+
+// Archive writes the summary and the report committed by the run with the config snapshot into
+// `dir/<start time>/`, links `dir/latest` to it, and prunes the oldest runs beyond keepLast, 0 means no
+// limit. It returns the directory of the run in the history. The directory of the run shouldn't exist, so the
+// runs started in the same second are not mixed.
+func Archive(dir, taskName string, r *report.Report, configSnapshot []byte, keepLast int, perm os.FileMode) (string, error) {
+	name := config.RunDirName(r.StartTime)
+	runDir := filepath.Join(dir, name)
+	if err := os.MkdirAll(dir, perm); err != nil {
+		return "", errors.Trace(err)
+	}
+	if err := os.Mkdir(runDir, perm); err != nil {
+		if os.IsExist(err) {
+			return "", errors.Errorf("the run %s already exists in the history %s", name, dir)
+		}
+		return "", errors.Trace(err)
+	}
+	if err := writeRun(runDir, taskName, r, configSnapshot); err != nil {
+		// the partial run is removed, so the run can be archived again.
+		if removeErr := os.RemoveAll(runDir); removeErr != nil {
+			log.Warn("failed to remove the partial run in the history", zap.String("run", runDir), zap.Error(removeErr))
+		}
+		return "", errors.Trace(err)
+	}
+	if err := config.UpdateLatestLink(dir, name); err != nil {
+		return "", errors.Annotate(err, "failed to update the latest link")
+	}
+	if keepLast > 0 {
+		if err := prune(dir, keepLast); err != nil {
+			return "", errors.Annotate(err, "failed to prune the history")
+		}
+	}
+	return runDir, nil
+}
+
+// writeRun writes the outputs of the run into runDir.
+func writeRun(runDir, taskName string, r *report.Report, configSnapshot []byte) error {
+	// the outputs of the run may not be local, e.g. uploaded by the sink, so they are written from the report.
+	if err := r.ArchiveFiles(report.NewFileSink(runDir)); err != nil {
+		return errors.Trace(err)
+	}
+	if err := os.WriteFile(filepath.Join(runDir, ConfigFileName), configSnapshot, config.LocalFilePerm); err != nil {
+		return errors.Trace(err)
+	}
+	meta, err := json.Marshal(&Run{Task: taskName, Result: r.Result})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(os.WriteFile(filepath.Join(runDir, RunFileName), meta, config.LocalFilePerm))
+}
+
+// runDirs returns the names of the directories of the runs in dir sorted by the start time, the other files and
+// directories, e.g. `latest`, are ignored.
+func runDirs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Trace(err)
+	}
+	startTimes := make(map[string]time.Time, len(entries))
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		startTime, err := config.ParseRunDirName(entry.Name())
+		if err != nil {
+			continue
+		}
+		startTimes[entry.Name()] = startTime
+		names = append(names, entry.Name())
+	}
+	// the names in different time zones aren't sorted by the strings.
+	sort.Slice(names, func(i, j int) bool { return startTimes[names[i]].Before(startTimes[names[j]]) })
+	return names, nil
+}
+
+// prune removes the oldest runs in dir beyond keepLast.
+func prune(dir string, keepLast int) error {
+	names, err := runDirs(dir)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for i := 0; i < len(names)-keepLast; i++ {
+		log.Info("prune the run in the history", zap.String("run", names[i]))
+		if err = os.RemoveAll(filepath.Join(dir, names[i])); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// List returns the runs archived in dir sorted by the start time. The runs whose reports fail to load, e.g. being
+// archived, are skipped.
+func List(dir string) ([]*Run, error) {
+	names, err := runDirs(dir)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	runs := make([]*Run, 0, len(names))
+	for _, name := range names {
+		run, err := loadRun(filepath.Join(dir, name))
+		if err != nil {
+			log.Warn("skip the run failed to load in the history", zap.String("run", name), zap.Error(err))
+			continue
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+// Latest returns the newest run archived in dir, which is nil if there is no run. The runs whose reports fail to
+// load are skipped like List.
+func Latest(dir string) (*Run, error) {
+	names, err := runDirs(dir)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for i := len(names) - 1; i >= 0; i-- {
+		run, err := loadRun(filepath.Join(dir, names[i]))
+		if err != nil {
+			log.Warn("skip the run failed to load in the history", zap.String("run", names[i]), zap.Error(err))
+			continue
+		}
+		return run, nil
+	}
+	return nil, nil
+}
+
+// Report returns the report of the run loaded from `report.json`.
+func (run *Run) Report() *report.Report {
+	return run.report
+}
+
+func loadRun(runDir string) (*Run, error) {
+	run := new(Run)
+	data, err := os.ReadFile(filepath.Join(runDir, RunFileName))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err = json.Unmarshal(data, run); err != nil {
+		return nil, errors.Annotatef(err, "failed to parse %s", RunFileName)
+	}
+	r, err := report.LoadReportFile(filepath.Join(runDir, reportFileName))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	run.Name = filepath.Base(runDir)
+	run.Dir = runDir
+	run.StartTime, _ = config.ParseRunDirName(run.Name)
+	run.Duration = r.Duration
+	run.Interrupted = r.Interrupted
+	run.FailedTables = r.FailedNum
+	run.ErrorTables = r.ErrorNum
+	run.RowsAdd, run.RowsDelete = r.TotalDiffRows()
+	run.report = r
+	return run, nil
+}
+
+// Print prints the runs as a table with the results, the durations and the diff rows.
+func Print(w io.Writer, runs []*Run) {
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetHeader([]string{"Run", "Result", "Duration", "Failed tables", "Errored tables", "Rows add", "Rows delete"})
+	for _, run := range runs {
+		result := run.Result
+		if run.Interrupted {
+			result += " (interrupted)"
+		}
+		table.Append([]string{
+			run.Name,
+			result,
+			run.Duration.Round(time.Second).String(),
+			fmt.Sprint(run.FailedTables),
+			fmt.Sprint(run.ErrorTables),
+			fmt.Sprint(run.RowsAdd),
+			fmt.Sprint(run.RowsDelete),
+		})
+	}
+	table.Render()
+	fmt.Fprint(w, tableString.String())
+}
+
+// CompareWithPrevious returns the changes of the tables in the newest run compared with the previous run, which is
+// nil if there are less than 2 runs.
+func CompareWithPrevious(runs []*Run) *report.ReportDelta {
+	if len(runs) < 2 {
+		return nil
+	}
+	return report.CompareReports(runs[len(runs)-2].report, runs[len(runs)-1].report)
+}
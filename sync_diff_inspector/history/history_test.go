@@ -0,0 +1,124 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package history
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/report"
+	"github.com/stretchr/testify/require"
+)
+
+// commitRun commits the outputs of a run into outputDir, where the table `test`.`t` has rowsDelete rows to delete.
+func commitRun(t *testing.T, outputDir string, startTime time.Time, rowsDelete int) *report.Report {
+	r := report.NewReport(&config.TaskConfig{OutputDir: outputDir})
+	r.StartTime = startTime
+	// the run takes 90s when the summary is committed.
+	r.ElapsedBeforeResume = 90*time.Second - time.Since(startTime)
+	r.TableResults = map[string]map[string]*report.TableResult{
+		"test": {"t": {Schema: "test", Table: "t", StructEqual: true, DataEqual: true, ChunkMap: report.ChunkResults{}}},
+	}
+	if rowsDelete > 0 {
+		r.Result = report.Fail
+		r.TableResults["test"]["t"].DataEqual = false
+		r.TableResults["test"]["t"].ChunkMap["0:0-0:0:1"] = &report.ChunkResult{RowsDelete: rowsDelete}
+	}
+	require.NoError(t, r.CommitSummary())
+	return r
+}
+
+func TestHistory(t *testing.T) {
+	outputDir, dir := t.TempDir(), filepath.Join(t.TempDir(), "nightly")
+	runs, err := List(dir)
+	require.NoError(t, err)
+	require.Empty(t, runs)
+
+	start := time.Date(2021, 10, 1, 1, 0, 0, 0, time.UTC)
+	for i, rowsDelete := range []int{0, 3, 0, 2} {
+		startTime := start.Add(time.Duration(i) * 24 * time.Hour)
+		r := commitRun(t, outputDir, startTime, rowsDelete)
+		runDir, err := Archive(dir, "nightly", r, []byte(`{"check-thread-count":4}`), 3, config.LocalDirPerm)
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(dir, config.RunDirName(startTime)), runDir)
+		// the summary is the same as the output of the run.
+		summary, err := os.ReadFile(filepath.Join(outputDir, summaryFileName))
+		require.NoError(t, err)
+		archived, err := os.ReadFile(filepath.Join(runDir, summaryFileName))
+		require.NoError(t, err)
+		require.Equal(t, string(summary), string(archived))
+		snapshot, err := os.ReadFile(filepath.Join(runDir, ConfigFileName))
+		require.NoError(t, err)
+		require.Equal(t, `{"check-thread-count":4}`, string(snapshot))
+		link, err := os.Readlink(filepath.Join(dir, "latest"))
+		require.NoError(t, err)
+		require.Equal(t, filepath.Base(runDir), link)
+	}
+
+	// the run started in the same second isn't mixed with the archived one.
+	r := commitRun(t, outputDir, start.Add(3*24*time.Hour), 0)
+	_, err = Archive(dir, "nightly", r, nil, 3, config.LocalDirPerm)
+	require.Contains(t, err.Error(), "already exists in the history")
+	// the report which isn't committed can't be archived.
+	r = report.NewReport(&config.TaskConfig{OutputDir: outputDir})
+	r.StartTime = start.Add(4 * 24 * time.Hour)
+	_, err = Archive(dir, "nightly", r, nil, 3, config.LocalDirPerm)
+	require.Contains(t, err.Error(), "isn't committed yet")
+	require.NoDirExists(t, filepath.Join(dir, config.RunDirName(r.StartTime)))
+
+	// the oldest run is pruned by keep-last.
+	runs, err = List(dir)
+	require.NoError(t, err)
+	require.Len(t, runs, 3)
+	require.NoDirExists(t, filepath.Join(dir, config.RunDirName(start)))
+	require.Equal(t, "20211002T010000Z", runs[0].Name)
+	require.Equal(t, "nightly", runs[0].Task)
+	require.Equal(t, report.Fail, runs[0].Result)
+	require.Equal(t, 3, runs[0].RowsDelete)
+	require.Equal(t, int32(1), runs[0].FailedTables)
+	require.Equal(t, report.Pass, runs[1].Result)
+
+	buf := new(bytes.Buffer)
+	Print(buf, runs)
+	require.Regexp(t, "\\| 20211002T010000Z \\| fail +\\| 1m30s +\\| +1 \\| +0 \\| +0 \\| +3 \\|", buf.String())
+	require.Regexp(t, "\\| 20211003T010000Z \\| pass +\\| 1m30s +\\| +0 \\| +0 \\| +0 \\| +0 \\|", buf.String())
+
+	// the newest run is compared with the previous run.
+	delta := CompareWithPrevious(runs)
+	require.Len(t, delta.NewlyFailed(), 1)
+	require.Empty(t, delta.NewlyPassed())
+	require.Nil(t, CompareWithPrevious(runs[:1]))
+
+	latest, err := Latest(dir)
+	require.NoError(t, err)
+	require.Equal(t, "20211004T010000Z", latest.Name)
+	require.Equal(t, 2, latest.Report().TableResults["test"]["t"].ChunkMap["0:0-0:0:1"].RowsDelete)
+
+	// the runs failed to load are skipped.
+	require.NoError(t, os.Remove(filepath.Join(runs[0].Dir, RunFileName)))
+	runs, err = List(dir)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	require.NoError(t, os.Remove(filepath.Join(latest.Dir, RunFileName)))
+	latest, err = Latest(dir)
+	require.NoError(t, err)
+	require.Equal(t, "20211003T010000Z", latest.Name)
+	latest, err = Latest(filepath.Join(t.TempDir(), "nothing"))
+	require.NoError(t, err)
+	require.Nil(t, latest)
+}
@@ -0,0 +1,63 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/report"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistory(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.TaskName = "nightly"
+	cfg.Task.OutputDir = t.TempDir()
+	cfg.HistoryDir = t.TempDir()
+	cfg.CompareWithPrevious = true
+
+	buf := new(bytes.Buffer)
+	require.True(t, listHistory(cfg, buf))
+	require.Contains(t, buf.String(), "No run of the task nightly in the history")
+
+	for i, dataEqual := range []bool{true, false} {
+		r := report.NewReport(&cfg.Task)
+		r.StartTime = time.Date(2021, 10, 1+i, 1, 0, 0, 0, time.UTC)
+		r.TableResults = map[string]map[string]*report.TableResult{
+			"test": {"t": {Schema: "test", Table: "t", StructEqual: true, DataEqual: dataEqual, ChunkMap: report.ChunkResults{}}},
+		}
+		if !dataEqual {
+			r.Result = report.Fail
+			r.TableResults["test"]["t"].ChunkMap["0:0-0:0:1"] = &report.ChunkResult{RowsAdd: 2}
+		}
+		require.NoError(t, r.CommitSummary())
+		buf.Reset()
+		archiveRun(cfg, r, buf)
+	}
+	// the newest run is compared with the previous run after being archived.
+	require.Equal(t, "Compared with the previous run 20211001T010000Z:\n"+
+		"1 tables newly became unequal\n"+
+		"    `test`.`t`: rows add 0 -> 2 (+2), rows delete 0\n"+
+		"0 tables newly healed\n", buf.String())
+
+	buf.Reset()
+	cfg.TaskName, cfg.HistoryTask = "other", "nightly"
+	require.True(t, listHistory(cfg, buf))
+	require.Regexp(t, "\\| 20211001T010000Z \\| pass +\\|", buf.String())
+	require.Regexp(t, "\\| 20211002T010000Z \\| fail +\\|", buf.String())
+	require.Contains(t, buf.String(), "1 tables newly became unequal\n")
+}
@@ -0,0 +1,83 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/utils"
+	"go.uber.org/zap"
+)
+
+// tableEntry is a table discovered by the config, which is printed by `--list-tables`.
+type tableEntry struct {
+	schema        string
+	table         string
+	estimatedRows int64
+	estimatedSize int64
+}
+
+// listTables connects to the databases and prints the tables to compare resolved by the filters and the routes,
+// with the sizes estimated from `information_schema`. Unlike `--dry-run`, the structures aren't compared and
+// the chunks aren't planned. It returns false if any database is unreachable or any table can't be resolved.
+func listTables(ctx context.Context, cfg *config.Config, w io.Writer) bool {
+	downstream, upstream, _, _, err := source.NewSources(ctx, cfg)
+	if err != nil {
+		fmt.Fprintf(w, "Fail to connect to the databases or resolve the tables to compare.\n%s\n", err.Error())
+		log.Error("failed to initialize the sources", zap.Error(err))
+		return false
+	}
+	defer upstream.Close()
+	defer downstream.Close()
+
+	tables := downstream.GetTables()
+	// the sizes of the tables in a schema are got together.
+	sizes := utils.NewTableSizeCache(downstream.GetDB())
+	for _, table := range tables {
+		sizes.Register(table.Schema, table.Table)
+	}
+	entries := make([]*tableEntry, 0, len(tables))
+	for _, table := range tables {
+		entry := &tableEntry{schema: table.Schema, table: table.Table}
+		entry.estimatedRows, err = utils.GetTableRowsEstimate(ctx, downstream.GetDB(), table.Schema, table.Table)
+		if err != nil {
+			log.Warn("failed to estimate the row count", zap.String("table", dbutil.TableName(table.Schema, table.Table)), zap.Error(err))
+		}
+		entry.estimatedSize, err = sizes.GetTableSize(ctx, table.Schema, table.Table)
+		if err != nil {
+			log.Warn("failed to estimate the size", zap.String("table", dbutil.TableName(table.Schema, table.Table)), zap.Error(err))
+		}
+		entries = append(entries, entry)
+	}
+	printTableEntries(w, entries)
+	return true
+}
+
+// printTableEntries prints the tables one per line like `schema.table`, so that the output can be
+// used to write the filters directly. The rows and the sizes are aligned after the name.
+func printTableEntries(w io.Writer, entries []*tableEntry) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "# table\testimated rows\testimated size (bytes)")
+	for _, entry := range entries {
+		fmt.Fprintf(tw, "%s.%s\t%d\t%d\n", entry.schema, entry.table, entry.estimatedRows, entry.estimatedSize)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,32 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintTableEntries(t *testing.T) {
+	buf := &bytes.Buffer{}
+	printTableEntries(buf, []*tableEntry{
+		{schema: "test", table: "t", estimatedRows: 120000, estimatedSize: 7880704},
+		{schema: "test", table: "dim", estimatedRows: 100, estimatedSize: 16384},
+	})
+	require.Equal(t, "# table   estimated rows  estimated size (bytes)\n"+
+		"test.t    120000          7880704\n"+
+		"test.dim  100             16384\n", buf.String())
+}
@@ -15,23 +15,51 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/pingcap/errors"
 	"github.com/pingcap/log"
 	"github.com/pingcap/tidb-tools/pkg/utils"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/apply"
 	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/diff"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/fixsql"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/notify"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/report"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/status"
 	flag "github.com/spf13/pflag"
 	"go.uber.org/zap"
 )
 
 func main() {
 	cfg := config.NewConfig()
-	err := cfg.Parse(os.Args[1:])
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "check-config" {
+		// `check-config` is the alias of `--dry-run`.
+		args = append(args[1:], "--dry-run")
+	}
+	if len(args) > 0 && args[0] == "list-tables" {
+		// `list-tables` is the alias of `--list-tables`.
+		args = append(args[1:], "--list-tables")
+	}
+	if len(args) > 0 && args[0] == "history" {
+		// `history` is the alias of `--history`.
+		args = append(args[1:], "--history")
+	}
+	if len(args) == 3 && args[0] == "compare-reports" {
+		// `compare-reports old new` is the alias of `--compare-reports=old,new`.
+		args = []string{fmt.Sprintf("--compare-reports=%s,%s", args[1], args[2])}
+	}
+	err := cfg.Parse(args)
 	switch errors.Cause(err) {
 	case nil:
 	case flag.ErrHelp:
@@ -47,9 +75,36 @@ func main() {
 		return
 	}
 
+	if len(cfg.CompareReports) > 0 {
+		if !compareReports(cfg.CompareReports[0], cfg.CompareReports[1]) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(cfg.VerifyFixDir) > 0 {
+		if !verifyFixDir(cfg.VerifyFixDir) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if cfg.ListHistory {
+		if !listHistory(cfg, os.Stdout) {
+			os.Exit(1)
+		}
+		return
+	}
+
 	conf := new(log.Config)
 	conf.Level = cfg.LogLevel
+	conf.Format = cfg.LogFormat
 
+	// the output dir is created with output-dir-perm before the log file is created in it.
+	if err = cfg.Task.MkdirOutputDir(); err != nil {
+		fmt.Printf("Fail to create the output dir.\n%s\n", err.Error())
+		os.Exit(2)
+	}
 	conf.File.Filename = filepath.Join(cfg.Task.OutputDir, config.LogFileName)
 	lg, p, e := log.InitLogger(conf)
 	if e != nil {
@@ -75,43 +130,243 @@ func main() {
 
 	log.Info("", zap.Stringer("config", cfg))
 
-	ctx := context.Background()
-	if !checkSyncState(ctx, cfg) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sc := make(chan os.Signal, 1)
+	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-sc
+		log.Info("got signal to exit, stop comparing and save the checkpoint", zap.Stringer("signal", sig))
+		cancel()
+	}()
+
+	if cfg.DryRun {
+		if !checkConfig(ctx, cfg, os.Stdout) {
+			log.Warn("check config failed!!!")
+			os.Exit(1)
+		}
+		log.Info("check config pass!!!")
+		return
+	}
+	if cfg.ListTables {
+		if !listTables(ctx, cfg, os.Stdout) {
+			log.Warn("list tables failed!!!")
+			os.Exit(1)
+		}
+		return
+	}
+	if cfg.IsDebugRange() {
+		if !compareDebugRange(ctx, cfg, os.Stdout) {
+			log.Warn("the range is different or failed to be compared!!!")
+			os.Exit(1)
+		}
+		log.Info("the range is equal!!!")
+		return
+	}
+	if len(cfg.ApplyFixDir) > 0 {
+		if !applyFix(ctx, cfg) {
+			log.Warn("apply fix sql failed!!!")
+			os.Exit(1)
+		}
+		log.Info("apply fix sql finished!!!")
+		return
+	}
+	// the outputs for humans are written to stderr if the report is streamed to stdout.
+	output := os.Stdout
+	if cfg.OutputToStdout {
+		output = os.Stderr
+	}
+	pass, timedOut := checkSyncState(ctx, cfg, output)
+	if timedOut {
+		// the results are incomplete, which is neither pass nor fail.
+		log.Warn("check timed out!!!")
+		os.Exit(3)
+	}
+	if !pass {
 		log.Warn("check failed!!!")
 		os.Exit(1)
 	}
 	log.Info("check pass!!!")
 }
 
-func checkSyncState(ctx context.Context, cfg *config.Config) bool {
+// checkSyncState compares the tables, and returns whether they pass and whether the comparison exceeds run-timeout.
+// The progress and the summary are written to output.
+func checkSyncState(ctx context.Context, cfg *config.Config, output io.Writer) (pass bool, timedOut bool) {
 	beginTime := time.Now()
 	defer func() {
 		log.Info("check data finished", zap.Duration("cost", time.Since(beginTime)))
 	}()
 
-	d, err := NewDiff(ctx, cfg)
+	d, err := diff.New(cfg)
 	if err != nil {
-		fmt.Printf("There is something error when initialize diff, please check log info in %s\n", filepath.Join(cfg.Task.OutputDir, config.LogFileName))
-		log.Fatal("failed to initialize diff process", zap.Error(err))
-		return false
+		fmt.Fprintf(output, "There is something error when initialize diff, please check log info in %s\n", filepath.Join(cfg.Task.OutputDir, config.LogFileName))
+		log.Error("failed to initialize diff process", zap.Error(err))
+		return false, false
+	}
+	if cfg.OutputToStdout {
+		name, _ := cfg.ReportFileName()
+		d.SetReportSink(report.NewTeeSink(report.NewFileSink(cfg.Task.OutputDir), name, os.Stdout))
+	}
+	d.SetProgressOutput(output)
+	d.SetConfirm(func(ctx context.Context, r *report.Report, totalSize int64) bool {
+		return confirmConfig(ctx, output, r, totalSize, cfg.Yes, stdinPrompt())
+	})
+	if len(cfg.StatusAddr) > 0 {
+		configDigest, err := cfg.Task.ComputeConfigHash()
+		if err != nil {
+			log.Error("failed to compute the config digest", zap.Error(err))
+			return false, false
+		}
+		server := status.NewServer(d, configDigest, cfg.StatusToken)
+		d.OnProgress(server.SetProgress)
+		if err := server.Start(cfg.StatusAddr); err != nil {
+			fmt.Fprintf(output, "There is something error when start the status server, please check log info in %s\n", filepath.Join(cfg.Task.OutputDir, config.LogFileName))
+			log.Error("failed to start the status server", zap.Error(err))
+			return false, false
+		}
+		defer server.Close()
+	}
+	r, err := d.Run(ctx)
+	if errors.Cause(err) == diff.ErrNotConfirmed {
+		fmt.Fprintf(output, "The config is not confirmed, nothing is compared\n")
+		return false, false
+	}
+	if r == nil {
+		fmt.Fprintf(output, "There is something error when compare the tables, please check log info in %s\n", filepath.Join(cfg.Task.OutputDir, config.LogFileName))
+		log.Error("failed to compare the tables", zap.Error(err))
+		if cfg.Notify != nil && cfg.Notify.Email != nil {
+			mailError(cfg, err, output)
+		}
+		return false, false
+	}
+	if cfg.CheckStructOnly {
+		fmt.Fprintf(output, "Check table struct only, skip data check\n")
+	}
+	if printErr := r.Print(output); printErr != nil {
+		log.Warn("failed to print the summary", zap.Error(printErr))
+	}
+	if cfg.KeepHistory {
+		archiveRun(cfg, r, output)
 	}
-	defer d.Close()
+	if cfg.Notify != nil && cfg.Notify.Email != nil {
+		mailReport(cfg, r, output)
+	}
+	if len(cfg.TextfileMetricsPath) > 0 {
+		writeTextfileMetrics(cfg, r, output)
+	}
+	// the report of the interrupted comparison is returned with the error.
+	return err == nil && r.Result == report.Pass, r.TimedOut
+}
+
+// mailReport mails the summary and the report by notify.email, the failure is only logged and doesn't change the result.
+func mailReport(cfg *config.Config, r *report.Report, output io.Writer) {
+	if cfg.Notify.Email.AttachMarkdown {
+		if err := r.CommitMarkdown(); err != nil {
+			// the email is still sent, and the missing summary.md is noted in the body.
+			log.Warn("failed to write the markdown summary", zap.Error(err))
+		}
+	}
+	// the email is still sent after the comparison is interrupted by the signal.
+	notifier := notify.NewEmailNotifier(cfg.Notify.Email)
+	if err := notifier.Notify(context.Background(), cfg.GetTaskName(), r.Result, cfg.Task.OutputDir); err != nil {
+		fmt.Fprintf(output, "There is something error when mail the report, please check log info in %s\n", filepath.Join(cfg.Task.OutputDir, config.LogFileName))
+		log.Warn("failed to mail the report", zap.Error(err))
+	}
+}
+
+// mailError mails the error of the run failed without a report by notify.email, the failure is only logged.
+func mailError(cfg *config.Config, runErr error, output io.Writer) {
+	notifier := notify.NewEmailNotifier(cfg.Notify.Email)
+	logFile := filepath.Join(cfg.Task.OutputDir, config.LogFileName)
+	if err := notifier.NotifyError(context.Background(), cfg.GetTaskName(), runErr, logFile); err != nil {
+		fmt.Fprintf(output, "There is something error when mail the error, please check log info in %s\n", logFile)
+		log.Warn("failed to mail the error", zap.Error(err))
+	}
+}
 
-	err = d.StructEqual(ctx)
+// writeTextfileMetrics writes the metrics of the run into textfile-metrics-path, the failure is only logged and doesn't
+// change the result.
+func writeTextfileMetrics(cfg *config.Config, r *report.Report, output io.Writer) {
+	if err := r.WriteTextfileMetrics(cfg.TextfileMetricsPath); err != nil {
+		fmt.Fprintf(output, "There is something error when write the textfile metrics, please check log info in %s\n", filepath.Join(cfg.Task.OutputDir, config.LogFileName))
+		log.Warn("failed to write the textfile metrics", zap.String("path", cfg.TextfileMetricsPath), zap.Error(err))
+		return
+	}
+	log.Info("the textfile metrics are written", zap.String("path", cfg.TextfileMetricsPath))
+}
+
+func applyFix(ctx context.Context, cfg *config.Config) bool {
+	beginTime := time.Now()
+	defer func() {
+		log.Info("apply fix sql finished", zap.Duration("cost", time.Since(beginTime)))
+	}()
+
+	var db *sql.DB
+	if !cfg.ApplyDryRun {
+		instance := cfg.Task.TargetInstance
+		if cfg.FixTarget == config.FixTargetSource {
+			instance = cfg.Task.SourceInstances[0]
+		}
+		var err error
+		db, err = common.CreateDBForCP(ctx, *instance.ToDBConfig())
+		if err != nil {
+			fmt.Printf("There is something error when connect to database, please check log info in %s\n", filepath.Join(cfg.Task.OutputDir, config.LogFileName))
+			log.Error("failed to connect to the fix target", zap.Error(err))
+			return false
+		}
+		defer db.Close()
+	}
+
+	applier := apply.NewApplier(db, cfg.ApplyFixDir, cfg.ApplyBatchSize, cfg.ApplyDryRun)
+	applier.SetForce(cfg.Force)
+	results, err := applier.Apply(ctx)
+	apply.PrintResults(os.Stdout, results, cfg.ApplyDryRun)
 	if err != nil {
-		fmt.Printf("There is something error when compare structure of table, please check log info in %s\n", filepath.Join(cfg.Task.OutputDir, config.LogFileName))
-		log.Fatal("failed to check structure difference", zap.Error(err))
+		fmt.Printf("There is something error when apply fix sql, please check log info in %s\n", filepath.Join(cfg.Task.OutputDir, config.LogFileName))
+		log.Error("failed to apply fix sql", zap.Error(err))
 		return false
 	}
-	if !d.ignoreDataCheck {
-		err = d.Equal(ctx)
-		if err != nil {
-			fmt.Printf("There is something error when compare data of table, please check log info in %s\n", filepath.Join(cfg.Task.OutputDir, config.LogFileName))
-			log.Fatal("failed to check data difference", zap.Error(err))
+	for _, result := range results {
+		if result.Failed > 0 {
 			return false
 		}
-	} else {
-		fmt.Printf("Check table struct only, skip data check\n")
 	}
-	return d.PrintSummary(ctx)
+	return true
+}
+
+func verifyFixDir(dir string) bool {
+	manifest, err := fixsql.LoadManifest(dir)
+	if err != nil {
+		fmt.Printf("Fail to load the manifest.\n%s\n", err.Error())
+		return false
+	}
+	mismatches, err := fixsql.VerifyManifest(dir, manifest)
+	if err != nil {
+		fmt.Printf("Fail to verify the fix sql files.\n%s\n", err.Error())
+		return false
+	}
+	for _, mismatch := range mismatches {
+		fmt.Printf("%s: %s\n", mismatch.Name, mismatch.Reason)
+	}
+	if len(mismatches) > 0 {
+		fmt.Printf("%d of %d fix sql files failed to be verified\n", len(mismatches), len(manifest.Files))
+		return false
+	}
+	fmt.Printf("all the %d fix sql files are verified\n", len(manifest.Files))
+	return true
+}
+
+func compareReports(oldPath, newPath string) bool {
+	oldReport, err := report.LoadReportFile(oldPath)
+	if err != nil {
+		fmt.Printf("Fail to load the old report.\n%s\n", err.Error())
+		return false
+	}
+	newReport, err := report.LoadReportFile(newPath)
+	if err != nil {
+		fmt.Printf("Fail to load the new report.\n%s\n", err.Error())
+		return false
+	}
+	report.CompareReports(oldReport, newReport).Print(os.Stdout)
+	return true
 }
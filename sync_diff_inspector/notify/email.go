@@ -0,0 +1,296 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"go.uber.org/zap"
+)
+
+const (
+	// MaxAttachmentSize is the max total size of the attachments of an email, the attachments beyond it are omitted
+	// and noted in the body, because the SMTP servers usually reject the large emails.
+	MaxAttachmentSize = 10 << 20
+
+	summaryFileName  = "summary.txt"
+	reportFileName   = "report.json"
+	markdownFileName = "summary.md"
+
+	dialTimeout = 30 * time.Second
+	// the base64 lines of the attachments are no longer than 76 characters by RFC 2045.
+	base64LineLength = 76
+)
+
+// Mailer sends the message to the recipients, which is the SMTP client by default and captures the message in the tests.
+type Mailer interface {
+	SendMail(from string, to []string, msg []byte) error
+}
+
+// smtpMailer sends the message to the SMTP server of the config.
+type smtpMailer struct {
+	cfg *config.EmailConfig
+}
+
+// SendMail implements the Mailer interface.
+func (m *smtpMailer) SendMail(from string, to []string, msg []byte) error {
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+	tlsConfig := &tls.Config{ServerName: m.cfg.Host}
+	var (
+		conn net.Conn
+		err  error
+	)
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	if m.cfg.GetTLS() == config.EmailTLSImplicit {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return errors.Trace(err)
+	}
+	client, err := smtp.NewClient(conn, m.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return errors.Trace(err)
+	}
+	defer client.Close()
+	if m.cfg.GetTLS() == config.EmailTLSStartTLS {
+		if err = client.StartTLS(tlsConfig); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if len(m.cfg.User) > 0 {
+		if err = client.Auth(smtp.PlainAuth("", m.cfg.User, m.cfg.Password, m.cfg.Host)); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if err = client.Mail(from); err != nil {
+		return errors.Trace(err)
+	}
+	for _, rcpt := range to {
+		if err = client.Rcpt(rcpt); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if _, err = w.Write(msg); err != nil {
+		w.Close()
+		return errors.Trace(err)
+	}
+	if err = w.Close(); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(client.Quit())
+}
+
+// EmailNotifier mails `summary.txt` inline and `report.json` as the attachment in output-dir when the comparison ends,
+// and `summary.md` is attached too if attach-markdown is set.
+type EmailNotifier struct {
+	cfg    *config.EmailConfig
+	mailer Mailer
+	// retryPolicy retries the failed sending, which may be caused by the transient network errors.
+	retryPolicy *dbutil.RetryPolicy
+	now         func() time.Time
+}
+
+// NewEmailNotifier returns an EmailNotifier sending the email by the SMTP server of the config.
+func NewEmailNotifier(cfg *config.EmailConfig) *EmailNotifier {
+	return &EmailNotifier{
+		cfg:    cfg,
+		mailer: &smtpMailer{cfg: cfg},
+		retryPolicy: &dbutil.RetryPolicy{
+			MaxAttempts: 3,
+			Backoff:     time.Second,
+			MaxBackoff:  10 * time.Second,
+			IsRetryable: func(error) bool { return true },
+		},
+		now: time.Now,
+	}
+}
+
+// SetMailer replaces the SMTP client, e.g. to capture the message in the tests.
+func (n *EmailNotifier) SetMailer(mailer Mailer) {
+	n.mailer = mailer
+}
+
+// Notify mails the summary and the report in dir of the task with the result, and retries the failed sending.
+func (n *EmailNotifier) Notify(ctx context.Context, taskName, result, dir string) error {
+	msg, err := n.buildMessage(taskName, result, dir)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err = n.send(ctx, msg); err != nil {
+		return errors.Trace(err)
+	}
+	log.Info("the report is mailed", zap.Strings("to", n.cfg.To))
+	return nil
+}
+
+// NotifyError mails the error of the task failed before the report is generated, e.g. failed to connect to the
+// databases, with the result "error" and the error as the body, and retries the failed sending.
+func (n *EmailNotifier) NotifyError(ctx context.Context, taskName string, runErr error, logFile string) error {
+	body := fmt.Sprintf("The comparison failed without a report: %s\nYou can view the details through '%s'\n", runErr, logFile)
+	msg, err := n.encodeMessage(taskName, "error", []byte(body), nil)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err = n.send(ctx, msg); err != nil {
+		return errors.Trace(err)
+	}
+	log.Info("the error is mailed", zap.Strings("to", n.cfg.To))
+	return nil
+}
+
+// send sends msg to the recipients, and retries the failed sending.
+func (n *EmailNotifier) send(ctx context.Context, msg []byte) error {
+	from, to := envelopeAddress(n.cfg.From), make([]string, 0, len(n.cfg.To))
+	for _, addr := range n.cfg.To {
+		to = append(to, envelopeAddress(addr))
+	}
+	return errors.Trace(n.retryPolicy.Do(ctx, func() error {
+		return n.mailer.SendMail(from, to, msg)
+	}))
+}
+
+type attachment struct {
+	name        string
+	contentType string
+	data        []byte
+}
+
+// buildMessage builds the MIME message with `summary.txt` as the body, `report.json` and `summary.md` by
+// attach-markdown as the attachments. The attachments beyond MaxAttachmentSize in total are omitted and noted at the
+// end of the body.
+func (n *EmailNotifier) buildMessage(taskName, result, dir string) ([]byte, error) {
+	summary, err := os.ReadFile(filepath.Join(dir, summaryFileName))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	body := bytes.NewBuffer(summary)
+	type file struct {
+		name, contentType string
+		// the optional file is noted in the body if it's missing, otherwise the email isn't sent.
+		optional bool
+	}
+	files := []file{{name: reportFileName, contentType: "application/json"}}
+	if n.cfg.AttachMarkdown {
+		files = append(files, file{name: markdownFileName, contentType: "text/markdown", optional: true})
+	}
+	attachments := make([]*attachment, 0, len(files))
+	size := 0
+	for _, file := range files {
+		data, err := os.ReadFile(filepath.Join(dir, file.name))
+		if file.optional && os.IsNotExist(err) {
+			fmt.Fprintf(body, "\n%s is not attached because it's not written\n", file.name)
+			continue
+		}
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if size+len(data) > MaxAttachmentSize {
+			fmt.Fprintf(body, "\n%s (%d bytes) is not attached because the attachments exceed %d bytes, find it in %s\n", file.name, len(data), MaxAttachmentSize, dir)
+			continue
+		}
+		size += len(data)
+		attachments = append(attachments, &attachment{name: file.name, contentType: file.contentType, data: data})
+	}
+	return n.encodeMessage(taskName, result, body.Bytes(), attachments)
+}
+
+// encodeMessage encodes the MIME message with the body and the attachments, whose subject is of the task and the
+// result.
+func (n *EmailNotifier) encodeMessage(taskName, result string, body []byte, attachments []*attachment) ([]byte, error) {
+	msg := new(bytes.Buffer)
+	w := multipart.NewWriter(msg)
+	header := []struct{ key, value string }{
+		{"From", n.cfg.From},
+		{"To", strings.Join(n.cfg.To, ", ")},
+		{"Subject", mime.QEncoding.Encode("utf-8", fmt.Sprintf("[sync-diff-inspector] %s: %s", taskName, result))},
+		{"Date", n.now().Format(time.RFC1123Z)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": w.Boundary()})},
+	}
+	for _, field := range header {
+		fmt.Fprintf(msg, "%s: %s\r\n", field.key, field.value)
+	}
+	msg.WriteString("\r\n")
+
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	writeBase64(part, body)
+	for _, a := range attachments {
+		part, err = w.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(a.contentType, map[string]string{"name": a.name})},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.name})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		writeBase64(part, a.data)
+	}
+	if err = w.Close(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return msg.Bytes(), nil
+}
+
+// writeBase64 writes data in base64 wrapped by base64LineLength, so that the body and the attachments survive the
+// SMTP servers limiting the line length.
+func writeBase64(w io.Writer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > base64LineLength {
+		w.Write([]byte(encoded[:base64LineLength] + "\r\n"))
+		encoded = encoded[base64LineLength:]
+	}
+	w.Write([]byte(encoded + "\r\n"))
+}
+
+// envelopeAddress returns the bare address of addr like "DBA <dba@example.com>" for the SMTP envelope, addr is
+// validated by the config.
+func envelopeAddress(addr string) string {
+	if address, err := mail.ParseAddress(addr); err == nil {
+		return address.Address
+	}
+	return addr
+}
@@ -0,0 +1,182 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/stretchr/testify/require"
+)
+
+// mockMailer captures the messages, and fails the first `failures` attempts.
+type mockMailer struct {
+	failures int
+	attempts int
+	from     string
+	to       []string
+	msg      []byte
+}
+
+func (m *mockMailer) SendMail(from string, to []string, msg []byte) error {
+	m.attempts++
+	if m.attempts <= m.failures {
+		return errors.New("connection reset by peer")
+	}
+	m.from, m.to, m.msg = from, to, msg
+	return nil
+}
+
+type mailPart struct {
+	contentType string
+	filename    string
+	data        string
+}
+
+// parseMessage returns the header and the decoded parts of the multipart message.
+func parseMessage(t *testing.T, msg []byte) (mail.Header, []*mailPart) {
+	m, err := mail.ReadMessage(bytes.NewReader(msg))
+	require.NoError(t, err)
+	mediaType, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	require.Equal(t, "multipart/mixed", mediaType)
+	r := multipart.NewReader(m.Body, params["boundary"])
+	parts := make([]*mailPart, 0)
+	for {
+		p, err := r.NextPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		require.Equal(t, "base64", p.Header.Get("Content-Transfer-Encoding"))
+		data, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, p))
+		require.NoError(t, err)
+		parts = append(parts, &mailPart{contentType: p.Header.Get("Content-Type"), filename: p.FileName(), data: string(data)})
+	}
+	return m.Header, parts
+}
+
+func newTestNotifier(cfg *config.EmailConfig, mailer Mailer) *EmailNotifier {
+	n := NewEmailNotifier(cfg)
+	n.SetMailer(mailer)
+	n.retryPolicy.Backoff = time.Millisecond
+	n.now = func() time.Time { return time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC) }
+	return n
+}
+
+func TestEmailNotify(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, summaryFileName), []byte("Summary\nThe data of 1 table are not equal\n"), config.LocalFilePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, reportFileName), []byte(`{"result":"fail"}`), config.LocalFilePerm))
+	cfg := &config.EmailConfig{
+		Host: "smtp.example.com",
+		Port: 587,
+		From: "Diff <diff@example.com>",
+		To:   []string{"dba@example.com", "DBA Team <team@example.com>"},
+	}
+
+	// the transient failure is retried.
+	mailer := &mockMailer{failures: 1}
+	require.NoError(t, newTestNotifier(cfg, mailer).Notify(context.Background(), "任务", "fail", dir))
+	require.Equal(t, 2, mailer.attempts)
+	require.Equal(t, "diff@example.com", mailer.from)
+	require.Equal(t, []string{"dba@example.com", "team@example.com"}, mailer.to)
+	header, parts := parseMessage(t, mailer.msg)
+	subject, err := new(mime.WordDecoder).DecodeHeader(header.Get("Subject"))
+	require.NoError(t, err)
+	require.Equal(t, "[sync-diff-inspector] 任务: fail", subject)
+	require.Equal(t, "dba@example.com, DBA Team <team@example.com>", header.Get("To"))
+	require.Equal(t, "Tue, 01 Jun 2021 12:00:00 +0000", header.Get("Date"))
+	require.Len(t, parts, 2)
+	require.Equal(t, "text/plain; charset=utf-8", parts[0].contentType)
+	require.Equal(t, "Summary\nThe data of 1 table are not equal\n", parts[0].data)
+	require.Equal(t, reportFileName, parts[1].filename)
+	require.Equal(t, `{"result":"fail"}`, parts[1].data)
+	// the lines are no longer than the limit of SMTP.
+	for _, line := range strings.Split(string(mailer.msg), "\r\n") {
+		require.LessOrEqual(t, len(line), 998)
+	}
+
+	// the attachments beyond the cap are omitted and noted in the body.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, reportFileName), bytes.Repeat([]byte("x"), MaxAttachmentSize+1), config.LocalFilePerm))
+	mailer = &mockMailer{}
+	require.NoError(t, newTestNotifier(cfg, mailer).Notify(context.Background(), "task", "pass", dir))
+	_, parts = parseMessage(t, mailer.msg)
+	require.Len(t, parts, 1)
+	require.Contains(t, parts[0].data, "report.json (10485761 bytes) is not attached because the attachments exceed 10485760 bytes")
+
+	// the error is returned after the attempts are exhausted.
+	mailer = &mockMailer{failures: 5}
+	err = newTestNotifier(cfg, mailer).Notify(context.Background(), "task", "pass", dir)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "connection reset by peer")
+	require.Equal(t, 3, mailer.attempts)
+
+	// the email isn't sent without the summary.
+	require.NoError(t, os.Remove(filepath.Join(dir, summaryFileName)))
+	mailer = &mockMailer{}
+	require.Error(t, newTestNotifier(cfg, mailer).Notify(context.Background(), "task", "pass", dir))
+	require.Equal(t, 0, mailer.attempts)
+}
+
+func TestEmailAttachMarkdown(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, summaryFileName), []byte("Summary\n"), config.LocalFilePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, reportFileName), []byte(`{"result":"pass"}`), config.LocalFilePerm))
+	cfg := &config.EmailConfig{Host: "smtp.example.com", Port: 587, From: "diff@example.com", To: []string{"dba@example.com"}, AttachMarkdown: true}
+
+	// summary.md missing is noted in the body, and the email is still sent.
+	mailer := &mockMailer{}
+	require.NoError(t, newTestNotifier(cfg, mailer).Notify(context.Background(), "task", "pass", dir))
+	_, parts := parseMessage(t, mailer.msg)
+	require.Len(t, parts, 2)
+	require.Equal(t, "Summary\n\nsummary.md is not attached because it's not written\n", parts[0].data)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, markdownFileName), []byte("# sync-diff-inspector: pass\n"), config.LocalFilePerm))
+	mailer = &mockMailer{}
+	require.NoError(t, newTestNotifier(cfg, mailer).Notify(context.Background(), "task", "pass", dir))
+	_, parts = parseMessage(t, mailer.msg)
+	require.Len(t, parts, 3)
+	require.Equal(t, "Summary\n", parts[0].data)
+	require.Equal(t, markdownFileName, parts[2].filename)
+	require.Equal(t, "text/markdown; name=summary.md", parts[2].contentType)
+	require.Equal(t, "# sync-diff-inspector: pass\n", parts[2].data)
+}
+
+func TestEmailNotifyError(t *testing.T) {
+	cfg := &config.EmailConfig{Host: "smtp.example.com", Port: 587, From: "diff@example.com", To: []string{"dba@example.com"}}
+	mailer := &mockMailer{failures: 1}
+	runErr := errors.New("dial tcp 127.0.0.1:4000: connect: connection refused")
+	require.NoError(t, newTestNotifier(cfg, mailer).NotifyError(context.Background(), "task", runErr, "/tmp/output/sync_diff.log"))
+	require.Equal(t, 2, mailer.attempts)
+	header, parts := parseMessage(t, mailer.msg)
+	subject, err := new(mime.WordDecoder).DecodeHeader(header.Get("Subject"))
+	require.NoError(t, err)
+	require.Equal(t, "[sync-diff-inspector] task: error", subject)
+	require.Len(t, parts, 1)
+	require.Equal(t, "The comparison failed without a report: dial tcp 127.0.0.1:4000: connect: connection refused\n"+
+		"You can view the details through '/tmp/output/sync_diff.log'\n", parts[0].data)
+}
@@ -19,15 +19,57 @@ import (
 	"io"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/pingcap/log"
+	"go.uber.org/zap"
 )
 
+// logInterval is the interval to log the progress when the output is not a terminal.
+const logInterval = 10 * time.Second
+
+// State is the snapshot of the progress, it can be reused by other components, e.g. the metrics.
+type State struct {
+	// TotalChunks is the number of chunks known after splitting, it grows while the tables are being split.
+	TotalChunks int
+	// CompletedChunks includes the chunks completed before resuming from the checkpoint.
+	CompletedChunks int
+	// Tables is the completion percentage of each table being compared.
+	Tables map[string]int
+	// ChunksPerSecond is the throughput of the chunks completed in this run.
+	ChunksPerSecond float64
+	// ETA is the estimated time to complete the rest chunks, 0 means unknown.
+	ETA    time.Duration
+	Paused bool
+	// StructChecked is the number of tables whose structures are checked, and StructTotal is the number of tables to check.
+	StructChecked int
+	StructTotal   int
+	// TableChunks is the chunks of each table registered in this run, including the finished tables.
+	TableChunks map[string]TableChunks
+	// QueryRetries is the number of the failed queries retried since the process started, see `AddQueryRetry`.
+	QueryRetries int64
+}
+
+// TableChunks is the progress of the chunks of a table.
+type TableChunks struct {
+	Completed int
+	// Total grows while the table is being split.
+	Total int
+	// Started means the chunks of the table are being split or compared, and Done means all of them are compared.
+	Started bool
+	Done    bool
+}
+
 type TableProgressPrinter struct {
 	tableList     *list.List
 	tableFailList *list.List
 	tableMap      map[string]*list.Element
 	output        io.Writer
 	lines         int
+	// finishedTables is the name => the total chunks of the tables finished, which are removed from tableMap.
+	finishedTables map[string]int
 
 	progressTableNums int
 	finishTableNums   int
@@ -36,6 +78,24 @@ type TableProgressPrinter struct {
 	progress int
 	total    int
 
+	// the chunks of all the tables, which are used to calculate the throughput and ETA.
+	totalChunks     int
+	completedChunks int
+	// resumedChunks is the number of chunks completed before resuming from the checkpoint.
+	resumedChunks int
+	// the tables whose structures are checked before comparing the data.
+	structChecked int
+	structTotal   int
+	startTime     time.Time
+	lastLogTime   time.Time
+	paused        bool
+	// interactive means the output is a terminal, so the progress is rendered as an updating bar,
+	// otherwise it's logged periodically.
+	interactive bool
+
+	stateMu sync.RWMutex
+	state   State
+
 	optCh    chan Operator
 	finishCh chan struct{}
 }
@@ -74,6 +134,10 @@ const (
 	PROGRESS_OPT_FAIL
 	PROGRESS_OPT_CLOSE
 	PROGRESS_OPT_ERROR
+	PROGRESS_OPT_PAUSE
+	PROGRESS_OPT_RESUMED
+	PROGRESS_OPT_STRUCT_START
+	PROGRESS_OPT_STRUCT_INC
 )
 
 type Operator struct {
@@ -82,15 +146,23 @@ type Operator struct {
 	total           int
 	state           table_state_t
 	totalStopUpdate bool
+	paused          bool
 }
 
 func NewTableProgressPrinter(tableNums int, finishTableNums int) *TableProgressPrinter {
+	return NewTableProgressPrinterWithOutput(tableNums, finishTableNums, os.Stdout)
+}
+
+// NewTableProgressPrinterWithOutput returns a TableProgressPrinter which renders the progress to output.
+func NewTableProgressPrinterWithOutput(tableNums int, finishTableNums int, output io.Writer) *TableProgressPrinter {
 	tpp := &TableProgressPrinter{
 		tableList:     list.New(),
 		tableFailList: list.New(),
 		tableMap:      make(map[string]*list.Element),
 		lines:         0,
 
+		finishedTables: make(map[string]int),
+
 		progressTableNums: 0,
 		finishTableNums:   finishTableNums,
 		tableNums:         tableNums,
@@ -98,10 +170,12 @@ func NewTableProgressPrinter(tableNums int, finishTableNums int) *TableProgressP
 		progress: 0,
 		total:    0,
 
+		startTime: time.Now(),
+
 		optCh:    make(chan Operator, 16),
 		finishCh: make(chan struct{}),
 	}
-	tpp.init()
+	tpp.init(output)
 	go tpp.serve()
 	fmt.Fprintf(tpp.output, "A total of %d tables need to be compared\n\n\n", tableNums)
 	return tpp
@@ -109,6 +183,56 @@ func NewTableProgressPrinter(tableNums int, finishTableNums int) *TableProgressP
 
 func (tpp *TableProgressPrinter) SetOutput(output io.Writer) {
 	tpp.output = output
+	tpp.interactive = isInteractive(output)
+}
+
+// SetPaused shows the comparison is paused or not.
+func (tpp *TableProgressPrinter) SetPaused(paused bool) {
+	tpp.optCh <- Operator{
+		optType: PROGRESS_OPT_PAUSE,
+		paused:  paused,
+	}
+}
+
+// AddResumedChunks adds the chunks completed before resuming from the checkpoint,
+// which are excluded from the throughput.
+func (tpp *TableProgressPrinter) AddResumedChunks(chunks int) {
+	tpp.optCh <- Operator{
+		optType: PROGRESS_OPT_RESUMED,
+		total:   chunks,
+	}
+}
+
+// GetState returns the snapshot of the progress.
+func (tpp *TableProgressPrinter) GetState() State {
+	tpp.stateMu.RLock()
+	defer tpp.stateMu.RUnlock()
+	state := tpp.state
+	state.Tables = make(map[string]int, len(tpp.state.Tables))
+	for name, percent := range tpp.state.Tables {
+		state.Tables[name] = percent
+	}
+	state.TableChunks = make(map[string]TableChunks, len(tpp.state.TableChunks))
+	for name, chunks := range tpp.state.TableChunks {
+		state.TableChunks[name] = chunks
+	}
+	state.QueryRetries = atomic.LoadInt64(&queryRetries)
+	return state
+}
+
+// StartStructCheck starts to check the structures of `total` tables, the progress of which is shown separately.
+func (tpp *TableProgressPrinter) StartStructCheck(total int) {
+	tpp.optCh <- Operator{
+		optType: PROGRESS_OPT_STRUCT_START,
+		total:   total,
+	}
+}
+
+// IncStructCheck increases the number of tables whose structures are checked.
+func (tpp *TableProgressPrinter) IncStructCheck() {
+	tpp.optCh <- Operator{
+		optType: PROGRESS_OPT_STRUCT_INC,
+	}
 }
 
 func (tpp *TableProgressPrinter) Inc(name string) {
@@ -172,7 +296,9 @@ func (tpp *TableProgressPrinter) Close() {
 
 func (tpp *TableProgressPrinter) PrintSummary() {
 	var cleanStr, fixStr string
-	cleanStr = "\x1b[1A\x1b[J"
+	if tpp.interactive {
+		cleanStr = "\x1b[1A\x1b[J"
+	}
 	fixStr = "\nSummary:\n\n"
 	if tpp.tableFailList.Len() == 0 {
 		fixStr = fmt.Sprintf(
@@ -206,17 +332,72 @@ func (tpp *TableProgressPrinter) Error(err error) {
 	}
 	<-tpp.finishCh
 	var cleanStr, fixStr string
-	cleanStr = "\x1b[1A\x1b[J"
+	if tpp.interactive {
+		cleanStr = "\x1b[1A\x1b[J"
+	}
 	fixStr = fmt.Sprintf("\nError in comparison process:\n%v\n\nYou can view the comparison details through './output_dir/sync_diff_inspector.log'\n", err)
 	fmt.Fprintf(tpp.output, "%s%s", cleanStr, fixStr)
 }
 
-func (tpp *TableProgressPrinter) init() {
+func (tpp *TableProgressPrinter) init(output io.Writer) {
 	tpp.tableList.PushBack(&TableProgress{
 		state: TABLE_STATE_HEAD,
 	})
 
-	tpp.output = os.Stdout
+	tpp.output = output
+	tpp.interactive = isInteractive(output)
+}
+
+// isInteractive returns false if the output is a file or pipe rather than a terminal.
+func isInteractive(output io.Writer) bool {
+	f, ok := output.(*os.File)
+	if !ok {
+		return true
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// updateState updates the snapshot of the progress, it's only called by the serve goroutine.
+func (tpp *TableProgressPrinter) updateState() {
+	state := State{
+		TotalChunks:     tpp.totalChunks,
+		CompletedChunks: tpp.completedChunks,
+		Tables:          make(map[string]int, len(tpp.tableMap)),
+		Paused:          tpp.paused,
+		StructChecked:   tpp.structChecked,
+		StructTotal:     tpp.structTotal,
+		TableChunks:     make(map[string]TableChunks, len(tpp.tableMap)+len(tpp.finishedTables)),
+	}
+	for name, e := range tpp.tableMap {
+		tp := e.Value.(*TableProgress)
+		if tp.total > 0 {
+			state.Tables[name] = 100 * tp.progress / tp.total
+		}
+		state.TableChunks[name] = TableChunks{Completed: tp.progress, Total: tp.total, Started: tp.state&TABLE_STATE_REGISTER == 0}
+	}
+	for name, total := range tpp.finishedTables {
+		state.TableChunks[name] = TableChunks{Completed: total, Total: total, Started: true, Done: true}
+	}
+	elapsed := time.Since(tpp.startTime).Seconds()
+	if completed := tpp.completedChunks - tpp.resumedChunks; completed > 0 && elapsed > 0 {
+		state.ChunksPerSecond = float64(completed) / elapsed
+		if rest := tpp.totalChunks - tpp.completedChunks; rest > 0 {
+			state.ETA = time.Duration(float64(rest) / state.ChunksPerSecond * float64(time.Second)).Round(time.Second)
+		}
+	}
+	tpp.stateMu.Lock()
+	tpp.state = state
+	tpp.stateMu.Unlock()
+}
+
+// dropOperators drops the operators sent after the printer is closed, so the callers never block on it.
+func (tpp *TableProgressPrinter) dropOperators() {
+	for range tpp.optCh {
+	}
 }
 
 func (tpp *TableProgressPrinter) serve() {
@@ -228,23 +409,38 @@ func (tpp *TableProgressPrinter) serve() {
 			tpp.flush(false)
 		case opt := <-tpp.optCh:
 			switch opt.optType {
+			case PROGRESS_OPT_PAUSE:
+				tpp.paused = opt.paused
+			case PROGRESS_OPT_STRUCT_START:
+				tpp.structTotal = opt.total
+				tpp.structChecked = 0
+			case PROGRESS_OPT_STRUCT_INC:
+				tpp.structChecked++
+			case PROGRESS_OPT_RESUMED:
+				tpp.totalChunks += opt.total
+				tpp.completedChunks += opt.total
+				tpp.resumedChunks += opt.total
 			case PROGRESS_OPT_CLOSE:
 				tpp.flush(false)
 				tpp.finishCh <- struct{}{}
+				go tpp.dropOperators()
 				return
 			case PROGRESS_OPT_ERROR:
 				tpp.finishCh <- struct{}{}
+				go tpp.dropOperators()
 				return
 			case PROGRESS_OPT_INC:
 				if e, ok := tpp.tableMap[opt.name]; ok {
 					tp := e.Value.(*TableProgress)
 					tp.progress++
 					tpp.progress++
+					tpp.completedChunks++
 					if tp.progress >= tp.total && tp.totalStopUpdate {
 						tp.state = (tp.state & TABLE_STATE_RESULT_MASK) | TABLE_STATE_FINISH
 						tpp.progress -= tp.progress
 						tpp.total -= tp.total
 						delete(tpp.tableMap, opt.name)
+						tpp.finishedTables[opt.name] = tp.total
 						tpp.flush(true)
 					}
 				}
@@ -279,14 +475,18 @@ func (tpp *TableProgressPrinter) serve() {
 				}
 				if e.Value.(*TableProgress).state&TABLE_STATE_RESULT_FAIL_STRUCTURE_DONE == 0 {
 					tpp.total += opt.total
+					tpp.totalChunks += opt.total
 				} else {
+					// the data check is skipped.
 					delete(tpp.tableMap, opt.name)
+					tpp.finishedTables[opt.name] = 0
 				}
 				tpp.flush(true)
 			case PROGRESS_OPT_UPDATE:
 				if e, ok := tpp.tableMap[opt.name]; ok {
 					tp := e.Value.(*TableProgress)
 					tpp.total += opt.total
+					tpp.totalChunks += opt.total
 					tp.total += opt.total
 					tp.totalStopUpdate = opt.totalStopUpdate
 				}
@@ -380,24 +580,94 @@ func (tpp *TableProgressPrinter) flush(stateIsChanged bool) {
 		}
 
 		dynStr = fmt.Sprintf("%s_____________________________________________________________________________\n", dynStr)
-		fmt.Fprintf(tpp.output, "%s%s%s", cleanStr, fixStr, dynStr)
-	} else {
+		if tpp.interactive {
+			fmt.Fprintf(tpp.output, "%s%s%s", cleanStr, fixStr, dynStr)
+		} else {
+			// the dynamic lines can't be refreshed, so only the results are printed.
+			fmt.Fprint(tpp.output, fixStr)
+		}
+	} else if tpp.interactive {
 		fmt.Fprint(tpp.output, "\x1b[1A\x1b[J")
 	}
+	tpp.updateState()
 	// show bar
 	// 60 '='+'-'
 	coe := float32(tpp.progressTableNums*tpp.progress)/float32(tpp.tableNums*(tpp.total+1)) + float32(tpp.finishTableNums)/float32(tpp.tableNums)
 	numLeft := int(60 * coe)
 	percent := int(100 * coe)
-	fmt.Fprintf(tpp.output, "Progress [%s>%s] %d%% %d/%d\n", strings.Repeat("=", numLeft), strings.Repeat("-", 60-numLeft), percent, tpp.progress, tpp.total)
+	if !tpp.interactive {
+		if time.Since(tpp.lastLogTime) >= logInterval {
+			tpp.lastLogTime = time.Now()
+			state := tpp.GetState()
+			log.Info("progress",
+				zap.Int("percent", percent),
+				zap.Int("completed chunks", state.CompletedChunks),
+				zap.Int("total chunks", state.TotalChunks),
+				zap.Float64("chunks per second", state.ChunksPerSecond),
+				zap.Duration("eta", state.ETA),
+				zap.Bool("paused", state.Paused),
+				zap.Int("checked structures", state.StructChecked),
+				zap.Int("total structures", state.StructTotal),
+				zap.Any("tables", state.Tables))
+		}
+		return
+	}
+	fmt.Fprintf(tpp.output, "Progress [%s>%s] %d%% %d/%d%s\n", strings.Repeat("=", numLeft), strings.Repeat("-", 60-numLeft), percent, tpp.progress, tpp.total, tpp.stateString())
 }
 
+// stateString returns the throughput, ETA and whether paused to show after the bar.
+func (tpp *TableProgressPrinter) stateString() string {
+	state := tpp.GetState()
+	var s strings.Builder
+	if state.StructChecked < state.StructTotal {
+		fmt.Fprintf(&s, ", checking the structures %d/%d", state.StructChecked, state.StructTotal)
+	}
+	if state.ChunksPerSecond > 0 {
+		fmt.Fprintf(&s, ", %.1f chunks/s", state.ChunksPerSecond)
+		if state.ETA > 0 {
+			fmt.Fprintf(&s, ", ETA %s", state.ETA)
+		}
+	}
+	if state.QueryRetries > 0 {
+		fmt.Fprintf(&s, ", %d query retries", state.QueryRetries)
+	}
+	if state.Paused {
+		s.WriteString(" PAUSED")
+	}
+	return s.String()
+}
+
+// progress_ is the progress of the comparison running in the process. It's global, so only one comparison can run
+// in a process at a time, see `diff.Diff.Run`.
 var progress_ *TableProgressPrinter = nil
 
+// queryRetries is counted without the progress printer, because the queries are retried before it's started too,
+// e.g. when loading the table infos.
+var queryRetries int64
+
 func Init(tableNums, finishTableNums int) {
 	progress_ = NewTableProgressPrinter(tableNums, finishTableNums)
 }
 
+// InitWithOutput is like Init, but the progress is rendered to output.
+func InitWithOutput(tableNums, finishTableNums int, output io.Writer) {
+	progress_ = NewTableProgressPrinterWithOutput(tableNums, finishTableNums, output)
+}
+
+// StartStructCheck starts to check the structures of `total` tables.
+func StartStructCheck(total int) {
+	if progress_ != nil {
+		progress_.StartStructCheck(total)
+	}
+}
+
+// IncStructCheck increases the number of tables whose structures are checked.
+func IncStructCheck() {
+	if progress_ != nil {
+		progress_.IncStructCheck()
+	}
+}
+
 func Inc(name string) {
 	if progress_ != nil {
 		progress_.Inc(name)
@@ -428,6 +698,33 @@ func FailTable(name string) {
 	}
 }
 
+// SetPaused shows the comparison is paused or not.
+func SetPaused(paused bool) {
+	if progress_ != nil {
+		progress_.SetPaused(paused)
+	}
+}
+
+// AddResumedChunks adds the chunks completed before resuming from the checkpoint.
+func AddResumedChunks(chunks int) {
+	if progress_ != nil {
+		progress_.AddResumedChunks(chunks)
+	}
+}
+
+// AddQueryRetry increases the number of the failed queries retried.
+func AddQueryRetry() {
+	atomic.AddInt64(&queryRetries, 1)
+}
+
+// GetState returns the snapshot of the progress of the comparison running in the process.
+func GetState() State {
+	if progress_ != nil {
+		return progress_.GetState()
+	}
+	return State{QueryRetries: atomic.LoadInt64(&queryRetries)}
+}
+
 func Close() {
 	if progress_ != nil {
 		progress_.Close()
@@ -16,6 +16,9 @@ package progress
 import (
 	"bytes"
 	"errors"
+	"fmt"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -95,3 +98,121 @@ func TestAllSuccess(t *testing.T) {
 		"You can view the comparison details through './output_dir/sync_diff_inspector.log'\n\n",
 	)
 }
+
+func TestProgressState(t *testing.T) {
+	p := NewTableProgressPrinter(2, 0)
+	buffer := new(bytes.Buffer)
+	p.SetOutput(buffer)
+	p.AddResumedChunks(2)
+	p.RegisterTable("1", false, false)
+	p.StartTable("1", 4, true)
+	p.Inc("1")
+	p.SetPaused(true)
+	time.Sleep(500 * time.Millisecond)
+
+	state := p.GetState()
+	require.Equal(t, 6, state.TotalChunks)
+	require.Equal(t, 3, state.CompletedChunks)
+	require.Equal(t, map[string]int{"1": 25}, state.Tables)
+	require.Equal(t, map[string]TableChunks{"1": {Completed: 1, Total: 4, Started: true}}, state.TableChunks)
+	require.True(t, state.Paused)
+	// only the chunk completed in this run counts.
+	require.Greater(t, state.ChunksPerSecond, 0.0)
+	require.Less(t, state.ChunksPerSecond, 10.0)
+	require.Greater(t, state.ETA, time.Duration(0))
+
+	p.SetPaused(false)
+	p.Inc("1")
+	time.Sleep(500 * time.Millisecond)
+	state = p.GetState()
+	require.False(t, state.Paused)
+	require.Equal(t, 4, state.CompletedChunks)
+	require.Equal(t, map[string]int{"1": 50}, state.Tables)
+	p.RegisterTable("2", true, true)
+	p.Inc("1")
+	p.Inc("1")
+	time.Sleep(500 * time.Millisecond)
+	// the table is registered but not started, and the finished table is kept.
+	require.Equal(t, map[string]TableChunks{"1": {Completed: 4, Total: 4, Started: true, Done: true}, "2": {}}, p.GetState().TableChunks)
+	p.Close()
+	require.Contains(t, buffer.String(), " chunks/s, ETA ")
+	require.Contains(t, buffer.String(), " PAUSED\n")
+}
+
+func TestStructCheckProgress(t *testing.T) {
+	p := NewTableProgressPrinter(3, 0)
+	buffer := new(bytes.Buffer)
+	p.SetOutput(buffer)
+	p.StartStructCheck(3)
+	p.IncStructCheck()
+	time.Sleep(500 * time.Millisecond)
+
+	state := p.GetState()
+	require.Equal(t, 1, state.StructChecked)
+	require.Equal(t, 3, state.StructTotal)
+
+	p.IncStructCheck()
+	p.IncStructCheck()
+	time.Sleep(500 * time.Millisecond)
+	state = p.GetState()
+	require.Equal(t, 3, state.StructChecked)
+	p.Close()
+	require.Contains(t, buffer.String(), ", checking the structures 1/3\n")
+	// the structure check progress isn't shown after all the structures are checked.
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	require.Contains(t, lines[len(lines)-1], "Progress [")
+	require.NotContains(t, lines[len(lines)-1], "checking the structures")
+}
+
+func TestOperatorsAfterClose(t *testing.T) {
+	p := NewTableProgressPrinterWithOutput(1, 0, new(bytes.Buffer))
+	p.RegisterTable("1", false, false)
+	p.Close()
+	// the operators are dropped rather than blocking after the buffer is full.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			p.IncStructCheck()
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the operators block after the printer is closed")
+	}
+}
+
+func TestNonInteractive(t *testing.T) {
+	require.True(t, isInteractive(new(bytes.Buffer)))
+	f, err := os.CreateTemp(t.TempDir(), "output")
+	require.NoError(t, err)
+	defer f.Close()
+	require.False(t, isInteractive(f))
+
+	p := NewTableProgressPrinter(1, 0)
+	p.SetOutput(f)
+	p.RegisterTable("1", false, false)
+	p.StartTable("1", 1, true)
+	p.Inc("1")
+	p.Close()
+	content, err := os.ReadFile(f.Name())
+	require.NoError(t, err)
+	// the progress bar isn't rendered into the file.
+	require.NotContains(t, string(content), "\x1b[")
+	require.NotContains(t, string(content), "Progress [")
+	require.Contains(t, string(content), "Comparing the table data of `1` ... equivalent\n")
+}
+
+func TestQueryRetries(t *testing.T) {
+	before := GetState().QueryRetries
+	AddQueryRetry()
+	AddQueryRetry()
+	require.Equal(t, before+2, GetState().QueryRetries)
+
+	p := NewTableProgressPrinter(1, 0)
+	p.SetOutput(new(bytes.Buffer))
+	defer p.Close()
+	require.Equal(t, before+2, p.GetState().QueryRetries)
+	require.Contains(t, p.stateString(), fmt.Sprintf(", %d query retries", before+2))
+}
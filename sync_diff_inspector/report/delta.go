@@ -0,0 +1,244 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+)
+
+// TableDelta is the change of the check result of a table between two reports.
+type TableDelta struct {
+	Schema string `json:"schema"`
+	Table  string `json:"table"`
+	// OldResult and NewResult are `Pass` or `Fail`, empty if the table is not in the report.
+	OldResult     string `json:"old-result"`
+	NewResult     string `json:"new-result"`
+	OldRowsAdd    int    `json:"old-rows-add"`
+	NewRowsAdd    int    `json:"new-rows-add"`
+	OldRowsDelete int    `json:"old-rows-delete"`
+	NewRowsDelete int    `json:"new-rows-delete"`
+}
+
+// Trend returns the arrow and the percentage change of the diff rows of the table, e.g. "↑ +9900% (7 -> 700)", which
+// is "new" if the table is not in the old report.
+func (t *TableDelta) Trend() string {
+	if len(t.OldResult) == 0 {
+		return "new"
+	}
+	oldRows, newRows := t.OldRowsAdd+t.OldRowsDelete, t.NewRowsAdd+t.NewRowsDelete
+	switch {
+	case oldRows == newRows:
+		return fmt.Sprintf("= (%d)", newRows)
+	case oldRows == 0:
+		return fmt.Sprintf("↑ (0 -> %d)", newRows)
+	case newRows > oldRows:
+		return fmt.Sprintf("↑ %+.0f%% (%d -> %d)", float64(newRows-oldRows)*100/float64(oldRows), oldRows, newRows)
+	default:
+		return fmt.Sprintf("↓ %+.0f%% (%d -> %d)", float64(newRows-oldRows)*100/float64(oldRows), oldRows, newRows)
+	}
+}
+
+// ReportDelta is the changes between two reports, it's used to track whether the diff is shrinking.
+type ReportDelta struct {
+	// Tables are sorted by the schema and table name.
+	Tables []*TableDelta
+}
+
+// NewlyPassed returns the tables failed in the old report but passed in the new report.
+func (d *ReportDelta) NewlyPassed() []*TableDelta {
+	return d.filter(func(t *TableDelta) bool { return t.OldResult == Fail && t.NewResult == Pass })
+}
+
+// NewlyFailed returns the tables passed in the old report but failed in the new report.
+func (d *ReportDelta) NewlyFailed() []*TableDelta {
+	return d.filter(func(t *TableDelta) bool { return t.OldResult == Pass && t.NewResult == Fail })
+}
+
+// Regressions returns the tables failed in the new report whose diff rows grow, including the tables newly failed.
+// The tables not in the old report are excluded since there is nothing to compare with.
+func (d *ReportDelta) Regressions() []*TableDelta {
+	return d.filter(func(t *TableDelta) bool {
+		if t.NewResult != Fail {
+			return false
+		}
+		return t.OldResult == Pass || t.OldResult == Fail && t.NewRowsAdd+t.NewRowsDelete > t.OldRowsAdd+t.OldRowsDelete
+	})
+}
+
+// find returns the change of the table, which is nil if the table is in neither report.
+func (d *ReportDelta) find(schema, table string) *TableDelta {
+	i := sort.Search(len(d.Tables), func(i int) bool {
+		if d.Tables[i].Schema != schema {
+			return d.Tables[i].Schema >= schema
+		}
+		return d.Tables[i].Table >= table
+	})
+	if i < len(d.Tables) && d.Tables[i].Schema == schema && d.Tables[i].Table == table {
+		return d.Tables[i]
+	}
+	return nil
+}
+
+func (d *ReportDelta) filter(f func(*TableDelta) bool) []*TableDelta {
+	tables := make([]*TableDelta, 0)
+	for _, t := range d.Tables {
+		if f(t) {
+			tables = append(tables, t)
+		}
+	}
+	return tables
+}
+
+// LoadReportFile loads the report from `report.json`.
+func LoadReportFile(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	r := new(Report)
+	if err = json.Unmarshal(data, r); err != nil {
+		return nil, errors.Annotatef(err, "failed to parse %s", path)
+	}
+	return r, nil
+}
+
+// CompareReports compares the check results of the tables in the two reports,
+// the tables only in one of the reports are included with the empty result on the other side.
+func CompareReports(old, new *Report) *ReportDelta {
+	tables := make(map[[2]string]*TableDelta)
+	getDelta := func(schema, table string) *TableDelta {
+		key := [2]string{schema, table}
+		if _, ok := tables[key]; !ok {
+			tables[key] = &TableDelta{Schema: schema, Table: table}
+		}
+		return tables[key]
+	}
+	for schema, tableMap := range old.TableResults {
+		for table, result := range tableMap {
+			delta := getDelta(schema, table)
+			delta.OldResult, delta.OldRowsAdd, delta.OldRowsDelete = summarizeTableResult(result)
+		}
+	}
+	for schema, tableMap := range new.TableResults {
+		for table, result := range tableMap {
+			delta := getDelta(schema, table)
+			delta.NewResult, delta.NewRowsAdd, delta.NewRowsDelete = summarizeTableResult(result)
+		}
+	}
+
+	delta := &ReportDelta{Tables: make([]*TableDelta, 0, len(tables))}
+	for _, t := range tables {
+		delta.Tables = append(delta.Tables, t)
+	}
+	sort.Slice(delta.Tables, func(i, j int) bool {
+		if delta.Tables[i].Schema != delta.Tables[j].Schema {
+			return delta.Tables[i].Schema < delta.Tables[j].Schema
+		}
+		return delta.Tables[i].Table < delta.Tables[j].Table
+	})
+	return delta
+}
+
+// summarizeTableResult returns the result and the total rows to add and delete of the table.
+func summarizeTableResult(result *TableResult) (string, int, int) {
+	rowsAdd, rowsDelete := 0, 0
+	for _, chunkResult := range result.ChunkMap {
+		rowsAdd += chunkResult.RowsAdd
+		rowsDelete += chunkResult.RowsDelete
+	}
+	if result.StructEqual && result.DataEqual {
+		return Pass, rowsAdd, rowsDelete
+	}
+	return Fail, rowsAdd, rowsDelete
+}
+
+// Print prints the changes of the tables as a table.
+func (d *ReportDelta) Print(w io.Writer) {
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetHeader([]string{"Table", "Old result", "New result", "Rows add", "Rows delete"})
+	oldDiffRows, newDiffRows := 0, 0
+	for _, t := range d.Tables {
+		table.Append([]string{
+			dbutil.TableName(t.Schema, t.Table),
+			resultString(t.OldResult),
+			resultString(t.NewResult),
+			rowsChangeString(t.OldRowsAdd, t.NewRowsAdd),
+			rowsChangeString(t.OldRowsDelete, t.NewRowsDelete),
+		})
+		oldDiffRows += t.OldRowsAdd + t.OldRowsDelete
+		newDiffRows += t.NewRowsAdd + t.NewRowsDelete
+	}
+	table.Render()
+	fmt.Fprint(w, tableString.String())
+	fmt.Fprintf(w, "%d tables newly passed, %d tables newly failed, the diff rows change from %d to %d\n",
+		len(d.NewlyPassed()), len(d.NewlyFailed()), oldDiffRows, newDiffRows)
+}
+
+func resultString(result string) string {
+	if len(result) == 0 {
+		return "missing"
+	}
+	return result
+}
+
+func rowsChangeString(old, new int) string {
+	if old == new {
+		return fmt.Sprint(new)
+	}
+	return fmt.Sprintf("%d -> %d (%+d)", old, new, new-old)
+}
+
+// PrintNewlyChanged prints the tables newly failed and newly passed in the new report.
+func (d *ReportDelta) PrintNewlyChanged(w io.Writer) {
+	for _, changed := range []struct {
+		tables []*TableDelta
+		title  string
+	}{
+		{d.NewlyFailed(), "newly became unequal"},
+		{d.NewlyPassed(), "newly healed"},
+	} {
+		fmt.Fprintf(w, "%d tables %s\n", len(changed.tables), changed.title)
+		printTableChanges(w, changed.tables)
+	}
+}
+
+// printTableChanges prints the changes of the diff rows of the tables one per line.
+func printTableChanges(w io.Writer, tables []*TableDelta) {
+	for _, t := range tables {
+		fmt.Fprintf(w, "    %s: rows add %s, rows delete %s\n", dbutil.TableName(t.Schema, t.Table),
+			rowsChangeString(t.OldRowsAdd, t.NewRowsAdd), rowsChangeString(t.OldRowsDelete, t.NewRowsDelete))
+	}
+}
+
+// TotalDiffRows returns the total rows to add and delete of the tables in the report.
+func (r *Report) TotalDiffRows() (rowsAdd int, rowsDelete int) {
+	for _, tableMap := range r.TableResults {
+		for _, result := range tableMap {
+			_, add, del := summarizeTableResult(result)
+			rowsAdd += add
+			rowsDelete += del
+		}
+	}
+	return rowsAdd, rowsDelete
+}
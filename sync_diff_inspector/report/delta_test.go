@@ -0,0 +1,151 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/chunk"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareReports(t *testing.T) {
+	oldReport := &Report{TableResults: map[string]map[string]*TableResult{
+		"test": {
+			"fixed": {Schema: "test", Table: "fixed", StructEqual: true, ChunkMap: ChunkResults{
+				"0:0-0:0:2": {RowsAdd: 3, RowsDelete: 1},
+				"0:0-0:1:2": {RowsAdd: 2},
+			}},
+			"broken":  {Schema: "test", Table: "broken", StructEqual: true, DataEqual: true, ChunkMap: ChunkResults{}},
+			"removed": {Schema: "test", Table: "removed", StructEqual: true, DataEqual: true, ChunkMap: ChunkResults{}},
+		},
+	}}
+	newReport := &Report{TableResults: map[string]map[string]*TableResult{
+		"test": {
+			"fixed": {Schema: "test", Table: "fixed", StructEqual: true, DataEqual: true, ChunkMap: ChunkResults{}},
+			"broken": {Schema: "test", Table: "broken", StructEqual: true, ChunkMap: ChunkResults{
+				"1:0-0:0:1": {RowsDelete: 4},
+			}},
+		},
+		"atest": {
+			"added": {Schema: "atest", Table: "added", StructEqual: false, ChunkMap: ChunkResults{}},
+		},
+	}}
+
+	// the reports are loaded from the json files.
+	dir := t.TempDir()
+	for name, r := range map[string]*Report{"old.json": oldReport, "new.json": newReport} {
+		data, err := json.Marshal(r)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), data, 0644))
+	}
+	oldReport, err := LoadReportFile(filepath.Join(dir, "old.json"))
+	require.NoError(t, err)
+	newReport, err = LoadReportFile(filepath.Join(dir, "new.json"))
+	require.NoError(t, err)
+
+	delta := CompareReports(oldReport, newReport)
+	require.Equal(t, []*TableDelta{
+		{Schema: "atest", Table: "added", NewResult: Fail},
+		{Schema: "test", Table: "broken", OldResult: Pass, NewResult: Fail, NewRowsDelete: 4},
+		{Schema: "test", Table: "fixed", OldResult: Fail, NewResult: Pass, OldRowsAdd: 5, OldRowsDelete: 1},
+		{Schema: "test", Table: "removed", OldResult: Pass},
+	}, delta.Tables)
+	require.Equal(t, []*TableDelta{delta.Tables[2]}, delta.NewlyPassed())
+	require.Equal(t, []*TableDelta{delta.Tables[1]}, delta.NewlyFailed())
+
+	buf := new(bytes.Buffer)
+	delta.Print(buf)
+	require.Contains(t, buf.String(), "| `atest`.`added`  | missing    | fail       |           0 |           0 |\n")
+	require.Contains(t, buf.String(), "| `test`.`fixed`   | fail       | pass       | 5 -> 0 (-5) | 1 -> 0 (-1) |\n")
+	require.Contains(t, buf.String(), "1 tables newly passed, 1 tables newly failed, the diff rows change from 6 to 4\n")
+
+	buf.Reset()
+	delta.PrintNewlyChanged(buf)
+	require.Equal(t, "1 tables newly became unequal\n"+
+		"    `test`.`broken`: rows add 0, rows delete 0 -> 4 (+4)\n"+
+		"1 tables newly healed\n"+
+		"    `test`.`fixed`: rows add 5 -> 0 (-5), rows delete 1 -> 0 (-1)\n", buf.String())
+	require.Equal(t, []*TableDelta{delta.Tables[1]}, delta.Regressions())
+	require.Equal(t, "new", delta.Tables[0].Trend())
+	require.Equal(t, "↑ (0 -> 4)", delta.Tables[1].Trend())
+	require.Equal(t, "↓ -100% (6 -> 0)", delta.Tables[2].Trend())
+	require.Equal(t, "= (0)", delta.Tables[3].Trend())
+	require.Nil(t, delta.find("test", "not_exist"))
+	rowsAdd, rowsDelete := oldReport.TotalDiffRows()
+	require.Equal(t, 5, rowsAdd)
+	require.Equal(t, 1, rowsDelete)
+
+	_, err = LoadReportFile(filepath.Join(dir, "not_exist.json"))
+	require.Error(t, err)
+}
+
+func TestTrendSincePreviousRun(t *testing.T) {
+	previous := &Report{TableResults: map[string]map[string]*TableResult{
+		"test": {
+			"growing":   {Schema: "test", Table: "growing", StructEqual: true, ChunkMap: ChunkResults{"0:0-0:0:1": {RowsAdd: 5, RowsDelete: 2}}},
+			"shrinking": {Schema: "test", Table: "shrinking", StructEqual: true, ChunkMap: ChunkResults{"1:0-0:0:1": {RowsAdd: 10}}},
+			"healed":    {Schema: "test", Table: "healed", StructEqual: true, ChunkMap: ChunkResults{"2:0-0:0:1": {RowsDelete: 1}}},
+			"broken":    {Schema: "test", Table: "broken", StructEqual: true, DataEqual: true, ChunkMap: ChunkResults{}},
+		},
+	}}
+	r := NewReport(task)
+	r.Init([]*common.TableDiff{
+		{Schema: "test", Table: "growing"},
+		{Schema: "test", Table: "shrinking"},
+		{Schema: "test", Table: "healed"},
+		{Schema: "test", Table: "broken"},
+		{Schema: "test", Table: "added"},
+	}, nil, nil)
+	r.SetPreviousRun("2021-10-01T01:00:00Z", previous)
+	// the rows to add and delete of the tables in this run, and "healed" becomes equal.
+	diffRows := map[string][2]int{"growing": {500, 200}, "shrinking": {5, 0}, "broken": {0, 3}, "added": {1, 0}}
+	for i, table := range []string{"growing", "shrinking", "healed", "broken", "added"} {
+		r.SetTableStructCheckResult("test", table, true, false)
+		rows, ok := diffRows[table]
+		r.SetTableDataCheckResult("test", table, !ok, rows[0], rows[1], &chunk.ChunkID{TableIndex: i, ChunkCnt: 1})
+	}
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	r.SetSink(sink)
+	require.NoError(t, r.CommitSummary())
+	summary := sink.files["summary.txt"].String()
+	require.Contains(t, summary, "the trend is the change of the diff rows since the previous run 2021-10-01T01:00:00Z\n\n")
+	require.Regexp(t, "\\| `test`.`growing` +\\| true +\\| \\+500/-200 +\\| ↑ \\+9900% \\(7 -> 700\\) +\\|", summary)
+	require.Regexp(t, "\\| `test`.`shrinking` +\\| true +\\| \\+5/-0 +\\| ↓ -50% \\(10 -> 5\\) +\\|", summary)
+	require.Regexp(t, "\\| `test`.`added` +\\| true +\\| \\+1/-0 +\\| new +\\|", summary)
+	require.Contains(t, summary, "\nThe following tables newly became unequal since the previous run 2021-10-01T01:00:00Z\n\n"+
+		"    `test`.`broken`: rows add 0, rows delete 0 -> 3 (+3)\n")
+	require.Contains(t, summary, "\nThe following tables newly became equal since the previous run 2021-10-01T01:00:00Z\n\n"+
+		"    `test`.`healed`: rows add 0, rows delete 1 -> 0 (-1)\n")
+
+	saved := new(Report)
+	require.NoError(t, json.Unmarshal(sink.files["report.json"].Bytes(), saved))
+	require.Equal(t, "2021-10-01T01:00:00Z", saved.PreviousRun)
+	require.Equal(t, []*TableDelta{
+		{Schema: "test", Table: "broken", OldResult: Pass, NewResult: Fail, NewRowsDelete: 3},
+		{Schema: "test", Table: "growing", OldResult: Fail, NewResult: Fail, OldRowsAdd: 5, OldRowsDelete: 2, NewRowsAdd: 500, NewRowsDelete: 200},
+	}, saved.Regressions)
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, r.Print(buf))
+	require.Contains(t, buf.String(), "Warning: the diff rows of 2 table grow since the previous run 2021-10-01T01:00:00Z:\n"+
+		"    `test`.`broken`: ↑ (0 -> 3)\n"+
+		"    `test`.`growing`: ↑ +9900% (7 -> 700)\n")
+}
@@ -0,0 +1,110 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"net"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/errno"
+)
+
+const (
+	// ErrorCategoryTimeout is the errors of the queries exceeding the deadlines, e.g. the context deadline, the read
+	// timeout of the connection and `max_execution_time`.
+	ErrorCategoryTimeout = "timeout"
+	// ErrorCategoryConnection is the errors of connecting to the databases or the broken connections.
+	ErrorCategoryConnection = "connection"
+	// ErrorCategoryPermission is the errors of the privileges of the users.
+	ErrorCategoryPermission = "permission"
+	// ErrorCategorySchema is the errors of the missing or changed databases, tables and columns.
+	ErrorCategorySchema = "schema"
+	// ErrorCategoryOther is the rest of the errors.
+	ErrorCategoryOther = "other"
+
+	// mysqlErrMaxExecutionTimeExceeded is the error of `max_execution_time` in MySQL, which is
+	// `errno.ErrMaxExecTimeExceeded` in TiDB.
+	mysqlErrMaxExecutionTimeExceeded = 3024
+)
+
+// errorCategories are the categories in the order they are listed in the summary.
+var errorCategories = []string{
+	ErrorCategoryTimeout,
+	ErrorCategoryConnection,
+	ErrorCategoryPermission,
+	ErrorCategorySchema,
+	ErrorCategoryOther,
+}
+
+// ClassifyError returns the category of the error met when checking a table by the cause of the error and the error
+// code of the database, so that the errors of the same cause can be diagnosed together.
+func ClassifyError(err error) string {
+	err = errors.Cause(err)
+	if err == context.DeadlineExceeded {
+		return ErrorCategoryTimeout
+	}
+	if err == driver.ErrBadConn || err == mysql.ErrInvalidConn || err == io.ErrUnexpectedEOF {
+		return ErrorCategoryConnection
+	}
+	if mysqlErr, ok := err.(*mysql.MySQLError); ok {
+		return classifyMySQLError(mysqlErr.Number)
+	}
+	if netErr, ok := err.(net.Error); ok {
+		if netErr.Timeout() {
+			return ErrorCategoryTimeout
+		}
+		return ErrorCategoryConnection
+	}
+	return ErrorCategoryOther
+}
+
+func classifyMySQLError(code uint16) string {
+	switch code {
+	case errno.ErrLockWaitTimeout,
+		errno.ErrMaxExecTimeExceeded,
+		mysqlErrMaxExecutionTimeExceeded,
+		errno.ErrPDServerTimeout,
+		errno.ErrTiKVServerTimeout,
+		errno.ErrResolveLockTimeout,
+		errno.ErrTiFlashServerTimeout:
+		return ErrorCategoryTimeout
+	case errno.ErrConCount,
+		errno.ErrTooManyUserConnections,
+		errno.ErrServerShutdown,
+		errno.ErrRegionUnavailable:
+		return ErrorCategoryConnection
+	case errno.ErrAccessDenied,
+		errno.ErrAccessDeniedNoPassword,
+		errno.ErrDBaccessDenied,
+		errno.ErrTableaccessDenied,
+		errno.ErrColumnaccessDenied,
+		errno.ErrSpecificAccessDenied:
+		return ErrorCategoryPermission
+	case errno.ErrBadDB,
+		errno.ErrBadTable,
+		errno.ErrUnknownTable,
+		errno.ErrNoSuchTable,
+		errno.ErrBadField,
+		errno.ErrKeyDoesNotExist,
+		errno.ErrInfoSchemaExpired,
+		errno.ErrInfoSchemaChanged:
+		return ErrorCategorySchema
+	default:
+		return ErrorCategoryOther
+	}
+}
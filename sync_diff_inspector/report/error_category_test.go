@@ -0,0 +1,117 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/chunk"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/pingcap/tidb/parser"
+	"github.com/stretchr/testify/require"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestClassifyError(t *testing.T) {
+	for _, c := range []struct {
+		err      error
+		category string
+	}{
+		{errors.Trace(context.DeadlineExceeded), ErrorCategoryTimeout},
+		{&net.OpError{Op: "read", Net: "tcp", Err: timeoutError{}}, ErrorCategoryTimeout},
+		{&mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}, ErrorCategoryTimeout},
+		{&mysql.MySQLError{Number: 3024, Message: "maximum statement execution time exceeded"}, ErrorCategoryTimeout},
+		{errors.Annotate(driver.ErrBadConn, "query failed"), ErrorCategoryConnection},
+		{mysql.ErrInvalidConn, ErrorCategoryConnection},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, ErrorCategoryConnection},
+		{&mysql.MySQLError{Number: 1040, Message: "Too many connections"}, ErrorCategoryConnection},
+		{errors.Trace(&mysql.MySQLError{Number: 1142, Message: "SELECT command denied"}), ErrorCategoryPermission},
+		{&mysql.MySQLError{Number: 1045, Message: "Access denied for user"}, ErrorCategoryPermission},
+		{&mysql.MySQLError{Number: 1146, Message: "Table doesn't exist"}, ErrorCategorySchema},
+		{&mysql.MySQLError{Number: 1054, Message: "Unknown column"}, ErrorCategorySchema},
+		{&mysql.MySQLError{Number: 1064, Message: "You have an error in your SQL syntax"}, ErrorCategoryOther},
+		{context.Canceled, ErrorCategoryOther},
+		{errors.New("checksum is different"), ErrorCategoryOther},
+	} {
+		require.Equal(t, c.category, ClassifyError(c.err), c.err.Error())
+	}
+}
+
+func TestErrorCategories(t *testing.T) {
+	createTableSQL := "create table `test`.`tbl`(`a` int, `b` varchar(10), primary key(`a`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+	tableDiffs := []*common.TableDiff{
+		{Schema: "test", Table: "t1", Info: tableInfo},
+		{Schema: "test", Table: "t2", Info: tableInfo},
+		{Schema: "test", Table: "t3", Info: tableInfo},
+		{Schema: "test", Table: "t4", Info: tableInfo},
+	}
+
+	report := NewReport(task)
+	report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	for i, tableDiff := range tableDiffs {
+		report.SetTableStructCheckResult("test", tableDiff.Table, true, false)
+		report.SetTableDataCheckResult("test", tableDiff.Table, true, 0, 0, &chunk.ChunkID{TableIndex: i, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1})
+	}
+	denied := &mysql.MySQLError{Number: 1142, Message: "SELECT command denied"}
+	report.SetTableMeetError("test", "t1", denied, nil, "")
+	report.SetTableMeetError("test", "t2", errors.New("unknown"), nil, "")
+	report.SetTableMeetError("test", "t3", denied, nil, "")
+	report.SetTableMeetError("test", "t4", context.DeadlineExceeded, &chunk.ChunkID{TableIndex: 3, ChunkCnt: 1}, "(1) < (a)")
+	require.Equal(t, ErrorCategoryPermission, report.TableResults["test"]["t1"].ErrorCategory)
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	summary := sink.files["summary.txt"].String()
+	require.Contains(t, summary, "The following tables meet errors, grouped by the category: 1 timeout, 2 permission, 1 other\n\n")
+	require.Regexp(t, "(?s)`test`.`t4` +\\| timeout +\\|.*`test`.`t1` +\\| permission +\\|.*`test`.`t3` +\\| permission +\\|.*`test`.`t2` +\\| other +\\|", summary)
+
+	buf := new(bytes.Buffer)
+	report.Print(buf)
+	require.Contains(t, buf.String(), "Error in comparison process:\n"+
+		"timeout errors in 1 table:\n"+
+		"    context deadline exceeded error occured in `test`.`t4` on chunk v2:g3.g0.g0.g0.g1 (bound (1) < (a))\n"+
+		"permission errors in 2 table:\n"+
+		"    Error 1142: SELECT command denied error occured in `test`.`t1`\n"+
+		"    Error 1142: SELECT command denied error occured in `test`.`t3`\n"+
+		"other errors in 1 table:\n"+
+		"    unknown error occured in `test`.`t2`\n")
+
+	// the category is kept after resuming from the checkpoint.
+	loaded := new(Report)
+	require.NoError(t, json.Unmarshal(sink.files["report.json"].Bytes(), loaded))
+	require.Equal(t, ErrorCategoryTimeout, loaded.TableResults["test"]["t4"].ErrorCategory)
+	loaded.TableResults["test"]["t2"].ErrorCategory = ""
+	resumed := NewReport(task)
+	resumed.Init(tableDiffs, nil, nil)
+	resumed.LoadReport(loaded)
+	require.Equal(t, ErrorCategoryPermission, resumed.TableResults["test"]["t1"].ErrorCategory)
+	require.Equal(t, ErrorCategoryOther, resumed.TableResults["test"]["t2"].ErrorCategory)
+}
@@ -0,0 +1,57 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"bufio"
+	"fmt"
+
+	"github.com/pingcap/errors"
+)
+
+// SetPreviousRun sets the report of the previous run in the history, which the diff rows of the tables are compared
+// with when the summary is committed.
+func (r *Report) SetPreviousRun(name string, previous *Report) {
+	r.Lock()
+	defer r.Unlock()
+	r.PreviousRun = name
+	r.previous = previous
+}
+
+// writeNewlyChanged writes the tables newly became unequal and newly became equal since the previous run.
+func (r *Report) writeNewlyChanged(w *bufio.Writer) {
+	if newlyFailed := r.trend.NewlyFailed(); len(newlyFailed) > 0 {
+		w.WriteString(fmt.Sprintf("\nThe following tables newly became unequal since the previous run %s\n\n", r.PreviousRun))
+		printTableChanges(w, newlyFailed)
+	}
+	if newlyPassed := r.trend.NewlyPassed(); len(newlyPassed) > 0 {
+		w.WriteString(fmt.Sprintf("\nThe following tables newly became equal since the previous run %s\n\n", r.PreviousRun))
+		printTableChanges(w, newlyPassed)
+	}
+}
+
+// ArchiveFiles writes `summary.txt` and `report.json` committed last by CommitSummary into sink, e.g. to archive
+// the run into the history. They are the same as the outputs of the run even if the sink of the report isn't local.
+func (r *Report) ArchiveFiles(sink ReportSink) error {
+	for _, name := range []string{summaryFileName, reportFileName} {
+		data, ok := r.committedFiles[name]
+		if !ok {
+			return errors.Errorf("%s isn't committed yet", name)
+		}
+		if err := writeSinkFile(sink, name, data); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
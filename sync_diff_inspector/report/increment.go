@@ -0,0 +1,135 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/chunk"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/utils"
+)
+
+// markDirty marks the table result is mutated since it's saved in the checkpoint, `id` is the chunk mutated,
+// which is nil if only the fields of the table are mutated. The caller should hold the write lock.
+func (r *Report) markDirty(schema, table string, id *chunk.ChunkID) {
+	if r.dirty == nil {
+		r.dirty = make(map[string]map[string]map[string]*chunk.ChunkID)
+	}
+	if _, ok := r.dirty[schema]; !ok {
+		r.dirty[schema] = make(map[string]map[string]*chunk.ChunkID)
+	}
+	chunks, ok := r.dirty[schema][table]
+	if !ok {
+		chunks = make(map[string]*chunk.ChunkID)
+		r.dirty[schema][table] = chunks
+	}
+	if id != nil {
+		key := id.Encode()
+		if _, ok := chunks[key]; !ok {
+			chunks[key] = id.Copy()
+		}
+	}
+}
+
+// takeDirty removes the mutations saved in the checkpoint of `chunkID`, i.e. the tables compared before or being
+// compared in the table `targetID`, and their chunks before or at `chunkID`, the others are kept to be saved later.
+// The removed mutations are returned, and the caller should hold the read lock.
+func (r *Report) takeDirty(chunkID *chunk.ChunkID, targetID string) map[string]map[string][]string {
+	r.dirtyMu.Lock()
+	defer r.dirtyMu.Unlock()
+	taken := make(map[string]map[string][]string)
+	for schema, tableMap := range r.dirty {
+		for table, chunks := range tableMap {
+			// the tables are compared in the descending order of the id, see `source.NewSources`.
+			if utils.UniqueID(schema, table) < targetID {
+				continue
+			}
+			if _, ok := taken[schema]; !ok {
+				taken[schema] = make(map[string][]string)
+			}
+			keys := make([]string, 0, len(chunks))
+			for key, id := range chunks {
+				if id.Compare(chunkID) <= 0 {
+					keys = append(keys, key)
+					delete(chunks, key)
+				}
+			}
+			taken[schema][table] = keys
+			if len(chunks) == 0 {
+				delete(tableMap, table)
+			}
+		}
+		if len(tableMap) == 0 {
+			delete(r.dirty, schema)
+		}
+	}
+	return taken
+}
+
+// GetIncrement returns the increment of the report since the last snapshot or increment, which has the tables
+// mutated since then with only their chunks mutated, so it's much smaller than the snapshot of `GetSnapshot`.
+// The snapshot with the increments applied in order by `ApplyIncrement` is the same as the snapshot taken at the
+// checkpoint of `chunkID`, the mutations after the checkpoint are kept in the later increments.
+func (r *Report) GetIncrement(chunkID *chunk.ChunkID, schema, table string) *Report {
+	r.RLock()
+	defer r.RUnlock()
+	taken := r.takeDirty(chunkID, utils.UniqueID(schema, table))
+	tableResults := make(map[string]map[string]*TableResult, len(taken))
+	for schema, tableMap := range taken {
+		tableResults[schema] = make(map[string]*TableResult, len(tableMap))
+		for table, keys := range tableMap {
+			result := r.TableResults[schema][table]
+			increment := snapshotTableResult(result)
+			increment.ChunkMap = make(ChunkResults, len(keys))
+			for _, key := range keys {
+				if chunkResult, ok := result.ChunkMap[key]; ok {
+					// the chunk results are marshaled without holding the lock.
+					c := *chunkResult
+					increment.ChunkMap[key] = &c
+				}
+			}
+			tableResults[schema][table] = increment
+		}
+	}
+	return r.snapshotHeader(chunkID, tableResults)
+}
+
+// ApplyIncrement applies the increment returned by `GetIncrement` to the snapshot loaded from the checkpoint,
+// the tables in the increment replace the ones in the snapshot except that their chunks are merged.
+func (r *Report) ApplyIncrement(increment *Report) {
+	r.StartTime = increment.StartTime
+	r.Duration = increment.Duration
+	r.ElapsedBeforeResume = increment.ElapsedBeforeResume
+	r.TotalSize = increment.TotalSize
+	r.FixTarget = increment.FixTarget
+	r.Aborted = increment.Aborted
+	r.ConfirmedChunks = increment.ConfirmedChunks
+	r.TransientChunks = increment.TransientChunks
+	r.SlowChunks = increment.SlowChunks
+	if r.TableResults == nil {
+		r.TableResults = make(map[string]map[string]*TableResult)
+	}
+	for schema, tableMap := range increment.TableResults {
+		if _, ok := r.TableResults[schema]; !ok {
+			r.TableResults[schema] = make(map[string]*TableResult)
+		}
+		for table, result := range tableMap {
+			if old, ok := r.TableResults[schema][table]; ok && old.ChunkMap != nil {
+				for id, chunkResult := range result.ChunkMap {
+					old.ChunkMap[id] = chunkResult
+				}
+				result.ChunkMap = old.ChunkMap
+			}
+			r.TableResults[schema][table] = result
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/chunk"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/stretchr/testify/require"
+)
+
+// roundTrip returns the report saved in and loaded from the checkpoint.
+func roundTrip(t *testing.T, r *Report) *Report {
+	data, err := json.Marshal(r)
+	require.NoError(t, err)
+	loaded := new(Report)
+	require.NoError(t, json.Unmarshal(data, loaded))
+	return loaded
+}
+
+func TestIncrement(t *testing.T) {
+	startTime := time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC)
+	nowFunc = func() time.Time { return startTime.Add(10 * time.Second) }
+	defer func() { nowFunc = time.Now }()
+
+	// the tables are compared in the order of t3, t2 and t1.
+	tableDiffs := []*common.TableDiff{{Schema: "test", Table: "t3"}, {Schema: "test", Table: "t2"}, {Schema: "test", Table: "t1"}}
+	chunkID := func(tableIndex, chunkIndex int) *chunk.ChunkID {
+		return &chunk.ChunkID{TableIndex: tableIndex, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: chunkIndex, ChunkCnt: 2}
+	}
+	report := NewReport(task)
+	report.Init(tableDiffs, nil, nil)
+	for _, tableDiff := range tableDiffs {
+		report.SetTableStructCheckResult(tableDiff.Schema, tableDiff.Table, true, false)
+	}
+	report.SetTableDataCheckResult("test", "t3", false, 1, 1, chunkID(0, 0))
+	report.SetChunkChecksumDuration("test", "t3", &chunk.Range{Index: chunkID(0, 0)}, time.Second)
+	base := roundTrip(t, mustGetSnapshot(t, report, chunkID(0, 0), "t3"))
+
+	report.SetChunkChecksumDuration("test", "t3", &chunk.Range{Index: chunkID(0, 1)}, 2*time.Second)
+	report.SetTableDataCheckResult("test", "t2", false, 2, 0, chunkID(1, 0))
+	report.SetTableDataCheckResult("test", "t2", false, 0, 3, chunkID(1, 1))
+	report.AddTableDuration("test", "t2", time.Minute)
+	report.SetTableStructCheckResult("test", "t1", false, true)
+
+	// only the tables and the chunks mutated before the checkpoint are in the increment.
+	increment := report.GetIncrement(chunkID(1, 0), "test", "t2")
+	require.Len(t, increment.TableResults["test"], 2)
+	require.Len(t, increment.TableResults["test"]["t3"].ChunkMap, 1)
+	require.Equal(t, 2*time.Second, increment.TableResults["test"]["t3"].ChunkMap[chunkID(0, 1).Encode()].ChecksumDuration)
+	require.Len(t, increment.TableResults["test"]["t2"].ChunkMap, 1)
+	require.Equal(t, 2, increment.TableResults["test"]["t2"].ChunkMap[chunkID(1, 0).Encode()].RowsAdd)
+	require.Equal(t, time.Minute, increment.TableResults["test"]["t2"].Duration)
+	require.Len(t, increment.SlowChunks, 2)
+	increments := []*Report{roundTrip(t, increment)}
+
+	// the chunk after the checkpoint is saved in the next increment.
+	report.SetTableDataCheckResult("test", "t2", false, 1, 0, chunkID(1, 0))
+	increment = report.GetIncrement(chunkID(1, 1), "test", "t2")
+	require.Len(t, increment.TableResults["test"], 1)
+	require.Len(t, increment.TableResults["test"]["t2"].ChunkMap, 2)
+	require.Equal(t, 3, increment.TableResults["test"]["t2"].ChunkMap[chunkID(1, 0).Encode()].RowsAdd)
+	increments = append(increments, roundTrip(t, increment))
+	require.Empty(t, report.GetIncrement(chunkID(1, 1), "test", "t2").TableResults)
+
+	// the snapshot with the increments applied is the same as the full snapshot.
+	for _, increment := range increments {
+		base.ApplyIncrement(increment)
+	}
+	expected := roundTrip(t, mustGetSnapshot(t, report, chunkID(1, 1), "t2"))
+	require.Equal(t, expected, base)
+
+	// the full snapshot takes the mutations too, except the ones of the tables after the checkpoint.
+	require.Len(t, report.GetIncrement(chunkID(2, 0), "test", "t1").TableResults["test"], 1)
+	require.Empty(t, report.GetIncrement(chunkID(2, 0), "test", "t1").TableResults)
+}
+
+func mustGetSnapshot(t *testing.T, r *Report, chunkID *chunk.ChunkID, table string) *Report {
+	snapshot, err := r.GetSnapshot(chunkID, "test", table)
+	require.NoError(t, err)
+	return snapshot
+}
+
+// BenchmarkCheckpointSnapshot measures the time of taking the report saved in the checkpoint, during which
+// the lock of the report is held, while 10k chunk results of the 30k tables are set concurrently.
+func BenchmarkCheckpointSnapshot(b *testing.B) {
+	const tableCount = 30000
+	const concurrentChunks = 10000
+	for _, incremental := range []bool{false, true} {
+		name := "full"
+		if incremental {
+			name = "incremental"
+		}
+		b.Run(name, func(b *testing.B) {
+			tableDiffs := make([]*common.TableDiff, 0, tableCount)
+			for i := 0; i < tableCount; i++ {
+				tableDiffs = append(tableDiffs, &common.TableDiff{Schema: "test", Table: fmt.Sprintf("t%05d", tableCount-1-i)})
+			}
+			report := NewReport(task)
+			report.Init(tableDiffs, nil, nil)
+			for i, tableDiff := range tableDiffs {
+				report.SetTableDataCheckResult("test", tableDiff.Table, false, 1, 0, &chunk.ChunkID{TableIndex: i, ChunkCnt: 1})
+			}
+			// the checkpoint is at the last table, so all the tables are saved.
+			checkpoint := &chunk.ChunkID{TableIndex: tableCount, ChunkCnt: 1}
+			if _, err := report.GetSnapshot(checkpoint, "test", "t00000"); err != nil {
+				b.Fatal(err)
+			}
+
+			var hold time.Duration
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				wg := &sync.WaitGroup{}
+				for j := 0; j < concurrentChunks; j++ {
+					wg.Add(1)
+					go func(j int) {
+						defer wg.Done()
+						tableIndex := (i*concurrentChunks + j) % tableCount
+						report.SetTableDataCheckResult("test", tableDiffs[tableIndex].Table, false, 1, 0,
+							&chunk.ChunkID{TableIndex: tableIndex, ChunkIndex: i + 1, ChunkCnt: b.N + 1})
+					}(j)
+				}
+				start := time.Now()
+				if incremental {
+					report.GetIncrement(checkpoint, "test", "t00000")
+				} else if _, err := report.GetSnapshot(checkpoint, "test", "t00000"); err != nil {
+					b.Fatal(err)
+				}
+				hold += time.Since(start)
+				wg.Wait()
+			}
+			b.ReportMetric(float64(hold.Nanoseconds())/float64(b.N), "ns/snapshot")
+		})
+	}
+}
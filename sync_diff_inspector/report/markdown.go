@@ -0,0 +1,89 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+)
+
+// markdownFileName is the summary in markdown, which is written by CommitMarkdown, e.g. to be attached to the email.
+const markdownFileName = "summary.md"
+
+// Markdown returns the summary of the comparison in markdown, i.e. the result printed by Print and the tables of the
+// inconsistent tables, the errors and the missing tables. It's called after CommitSummary.
+func (r *Report) Markdown() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# sync-diff-inspector: %s\n\n", r.Result)
+	// the lines of the result are kept as they are printed.
+	fmt.Fprintf(&buf, "```text\n%s```\n", r.Summary())
+	if diffRows := r.getDiffRows(); len(diffRows) > 0 {
+		buf.WriteString("\n## Inconsistent tables\n\n")
+		header := []string{"Table", "Structure equality", "Data diff rows"}
+		if r.trend != nil {
+			header = append(header, "Trend")
+		}
+		writeMarkdownTable(&buf, header, diffRows)
+	}
+	if groups := r.getErroredTablesByCategory(); len(groups) > 0 {
+		buf.WriteString("\n## Errors\n\n")
+		rows := make([][]string, 0)
+		for _, group := range groups {
+			for _, result := range group.tables {
+				rows = append(rows, []string{dbutil.TableName(result.Schema, result.Table), group.category, result.MeetError.Error()})
+			}
+		}
+		writeMarkdownTable(&buf, []string{"Table", "Category", "Error"}, rows)
+	}
+	if len(r.MissingTables) > 0 {
+		buf.WriteString("\n## Missing tables\n\n")
+		rows := make([][]string, 0, len(r.MissingTables))
+		for _, table := range r.MissingTables {
+			rows = append(rows, []string{dbutil.TableName(table.Schema, table.Table), table.MissingOn})
+		}
+		writeMarkdownTable(&buf, []string{"Table", "Missing on"}, rows)
+	}
+	return buf.Bytes()
+}
+
+// CommitMarkdown writes the summary in markdown into `summary.md` of the sink.
+func (r *Report) CommitMarkdown() error {
+	return errors.Trace(r.commitFile(markdownFileName, r.Markdown()))
+}
+
+// writeMarkdownTable writes the rows as a markdown table, the pipes and the line breaks in the cells are escaped so
+// that they don't break the table.
+func writeMarkdownTable(buf *bytes.Buffer, header []string, rows [][]string) {
+	replacer := strings.NewReplacer("|", "\\|", "\n", " ")
+	writeRow := func(cells []string) {
+		escaped := make([]string, 0, len(cells))
+		for _, cell := range cells {
+			escaped = append(escaped, replacer.Replace(cell))
+		}
+		fmt.Fprintf(buf, "| %s |\n", strings.Join(escaped, " | "))
+	}
+	writeRow(header)
+	separators := make([]string, 0, len(header))
+	for range header {
+		separators = append(separators, "---")
+	}
+	writeRow(separators)
+	for _, row := range rows {
+		writeRow(row)
+	}
+}
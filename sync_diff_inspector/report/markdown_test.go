@@ -0,0 +1,56 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/chunk"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/pingcap/tidb/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarkdown(t *testing.T) {
+	tableInfo, err := dbutil.GetTableInfoBySQL("create table `test`.`tbl`(`a` int, primary key(`a`))", parser.New())
+	require.NoError(t, err)
+	tableDiffs := []*common.TableDiff{{Schema: "test", Table: "t1", Info: tableInfo}, {Schema: "test", Table: "t2", Info: tableInfo}}
+	report := NewReport(task)
+	report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	report.SetTableStructCheckResult("test", "t1", true, false)
+	report.SetTableDataCheckResult("test", "t1", false, 2, 1, &chunk.ChunkID{TableIndex: 0, ChunkCnt: 1})
+	report.SetTableMeetError("test", "t2", errors.New("a | b\nc"), nil, "")
+	report.AddMissingTable("test", "only_source", MissingOnTarget)
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	require.NoError(t, report.CommitMarkdown())
+	markdown := sink.files["summary.md"].String()
+	require.Equal(t, string(report.Markdown()), markdown)
+	require.Contains(t, markdown, "# sync-diff-inspector: error\n\n```text\n"+report.Summary()+"```\n")
+	require.Contains(t, markdown, "\n## Inconsistent tables\n\n"+
+		"| Table | Structure equality | Data diff rows |\n"+
+		"| --- | --- | --- |\n"+
+		"| `test`.`t1` | true | +2/-1 |\n")
+	// the pipe and the line break of the error don't break the table.
+	require.Contains(t, markdown, "| `test`.`t2` | other | a \\| b c |\n")
+	require.Contains(t, markdown, "\n## Missing tables\n\n"+
+		"| Table | Missing on |\n"+
+		"| --- | --- |\n"+
+		"| `test`.`only_source` | target |\n")
+}
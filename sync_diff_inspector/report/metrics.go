@@ -0,0 +1,164 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+)
+
+// Metric names of the flat metrics.
+const (
+	MetricRowsAdd     = "rows_add"
+	MetricRowsDelete  = "rows_delete"
+	MetricStructEqual = "struct_equal"
+	MetricDurationMs  = "duration_ms"
+)
+
+// openMetricsPrefix is the prefix of the names of the flat metrics in the OpenMetrics format.
+const openMetricsPrefix = "sync_diff_inspector_"
+
+// metricHelps are the help texts of the flat metrics in the OpenMetrics format, in the order they're written.
+var metricHelps = [][2]string{
+	{MetricRowsAdd, "The number of the rows to add into the target of the table."},
+	{MetricRowsDelete, "The number of the rows to delete from the target of the table."},
+	{MetricStructEqual, "Whether the structures of the table are equal, 1 or 0."},
+	{MetricDurationMs, "The time in milliseconds spent comparing the chunks of the table."},
+}
+
+// FlatMetric is a metric of a table, the report is flattened into an array of them,
+// which can be consumed by the JSON datasource of Grafana directly.
+type FlatMetric struct {
+	Table  string `json:"table"`
+	Metric string `json:"metric"`
+	Value  int64  `json:"value"`
+}
+
+// getFlatMetrics flattens the results of the tables, sorted by the table name. The rows are summed over the chunks,
+// `struct_equal` is 1 or 0, and `duration_ms` is the time spent comparing the chunks of the table.
+func (r *Report) getFlatMetrics() []*FlatMetric {
+	metrics := make([]*FlatMetric, 0)
+	for _, name := range r.getSortedSchemaTables() {
+		schema, table := name[0], name[1]
+		result := r.TableResults[schema][table]
+		tableName := schema + "." + table
+		rowsAdd, rowsDelete := int64(0), int64(0)
+		for _, chunkResult := range result.ChunkMap {
+			rowsAdd += int64(chunkResult.RowsAdd)
+			rowsDelete += int64(chunkResult.RowsDelete)
+		}
+		structEqual := int64(0)
+		if result.StructEqual {
+			structEqual = 1
+		}
+		metrics = append(metrics,
+			&FlatMetric{Table: tableName, Metric: MetricRowsAdd, Value: rowsAdd},
+			&FlatMetric{Table: tableName, Metric: MetricRowsDelete, Value: rowsDelete},
+			&FlatMetric{Table: tableName, Metric: MetricStructEqual, Value: structEqual},
+			&FlatMetric{Table: tableName, Metric: MetricDurationMs, Value: result.Duration.Milliseconds()},
+		)
+	}
+	return metrics
+}
+
+// WriteFlatMetrics writes the results of the tables as a flat JSON array of `{table, metric, value}`
+// rather than the nested structure of `report.json`.
+func (r *Report) WriteFlatMetrics(w io.Writer) error {
+	r.RLock()
+	metrics := r.getFlatMetrics()
+	r.RUnlock()
+	data, err := json.MarshalIndent(metrics, "", "  ")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	_, err = w.Write(data)
+	return errors.Trace(err)
+}
+
+// writeFlatMetrics writes the flat metrics into `metrics.json`.
+func (r *Report) writeFlatMetrics() (err error) {
+	w, err := r.sink.Create("metrics.json")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer func() {
+		if closeErr := w.Close(); err == nil {
+			err = errors.Trace(closeErr)
+		}
+	}()
+	return errors.Trace(r.WriteFlatMetrics(w))
+}
+
+// labelValueReplacer escapes the label values in the OpenMetrics format.
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// WriteOpenMetrics writes the flat metrics in the OpenMetrics text format, each metric is a gauge family prefixed
+// by `sync_diff_inspector_` with the label `table`, and the output ends with `# EOF`. It's also parsed by the
+// Prometheus text format, which takes `# EOF` as a comment.
+func (r *Report) WriteOpenMetrics(w io.Writer) error {
+	r.RLock()
+	metrics := r.getFlatMetrics()
+	r.RUnlock()
+	bw := bufio.NewWriter(w)
+	for _, metricHelp := range metricHelps {
+		name := openMetricsPrefix + metricHelp[0]
+		fmt.Fprintf(bw, "# TYPE %s gauge\n", name)
+		fmt.Fprintf(bw, "# HELP %s %s\n", name, metricHelp[1])
+		for _, metric := range metrics {
+			if metric.Metric == metricHelp[0] {
+				fmt.Fprintf(bw, "%s{table=\"%s\"} %d\n", name, labelValueReplacer.Replace(metric.Table), metric.Value)
+			}
+		}
+	}
+	bw.WriteString("# EOF\n")
+	return errors.Trace(bw.Flush())
+}
+
+// WriteTextfileMetrics writes the flat metrics in the OpenMetrics format into path for the textfile collector of
+// node_exporter. The metrics are written into a temporary file in the same directory then renamed to path, so that
+// the collector never reads a partial file, and the temporary file like `.sync_diff.prom.123.tmp` is skipped by the
+// collector. The temporary file is removed if the write fails.
+func (r *Report) WriteTextfileMetrics(path string) (err error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	tmpFile := f.Name()
+	defer func() {
+		if err != nil {
+			os.Remove(tmpFile)
+		}
+	}()
+	// the temporary file is created with 0600.
+	if err = f.Chmod(config.LocalFilePerm); err != nil {
+		f.Close()
+		return errors.Trace(err)
+	}
+	if err = r.WriteOpenMetrics(f); err != nil {
+		f.Close()
+		return errors.Trace(err)
+	}
+	if err = f.Close(); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(os.Rename(tmpFile, path))
+}
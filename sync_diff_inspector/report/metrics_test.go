@@ -0,0 +1,112 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFlatMetrics(t *testing.T) {
+	report := &Report{TableResults: map[string]map[string]*TableResult{
+		"test": {
+			"equal": {Schema: "test", Table: "equal", StructEqual: true, DataEqual: true, ChunkMap: ChunkResults{}, Duration: 1500 * time.Millisecond},
+			"diff": {Schema: "test", Table: "diff", StructEqual: false, ChunkMap: ChunkResults{
+				"0:0-0:0:2": {RowsAdd: 3, RowsDelete: 1},
+				"0:0-0:1:2": {RowsAdd: 2},
+			}, Duration: 20 * time.Millisecond},
+		},
+	}}
+	buf := new(bytes.Buffer)
+	require.NoError(t, report.WriteFlatMetrics(buf))
+
+	var rows []map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &rows))
+	require.Equal(t, []map[string]interface{}{
+		{"table": "test.diff", "metric": "rows_add", "value": float64(5)},
+		{"table": "test.diff", "metric": "rows_delete", "value": float64(1)},
+		{"table": "test.diff", "metric": "struct_equal", "value": float64(0)},
+		{"table": "test.diff", "metric": "duration_ms", "value": float64(20)},
+		{"table": "test.equal", "metric": "rows_add", "value": float64(0)},
+		{"table": "test.equal", "metric": "rows_delete", "value": float64(0)},
+		{"table": "test.equal", "metric": "struct_equal", "value": float64(1)},
+		{"table": "test.equal", "metric": "duration_ms", "value": float64(1500)},
+	}, rows)
+
+	// no tables
+	buf.Reset()
+	require.NoError(t, (&Report{}).WriteFlatMetrics(buf))
+	require.Equal(t, "[]", buf.String())
+}
+
+func TestWriteOpenMetrics(t *testing.T) {
+	report := &Report{TableResults: map[string]map[string]*TableResult{
+		"test": {
+			"equal": {Schema: "test", Table: "equal", StructEqual: true, DataEqual: true, ChunkMap: ChunkResults{}, Duration: 1500 * time.Millisecond},
+			"di\"ff": {Schema: "test", Table: "di\"ff", StructEqual: false, ChunkMap: ChunkResults{
+				"0:0-0:0:2": {RowsAdd: 3, RowsDelete: 1},
+				"0:0-0:1:2": {RowsAdd: 2},
+			}, Duration: 20 * time.Millisecond},
+		},
+	}}
+	expected := `# TYPE sync_diff_inspector_rows_add gauge
+# HELP sync_diff_inspector_rows_add The number of the rows to add into the target of the table.
+sync_diff_inspector_rows_add{table="test.di\"ff"} 5
+sync_diff_inspector_rows_add{table="test.equal"} 0
+# TYPE sync_diff_inspector_rows_delete gauge
+# HELP sync_diff_inspector_rows_delete The number of the rows to delete from the target of the table.
+sync_diff_inspector_rows_delete{table="test.di\"ff"} 1
+sync_diff_inspector_rows_delete{table="test.equal"} 0
+# TYPE sync_diff_inspector_struct_equal gauge
+# HELP sync_diff_inspector_struct_equal Whether the structures of the table are equal, 1 or 0.
+sync_diff_inspector_struct_equal{table="test.di\"ff"} 0
+sync_diff_inspector_struct_equal{table="test.equal"} 1
+# TYPE sync_diff_inspector_duration_ms gauge
+# HELP sync_diff_inspector_duration_ms The time in milliseconds spent comparing the chunks of the table.
+sync_diff_inspector_duration_ms{table="test.di\"ff"} 20
+sync_diff_inspector_duration_ms{table="test.equal"} 1500
+# EOF
+`
+	buf := new(bytes.Buffer)
+	require.NoError(t, report.WriteOpenMetrics(buf))
+	require.Equal(t, expected, buf.String())
+
+	// the file is replaced by renaming, and the temporary file is left nowhere.
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sync_diff.prom")
+	require.NoError(t, os.WriteFile(path, []byte("stale"), 0o644))
+	require.NoError(t, report.WriteTextfileMetrics(path))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, expected, string(data))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	// the directory doesn't exist.
+	require.Error(t, report.WriteTextfileMetrics(filepath.Join(dir, "missing", "sync_diff.prom")))
+
+	// the temporary file is removed after failing to be renamed to a directory.
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "dir.prom"), 0o755))
+	require.Error(t, report.WriteTextfileMetrics(filepath.Join(dir, "dir.prom")))
+	entries, err = os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+}
@@ -14,18 +14,17 @@
 package report
 
 import (
+	"bytes"
 	"context"
-	"database/sql"
+	"encoding/json"
 	"fmt"
 	"io"
-	"os"
-	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/olekukonko/tablewriter"
 	"github.com/pingcap/errors"
 	"github.com/pingcap/log"
 	"github.com/pingcap/tidb-tools/pkg/dbutil"
@@ -44,6 +43,92 @@ const (
 	Error = "error"
 )
 
+const (
+	summaryFileName = "summary.txt"
+	reportFileName  = "report.json"
+)
+
+// ResumedError is the `MeetError` of the table restored from the checkpoint, only the message of the original error
+// is saved, so it's distinguished from the errors of this run.
+type ResumedError struct {
+	Message string
+}
+
+func (e *ResumedError) Error() string {
+	return e.Message
+}
+
+const (
+	// StructDiffBreaking means the column sets or the column types are different, the data check is skipped.
+	StructDiffBreaking = "breaking"
+	// StructDiffNonBreaking means only the column orders or the indices are different, the data is still checked.
+	StructDiffNonBreaking = "non-breaking"
+)
+
+const (
+	// ViewEqual means the definitions of the view are equal on the sources and the target.
+	ViewEqual = "equal"
+	// ViewUnequal means the definitions of the view are different.
+	ViewUnequal = "unequal"
+	// ViewMissingOnTarget means the view exists on the sources, but not on the target.
+	ViewMissingOnTarget = "missing-on-target"
+	// ViewMissingOnSource means the view exists on the target, but not on the sources.
+	ViewMissingOnSource = "missing-on-source"
+	// ViewNotCompared means the view exists on both sides, and the definitions are not compared without `check-views`.
+	ViewNotCompared = "not-compared"
+)
+
+const (
+	// MissingOnSource means the table only exists on the target.
+	MissingOnSource = "source"
+	// MissingOnTarget means the table only exists on the sources.
+	MissingOnTarget = "target"
+)
+
+const (
+	// ObjectOnSource means the skipped object is on the sources.
+	ObjectOnSource = "source"
+	// ObjectOnTarget means the skipped object is on the target.
+	ObjectOnTarget = "target"
+)
+
+const (
+	// CheckPathAdminChecksum means the data of the table is verified by `ADMIN CHECKSUM TABLE` without any chunk.
+	CheckPathAdminChecksum = "admin-checksum"
+	// CheckPathChunks means the data of the table is compared by chunks after `ADMIN CHECKSUM TABLE` is tried.
+	CheckPathChunks = "chunks"
+)
+
+const (
+	// VerificationRowByRow means the data of the table is compared row by row, e.g. the equal checksums of the table
+	// without primary key or unique key are confirmed by the rows.
+	VerificationRowByRow = "row-by-row"
+	// VerificationChecksumOnly means the data of the table is verified by the checksums of the chunks or
+	// `ADMIN CHECKSUM TABLE`, which may collide.
+	VerificationChecksumOnly = "checksum-only"
+	// VerificationSampled means only the chunks sampled by sample-rate are compared.
+	VerificationSampled = "sampled"
+	// VerificationRowCount means the data of the table is only verified by the row count.
+	VerificationRowCount = "row-count"
+)
+
+// verificationMethods are the verification methods from the strongest to the weakest.
+var verificationMethods = []string{VerificationRowByRow, VerificationChecksumOnly, VerificationSampled, VerificationRowCount}
+
+// verificationStrength returns the strength of the verification method, the greater the stronger.
+func verificationStrength(method string) int {
+	for i, m := range verificationMethods {
+		if m == method {
+			return len(verificationMethods) - i
+		}
+	}
+	return 0
+}
+
+// ReplicationLagSkipReason is the reason to skip the data check of the tables when the replication doesn't catch up
+// within the timeout of wait-sync, since the diffs are misleading.
+const ReplicationLagSkipReason = "replication lag"
+
 // ReportConfig stores the config information for the user
 type ReportConfig struct {
 	Host     string `toml:"host"`
@@ -53,59 +138,643 @@ type ReportConfig struct {
 	SqlMode  string `toml:"sql-mode,omitempty"`
 }
 
+// ServerVersion stores the version of the database server and the settings of the server affecting the comparison.
+type ServerVersion struct {
+	Version string `json:"version"`
+	// Flavor is `utils.FlavorMySQL`, `utils.FlavorMariaDB` or `utils.FlavorTiDB` parsed from the version, which is
+	// empty if unknown.
+	Flavor string `json:"flavor,omitempty"`
+	// TiDBVersion is the result of `tidb_version()`, which is empty if the server is not TiDB.
+	TiDBVersion string `json:"tidb-version,omitempty"`
+	// AutoIncrementIncrement and AutoIncrementOffset are `auto_increment_increment` and `auto_increment_offset` of
+	// the server, which are 0 if failed to get.
+	AutoIncrementIncrement int64 `json:"auto-increment-increment,omitempty"`
+	AutoIncrementOffset    int64 `json:"auto-increment-offset,omitempty"`
+}
+
+// ConnPoolStats is the statistics of the connection pool of a database during the comparison, which are sampled from
+// `sql.DB.Stats()` periodically.
+type ConnPoolStats struct {
+	Name               string `json:"name"`
+	MaxOpenConnections int    `json:"max-open-connections"`
+	// PeakInUse is the most connections in use observed by the samples.
+	PeakInUse int `json:"peak-in-use"`
+	// WaitCount and WaitDuration are the number of the times waited for a connection and the total time waited in
+	// this run, the time waited by the concurrent goroutines is summed up.
+	WaitCount    int64         `json:"wait-count"`
+	WaitDuration time.Duration `json:"wait-duration"`
+}
+
+// connPoolWaitWarnRatio is the ratio of the time waited for the connections of a database to the time of the run,
+// above which the summary warns the connection pool is the bottleneck.
+const connPoolWaitWarnRatio = 0.1
+
+// IsWaitSignificant returns true if the time waited for the connections is above `connPoolWaitWarnRatio` of the
+// time of the run, which means the connection pool is the bottleneck.
+func (s *ConnPoolStats) IsWaitSignificant(elapsed time.Duration) bool {
+	return elapsed > 0 && float64(s.WaitDuration) > float64(elapsed)*connPoolWaitWarnRatio
+}
+
 // TableResult saves the check result for every table.
 type TableResult struct {
-	Schema      string                  `json:"schema"`
-	Table       string                  `json:"table"`
-	StructEqual bool                    `json:"struct-equal"`
-	DataSkip    bool                    `json:"data-skip"`
-	DataEqual   bool                    `json:"data-equal"`
-	MeetError   error                   `json:"-"`
-	ChunkMap    map[string]*ChunkResult `json:"chunk-result"` // `ChunkMap` stores the `ChunkResult` of each chunk of the table
+	Schema      string       `json:"schema"`
+	Table       string       `json:"table"`
+	StructEqual bool         `json:"struct-equal"`
+	DataSkip    bool         `json:"data-skip"`
+	DataEqual   bool         `json:"data-equal"`
+	MeetError   error        `json:"-"`
+	ChunkMap    ChunkResults `json:"chunk-result"` // `ChunkMap` stores the `ChunkResult` of each chunk of the table
+	// NoPKFallback means the table has no primary key or unique key,
+	// and all the columns are used as the order key to compare rows.
+	NoPKFallback bool `json:"no-pk-fallback"`
+	// Concurrency is the effective number of chunks of the table compared concurrently.
+	Concurrency int `json:"concurrency"`
+	// StructDiff is the classification of the struct mismatch, `StructDiffBreaking` or `StructDiffNonBreaking`,
+	// empty if the structures are equal.
+	StructDiff string `json:"struct-diff,omitempty"`
+	// StructChanges are the differences of the columns and the indices of the struct mismatch, see `utils.GetStructChanges`.
+	StructChanges []*utils.StructChange `json:"struct-changes,omitempty"`
+	// StructFix is the statements to make the structure of the target match the source by generate-struct-fix,
+	// see `utils.GenerateStructFix`.
+	StructFix []*utils.StructFixStatement `json:"struct-fix,omitempty"`
+	// ErrorChunk and ErrorChunkBound are the id and the bound of the chunk where `MeetError` happened,
+	// empty if the error is not related to a chunk.
+	ErrorChunk      string `json:"error-chunk,omitempty"`
+	ErrorChunkBound string `json:"error-chunk-bound,omitempty"`
+	// ErrorMessage is the message of `MeetError`, which is saved in the checkpoint since the error can't be
+	// serialized, and `MeetError` is restored from it after resuming.
+	ErrorMessage string `json:"error-message,omitempty"`
+	// ErrorCategory is the category of `MeetError` by `ClassifyError`, e.g. "timeout" or "permission".
+	ErrorCategory string `json:"error-category,omitempty"`
+	// SkipReason is why the data check is skipped besides the struct mismatch, e.g. "skipped: size 52GB > max 10GB".
+	SkipReason string `json:"skip-reason,omitempty"`
+	// ColumnsReordered means the columns of the target are reordered to match the source by name.
+	ColumnsReordered bool `json:"columns-reordered,omitempty"`
+	// CountOnly means the data is only verified by the row count, and the `ChunkMap` carries the count delta.
+	CountOnly bool `json:"count-only,omitempty"`
+	// CheckPath is how the data of the table is verified when admin-checksum is used, `CheckPathAdminChecksum` or
+	// `CheckPathChunks`, empty if `ADMIN CHECKSUM TABLE` is not tried.
+	CheckPath string `json:"check-path,omitempty"`
+	// VerificationMethod is how the data of the table is verified, e.g. `VerificationChecksumOnly`, which is the
+	// weakest method of the chunks compared, empty if no chunk is compared.
+	VerificationMethod string `json:"verification-method,omitempty"`
+	// IsView means the table is an updatable view whose data is compared by check-view-data, which is listed in the
+	// "Views" section of the summary rather than the tables.
+	IsView bool `json:"is-view,omitempty"`
+	// ChunksTotal, ChunksSampled and ChunksDiffered are the numbers of the chunks of the table split, compared and
+	// different in a sampled run by sample-rate, which are all 0 if all the chunks are compared.
+	ChunksTotal    int `json:"chunks-total,omitempty"`
+	ChunksSampled  int `json:"chunks-sampled,omitempty"`
+	ChunksDiffered int `json:"chunks-differed,omitempty"`
+	// Duration is the time spent comparing the chunks of the table, which is summed over the chunks
+	// compared concurrently, so it may be longer than the wall time.
+	Duration time.Duration `json:"duration,omitempty"`
+	// SchemaDiff is the unified diff of the normalized `CREATE TABLE` statements of the source and the target,
+	// which is only set in the struct-only mode and empty if the structures are equal.
+	SchemaDiff string `json:"schema-diff,omitempty"`
+	// Range is the `range` of the table config applied to both sides, so only the rows in it are compared, and
+	// the result doesn't cover the rows out of it.
+	Range string `json:"range,omitempty"`
+	// ColumnTransforms are the built-in transforms of the columns applied to the source values before comparison.
+	ColumnTransforms map[string]string `json:"column-transforms,omitempty"`
+	// TrimmedColumns are the CHAR and VARCHAR columns whose trailing spaces are ignored by trim-char-padding.
+	TrimmedColumns []string `json:"trimmed-columns,omitempty"`
+	// ReorderedEnumColumns are the ENUM and SET columns whose members are in different orders on both sides,
+	// and they are compared by value.
+	ReorderedEnumColumns []string `json:"reordered-enum-columns,omitempty"`
+	// GeometryColumns are the GEOMETRY columns compared by the SRID and the WKB by compare-geometry, and
+	// SRIDMismatchColumns are the ones whose values have the same WKB but the different SRIDs on both sides.
+	GeometryColumns     []string `json:"geometry-columns,omitempty"`
+	SRIDMismatchColumns []string `json:"srid-mismatch-columns,omitempty"`
+	// TranscodedColumns are the columns whose source values are transcoded to the charsets of the target by charset-map.
+	TranscodedColumns []*TranscodedColumn `json:"transcoded-columns,omitempty"`
+	// EstimatedRows is the row count of the table in the statistics of the target, which is nil if it's not fetched.
+	// ActualRows is the rows checked by the chunks, which is summed over the chunks compared.
+	EstimatedRows *int64 `json:"estimated-rows,omitempty"`
+	ActualRows    int64  `json:"actual-rows,omitempty"`
+	// SourceRows and TargetRows are the row counts of the table on both sides by `SELECT COUNT(*)` in the range,
+	// which are compared before the chunks by count-precheck and check-mode "count" or "count-then-full", nil if
+	// the row counts are not compared.
+	SourceRows *int64 `json:"source-rows,omitempty"`
+	TargetRows *int64 `json:"target-rows,omitempty"`
+	// StructCheckedShards is the number of the shards whose structures are checked by shard-struct-sample in `Shards`,
+	// and the structures of the other shards are assumed to be equal. Both are 0 if all the shards are checked.
+	StructCheckedShards int `json:"struct-checked-shards,omitempty"`
+	Shards              int `json:"shards,omitempty"`
+	// DiffRowsFile is the CSV file of the rows only on one side exported by export-diff-rows, and DiffRowsExported
+	// is the number of the rows exported to it, which is capped by max-export-rows.
+	DiffRowsFile     string `json:"diff-rows-file,omitempty"`
+	DiffRowsExported int64  `json:"diff-rows-exported,omitempty"`
+	// RowDiffsExported is the number of the different rows exported by export-row-diffs into the files of the chunks,
+	// and RowDiffsSuppressed is the number of the ones not exported because of max-row-diffs.
+	RowDiffsExported   int64 `json:"row-diffs-exported,omitempty"`
+	RowDiffsSuppressed int64 `json:"row-diffs-suppressed,omitempty"`
+	// FixSQLFile is the fix sql file of the table with fix-file-layout = "table", which has the fix sql of all
+	// the chunks of the table, so the table can be re-applied selectively.
+	FixSQLFile string `json:"fix-sql-file,omitempty"`
+	// DuplicateKeyColumns are the columns of the unique key whose values are shared by more than one row found by
+	// check-pk-uniqueness, and SourceDuplicateKeys and TargetDuplicateKeys are the duplicate values on each side,
+	// which are capped. The data check of the table is skipped with an error if any is found.
+	DuplicateKeyColumns []string              `json:"duplicate-key-columns,omitempty"`
+	SourceDuplicateKeys []*utils.DuplicateKey `json:"source-duplicate-keys,omitempty"`
+	TargetDuplicateKeys []*utils.DuplicateKey `json:"target-duplicate-keys,omitempty"`
+	// IndexResults are the results of the indices compared by check-index, `index` => `IndexResult`, which are
+	// separate from the data, i.e. the data may be equal while an index is inconsistent.
+	IndexResults map[string]*IndexResult `json:"index-results,omitempty"`
+	// AdaptiveChunks is the number of the chunks split by adaptive-chunking, and ChunkSkewFactor is the most sampled
+	// rows in a chunk divided by the average. Both are 0 if the chunks are not split by adaptive-chunking.
+	AdaptiveChunks  int     `json:"adaptive-chunks,omitempty"`
+	ChunkSkewFactor float64 `json:"chunk-skew-factor,omitempty"`
+	// DiffLimitExceeded means the diff rows of the table exceed max-diff-rows-per-table, so the comparison of the
+	// table is truncated, and TruncatedChunks is the number of the chunks not compared after it. The diff rows of the
+	// table are partial counts then, and the fix sql only covers the chunks compared.
+	DiffLimitExceeded bool `json:"diff-limit-exceeded,omitempty"`
+	TruncatedChunks   int  `json:"truncated-chunks,omitempty"`
+
+	// runningChunks is the number of the chunks being compared in this run, and lastChunkStarted means
+	// the last chunk of the table has started, so the table is completed when no chunk is running.
+	runningChunks    int
+	lastChunkStarted bool
+}
+
+// IndexResult is the result of an index compared by check-index.
+type IndexResult struct {
+	// MismatchChunks are the chunks whose checksums of the index are different on both sides.
+	MismatchChunks []string `json:"mismatch-chunks,omitempty"`
+}
+
+// TranscodedColumn is a column whose source values are transcoded from the charset From to the charset To by charset-map.
+type TranscodedColumn struct {
+	Column string `json:"column"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	// UnmappableValues is the number of the source values compared which have the characters that can't be mapped to
+	// the charset To, and UnmappableChars are the distinct ones of the characters, which are capped.
+	UnmappableValues int64    `json:"unmappable-values,omitempty"`
+	UnmappableChars  []string `json:"unmappable-chars,omitempty"`
+}
+
+// maxUnmappableChars is the most distinct un-mappable characters of a column kept in the report.
+const maxUnmappableChars = 10
+
+// cloneTranscodedColumns returns a deep copy of the transcoded columns.
+func cloneTranscodedColumns(columns []*TranscodedColumn) []*TranscodedColumn {
+	if columns == nil {
+		return nil
+	}
+	results := make([]*TranscodedColumn, 0, len(columns))
+	for _, column := range columns {
+		c := *column
+		c.UnmappableChars = append([]string(nil), column.UnmappableChars...)
+		results = append(results, &c)
+	}
+	return results
+}
+
+// cloneIndexResults returns a deep copy of the index results.
+func cloneIndexResults(indexResults map[string]*IndexResult) map[string]*IndexResult {
+	if indexResults == nil {
+		return nil
+	}
+	results := make(map[string]*IndexResult, len(indexResults))
+	for index, indexResult := range indexResults {
+		results[index] = &IndexResult{MismatchChunks: append([]string(nil), indexResult.MismatchChunks...)}
+	}
+	return results
+}
+
+// hasIndexMismatch returns whether any index compared by check-index is inconsistent.
+func (t *TableResult) hasIndexMismatch() bool {
+	for _, indexResult := range t.IndexResults {
+		if len(indexResult.MismatchChunks) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// getResult returns the result of the table in the precedence of Error > Fail > Pass, only the structure
+// is required to be equal if checkStructOnly is true.
+func (t *TableResult) getResult(checkStructOnly bool) string {
+	if t.MeetError != nil {
+		return Error
+	}
+	if t.StructEqual && (t.DataEqual && !t.hasIndexMismatch() || checkStructOnly) {
+		return Pass
+	}
+	return Fail
+}
+
+// clone returns a deep copy of the table result, including `ChunkMap`, `ColumnTransforms` and the column lists.
+func (t *TableResult) clone() *TableResult {
+	result := *t
+	if t.ChunkMap != nil {
+		result.ChunkMap = make(ChunkResults, len(t.ChunkMap))
+		for id, chunkResult := range t.ChunkMap {
+			c := *chunkResult
+			result.ChunkMap[id] = &c
+		}
+	}
+	if t.ColumnTransforms != nil {
+		result.ColumnTransforms = make(map[string]string, len(t.ColumnTransforms))
+		for column, transform := range t.ColumnTransforms {
+			result.ColumnTransforms[column] = transform
+		}
+	}
+	if t.TrimmedColumns != nil {
+		result.TrimmedColumns = append([]string(nil), t.TrimmedColumns...)
+	}
+	if t.ReorderedEnumColumns != nil {
+		result.ReorderedEnumColumns = append([]string(nil), t.ReorderedEnumColumns...)
+	}
+	if t.GeometryColumns != nil {
+		result.GeometryColumns = append([]string(nil), t.GeometryColumns...)
+	}
+	if t.SRIDMismatchColumns != nil {
+		result.SRIDMismatchColumns = append([]string(nil), t.SRIDMismatchColumns...)
+	}
+	if t.DuplicateKeyColumns != nil {
+		result.DuplicateKeyColumns = append([]string(nil), t.DuplicateKeyColumns...)
+	}
+	result.TranscodedColumns = cloneTranscodedColumns(t.TranscodedColumns)
+	// the duplicate keys are never modified after being set.
+	if t.SourceDuplicateKeys != nil {
+		result.SourceDuplicateKeys = append([]*utils.DuplicateKey(nil), t.SourceDuplicateKeys...)
+	}
+	if t.TargetDuplicateKeys != nil {
+		result.TargetDuplicateKeys = append([]*utils.DuplicateKey(nil), t.TargetDuplicateKeys...)
+	}
+	// the struct changes and the struct fix are never modified after being set.
+	if t.StructChanges != nil {
+		result.StructChanges = append([]*utils.StructChange(nil), t.StructChanges...)
+	}
+	if t.StructFix != nil {
+		result.StructFix = append([]*utils.StructFixStatement(nil), t.StructFix...)
+	}
+	result.IndexResults = cloneIndexResults(t.IndexResults)
+	return &result
+}
+
+// ViewResult saves the check result for every view.
+type ViewResult struct {
+	Schema string `json:"schema"`
+	View   string `json:"view"`
+	Status string `json:"status"`
+}
+
+// QueryPairResult saves the check result for every pair of the queries in query-pairs.
+type QueryPairResult struct {
+	Label string `json:"label"`
+	Equal bool   `json:"equal"`
+	// SourceRows and TargetRows are the rows of the result sets of the source query and the target query.
+	SourceRows int64 `json:"source-rows"`
+	TargetRows int64 `json:"target-rows"`
+	// RowsAdd and RowsDelete are the rows to add to and delete from the target result set to match the source, and
+	// the rows with the same key but different values are counted in both like the tables.
+	RowsAdd    int64 `json:"rows-add"`
+	RowsDelete int64 `json:"rows-delete"`
+	// ErrorMessage is the error met when running or comparing the queries, and ErrorCategory is its category.
+	ErrorMessage  string `json:"error-message,omitempty"`
+	ErrorCategory string `json:"error-category,omitempty"`
+}
+
+// MissingTable is a table which only exists on one side, which is not compared.
+type MissingTable struct {
+	Schema string `json:"schema"`
+	Table  string `json:"table"`
+	// MissingOn is the side where the table is missing, `MissingOnSource` or `MissingOnTarget`.
+	MissingOn string `json:"missing-on"`
+	// StructFix is the statement to create or drop the table on the target by generate-struct-fix.
+	StructFix *utils.StructFixStatement `json:"struct-fix,omitempty"`
+}
+
+// SkippedObject is an object which can't be compared, e.g. a sequence or a table of the FEDERATED engine.
+type SkippedObject struct {
+	// Schema and Table are the names of the object on the target.
+	Schema string `json:"schema"`
+	Table  string `json:"table"`
+	// On is the side where the object is, `ObjectOnSource` or `ObjectOnTarget`.
+	On     string `json:"on"`
+	Type   string `json:"type"`
+	Engine string `json:"engine,omitempty"`
+	Reason string `json:"reason"`
 }
 
 // ChunkResult save the necessarily information to provide summary information
+// `RowsAdd` and `RowsDelete` are relative to the fix target, e.g. when fix-target is "source",
+// `RowsAdd` is the number of rows needed to add into the source.
 type ChunkResult struct {
-	RowsAdd    int `json:"rows-add"`    // `RowAdd` is the number of rows needed to add
-	RowsDelete int `json:"rows-delete"` // `RowDelete` is the number of rows needed to delete
+	RowsAdd     int   `json:"rows-add"`                // `RowAdd` is the number of rows needed to add
+	RowsDelete  int   `json:"rows-delete"`             // `RowDelete` is the number of rows needed to delete
+	FixSQLBytes int64 `json:"fix-sql-bytes,omitempty"` // `FixSQLBytes` is the bytes of the fix sql files written
+	// `Partition` is the partition of the chunk if the table is split by partition.
+	Partition string `json:"partition,omitempty"`
+	// `ChecksumDuration` is the time of the checksum query of the chunk, which is recorded for the equal chunks too.
+	ChecksumDuration time.Duration `json:"checksum-duration,omitempty"`
+	// `Bounds` is the bounds of the inconsistent chunk, so that the chunk can be compared again alone by its id.
+	Bounds *ChunkBounds `json:"bounds,omitempty"`
+	// `RowDiffsFile` is the NDJSON file of the different rows of the chunk exported by export-row-diffs.
+	RowDiffsFile string `json:"row-diffs-file,omitempty"`
+}
+
+// ChunkBounds is the bounds of a chunk, see `chunk.Range`. The chunk has no bound if it covers the whole table.
+type ChunkBounds struct {
+	Bounds     []*chunk.Bound `json:"bounds"`
+	NullColumn string         `json:"null-column,omitempty"`
+}
+
+// ChunkRange returns the range of the chunk built by the bounds, whose conditions are not initialized.
+// It's nil if the bounds are not recorded.
+func (c *ChunkResult) ChunkRange(id *chunk.ChunkID) *chunk.Range {
+	if c.Bounds == nil {
+		return nil
+	}
+	chunkRange := chunk.NewChunkRange()
+	chunkRange.Index = id.Copy()
+	chunkRange.Partition = c.Partition
+	chunkRange.NullColumn = c.Bounds.NullColumn
+	for _, bound := range c.Bounds.Bounds {
+		chunkRange.Update(bound.Column, bound.Lower, bound.Upper, bound.HasLower, bound.HasUpper)
+	}
+	return chunkRange
+}
+
+// slowChunkCount is the number of the slowest chunks listed in the summary.
+const slowChunkCount = 10
+
+// SlowChunk is one of the chunks whose checksum queries are the slowest across the tables.
+type SlowChunk struct {
+	Schema           string         `json:"schema"`
+	Table            string         `json:"table"`
+	ChunkID          *chunk.ChunkID `json:"chunk-id"`
+	Bound            string         `json:"bound"`
+	ChecksumDuration time.Duration  `json:"checksum-duration"`
+}
+
+// ChunkResults is the map of `chunk id` => `ChunkResult`.
+type ChunkResults map[string]*ChunkResult
+
+// MarshalJSON implements the json.Marshaler interface, the chunks are serialized in the order of the chunk id,
+// so that the output is reproducible and readable, e.g. `0:0-0:2:10` is before `0:0-0:10:10`.
+func (c ChunkResults) MarshalJSON() ([]byte, error) {
+	ids := make([]string, 0, len(c))
+	chunkIDs := make(map[string]*chunk.ChunkID, len(c))
+	for id := range c {
+		chunkID := new(chunk.ChunkID)
+		if err := chunkID.FromString(id); err != nil {
+			return nil, errors.Trace(err)
+		}
+		ids = append(ids, id)
+		chunkIDs[id] = chunkID
+	}
+	sort.Slice(ids, func(i, j int) bool { return chunkIDs[ids[i]].Compare(chunkIDs[ids[j]]) < 0 })
+
+	buf := new(bytes.Buffer)
+	buf.WriteByte('{')
+	for i, id := range ids {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(id)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		value, err := json.Marshal(c[id])
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
 }
 
+// nowFunc returns the current time, it's replaced in the tests to get the reproducible summary.
+var nowFunc = time.Now
+
 // Report saves the check results.
 type Report struct {
 	sync.RWMutex
 	Result       string                             `json:"-"`             // Result is pass or fail
-	PassNum      int32                              `json:"-"`             // The pass number of tables
-	FailedNum    int32                              `json:"-"`             // The failed number of tables
+	PassNum      int32                              `json:"pass-num"`      // The pass number of tables
+	FailedNum    int32                              `json:"failed-num"`    // The failed number of tables, excluding the errored ones
+	ErrorNum     int32                              `json:"error-num"`     // The number of tables meeting errors
 	TableResults map[string]map[string]*TableResult `json:"table-results"` // TableResult saved the map of  `schema` => `table` => `tableResult`
-	StartTime    time.Time                          `json:"start-time"`
-	Duration     time.Duration                      `json:"time-duration"`
-	TotalSize    int64                              `json:"-"` // Total size of the checked tables
+	StartTime    time.Time                          `json:"start-time"`    // The start time of this run, which is reset after resuming
+	Duration     time.Duration                      `json:"time-duration"` // The total time of all the runs when the report is saved, see `TotalDuration`
+	FixTarget    string                             `json:"fix-target"`    // The side the fix sql is generated for
+	Aborted      bool                               `json:"aborted"`       // The comparison is aborted by `max-diff-rows`, and the results are partial
+	Interrupted  bool                               `json:"interrupted"`   // The comparison is interrupted by canceling, and the results are partial
+	TotalSize    int64                              `json:"-"`             // Total size of the checked tables
 	SourceConfig [][]byte                           `json:"-"`
 	TargetConfig []byte                             `json:"-"`
+	// SizeUnavailableTables is the number of the tables whose sizes fail to get, which aren't in `TotalSize`.
+	SizeUnavailableTables int `json:"-"`
+	// TimedOut means the comparison is interrupted by exceeding `RunTimeout`, the results are incomplete
+	// and there is no pass or fail verdict.
+	TimedOut   bool          `json:"timed-out,omitempty"`
+	RunTimeout time.Duration `json:"run-timeout,omitempty"`
+	// FailedFast means the comparison is stopped by fail-fast after FailedFastTable is found different,
+	// and the results are partial.
+	FailedFast      bool   `json:"failed-fast,omitempty"`
+	FailedFastTable string `json:"failed-fast-table,omitempty"`
+	// ElapsedBeforeResume is the time accumulated by the previous runs before this run resumes from the checkpoint,
+	// which is the `Duration` saved in the checkpoint. The time after the checkpoint of an interrupted run is not
+	// counted since the chunks after it are compared again.
+	ElapsedBeforeResume time.Duration `json:"elapsed-before-resume,omitempty"`
+	// SourceVersions and TargetVersion are the versions of the database servers, which are nil if failed to get.
+	SourceVersions []*ServerVersion `json:"source-versions,omitempty"`
+	TargetVersion  *ServerVersion   `json:"target-version,omitempty"`
+	// ConnPoolStats are the statistics of the connection pools of the sources followed by the target.
+	ConnPoolStats []*ConnPoolStats `json:"conn-pool-stats,omitempty"`
+	// ConfigOverrides are the config values overridden by the environment variables and `--override`.
+	ConfigOverrides []string `json:"config-overrides,omitempty"`
+	// ConfirmedChunks and TransientChunks are the numbers of the failed chunks which are still different
+	// and become equal after being rechecked by `recheck-failed-chunks`.
+	ConfirmedChunks int64 `json:"confirmed-chunks,omitempty"`
+	TransientChunks int64 `json:"transient-chunks,omitempty"`
+	// HealedOnRecheck is the number of the transient chunks become equal on each recheck by recheck-times in order,
+	// which shows how long the diffs last to tune recheck-delay.
+	HealedOnRecheck []int64 `json:"healed-on-recheck,omitempty"`
+	// CheckStructOnly means only the table structures are compared by `check-struct-only`,
+	// and the tables pass if their structures are equal.
+	CheckStructOnly bool `json:"check-struct-only,omitempty"`
+	// ViewResults are the results of the views sorted by name, which are compared separately from the tables,
+	// and only the views unequal or missing on the target cause Fail if `CheckViews` is true.
+	ViewResults []*ViewResult `json:"view-results,omitempty"`
+	CheckViews  bool          `json:"check-views,omitempty"`
+	// QueryPairResults are the results of query-pairs sorted by the label, which are compared after the tables.
+	QueryPairResults []*QueryPairResult `json:"query-pair-results,omitempty"`
+	// MissingTables are the tables only exist on one side sorted by name, which cause Fail if `FailOnMissingTables` is true.
+	MissingTables       []*MissingTable `json:"missing-tables,omitempty"`
+	FailOnMissingTables bool            `json:"fail-on-missing-tables,omitempty"`
+	// SkippedObjects are the objects which can't be compared sorted by name, the tables routed from or to them are not compared.
+	SkippedObjects []*SkippedObject `json:"skipped-objects,omitempty"`
+	// StructFixFile is the file of the statements to make the structures of the target match the source by
+	// generate-struct-fix, empty if there is no statement.
+	StructFixFile string `json:"struct-fix-file,omitempty"`
+	// SlowChunks are the chunks whose checksum queries are the slowest sorted by the duration in descending order,
+	// at most `slowChunkCount` chunks are kept with their bounds.
+	SlowChunks []*SlowChunk `json:"slow-chunks,omitempty"`
+	// SampleRate is the fraction of the chunks compared by sample-rate, and SampleSeed is the seed to select them,
+	// both are 0 if all the chunks are compared. The tables passed in a sampled run are not fully verified.
+	SampleRate float64 `json:"sample-rate,omitempty"`
+	SampleSeed int64   `json:"sample-seed,omitempty"`
+	// ReplicationPositions are the replication positions observed last by wait-sync, and ReplicationLag means they
+	// don't catch up before the timeout, so the data check of the tables is skipped for the replication lag.
+	ReplicationPositions []*utils.ReplicationPosition `json:"replication-positions,omitempty"`
+	ReplicationLag       bool                         `json:"replication-lag,omitempty"`
+	// PreviousRun is the run in the history of keep-history which the diff rows of the tables are compared with, and
+	// Regressions are the tables failed in this run whose diff rows grow since the previous run.
+	PreviousRun string        `json:"previous-run,omitempty"`
+	Regressions []*TableDelta `json:"regressions,omitempty"`
 
 	task *config.TaskConfig `json:"-"`
+	sink ReportSink         `json:"-"`
+	// committedFiles is the name => the content of the files written into the sink by CommitSummary, see
+	// ArchiveFiles.
+	committedFiles map[string][]byte
+
+	// maxDiffRows is the limit of diffRows, 0 means no limit.
+	maxDiffRows int64
+	// maxDiffRowsPerTable is the limit of the diff rows of each table, the comparison of the table is truncated
+	// when it's exceeded, 0 means no limit.
+	maxDiffRowsPerTable int64
+	// failFast stops the comparison when the first table is found different.
+	failFast bool
+	// rowsEstimateWarnFactor is the factor of the divergence between the estimated and the actual rows
+	// to warn about the stale statistics, 0 means no warning.
+	rowsEstimateWarnFactor float64
+	// rawUnits writes the sizes in bytes and the row counts as is rather than humanized by `utils.HumanizeBytes`
+	// and `utils.HumanizeCount`.
+	rawUnits bool
+	// previous is the report of PreviousRun, and trend is the changes of the tables since it, which is computed when
+	// the summary is committed, both are nil if there is no previous run.
+	previous *Report
+	trend    *ReportDelta
+	// diffRows is the total number of rows needed to add and delete.
+	diffRows int64
+
+	// the progress of this run, which is logged by the heartbeat.
+	completedTables int
+	completedChunks int
+	currentTable    string
+
+	// dirty is the chunks of the tables mutated since they are saved in the checkpoint, keyed by the schema, the table
+	// and the chunk id, see `GetIncrement`. It's marked under the write lock, and taken under the read lock with dirtyMu.
+	dirtyMu sync.Mutex
+	dirty   map[string]map[string]map[string]*chunk.ChunkID
+}
+
+// Heartbeat is the progress of this run reported by the heartbeat log.
+type Heartbeat struct {
+	CompletedTables int
+	TotalTables     int
+	CompletedChunks int
+	// CurrentTable is the table of the chunk started latest.
+	CurrentTable string
+	RowsAdd      int
+	RowsDelete   int
 }
 
 // LoadReport loads the report from the checkpoint
 func (r *Report) LoadReport(reportInfo *Report) {
-	r.StartTime = time.Now()
+	r.StartTime = nowFunc()
+	r.ElapsedBeforeResume = reportInfo.Duration
 	r.Duration = reportInfo.Duration
 	r.TotalSize = reportInfo.TotalSize
+	// the comparison aborted by max-diff-rows before the checkpoint isn't continued after resuming.
+	r.Aborted = reportInfo.Aborted
+	r.ConfirmedChunks = reportInfo.ConfirmedChunks
+	r.TransientChunks = reportInfo.TransientChunks
+	r.HealedOnRecheck = reportInfo.HealedOnRecheck
+	r.SlowChunks = reportInfo.SlowChunks
+	r.SampleSeed = reportInfo.SampleSeed
 	for schema, tableMap := range reportInfo.TableResults {
 		if _, ok := r.TableResults[schema]; !ok {
 			r.TableResults[schema] = make(map[string]*TableResult)
 		}
 		for table, result := range tableMap {
+			result.ChunkMap = encodeChunkKeys(result.ChunkMap)
+			if len(result.ErrorChunk) > 0 {
+				result.ErrorChunk = encodeChunkID(result.ErrorChunk)
+			}
+			for _, indexResult := range result.IndexResults {
+				for i, id := range indexResult.MismatchChunks {
+					indexResult.MismatchChunks[i] = encodeChunkID(id)
+				}
+			}
+			if len(result.ErrorMessage) > 0 {
+				result.MeetError = &ResumedError{Message: result.ErrorMessage}
+				if len(result.ErrorCategory) == 0 {
+					// the checkpoint is saved by the old version without the category.
+					result.ErrorCategory = ErrorCategoryOther
+				}
+				r.Result = Error
+			}
 			r.TableResults[schema][table] = result
+			for _, chunkResult := range result.ChunkMap {
+				r.diffRows += int64(chunkResult.RowsAdd + chunkResult.RowsDelete)
+			}
+		}
+	}
+}
+
+// DropTablesSince removes the results of the table and the tables compared after it from the report loaded from
+// the checkpoint, so that they are compared again after resuming, see `retry-errored-tables`.
+func (r *Report) DropTablesSince(schema, table string) {
+	targetID := utils.UniqueID(schema, table)
+	// the tables are compared in the descending order of the id, see `source.NewSources`.
+	for schema, tableMap := range r.TableResults {
+		for table := range tableMap {
+			if utils.UniqueID(schema, table) <= targetID {
+				delete(tableMap, table)
+			}
+		}
+		if len(tableMap) == 0 {
+			delete(r.TableResults, schema)
 		}
 	}
+	slowChunks := r.SlowChunks[:0]
+	for _, c := range r.SlowChunks {
+		if utils.UniqueID(c.Schema, c.Table) > targetID {
+			slowChunks = append(slowChunks, c)
+		}
+	}
+	r.SlowChunks = slowChunks
+}
+
+// encodeChunkKeys converts the chunk ids in the form of `ChunkID.ToString`, which are the keys of the chunks saved
+// by the old versions, to the form of `ChunkID.Encode`. The chunk id that can't be parsed is kept as it is.
+func encodeChunkKeys(chunkMap ChunkResults) ChunkResults {
+	if chunkMap == nil {
+		return nil
+	}
+	encoded := make(ChunkResults, len(chunkMap))
+	for id, chunkResult := range chunkMap {
+		encoded[encodeChunkID(id)] = chunkResult
+	}
+	return encoded
+}
+
+// encodeChunkID converts the chunk id saved by the old versions in the form of `ChunkID.ToString` to the form of
+// `ChunkID.Encode`. The chunk id that can't be parsed is kept as it is.
+func encodeChunkID(id string) string {
+	chunkID := new(chunk.ChunkID)
+	if err := chunkID.FromString(id); err != nil {
+		log.Warn("fail to parse the chunk id in the checkpoint", zap.String("chunk id", id), zap.Error(err))
+		return id
+	}
+	return chunkID.Encode()
 }
 
 func (r *Report) getSortedTables() []string {
 	equalTables := make([]string, 0)
 	for schema, tableMap := range r.TableResults {
 		for table, result := range tableMap {
-			if result.StructEqual && result.DataEqual {
+			if result.StructEqual && result.DataEqual && !result.DataSkip && !result.CountOnly && !result.IsView {
 				equalTables = append(equalTables, dbutil.TableName(schema, table))
 			}
 		}
@@ -114,242 +783,1807 @@ func (r *Report) getSortedTables() []string {
 	return equalTables
 }
 
-func (r *Report) getDiffRows() [][]string {
-	diffRows := make([][]string, 0)
-	for schema, tableMap := range r.TableResults {
-		for table, result := range tableMap {
-			if result.StructEqual && result.DataEqual {
-				continue
-			}
-			diffRow := make([]string, 0)
-			diffRow = append(diffRow, dbutil.TableName(schema, table))
-			if !result.StructEqual {
-				diffRow = append(diffRow, "false")
-			} else {
-				diffRow = append(diffRow, "true")
-			}
-			rowAdd, rowDelete := 0, 0
-			for _, chunkResult := range result.ChunkMap {
-				rowAdd += chunkResult.RowsAdd
-				rowDelete += chunkResult.RowsDelete
+// getVerificationMethodRows returns the numbers of the equivalent tables verified by each method from the strongest to
+// the weakest, which is empty if no method is recorded.
+func (r *Report) getVerificationMethodRows() [][]string {
+	counts := make(map[string]int)
+	for _, tableMap := range r.TableResults {
+		for _, result := range tableMap {
+			if result.StructEqual && result.DataEqual && !result.DataSkip && len(result.VerificationMethod) > 0 {
+				counts[result.VerificationMethod]++
 			}
-			diffRow = append(diffRow, fmt.Sprintf("+%d/-%d", rowAdd, rowDelete))
-			diffRows = append(diffRows, diffRow)
 		}
 	}
-	return diffRows
+	rows := make([][]string, 0, len(counts))
+	for _, method := range verificationMethods {
+		if count, ok := counts[method]; ok {
+			rows = append(rows, []string{method, strconv.Itoa(count)})
+		}
+	}
+	return rows
 }
 
-// CalculateTotalSize calculate the total size of all the checked tables
-// Notice, user should run the analyze table first, when some of tables' size are zero.
-func (r *Report) CalculateTotalSize(ctx context.Context, db *sql.DB) {
-	for schema, tableMap := range r.TableResults {
-		for table := range tableMap {
-			size, err := utils.GetTableSize(ctx, db, schema, table)
-			if err != nil {
-				r.SetTableMeetError(schema, table, err)
-			}
-			if size == 0 {
-				log.Warn("fail to get the correct size of table, if you want to get the correct size, please analyze the corresponding tables", zap.String("table", dbutil.TableName(schema, table)))
-			} else {
-				r.TotalSize += size
-			}
+// getCountVerifiedTables returns the sorted tables whose row counts are equal, but the data is not fully compared.
+func (r *Report) getCountVerifiedTables() []string {
+	tables := make([]string, 0)
+	for _, name := range r.getSortedSchemaTables() {
+		result := r.TableResults[name[0]][name[1]]
+		if result.StructEqual && result.DataEqual && !result.DataSkip && result.CountOnly {
+			tables = append(tables, dbutil.TableName(name[0], name[1]))
 		}
 	}
+	return tables
 }
 
-// CommitSummary commit summary info
-func (r *Report) CommitSummary() error {
-	passNum, failedNum := int32(0), int32(0)
-	for _, tableMap := range r.TableResults {
-		for _, result := range tableMap {
-			if result.StructEqual && result.DataEqual {
-				passNum++
-			} else {
-				failedNum++
-			}
+// getAdminChecksumTables returns the sorted tables whose data is verified by `ADMIN CHECKSUM TABLE` without chunks.
+func (r *Report) getAdminChecksumTables() []string {
+	tables := make([]string, 0)
+	for _, name := range r.getSortedSchemaTables() {
+		result := r.TableResults[name[0]][name[1]]
+		if result.StructEqual && result.DataEqual && !result.DataSkip && result.CheckPath == CheckPathAdminChecksum {
+			tables = append(tables, dbutil.TableName(name[0], name[1]))
 		}
 	}
-	r.PassNum = passNum
-	r.FailedNum = failedNum
-	summaryPath := filepath.Join(r.task.OutputDir, "summary.txt")
-	summaryFile, err := os.Create(summaryPath)
-	if err != nil {
-		return errors.Trace(err)
-	}
-	defer summaryFile.Close()
-	summaryFile.WriteString("Summary\n\n\n\n")
-	summaryFile.WriteString("Source Database\n\n\n\n")
-	for i := 0; i < len(r.SourceConfig); i++ {
-		summaryFile.Write(r.SourceConfig[i])
-		summaryFile.WriteString("\n")
-	}
-	summaryFile.WriteString("Target Databases\n\n\n\n")
-	summaryFile.Write(r.TargetConfig)
-	summaryFile.WriteString("\n")
-
-	summaryFile.WriteString("Comparison Result\n\n\n\n")
-	summaryFile.WriteString("The table structure and data in following tables are equivalent\n\n")
-	equalTables := r.getSortedTables()
-	for _, table := range equalTables {
-		summaryFile.WriteString(table + "\n")
-	}
-	if r.Result == Fail {
-		summaryFile.WriteString("\nThe following tables contains inconsistent data\n\n")
-		tableString := &strings.Builder{}
-		table := tablewriter.NewWriter(tableString)
-		table.SetHeader([]string{"Table", "Structure equality", "Data diff rows"})
-		diffRows := r.getDiffRows()
-		for _, v := range diffRows {
-			table.Append(v)
-		}
-		table.Render()
-		summaryFile.WriteString(tableString.String())
-	}
-	duration := r.Duration + time.Since(r.StartTime)
-	summaryFile.WriteString(fmt.Sprintf("Time Cost: %s\n", duration))
-	summaryFile.WriteString(fmt.Sprintf("Average Speed: %fMB/s\n", float64(r.TotalSize)/(1024.0*1024.0*duration.Seconds())))
-	return nil
-}
-
-func (r *Report) Print(w io.Writer) error {
-	var summary strings.Builder
-	if r.Result == Pass {
-		summary.WriteString(fmt.Sprintf("A total of %d table have been compared and all are equal.\n", r.FailedNum+r.PassNum))
-		summary.WriteString(fmt.Sprintf("You can view the comparision details through '%s/%s'\n", r.task.OutputDir, config.LogFileName))
-	} else if r.Result == Fail {
-		for schema, tableMap := range r.TableResults {
-			for table, result := range tableMap {
-				if !result.StructEqual {
-					if result.DataSkip {
-						summary.WriteString(fmt.Sprintf("The structure of %s is not equal, and data-check is skipped\n", dbutil.TableName(schema, table)))
-					} else {
-						summary.WriteString(fmt.Sprintf("The structure of %s is not equal\n", dbutil.TableName(schema, table)))
-					}
-				}
-				if !result.DataEqual {
-					summary.WriteString(fmt.Sprintf("The data of %s is not equal\n", dbutil.TableName(schema, table)))
-				}
+	return tables
+}
+
+// getNoPKTables returns the sorted tables without primary key or unique key whose data is compared,
+// the skipped ones are listed with the reason.
+func (r *Report) getNoPKTables() []string {
+	fallbackTables := make([]string, 0)
+	for schema, tableMap := range r.TableResults {
+		for table, result := range tableMap {
+			if result.NoPKFallback && !result.DataSkip {
+				fallbackTables = append(fallbackTables, dbutil.TableName(schema, table))
 			}
 		}
-		summary.WriteString("\n")
-		summary.WriteString("The rest of tables are all equal.\n")
-		summary.WriteString(fmt.Sprintf("The patch file has been generated in \n\t'%s/'\n", r.task.FixDir))
-		summary.WriteString(fmt.Sprintf("You can view the comparision details through '%s/%s'\n", r.task.OutputDir, config.LogFileName))
-	} else {
-		summary.WriteString("Error in comparison process:\n")
-		for schema, tableMap := range r.TableResults {
-			for table, result := range tableMap {
-				summary.WriteString(fmt.Sprintf("%s error occured in %s\n", result.MeetError.Error(), dbutil.TableName(schema, table)))
-			}
+	}
+	sort.Strings(fallbackTables)
+	return fallbackTables
+}
+
+// getReorderedTables returns the sorted tables whose columns are reordered to match the source by name.
+func (r *Report) getReorderedTables() []string {
+	tables := make([]string, 0)
+	for _, name := range r.getSortedSchemaTables() {
+		if r.TableResults[name[0]][name[1]].ColumnsReordered {
+			tables = append(tables, dbutil.TableName(name[0], name[1]))
 		}
-		summary.WriteString(fmt.Sprintf("You can view the comparision details through '%s/%s'\n", r.task.OutputDir, config.LogFileName))
 	}
-	fmt.Fprint(w, summary.String())
-	return nil
+	return tables
 }
 
-// NewReport returns a new Report.
-func NewReport(task *config.TaskConfig) *Report {
-	return &Report{
-		TableResults: make(map[string]map[string]*TableResult),
-		Result:       Pass,
-		task:         task,
+// getRangeTables returns the sorted tables compared in the range with the range like "`schema`.`table` a > 10".
+func (r *Report) getRangeTables() []string {
+	tables := make([]string, 0)
+	for _, name := range r.getSortedSchemaTables() {
+		if result := r.TableResults[name[0]][name[1]]; len(result.Range) > 0 {
+			tables = append(tables, fmt.Sprintf("%s %s", dbutil.TableName(name[0], name[1]), result.Range))
+		}
 	}
+	return tables
 }
 
-func (r *Report) Init(tableDiffs []*common.TableDiff, sourceConfig [][]byte, targetConfig []byte) {
-	r.StartTime = time.Now()
-	r.SourceConfig = sourceConfig
-	r.TargetConfig = targetConfig
-	for _, tableDiff := range tableDiffs {
-		schema, table := tableDiff.Schema, tableDiff.Table
-		if _, ok := r.TableResults[schema]; !ok {
-			r.TableResults[schema] = make(map[string]*TableResult)
+// getColumnTransforms returns the applied column transforms like "`schema`.`table`.`column` lower" sorted by the table and the column.
+func (r *Report) getColumnTransforms() []string {
+	transforms := make([]string, 0)
+	for _, name := range r.getSortedSchemaTables() {
+		result := r.TableResults[name[0]][name[1]]
+		columns := make([]string, 0, len(result.ColumnTransforms))
+		for column := range result.ColumnTransforms {
+			columns = append(columns, column)
 		}
-		r.TableResults[schema][table] = &TableResult{
-			Schema:      schema,
-			Table:       table,
-			StructEqual: true,
-			DataEqual:   true,
-			MeetError:   nil,
-			ChunkMap:    make(map[string]*ChunkResult),
+		sort.Strings(columns)
+		for _, column := range columns {
+			transforms = append(transforms, fmt.Sprintf("%s.%s %s", dbutil.TableName(name[0], name[1]), dbutil.ColumnName(column), result.ColumnTransforms[column]))
 		}
 	}
+	return transforms
 }
 
-// SetTableStructCheckResult sets the struct check result for table.
-func (r *Report) SetTableStructCheckResult(schema, table string, equal bool, skip bool) {
-	r.Lock()
-	defer r.Unlock()
-	tableResult := r.TableResults[schema][table]
-	tableResult.StructEqual = equal
-	tableResult.DataSkip = skip
-	if !equal && r.Result != Error {
-		r.Result = Fail
+// getTrimmedColumns returns the columns whose trailing spaces are ignored like "`schema`.`table`.`column`",
+// sorted by the table and the column.
+func (r *Report) getTrimmedColumns() []string {
+	return r.getColumns(func(result *TableResult) []string { return result.TrimmedColumns })
+}
+
+// getReorderedEnumColumns returns the ENUM and SET columns compared by value like "`schema`.`table`.`column`",
+// sorted by the table and the column.
+func (r *Report) getReorderedEnumColumns() []string {
+	return r.getColumns(func(result *TableResult) []string { return result.ReorderedEnumColumns })
+}
+
+// getGeometryColumns returns the GEOMETRY columns compared by the SRID and the WKB like "`schema`.`table`.`column`",
+// sorted by the table and the column.
+func (r *Report) getGeometryColumns() []string {
+	return r.getColumns(func(result *TableResult) []string { return result.GeometryColumns })
+}
+
+// getSRIDMismatchColumns returns the GEOMETRY columns whose SRIDs are different like "`schema`.`table`.`column`",
+// sorted by the table and the column.
+func (r *Report) getSRIDMismatchColumns() []string {
+	return r.getColumns(func(result *TableResult) []string { return result.SRIDMismatchColumns })
+}
+
+func (r *Report) getColumns(columnsOf func(*TableResult) []string) []string {
+	columns := make([]string, 0)
+	for _, name := range r.getSortedSchemaTables() {
+		tableColumns := append([]string(nil), columnsOf(r.TableResults[name[0]][name[1]])...)
+		sort.Strings(tableColumns)
+		for _, column := range tableColumns {
+			columns = append(columns, fmt.Sprintf("%s.%s", dbutil.TableName(name[0], name[1]), dbutil.ColumnName(column)))
+		}
 	}
+	return columns
 }
 
-// SetTableDataCheckResult sets the data check result for table.
-func (r *Report) SetTableDataCheckResult(schema, table string, equal bool, rowsAdd, rowsDelete int, id *chunk.ChunkID) {
-	r.Lock()
-	defer r.Unlock()
-	if !equal {
-		result := r.TableResults[schema][table]
-		result.DataEqual = equal
-		if _, ok := result.ChunkMap[id.ToString()]; !ok {
-			result.ChunkMap[id.ToString()] = &ChunkResult{
-				RowsAdd:    0,
-				RowsDelete: 0,
+// minRowsToWarnStaleStats is the least rows of a table to warn about its stale statistics,
+// because the estimated row count of a small table diverges easily, and it hardly affects the plans.
+const minRowsToWarnStaleStats = 1000
+
+// getStaleStatsRows returns the table name, the estimated rows and the actual rows of the tables whose estimated
+// row count diverges from the actual rows by more than `rowsEstimateWarnFactor` times, sorted by the table name.
+// Only the tables whose data is compared completely and equal are checked, so the actual rows are the rows of the target.
+func (r *Report) getStaleStatsRows() [][]string {
+	rows := make([][]string, 0)
+	if r.rowsEstimateWarnFactor <= 0 || r.Aborted || r.Interrupted || r.FailedFast {
+		return rows
+	}
+	for _, name := range r.getSortedSchemaTables() {
+		result := r.TableResults[name[0]][name[1]]
+		if result.EstimatedRows == nil || result.DataSkip || result.CountOnly || !result.DataEqual || result.MeetError != nil {
+			continue
+		}
+		estimated, actual := *result.EstimatedRows, result.ActualRows
+		less, greater := estimated, actual
+		if less > greater {
+			less, greater = greater, less
+		}
+		if greater < minRowsToWarnStaleStats || float64(greater) <= r.rowsEstimateWarnFactor*float64(less) {
+			continue
+		}
+		rows = append(rows, []string{dbutil.TableName(name[0], name[1]), strconv.FormatInt(estimated, 10), strconv.FormatInt(actual, 10)})
+	}
+	return rows
+}
+
+// getDuplicateKeyRows returns the duplicate values of the unique keys found by check-pk-uniqueness like
+// ["`schema`.`table`", "source", "(`id`)", "(1)", "2"], sorted by the table name.
+func (r *Report) getDuplicateKeyRows() [][]string {
+	rows := make([][]string, 0)
+	for _, name := range r.getSortedSchemaTables() {
+		result := r.TableResults[name[0]][name[1]]
+		if len(result.DuplicateKeyColumns) == 0 {
+			continue
+		}
+		columns := make([]string, 0, len(result.DuplicateKeyColumns))
+		for _, column := range result.DuplicateKeyColumns {
+			columns = append(columns, dbutil.ColumnName(column))
+		}
+		key := "(" + strings.Join(columns, ", ") + ")"
+		for _, side := range []struct {
+			name string
+			keys []*utils.DuplicateKey
+		}{{"source", result.SourceDuplicateKeys}, {"target", result.TargetDuplicateKeys}} {
+			for _, duplicateKey := range side.keys {
+				rows = append(rows, []string{dbutil.TableName(name[0], name[1]), side.name, key,
+					"(" + strings.Join(duplicateKey.Values, ", ") + ")", strconv.FormatInt(duplicateKey.Rows, 10)})
 			}
 		}
-		result.ChunkMap[id.ToString()].RowsAdd += rowsAdd
-		result.ChunkMap[id.ToString()].RowsDelete += rowsDelete
-		if r.Result != Error {
-			r.Result = Fail
+	}
+	return rows
+}
+
+// getRowCountDeltaRows returns the table name, the source rows, the target rows and the delta of the target to the
+// source of the tables whose row counts are different by count-precheck, sorted by the table name. The tables only
+// verified by the row count are listed as the inconsistent tables instead.
+func (r *Report) getRowCountDeltaRows() [][]string {
+	rows := make([][]string, 0)
+	for _, name := range r.getSortedSchemaTables() {
+		result := r.TableResults[name[0]][name[1]]
+		if result.SourceRows == nil || result.TargetRows == nil || result.CountOnly || *result.SourceRows == *result.TargetRows {
+			continue
+		}
+		delta := *result.TargetRows - *result.SourceRows
+		deltaString := r.formatCount(delta)
+		if delta > 0 {
+			deltaString = "+" + deltaString
 		}
+		rows = append(rows, []string{dbutil.TableName(name[0], name[1]), r.formatCount(*result.SourceRows),
+			r.formatCount(*result.TargetRows), deltaString})
 	}
-	if !equal && r.Result != Error {
-		r.Result = Fail
+	return rows
+}
+
+// getTruncatedTables returns the tables whose comparison is truncated by max-diff-rows-per-table with the number of
+// the chunks not compared, sorted by the table name.
+func (r *Report) getTruncatedTables() []string {
+	tables := make([]string, 0)
+	for _, name := range r.getSortedSchemaTables() {
+		result := r.TableResults[name[0]][name[1]]
+		if !result.DiffLimitExceeded {
+			continue
+		}
+		tables = append(tables, fmt.Sprintf("%s: %d chunks not compared", dbutil.TableName(name[0], name[1]), result.TruncatedChunks))
 	}
+	return tables
 }
 
-// SetTableMeetError sets meet error when check the table.
-func (r *Report) SetTableMeetError(schema, table string, err error) {
-	r.Lock()
-	defer r.Unlock()
-	if _, ok := r.TableResults[schema]; !ok {
-		r.TableResults[schema] = make(map[string]*TableResult)
-		r.TableResults[schema][table] = &TableResult{
-			MeetError: err,
+// getAutoIncrementWarning returns the warning if the AUTO_INCREMENT values generated by the settings of any source
+// and the target are disjoint, which is empty if not. In the active-active topologies, the rows inserted on each side
+// never share the auto-increment primary keys, and they're all reported as the rows to add and delete.
+func (r *Report) getAutoIncrementWarning() string {
+	target := r.TargetVersion
+	if target == nil || target.AutoIncrementIncrement == 0 {
+		return ""
+	}
+	sources := make([]string, 0)
+	for i, source := range r.SourceVersions {
+		if source == nil || !utils.AutoIncrementDisjoint(source.AutoIncrementIncrement, source.AutoIncrementOffset,
+			target.AutoIncrementIncrement, target.AutoIncrementOffset) {
+			continue
 		}
-		return
+		sources = append(sources, fmt.Sprintf("Source Database %d (increment %d, offset %d)", i, source.AutoIncrementIncrement, source.AutoIncrementOffset))
+	}
+	if len(sources) == 0 {
+		return ""
 	}
+	return fmt.Sprintf("WARNING: the AUTO_INCREMENT values generated by %s and Target Database (increment %d, offset %d) never overlap, "+
+		"the rows inserted on each side don't share the auto-increment keys and are reported as the rows to add and delete, "+
+		"compare the tables by the natural keys with `index-fields` or `query-pairs` instead",
+		strings.Join(sources, ", "), target.AutoIncrementIncrement, target.AutoIncrementOffset)
+}
 
-	r.TableResults[schema][table].MeetError = err
-	r.Result = Error
+// getAdaptiveChunkingRows returns the table name, the number of the chunks and the skew factor of the tables split by
+// adaptive-chunking, sorted by the table name.
+func (r *Report) getAdaptiveChunkingRows() [][]string {
+	rows := make([][]string, 0)
+	for _, name := range r.getSortedSchemaTables() {
+		result := r.TableResults[name[0]][name[1]]
+		if result.AdaptiveChunks == 0 {
+			continue
+		}
+		rows = append(rows, []string{dbutil.TableName(name[0], name[1]), strconv.Itoa(result.AdaptiveChunks), strconv.FormatFloat(result.ChunkSkewFactor, 'f', 2, 64)})
+	}
+	return rows
 }
 
-// GetSnapshot get the snapshot of the current state of the report, then we can restart the
-// sync-diff and get the correct report state.
-func (r *Report) GetSnapshot(chunkID *chunk.ChunkID, schema, table string) (*Report, error) {
-	r.RLock()
-	defer r.RUnlock()
-	targetID := utils.UniqueID(schema, table)
-	reserveMap := make(map[string]map[string]*TableResult)
-	for schema, tableMap := range r.TableResults {
-		reserveMap[schema] = make(map[string]*TableResult)
-		for table, result := range tableMap {
+// getTranscodedColumnRows returns the columns transcoded by charset-map like
+// ["`schema`.`table`.`column`", "latin1", "utf8mb4", "2", "\"€\", \"😀\""], sorted by the table name and the column.
+func (r *Report) getTranscodedColumnRows() [][]string {
+	rows := make([][]string, 0)
+	for _, name := range r.getSortedSchemaTables() {
+		columns := append([]*TranscodedColumn(nil), r.TableResults[name[0]][name[1]].TranscodedColumns...)
+		sort.Slice(columns, func(i, j int) bool { return columns[i].Column < columns[j].Column })
+		for _, column := range columns {
+			chars := make([]string, 0, len(column.UnmappableChars))
+			for _, char := range column.UnmappableChars {
+				chars = append(chars, strconv.Quote(char))
+			}
+			rows = append(rows, []string{fmt.Sprintf("%s.%s", dbutil.TableName(name[0], name[1]), dbutil.ColumnName(column.Column)),
+				column.From, column.To, strconv.FormatInt(column.UnmappableValues, 10), strings.Join(chars, ", ")})
+		}
+	}
+	return rows
+}
+
+// getUnmappableValues returns the number of the source values which have the characters un-mappable by charset-map.
+func (r *Report) getUnmappableValues() int64 {
+	values := int64(0)
+	for _, tableResults := range r.TableResults {
+		for _, result := range tableResults {
+			for _, column := range result.TranscodedColumns {
+				values += column.UnmappableValues
+			}
+		}
+	}
+	return values
+}
+
+// getIndexMismatchRows returns the indices inconsistent found by check-index like
+// ["`schema`.`table`", "idx", "2", "ADMIN CHECK INDEX `schema`.`table` `idx`"], sorted by the table and index name.
+func (r *Report) getIndexMismatchRows() [][]string {
+	rows := make([][]string, 0)
+	for _, name := range r.getSortedSchemaTables() {
+		result := r.TableResults[name[0]][name[1]]
+		indices := make([]string, 0, len(result.IndexResults))
+		for index, indexResult := range result.IndexResults {
+			if len(indexResult.MismatchChunks) > 0 {
+				indices = append(indices, index)
+			}
+		}
+		sort.Strings(indices)
+		for _, index := range indices {
+			tableName := dbutil.TableName(name[0], name[1])
+			rows = append(rows, []string{tableName, index, strconv.Itoa(len(result.IndexResults[index].MismatchChunks)),
+				fmt.Sprintf("ADMIN CHECK INDEX %s %s", tableName, dbutil.QuoteName(index))})
+		}
+	}
+	return rows
+}
+
+// getStructChanges returns the tables with the struct changes sorted by the table name, each of which is
+// the table name followed by the changes indented in lines.
+func (r *Report) getStructChanges() []string {
+	tables := make([]string, 0)
+	for _, name := range r.getSortedSchemaTables() {
+		result := r.TableResults[name[0]][name[1]]
+		if len(result.StructChanges) == 0 {
+			continue
+		}
+		var b strings.Builder
+		b.WriteString(dbutil.TableName(name[0], name[1]))
+		for _, change := range result.StructChanges {
+			b.WriteString("\n  " + change.String())
+		}
+		tables = append(tables, b.String())
+	}
+	return tables
+}
+
+// getStructFixRows returns the numbers of the statements and the destructive ones of generate-struct-fix of each
+// table sorted by the table name.
+func (r *Report) getStructFixRows() [][]string {
+	fixes := r.getStructFixes()
+	rows := make([][]string, 0, len(fixes))
+	for _, fix := range fixes {
+		destructive := 0
+		for _, statement := range fix.Statements {
+			if len(statement.Warning) > 0 {
+				destructive++
+			}
+		}
+		rows = append(rows, []string{dbutil.TableName(fix.Schema, fix.Table), strconv.Itoa(len(fix.Statements)), strconv.Itoa(destructive)})
+	}
+	return rows
+}
+
+// getStructSampledTables returns the tables whose structures are only checked on a sample of the shards
+// with the numbers of the shards, sorted by the table name.
+func (r *Report) getStructSampledTables() []string {
+	tables := make([]string, 0)
+	for _, name := range r.getSortedSchemaTables() {
+		result := r.TableResults[name[0]][name[1]]
+		if result.StructCheckedShards > 0 {
+			tables = append(tables, fmt.Sprintf("%s %d/%d shards", dbutil.TableName(name[0], name[1]), result.StructCheckedShards, result.Shards))
+		}
+	}
+	return tables
+}
+
+// getSkippedTables returns the sorted tables whose data check is skipped with a reason, and the reasons.
+func (r *Report) getSkippedTables() (tables []string, reasons []string) {
+	for _, name := range r.getSortedSchemaTables() {
+		schema, table := name[0], name[1]
+		result := r.TableResults[schema][table]
+		if result.DataSkip && len(result.SkipReason) > 0 {
+			tables = append(tables, dbutil.TableName(schema, table))
+			reasons = append(reasons, result.SkipReason)
+		}
+	}
+	return tables, reasons
+}
+
+// getSortedSchemaTables returns the schema and table names of the results, sorted by schema then table.
+func (r *Report) getSortedSchemaTables() [][2]string {
+	names := make([][2]string, 0)
+	for schema, tableMap := range r.TableResults {
+		for table := range tableMap {
+			names = append(names, [2]string{schema, table})
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if names[i][0] != names[j][0] {
+			return names[i][0] < names[j][0]
+		}
+		return names[i][1] < names[j][1]
+	})
+	return names
+}
+
+// getDiffRows returns the diff rows of the unequal tables, sorted by schema then table.
+func (r *Report) getDiffRows() [][]string {
+	return r.getObjectDiffRows(false)
+}
+
+// getViewDiffRows returns the diff rows of the unequal views compared by check-view-data, sorted by schema then view.
+func (r *Report) getViewDiffRows() [][]string {
+	return r.getObjectDiffRows(true)
+}
+
+// getObjectDiffRows returns the diff rows of the unequal views if views is true, otherwise the unequal tables.
+func (r *Report) getObjectDiffRows(views bool) [][]string {
+	diffRows := make([][]string, 0)
+	for _, name := range r.getSortedSchemaTables() {
+		schema, table := name[0], name[1]
+		result := r.TableResults[schema][table]
+		// the data of the tables failed only by the indices is equal.
+		if result.StructEqual && result.DataEqual || result.IsView != views {
+			continue
+		}
+		diffRow := make([]string, 0)
+		diffRow = append(diffRow, dbutil.TableName(schema, table))
+		if !result.StructEqual {
+			diffRow = append(diffRow, "false")
+		} else {
+			diffRow = append(diffRow, "true")
+		}
+		rowAdd, rowDelete := 0, 0
+		for _, chunkResult := range result.ChunkMap {
+			rowAdd += chunkResult.RowsAdd
+			rowDelete += chunkResult.RowsDelete
+		}
+		switch {
+		case result.CountOnly:
+			// the rows are estimated by the count delta.
+			diffRow = append(diffRow, fmt.Sprintf("+%s/-%s (by count)", r.formatCount(int64(rowAdd)), r.formatCount(int64(rowDelete))))
+		case result.DiffLimitExceeded:
+			// the rows are counted by the chunks compared before the comparison is truncated.
+			diffRow = append(diffRow, fmt.Sprintf("+%s/-%s (truncated)", r.formatCount(int64(rowAdd)), r.formatCount(int64(rowDelete))))
+		default:
+			diffRow = append(diffRow, fmt.Sprintf("+%s/-%s", r.formatCount(int64(rowAdd)), r.formatCount(int64(rowDelete))))
+		}
+		if r.trend != nil && !views {
+			diffRow = append(diffRow, r.trend.find(schema, table).Trend())
+		}
+		diffRows = append(diffRows, diffRow)
+	}
+	return diffRows
+}
+
+// erroredTables are the tables meeting the errors of a category.
+type erroredTables struct {
+	category string
+	tables   []*TableResult
+}
+
+// getErroredTablesByCategory returns the tables meeting errors grouped by `ErrorCategory` in the order of
+// `errorCategories`, and the tables of each category are sorted by the table name.
+func (r *Report) getErroredTablesByCategory() []*erroredTables {
+	tablesByCategory := make(map[string][]*TableResult)
+	for _, name := range r.getSortedSchemaTables() {
+		result := r.TableResults[name[0]][name[1]]
+		if result.MeetError == nil {
+			continue
+		}
+		category := result.ErrorCategory
+		if len(category) == 0 {
+			category = ErrorCategoryOther
+		}
+		tablesByCategory[category] = append(tablesByCategory[category], result)
+	}
+	groups := make([]*erroredTables, 0, len(tablesByCategory))
+	for _, category := range errorCategories {
+		if tables, ok := tablesByCategory[category]; ok {
+			groups = append(groups, &erroredTables{category: category, tables: tables})
+		}
+	}
+	return groups
+}
+
+// getViewDataStatus returns the status of the data of the view compared by check-view-data, "equal", "unequal",
+// "skipped" or "error", which is empty if the data of the view isn't compared.
+func (r *Report) getViewDataStatus(schema, view string) string {
+	result, ok := r.TableResults[schema][view]
+	switch {
+	case !ok || !result.IsView:
+		return ""
+	case result.MeetError != nil:
+		return "error"
+	case result.DataSkip:
+		return "skipped"
+	case !result.StructEqual || !result.DataEqual:
+		return "unequal"
+	}
+	return "equal"
+}
+
+// getFixSQLBytes returns the bytes of the fix sql files written of each table, sorted by the table name, with the
+// fix sql file of each table if fix-file-layout is "table", which is indicated by the returned bool.
+func (r *Report) getFixSQLBytes() ([][]string, bool) {
+	tables := make([]string, 0)
+	fixSQLBytes := make(map[string]int64)
+	fixSQLFiles := make(map[string]string)
+	for schema, tableMap := range r.TableResults {
+		for table, result := range tableMap {
+			bytes := int64(0)
+			for _, chunkResult := range result.ChunkMap {
+				bytes += chunkResult.FixSQLBytes
+			}
+			if bytes == 0 {
+				continue
+			}
+			tableName := dbutil.TableName(schema, table)
+			tables = append(tables, tableName)
+			fixSQLBytes[tableName] = bytes
+			if len(result.FixSQLFile) > 0 {
+				fixSQLFiles[tableName] = result.FixSQLFile
+			}
+		}
+	}
+	sort.Strings(tables)
+	hasFiles := len(fixSQLFiles) > 0
+	rows := make([][]string, 0, len(tables))
+	for _, table := range tables {
+		row := []string{table, r.formatBytes(fixSQLBytes[table])}
+		if hasFiles {
+			row = append(row, fixSQLFiles[table])
+		}
+		rows = append(rows, row)
+	}
+	return rows, hasFiles
+}
+
+// getExportedDiffRows returns the rows exported and the CSV file of each table, sorted by the table name.
+func (r *Report) getExportedDiffRows() [][]string {
+	rows := make([][]string, 0)
+	for _, schemaTable := range r.getSortedSchemaTables() {
+		result := r.TableResults[schemaTable[0]][schemaTable[1]]
+		if len(result.DiffRowsFile) == 0 {
+			continue
+		}
+		rows = append(rows, []string{dbutil.TableName(schemaTable[0], schemaTable[1]), strconv.FormatInt(result.DiffRowsExported, 10), result.DiffRowsFile})
+	}
+	return rows
+}
+
+// getRowDiffsRows returns the rows exported and suppressed by export-row-diffs and the number of the files of each
+// table, sorted by the table name.
+func (r *Report) getRowDiffsRows() [][]string {
+	rows := make([][]string, 0)
+	for _, schemaTable := range r.getSortedSchemaTables() {
+		result := r.TableResults[schemaTable[0]][schemaTable[1]]
+		if result.RowDiffsExported == 0 && result.RowDiffsSuppressed == 0 {
+			continue
+		}
+		files := 0
+		for _, chunkResult := range result.ChunkMap {
+			if len(chunkResult.RowDiffsFile) > 0 {
+				files++
+			}
+		}
+		rows = append(rows, []string{dbutil.TableName(schemaTable[0], schemaTable[1]), strconv.FormatInt(result.RowDiffsExported, 10),
+			strconv.FormatInt(result.RowDiffsSuppressed, 10), strconv.Itoa(files)})
+	}
+	return rows
+}
+
+// getPartitionDiffRows returns the rows add and rows delete of each partition of the tables split by partition,
+// sorted by the table name then the partition name.
+func (r *Report) getPartitionDiffRows() [][]string {
+	type partitionDiff struct {
+		rowsAdd, rowsDelete int
+	}
+	names := make([]string, 0)
+	diffs := make(map[string]*partitionDiff)
+	for schema, tableMap := range r.TableResults {
+		for table, result := range tableMap {
+			for _, chunkResult := range result.ChunkMap {
+				if len(chunkResult.Partition) == 0 {
+					continue
+				}
+				name := fmt.Sprintf("%s PARTITION %s", dbutil.TableName(schema, table), dbutil.QuoteName(chunkResult.Partition))
+				if _, ok := diffs[name]; !ok {
+					names = append(names, name)
+					diffs[name] = &partitionDiff{}
+				}
+				diffs[name].rowsAdd += chunkResult.RowsAdd
+				diffs[name].rowsDelete += chunkResult.RowsDelete
+			}
+		}
+	}
+	sort.Strings(names)
+	rows := make([][]string, 0, len(names))
+	for _, name := range names {
+		rows = append(rows, []string{name, fmt.Sprintf("+%d/-%d", diffs[name].rowsAdd, diffs[name].rowsDelete)})
+	}
+	return rows
+}
+
+// CalculateTotalSize calculate the total size of all the checked tables
+// Notice, user should run the analyze table first, when some of tables' size are zero.
+// The size is only used for the speed, so the tables whose sizes fail to get are counted in `SizeUnavailableTables`
+// rather than errored.
+func (r *Report) CalculateTotalSize(ctx context.Context, sizes *utils.TableSizeCache) {
+	totalSize, unavailable := r.sumTableSizes(ctx, sizes)
+	r.TotalSize += totalSize
+	r.SizeUnavailableTables = unavailable
+}
+
+// EstimateTotalSize returns the total size of all the tables to check like `CalculateTotalSize`, which is
+// estimated before the comparison, so the report isn't changed and the errors are only logged.
+func (r *Report) EstimateTotalSize(ctx context.Context, sizes *utils.TableSizeCache) int64 {
+	totalSize, _ := r.sumTableSizes(ctx, sizes)
+	return totalSize
+}
+
+// sumTableSizes returns the sum of the sizes of all the tables, and the number of the tables whose sizes fail to get.
+// The sizes of the tables in a schema are got together by the cache.
+func (r *Report) sumTableSizes(ctx context.Context, sizes *utils.TableSizeCache) (int64, int) {
+	for schema, tableMap := range r.TableResults {
+		for table := range tableMap {
+			sizes.Register(schema, table)
+		}
+	}
+	var totalSize int64
+	unavailable := 0
+	for schema, tableMap := range r.TableResults {
+		for table := range tableMap {
+			size, err := sizes.GetTableSize(ctx, schema, table)
+			if err != nil {
+				log.Warn("fail to get the size of table", zap.String("table", dbutil.TableName(schema, table)), zap.Error(err))
+				unavailable++
+				continue
+			}
+			if size == 0 {
+				log.Warn("fail to get the correct size of table, if you want to get the correct size, please analyze the corresponding tables", zap.String("table", dbutil.TableName(schema, table)))
+			} else {
+				totalSize += size
+			}
+		}
+	}
+	return totalSize, unavailable
+}
+
+// PrintConfig prints the databases, the number of the tables and their estimated total size to check, so that
+// the config can be confirmed before the comparison. The passwords are not printed like in the summary.
+func (r *Report) PrintConfig(w io.Writer, totalSize int64) {
+	var b strings.Builder
+	b.WriteString("Source Database\n\n")
+	for _, sourceConfig := range r.SourceConfig {
+		b.Write(sourceConfig)
+		b.WriteString("\n")
+	}
+	b.WriteString("Target Database\n\n")
+	b.Write(r.TargetConfig)
+	b.WriteString("\n")
+	if len(r.ConfigOverrides) > 0 {
+		b.WriteString("Config Overrides\n\n")
+		for _, override := range r.ConfigOverrides {
+			b.WriteString(override + "\n")
+		}
+		b.WriteString("\n")
+	}
+	tableNum := 0
+	for _, tableMap := range r.TableResults {
+		tableNum += len(tableMap)
+	}
+	b.WriteString(fmt.Sprintf("A total of %d tables will be compared, the estimated total size is %s\n", tableNum, r.formatBytes(totalSize)))
+	b.WriteString(fmt.Sprintf("The output dir is '%s'\n", r.task.OutputDir))
+	b.WriteString(fmt.Sprintf("The fix sql is written to '%s', and the checkpoint is saved in '%s'\n", r.task.FixDir, r.task.CheckpointDir))
+	fmt.Fprint(w, b.String())
+}
+
+// TotalDuration returns the time of all the runs so far, i.e. `ElapsedBeforeResume` plus the time since this run
+// started. `StartTime` and `ElapsedBeforeResume` are only set before comparing, so the lock isn't required.
+func (r *Report) TotalDuration() time.Duration {
+	return r.ElapsedBeforeResume + nowFunc().Sub(r.StartTime)
+}
+
+// NewReport returns a new Report.
+func NewReport(task *config.TaskConfig) *Report {
+	return &Report{
+		TableResults: make(map[string]map[string]*TableResult),
+		Result:       Pass,
+		task:         task,
+		sink:         NewFileSink(task.OutputDir),
+	}
+}
+
+// SetMaxDiffRows sets the limit of the number of diff rows, 0 means no limit.
+func (r *Report) SetMaxDiffRows(maxDiffRows int64) {
+	r.maxDiffRows = maxDiffRows
+}
+
+// SetMaxDiffRowsPerTable sets the limit of the number of diff rows of each table, 0 means no limit.
+func (r *Report) SetMaxDiffRowsPerTable(maxDiffRows int64) {
+	r.maxDiffRowsPerTable = maxDiffRows
+}
+
+// SetFailFast sets whether to stop the comparison by fail-fast once a table is found different by the struct
+// or the data check, see IsFailedFast.
+func (r *Report) SetFailFast(failFast bool) {
+	r.failFast = failFast
+}
+
+// markTableFailed stops the comparison by fail-fast when the first table is found different,
+// the caller should hold the lock.
+func (r *Report) markTableFailed(schema, table string) {
+	if !r.failFast || r.FailedFast {
+		return
+	}
+	log.Warn("the table is different, stop the comparison by fail-fast", zap.String("table", dbutil.TableName(schema, table)))
+	r.FailedFast = true
+	r.FailedFastTable = dbutil.TableName(schema, table)
+}
+
+// SetRowsEstimateWarnFactor sets the factor of the divergence between the estimated and the actual rows
+// to warn about the stale statistics, 0 means no warning.
+func (r *Report) SetRowsEstimateWarnFactor(factor float64) {
+	r.rowsEstimateWarnFactor = factor
+}
+
+// SetRawUnits writes the sizes in the summary in bytes for machine consumption rather than humanized.
+func (r *Report) SetRawUnits(rawUnits bool) {
+	r.rawUnits = rawUnits
+}
+
+// formatBytes formats the size in bytes as is with raw-units, or humanized like "1.5GB".
+func (r *Report) formatBytes(size int64) string {
+	if r.rawUnits {
+		return strconv.FormatInt(size, 10) + "B"
+	}
+	return utils.HumanizeBytes(size)
+}
+
+// formatCount formats the row count as is with raw-units, or humanized like "1,234,567".
+func (r *Report) formatCount(count int64) string {
+	if r.rawUnits {
+		return strconv.FormatInt(count, 10)
+	}
+	return utils.HumanizeCount(count)
+}
+
+// IsAborted returns true if the number of diff rows exceeds the limit.
+func (r *Report) IsAborted() bool {
+	r.RLock()
+	defer r.RUnlock()
+	return r.Aborted
+}
+
+// SetInterrupted marks the comparison is interrupted by canceling. The comparison stopped by fail-fast is not
+// taken as interrupted, whose verdict is already fail.
+func (r *Report) SetInterrupted() {
+	r.Lock()
+	defer r.Unlock()
+	if r.FailedFast {
+		return
+	}
+	r.Interrupted = true
+}
+
+// SetTimedOut marks the comparison is interrupted by exceeding the run timeout.
+func (r *Report) SetTimedOut(runTimeout time.Duration) {
+	r.Lock()
+	defer r.Unlock()
+	r.TimedOut = true
+	r.RunTimeout = runTimeout
+}
+
+// IsFailedFast returns true if the comparison is stopped by fail-fast, then no more tables or chunks should be
+// compared, and the ones being compared are finished.
+func (r *Report) IsFailedFast() bool {
+	r.RLock()
+	defer r.RUnlock()
+	return r.FailedFast
+}
+
+// IsInterrupted returns true if the comparison is interrupted by canceling.
+func (r *Report) IsInterrupted() bool {
+	r.RLock()
+	defer r.RUnlock()
+	return r.Interrupted
+}
+
+// SetServerVersions sets the versions of the source and target database servers.
+func (r *Report) SetServerVersions(sourceVersions []*ServerVersion, targetVersion *ServerVersion) {
+	r.Lock()
+	defer r.Unlock()
+	r.SourceVersions = sourceVersions
+	r.TargetVersion = targetVersion
+}
+
+// SetConnPoolStats sets the statistics of the connection pools of the sources and the target.
+func (r *Report) SetConnPoolStats(stats []*ConnPoolStats) {
+	r.Lock()
+	defer r.Unlock()
+	r.ConnPoolStats = stats
+}
+
+// SetConfigOverrides sets the config values overridden, with the values of the redacted keys masked.
+func (r *Report) SetConfigOverrides(overrides []string) {
+	r.Lock()
+	defer r.Unlock()
+	r.ConfigOverrides = overrides
+}
+
+// AddRecheckedChunk counts the failed chunk rechecked, which is transient if it becomes equal on the `attempt`th
+// recheck, or confirmed if it's still different after the last recheck.
+func (r *Report) AddRecheckedChunk(attempt int, transient bool) {
+	r.Lock()
+	defer r.Unlock()
+	if !transient {
+		r.ConfirmedChunks++
+		return
+	}
+	r.TransientChunks++
+	for len(r.HealedOnRecheck) < attempt {
+		r.HealedOnRecheck = append(r.HealedOnRecheck, 0)
+	}
+	r.HealedOnRecheck[attempt-1]++
+}
+
+// SetReplicationPositions sets the replication positions observed by wait-sync, and whether they catch up.
+func (r *Report) SetReplicationPositions(positions []*utils.ReplicationPosition, caughtUp bool) {
+	r.Lock()
+	defer r.Unlock()
+	r.ReplicationPositions = positions
+	r.ReplicationLag = !caughtUp
+}
+
+// replicationLagString returns the warning of the tables skipped for the replication lag.
+func (r *Report) replicationLagString() string {
+	skipped := 0
+	for _, tableMap := range r.TableResults {
+		for _, result := range tableMap {
+			if result.DataSkip && result.SkipReason == ReplicationLagSkipReason {
+				skipped++
+			}
+		}
+	}
+	return fmt.Sprintf("WARNING: the replication doesn't catch up within the timeout of wait-sync, the data check of %d tables is skipped for the replication lag", skipped)
+}
+
+// getReplicationPositionRows returns the replication positions like ["mysql-replica-01", "(mysql-bin.000001, 2022)",
+// "(mysql-bin.000001, 1024)", "false"].
+func (r *Report) getReplicationPositionRows() [][]string {
+	rows := make([][]string, 0, len(r.ReplicationPositions))
+	for _, position := range r.ReplicationPositions {
+		rows = append(rows, []string{position.Source, position.Goal, position.Checkpoint, strconv.FormatBool(position.CaughtUp)})
+	}
+	return rows
+}
+
+// SetSampling sets the sample-rate and the seed of a sampled run.
+func (r *Report) SetSampling(rate float64, seed int64) {
+	r.Lock()
+	defer r.Unlock()
+	r.SampleRate = rate
+	r.SampleSeed = seed
+}
+
+// AddSampledChunk counts a chunk of the table in a sampled run, which is compared if sampled, and differed means
+// the data of the chunk compared is different.
+func (r *Report) AddSampledChunk(schema, table string, sampled, differed bool) {
+	r.Lock()
+	defer r.Unlock()
+	r.markDirty(schema, table, nil)
+	tableResult := r.getTableResult(schema, table)
+	tableResult.ChunksTotal++
+	if sampled {
+		tableResult.ChunksSampled++
+	}
+	if differed {
+		tableResult.ChunksDiffered++
+	}
+}
+
+// isSampled returns whether the chunks are sampled by sample-rate.
+func (r *Report) isSampled() bool {
+	return r.SampleRate > 0 && r.SampleRate < 1
+}
+
+// sampleString returns the warning of a sampled run, whose result isn't a full verification.
+func (r *Report) sampleString() string {
+	var total, sampled, differed int
+	for _, tableMap := range r.TableResults {
+		for _, result := range tableMap {
+			total += result.ChunksTotal
+			sampled += result.ChunksSampled
+			differed += result.ChunksDiffered
+		}
+	}
+	return fmt.Sprintf("WARNING: this is a sampled run by sample-rate(%g) and sample-seed(%d), only %d of %d chunks are compared and %d of them are different, it's NOT a full verification",
+		r.SampleRate, r.SampleSeed, sampled, total, differed)
+}
+
+// getSampleRows returns the chunks of the tables in a sampled run like ["`schema`.`table`", "10", "5", "1"],
+// sorted by the table name.
+func (r *Report) getSampleRows() [][]string {
+	rows := make([][]string, 0)
+	for _, name := range r.getSortedSchemaTables() {
+		result := r.TableResults[name[0]][name[1]]
+		if result.ChunksTotal == 0 {
+			continue
+		}
+		rows = append(rows, []string{dbutil.TableName(name[0], name[1]), strconv.Itoa(result.ChunksTotal),
+			strconv.Itoa(result.ChunksSampled), strconv.Itoa(result.ChunksDiffered)})
+	}
+	return rows
+}
+
+// countTables returns the numbers of the tables passed, failed and errored by the current results, the errors take
+// precedence over the differences.
+func (r *Report) countTables() (passNum, failedNum, errorNum int32) {
+	for _, tableMap := range r.TableResults {
+		for _, result := range tableMap {
+			switch result.getResult(r.CheckStructOnly) {
+			case Pass:
+				passNum++
+			case Fail:
+				failedNum++
+			default:
+				errorNum++
+			}
+		}
+	}
+	return passNum, failedNum, errorNum
+}
+
+// countString returns the numbers of the tables passed, failed and errored, which are counted from the results, so
+// it's right even if the summary isn't committed yet.
+func (r *Report) countString() string {
+	passNum, failedNum, errorNum := r.countTables()
+	return fmt.Sprintf("%d succeeded, %d mismatched, %d errored", passNum, failedNum, errorNum)
+}
+
+func (r *Report) recheckString() string {
+	recheck := fmt.Sprintf("%d failed chunks are rechecked, %d are confirmed different and %d are transient",
+		r.ConfirmedChunks+r.TransientChunks, r.ConfirmedChunks, r.TransientChunks)
+	if len(r.HealedOnRecheck) > 1 {
+		healed := make([]string, 0, len(r.HealedOnRecheck))
+		for _, n := range r.HealedOnRecheck {
+			healed = append(healed, strconv.FormatInt(n, 10))
+		}
+		recheck += fmt.Sprintf(" (%s become equal on each recheck)", strings.Join(healed, ", "))
+	}
+	return recheck
+}
+
+// SetSink replaces the sink where the summary is written to.
+func (r *Report) SetSink(sink ReportSink) {
+	r.sink = sink
+}
+
+func (r *Report) Init(tableDiffs []*common.TableDiff, sourceConfig [][]byte, targetConfig []byte) {
+	r.StartTime = nowFunc()
+	r.SourceConfig = sourceConfig
+	r.TargetConfig = targetConfig
+	for _, tableDiff := range tableDiffs {
+		schema, table := tableDiff.Schema, tableDiff.Table
+		if _, ok := r.TableResults[schema]; !ok {
+			r.TableResults[schema] = make(map[string]*TableResult)
+		}
+		result := newTableResult(schema, table)
+		result.NoPKFallback = tableDiff.NoPKFallback
+		if tableDiff.HasRange() {
+			result.Range = tableDiff.Range
+		}
+		result.ColumnTransforms = tableDiff.ColumnTransforms
+		result.TrimmedColumns = tableDiff.TrimmedColumns
+		result.GeometryColumns = tableDiff.GeometryColumns
+		result.IsView = tableDiff.IsView
+		if len(tableDiff.CheckIndices) > 0 {
+			result.IndexResults = make(map[string]*IndexResult, len(tableDiff.CheckIndices))
+			for _, index := range tableDiff.CheckIndices {
+				result.IndexResults[index.Name.O] = &IndexResult{}
+			}
+		}
+		r.TableResults[schema][table] = result
+	}
+}
+
+// newTableResult returns the result of the table which is equal before it's checked.
+func newTableResult(schema, table string) *TableResult {
+	return &TableResult{
+		Schema:      schema,
+		Table:       table,
+		StructEqual: true,
+		DataEqual:   true,
+		MeetError:   nil,
+		ChunkMap:    make(map[string]*ChunkResult),
+	}
+}
+
+// getTableResult returns the result of the table, which is created if the table isn't registered by `Init`,
+// e.g. the table is found after the report is initialized. The caller should hold the write lock.
+func (r *Report) getTableResult(schema, table string) *TableResult {
+	if _, ok := r.TableResults[schema]; !ok {
+		r.TableResults[schema] = make(map[string]*TableResult)
+	}
+	result, ok := r.TableResults[schema][table]
+	if !ok {
+		result = newTableResult(schema, table)
+		r.TableResults[schema][table] = result
+	}
+	return result
+}
+
+// SetTableStructCheckResult sets the struct check result for table.
+func (r *Report) SetTableStructCheckResult(schema, table string, equal bool, skip bool) {
+	r.Lock()
+	defer r.Unlock()
+	r.markDirty(schema, table, nil)
+	tableResult := r.getTableResult(schema, table)
+	tableResult.StructEqual = equal
+	tableResult.DataSkip = skip
+	if !equal {
+		if r.Result != Error {
+			r.Result = Fail
+		}
+		r.markTableFailed(schema, table)
+	}
+}
+
+// SetTableSkipReason sets the reason why the data check of table is skipped.
+func (r *Report) SetTableSkipReason(schema, table string, reason string) {
+	r.Lock()
+	defer r.Unlock()
+	r.markDirty(schema, table, nil)
+	r.getTableResult(schema, table).SkipReason = reason
+}
+
+// SetTableDataSkip skips the data check of table for the reason after the struct check.
+func (r *Report) SetTableDataSkip(schema, table string, reason string) {
+	r.Lock()
+	defer r.Unlock()
+	r.markDirty(schema, table, nil)
+	tableResult := r.getTableResult(schema, table)
+	tableResult.DataSkip = true
+	tableResult.SkipReason = reason
+}
+
+// SetTableStructCheckedShards sets the number of the shards whose structures are checked in the shards of the table.
+func (r *Report) SetTableStructCheckedShards(schema, table string, checked, shards int) {
+	r.Lock()
+	defer r.Unlock()
+	r.markDirty(schema, table, nil)
+	result := r.getTableResult(schema, table)
+	result.StructCheckedShards = checked
+	result.Shards = shards
+}
+
+// SetTableColumnsReordered marks the columns of table are reordered to match the source by name.
+func (r *Report) SetTableColumnsReordered(schema, table string) {
+	r.Lock()
+	defer r.Unlock()
+	r.markDirty(schema, table, nil)
+	r.getTableResult(schema, table).ColumnsReordered = true
+}
+
+// SetTableReorderedEnumColumns sets the ENUM and SET columns of table compared by value.
+func (r *Report) SetTableReorderedEnumColumns(schema, table string, columns []string) {
+	r.Lock()
+	defer r.Unlock()
+	r.markDirty(schema, table, nil)
+	r.getTableResult(schema, table).ReorderedEnumColumns = columns
+}
+
+// AddTableSRIDMismatchColumns adds the GEOMETRY columns of table whose values have the same WKB but
+// the different SRIDs, the columns added before are ignored.
+func (r *Report) AddTableSRIDMismatchColumns(schema, table string, columns []string) {
+	r.Lock()
+	defer r.Unlock()
+	r.markDirty(schema, table, nil)
+	result := r.getTableResult(schema, table)
+	for _, column := range columns {
+		found := false
+		for _, c := range result.SRIDMismatchColumns {
+			if c == column {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result.SRIDMismatchColumns = append(result.SRIDMismatchColumns, column)
+		}
+	}
+}
+
+// SetTableTranscodedColumns sets the columns of table whose source values are transcoded by charset-map, the
+// un-mappable characters counted before are kept for the same columns, e.g. the ones resumed from the checkpoint.
+func (r *Report) SetTableTranscodedColumns(schema, table string, columns []*TranscodedColumn) {
+	r.Lock()
+	defer r.Unlock()
+	r.markDirty(schema, table, nil)
+	result := r.getTableResult(schema, table)
+	for _, column := range columns {
+		for _, c := range result.TranscodedColumns {
+			if c.Column == column.Column && c.From == column.From && c.To == column.To {
+				column.UnmappableValues = c.UnmappableValues
+				column.UnmappableChars = c.UnmappableChars
+				break
+			}
+		}
+	}
+	result.TranscodedColumns = columns
+}
+
+// AddTableUnmappableChars counts a source value of the transcoded column of table which has the characters chars
+// that can't be mapped to the charset of the target.
+func (r *Report) AddTableUnmappableChars(schema, table, column string, chars []string) {
+	r.Lock()
+	defer r.Unlock()
+	r.markDirty(schema, table, nil)
+	for _, c := range r.getTableResult(schema, table).TranscodedColumns {
+		if c.Column != column {
+			continue
+		}
+		c.UnmappableValues++
+		for _, char := range chars {
+			if len(c.UnmappableChars) >= maxUnmappableChars {
+				break
+			}
+			found := false
+			for _, unmappable := range c.UnmappableChars {
+				if unmappable == char {
+					found = true
+					break
+				}
+			}
+			if !found {
+				c.UnmappableChars = append(c.UnmappableChars, char)
+			}
+		}
+		return
+	}
+}
+
+// SetTableDuplicateKeys sets the duplicate values of the unique key of table on both sides found by check-pk-uniqueness.
+func (r *Report) SetTableDuplicateKeys(schema, table string, columns []string, sourceKeys, targetKeys []*utils.DuplicateKey) {
+	r.Lock()
+	defer r.Unlock()
+	r.markDirty(schema, table, nil)
+	result := r.getTableResult(schema, table)
+	result.DuplicateKeyColumns = columns
+	result.SourceDuplicateKeys = sourceKeys
+	result.TargetDuplicateKeys = targetKeys
+}
+
+// AddTableIndexMismatch adds the chunk whose checksums of the index are different on both sides found by check-index.
+func (r *Report) AddTableIndexMismatch(schema, table string, index string, id *chunk.ChunkID) {
+	r.Lock()
+	defer r.Unlock()
+	r.markDirty(schema, table, nil)
+	result := r.getTableResult(schema, table)
+	if result.IndexResults == nil {
+		result.IndexResults = make(map[string]*IndexResult)
+	}
+	indexResult, ok := result.IndexResults[index]
+	if !ok {
+		indexResult = &IndexResult{}
+		result.IndexResults[index] = indexResult
+	}
+	// the chunk is compared again after resuming if it's after the checkpoint.
+	chunkID := id.Encode()
+	for _, c := range indexResult.MismatchChunks {
+		if c == chunkID {
+			return
+		}
+	}
+	indexResult.MismatchChunks = append(indexResult.MismatchChunks, chunkID)
+	if r.Result != Error {
+		r.Result = Fail
+	}
+}
+
+// SetCheckStructOnly marks only the table structures are compared.
+func (r *Report) SetCheckStructOnly() {
+	r.Lock()
+	defer r.Unlock()
+	r.CheckStructOnly = true
+}
+
+// SetFailOnMissingTables marks the tables only exist on one side cause Fail.
+func (r *Report) SetFailOnMissingTables() {
+	r.Lock()
+	defer r.Unlock()
+	r.FailOnMissingTables = true
+}
+
+// AddMissingTable adds the table only exists on one side, the tables should be added in the order of the names.
+func (r *Report) AddMissingTable(schema, table string, missingOn string) {
+	r.Lock()
+	defer r.Unlock()
+	r.MissingTables = append(r.MissingTables, &MissingTable{Schema: schema, Table: table, MissingOn: missingOn})
+	if r.FailOnMissingTables && r.Result != Error {
+		r.Result = Fail
+	}
+}
+
+// AddSkippedObject adds the object which can't be compared, the objects should be added in the order of the names.
+func (r *Report) AddSkippedObject(object *SkippedObject) {
+	r.Lock()
+	defer r.Unlock()
+	r.SkippedObjects = append(r.SkippedObjects, object)
+}
+
+// SetCheckViews marks the views unequal or missing on the target cause Fail.
+func (r *Report) SetCheckViews() {
+	r.Lock()
+	defer r.Unlock()
+	r.CheckViews = true
+}
+
+// AddViewResult adds the check result of the view, the views should be added in the order of the names.
+func (r *Report) AddViewResult(schema, view string, status string) {
+	r.Lock()
+	defer r.Unlock()
+	result := &ViewResult{Schema: schema, View: view, Status: status}
+	r.ViewResults = append(r.ViewResults, result)
+	if r.isViewFailed(result) && r.Result != Error {
+		r.Result = Fail
+	}
+}
+
+// AddQueryPairResult adds the check result of the query pair, the pairs should be added in the order of the labels.
+func (r *Report) AddQueryPairResult(result *QueryPairResult) {
+	r.Lock()
+	defer r.Unlock()
+	r.QueryPairResults = append(r.QueryPairResults, result)
+	if len(result.ErrorMessage) > 0 {
+		r.Result = Error
+	} else if !result.Equal && r.Result != Error {
+		r.Result = Fail
+	}
+}
+
+// AddQueryPairError adds the error met when comparing the query pair labeled label.
+func (r *Report) AddQueryPairError(label string, err error) {
+	r.AddQueryPairResult(&QueryPairResult{
+		Label:         label,
+		ErrorMessage:  err.Error(),
+		ErrorCategory: ClassifyError(err),
+	})
+}
+
+// getQueryPairRows returns the rows of the results of query-pairs in summary.txt.
+func (r *Report) getQueryPairRows() [][]string {
+	rows := make([][]string, 0, len(r.QueryPairResults))
+	for _, result := range r.QueryPairResults {
+		status, diffRows := "equal", fmt.Sprintf("+%d/-%d", result.RowsAdd, result.RowsDelete)
+		switch {
+		case len(result.ErrorMessage) > 0:
+			status, diffRows = result.ErrorCategory+" error", "-"
+		case !result.Equal:
+			status = "unequal"
+		}
+		rows = append(rows, []string{result.Label, status, strconv.FormatInt(result.SourceRows, 10), strconv.FormatInt(result.TargetRows, 10), diffRows})
+	}
+	return rows
+}
+
+// isViewFailed returns whether the result of the view causes Fail.
+func (r *Report) isViewFailed(result *ViewResult) bool {
+	return r.CheckViews && (result.Status == ViewUnequal || result.Status == ViewMissingOnTarget)
+}
+
+// SetTableSchemaDiff sets the unified diff of the table structures for table.
+func (r *Report) SetTableSchemaDiff(schema, table string, schemaDiff string) {
+	r.Lock()
+	defer r.Unlock()
+	r.markDirty(schema, table, nil)
+	r.getTableResult(schema, table).SchemaDiff = schemaDiff
+}
+
+// SetTableStructChanges sets the differences of the columns and the indices of the struct mismatch for table.
+func (r *Report) SetTableStructChanges(schema, table string, changes []*utils.StructChange) {
+	r.Lock()
+	defer r.Unlock()
+	r.markDirty(schema, table, nil)
+	r.getTableResult(schema, table).StructChanges = changes
+}
+
+// SetTableStructFix sets the statements to make the structure of the target match the source for table.
+func (r *Report) SetTableStructFix(schema, table string, statements []*utils.StructFixStatement) {
+	r.Lock()
+	defer r.Unlock()
+	r.markDirty(schema, table, nil)
+	r.getTableResult(schema, table).StructFix = statements
+}
+
+// SetMissingTableStructFix sets the statement to create or drop the missing table on the target.
+func (r *Report) SetMissingTableStructFix(schema, table string, statement *utils.StructFixStatement) {
+	r.Lock()
+	defer r.Unlock()
+	for _, missingTable := range r.MissingTables {
+		if missingTable.Schema == schema && missingTable.Table == table {
+			missingTable.StructFix = statement
+			return
+		}
+	}
+}
+
+// SetStructFixFile sets the file of the statements written by generate-struct-fix.
+func (r *Report) SetStructFixFile(path string) {
+	r.Lock()
+	defer r.Unlock()
+	r.StructFixFile = path
+}
+
+// TableStructFix is the statements to make the structure of a table on the target match the source.
+type TableStructFix struct {
+	Schema     string
+	Table      string
+	Statements []*utils.StructFixStatement
+}
+
+// GetStructFixes returns the statements of generate-struct-fix of the tables and the missing tables sorted by the
+// table name, the tables without statements are omitted.
+func (r *Report) GetStructFixes() []*TableStructFix {
+	r.RLock()
+	defer r.RUnlock()
+	return r.getStructFixes()
+}
+
+func (r *Report) getStructFixes() []*TableStructFix {
+	fixes := make([]*TableStructFix, 0)
+	for _, name := range r.getSortedSchemaTables() {
+		if result := r.TableResults[name[0]][name[1]]; len(result.StructFix) > 0 {
+			fixes = append(fixes, &TableStructFix{Schema: name[0], Table: name[1], Statements: result.StructFix})
+		}
+	}
+	for _, table := range r.MissingTables {
+		if table.StructFix != nil {
+			fixes = append(fixes, &TableStructFix{Schema: table.Schema, Table: table.Table, Statements: []*utils.StructFixStatement{table.StructFix}})
+		}
+	}
+	sort.SliceStable(fixes, func(i, j int) bool {
+		if fixes[i].Schema != fixes[j].Schema {
+			return fixes[i].Schema < fixes[j].Schema
+		}
+		return fixes[i].Table < fixes[j].Table
+	})
+	return fixes
+}
+
+// SetTableCheckPath sets how the data of the table is verified, see `CheckPathAdminChecksum`.
+func (r *Report) SetTableCheckPath(schema, table string, checkPath string) {
+	r.Lock()
+	defer r.Unlock()
+	r.markDirty(schema, table, nil)
+	r.getTableResult(schema, table).CheckPath = checkPath
+}
+
+// AddTableVerificationMethod records how a chunk of the table is verified, and the table keeps the weakest method of
+// its chunks, so that the data equality isn't overstated.
+func (r *Report) AddTableVerificationMethod(schema, table string, method string) {
+	r.Lock()
+	defer r.Unlock()
+	result := r.getTableResult(schema, table)
+	if len(result.VerificationMethod) == 0 || verificationStrength(method) < verificationStrength(result.VerificationMethod) {
+		r.markDirty(schema, table, nil)
+		result.VerificationMethod = method
+	}
+}
+
+// SetTableStructDiff sets the classification of the struct mismatch for table.
+func (r *Report) SetTableStructDiff(schema, table string, structDiff string) {
+	r.Lock()
+	defer r.Unlock()
+	r.markDirty(schema, table, nil)
+	r.getTableResult(schema, table).StructDiff = structDiff
+}
+
+// SetTableDataCheckResult sets the data check result for table.
+func (r *Report) SetTableDataCheckResult(schema, table string, equal bool, rowsAdd, rowsDelete int, id *chunk.ChunkID) {
+	r.Lock()
+	defer r.Unlock()
+	result := r.getTableResult(schema, table)
+	if !equal {
+		r.markDirty(schema, table, id)
+		result.DataEqual = equal
+		if _, ok := result.ChunkMap[id.Encode()]; !ok {
+			result.ChunkMap[id.Encode()] = &ChunkResult{
+				RowsAdd:    0,
+				RowsDelete: 0,
+			}
+		}
+		result.ChunkMap[id.Encode()].RowsAdd += rowsAdd
+		result.ChunkMap[id.Encode()].RowsDelete += rowsDelete
+		if r.Result != Error {
+			r.Result = Fail
+		}
+		r.diffRows += int64(rowsAdd + rowsDelete)
+		if r.maxDiffRows > 0 && r.diffRows > r.maxDiffRows && !r.Aborted {
+			log.Warn("the number of diff rows exceeds max-diff-rows, abort the comparison", zap.Int64("diff rows", r.diffRows), zap.Int64("max-diff-rows", r.maxDiffRows))
+			r.Aborted = true
+		}
+		if r.maxDiffRowsPerTable > 0 && !result.DiffLimitExceeded {
+			if _, add, del := summarizeTableResult(result); int64(add+del) > r.maxDiffRowsPerTable {
+				log.Warn("the number of diff rows of the table exceeds max-diff-rows-per-table, the rest of its chunks are not compared",
+					zap.String("table", dbutil.TableName(schema, table)), zap.Int("diff rows", add+del), zap.Int64("max-diff-rows-per-table", r.maxDiffRowsPerTable))
+				result.DiffLimitExceeded = true
+			}
+		}
+		r.markTableFailed(schema, table)
+	}
+}
+
+// IsTableDiffLimitExceeded returns true if the diff rows of the table exceed max-diff-rows-per-table, so the rest of
+// its chunks should not be compared.
+func (r *Report) IsTableDiffLimitExceeded(schema, table string) bool {
+	r.RLock()
+	defer r.RUnlock()
+	result, ok := r.TableResults[schema][table]
+	return ok && result.DiffLimitExceeded
+}
+
+// AddTruncatedChunk counts a chunk of the table not compared because the diff rows of the table exceed
+// max-diff-rows-per-table.
+func (r *Report) AddTruncatedChunk(schema, table string) {
+	r.Lock()
+	defer r.Unlock()
+	r.markDirty(schema, table, nil)
+	r.getTableResult(schema, table).TruncatedChunks++
+}
+
+// SetTableCountCheckResult sets the row count check result for table, the data of the table is only verified
+// by the row count, and the count delta is recorded as the result of the chunk `id` covering the whole table.
+func (r *Report) SetTableCountCheckResult(schema, table string, equal bool, rowsAdd, rowsDelete int, id *chunk.ChunkID) {
+	r.Lock()
+	result := r.getTableResult(schema, table)
+	result.CountOnly = true
+	result.VerificationMethod = VerificationRowCount
+	r.markDirty(schema, table, nil)
+	r.Unlock()
+	r.SetTableDataCheckResult(schema, table, equal, rowsAdd, rowsDelete, id)
+}
+
+// StartChunk marks a chunk of the table starts being compared, isLastChunk means it's the last chunk of the table.
+func (r *Report) StartChunk(schema, table string, isLastChunk bool) {
+	r.Lock()
+	defer r.Unlock()
+	tableResult := r.getTableResult(schema, table)
+	tableResult.runningChunks++
+	if isLastChunk {
+		tableResult.lastChunkStarted = true
+	}
+	r.currentTable = dbutil.TableName(schema, table)
+}
+
+// FinishChunk marks a chunk of the table is compared, and the table is completed if its last chunk has started
+// and all its chunks are compared.
+func (r *Report) FinishChunk(schema, table string) {
+	r.Lock()
+	defer r.Unlock()
+	tableResult := r.getTableResult(schema, table)
+	tableResult.runningChunks--
+	r.completedChunks++
+	if tableResult.lastChunkStarted && tableResult.runningChunks == 0 {
+		r.completedTables++
+	}
+}
+
+// GetHeartbeat returns the progress of this run, the rows to add and delete are summed over all the tables.
+func (r *Report) GetHeartbeat() *Heartbeat {
+	r.RLock()
+	defer r.RUnlock()
+	heartbeat := &Heartbeat{
+		CompletedTables: r.completedTables,
+		CompletedChunks: r.completedChunks,
+		CurrentTable:    r.currentTable,
+	}
+	for _, tableMap := range r.TableResults {
+		heartbeat.TotalTables += len(tableMap)
+		for _, result := range tableMap {
+			for _, chunkResult := range result.ChunkMap {
+				heartbeat.RowsAdd += chunkResult.RowsAdd
+				heartbeat.RowsDelete += chunkResult.RowsDelete
+			}
+		}
+	}
+	return heartbeat
+}
+
+// AddTableDuration adds the time spent comparing a chunk of the table.
+func (r *Report) AddTableDuration(schema, table string, duration time.Duration) {
+	r.Lock()
+	defer r.Unlock()
+	r.markDirty(schema, table, nil)
+	r.getTableResult(schema, table).Duration += duration
+}
+
+// SetTableEstimatedRows sets the estimated row count of the table in the statistics.
+func (r *Report) SetTableEstimatedRows(schema, table string, rows int64) {
+	r.Lock()
+	defer r.Unlock()
+	r.markDirty(schema, table, nil)
+	r.getTableResult(schema, table).EstimatedRows = &rows
+}
+
+// SetTableRowCounts sets the row counts of the table on both sides compared before the chunks.
+func (r *Report) SetTableRowCounts(schema, table string, sourceRows, targetRows int64) {
+	r.Lock()
+	defer r.Unlock()
+	r.markDirty(schema, table, nil)
+	result := r.getTableResult(schema, table)
+	result.SourceRows = &sourceRows
+	result.TargetRows = &targetRows
+}
+
+// AddTableActualRows adds the rows checked by a chunk of the table.
+func (r *Report) AddTableActualRows(schema, table string, rows int64) {
+	r.Lock()
+	defer r.Unlock()
+	r.markDirty(schema, table, nil)
+	r.getTableResult(schema, table).ActualRows += rows
+}
+
+// AddFixSQLBytes adds the bytes of the fix sql files written for the chunk.
+func (r *Report) AddFixSQLBytes(schema, table string, id *chunk.ChunkID, bytes int64) {
+	r.Lock()
+	defer r.Unlock()
+	r.markDirty(schema, table, id)
+	result := r.getTableResult(schema, table)
+	if _, ok := result.ChunkMap[id.Encode()]; !ok {
+		result.ChunkMap[id.Encode()] = &ChunkResult{}
+	}
+	result.ChunkMap[id.Encode()].FixSQLBytes += bytes
+}
+
+// SetChunkPartition sets the partition of the inconsistent chunk, so that the diff rows can be attributed to the partition.
+func (r *Report) SetChunkPartition(schema, table string, id *chunk.ChunkID, partition string) {
+	r.Lock()
+	defer r.Unlock()
+	result := r.getTableResult(schema, table)
+	if chunkResult, ok := result.ChunkMap[id.Encode()]; ok {
+		chunkResult.Partition = partition
+		r.markDirty(schema, table, id)
+	}
+}
+
+// SetChunkBounds sets the bounds of the inconsistent chunk, so that the chunk can be compared again by its id.
+func (r *Report) SetChunkBounds(schema, table string, chunkRange *chunk.Range) {
+	r.Lock()
+	defer r.Unlock()
+	result := r.getTableResult(schema, table)
+	if chunkResult, ok := result.ChunkMap[chunkRange.Index.Encode()]; ok {
+		bounds := &ChunkBounds{
+			Bounds:     make([]*chunk.Bound, 0, len(chunkRange.Bounds)),
+			NullColumn: chunkRange.NullColumn,
+		}
+		for _, bound := range chunkRange.Bounds {
+			b := *bound
+			bounds.Bounds = append(bounds.Bounds, &b)
+		}
+		chunkResult.Bounds = bounds
+		r.markDirty(schema, table, chunkRange.Index)
+	}
+}
+
+// FindChunk returns the table and the result of the chunk of the id, the result is nil if it's not found.
+func (r *Report) FindChunk(id *chunk.ChunkID) (schema, table string, result *ChunkResult, err error) {
+	r.RLock()
+	defer r.RUnlock()
+	for schema, tableResults := range r.TableResults {
+		for table, tableResult := range tableResults {
+			for key, chunkResult := range tableResult.ChunkMap {
+				chunkID := new(chunk.ChunkID)
+				if err := chunkID.FromString(key); err != nil {
+					return "", "", nil, errors.Trace(err)
+				}
+				if chunkID.Compare(id) == 0 {
+					return schema, table, chunkResult, nil
+				}
+			}
+		}
+	}
+	return "", "", nil, nil
+}
+
+// SetChunkChecksumDuration sets the time of the checksum query of the chunk, and keeps the chunk with its bound
+// in `SlowChunks` if it's one of the slowest. The bound is only formatted for the slowest chunks.
+func (r *Report) SetChunkChecksumDuration(schema, table string, chunkRange *chunk.Range, duration time.Duration) {
+	r.Lock()
+	defer r.Unlock()
+	result := r.getTableResult(schema, table)
+	id := chunkRange.Index.Encode()
+	if _, ok := result.ChunkMap[id]; !ok {
+		result.ChunkMap[id] = &ChunkResult{}
+	}
+	result.ChunkMap[id].ChecksumDuration = duration
+	r.markDirty(schema, table, chunkRange.Index)
+
+	i := sort.Search(len(r.SlowChunks), func(i int) bool { return r.SlowChunks[i].ChecksumDuration < duration })
+	if i >= slowChunkCount {
+		return
+	}
+	slowChunk := &SlowChunk{
+		Schema:           schema,
+		Table:            table,
+		ChunkID:          chunkRange.Index.Copy(),
+		Bound:            chunkRange.BoundString(),
+		ChecksumDuration: duration,
+	}
+	r.SlowChunks = append(r.SlowChunks, nil)
+	copy(r.SlowChunks[i+1:], r.SlowChunks[i:])
+	r.SlowChunks[i] = slowChunk
+	if len(r.SlowChunks) > slowChunkCount {
+		r.SlowChunks = r.SlowChunks[:slowChunkCount]
+	}
+}
+
+// SetTableDiffRowsFile sets the CSV file of the diff rows of the table and the number of the rows exported to it.
+func (r *Report) SetTableDiffRowsFile(schema, table string, file string, exported int64) {
+	r.Lock()
+	defer r.Unlock()
+	r.markDirty(schema, table, nil)
+	result := r.getTableResult(schema, table)
+	result.DiffRowsFile = file
+	result.DiffRowsExported = exported
+}
+
+// SetTableFixSQLFile sets the fix sql file of the table with fix-file-layout = "table".
+func (r *Report) SetTableFixSQLFile(schema, table string, file string) {
+	r.Lock()
+	defer r.Unlock()
+	r.markDirty(schema, table, nil)
+	r.getTableResult(schema, table).FixSQLFile = file
+}
+
+// GetTableDiffRowsExported returns the number of the diff rows of the table exported, including the ones
+// exported before resuming from the checkpoint.
+func (r *Report) GetTableDiffRowsExported(schema, table string) int64 {
+	r.RLock()
+	defer r.RUnlock()
+	if result, ok := r.TableResults[schema][table]; ok {
+		return result.DiffRowsExported
+	}
+	return 0
+}
+
+// AddChunkRowDiffs sets the file of the different rows of the chunk exported by export-row-diffs, and adds the rows
+// exported and suppressed to the table. The file is empty if all the rows of the chunk are suppressed.
+func (r *Report) AddChunkRowDiffs(schema, table string, id *chunk.ChunkID, file string, exported, suppressed int64) {
+	r.Lock()
+	defer r.Unlock()
+	r.markDirty(schema, table, id)
+	result := r.getTableResult(schema, table)
+	result.RowDiffsExported += exported
+	result.RowDiffsSuppressed += suppressed
+	if len(file) == 0 {
+		return
+	}
+	if _, ok := result.ChunkMap[id.Encode()]; !ok {
+		result.ChunkMap[id.Encode()] = &ChunkResult{}
+	}
+	result.ChunkMap[id.Encode()].RowDiffsFile = file
+}
+
+// GetTableRowDiffsExported returns the number of the different rows of the table exported by export-row-diffs,
+// including the ones exported before resuming from the checkpoint.
+func (r *Report) GetTableRowDiffsExported(schema, table string) int64 {
+	r.RLock()
+	defer r.RUnlock()
+	if result, ok := r.TableResults[schema][table]; ok {
+		return result.RowDiffsExported
+	}
+	return 0
+}
+
+// SetTableChunking sets the number of the chunks and the skew factor of the table split by adaptive-chunking.
+func (r *Report) SetTableChunking(schema, table string, chunks int, skewFactor float64) {
+	r.Lock()
+	defer r.Unlock()
+	r.markDirty(schema, table, nil)
+	result := r.getTableResult(schema, table)
+	result.AdaptiveChunks = chunks
+	result.ChunkSkewFactor = skewFactor
+}
+
+// SetTableConcurrency sets the effective concurrency of the table.
+func (r *Report) SetTableConcurrency(schema, table string, concurrency int) {
+	r.Lock()
+	defer r.Unlock()
+	r.markDirty(schema, table, nil)
+	r.getTableResult(schema, table).Concurrency = concurrency
+}
+
+// SetTableMeetError sets meet error when check the table.
+// `id` is the chunk where the error happened and `bound` is its bound, `id` is nil if
+// the error is not related to a chunk.
+func (r *Report) SetTableMeetError(schema, table string, err error, id *chunk.ChunkID, bound string) {
+	r.Lock()
+	defer r.Unlock()
+	errorChunk := ""
+	if id != nil {
+		errorChunk = id.Encode()
+	} else {
+		bound = ""
+	}
+	r.markDirty(schema, table, nil)
+	result := r.getTableResult(schema, table)
+	result.MeetError = err
+	result.ErrorMessage = err.Error()
+	result.ErrorCategory = ClassifyError(err)
+	result.ErrorChunk = errorChunk
+	result.ErrorChunkBound = bound
+	r.Result = Error
+}
+
+// SnapshotResults returns a deep copy of `TableResults` taken under the read lock, which is the safe way to inspect
+// the results while the comparison is running. The returned results are not shared with the report,
+// so they are safe to mutate.
+func (r *Report) SnapshotResults() map[string]map[string]*TableResult {
+	r.RLock()
+	defer r.RUnlock()
+	return r.cloneResults()
+}
+
+// Snapshot returns a copy of the report taken under the read lock, whose `TableResults` are deep copied by
+// `SnapshotResults`. Unlike GetSnapshot, the results of all the chunks are kept, so it can be marshaled like
+// `report.json` while the comparison is running, and the lock isn't held during marshaling.
+func (r *Report) Snapshot() *Report {
+	r.RLock()
+	defer r.RUnlock()
+	return &Report{
+		Result:                r.Result,
+		PassNum:               r.PassNum,
+		FailedNum:             r.FailedNum,
+		ErrorNum:              r.ErrorNum,
+		TableResults:          r.cloneResults(),
+		StartTime:             r.StartTime,
+		Duration:              r.TotalDuration(),
+		FixTarget:             r.FixTarget,
+		Aborted:               r.Aborted,
+		Interrupted:           r.Interrupted,
+		TotalSize:             r.TotalSize,
+		TimedOut:              r.TimedOut,
+		RunTimeout:            r.RunTimeout,
+		FailedFast:            r.FailedFast,
+		FailedFastTable:       r.FailedFastTable,
+		ElapsedBeforeResume:   r.ElapsedBeforeResume,
+		SourceVersions:        r.SourceVersions,
+		TargetVersion:         r.TargetVersion,
+		ConnPoolStats:         append([]*ConnPoolStats(nil), r.ConnPoolStats...),
+		ConfigOverrides:       append([]string(nil), r.ConfigOverrides...),
+		ConfirmedChunks:       r.ConfirmedChunks,
+		TransientChunks:       r.TransientChunks,
+		HealedOnRecheck:       append([]int64(nil), r.HealedOnRecheck...),
+		CheckStructOnly:       r.CheckStructOnly,
+		ViewResults:           append([]*ViewResult(nil), r.ViewResults...),
+		CheckViews:            r.CheckViews,
+		QueryPairResults:      append([]*QueryPairResult(nil), r.QueryPairResults...),
+		PreviousRun:           r.PreviousRun,
+		Regressions:           append([]*TableDelta(nil), r.Regressions...),
+		MissingTables:         append([]*MissingTable(nil), r.MissingTables...),
+		FailOnMissingTables:   r.FailOnMissingTables,
+		SkippedObjects:        append([]*SkippedObject(nil), r.SkippedObjects...),
+		SlowChunks:            append([]*SlowChunk(nil), r.SlowChunks...),
+		SampleRate:            r.SampleRate,
+		SampleSeed:            r.SampleSeed,
+		ReplicationPositions:  append([]*utils.ReplicationPosition(nil), r.ReplicationPositions...),
+		ReplicationLag:        r.ReplicationLag,
+		SizeUnavailableTables: r.SizeUnavailableTables,
+
+		task: r.task,
+	}
+}
+
+// GetResult returns the current result, which is pass until any table fails.
+func (r *Report) GetResult() string {
+	r.RLock()
+	defer r.RUnlock()
+	return r.Result
+}
+
+// cloneResults returns a deep copy of `TableResults`, the caller should hold the lock.
+func (r *Report) cloneResults() map[string]map[string]*TableResult {
+	results := make(map[string]map[string]*TableResult, len(r.TableResults))
+	for schema, tableMap := range r.TableResults {
+		results[schema] = make(map[string]*TableResult, len(tableMap))
+		for table, result := range tableMap {
+			results[schema][table] = result.clone()
+		}
+	}
+	return results
+}
+
+// GetSnapshot get the snapshot of the current state of the report, then we can restart the
+// sync-diff and get the correct report state.
+func (r *Report) GetSnapshot(chunkID *chunk.ChunkID, schema, table string) (*Report, error) {
+	r.RLock()
+	defer r.RUnlock()
+	targetID := utils.UniqueID(schema, table)
+	reserveMap := make(map[string]map[string]*TableResult)
+	for schema, tableMap := range r.TableResults {
+		reserveMap[schema] = make(map[string]*TableResult)
+		for table, result := range tableMap {
 			reportID := utils.UniqueID(schema, table)
 			if reportID >= targetID {
 				chunkRes := make(map[string]*ChunkResult)
-				reserveMap[schema][table] = &TableResult{
-					Schema:      result.Schema,
-					Table:       result.Table,
-					StructEqual: result.StructEqual,
-					DataEqual:   result.DataEqual,
-					MeetError:   result.MeetError,
-				}
+				reserveMap[schema][table] = snapshotTableResult(result)
 				for id, chunkResult := range result.ChunkMap {
 					sid := new(chunk.ChunkID)
 					err := sid.FromString(id)
@@ -357,27 +2591,57 @@ func (r *Report) GetSnapshot(chunkID *chunk.ChunkID, schema, table string) (*Rep
 						return nil, errors.Trace(err)
 					}
 					if sid.Compare(chunkID) <= 0 {
-						chunkRes[id] = chunkResult
+						chunkRes[sid.Encode()] = chunkResult
 					}
 				}
 				reserveMap[schema][table].ChunkMap = chunkRes
 			}
 		}
 	}
+	r.takeDirty(chunkID, targetID)
+	return r.snapshotHeader(chunkID, reserveMap), nil
+}
+
+// snapshotTableResult returns the copy of the table result saved in the checkpoint without the chunks,
+// the caller should hold the lock. The struct is copied as a whole, so no field is lost after resuming.
+func snapshotTableResult(result *TableResult) *TableResult {
+	snapshot := *result
+	snapshot.ChunkMap = nil
+	// the un-mappable characters are counted while the snapshot is marshaled.
+	snapshot.TranscodedColumns = cloneTranscodedColumns(result.TranscodedColumns)
+	// the mismatch chunks are appended while the snapshot is marshaled.
+	snapshot.IndexResults = cloneIndexResults(result.IndexResults)
+	return &snapshot
+}
+
+// snapshotHeader returns the report saved in the checkpoint with the table results, the caller should hold the lock.
+func (r *Report) snapshotHeader(chunkID *chunk.ChunkID, tableResults map[string]map[string]*TableResult) *Report {
+	// the chunks after the checkpoint are compared again after resuming.
+	slowChunks := make([]*SlowChunk, 0, len(r.SlowChunks))
+	for _, slowChunk := range r.SlowChunks {
+		if slowChunk.ChunkID.Compare(chunkID) <= 0 {
+			slowChunks = append(slowChunks, slowChunk)
+		}
+	}
 
-	result := r.Result
-	totalSize := r.TotalSize
-	duration := time.Since(r.StartTime)
-	task := r.task
 	return &Report{
-		PassNum:      0,
-		FailedNum:    0,
-		Result:       result,
-		TableResults: reserveMap,
-		StartTime:    r.StartTime,
-		Duration:     duration,
-		TotalSize:    totalSize,
-
-		task: task,
-	}, nil
+		PassNum:             0,
+		FailedNum:           0,
+		Result:              r.Result,
+		TableResults:        tableResults,
+		StartTime:           r.StartTime,
+		Duration:            r.TotalDuration(),
+		TotalSize:           r.TotalSize,
+		FixTarget:           r.FixTarget,
+		Aborted:             r.Aborted,
+		ConfirmedChunks:     r.ConfirmedChunks,
+		TransientChunks:     r.TransientChunks,
+		HealedOnRecheck:     append([]int64(nil), r.HealedOnRecheck...),
+		SlowChunks:          slowChunks,
+		ElapsedBeforeResume: r.ElapsedBeforeResume,
+		SampleRate:          r.SampleRate,
+		SampleSeed:          r.SampleSeed,
+
+		task: r.task,
+	}
 }
@@ -16,10 +16,19 @@ package report
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"os"
 	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/DATA-DOG/go-sqlmock"
@@ -27,7 +36,9 @@ import (
 	"github.com/pingcap/tidb-tools/sync_diff_inspector/chunk"
 	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
 	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/utils"
 	"github.com/pingcap/tidb/parser"
+	"github.com/pingcap/tidb/parser/model"
 	"github.com/stretchr/testify/require"
 )
 
@@ -98,15 +109,21 @@ func TestReport(t *testing.T) {
 	}
 	report.Init(tableDiffs, configsBytes[:2], configsBytes[2])
 
-	// Test CalculateTotal
-	mock.ExpectQuery("select sum.*").WillReturnRows(sqlmock.NewRows([]string{"data"}).AddRow("123"))
-	mock.ExpectQuery("select sum.*where table_schema=.*").WillReturnRows(sqlmock.NewRows([]string{"data"}).AddRow("456"))
-	report.CalculateTotalSize(ctx, db)
+	// Test CalculateTotal, the sizes are got once by schema in any order.
+	mock.MatchExpectationsInOrder(false)
+	mock.ExpectQuery("select table_name, sum.*where table_schema = .*").WithArgs("test", "tbl").WillReturnRows(sqlmock.NewRows([]string{"table_name", "data"}).AddRow("tbl", "123"))
+	mock.ExpectQuery("select table_name, sum.*where table_schema = .*").WithArgs("atest", "atbl").WillReturnRows(sqlmock.NewRows([]string{"table_name", "data"}).AddRow("atbl", "456"))
+	mock.ExpectQuery("select table_name, sum.*where table_schema = .*").WithArgs("ctest", "atbl").WillReturnRows(sqlmock.NewRows([]string{"table_name", "data"}).AddRow("atbl", "0"))
+	// the sizes are all 0 in information_schema, so SHOW TABLE STATUS is tried.
+	mock.ExpectQuery("SHOW TABLE STATUS FROM `ctest`").WillReturnRows(sqlmock.NewRows([]string{"Name", "Engine", "Data_length"}).AddRow("atbl", "InnoDB", "0"))
+	report.CalculateTotalSize(ctx, utils.NewTableSizeCache(db))
+	require.NoError(t, mock.ExpectationsWereMet())
+	mock.MatchExpectationsInOrder(true)
 
 	// Test Table Report
 	report.SetTableStructCheckResult("test", "tbl", true, false)
 	report.SetTableDataCheckResult("test", "tbl", true, 100, 200, &chunk.ChunkID{1, 1, 1, 1, 2})
-	report.SetTableMeetError("test", "tbl", errors.New("eeee"))
+	report.SetTableMeetError("test", "tbl", errors.New("eeee"), nil, "")
 
 	new_report := NewReport(task)
 	new_report.LoadReport(report)
@@ -131,10 +148,27 @@ func TestReport(t *testing.T) {
 	require.Equal(t, new_report.getDiffRows(), [][]string{{"`atest`.`atbl`", "false", "+111/-222"}})
 
 	new_report.SetTableStructCheckResult("ctest", "atbl", false, true)
+	new_report.SetTableMeetError("ctest", "atbl", errors.New("ffff"), nil, "")
 
+	// the errors are kept after loading, and they take precedence over the differences.
+	require.Equal(t, Error, new_report.GetResult())
+	new_report.SetSink(&memorySink{files: make(map[string]*bytes.Buffer)})
+	require.NoError(t, new_report.CommitSummary())
+	require.Equal(t, []int32{0, 1, 2}, []int32{new_report.PassNum, new_report.FailedNum, new_report.ErrorNum})
 	buf := new(bytes.Buffer)
 	new_report.Print(buf)
 	info := buf.String()
+	require.Contains(t, info, "0 succeeded, 1 mismatched, 2 errored.\n")
+	require.Contains(t, info, "eeee error occured in `test`.`tbl`\n")
+
+	// the differences are printed without the errors.
+	for _, name := range [][2]string{{"test", "tbl"}, {"ctest", "atbl"}} {
+		new_report.TableResults[name[0]][name[1]].MeetError = nil
+	}
+	new_report.Result = Fail
+	buf = new(bytes.Buffer)
+	new_report.Print(buf)
+	info = buf.String()
 	require.Contains(t, info, "The structure of `atest`.`atbl` is not equal\n")
 	require.Contains(t, info, "The data of `atest`.`atbl` is not equal\n")
 	require.Contains(t, info, "The structure of `ctest`.`atbl` is not equal, and data-check is skipped\n")
@@ -191,11 +225,30 @@ func TestCalculateTotal(t *testing.T) {
 	report.Init(tableDiffs, configsBytes[:2], configsBytes[2])
 
 	// Normal
-	mock.ExpectQuery("select sum.*").WillReturnRows(sqlmock.NewRows([]string{"data"}).AddRow("123"))
-	report.CalculateTotalSize(ctx, db)
+	mock.ExpectQuery("select table_name, sum.*").WillReturnRows(sqlmock.NewRows([]string{"table_name", "data"}).AddRow("tbl", "123"))
+	report.CalculateTotalSize(ctx, utils.NewTableSizeCache(db))
 	require.Equal(t, report.TotalSize, int64(123))
+	require.Equal(t, 0, report.SizeUnavailableTables)
+
+	// the table isn't errored if failed to get the size, which is only used for the speed.
+	report.TotalSize = 0
+	mock.ExpectQuery("select table_name, sum.*").WillReturnError(errors.New("information_schema is unavailable"))
+	report.CalculateTotalSize(ctx, utils.NewTableSizeCache(db))
+	require.Equal(t, int64(0), report.TotalSize)
+	require.Equal(t, 1, report.SizeUnavailableTables)
+	require.Nil(t, report.TableResults["test"]["tbl"].MeetError)
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	require.Contains(t, sink.files["summary.txt"].String(), "Total Size: 0B (estimated by the table statistics, the sizes of 1 tables are unavailable, so the speed is underestimated)\n")
+	require.NoError(t, mock.ExpectationsWereMet())
 }
 
+// errorWriter is a writer which always fails.
+type errorWriter struct{}
+
+func (errorWriter) Write([]byte) (int, error) { return 0, io.ErrClosedPipe }
+
 func TestPrint(t *testing.T) {
 	report := NewReport(task)
 	createTableSQL := "create table `test`.`tbl`(`a` int, `b` varchar(10), `c` float, `d` datetime, primary key(`a`, `b`))"
@@ -243,17 +296,41 @@ func TestPrint(t *testing.T) {
 	report.SetTableDataCheckResult("test", "tbl", true, 0, 0, &chunk.ChunkID{0, 0, 0, 0, 1})
 	buf = new(bytes.Buffer)
 	report.Print(buf)
-	require.Equal(t, buf.String(), "A total of 0 table have been compared and all are equal.\n"+
+	require.Equal(t, buf.String(), "1 succeeded, 0 mismatched, 0 errored.\n"+
+		"A total of 1 table have been compared and all are equal.\n"+
 		"You can view the comparision details through 'output_dir/sync_diff.log'\n")
 
 	// Error
-	report.SetTableMeetError("test", "tbl", errors.New("123"))
+	report.SetTableMeetError("test", "tbl", errors.New("123"), nil, "")
 	report.SetTableStructCheckResult("test", "tbl", false, false)
 	buf = new(bytes.Buffer)
 	report.Print(buf)
-	require.Equal(t, buf.String(), "Error in comparison process:\n"+
-		"123 error occured in `test`.`tbl`\n"+
+	require.Equal(t, buf.String(), "0 succeeded, 0 mismatched, 1 errored.\n"+
+		"Error in comparison process:\n"+
+		"other errors in 1 table:\n"+
+		"    123 error occured in `test`.`tbl`\n"+
+		"You can view the comparision details through 'output_dir/sync_diff.log'\n")
+
+	// Error on chunk
+	report.SetTableMeetError("test", "tbl", errors.New("456"), &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 1, BucketIndexRight: 1, ChunkIndex: 2, ChunkCnt: 3}, "(1) < (a) <= (5)")
+	buf = new(bytes.Buffer)
+	report.Print(buf)
+	require.Equal(t, buf.String(), "0 succeeded, 0 mismatched, 1 errored.\n"+
+		"Error in comparison process:\n"+
+		"other errors in 1 table:\n"+
+		"    456 error occured in `test`.`tbl` on chunk v2:g0.g1.g2.g1.g3 (bound (1) < (a) <= (5))\n"+
 		"You can view the comparision details through 'output_dir/sync_diff.log'\n")
+	require.Equal(t, buf.String(), report.Summary())
+	// the error writing the summary is returned.
+	require.ErrorIs(t, report.Print(errorWriter{}), io.ErrClosedPipe)
+	result := report.TableResults["test"]["tbl"]
+	require.Equal(t, "v2:g0.g1.g2.g1.g3", result.ErrorChunk)
+	require.Equal(t, "(1) < (a) <= (5)", result.ErrorChunkBound)
+
+	// the chunk of the previous error is cleared
+	report.SetTableMeetError("test", "tbl", errors.New("789"), nil, "(1) < (a) <= (5)")
+	require.Empty(t, result.ErrorChunk)
+	require.Empty(t, result.ErrorChunkBound)
 }
 
 func TestGetSnapshot(t *testing.T) {
@@ -328,6 +405,8 @@ func TestGetSnapshot(t *testing.T) {
 	report.SetTableDataCheckResult("xtest", "tbl", true, 0, 0, &chunk.ChunkID{0, 0, 0, 1, 10})
 	report.SetTableDataCheckResult("xtest", "tbl", false, 200, 200, &chunk.ChunkID{0, 0, 0, 3, 10})
 
+	report.SetTableConcurrency("test", "tbl", 2)
+	report.SetTableStructDiff("test", "tbl", StructDiffNonBreaking)
 	report_snap, err := report.GetSnapshot(&chunk.ChunkID{0, 0, 0, 1, 10}, "test", "tbl")
 	require.NoError(t, err)
 	require.Equal(t, report_snap.TotalSize, report.TotalSize)
@@ -352,6 +431,8 @@ func TestGetSnapshot(t *testing.T) {
 		require.Equal(t, v1.StructEqual, v2.StructEqual)
 		require.Equal(t, v1.DataEqual, v2.DataEqual)
 		require.Equal(t, v1.MeetError, v2.MeetError)
+		require.Equal(t, v1.Concurrency, v2.Concurrency)
+		require.Equal(t, v1.StructDiff, v2.StructDiff)
 
 		chunkMap1 := v1.ChunkMap
 		chunkMap2 := v2.ChunkMap
@@ -466,6 +547,7 @@ func TestCommitSummary(t *testing.T) {
 		"port = 4000\n"+
 		"user = \"root\"\n\n"+
 		"Comparison Result\n\n\n\n"+
+		"2 succeeded, 2 mismatched, 0 errored\n\n"+
 		"The table structure and data in following tables are equivalent\n\n"+
 		"`test`.`tbl`\n"+
 		"`ytest`.`tbl`\n\n"+
@@ -481,4 +563,2006 @@ func TestCommitSummary(t *testing.T) {
 	file.Close()
 	err = os.Remove(filename)
 	require.NoError(t, err)
+	err = os.Remove(path.Join(outputDir, "report.json"))
+	require.NoError(t, err)
+}
+
+type memorySink struct {
+	files map[string]*bytes.Buffer
+}
+
+type memoryFile struct {
+	*bytes.Buffer
+}
+
+func (f *memoryFile) Close() error { return nil }
+
+func (s *memorySink) Create(name string) (io.WriteCloser, error) {
+	buf := new(bytes.Buffer)
+	s.files[name] = buf
+	return &memoryFile{buf}, nil
+}
+
+func TestReportSink(t *testing.T) {
+	report := NewReport(task)
+	createTableSQL := "create table `test`.`tbl`(`a` int, `b` varchar(10), `c` float, `d` datetime, primary key(`a`, `b`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+
+	tableDiffs := []*common.TableDiff{
+		{
+			Schema:    "test",
+			Table:     "tbl",
+			Info:      tableInfo,
+			Collation: "[123]",
+		},
+	}
+	report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	report.SetTableStructCheckResult("test", "tbl", true, false)
+	report.SetTableDataCheckResult("test", "tbl", true, 0, 0, &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1})
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	buf, ok := sink.files["summary.txt"]
+	require.True(t, ok)
+	require.Contains(t, buf.String(), "Summary\n\n\n\n"+
+		"Source Database\n\n\n\n"+
+		"host = \"127.0.0.1\"\n\n"+
+		"Target Databases\n\n\n\n"+
+		"host = \"127.0.0.2\"\n\n")
+	require.Contains(t, buf.String(), "`test`.`tbl`\n")
+}
+
+// uploadSink is a memorySink whose file of `failName` fails to be uploaded on Close.
+type uploadSink struct {
+	memorySink
+	failName string
+}
+
+type uploadFile struct {
+	memoryFile
+	err error
+}
+
+func (f *uploadFile) Close() error { return f.err }
+
+func (s *uploadSink) Create(name string) (io.WriteCloser, error) {
+	w, _ := s.memorySink.Create(name)
+	file := &uploadFile{memoryFile: *w.(*memoryFile)}
+	if name == s.failName {
+		file.err = errors.New("upload failed")
+	}
+	return file, nil
+}
+
+func TestReportSinkCloseError(t *testing.T) {
+	report := NewReport(task)
+	tableDiffs := []*common.TableDiff{{Schema: "test", Table: "tbl"}}
+	report.Init(tableDiffs, nil, nil)
+	report.SetTableStructCheckResult("test", "tbl", true, false)
+	report.SetTableDataCheckResult("test", "tbl", true, 0, 0, &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1})
+	for _, name := range []string{"summary.txt", "report.json", "metrics.json"} {
+		report.SetSink(&uploadSink{memorySink: memorySink{files: make(map[string]*bytes.Buffer)}, failName: name})
+		err := report.CommitSummary()
+		require.Error(t, err, name)
+		require.Contains(t, err.Error(), "upload failed")
+	}
+}
+
+func TestTeeSink(t *testing.T) {
+	report := NewReport(task)
+	report.Init(nil, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	stdout := new(bytes.Buffer)
+	report.SetSink(NewTeeSink(sink, "report.json", stdout))
+	require.NoError(t, report.CommitSummary())
+	// only the report of the name is copied.
+	require.Equal(t, sink.files["report.json"].String(), stdout.String())
+	result := new(Report)
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), result))
+	require.Contains(t, sink.files["summary.txt"].String(), "Summary\n")
+	require.NotContains(t, stdout.String(), "Summary\n")
+}
+
+func TestReportJSON(t *testing.T) {
+	report := NewReport(task)
+	createTableSQL := "create table `test`.`tbl`(`a` int, `b` varchar(10), `c` float, `d` datetime, primary key(`a`, `b`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+
+	tableDiffs := []*common.TableDiff{
+		{
+			Schema:    "test",
+			Table:     "tbl",
+			Info:      tableInfo,
+			Collation: "[123]",
+		},
+	}
+	report.FixTarget = "source"
+	report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	report.SetTableStructCheckResult("test", "tbl", true, false)
+	report.SetTableDataCheckResult("test", "tbl", false, 2, 1, &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1})
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	buf, ok := sink.files["report.json"]
+	require.True(t, ok)
+
+	result := new(Report)
+	require.NoError(t, json.Unmarshal(buf.Bytes(), result))
+	require.Equal(t, "source", result.FixTarget)
+	chunkResult := result.TableResults["test"]["tbl"].ChunkMap["v2:g0.g0.g0.g0.g1"]
+	require.Equal(t, 2, chunkResult.RowsAdd)
+	require.Equal(t, 1, chunkResult.RowsDelete)
+	require.Nil(t, result.TargetVersion)
+	require.NotContains(t, sink.files["summary.txt"].String(), "Environment")
+
+	buf, ok = sink.files["metrics.json"]
+	require.True(t, ok)
+	require.Contains(t, buf.String(), `"table": "test.tbl",
+    "metric": "rows_add",
+    "value": 2`)
+}
+
+func TestChunkBounds(t *testing.T) {
+	report := NewReport(task)
+	createTableSQL := "create table `test`.`tbl`(`a` int, `b` varchar(10), primary key(`a`, `b`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+	report.Init([]*common.TableDiff{{Schema: "test", Table: "tbl", Info: tableInfo}}, nil, nil)
+
+	id := &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 1, BucketIndexRight: 2, ChunkIndex: 3, ChunkCnt: 5}
+	chunkRange := chunk.NewChunkRange()
+	chunkRange.Index = id
+	chunkRange.Update("a", "1", "10", true, true)
+	chunkRange.Update("b", "", "x", false, true)
+	// the bounds are only recorded for the inconsistent chunks.
+	report.SetChunkBounds("test", "tbl", chunkRange)
+	_, _, result, err := report.FindChunk(id)
+	require.NoError(t, err)
+	require.Nil(t, result)
+
+	report.SetTableDataCheckResult("test", "tbl", false, 1, 0, id)
+	report.SetChunkBounds("test", "tbl", chunkRange)
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+
+	saved := new(Report)
+	require.NoError(t, json.Unmarshal(sink.files["report.json"].Bytes(), saved))
+	schema, table, result, err := saved.FindChunk(id)
+	require.NoError(t, err)
+	require.Equal(t, "test", schema)
+	require.Equal(t, "tbl", table)
+	require.NotNil(t, result)
+	rebuilt := result.ChunkRange(id)
+	require.Equal(t, chunkRange.Bounds, rebuilt.Bounds)
+	require.Equal(t, 0, rebuilt.Index.Compare(id))
+
+	// the chunks compared by the old versions have no bounds.
+	result.Bounds = nil
+	require.Nil(t, result.ChunkRange(id))
+	_, _, result, err = saved.FindChunk(&chunk.ChunkID{TableIndex: 1, ChunkCnt: 1})
+	require.NoError(t, err)
+	require.Nil(t, result)
+}
+
+func TestServerVersions(t *testing.T) {
+	report := NewReport(task)
+	report.Init(nil, [][]byte{[]byte("host = \"127.0.0.1\"\n"), []byte("host = \"127.0.0.2\"\n")}, []byte("host = \"127.0.0.3\"\n"))
+	report.SetServerVersions([]*ServerVersion{
+		{Version: "8.0.25"},
+		nil,
+		{Version: "5.5.5-10.6.12-MariaDB-log", Flavor: utils.FlavorMariaDB},
+	}, &ServerVersion{Version: "5.7.25-TiDB-v5.3.0", TiDBVersion: "Release Version: v5.3.0\nEdition: Community"})
+	report.SetConfigOverrides([]string{"data-sources.tidb0.host=prod-tidb", "data-sources.tidb0.password=******"})
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	require.Contains(t, sink.files["summary.txt"].String(), "Config Overrides\n\n\n\n"+
+		"data-sources.tidb0.host=prod-tidb\n"+
+		"data-sources.tidb0.password=******\n\n")
+	require.Contains(t, sink.files["summary.txt"].String(), "Environment\n\n\n\n"+
+		"Source Database 0 Version: 8.0.25\n"+
+		"Source Database 1 Version: unknown\n"+
+		"Source Database 2 Version: 5.5.5-10.6.12-MariaDB-log (MariaDB 10.6.12)\n"+
+		"Target Database Version: 5.7.25-TiDB-v5.3.0\n"+
+		"    Release Version: v5.3.0\n"+
+		"    Edition: Community\n\n")
+
+	result := new(Report)
+	require.NoError(t, json.Unmarshal(sink.files["report.json"].Bytes(), result))
+	require.Equal(t, []*ServerVersion{{Version: "8.0.25"}, nil, {Version: "5.5.5-10.6.12-MariaDB-log", Flavor: utils.FlavorMariaDB}}, result.SourceVersions)
+	require.Equal(t, "Release Version: v5.3.0\nEdition: Community", result.TargetVersion.TiDBVersion)
+}
+
+func TestAutoIncrementWarning(t *testing.T) {
+	report := NewReport(task)
+	report.Init(nil, [][]byte{[]byte("host = \"127.0.0.1\"\n"), []byte("host = \"127.0.0.2\"\n")}, []byte("host = \"127.0.0.3\"\n"))
+	report.SetServerVersions([]*ServerVersion{
+		{Version: "8.0.25", AutoIncrementIncrement: 2, AutoIncrementOffset: 1},
+		{Version: "8.0.25", AutoIncrementIncrement: 1, AutoIncrementOffset: 1},
+	}, &ServerVersion{Version: "8.0.25", AutoIncrementIncrement: 2, AutoIncrementOffset: 2})
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	summary := sink.files["summary.txt"].String()
+	require.Contains(t, summary, "Environment\n\n\n\n"+
+		"Source Database 0 Version: 8.0.25\n"+
+		"Source Database 0 auto_increment_increment: 2, auto_increment_offset: 1\n"+
+		"Source Database 1 Version: 8.0.25\n"+
+		"Source Database 1 auto_increment_increment: 1, auto_increment_offset: 1\n"+
+		"Target Database Version: 8.0.25\n"+
+		"Target Database auto_increment_increment: 2, auto_increment_offset: 2\n"+
+		"WARNING: the AUTO_INCREMENT values generated by Source Database 0 (increment 2, offset 1) and Target Database (increment 2, offset 2) never overlap")
+	// only the source disjoint with the target is warned.
+	require.NotContains(t, summary, "Source Database 1 (increment")
+	buf := new(bytes.Buffer)
+	require.NoError(t, report.Print(buf))
+	require.Contains(t, buf.String(), "compare the tables by the natural keys with `index-fields` or `query-pairs` instead.\n")
+
+	result := new(Report)
+	require.NoError(t, json.Unmarshal(sink.files["report.json"].Bytes(), result))
+	require.Equal(t, int64(2), result.TargetVersion.AutoIncrementOffset)
+
+	// the settings failed to get aren't warned.
+	report.SetServerVersions([]*ServerVersion{{Version: "8.0.25", AutoIncrementIncrement: 2, AutoIncrementOffset: 1}}, &ServerVersion{Version: "8.0.25"})
+	require.Empty(t, report.getAutoIncrementWarning())
+}
+
+func TestConnPoolStats(t *testing.T) {
+	report := NewReport(task)
+	report.Init(nil, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	report.StartTime = time.Now().Add(-100 * time.Second)
+	report.SetServerVersions([]*ServerVersion{{Version: "8.0.25"}}, &ServerVersion{Version: "8.0.25"})
+	report.SetConnPoolStats([]*ConnPoolStats{
+		{Name: "Source Database 0", MaxOpenConnections: 5, PeakInUse: 3, WaitCount: 2, WaitDuration: time.Second},
+		{Name: "Target Database", MaxOpenConnections: 7, PeakInUse: 7, WaitCount: 1200, WaitDuration: time.Minute},
+	})
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	summary := sink.files["summary.txt"].String()
+	require.Contains(t, summary, "Target Database Version: 8.0.25\n"+
+		"Source Database 0 Connection Pool: max open 5, peak in use 3, waited 2 times for 1s\n"+
+		"Target Database Connection Pool: max open 7, peak in use 7, waited 1200 times for 1m0s\n"+
+		"WARNING: the connections of Target Database are waited for 1m0s in the run of 1m40s")
+	require.Contains(t, summary, "consider raising max-open-conns\n\n")
+	// only the pool waited above the ratio of the run is warned.
+	require.Equal(t, 1, strings.Count(summary, "WARNING: the connections"))
+
+	result := new(Report)
+	require.NoError(t, json.Unmarshal(sink.files["report.json"].Bytes(), result))
+	require.Equal(t, report.ConnPoolStats, result.ConnPoolStats)
+
+	stats := &ConnPoolStats{WaitDuration: time.Second}
+	require.True(t, stats.IsWaitSignificant(5*time.Second))
+	require.False(t, stats.IsWaitSignificant(time.Minute))
+	require.False(t, stats.IsWaitSignificant(0))
+}
+
+func TestRangeTables(t *testing.T) {
+	report := NewReport(task)
+	tableDiffs := []*common.TableDiff{
+		{Schema: "test", Table: "t1", Range: "created_at > '2024-01-01'"},
+		// the range is "TRUE" if it isn't set.
+		{Schema: "test", Table: "t2", Range: "TRUE"},
+	}
+	report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	report.SetTableStructCheckResult("test", "t1", true, false)
+	report.SetTableStructCheckResult("test", "t2", true, false)
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	require.Contains(t, sink.files["summary.txt"].String(), "Only the rows in the range of the following tables are compared\n\n"+
+		"`test`.`t1` created_at > '2024-01-01'\n")
+	require.NotContains(t, sink.files["summary.txt"].String(), "`test`.`t2` TRUE")
+
+	result := new(Report)
+	require.NoError(t, json.Unmarshal(sink.files["report.json"].Bytes(), result))
+	require.Equal(t, "created_at > '2024-01-01'", result.TableResults["test"]["t1"].Range)
+	require.Empty(t, result.TableResults["test"]["t2"].Range)
+}
+
+func TestNoPKTables(t *testing.T) {
+	report := NewReport(task)
+	createTableSQL := "create table `test`.`tbl`(`a` int, `b` varchar(10))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+
+	tableDiffs := []*common.TableDiff{
+		{
+			Schema:       "test",
+			Table:        "tbl",
+			Info:         tableInfo,
+			NoPKFallback: true,
+		},
+		{
+			Schema:       "xtest",
+			Table:        "tbl",
+			Info:         tableInfo,
+			NoPKFallback: true,
+		},
+	}
+	report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	report.SetTableStructCheckResult("test", "tbl", true, false)
+	report.SetTableDataCheckResult("test", "tbl", true, 0, 0, &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1})
+	// skipped without compare-no-index-tables
+	report.SetTableSkipReason("xtest", "tbl", "no usable unique key")
+	report.SetTableStructCheckResult("xtest", "tbl", true, true)
+	require.True(t, report.TableResults["test"]["tbl"].NoPKFallback)
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	require.Equal(t, Pass, report.Result)
+	summary := sink.files["summary.txt"].String()
+	require.Contains(t, summary, "The table structure and data in following tables are equivalent\n\n"+
+		"`test`.`tbl`\n\n"+
+		"The data check of the following tables is skipped\n\n"+
+		"`xtest`.`tbl` no usable unique key\n\n"+
+		"Warning: the following tables have no primary key or unique key, the rows of the whole table are compared as multisets, and the fix sql is `DELETE ... LIMIT 1` and `INSERT` pairs\n\n"+
+		"`test`.`tbl`\n")
+}
+
+func TestMaxDiffRows(t *testing.T) {
+	report := NewReport(task)
+	report.SetMaxDiffRows(10)
+	createTableSQL := "create table `test`.`tbl`(`a` int, `b` varchar(10), primary key(`a`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+
+	tableDiffs := []*common.TableDiff{
+		{
+			Schema: "test",
+			Table:  "tbl",
+			Info:   tableInfo,
+		},
+	}
+	report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	report.SetTableStructCheckResult("test", "tbl", true, false)
+	report.SetTableDataCheckResult("test", "tbl", false, 3, 2, &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 3})
+	report.SetTableDataCheckResult("test", "tbl", false, 5, 0, &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 1, ChunkCnt: 3})
+	require.False(t, report.IsAborted())
+	report.SetTableDataCheckResult("test", "tbl", false, 1, 0, &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 2, ChunkCnt: 3})
+	require.True(t, report.IsAborted())
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	require.Contains(t, sink.files["summary.txt"].String(), "The comparison is aborted because the number of diff rows exceeds max-diff-rows(10), the results are partial\n")
+	buf := new(bytes.Buffer)
+	require.NoError(t, report.Print(buf))
+	require.Contains(t, buf.String(), "The comparison is aborted")
+
+	// the diff rows are restored from the checkpoint.
+	snapshot, err := report.GetSnapshot(&chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 1, ChunkCnt: 3}, "test", "tbl")
+	require.NoError(t, err)
+	require.True(t, snapshot.Aborted)
+	// the checkpoint saved before the abort, whose diff rows make the new ones abort the resumed comparison.
+	snapshot.Aborted = false
+	newReport := NewReport(task)
+	newReport.SetMaxDiffRows(10)
+	newReport.Init(tableDiffs, nil, nil)
+	newReport.LoadReport(roundTrip(t, snapshot))
+	require.False(t, newReport.IsAborted())
+	newReport.SetTableDataCheckResult("test", "tbl", false, 3, 0, &chunk.ChunkID{TableIndex: 1, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1})
+	require.True(t, newReport.IsAborted())
+
+	// the aborted state is restored from the checkpoint, so the resumed comparison stays aborted.
+	snapshot, err = report.GetSnapshot(&chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 2, ChunkCnt: 3}, "test", "tbl")
+	require.NoError(t, err)
+	newReport = NewReport(task)
+	newReport.SetMaxDiffRows(10)
+	newReport.Init(tableDiffs, nil, nil)
+	newReport.LoadReport(roundTrip(t, snapshot))
+	require.True(t, newReport.IsAborted())
+	sink = &memorySink{files: make(map[string]*bytes.Buffer)}
+	newReport.SetSink(sink)
+	require.NoError(t, newReport.CommitSummary())
+	require.Contains(t, sink.files["summary.txt"].String(), "The comparison is aborted because the number of diff rows exceeds max-diff-rows(10)")
+}
+
+func TestMaxDiffRowsPerTable(t *testing.T) {
+	report := NewReport(task)
+	report.SetMaxDiffRowsPerTable(5)
+	createTableSQL := "create table `test`.`tbl`(`a` int, `b` varchar(10), primary key(`a`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+
+	tableDiffs := []*common.TableDiff{
+		{
+			Schema: "test",
+			Table:  "tbl",
+			Info:   tableInfo,
+		},
+	}
+	report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	report.SetTableStructCheckResult("test", "tbl", true, false)
+	report.SetTableDataCheckResult("test", "tbl", false, 3, 2, &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 3})
+	require.False(t, report.IsTableDiffLimitExceeded("test", "tbl"))
+	report.SetTableDataCheckResult("test", "tbl", false, 1, 0, &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 1, ChunkCnt: 3})
+	require.True(t, report.IsTableDiffLimitExceeded("test", "tbl"))
+	require.False(t, report.IsTableDiffLimitExceeded("test", "missing"))
+	// the limit of the table doesn't abort the whole comparison.
+	require.False(t, report.IsAborted())
+	report.AddTruncatedChunk("test", "tbl")
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	summary := sink.files["summary.txt"].String()
+	require.Regexp(t, "\\+4/-2 \\(truncated\\)", summary)
+	require.Contains(t, summary, "Warning: the comparison of the following tables is truncated because their diff rows exceed max-diff-rows-per-table")
+	require.Contains(t, summary, "\n`test`.`tbl`: 1 chunks not compared\n")
+	require.Contains(t, report.Summary(), "Warning: the comparison of 1 table is truncated by max-diff-rows-per-table, their diff rows are partial counts:\n    `test`.`tbl`: 1 chunks not compared\n")
+
+	// the truncation is restored from the checkpoint.
+	snapshot, err := report.GetSnapshot(&chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 2, ChunkCnt: 3}, "test", "tbl")
+	require.NoError(t, err)
+	newReport := NewReport(task)
+	newReport.Init(tableDiffs, nil, nil)
+	newReport.LoadReport(snapshot)
+	require.True(t, newReport.IsTableDiffLimitExceeded("test", "tbl"))
+	require.Equal(t, 1, newReport.TableResults["test"]["tbl"].TruncatedChunks)
+}
+
+func TestLoadLegacyChunkKeys(t *testing.T) {
+	tableDiffs := []*common.TableDiff{{Schema: "test", Table: "tbl"}}
+	// the report saved in the checkpoint by the old versions.
+	data := []byte(`{"table-results":{"test":{"tbl":{"schema":"test","table":"tbl","struct-equal":true,"data-equal":false,"meet-error":null,` +
+		`"chunk-result":{"0:0-0:1:10":{"rows-add":1,"rows-delete":0},"0:0-0:10:10":{"rows-add":0,"rows-delete":2},"0:1-x":{"rows-add":4,"rows-delete":0}}}}}}`)
+	saved := new(Report)
+	require.NoError(t, json.Unmarshal(data, saved))
+
+	report := NewReport(task)
+	report.Init(tableDiffs, nil, nil)
+	report.LoadReport(saved)
+	chunkMap := report.TableResults["test"]["tbl"].ChunkMap
+	require.Len(t, chunkMap, 3)
+	require.Equal(t, 1, chunkMap["v2:g0.g0.g1.g0.ga"].RowsAdd)
+	require.Equal(t, 2, chunkMap["v2:g0.g0.ga.g0.ga"].RowsDelete)
+	// the chunk id that can't be parsed is kept.
+	require.Equal(t, 4, chunkMap["0:1-x"].RowsAdd)
+
+	// the chunks are added to the converted keys after resuming.
+	id := &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 1, ChunkCnt: 10}
+	report.SetTableDataCheckResult("test", "tbl", false, 1, 0, id)
+	require.Equal(t, 2, chunkMap[id.Encode()].RowsAdd)
+
+	saved = new(Report)
+	require.NoError(t, json.Unmarshal(data, saved))
+	delete(saved.TableResults["test"]["tbl"].ChunkMap, "0:1-x")
+	snapshot, err := saved.GetSnapshot(id, "test", "tbl")
+	require.NoError(t, err)
+	require.Equal(t, ChunkResults{"v2:g0.g0.g1.g0.ga": {RowsAdd: 1}}, snapshot.TableResults["test"]["tbl"].ChunkMap)
+}
+
+func TestFixSQLBytes(t *testing.T) {
+	report := NewReport(task)
+	createTableSQL := "create table `test`.`tbl`(`a` int, `b` varchar(10), primary key(`a`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+
+	tableDiffs := []*common.TableDiff{
+		{
+			Schema: "test",
+			Table:  "tbl",
+			Info:   tableInfo,
+		},
+		{
+			Schema: "atest",
+			Table:  "tbl",
+			Info:   tableInfo,
+		},
+	}
+	report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	report.SetTableStructCheckResult("test", "tbl", true, false)
+	report.SetTableStructCheckResult("atest", "tbl", true, false)
+	id1 := &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 2}
+	id2 := &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 1, ChunkCnt: 2}
+	id3 := &chunk.ChunkID{TableIndex: 1, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1}
+	report.SetTableDataCheckResult("test", "tbl", false, 1234, 0, id1)
+	report.AddFixSQLBytes("test", "tbl", id1, 1536)
+	report.SetTableDataCheckResult("test", "tbl", false, 0, 1, id2)
+	report.AddFixSQLBytes("test", "tbl", id2, 20)
+	report.SetTableDataCheckResult("atest", "tbl", false, 0, 1, id3)
+	report.AddFixSQLBytes("atest", "tbl", id3, 3)
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	require.Contains(t, sink.files["summary.txt"].String(), "| `test`.`tbl`  | true               | +1,234/-1      |\n")
+	require.Contains(t, sink.files["summary.txt"].String(), "The following fix sql files have been written\n\n"+
+		"+---------------+--------------+\n"+
+		"|     TABLE     | FIX SQL SIZE |\n"+
+		"+---------------+--------------+\n"+
+		"| `atest`.`tbl` | 3B           |\n"+
+		"| `test`.`tbl`  | 1.5KB        |\n"+
+		"+---------------+--------------+\n")
+
+	// the fix sql file of each table is shown with fix-file-layout = "table".
+	report.SetTableFixSQLFile("test", "tbl", "/tmp/fix/test:tbl.sql")
+	require.NoError(t, report.CommitSummary())
+	require.Regexp(t, "FIX SQL FILE +\\|\n.*\n\\| `atest`.`tbl` \\| 3B +\\| +\\|\n\\| `test`.`tbl` +\\| 1.5KB +\\| /tmp/fix/test:tbl.sql \\|", sink.files["summary.txt"].String())
+
+	// the sizes and the rows are written as is with raw-units.
+	report.SetRawUnits(true)
+	require.NoError(t, report.CommitSummary())
+	require.Contains(t, sink.files["summary.txt"].String(), "| +1234/-1       |")
+	require.Regexp(t, "\\| `test`.`tbl` +\\| 1556B +\\|", sink.files["summary.txt"].String())
+}
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+func TestReproducibleSummary(t *testing.T) {
+	startTime := time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC)
+	nowFunc = func() time.Time { return startTime.Add(10 * time.Second) }
+	defer func() { nowFunc = time.Now }()
+
+	createTableSQL := "create table `test`.`tbl`(`a` int, `b` varchar(10), primary key(`a`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+	tableDiffs := make([]*common.TableDiff, 0)
+	for _, schema := range []string{"test", "atest", "b_test", "btest"} {
+		for _, table := range []string{"tbl", "t1", "t2"} {
+			tableDiffs = append(tableDiffs, &common.TableDiff{Schema: schema, Table: table, Info: tableInfo})
+		}
+	}
+	report := NewReport(task)
+	report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	report.StartTime = startTime
+	report.TotalSize = 10 * 1024 * 1024
+	for i, tableDiff := range tableDiffs {
+		report.SetTableStructCheckResult(tableDiff.Schema, tableDiff.Table, i != 4, false)
+		for j := 0; j < 12; j++ {
+			id := &chunk.ChunkID{TableIndex: i, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: j, ChunkCnt: 12}
+			if i%3 != 0 {
+				report.SetTableDataCheckResult(tableDiff.Schema, tableDiff.Table, true, 0, 0, id)
+				continue
+			}
+			report.SetTableDataCheckResult(tableDiff.Schema, tableDiff.Table, false, j, j%2, id)
+			report.AddFixSQLBytes(tableDiff.Schema, tableDiff.Table, id, int64(100*j))
+		}
+	}
+
+	outputs := make([]*memorySink, 0, 2)
+	for i := 0; i < 2; i++ {
+		sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+		report.SetSink(sink)
+		require.NoError(t, report.CommitSummary())
+		buf := new(bytes.Buffer)
+		require.NoError(t, report.Print(buf))
+		sink.files["print"] = buf
+		outputs = append(outputs, sink)
+	}
+	for _, name := range []string{"summary.txt", "report.json", "print"} {
+		require.Equal(t, outputs[0].files[name].String(), outputs[1].files[name].String())
+	}
+
+	golden := filepath.Join("testdata", "summary.golden")
+	if *updateGolden {
+		require.NoError(t, os.WriteFile(golden, outputs[0].files["summary.txt"].Bytes(), 0644))
+	}
+	expected, err := os.ReadFile(golden)
+	require.NoError(t, err)
+	require.Equal(t, string(expected), outputs[0].files["summary.txt"].String())
+
+	// the chunks are serialized in the order of the chunk id.
+	reportJSON := outputs[0].files["report.json"].String()
+	require.Less(t, strings.Index(reportJSON, `"v2:g0.g0.g2.g0.gc"`), strings.Index(reportJSON, `"v2:g0.g0.ga.g0.gc"`))
+}
+
+func TestSlowChunks(t *testing.T) {
+	tableDiffs := []*common.TableDiff{{Schema: "test", Table: "tbl"}}
+	report := NewReport(task)
+	report.Init(tableDiffs, nil, nil)
+	report.SetTableStructCheckResult("test", "tbl", true, false)
+	for i := 0; i < 15; i++ {
+		chunkRange := chunk.NewChunkRange()
+		chunkRange.Index = &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: i, ChunkCnt: 15}
+		chunkRange.Update("a", strconv.Itoa(i*10), strconv.Itoa(i*10+10), true, true)
+		// the chunk 2 is the slowest.
+		duration := time.Duration((i*7)%15+1) * time.Millisecond
+		report.SetChunkChecksumDuration("test", "tbl", chunkRange, duration)
+	}
+	// the durations of all the chunks are recorded.
+	require.Len(t, report.TableResults["test"]["tbl"].ChunkMap, 15)
+	require.Len(t, report.SlowChunks, slowChunkCount)
+	require.Equal(t, 2, report.SlowChunks[0].ChunkID.ChunkIndex)
+	require.Equal(t, "(20) < (a) <= (30)", report.SlowChunks[0].Bound)
+	require.Equal(t, 15*time.Millisecond, report.SlowChunks[0].ChecksumDuration)
+	for i := 1; i < len(report.SlowChunks); i++ {
+		require.GreaterOrEqual(t, report.SlowChunks[i-1].ChecksumDuration, report.SlowChunks[i].ChecksumDuration)
+	}
+	require.Equal(t, 6*time.Millisecond, report.SlowChunks[slowChunkCount-1].ChecksumDuration)
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	summary := sink.files["summary.txt"].String()
+	require.Contains(t, summary, "\nThe checksums of the following chunks are the slowest\n\n")
+	require.Regexp(t, "`test`.`tbl` +\\| v2:g0.g0.g2.g0.gf +\\| \\(20\\) < \\(a\\) <= \\(30\\) +\\| 15ms", summary)
+
+	// the slowest chunks after the checkpoint are compared again after resuming.
+	snapshot, err := report.GetSnapshot(&chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 5, ChunkCnt: 15}, "test", "tbl")
+	require.NoError(t, err)
+	require.Len(t, snapshot.TableResults["test"]["tbl"].ChunkMap, 6)
+	resumed := NewReport(task)
+	resumed.Init(tableDiffs, nil, nil)
+	resumed.LoadReport(snapshot)
+	for _, slowChunk := range resumed.SlowChunks {
+		require.LessOrEqual(t, slowChunk.ChunkID.ChunkIndex, 5)
+	}
+	require.Len(t, resumed.SlowChunks, 5)
+	require.Equal(t, 15*time.Millisecond, resumed.SlowChunks[0].ChecksumDuration)
+}
+
+func TestRecheckedChunks(t *testing.T) {
+	report := NewReport(task)
+	report.Init(nil, nil, nil)
+	report.AddRecheckedChunk(1, true)
+	report.AddRecheckedChunk(1, true)
+	report.AddRecheckedChunk(1, false)
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	require.Contains(t, sink.files["summary.txt"].String(), "Comparison Result\n\n\n\n"+
+		"0 succeeded, 0 mismatched, 0 errored\n\n"+
+		"3 failed chunks are rechecked, 1 are confirmed different and 2 are transient\n\n")
+
+	result := new(Report)
+	require.NoError(t, json.Unmarshal(sink.files["report.json"].Bytes(), result))
+	require.Equal(t, int64(1), result.ConfirmedChunks)
+	require.Equal(t, int64(2), result.TransientChunks)
+
+	// the counts are kept after resuming from the checkpoint.
+	snapshot, err := report.GetSnapshot(&chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1}, "test", "tbl")
+	require.NoError(t, err)
+	resumed := NewReport(task)
+	resumed.LoadReport(snapshot)
+	require.Equal(t, int64(2), resumed.TransientChunks)
+
+	// the transient chunks on each recheck are noted with more than one recheck.
+	resumed.AddRecheckedChunk(3, true)
+	require.Equal(t, []int64{2, 0, 1}, resumed.HealedOnRecheck)
+	require.Equal(t, "4 failed chunks are rechecked, 1 are confirmed different and 3 are transient (2, 0, 1 become equal on each recheck)",
+		resumed.recheckString())
+}
+
+func TestSkipReason(t *testing.T) {
+	report := NewReport(task)
+	createTableSQL := "create table `test`.`tbl`(`a` int, `b` varchar(10), primary key(`a`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+
+	tableDiffs := []*common.TableDiff{
+		{Schema: "test", Table: "tbl", Info: tableInfo},
+		{Schema: "xtest", Table: "tbl", Info: tableInfo},
+		{Schema: "ytest", Table: "tbl", Info: tableInfo, NoPKFallback: true},
+	}
+	report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	report.SetTableStructCheckResult("test", "tbl", true, false)
+	report.SetTableDataCheckResult("test", "tbl", true, 0, 0, &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1})
+	report.SetTableSkipReason("xtest", "tbl", "skipped: size 52GB > max 10GB")
+	report.SetTableStructCheckResult("xtest", "tbl", true, true)
+	report.SetTableSkipReason("ytest", "tbl", "skipped: size is 0 in the statistics")
+	report.SetTableStructCheckResult("ytest", "tbl", true, true)
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	require.Equal(t, Pass, report.Result)
+	summary := sink.files["summary.txt"].String()
+	require.Contains(t, summary, "The table structure and data in following tables are equivalent\n\n"+
+		"`test`.`tbl`\n\n"+
+		"The data check of the following tables is skipped\n\n"+
+		"`xtest`.`tbl` skipped: size 52GB > max 10GB\n"+
+		"`ytest`.`tbl` skipped: size is 0 in the statistics\n")
+	require.NotContains(t, summary, "no primary key or unique key")
+
+	result := new(Report)
+	require.NoError(t, json.Unmarshal(sink.files["report.json"].Bytes(), result))
+	require.Equal(t, "skipped: size 52GB > max 10GB", result.TableResults["xtest"]["tbl"].SkipReason)
+	require.True(t, result.TableResults["xtest"]["tbl"].DataSkip)
+}
+
+func TestResumeSkippedTables(t *testing.T) {
+	tableDiffs := []*common.TableDiff{
+		{Schema: "test", Table: "tbl"},
+		{Schema: "xtest", Table: "tbl"},
+		{Schema: "ytest", Table: "tbl"},
+	}
+	report := NewReport(task)
+	report.Init(tableDiffs, nil, nil)
+	report.SetTableStructCheckResult("ytest", "tbl", true, true)
+	report.SetTableSkipReason("ytest", "tbl", "skipped: size 52GB > max 10GB")
+	report.SetTableStructCheckResult("xtest", "tbl", true, false)
+	report.SetTableDataSkip("xtest", "tbl", ReplicationLagSkipReason)
+	report.SetTableStructCheckResult("test", "tbl", true, false)
+	chunkID := &chunk.ChunkID{TableIndex: 2, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1}
+	report.SetTableDataCheckResult("test", "tbl", true, 0, 0, chunkID)
+
+	resumed := NewReport(task)
+	resumed.Init(tableDiffs, nil, nil)
+	resumed.LoadReport(roundTrip(t, mustGetSnapshot(t, report, chunkID, "tbl")))
+	for _, schema := range []string{"xtest", "ytest"} {
+		expected, result := report.TableResults[schema]["tbl"], resumed.TableResults[schema]["tbl"]
+		require.Equal(t, expected.StructEqual, result.StructEqual, schema)
+		require.True(t, result.DataSkip, schema)
+		require.Equal(t, expected.DataEqual, result.DataEqual, schema)
+		require.Equal(t, expected.SkipReason, result.SkipReason, schema)
+		require.Empty(t, result.ChunkMap, schema)
+	}
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	resumed.SetSink(sink)
+	require.NoError(t, resumed.CommitSummary())
+	summary := sink.files["summary.txt"].String()
+	require.Contains(t, summary, "The table structure and data in following tables are equivalent\n\n"+
+		"`test`.`tbl`\n\n"+
+		"The data check of the following tables is skipped\n\n"+
+		"`xtest`.`tbl` replication lag\n"+
+		"`ytest`.`tbl` skipped: size 52GB > max 10GB\n")
+}
+
+func TestColumnsReordered(t *testing.T) {
+	report := NewReport(task)
+	createTableSQL := "create table `test`.`tbl`(`a` int, `b` varchar(10), primary key(`a`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+
+	tableDiffs := []*common.TableDiff{
+		{Schema: "test", Table: "tbl", Info: tableInfo},
+		{Schema: "xtest", Table: "tbl", Info: tableInfo},
+	}
+	report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	report.SetTableColumnsReordered("xtest", "tbl")
+	for _, tableDiff := range tableDiffs {
+		report.SetTableStructCheckResult(tableDiff.Schema, tableDiff.Table, true, false)
+		report.SetTableDataCheckResult(tableDiff.Schema, tableDiff.Table, true, 0, 0, &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1})
+	}
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	require.Equal(t, Pass, report.Result)
+	require.Contains(t, sink.files["summary.txt"].String(), "The table structure and data in following tables are equivalent\n\n"+
+		"`test`.`tbl`\n"+
+		"`xtest`.`tbl`\n\n"+
+		"The columns of the following tables are in different orders, and they are matched by name\n\n"+
+		"`xtest`.`tbl`\n")
+
+	result := new(Report)
+	require.NoError(t, json.Unmarshal(sink.files["report.json"].Bytes(), result))
+	require.False(t, result.TableResults["test"]["tbl"].ColumnsReordered)
+	require.True(t, result.TableResults["xtest"]["tbl"].ColumnsReordered)
+}
+
+func TestCountOnly(t *testing.T) {
+	report := NewReport(task)
+	createTableSQL := "create table `test`.`tbl`(`a` int, `b` varchar(10), primary key(`a`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+
+	tableDiffs := []*common.TableDiff{
+		{Schema: "test", Table: "tbl", Info: tableInfo},
+		{Schema: "xtest", Table: "tbl", Info: tableInfo},
+		{Schema: "ytest", Table: "tbl", Info: tableInfo},
+	}
+	report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	for _, tableDiff := range tableDiffs {
+		report.SetTableStructCheckResult(tableDiff.Schema, tableDiff.Table, true, false)
+	}
+	// fully verified
+	report.SetTableDataCheckResult("test", "tbl", true, 0, 0, &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1})
+	// count verified
+	report.SetTableCountCheckResult("xtest", "tbl", true, 0, 0, &chunk.ChunkID{TableIndex: 1, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1})
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	buf := new(bytes.Buffer)
+	report.Print(buf)
+	require.Contains(t, buf.String(), "A total of 3 table have been compared and all are equal.\n"+
+		"1 of them are only verified by the row count.\n")
+
+	report.SetTableCountCheckResult("ytest", "tbl", false, 3, 0, &chunk.ChunkID{TableIndex: 2, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1})
+	require.NoError(t, report.CommitSummary())
+	require.Equal(t, Fail, report.Result)
+	summary := sink.files["summary.txt"].String()
+	require.Contains(t, summary, "The table structure and data in following tables are equivalent\n\n"+
+		"`test`.`tbl`\n\n"+
+		"The row counts of following tables are equal, but the data is not compared by chunks\n\n"+
+		"`xtest`.`tbl`\n")
+	require.Contains(t, summary, "| `ytest`.`tbl` | true               | +3/-0 (by count) |")
+
+	buf = new(bytes.Buffer)
+	report.Print(buf)
+	require.Contains(t, buf.String(), "The row count of `ytest`.`tbl` is not equal\n")
+
+	result := new(Report)
+	require.NoError(t, json.Unmarshal(sink.files["report.json"].Bytes(), result))
+	require.False(t, result.TableResults["test"]["tbl"].CountOnly)
+	require.True(t, result.TableResults["ytest"]["tbl"].CountOnly)
+	require.Equal(t, 3, result.TableResults["ytest"]["tbl"].ChunkMap["v2:g2.g0.g0.g0.g1"].RowsAdd)
+}
+
+func TestCheckPath(t *testing.T) {
+	report := NewReport(task)
+	createTableSQL := "create table `test`.`tbl`(`a` int, `b` varchar(10), primary key(`a`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+
+	tableDiffs := []*common.TableDiff{
+		{Schema: "test", Table: "tbl", Info: tableInfo},
+		{Schema: "xtest", Table: "tbl", Info: tableInfo},
+	}
+	report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	for _, tableDiff := range tableDiffs {
+		report.SetTableStructCheckResult(tableDiff.Schema, tableDiff.Table, true, false)
+	}
+	report.SetTableCheckPath("test", "tbl", CheckPathChunks)
+	report.SetTableDataCheckResult("test", "tbl", true, 0, 0, &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1})
+	// verified by the checksum without chunks.
+	report.SetTableCheckPath("xtest", "tbl", CheckPathAdminChecksum)
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	require.Equal(t, Pass, report.Result)
+	buf := new(bytes.Buffer)
+	report.Print(buf)
+	require.Contains(t, buf.String(), "A total of 2 table have been compared and all are equal.\n"+
+		"1 of them are verified by ADMIN CHECKSUM TABLE.\n")
+	require.Contains(t, sink.files["summary.txt"].String(), "The following tables are verified by `ADMIN CHECKSUM TABLE`, and the data is not compared by chunks\n\n"+
+		"`xtest`.`tbl`\n")
+
+	result := new(Report)
+	require.NoError(t, json.Unmarshal(sink.files["report.json"].Bytes(), result))
+	require.Equal(t, CheckPathChunks, result.TableResults["test"]["tbl"].CheckPath)
+	require.Equal(t, CheckPathAdminChecksum, result.TableResults["xtest"]["tbl"].CheckPath)
+
+	// the path is kept in the checkpoint.
+	snapshot, err := report.GetSnapshot(&chunk.ChunkID{TableIndex: 1, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1}, "xtest", "tbl")
+	require.NoError(t, err)
+	require.Equal(t, CheckPathAdminChecksum, snapshot.TableResults["xtest"]["tbl"].CheckPath)
+}
+
+func TestSampledRun(t *testing.T) {
+	report := NewReport(task)
+	createTableSQL := "create table `test`.`tbl`(`a` int, `b` varchar(10), primary key(`a`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+
+	tableDiffs := []*common.TableDiff{{Schema: "test", Table: "tbl", Info: tableInfo}}
+	report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	report.SetTableStructCheckResult("test", "tbl", true, false)
+	report.SetSampling(0.5, 42)
+	report.AddSampledChunk("test", "tbl", true, false)
+	report.AddSampledChunk("test", "tbl", false, false)
+	report.AddSampledChunk("test", "tbl", false, false)
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	require.Equal(t, Pass, report.Result)
+	warning := "WARNING: this is a sampled run by sample-rate(0.5) and sample-seed(42), only 1 of 3 chunks are compared and 0 of them are different, it's NOT a full verification"
+	buf := new(bytes.Buffer)
+	report.Print(buf)
+	require.Contains(t, buf.String(), warning+".\n")
+	summary := sink.files["summary.txt"].String()
+	require.Contains(t, summary, "Comparison Result\n\n\n\n"+warning+"\n\n")
+	require.Contains(t, summary, "| `test`.`tbl` |            3 |              1 |               0 |")
+
+	// the coverage and the seed are kept in the checkpoint.
+	snapshot, err := report.GetSnapshot(&chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 2, ChunkCnt: 3}, "test", "tbl")
+	require.NoError(t, err)
+	require.Equal(t, int64(42), snapshot.SampleSeed)
+	require.Equal(t, 3, snapshot.TableResults["test"]["tbl"].ChunksTotal)
+	require.Equal(t, 1, snapshot.TableResults["test"]["tbl"].ChunksSampled)
+}
+
+func TestReplicationLag(t *testing.T) {
+	report := NewReport(task)
+	createTableSQL := "create table `test`.`tbl`(`a` int, `b` varchar(10), primary key(`a`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+
+	tableDiffs := []*common.TableDiff{{Schema: "test", Table: "tbl", Info: tableInfo}}
+	report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	report.SetTableStructCheckResult("test", "tbl", true, false)
+	report.SetReplicationPositions([]*utils.ReplicationPosition{
+		{Source: "mysql-replica-01", Goal: "(mysql-bin.000002, 100)", Checkpoint: "(mysql-bin.000001, 4000)"},
+	}, false)
+	report.SetTableDataSkip("test", "tbl", ReplicationLagSkipReason)
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	warning := "WARNING: the replication doesn't catch up within the timeout of wait-sync, the data check of 1 tables is skipped for the replication lag"
+	buf := new(bytes.Buffer)
+	report.Print(buf)
+	require.Contains(t, buf.String(), warning+".\n")
+	summary := sink.files["summary.txt"].String()
+	require.Contains(t, summary, warning+"\n\n")
+	require.Contains(t, summary, "| mysql-replica-01 | (mysql-bin.000002, 100) | (mysql-bin.000001, 4000) | false     |")
+	require.Contains(t, summary, "`test`.`tbl` replication lag\n")
+}
+
+func TestTranscodedColumns(t *testing.T) {
+	report := NewReport(task)
+	createTableSQL := "create table `test`.`tbl`(`a` int, `b` varchar(10), primary key(`a`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+
+	tableDiffs := []*common.TableDiff{{Schema: "test", Table: "tbl", Info: tableInfo}}
+	report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	report.SetTableStructCheckResult("test", "tbl", true, false)
+	report.SetTableTranscodedColumns("test", "tbl", []*TranscodedColumn{{Column: "b", From: "utf8mb4", To: "latin1"}})
+	report.AddTableUnmappableChars("test", "tbl", "b", []string{"😀", "中"})
+	report.AddTableUnmappableChars("test", "tbl", "b", []string{"中"})
+	// the counts are kept when the columns are set again after resuming.
+	report.SetTableTranscodedColumns("test", "tbl", []*TranscodedColumn{{Column: "b", From: "utf8mb4", To: "latin1"}})
+	require.Equal(t, []*TranscodedColumn{{Column: "b", From: "utf8mb4", To: "latin1", UnmappableValues: 2, UnmappableChars: []string{"😀", "中"}}},
+		report.TableResults["test"]["tbl"].TranscodedColumns)
+	report.SetTableDataCheckResult("test", "tbl", true, 0, 0, &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1})
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	buf := new(bytes.Buffer)
+	report.Print(buf)
+	require.Contains(t, buf.String(), "Warning: 2 source values have the characters which can't be mapped to the charsets of the target by charset-map.\n")
+	summary := sink.files["summary.txt"].String()
+	require.Contains(t, summary, "The source values of the following columns are transcoded by charset-map")
+	require.Contains(t, summary, "| `test`.`tbl`.`b` | utf8mb4 | latin1 |                 2 | \"😀\", \"中\"            |")
+}
+
+func TestFailFast(t *testing.T) {
+	report := NewReport(task)
+	createTableSQL := "create table `test`.`tbl`(`a` int, `b` varchar(10), primary key(`a`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+
+	tableDiffs := []*common.TableDiff{{Schema: "test", Table: "tbl", Info: tableInfo}, {Schema: "test", Table: "tbl2", Info: tableInfo}}
+	report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	report.SetFailFast(true)
+	report.SetTableStructCheckResult("test", "tbl", true, false)
+	report.SetTableDataCheckResult("test", "tbl", true, 0, 0, &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1})
+	require.False(t, report.IsFailedFast())
+	// the comparison is stopped by the first different table.
+	report.SetTableStructCheckResult("test", "tbl2", false, false)
+	require.True(t, report.IsFailedFast())
+	require.Equal(t, "`test`.`tbl2`", report.FailedFastTable)
+	report.SetTableDataCheckResult("test", "tbl", false, 1, 0, &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1})
+	require.Equal(t, "`test`.`tbl2`", report.FailedFastTable)
+	// the stop by fail-fast isn't an interruption.
+	report.SetInterrupted()
+	require.False(t, report.IsInterrupted())
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	buf := new(bytes.Buffer)
+	report.Print(buf)
+	require.Contains(t, buf.String(), "The comparison is stopped early by fail-fast after the table `test`.`tbl2` is found different, the results are partial.\n")
+	require.Contains(t, sink.files["summary.txt"].String(), "The comparison is stopped early by fail-fast after the table `test`.`tbl2` is found different, the results are partial, "+
+		"run it again without fail-fast to resume the full comparison from the checkpoint\n")
+}
+
+func TestCheckStructOnly(t *testing.T) {
+	report := NewReport(task)
+	createTableSQL := "create table `test`.`tbl`(`a` int, `b` varchar(10), primary key(`a`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+
+	tableDiffs := []*common.TableDiff{
+		{Schema: "test", Table: "tbl", Info: tableInfo},
+		{Schema: "xtest", Table: "tbl", Info: tableInfo},
+	}
+	report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	report.SetCheckStructOnly()
+	for _, tableDiff := range tableDiffs {
+		report.SetTableStructCheckResult(tableDiff.Schema, tableDiff.Table, true, true)
+	}
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	require.Equal(t, Pass, report.Result)
+	require.Equal(t, int32(2), report.PassNum)
+	buf := new(bytes.Buffer)
+	report.Print(buf)
+	require.Contains(t, buf.String(), "A total of 2 table have been compared and all are equal.\n"+
+		"Only the table structures are compared.\n")
+
+	schemaDiff := "--- source tbl\n+++ target tbl\n@@ -1,4 +1,4 @@\n CREATE TABLE `tbl` (\n   `a` int(11) NOT NULL,\n-  `b` varchar(10) DEFAULT NULL,\n+  `b` varchar(20) DEFAULT NULL,\n   PRIMARY KEY (`a`)\n"
+	report.SetTableStructCheckResult("xtest", "tbl", false, true)
+	report.SetTableSchemaDiff("xtest", "tbl", schemaDiff)
+	require.NoError(t, report.CommitSummary())
+	require.Equal(t, Fail, report.Result)
+	require.Equal(t, int32(1), report.PassNum)
+	summary := sink.files["summary.txt"].String()
+	require.Contains(t, summary, "The table structure in following tables are equivalent, and the data check is skipped by check-struct-only\n\n"+
+		"`test`.`tbl`\n\n"+
+		"The table structure in following tables are different\n\n"+
+		"`xtest`.`tbl`\n"+schemaDiff+"\n")
+	require.NotContains(t, summary, "The following tables contains inconsistent data")
+
+	buf = new(bytes.Buffer)
+	report.Print(buf)
+	require.Contains(t, buf.String(), "The structure of `xtest`.`tbl` is not equal, and data-check is skipped\n")
+	require.Contains(t, buf.String(), "Only the table structures are compared, and the data is not checked.\n")
+	require.NotContains(t, buf.String(), "The patch file has been generated")
+
+	result := new(Report)
+	require.NoError(t, json.Unmarshal(sink.files["report.json"].Bytes(), result))
+	require.True(t, result.CheckStructOnly)
+	require.True(t, result.TableResults["test"]["tbl"].DataSkip)
+	require.Empty(t, result.TableResults["test"]["tbl"].SchemaDiff)
+	require.Equal(t, schemaDiff, result.TableResults["xtest"]["tbl"].SchemaDiff)
+}
+
+func TestViewResults(t *testing.T) {
+	createTableSQL := "create table `test`.`tbl`(`a` int, `b` varchar(10), primary key(`a`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+	tableDiffs := []*common.TableDiff{{Schema: "test", Table: "tbl", Info: tableInfo}}
+
+	for _, checkViews := range []bool{false, true} {
+		report := NewReport(task)
+		report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+		if checkViews {
+			report.SetCheckViews()
+		}
+		report.SetTableStructCheckResult("test", "tbl", true, false)
+		report.SetTableDataCheckResult("test", "tbl", true, 0, 0, &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1})
+		report.AddViewResult("test", "v1", ViewEqual)
+		report.AddViewResult("test", "v2", ViewUnequal)
+		report.AddViewResult("test", "v3", ViewMissingOnTarget)
+		report.AddViewResult("test", "v4", ViewMissingOnSource)
+
+		sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+		report.SetSink(sink)
+		require.NoError(t, report.CommitSummary())
+		// the views are not counted as the tables.
+		require.Equal(t, int32(1), report.PassNum)
+		require.Equal(t, int32(0), report.FailedNum)
+		require.Contains(t, sink.files["summary.txt"].String(), "\nViews\n\n"+
+			"`test`.`v1` equal\n"+
+			"`test`.`v2` unequal\n"+
+			"`test`.`v3` missing-on-target\n"+
+			"`test`.`v4` missing-on-source\n")
+
+		buf := new(bytes.Buffer)
+		report.Print(buf)
+		if checkViews {
+			require.Equal(t, Fail, report.Result)
+			require.Contains(t, buf.String(), "The definition of view `test`.`v2` is not equal\n"+
+				"The view `test`.`v3` is missing on the target\n")
+		} else {
+			require.Equal(t, Pass, report.Result)
+		}
+
+		result := new(Report)
+		require.NoError(t, json.Unmarshal(sink.files["report.json"].Bytes(), result))
+		require.Equal(t, checkViews, result.CheckViews)
+		require.Len(t, result.ViewResults, 4)
+		require.Equal(t, ViewMissingOnTarget, result.ViewResults[2].Status)
+	}
+}
+
+func TestViewData(t *testing.T) {
+	tableDiffs := []*common.TableDiff{{Schema: "test", Table: "tbl"}, {Schema: "test", Table: "v1", IsView: true}, {Schema: "test", Table: "v2", IsView: true}}
+	report := NewReport(task)
+	report.Init(tableDiffs, nil, nil)
+	for i, tableDiff := range tableDiffs {
+		report.SetTableStructCheckResult("test", tableDiff.Table, true, false)
+		report.SetTableDataCheckResult("test", tableDiff.Table, tableDiff.Table != "v2", 1, 0, &chunk.ChunkID{TableIndex: i, ChunkCnt: 1})
+	}
+	report.AddViewResult("test", "v1", ViewEqual)
+	report.AddViewResult("test", "v2", ViewNotCompared)
+	report.AddViewResult("test", "v3", ViewNotCompared)
+	require.True(t, report.TableResults["test"]["v1"].IsView)
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	require.Equal(t, Fail, report.Result)
+	summary := sink.files["summary.txt"].String()
+	// the views are listed separately from the tables.
+	require.Contains(t, summary, "The table structure and data in following tables are equivalent\n\n`test`.`tbl`\n\n")
+	require.NotContains(t, summary, "The following tables contains inconsistent data")
+	require.Contains(t, summary, "\nThe following views contain inconsistent data\n\n")
+	require.Regexp(t, "`test`.`v2` +\\| true +\\| \\+1/-0", summary)
+	require.Contains(t, summary, "\nViews\n\n"+
+		"`test`.`v1` equal, data equal\n"+
+		"`test`.`v2` not-compared, data unequal\n"+
+		"`test`.`v3` not-compared\n")
+
+	result := new(Report)
+	require.NoError(t, json.Unmarshal(sink.files["report.json"].Bytes(), result))
+	require.True(t, result.TableResults["test"]["v2"].IsView)
+	require.False(t, result.TableResults["test"]["tbl"].IsView)
+}
+
+func TestMissingTables(t *testing.T) {
+	createTableSQL := "create table `test`.`tbl`(`a` int, `b` varchar(10), primary key(`a`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+	tableDiffs := []*common.TableDiff{{Schema: "test", Table: "tbl", Info: tableInfo}}
+
+	for _, failOnMissingTables := range []bool{false, true} {
+		report := NewReport(task)
+		report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+		if failOnMissingTables {
+			report.SetFailOnMissingTables()
+		}
+		report.AddMissingTable("test", "only_source", MissingOnTarget)
+		report.AddMissingTable("test", "only_target", MissingOnSource)
+		report.SetTableStructCheckResult("test", "tbl", true, false)
+		report.SetTableDataCheckResult("test", "tbl", true, 0, 0, &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1})
+
+		sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+		report.SetSink(sink)
+		require.NoError(t, report.CommitSummary())
+		require.Equal(t, int32(1), report.PassNum)
+		require.Contains(t, sink.files["summary.txt"].String(), "\nThe following tables only exist on one side, and they are not compared\n\n"+
+			"`test`.`only_source` missing on the target\n"+
+			"`test`.`only_target` missing on the source\n")
+
+		buf := new(bytes.Buffer)
+		report.Print(buf)
+		if failOnMissingTables {
+			require.Equal(t, Fail, report.Result)
+			require.Contains(t, buf.String(), "The table `test`.`only_source` is missing on the target\n"+
+				"The table `test`.`only_target` is missing on the source\n")
+		} else {
+			require.Equal(t, Pass, report.Result)
+			require.Contains(t, buf.String(), "A total of 1 table have been compared and all are equal.\n"+
+				"2 table only exist on one side, and they are not compared.\n")
+		}
+
+		result := new(Report)
+		require.NoError(t, json.Unmarshal(sink.files["report.json"].Bytes(), result))
+		require.Equal(t, []*MissingTable{
+			{Schema: "test", Table: "only_source", MissingOn: MissingOnTarget},
+			{Schema: "test", Table: "only_target", MissingOn: MissingOnSource},
+		}, result.MissingTables)
+	}
+}
+
+func TestSkippedObjects(t *testing.T) {
+	createTableSQL := "create table `test`.`tbl`(`a` int, `b` varchar(10), primary key(`a`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+	tableDiffs := []*common.TableDiff{{Schema: "test", Table: "tbl", Info: tableInfo}}
+
+	report := NewReport(task)
+	report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	skippedObjects := []*SkippedObject{
+		{Schema: "test", Table: "fed", On: ObjectOnTarget, Type: "BASE TABLE", Engine: "FEDERATED", Reason: "the rows of FEDERATED table are on the remote server"},
+		{Schema: "test", Table: "seq", On: ObjectOnSource, Type: "SEQUENCE", Reason: "sequence can't be compared"},
+	}
+	for _, object := range skippedObjects {
+		report.AddSkippedObject(object)
+	}
+	report.SetTableStructCheckResult("test", "tbl", true, false)
+	report.SetTableDataCheckResult("test", "tbl", true, 0, 0, &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1})
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	// the skipped objects don't cause Fail.
+	require.Equal(t, Pass, report.Result)
+	require.Contains(t, sink.files["summary.txt"].String(), "\nSkipped objects\n\n"+
+		"`test`.`fed` on the target (BASE TABLE): the rows of FEDERATED table are on the remote server\n"+
+		"`test`.`seq` on the source (SEQUENCE): sequence can't be compared\n")
+
+	buf := new(bytes.Buffer)
+	report.Print(buf)
+	require.Contains(t, buf.String(), "A total of 1 table have been compared and all are equal.\n"+
+		"2 object can't be compared, and they are skipped.\n")
+
+	result := new(Report)
+	require.NoError(t, json.Unmarshal(sink.files["report.json"].Bytes(), result))
+	require.Equal(t, skippedObjects, result.SkippedObjects)
+}
+
+func TestColumnTransforms(t *testing.T) {
+	createTableSQL := "create table `test`.`tbl`(`a` int, `b` varchar(10), `c` varchar(20), primary key(`a`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+	tableDiffs := []*common.TableDiff{{Schema: "test", Table: "tbl", Info: tableInfo, ColumnTransforms: map[string]string{"c": "mask_last4", "b": "lower"}}}
+
+	report := NewReport(task)
+	report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	report.SetTableStructCheckResult("test", "tbl", true, false)
+	report.SetTableDataCheckResult("test", "tbl", true, 0, 0, &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1})
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	require.Contains(t, sink.files["summary.txt"].String(), "\nThe following column transforms are applied to the source values before comparison\n\n"+
+		"`test`.`tbl`.`b` lower\n"+
+		"`test`.`tbl`.`c` mask_last4\n")
+
+	result := new(Report)
+	require.NoError(t, json.Unmarshal(sink.files["report.json"].Bytes(), result))
+	require.Equal(t, map[string]string{"b": "lower", "c": "mask_last4"}, result.TableResults["test"]["tbl"].ColumnTransforms)
+}
+
+func TestTrimmedColumns(t *testing.T) {
+	createTableSQL := "create table `test`.`tbl`(`a` int, `b` varchar(10), `c` char(20), `d` binary(10), primary key(`a`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+	tableDiffs := []*common.TableDiff{{Schema: "test", Table: "tbl", Info: tableInfo, TrimmedColumns: []string{"c", "b"}}}
+
+	report := NewReport(task)
+	report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	report.SetTableStructCheckResult("test", "tbl", true, false)
+	report.SetTableDataCheckResult("test", "tbl", true, 0, 0, &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1})
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	require.Contains(t, sink.files["summary.txt"].String(), "\nThe trailing spaces of the following columns are ignored by trim-char-padding\n\n"+
+		"`test`.`tbl`.`b`\n"+
+		"`test`.`tbl`.`c`\n")
+
+	result := new(Report)
+	require.NoError(t, json.Unmarshal(sink.files["report.json"].Bytes(), result))
+	require.Equal(t, []string{"c", "b"}, result.TableResults["test"]["tbl"].TrimmedColumns)
+}
+
+func TestResumeDuration(t *testing.T) {
+	now := time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC)
+	nowFunc = func() time.Time { return now }
+	defer func() { nowFunc = time.Now }()
+
+	tableDiffs := []*common.TableDiff{{Schema: "test", Table: "t2"}, {Schema: "test", Table: "t1"}}
+	chunkID := func(tableIndex int) *chunk.ChunkID {
+		return &chunk.ChunkID{TableIndex: tableIndex, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1}
+	}
+	report := NewReport(task)
+	report.Init(tableDiffs, nil, nil)
+	report.SetTableStructCheckResult("test", "t2", true, false)
+	report.SetTableDataCheckResult("test", "t2", true, 0, 0, chunkID(0))
+	now = now.Add(10 * time.Second)
+	checkpoint := roundTrip(t, mustGetSnapshot(t, report, chunkID(0), "t2"))
+	require.Equal(t, 10*time.Second, checkpoint.Duration)
+	// the run is interrupted 5s after the checkpoint, and resumed after 1m.
+	now = now.Add(5 * time.Second).Add(time.Minute)
+
+	report = NewReport(task)
+	report.Init(tableDiffs, nil, nil)
+	report.LoadReport(checkpoint)
+	require.Equal(t, 10*time.Second, report.TotalDuration())
+	now = now.Add(20 * time.Second)
+	report.SetTableStructCheckResult("test", "t1", true, false)
+	report.SetTableDataCheckResult("test", "t1", true, 0, 0, chunkID(1))
+	checkpoint = roundTrip(t, mustGetSnapshot(t, report, chunkID(1), "t1"))
+	require.Equal(t, 30*time.Second, checkpoint.Duration)
+	require.Equal(t, 10*time.Second, checkpoint.ElapsedBeforeResume)
+	require.Equal(t, 30*time.Second, roundTrip(t, report.Snapshot()).Duration)
+	// the run is interrupted again 3s after the checkpoint, and resumed after 1h.
+	now = now.Add(3 * time.Second).Add(time.Hour)
+
+	report = NewReport(task)
+	report.Init(tableDiffs, nil, nil)
+	report.LoadReport(checkpoint)
+	report.TotalSize = 70 * 1024 * 1024
+	now = now.Add(40 * time.Second)
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	// neither the downtime nor the time after the checkpoints is counted.
+	total := report.TotalDuration()
+	require.True(t, total >= 70*time.Second && total <= 78*time.Second, total)
+	summary := sink.files["summary.txt"].String()
+	require.Contains(t, summary, "Time Cost: 1m10s\n")
+	require.Contains(t, summary, "Total Size: 70MB (estimated by the table statistics)\nTime Cost: 1m10s\nAverage Speed: 1MB/s (estimated by the total size)\n")
+
+	// the sizes are written in bytes with raw-units.
+	report.SetRawUnits(true)
+	require.NoError(t, report.CommitSummary())
+	summary = sink.files["summary.txt"].String()
+	require.Contains(t, summary, "Total Size: 73400320B (estimated by the table statistics)\n")
+	require.Contains(t, summary, "Average Speed: 1048576B/s (estimated by the total size)\n")
+	result := new(Report)
+	require.NoError(t, json.Unmarshal(sink.files["report.json"].Bytes(), result))
+	require.Equal(t, 70*time.Second, result.Duration)
+	require.Equal(t, 30*time.Second, result.ElapsedBeforeResume)
+}
+
+func TestTimedOut(t *testing.T) {
+	tableDiffs := []*common.TableDiff{{Schema: "test", Table: "t1"}, {Schema: "test", Table: "t2"}}
+	report := NewReport(task)
+	report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	report.SetTableStructCheckResult("test", "t1", true, false)
+	report.SetTableDataCheckResult("test", "t1", false, 1, 0, &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1})
+	report.SetInterrupted()
+	report.SetTimedOut(2 * time.Hour)
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	summary := sink.files["summary.txt"].String()
+	require.Contains(t, summary, "The comparison is truncated by run-timeout(2h0m0s), the results are incomplete without a pass or fail verdict, run it again to resume from the checkpoint\n")
+	require.NotContains(t, summary, "The comparison is interrupted")
+
+	// no verdict is printed even if some tables are different.
+	buf := new(bytes.Buffer)
+	report.Print(buf)
+	require.Equal(t, "The comparison is truncated by run-timeout(2h0m0s), the results are incomplete without a pass or fail verdict, run it again to resume from the checkpoint.\n"+
+		"1 succeeded, 1 mismatched, 0 errored so far.\n"+
+		"You can view the comparision details through 'output_dir/sync_diff.log'\n", buf.String())
+
+	result := new(Report)
+	require.NoError(t, json.Unmarshal(sink.files["report.json"].Bytes(), result))
+	require.True(t, result.TimedOut)
+	require.Equal(t, 2*time.Hour, result.RunTimeout)
+}
+
+func TestReorderedEnumColumns(t *testing.T) {
+	tableDiffs := []*common.TableDiff{{Schema: "test", Table: "tbl"}}
+	report := NewReport(task)
+	report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	report.SetTableStructCheckResult("test", "tbl", true, false)
+	report.SetTableReorderedEnumColumns("test", "tbl", []string{"c", "b"})
+	report.SetTableDataCheckResult("test", "tbl", true, 0, 0, &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1})
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	require.Contains(t, sink.files["summary.txt"].String(), "\nThe members of the following ENUM and SET columns are in different orders, and they are compared by value\n\n"+
+		"`test`.`tbl`.`b`\n"+
+		"`test`.`tbl`.`c`\n")
+
+	result := new(Report)
+	require.NoError(t, json.Unmarshal(sink.files["report.json"].Bytes(), result))
+	require.Equal(t, []string{"c", "b"}, result.TableResults["test"]["tbl"].ReorderedEnumColumns)
+}
+
+func TestGeometryColumns(t *testing.T) {
+	tableDiffs := []*common.TableDiff{{Schema: "test", Table: "tbl", GeometryColumns: []string{"c", "b"}}}
+	report := NewReport(task)
+	report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	report.SetTableStructCheckResult("test", "tbl", true, false)
+	report.AddTableSRIDMismatchColumns("test", "tbl", []string{"c"})
+	report.AddTableSRIDMismatchColumns("test", "tbl", []string{"c"})
+	report.SetTableDataCheckResult("test", "tbl", false, 1, 1, &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1})
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	summary := sink.files["summary.txt"].String()
+	require.Contains(t, summary, "\nThe following GEOMETRY columns are compared by the SRID and the WKB\n\n"+
+		"`test`.`tbl`.`b`\n"+
+		"`test`.`tbl`.`c`\n")
+	require.Contains(t, summary, "\nWarning: the values of the following GEOMETRY columns have the same WKB but the different SRIDs on both sides\n\n"+
+		"`test`.`tbl`.`c`\n")
+
+	result := new(Report)
+	require.NoError(t, json.Unmarshal(sink.files["report.json"].Bytes(), result))
+	require.Equal(t, []string{"c", "b"}, result.TableResults["test"]["tbl"].GeometryColumns)
+	require.Equal(t, []string{"c"}, result.TableResults["test"]["tbl"].SRIDMismatchColumns)
+}
+
+func TestDuplicateKeys(t *testing.T) {
+	tableDiffs := []*common.TableDiff{{Schema: "test", Table: "tbl"}, {Schema: "test", Table: "other"}}
+	report := NewReport(task)
+	report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	report.SetTableStructCheckResult("test", "tbl", true, false)
+	report.SetTableStructCheckResult("test", "other", true, false)
+	report.SetTableDuplicateKeys("test", "tbl", []string{"a", "b"},
+		[]*utils.DuplicateKey{{Values: []string{"1", "x"}, Rows: 2}},
+		[]*utils.DuplicateKey{{Values: []string{"2", "y"}, Rows: 3}, {Values: []string{"3", "z"}, Rows: 2}})
+	report.SetTableMeetError("test", "tbl", errors.New("the unique key (`a`, `b`) has duplicate values"), nil, "")
+	report.SetTableDataCheckResult("test", "other", true, 0, 0, &chunk.ChunkID{TableIndex: 1, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1})
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	require.Equal(t, Error, report.Result)
+	summary := sink.files["summary.txt"].String()
+	require.Contains(t, summary, "\nThe unique keys of the following tables have duplicate values, and the data check of them is skipped\n\n")
+	require.Regexp(t, "`test`.`tbl` +\\| +source +\\| +\\(`a`, `b`\\) +\\| +\\(1, x\\) +\\| +2", summary)
+	require.Regexp(t, "`test`.`tbl` +\\| +target +\\| +\\(`a`, `b`\\) +\\| +\\(3, z\\) +\\| +2", summary)
+	require.NotRegexp(t, "`test`.`other` +\\|", summary)
+
+	result := new(Report)
+	require.NoError(t, json.Unmarshal(sink.files["report.json"].Bytes(), result))
+	require.Equal(t, []string{"a", "b"}, result.TableResults["test"]["tbl"].DuplicateKeyColumns)
+	require.Len(t, result.TableResults["test"]["tbl"].TargetDuplicateKeys, 2)
+	require.Equal(t, int64(3), result.TableResults["test"]["tbl"].TargetDuplicateKeys[0].Rows)
+	require.Empty(t, result.TableResults["test"]["other"].DuplicateKeyColumns)
+
+	// the duplicate keys are kept in the checkpoint.
+	snapshot, err := report.GetSnapshot(&chunk.ChunkID{TableIndex: 1, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1}, "test", "other")
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, snapshot.TableResults["test"]["tbl"].DuplicateKeyColumns)
+	require.Len(t, snapshot.TableResults["test"]["tbl"].SourceDuplicateKeys, 1)
+}
+
+func TestIndexResults(t *testing.T) {
+	tableInfo, err := dbutil.GetTableInfoBySQL("create table `test`.`tbl`(`a` int primary key, `b` int, `c` int, key `idx_b`(`b`), key `idx_c`(`c`))", parser.New())
+	require.NoError(t, err)
+	tableDiffs := []*common.TableDiff{
+		{Schema: "test", Table: "tbl", Info: tableInfo, CheckIndices: []*model.IndexInfo{utils.FindIndexByName(tableInfo, "idx_b"), utils.FindIndexByName(tableInfo, "idx_c")}},
+		{Schema: "test", Table: "other"},
+	}
+	report := NewReport(task)
+	report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	report.SetTableStructCheckResult("test", "tbl", true, false)
+	report.SetTableStructCheckResult("test", "other", true, false)
+	id := &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 1, ChunkCnt: 2}
+	report.SetTableDataCheckResult("test", "tbl", true, 0, 0, id)
+	report.AddTableIndexMismatch("test", "tbl", "idx_c", id)
+	// the chunk is compared again after resuming.
+	report.AddTableIndexMismatch("test", "tbl", "idx_c", id)
+	report.SetTableDataCheckResult("test", "other", true, 0, 0, &chunk.ChunkID{TableIndex: 1, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1})
+
+	snapshot, err := report.GetSnapshot(id, "test", "tbl")
+	require.NoError(t, err)
+	require.Equal(t, []string{id.Encode()}, snapshot.TableResults["test"]["tbl"].IndexResults["idx_c"].MismatchChunks)
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	// the data is equal, but the table fails.
+	require.Equal(t, Fail, report.Result)
+	require.Equal(t, int32(1), report.FailedNum)
+	summary := sink.files["summary.txt"].String()
+	require.Contains(t, summary, "\nThe following indices are inconsistent with the data, the fix sql isn't applicable to them, check them by `ADMIN CHECK INDEX` and rebuild them if needed\n\n")
+	require.Regexp(t, "`test`.`tbl` +\\| +idx_c +\\| +1 +\\| +ADMIN CHECK INDEX `test`.`tbl` `idx_c`", summary)
+	require.NotContains(t, summary, "idx_b |")
+
+	result := new(Report)
+	require.NoError(t, json.Unmarshal(sink.files["report.json"].Bytes(), result))
+	require.Len(t, result.TableResults["test"]["tbl"].IndexResults, 2)
+	require.Empty(t, result.TableResults["test"]["tbl"].IndexResults["idx_b"].MismatchChunks)
+	require.Empty(t, result.TableResults["test"]["other"].IndexResults)
+
+	// the chunk ids saved by the old versions are converted after resuming, so the same chunk isn't added twice.
+	resumed := NewReport(task)
+	resumed.Init(tableDiffs, nil, nil)
+	resumed.LoadReport(&Report{TableResults: map[string]map[string]*TableResult{"test": {"tbl": {
+		Schema: "test", Table: "tbl", ErrorMessage: "456", ErrorChunk: id.ToString(),
+		IndexResults: map[string]*IndexResult{"idx_c": {MismatchChunks: []string{id.ToString()}}},
+	}}}})
+	resumed.AddTableIndexMismatch("test", "tbl", "idx_c", id)
+	require.Equal(t, []string{id.Encode()}, resumed.TableResults["test"]["tbl"].IndexResults["idx_c"].MismatchChunks)
+	require.Equal(t, id.Encode(), resumed.TableResults["test"]["tbl"].ErrorChunk)
+}
+
+func TestStructChanges(t *testing.T) {
+	tableDiffs := []*common.TableDiff{{Schema: "test", Table: "tbl"}, {Schema: "test", Table: "other"}}
+	report := NewReport(task)
+	report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	report.SetTableStructCheckResult("test", "tbl", false, true)
+	report.SetTableStructDiff("test", "tbl", StructDiffBreaking)
+	report.SetTableStructChanges("test", "tbl", []*utils.StructChange{
+		{Kind: "column", Name: "b", Change: utils.StructChangeChanged, Source: "varchar(10) NULL", Target: "varchar(20) NOT NULL"},
+		{Kind: "index", Name: "idx_c", Change: utils.StructChangeRemoved, Source: "KEY (`c`)"},
+	})
+	report.SetTableStructCheckResult("test", "other", true, false)
+
+	snapshot, err := report.GetSnapshot(&chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1}, "test", "tbl")
+	require.NoError(t, err)
+	require.Len(t, snapshot.TableResults["test"]["tbl"].StructChanges, 2)
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	require.Contains(t, sink.files["summary.txt"].String(), "\nThe structures of the following tables are different\n\n"+
+		"`test`.`tbl`\n"+
+		"  changed column `b`: varchar(10) NULL -> varchar(20) NOT NULL\n"+
+		"  removed index `idx_c`: KEY (`c`)\n")
+
+	result := new(Report)
+	require.NoError(t, json.Unmarshal(sink.files["report.json"].Bytes(), result))
+	require.Equal(t, "varchar(20) NOT NULL", result.TableResults["test"]["tbl"].StructChanges[0].Target)
+	require.Empty(t, result.TableResults["test"]["other"].StructChanges)
+}
+
+func TestStructFix(t *testing.T) {
+	tableDiffs := []*common.TableDiff{{Schema: "test", Table: "tbl"}, {Schema: "test", Table: "other"}}
+	report := NewReport(task)
+	report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	report.SetTableStructCheckResult("test", "tbl", false, true)
+	report.SetTableStructFix("test", "tbl", []*utils.StructFixStatement{
+		{SQL: "ALTER TABLE `test`.`tbl` DROP COLUMN `c`;", Warning: "the column `c` and its data are dropped"},
+		{SQL: "ALTER TABLE `test`.`tbl` ADD KEY `idx_b` (`b`);"},
+	})
+	report.SetTableStructCheckResult("test", "other", true, false)
+	report.AddMissingTable("test", "a_only_target", "source")
+	report.SetMissingTableStructFix("test", "a_only_target", utils.GenerateDropTableFix("test", "a_only_target"))
+
+	snapshot, err := report.GetSnapshot(&chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1}, "test", "tbl")
+	require.NoError(t, err)
+	require.Len(t, snapshot.TableResults["test"]["tbl"].StructFix, 2)
+
+	fixes := report.GetStructFixes()
+	require.Len(t, fixes, 2)
+	require.Equal(t, "a_only_target", fixes[0].Table)
+	require.Equal(t, "DROP TABLE `test`.`a_only_target`;", fixes[0].Statements[0].SQL)
+	require.Equal(t, "tbl", fixes[1].Table)
+
+	report.SetStructFixFile("/tmp/output/fix-on-tidb/struct_fix.sql")
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	summary := sink.files["summary.txt"].String()
+	require.Contains(t, summary, "\nThe statements to make the structures of the target match the source have been written to '/tmp/output/fix-on-tidb/struct_fix.sql', which are not applied, please review them before applying them manually\n\n")
+	require.Regexp(t, "`test`.`a_only_target` +\\| +1 +\\| +1 ", summary)
+	require.Regexp(t, "`test`.`tbl` +\\| +2 +\\| +1 ", summary)
+	require.NotContains(t, summary, "`test`.`other` ")
+
+	result := new(Report)
+	require.NoError(t, json.Unmarshal(sink.files["report.json"].Bytes(), result))
+	require.Equal(t, "/tmp/output/fix-on-tidb/struct_fix.sql", result.StructFixFile)
+	require.Equal(t, "the column `c` and its data are dropped", result.TableResults["test"]["tbl"].StructFix[0].Warning)
+	require.Empty(t, result.TableResults["test"]["other"].StructFix)
+	require.Equal(t, "DROP TABLE `test`.`a_only_target`;", result.MissingTables[0].StructFix.SQL)
+}
+
+func TestSetUnregisteredTables(t *testing.T) {
+	report := NewReport(task)
+	report.Init([]*common.TableDiff{{Schema: "test", Table: "t0"}}, nil, nil)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 100; i++ {
+		wg.Add(3)
+		table := fmt.Sprintf("t%d", i%10)
+		id := &chunk.ChunkID{TableIndex: i % 10, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: i / 10, ChunkCnt: 10}
+		go func() {
+			defer wg.Done()
+			report.SetTableMeetError("other", table, errors.New("meet error"), nil, "")
+		}()
+		go func() {
+			defer wg.Done()
+			report.SetTableStructCheckResult("other", table, true, false)
+		}()
+		go func() {
+			defer wg.Done()
+			report.SetTableDataCheckResult("other", table, false, 1, 0, id)
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, Error, report.Result)
+	require.Len(t, report.TableResults["test"], 1)
+	require.Len(t, report.TableResults["other"], 10)
+	for i := 0; i < 10; i++ {
+		result := report.TableResults["other"][fmt.Sprintf("t%d", i)]
+		require.Equal(t, "other", result.Schema)
+		require.Equal(t, fmt.Sprintf("t%d", i), result.Table)
+		require.True(t, result.StructEqual)
+		require.False(t, result.DataEqual)
+		require.Error(t, result.MeetError)
+		require.Len(t, result.ChunkMap, 10)
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	tableDiffs := []*common.TableDiff{{Schema: "test", Table: "t1"}, {Schema: "test", Table: "t2"}}
+	report := NewReport(task)
+	report.Init(tableDiffs, nil, nil)
+
+	report.StartChunk("test", "t1", false)
+	report.StartChunk("test", "t1", true)
+	report.FinishChunk("test", "t1")
+	report.SetTableDataCheckResult("test", "t1", false, 2, 1, &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 1, ChunkCnt: 2})
+	report.StartChunk("test", "t2", false)
+	// the table is not completed until all its chunks are compared.
+	require.Equal(t, &Heartbeat{CompletedTables: 0, TotalTables: 2, CompletedChunks: 1, CurrentTable: "`test`.`t2`", RowsAdd: 2, RowsDelete: 1}, report.GetHeartbeat())
+
+	report.FinishChunk("test", "t1")
+	report.FinishChunk("test", "t2")
+	require.Equal(t, &Heartbeat{CompletedTables: 1, TotalTables: 2, CompletedChunks: 3, CurrentTable: "`test`.`t2`", RowsAdd: 2, RowsDelete: 1}, report.GetHeartbeat())
+}
+
+func TestPartitionDiffRows(t *testing.T) {
+	tableDiffs := []*common.TableDiff{{Schema: "test", Table: "tbl", SplitByPartition: true}}
+	report := NewReport(task)
+	report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	report.SetTableStructCheckResult("test", "tbl", true, false)
+	for i, partition := range []string{"p1", "p0", "p1"} {
+		id := &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: i, BucketIndexRight: i, ChunkIndex: 0, ChunkCnt: 1}
+		report.SetTableDataCheckResult("test", "tbl", false, i+1, 1, id)
+		report.SetChunkPartition("test", "tbl", id, partition)
+	}
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	summary := sink.files["summary.txt"].String()
+	require.Contains(t, summary, "\nThe following partitions contains inconsistent data\n\n")
+	require.Regexp(t, "`test`.`tbl` PARTITION `p0` +\\| \\+2/-1", summary)
+	require.Regexp(t, "`test`.`tbl` PARTITION `p1` +\\| \\+4/-2", summary)
+
+	result := new(Report)
+	require.NoError(t, json.Unmarshal(sink.files["report.json"].Bytes(), result))
+	require.Equal(t, "p0", result.TableResults["test"]["tbl"].ChunkMap["v2:g0.g1.g0.g1.g1"].Partition)
+}
+
+func TestSnapshotResults(t *testing.T) {
+	tableDiffs := []*common.TableDiff{{Schema: "test", Table: "tbl", ColumnTransforms: map[string]string{"b": "lower"}}}
+	report := NewReport(task)
+	report.Init(tableDiffs, nil, nil)
+	report.SetTableStructCheckResult("test", "tbl", true, false)
+	id := &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 2}
+	report.SetTableDataCheckResult("test", "tbl", false, 1, 2, id)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		// the results are written while they are inspected.
+		for i := 0; i < 100; i++ {
+			report.SetTableDataCheckResult("test", "tbl", false, 1, 0, &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 1, ChunkCnt: 2})
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		results := report.SnapshotResults()
+		require.Equal(t, 1, results["test"]["tbl"].ChunkMap[id.Encode()].RowsAdd)
+	}
+	wg.Wait()
+
+	results := report.SnapshotResults()
+	require.Equal(t, 100, results["test"]["tbl"].ChunkMap["v2:g0.g0.g1.g0.g2"].RowsAdd)
+	// the snapshot is not shared with the report.
+	results["test"]["tbl"].DataEqual = true
+	results["test"]["tbl"].ChunkMap[id.Encode()].RowsAdd = 10
+	results["test"]["tbl"].ColumnTransforms["b"] = "trim"
+	delete(results["test"], "tbl")
+	result := report.TableResults["test"]["tbl"]
+	require.False(t, result.DataEqual)
+	require.Equal(t, 1, result.ChunkMap[id.Encode()].RowsAdd)
+	require.Equal(t, "lower", result.ColumnTransforms["b"])
+}
+
+func TestStaleStats(t *testing.T) {
+	createTableSQL := "create table `test`.`tbl`(`a` int, `b` varchar(10), primary key(`a`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+	tableDiffs := []*common.TableDiff{
+		{Schema: "test", Table: "stale", Info: tableInfo},
+		{Schema: "test", Table: "empty", Info: tableInfo},
+		{Schema: "test", Table: "fresh", Info: tableInfo},
+		{Schema: "test", Table: "small", Info: tableInfo},
+		{Schema: "test", Table: "unknown", Info: tableInfo},
+	}
+
+	report := NewReport(task)
+	report.SetRowsEstimateWarnFactor(2)
+	report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	for i, tableDiff := range tableDiffs {
+		report.SetTableStructCheckResult("test", tableDiff.Table, true, false)
+		report.SetTableDataCheckResult("test", tableDiff.Table, true, 0, 0, &chunk.ChunkID{TableIndex: i, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1})
+	}
+	report.SetTableEstimatedRows("test", "stale", 1000)
+	report.AddTableActualRows("test", "stale", 2000)
+	report.AddTableActualRows("test", "stale", 1000)
+	// the table is not analyzed.
+	report.SetTableEstimatedRows("test", "empty", 0)
+	report.AddTableActualRows("test", "empty", 5000)
+	report.SetTableEstimatedRows("test", "fresh", 9000)
+	report.AddTableActualRows("test", "fresh", 10000)
+	report.SetTableEstimatedRows("test", "small", 10)
+	report.AddTableActualRows("test", "small", 100)
+	report.AddTableActualRows("test", "unknown", 10000)
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	// the stale statistics don't cause Fail.
+	require.Equal(t, Pass, report.Result)
+	summary := sink.files["summary.txt"].String()
+	require.Contains(t, summary, "\nWarning: the estimated row counts of the following tables diverge from the actual rows by more than 2 times, "+
+		"the statistics may be stale, run `ANALYZE TABLE` on them to update the statistics\n\n")
+	require.Regexp(t, "`test`.`empty` +\\| +0 +\\| +5000", summary)
+	require.Regexp(t, "`test`.`stale` +\\| +1000 +\\| +3000", summary)
+	require.NotContains(t, summary, "`test`.`fresh` ")
+	require.NotContains(t, summary, "`test`.`small` ")
+	require.NotContains(t, summary, "`test`.`unknown` ")
+
+	buf := new(bytes.Buffer)
+	report.Print(buf)
+	require.Contains(t, buf.String(), "2 table may have the stale statistics, run `ANALYZE TABLE` on them.\n")
+
+	result := new(Report)
+	require.NoError(t, json.Unmarshal(sink.files["report.json"].Bytes(), result))
+	require.Equal(t, int64(1000), *result.TableResults["test"]["stale"].EstimatedRows)
+	require.Equal(t, int64(3000), result.TableResults["test"]["stale"].ActualRows)
+	require.Equal(t, int64(0), *result.TableResults["test"]["empty"].EstimatedRows)
+	require.Nil(t, result.TableResults["test"]["unknown"].EstimatedRows)
+
+	// no warning if the factor is 0.
+	report.SetRowsEstimateWarnFactor(0)
+	require.Empty(t, report.getStaleStatsRows())
+}
+
+func TestRowCountDelta(t *testing.T) {
+	createTableSQL := "create table `test`.`tbl`(`a` int, `b` varchar(10), primary key(`a`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+	tableDiffs := []*common.TableDiff{
+		{Schema: "test", Table: "equal", Info: tableInfo},
+		{Schema: "test", Table: "less", Info: tableInfo},
+		{Schema: "test", Table: "more", Info: tableInfo},
+		{Schema: "test", Table: "unknown", Info: tableInfo},
+	}
+
+	report := NewReport(task)
+	report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	for i, tableDiff := range tableDiffs {
+		report.SetTableStructCheckResult("test", tableDiff.Table, true, false)
+		report.SetTableDataCheckResult("test", tableDiff.Table, true, 0, 0, &chunk.ChunkID{TableIndex: i, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1})
+	}
+	report.SetTableRowCounts("test", "equal", 100, 100)
+	report.SetTableRowCounts("test", "less", 100, 90)
+	report.SetTableRowCounts("test", "more", 1000, 1200)
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	summary := sink.files["summary.txt"].String()
+	require.Contains(t, summary, "\nWarning: the row counts of the following tables are different on both sides by count-precheck, "+
+		"the delta is the target rows minus the source rows\n\n")
+	require.Regexp(t, "`test`.`less` +\\| +100 +\\| +90 +\\| +-10", summary)
+	require.Regexp(t, "`test`.`more` +\\| +1,000 +\\| +1,200 +\\| +\\+200", summary)
+	require.NotContains(t, summary, "`test`.`equal` ")
+	require.NotContains(t, summary, "`test`.`unknown` ")
+
+	buf := new(bytes.Buffer)
+	report.Print(buf)
+	require.Contains(t, buf.String(), "Warning: the row counts of 2 table are different on both sides by count-precheck:\n"+
+		"    `test`.`less`: source 100 rows, target 90 rows (-10)\n"+
+		"    `test`.`more`: source 1,000 rows, target 1,200 rows (+200)\n")
+
+	result := new(Report)
+	require.NoError(t, json.Unmarshal(sink.files["report.json"].Bytes(), result))
+	require.Equal(t, int64(100), *result.TableResults["test"]["less"].SourceRows)
+	require.Equal(t, int64(90), *result.TableResults["test"]["less"].TargetRows)
+	require.Nil(t, result.TableResults["test"]["unknown"].SourceRows)
+}
+
+func TestRowDiffs(t *testing.T) {
+	createTableSQL := "create table `test`.`tbl`(`a` int, `b` varchar(10), primary key(`a`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+	tableDiffs := []*common.TableDiff{
+		{Schema: "test", Table: "diff", Info: tableInfo},
+		{Schema: "test", Table: "equal", Info: tableInfo},
+	}
+
+	report := NewReport(task)
+	report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	id0 := &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 2}
+	id1 := &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 1, ChunkCnt: 2}
+	report.SetTableStructCheckResult("test", "diff", true, false)
+	report.SetTableDataCheckResult("test", "diff", false, 1, 1, id0)
+	report.SetTableDataCheckResult("test", "diff", false, 1, 1, id1)
+	report.SetTableStructCheckResult("test", "equal", true, false)
+	report.SetTableDataCheckResult("test", "equal", true, 0, 0, &chunk.ChunkID{TableIndex: 1, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1})
+	report.AddChunkRowDiffs("test", "diff", id0, "row-diffs/test:diff:0:0-0:0.ndjson", 10, 0)
+	// all the rows of the second chunk are suppressed.
+	report.AddChunkRowDiffs("test", "diff", id1, "", 0, 5)
+	require.Equal(t, int64(10), report.GetTableRowDiffsExported("test", "diff"))
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	summary := sink.files["summary.txt"].String()
+	require.Contains(t, summary, "\nThe different rows of the following tables have been exported by export-row-diffs")
+	require.Regexp(t, "`test`.`diff` +\\| +10 +\\| +5 +\\| +1", summary)
+	require.NotContains(t, summary, "`test`.`equal` ")
+
+	result := new(Report)
+	require.NoError(t, json.Unmarshal(sink.files["report.json"].Bytes(), result))
+	require.Equal(t, int64(10), result.TableResults["test"]["diff"].RowDiffsExported)
+	require.Equal(t, int64(5), result.TableResults["test"]["diff"].RowDiffsSuppressed)
+	require.Equal(t, "row-diffs/test:diff:0:0-0:0.ndjson", result.TableResults["test"]["diff"].ChunkMap[id0.Encode()].RowDiffsFile)
+	require.Equal(t, "", result.TableResults["test"]["diff"].ChunkMap[id1.Encode()].RowDiffsFile)
+}
+
+func TestAdaptiveChunking(t *testing.T) {
+	createTableSQL := "create table `test`.`tbl`(`a` int, `b` varchar(10), primary key(`a`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+	tableDiffs := []*common.TableDiff{
+		{Schema: "test", Table: "adaptive", Info: tableInfo},
+		{Schema: "test", Table: "random", Info: tableInfo},
+	}
+
+	report := NewReport(task)
+	report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	for i, tableDiff := range tableDiffs {
+		report.SetTableStructCheckResult("test", tableDiff.Table, true, false)
+		report.SetTableDataCheckResult("test", tableDiff.Table, true, 0, 0, &chunk.ChunkID{TableIndex: i, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1})
+	}
+	report.SetTableChunking("test", "adaptive", 3, 2.1)
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	summary := sink.files["summary.txt"].String()
+	require.Contains(t, summary, "\nThe chunks of the following tables are split by adaptive-chunking, "+
+		"the skew factor is the most sampled rows in a chunk divided by the average\n\n")
+	require.Regexp(t, "`test`.`adaptive` +\\| +3 +\\| +2.10", summary)
+	require.NotContains(t, summary, "`test`.`random` ")
+
+	result := new(Report)
+	require.NoError(t, json.Unmarshal(sink.files["report.json"].Bytes(), result))
+	require.Equal(t, 3, result.TableResults["test"]["adaptive"].AdaptiveChunks)
+	require.Equal(t, 2.1, result.TableResults["test"]["adaptive"].ChunkSkewFactor)
+	require.Equal(t, 0, result.TableResults["test"]["random"].AdaptiveChunks)
+}
+
+func TestStructCheckedShards(t *testing.T) {
+	createTableSQL := "create table `test`.`tbl`(`a` int, `b` varchar(10), primary key(`a`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+	tableDiffs := []*common.TableDiff{{Schema: "test", Table: "tbl", Info: tableInfo}, {Schema: "test", Table: "tbl2", Info: tableInfo}}
+
+	report := NewReport(task)
+	report.Init(tableDiffs, [][]byte{[]byte("host = \"127.0.0.1\"\n")}, []byte("host = \"127.0.0.2\"\n"))
+	report.SetTableStructCheckedShards("test", "tbl", 16, 512)
+	for i, tableDiff := range tableDiffs {
+		report.SetTableStructCheckResult("test", tableDiff.Table, true, false)
+		report.SetTableDataCheckResult("test", tableDiff.Table, true, 0, 0, &chunk.ChunkID{TableIndex: i, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1})
+	}
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	require.Equal(t, Pass, report.Result)
+	require.Contains(t, sink.files["summary.txt"].String(), "\nThe structures of the following tables are only checked on a sample of the shards by shard-struct-sample, "+
+		"and the other shards are assumed to be equal\n\n`test`.`tbl` 16/512 shards\n")
+	require.NotContains(t, sink.files["summary.txt"].String(), "`test`.`tbl2` ")
+
+	result := new(Report)
+	require.NoError(t, json.Unmarshal(sink.files["report.json"].Bytes(), result))
+	require.Equal(t, 16, result.TableResults["test"]["tbl"].StructCheckedShards)
+	require.Equal(t, 512, result.TableResults["test"]["tbl"].Shards)
+}
+
+func TestVerificationMethod(t *testing.T) {
+	tableDiffs := []*common.TableDiff{{Schema: "test", Table: "t1"}, {Schema: "test", Table: "t2"}, {Schema: "test", Table: "t3"}, {Schema: "test", Table: "t4"}}
+	report := NewReport(task)
+	report.Init(tableDiffs, nil, nil)
+	for i, tableDiff := range tableDiffs {
+		report.SetTableStructCheckResult("test", tableDiff.Table, true, false)
+		report.SetTableDataCheckResult("test", tableDiff.Table, true, 0, 0, &chunk.ChunkID{TableIndex: i, ChunkCnt: 1})
+	}
+	// the table keeps the weakest method of its chunks.
+	report.AddTableVerificationMethod("test", "t1", VerificationRowByRow)
+	report.AddTableVerificationMethod("test", "t1", VerificationChecksumOnly)
+	report.AddTableVerificationMethod("test", "t1", VerificationRowByRow)
+	report.AddTableVerificationMethod("test", "t2", VerificationChecksumOnly)
+	report.AddTableVerificationMethod("test", "t2", VerificationSampled)
+	report.AddTableVerificationMethod("test", "t3", VerificationRowByRow)
+	require.Equal(t, VerificationChecksumOnly, report.TableResults["test"]["t1"].VerificationMethod)
+	require.Equal(t, VerificationSampled, report.TableResults["test"]["t2"].VerificationMethod)
+	require.Equal(t, VerificationRowByRow, report.TableResults["test"]["t3"].VerificationMethod)
+	require.Empty(t, report.TableResults["test"]["t4"].VerificationMethod)
+
+	// the method is kept after resuming.
+	snapshot, err := report.GetSnapshot(&chunk.ChunkID{TableIndex: 0, ChunkCnt: 1}, "test", "t1")
+	require.NoError(t, err)
+	resumed := NewReport(task)
+	resumed.Init(tableDiffs, nil, nil)
+	resumed.LoadReport(snapshot)
+	require.Equal(t, VerificationChecksumOnly, resumed.TableResults["test"]["t1"].VerificationMethod)
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	summary := sink.files["summary.txt"].String()
+	require.Contains(t, summary, "\nThe data of the equivalent tables is verified by the following methods\n\n")
+	require.Regexp(t, "(?s)row-by-row +\\| +1 \\|.*checksum-only +\\| +1 \\|.*sampled +\\| +1 \\|", summary)
+
+	result := new(Report)
+	require.NoError(t, json.Unmarshal(sink.files["report.json"].Bytes(), result))
+	require.Equal(t, VerificationSampled, result.TableResults["test"]["t2"].VerificationMethod)
+}
+
+func TestDiffRowsFile(t *testing.T) {
+	tableDiffs := []*common.TableDiff{{Schema: "test", Table: "t1"}, {Schema: "test", Table: "t2"}}
+	report := NewReport(task)
+	report.Init(tableDiffs, nil, nil)
+	report.SetTableStructCheckResult("test", "t1", true, false)
+	report.SetTableStructCheckResult("test", "t2", true, false)
+	report.SetTableDataCheckResult("test", "t1", false, 2, 1, &chunk.ChunkID{TableIndex: 0, ChunkCnt: 1})
+	report.SetTableDiffRowsFile("test", "t1", "/tmp/output/diff-rows/test.t1.csv", 3)
+	require.Equal(t, int64(3), report.GetTableDiffRowsExported("test", "t1"))
+	require.Equal(t, int64(0), report.GetTableDiffRowsExported("test", "t2"))
+
+	// the exported rows are kept after resuming.
+	snapshot, err := report.GetSnapshot(&chunk.ChunkID{TableIndex: 0, ChunkCnt: 1}, "test", "t1")
+	require.NoError(t, err)
+	resumed := NewReport(task)
+	resumed.Init(tableDiffs, nil, nil)
+	resumed.LoadReport(snapshot)
+	require.Equal(t, int64(3), resumed.GetTableDiffRowsExported("test", "t1"))
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	report.SetSink(sink)
+	require.NoError(t, report.CommitSummary())
+	summary := sink.files["summary.txt"].String()
+	require.Contains(t, summary, "\nThe rows only on one side of the following tables have been exported\n\n")
+	require.Regexp(t, "`test`.`t1` +\\| +3 +\\| /tmp/output/diff-rows/test.t1.csv", summary)
+}
+
+func TestErrorNum(t *testing.T) {
+	tableDiffs := []*common.TableDiff{{Schema: "test", Table: "t1"}, {Schema: "test", Table: "t2"}, {Schema: "test", Table: "t3"}}
+	report := NewReport(task)
+	report.Init(tableDiffs, nil, nil)
+	for _, tableDiff := range tableDiffs {
+		report.SetTableStructCheckResult(tableDiff.Schema, tableDiff.Table, true, false)
+	}
+	report.SetTableDataCheckResult("test", "t2", false, 1, 0, &chunk.ChunkID{TableIndex: 1, ChunkCnt: 1})
+	// the errored table is not counted as passed or failed, even if its data is equal or different.
+	report.SetTableDataCheckResult("test", "t1", false, 1, 0, &chunk.ChunkID{TableIndex: 2, ChunkCnt: 1})
+	report.SetTableMeetError("test", "t1", errors.New("connection refused"), &chunk.ChunkID{TableIndex: 2, ChunkCnt: 1}, "")
+
+	// the error is kept after resuming from the checkpoint.
+	snapshot, err := report.GetSnapshot(&chunk.ChunkID{TableIndex: 2, ChunkCnt: 1}, "test", "t1")
+	require.NoError(t, err)
+	data, err := json.Marshal(snapshot)
+	require.NoError(t, err)
+	loaded := new(Report)
+	require.NoError(t, json.Unmarshal(data, loaded))
+	resumed := NewReport(task)
+	resumed.Init(tableDiffs, nil, nil)
+	resumed.LoadReport(loaded)
+	require.Equal(t, Error, resumed.GetResult())
+	require.EqualError(t, resumed.TableResults["test"]["t1"].MeetError, "connection refused")
+	_, ok := resumed.TableResults["test"]["t1"].MeetError.(*ResumedError)
+	require.True(t, ok)
+
+	sink := &memorySink{files: make(map[string]*bytes.Buffer)}
+	resumed.SetSink(sink)
+	require.NoError(t, resumed.CommitSummary())
+	require.Contains(t, sink.files["summary.txt"].String(), "Comparison Result\n\n\n\n1 succeeded, 1 mismatched, 1 errored\n\n")
+	buf := new(bytes.Buffer)
+	require.NoError(t, resumed.Print(buf))
+	require.Contains(t, buf.String(), "connection refused error occured in `test`.`t1` on chunk")
+	result := new(Report)
+	require.NoError(t, json.Unmarshal(sink.files["report.json"].Bytes(), result))
+	require.Equal(t, []int32{1, 1, 1}, []int32{result.PassNum, result.FailedNum, result.ErrorNum})
+	require.Equal(t, "connection refused", result.TableResults["test"]["t1"].ErrorMessage)
+}
+
+func TestDropTablesSince(t *testing.T) {
+	// the tables are compared in the order of t3, t2 and t1.
+	tableDiffs := []*common.TableDiff{{Schema: "test", Table: "t3"}, {Schema: "test", Table: "t2"}, {Schema: "test", Table: "t1"}}
+	report := NewReport(task)
+	report.Init(tableDiffs, nil, nil)
+	for i, tableDiff := range tableDiffs {
+		id := &chunk.ChunkID{TableIndex: i, ChunkCnt: 1}
+		report.SetTableDataCheckResult(tableDiff.Schema, tableDiff.Table, true, 0, 0, id)
+		report.SetChunkChecksumDuration(tableDiff.Schema, tableDiff.Table, &chunk.Range{Index: id}, time.Second)
+	}
+	report.SetTableMeetError("test", "t2", errors.New("connection refused"), nil, "")
+
+	report.DropTablesSince("test", "t2")
+	require.Len(t, report.TableResults["test"], 1)
+	require.Contains(t, report.TableResults["test"], "t3")
+	require.Len(t, report.SlowChunks, 1)
+	require.Equal(t, "t3", report.SlowChunks[0].Table)
+
+	report.DropTablesSince("test", "t3")
+	require.Empty(t, report.TableResults)
+	require.Empty(t, report.SlowChunks)
 }
@@ -0,0 +1,106 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pingcap/errors"
+)
+
+// ReportSink is the destination of the files generated by sync-diff,
+// e.g. `summary.txt` and the fix sql files.
+// Users can implement it to upload the outputs to the external storage(S3, GCS, etc.).
+type ReportSink interface {
+	// Create creates the file with the given name, the name is relative to the root of the sink.
+	// The file may be uploaded or committed when it's closed, so the error of Close fails the write.
+	Create(name string) (io.WriteCloser, error)
+}
+
+// FileSink is the default ReportSink, which writes the files into the local directory.
+type FileSink struct {
+	dir string
+}
+
+// NewFileSink returns a FileSink rooted at `dir`.
+func NewFileSink(dir string) *FileSink {
+	return &FileSink{
+		dir: dir,
+	}
+}
+
+// Dir returns the directory the sink is rooted at.
+func (s *FileSink) Dir() string {
+	return s.dir
+}
+
+// Create implements the ReportSink interface.
+func (s *FileSink) Create(name string) (io.WriteCloser, error) {
+	f, err := os.Create(filepath.Join(s.dir, name))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return f, nil
+}
+
+// TeeSink wraps a ReportSink and copies the file of the name to a writer besides the sink, e.g. `report.json` to stdout.
+type TeeSink struct {
+	ReportSink
+	name string
+	w    io.Writer
+}
+
+// NewTeeSink returns a TeeSink copying the file of `name` created by `sink` to `w`.
+func NewTeeSink(sink ReportSink, name string, w io.Writer) *TeeSink {
+	return &TeeSink{
+		ReportSink: sink,
+		name:       name,
+		w:          w,
+	}
+}
+
+// Create implements the ReportSink interface.
+func (s *TeeSink) Create(name string) (io.WriteCloser, error) {
+	w, err := s.ReportSink.Create(name)
+	if err != nil || name != s.name {
+		return w, err
+	}
+	return &teeWriteCloser{Writer: io.MultiWriter(w, s.w), closer: w}, nil
+}
+
+type teeWriteCloser struct {
+	io.Writer
+	closer io.Closer
+}
+
+func (w *teeWriteCloser) Close() error {
+	return w.closer.Close()
+}
+
+// writeSinkFile writes the file of the name with data into sink.
+func writeSinkFile(sink ReportSink, name string, data []byte) (err error) {
+	w, err := sink.Create(name)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer func() {
+		if closeErr := w.Close(); err == nil {
+			err = errors.Trace(closeErr)
+		}
+	}()
+	_, err = w.Write(data)
+	return errors.Trace(err)
+}
@@ -0,0 +1,674 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/utils"
+	"go.uber.org/zap"
+)
+
+// CommitSummary commit summary info
+func (r *Report) CommitSummary() error {
+	r.countResults()
+	var summary bytes.Buffer
+	summaryFile := bufio.NewWriter(&summary)
+	r.writeSummaryHeader(summaryFile)
+	r.writeComparisonResult(summaryFile)
+	if r.CheckStructOnly {
+		r.writeStructOnlyResult(summaryFile)
+	} else {
+		r.writeEqualTables(summaryFile)
+		r.writeDiffTables(summaryFile)
+	}
+	r.writeObjectResults(summaryFile)
+	// the same duration is written in report.json.
+	r.Duration = r.TotalDuration()
+	r.writeCost(summaryFile)
+	if err := summaryFile.Flush(); err != nil {
+		return errors.Trace(err)
+	}
+	if err := r.commitFile(summaryFileName, summary.Bytes()); err != nil {
+		return errors.Trace(err)
+	}
+	if err := r.writeJSON(); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(r.writeFlatMetrics())
+}
+
+// countResults counts the tables by the results into report.json, and compares the diff rows of the tables with the
+// previous run.
+func (r *Report) countResults() {
+	r.PassNum, r.FailedNum, r.ErrorNum = r.countTables()
+	if r.previous != nil {
+		r.trend = CompareReports(r.previous, r)
+		r.Regressions = r.trend.Regressions()
+	}
+}
+
+// writeSummaryHeader writes the partial results warnings, the databases, the config overrides and the environment.
+func (r *Report) writeSummaryHeader(w *bufio.Writer) {
+	w.WriteString("Summary\n\n\n\n")
+	if r.Aborted {
+		w.WriteString(fmt.Sprintf("The comparison is aborted because the number of diff rows exceeds max-diff-rows(%d), the results are partial\n\n", r.maxDiffRows))
+	}
+	if r.FailedFast {
+		w.WriteString(fmt.Sprintf("The comparison is stopped early by fail-fast after the table %s is found different, the results are partial, "+
+			"run it again without fail-fast to resume the full comparison from the checkpoint\n\n", r.FailedFastTable))
+	}
+	if r.TimedOut {
+		w.WriteString(fmt.Sprintf("The comparison is truncated by run-timeout(%s), the results are incomplete without a pass or fail verdict, run it again to resume from the checkpoint\n\n", r.RunTimeout))
+	} else if r.Interrupted {
+		w.WriteString("The comparison is interrupted, the results are partial, run it again to resume from the checkpoint\n\n")
+	}
+	w.WriteString("Source Database\n\n\n\n")
+	for i := 0; i < len(r.SourceConfig); i++ {
+		w.Write(r.SourceConfig[i])
+		w.WriteString("\n")
+	}
+	w.WriteString("Target Databases\n\n\n\n")
+	w.Write(r.TargetConfig)
+	w.WriteString("\n")
+	if len(r.ConfigOverrides) > 0 {
+		w.WriteString("Config Overrides\n\n\n\n")
+		for _, override := range r.ConfigOverrides {
+			w.WriteString(override + "\n")
+		}
+		w.WriteString("\n")
+	}
+	if len(r.SourceVersions) > 0 || r.TargetVersion != nil || len(r.ConnPoolStats) > 0 {
+		w.WriteString("Environment\n\n\n\n")
+		for i, version := range r.SourceVersions {
+			writeServerVersion(w, fmt.Sprintf("Source Database %d", i), version)
+		}
+		writeServerVersion(w, "Target Database", r.TargetVersion)
+		if warning := r.getAutoIncrementWarning(); len(warning) > 0 {
+			w.WriteString(warning + "\n")
+		}
+		r.writeConnPoolStats(w)
+		w.WriteString("\n")
+	}
+}
+
+// writeComparisonResult writes the counts of the results, the replication positions and the tables meeting errors.
+func (r *Report) writeComparisonResult(w *bufio.Writer) {
+	w.WriteString("Comparison Result\n\n\n\n")
+	if r.isSampled() {
+		w.WriteString(r.sampleString() + "\n\n")
+	}
+	if r.ReplicationLag {
+		w.WriteString(r.replicationLagString() + "\n\n")
+	}
+	w.WriteString(r.countString() + "\n\n")
+	if r.ConfirmedChunks > 0 || r.TransientChunks > 0 {
+		w.WriteString(fmt.Sprintf("%s\n\n", r.recheckString()))
+	}
+	if len(r.ReplicationPositions) > 0 {
+		w.WriteString("The replication positions observed by wait-sync\n\n")
+		w.WriteString(renderTable([]string{"Source", "Goal", "Checkpoint", "Caught up"}, r.getReplicationPositionRows()) + "\n")
+	}
+	if groups := r.getErroredTablesByCategory(); len(groups) > 0 {
+		counts := make([]string, 0, len(groups))
+		for _, group := range groups {
+			counts = append(counts, fmt.Sprintf("%d %s", len(group.tables), group.category))
+		}
+		w.WriteString(fmt.Sprintf("The following tables meet errors, grouped by the category: %s\n\n", strings.Join(counts, ", ")))
+		tableString := &strings.Builder{}
+		table := tablewriter.NewWriter(tableString)
+		table.SetHeader([]string{"Table", "Category", "Error"})
+		for _, group := range groups {
+			for _, result := range group.tables {
+				table.Append([]string{dbutil.TableName(result.Schema, result.Table), group.category, result.MeetError.Error()})
+			}
+		}
+		table.Render()
+		w.WriteString(tableString.String() + "\n")
+	}
+}
+
+// writeEqualTables writes the equivalent tables, how they are verified, and the warnings of the tables compared.
+func (r *Report) writeEqualTables(w *bufio.Writer) {
+	w.WriteString("The table structure and data in following tables are equivalent\n\n")
+	equalTables := r.getSortedTables()
+	for _, table := range equalTables {
+		w.WriteString(table + "\n")
+	}
+	if countVerifiedTables := r.getCountVerifiedTables(); len(countVerifiedTables) > 0 {
+		w.WriteString("\nThe row counts of following tables are equal, but the data is not compared by chunks\n\n")
+		for _, table := range countVerifiedTables {
+			w.WriteString(table + "\n")
+		}
+	}
+	if adminChecksumTables := r.getAdminChecksumTables(); len(adminChecksumTables) > 0 {
+		w.WriteString("\nThe following tables are verified by `ADMIN CHECKSUM TABLE`, and the data is not compared by chunks\n\n")
+		for _, table := range adminChecksumTables {
+			w.WriteString(table + "\n")
+		}
+	}
+	if methodRows := r.getVerificationMethodRows(); len(methodRows) > 0 {
+		w.WriteString("\nThe data of the equivalent tables is verified by the following methods\n\n")
+		w.WriteString(renderTable([]string{"Verification method", "Tables"}, methodRows))
+	}
+	if reasonSkippedTables, reasons := r.getSkippedTables(); len(reasonSkippedTables) > 0 {
+		w.WriteString("\nThe data check of the following tables is skipped\n\n")
+		for i, table := range reasonSkippedTables {
+			w.WriteString(fmt.Sprintf("%s %s\n", table, reasons[i]))
+		}
+	}
+	if reorderedTables := r.getReorderedTables(); len(reorderedTables) > 0 {
+		w.WriteString("\nThe columns of the following tables are in different orders, and they are matched by name\n\n")
+		for _, table := range reorderedTables {
+			w.WriteString(table + "\n")
+		}
+	}
+	if rangeTables := r.getRangeTables(); len(rangeTables) > 0 {
+		w.WriteString("\nOnly the rows in the range of the following tables are compared\n\n")
+		for _, table := range rangeTables {
+			w.WriteString(table + "\n")
+		}
+	}
+	if columnTransforms := r.getColumnTransforms(); len(columnTransforms) > 0 {
+		w.WriteString("\nThe following column transforms are applied to the source values before comparison\n\n")
+		for _, transform := range columnTransforms {
+			w.WriteString(transform + "\n")
+		}
+	}
+	if trimmedColumns := r.getTrimmedColumns(); len(trimmedColumns) > 0 {
+		w.WriteString("\nThe trailing spaces of the following columns are ignored by trim-char-padding\n\n")
+		for _, column := range trimmedColumns {
+			w.WriteString(column + "\n")
+		}
+	}
+	if enumColumns := r.getReorderedEnumColumns(); len(enumColumns) > 0 {
+		w.WriteString("\nThe members of the following ENUM and SET columns are in different orders, and they are compared by value\n\n")
+		for _, column := range enumColumns {
+			w.WriteString(column + "\n")
+		}
+	}
+	if geometryColumns := r.getGeometryColumns(); len(geometryColumns) > 0 {
+		w.WriteString("\nThe following GEOMETRY columns are compared by the SRID and the WKB\n\n")
+		for _, column := range geometryColumns {
+			w.WriteString(column + "\n")
+		}
+	}
+	if sridColumns := r.getSRIDMismatchColumns(); len(sridColumns) > 0 {
+		w.WriteString("\nWarning: the values of the following GEOMETRY columns have the same WKB but the different SRIDs on both sides\n\n")
+		for _, column := range sridColumns {
+			w.WriteString(column + "\n")
+		}
+	}
+	if transcodedRows := r.getTranscodedColumnRows(); len(transcodedRows) > 0 {
+		w.WriteString("\nThe source values of the following columns are transcoded by charset-map, and the un-mappable characters are replaced by \"?\"\n\n")
+		w.WriteString(renderTable([]string{"Column", "From", "To", "Unmappable values", "Unmappable characters"}, transcodedRows))
+	}
+	if duplicateKeyRows := r.getDuplicateKeyRows(); len(duplicateKeyRows) > 0 {
+		w.WriteString("\nThe unique keys of the following tables have duplicate values, and the data check of them is skipped\n\n")
+		w.WriteString(renderTable([]string{"Table", "Side", "Key", "Value", "Rows"}, duplicateKeyRows))
+	}
+	if sampleRows := r.getSampleRows(); r.isSampled() && len(sampleRows) > 0 {
+		w.WriteString("\nThe chunks of the following tables are sampled, and the tables are not fully verified\n\n")
+		w.WriteString(renderTable([]string{"Table", "Chunks total", "Chunks sampled", "Chunks differed"}, sampleRows))
+	}
+	if chunkingRows := r.getAdaptiveChunkingRows(); len(chunkingRows) > 0 {
+		w.WriteString("\nThe chunks of the following tables are split by adaptive-chunking, the skew factor is the most sampled rows in a chunk divided by the average\n\n")
+		w.WriteString(renderTable([]string{"Table", "Chunks", "Skew factor"}, chunkingRows))
+	}
+	if indexMismatchRows := r.getIndexMismatchRows(); len(indexMismatchRows) > 0 {
+		w.WriteString("\nThe following indices are inconsistent with the data, the fix sql isn't applicable to them, check them by `ADMIN CHECK INDEX` and rebuild them if needed\n\n")
+		tableString := &strings.Builder{}
+		table := tablewriter.NewWriter(tableString)
+		table.SetHeader([]string{"Table", "Index", "Inconsistent chunks", "Check"})
+		// the statements are kept in one line to be copied.
+		table.SetAutoWrapText(false)
+		table.AppendBulk(indexMismatchRows)
+		table.Render()
+		w.WriteString(tableString.String())
+	}
+	if deltaRows := r.getRowCountDeltaRows(); len(deltaRows) > 0 {
+		w.WriteString("\nWarning: the row counts of the following tables are different on both sides by count-precheck, the delta is the target rows minus the source rows\n\n")
+		w.WriteString(renderTable([]string{"Table", "Source rows", "Target rows", "Delta"}, deltaRows))
+	}
+	if staleStatsRows := r.getStaleStatsRows(); len(staleStatsRows) > 0 {
+		w.WriteString(fmt.Sprintf("\nWarning: the estimated row counts of the following tables diverge from the actual rows by more than %g times, the statistics may be stale, run `ANALYZE TABLE` on them to update the statistics\n\n", r.rowsEstimateWarnFactor))
+		tableString := &strings.Builder{}
+		table := tablewriter.NewWriter(tableString)
+		table.SetHeader([]string{"Table", "Estimated rows", "Actual rows"})
+		for _, row := range staleStatsRows {
+			log.Warn("the estimated row count of the table diverges from the actual rows, run ANALYZE TABLE to update the statistics",
+				zap.String("table", row[0]), zap.String("estimated rows", row[1]), zap.String("actual rows", row[2]))
+			table.Append(row)
+		}
+		table.Render()
+		w.WriteString(tableString.String())
+	}
+	if fallbackTables := r.getNoPKTables(); len(fallbackTables) > 0 {
+		w.WriteString("\nWarning: the following tables have no primary key or unique key, the rows of the whole table are compared as multisets, and the fix sql is `DELETE ... LIMIT 1` and `INSERT` pairs\n\n")
+		for _, table := range fallbackTables {
+			w.WriteString(table + "\n")
+		}
+	}
+}
+
+// writeDiffTables writes the inconsistent tables, views and partitions, and the files exported for them.
+func (r *Report) writeDiffTables(w *bufio.Writer) {
+	if diffRows := r.getDiffRows(); r.Result == Fail && len(diffRows) > 0 {
+		tableString := &strings.Builder{}
+		table := tablewriter.NewWriter(tableString)
+		if r.trend != nil {
+			w.WriteString(fmt.Sprintf("\nThe following tables contains inconsistent data, the trend is the change of the diff rows since the previous run %s\n\n", r.PreviousRun))
+			table.SetHeader([]string{"Table", "Structure equality", "Data diff rows", "Trend"})
+		} else {
+			w.WriteString("\nThe following tables contains inconsistent data\n\n")
+			table.SetHeader([]string{"Table", "Structure equality", "Data diff rows"})
+		}
+		for _, v := range diffRows {
+			table.Append(v)
+		}
+		table.Render()
+		w.WriteString(tableString.String())
+	}
+	if truncatedTables := r.getTruncatedTables(); len(truncatedTables) > 0 {
+		w.WriteString("\nWarning: the comparison of the following tables is truncated because their diff rows exceed max-diff-rows-per-table, the diff rows are partial counts rather than the totals, and the fix sql only covers the chunks compared\n\n")
+		for _, table := range truncatedTables {
+			w.WriteString(table + "\n")
+		}
+	}
+	if r.trend != nil {
+		r.writeNewlyChanged(w)
+	}
+	if viewDiffRows := r.getViewDiffRows(); len(viewDiffRows) > 0 {
+		w.WriteString("\nThe following views contain inconsistent data\n\n")
+		w.WriteString(renderTable([]string{"View", "Structure equality", "Data diff rows"}, viewDiffRows))
+	}
+	if structChanges := r.getStructChanges(); len(structChanges) > 0 {
+		w.WriteString("\nThe structures of the following tables are different\n\n")
+		for _, changes := range structChanges {
+			w.WriteString(changes + "\n")
+		}
+	}
+	if partitionDiffRows := r.getPartitionDiffRows(); len(partitionDiffRows) > 0 {
+		w.WriteString("\nThe following partitions contains inconsistent data\n\n")
+		w.WriteString(renderTable([]string{"Partition", "Data diff rows"}, partitionDiffRows))
+	}
+	if fixSQLBytes, hasFiles := r.getFixSQLBytes(); len(fixSQLBytes) > 0 {
+		w.WriteString("\nThe following fix sql files have been written\n\n")
+		tableString := &strings.Builder{}
+		table := tablewriter.NewWriter(tableString)
+		if hasFiles {
+			table.SetHeader([]string{"Table", "Fix SQL size", "Fix SQL file"})
+		} else {
+			table.SetHeader([]string{"Table", "Fix SQL size"})
+		}
+		table.AppendBulk(fixSQLBytes)
+		table.Render()
+		w.WriteString(tableString.String())
+	}
+	if diffRows := r.getExportedDiffRows(); len(diffRows) > 0 {
+		w.WriteString("\nThe rows only on one side of the following tables have been exported\n\n")
+		w.WriteString(renderTable([]string{"Table", "Rows", "File"}, diffRows))
+	}
+	if rowDiffsRows := r.getRowDiffsRows(); len(rowDiffsRows) > 0 {
+		w.WriteString("\nThe different rows of the following tables have been exported by export-row-diffs, the file of each chunk is `row-diffs-file` of the chunk in report.json\n\n")
+		w.WriteString(renderTable([]string{"Table", "Rows exported", "Rows suppressed", "Files"}, rowDiffsRows))
+	}
+	if len(r.SlowChunks) > 0 {
+		w.WriteString("\nThe checksums of the following chunks are the slowest\n\n")
+		tableString := &strings.Builder{}
+		table := tablewriter.NewWriter(tableString)
+		table.SetHeader([]string{"Table", "Chunk", "Bound", "Checksum duration"})
+		for _, slowChunk := range r.SlowChunks {
+			table.Append([]string{dbutil.TableName(slowChunk.Schema, slowChunk.Table), slowChunk.ChunkID.Encode(), slowChunk.Bound, slowChunk.ChecksumDuration.String()})
+		}
+		table.Render()
+		w.WriteString(tableString.String())
+	}
+}
+
+// writeObjectResults writes the results of the objects other than the tables compared, e.g. the missing tables,
+// the skipped objects, the views and the query pairs, and the structure fix.
+func (r *Report) writeObjectResults(w *bufio.Writer) {
+	if sampledTables := r.getStructSampledTables(); len(sampledTables) > 0 {
+		w.WriteString("\nThe structures of the following tables are only checked on a sample of the shards by shard-struct-sample, and the other shards are assumed to be equal\n\n")
+		for _, table := range sampledTables {
+			w.WriteString(table + "\n")
+		}
+	}
+	if len(r.MissingTables) > 0 {
+		w.WriteString("\nThe following tables only exist on one side, and they are not compared\n\n")
+		for _, table := range r.MissingTables {
+			w.WriteString(fmt.Sprintf("%s missing on the %s\n", dbutil.TableName(table.Schema, table.Table), table.MissingOn))
+		}
+	}
+	if len(r.StructFixFile) > 0 {
+		w.WriteString(fmt.Sprintf("\nThe statements to make the structures of the target match the source have been written to '%s', which are not applied, please review them before applying them manually\n\n", r.StructFixFile))
+		w.WriteString(renderTable([]string{"Table", "Statements", "Destructive statements"}, r.getStructFixRows()))
+	}
+	if len(r.SkippedObjects) > 0 {
+		w.WriteString("\nSkipped objects\n\n")
+		for _, object := range r.SkippedObjects {
+			w.WriteString(fmt.Sprintf("%s on the %s (%s): %s\n", dbutil.TableName(object.Schema, object.Table), object.On, object.Type, object.Reason))
+		}
+	}
+	if len(r.ViewResults) > 0 {
+		w.WriteString("\nViews\n\n")
+		for _, result := range r.ViewResults {
+			if dataStatus := r.getViewDataStatus(result.Schema, result.View); len(dataStatus) > 0 {
+				w.WriteString(fmt.Sprintf("%s %s, data %s\n", dbutil.TableName(result.Schema, result.View), result.Status, dataStatus))
+				continue
+			}
+			w.WriteString(fmt.Sprintf("%s %s\n", dbutil.TableName(result.Schema, result.View), result.Status))
+		}
+	}
+	if len(r.QueryPairResults) > 0 {
+		w.WriteString("\nQuery pairs\n\n")
+		w.WriteString(renderTable([]string{"Label", "Result", "Source rows", "Target rows", "Data diff rows"}, r.getQueryPairRows()))
+		for _, result := range r.QueryPairResults {
+			if len(result.ErrorMessage) > 0 {
+				w.WriteString(fmt.Sprintf("%s: %s\n", result.Label, result.ErrorMessage))
+			}
+		}
+	}
+}
+
+// writeCost writes the total size, the time cost and the average speed of the comparison. The bytes read aren't
+// counted, since most chunks are compared by the checksums without reading the rows, so the size is the estimate
+// by the table statistics, i.e. `DATA_LENGTH` of `information_schema`.`tables`, and so is the speed.
+func (r *Report) writeCost(w *bufio.Writer) {
+	speed := int64(0)
+	if r.Duration > 0 {
+		speed = int64(float64(r.TotalSize) / r.Duration.Seconds())
+	}
+	if r.SizeUnavailableTables > 0 {
+		w.WriteString(fmt.Sprintf("Total Size: %s (estimated by the table statistics, the sizes of %d tables are unavailable, so the speed is underestimated)\n",
+			r.formatBytes(r.TotalSize), r.SizeUnavailableTables))
+	} else {
+		w.WriteString(fmt.Sprintf("Total Size: %s (estimated by the table statistics)\n", r.formatBytes(r.TotalSize)))
+	}
+	w.WriteString(fmt.Sprintf("Time Cost: %s\n", r.Duration))
+	w.WriteString(fmt.Sprintf("Average Speed: %s/s (estimated by the total size)\n", r.formatBytes(speed)))
+}
+
+// renderTable renders the rows as a text table with the header.
+func renderTable(header []string, rows [][]string) string {
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetHeader(header)
+	table.AppendBulk(rows)
+	table.Render()
+	return tableString.String()
+}
+
+// writeStructOnlyResult writes the comparison result of the struct-only mode,
+// the differences of the tables are rendered as the unified diffs of the `CREATE TABLE` statements.
+func (r *Report) writeStructOnlyResult(w *bufio.Writer) {
+	w.WriteString("The table structure in following tables are equivalent, and the data check is skipped by check-struct-only\n\n")
+	diffTables := make([][2]string, 0)
+	for _, name := range r.getSortedSchemaTables() {
+		if r.TableResults[name[0]][name[1]].StructEqual {
+			w.WriteString(dbutil.TableName(name[0], name[1]) + "\n")
+		} else {
+			diffTables = append(diffTables, name)
+		}
+	}
+	if reorderedTables := r.getReorderedTables(); len(reorderedTables) > 0 {
+		w.WriteString("\nThe columns of the following tables are in different orders, and they are matched by name\n\n")
+		for _, table := range reorderedTables {
+			w.WriteString(table + "\n")
+		}
+	}
+	if enumColumns := r.getReorderedEnumColumns(); len(enumColumns) > 0 {
+		w.WriteString("\nThe members of the following ENUM and SET columns are in different orders, and they are compared by value\n\n")
+		for _, column := range enumColumns {
+			w.WriteString(column + "\n")
+		}
+	}
+	if len(diffTables) > 0 {
+		w.WriteString("\nThe table structure in following tables are different\n\n")
+		for _, name := range diffTables {
+			result := r.TableResults[name[0]][name[1]]
+			w.WriteString(dbutil.TableName(name[0], name[1]) + "\n")
+			if len(result.SchemaDiff) > 0 {
+				w.WriteString(result.SchemaDiff)
+			}
+			w.WriteString("\n")
+		}
+	}
+}
+
+// writeConnPoolStats writes the statistics of the connection pools, and warns the pools waited long relative to the
+// time of this run, which slow down the comparison.
+func (r *Report) writeConnPoolStats(w *bufio.Writer) {
+	elapsed := time.Since(r.StartTime)
+	for _, stats := range r.ConnPoolStats {
+		w.WriteString(fmt.Sprintf("%s Connection Pool: max open %d, peak in use %d, waited %d times for %s\n",
+			stats.Name, stats.MaxOpenConnections, stats.PeakInUse, stats.WaitCount, stats.WaitDuration))
+		if stats.IsWaitSignificant(elapsed) {
+			w.WriteString(fmt.Sprintf("WARNING: the connections of %s are waited for %s in the run of %s, the connection pool may be the bottleneck, consider raising max-open-conns\n",
+				stats.Name, stats.WaitDuration, elapsed.Round(time.Second)))
+		}
+	}
+}
+
+// writeServerVersion writes the version of the server, the multi-line `tidb_version()` is indented.
+func writeServerVersion(w *bufio.Writer, name string, version *ServerVersion) {
+	if version == nil {
+		w.WriteString(fmt.Sprintf("%s Version: unknown\n", name))
+		return
+	}
+	versionString := version.Version
+	if version.Flavor == utils.FlavorMariaDB {
+		// the version of MariaDB may be prefixed by "5.5.5-", e.g. "5.5.5-10.6.12-MariaDB-log".
+		if _, release := utils.ParseServerVersion(version.Version); release != nil {
+			versionString = fmt.Sprintf("%s (MariaDB %s)", version.Version, release)
+		}
+	}
+	w.WriteString(fmt.Sprintf("%s Version: %s\n", name, versionString))
+	if len(version.TiDBVersion) > 0 {
+		for _, line := range strings.Split(version.TiDBVersion, "\n") {
+			w.WriteString("    " + line + "\n")
+		}
+	}
+	if version.AutoIncrementIncrement > 0 {
+		w.WriteString(fmt.Sprintf("%s auto_increment_increment: %d, auto_increment_offset: %d\n", name, version.AutoIncrementIncrement, version.AutoIncrementOffset))
+	}
+}
+
+// writeJSON writes the report into `report.json`, so that it can be parsed by other tools.
+func (r *Report) writeJSON() error {
+	reportData, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(r.commitFile(reportFileName, reportData))
+}
+
+// commitFile writes the file of the name into the sink, and keeps the content for ArchiveFiles.
+func (r *Report) commitFile(name string, data []byte) error {
+	if err := writeSinkFile(r.sink, name, data); err != nil {
+		return errors.Trace(err)
+	}
+	if r.committedFiles == nil {
+		r.committedFiles = make(map[string][]byte)
+	}
+	r.committedFiles[name] = data
+	return nil
+}
+
+// Summary returns the result of the comparison which is printed by Print.
+func (r *Report) Summary() string {
+	var summary strings.Builder
+	if r.Aborted {
+		summary.WriteString(fmt.Sprintf("The comparison is aborted because the number of diff rows exceeds max-diff-rows(%d), the results are partial.\n", r.maxDiffRows))
+	}
+	if r.FailedFast {
+		summary.WriteString(fmt.Sprintf("The comparison is stopped early by fail-fast after the table %s is found different, the results are partial.\n", r.FailedFastTable))
+	}
+	if r.TimedOut {
+		// the results are incomplete, so neither pass nor fail is concluded.
+		summary.WriteString(fmt.Sprintf("The comparison is truncated by run-timeout(%s), the results are incomplete without a pass or fail verdict, run it again to resume from the checkpoint.\n", r.RunTimeout))
+		summary.WriteString(fmt.Sprintf("%s so far.\n", r.countString()))
+		summary.WriteString(fmt.Sprintf("You can view the comparision details through '%s/%s'\n", r.task.OutputDir, config.LogFileName))
+		return summary.String()
+	}
+	if r.Interrupted {
+		summary.WriteString("The comparison is interrupted, the results are partial, run it again to resume from the checkpoint.\n")
+	}
+	if r.isSampled() {
+		summary.WriteString(r.sampleString() + ".\n")
+	}
+	if r.ReplicationLag {
+		summary.WriteString(r.replicationLagString() + ".\n")
+	}
+	if deltaRows := r.getRowCountDeltaRows(); len(deltaRows) > 0 {
+		summary.WriteString(fmt.Sprintf("Warning: the row counts of %d table are different on both sides by count-precheck:\n", len(deltaRows)))
+		for _, row := range deltaRows {
+			summary.WriteString(fmt.Sprintf("    %s: source %s rows, target %s rows (%s)\n", row[0], row[1], row[2], row[3]))
+		}
+	}
+	if warning := r.getAutoIncrementWarning(); len(warning) > 0 {
+		summary.WriteString(warning + ".\n")
+	}
+	if truncatedTables := r.getTruncatedTables(); len(truncatedTables) > 0 {
+		summary.WriteString(fmt.Sprintf("Warning: the comparison of %d table is truncated by max-diff-rows-per-table, their diff rows are partial counts:\n", len(truncatedTables)))
+		for _, table := range truncatedTables {
+			summary.WriteString("    " + table + "\n")
+		}
+	}
+	if len(r.Regressions) > 0 {
+		summary.WriteString(fmt.Sprintf("Warning: the diff rows of %d table grow since the previous run %s:\n", len(r.Regressions), r.PreviousRun))
+		for _, t := range r.Regressions {
+			summary.WriteString(fmt.Sprintf("    %s: %s\n", dbutil.TableName(t.Schema, t.Table), t.Trend()))
+		}
+	}
+	if unmappable := r.getUnmappableValues(); unmappable > 0 {
+		summary.WriteString(fmt.Sprintf("Warning: %d source values have the characters which can't be mapped to the charsets of the target by charset-map.\n", unmappable))
+	}
+	if r.ConfirmedChunks > 0 || r.TransientChunks > 0 {
+		summary.WriteString(fmt.Sprintf("%s.\n", r.recheckString()))
+	}
+	summary.WriteString(fmt.Sprintf("%s.\n", r.countString()))
+	if r.Result == Pass {
+		passNum, failedNum, errorNum := r.countTables()
+		summary.WriteString(fmt.Sprintf("A total of %d table have been compared and all are equal.\n", passNum+failedNum+errorNum))
+		if r.CheckStructOnly {
+			summary.WriteString("Only the table structures are compared.\n")
+		}
+		if countVerified := len(r.getCountVerifiedTables()); countVerified > 0 {
+			summary.WriteString(fmt.Sprintf("%d of them are only verified by the row count.\n", countVerified))
+		}
+		if adminChecksumVerified := len(r.getAdminChecksumTables()); adminChecksumVerified > 0 {
+			summary.WriteString(fmt.Sprintf("%d of them are verified by ADMIN CHECKSUM TABLE.\n", adminChecksumVerified))
+		}
+		if len(r.MissingTables) > 0 {
+			summary.WriteString(fmt.Sprintf("%d table only exist on one side, and they are not compared.\n", len(r.MissingTables)))
+		}
+		if len(r.SkippedObjects) > 0 {
+			summary.WriteString(fmt.Sprintf("%d object can't be compared, and they are skipped.\n", len(r.SkippedObjects)))
+		}
+		if len(r.QueryPairResults) > 0 {
+			summary.WriteString(fmt.Sprintf("%d query pair have been compared and the result sets are all equal.\n", len(r.QueryPairResults)))
+		}
+		if staleStats := len(r.getStaleStatsRows()); staleStats > 0 {
+			summary.WriteString(fmt.Sprintf("%d table may have the stale statistics, run `ANALYZE TABLE` on them.\n", staleStats))
+		}
+		summary.WriteString(fmt.Sprintf("You can view the comparision details through '%s/%s'\n", r.task.OutputDir, config.LogFileName))
+	} else if r.Result == Fail {
+		for _, name := range r.getSortedSchemaTables() {
+			schema, table := name[0], name[1]
+			result := r.TableResults[schema][table]
+			if !result.StructEqual {
+				if result.DataSkip {
+					summary.WriteString(fmt.Sprintf("The structure of %s is not equal, and data-check is skipped\n", dbutil.TableName(schema, table)))
+				} else {
+					summary.WriteString(fmt.Sprintf("The structure of %s is not equal\n", dbutil.TableName(schema, table)))
+				}
+			}
+			if !result.DataEqual {
+				if result.CountOnly {
+					summary.WriteString(fmt.Sprintf("The row count of %s is not equal\n", dbutil.TableName(schema, table)))
+				} else {
+					summary.WriteString(fmt.Sprintf("The data of %s is not equal\n", dbutil.TableName(schema, table)))
+				}
+			}
+		}
+		if r.FailOnMissingTables {
+			for _, table := range r.MissingTables {
+				summary.WriteString(fmt.Sprintf("The table %s is missing on the %s\n", dbutil.TableName(table.Schema, table.Table), table.MissingOn))
+			}
+		}
+		for _, result := range r.ViewResults {
+			if !r.isViewFailed(result) {
+				continue
+			}
+			if result.Status == ViewUnequal {
+				summary.WriteString(fmt.Sprintf("The definition of view %s is not equal\n", dbutil.TableName(result.Schema, result.View)))
+			} else {
+				summary.WriteString(fmt.Sprintf("The view %s is missing on the target\n", dbutil.TableName(result.Schema, result.View)))
+			}
+		}
+		for _, result := range r.QueryPairResults {
+			if !result.Equal {
+				summary.WriteString(fmt.Sprintf("The result sets of query pair %s are not equal\n", result.Label))
+			}
+		}
+		summary.WriteString("\n")
+		summary.WriteString("The rest of tables are all equal.\n")
+		if r.CheckStructOnly {
+			summary.WriteString("Only the table structures are compared, and the data is not checked.\n")
+			summary.WriteString(fmt.Sprintf("The differences of the table structures have been written in \n\t'%s/summary.txt'\n", r.task.OutputDir))
+		} else {
+			summary.WriteString(fmt.Sprintf("The patch file has been generated in \n\t'%s/'\n", r.task.FixDir))
+		}
+		summary.WriteString(fmt.Sprintf("You can view the comparision details through '%s/%s'\n", r.task.OutputDir, config.LogFileName))
+	} else {
+		summary.WriteString("Error in comparison process:\n")
+		for _, group := range r.getErroredTablesByCategory() {
+			summary.WriteString(fmt.Sprintf("%s errors in %d table:\n", group.category, len(group.tables)))
+			for _, result := range group.tables {
+				name := dbutil.TableName(result.Schema, result.Table)
+				if len(result.ErrorChunk) > 0 {
+					summary.WriteString(fmt.Sprintf("    %s error occured in %s on chunk %s (bound %s)\n", result.MeetError.Error(), name, result.ErrorChunk, result.ErrorChunkBound))
+				} else {
+					summary.WriteString(fmt.Sprintf("    %s error occured in %s\n", result.MeetError.Error(), name))
+				}
+			}
+		}
+		for _, result := range r.QueryPairResults {
+			if len(result.ErrorMessage) > 0 {
+				summary.WriteString(fmt.Sprintf("%s error occured in query pair %s: %s\n", result.ErrorCategory, result.Label, result.ErrorMessage))
+			}
+		}
+		summary.WriteString(fmt.Sprintf("You can view the comparision details through '%s/%s'\n", r.task.OutputDir, config.LogFileName))
+	}
+	return summary.String()
+}
+
+// Print writes the summary of the comparison into w.
+func (r *Report) Print(w io.Writer) error {
+	_, err := fmt.Fprint(w, r.Summary())
+	return errors.Trace(err)
+}
@@ -90,6 +90,7 @@ func (t *ChunksIterator) produceChunks(ctx context.Context, startRange *splitter
 						ChunkRange: c,
 						IndexID:    getCurTableIndexID(chunkIter),
 						ProgressID: dbutil.TableName(curTable.Schema, curTable.Table),
+						Chunking:   getChunkingStats(chunkIter),
 					}:
 					}
 				}
@@ -151,6 +152,7 @@ func (t *ChunksIterator) produceChunks(ctx context.Context, startRange *splitter
 					ChunkRange: c,
 					IndexID:    getCurTableIndexID(chunkIter),
 					ProgressID: dbutil.TableName(table.Schema, table.Table),
+					Chunking:   getChunkingStats(chunkIter),
 				}:
 				}
 			}
@@ -181,10 +183,21 @@ func (t *ChunksIterator) getCurTableIndex() int {
 	return t.nextTableIndex - 1
 }
 
-// TODO: getCurTableIndexID only used for binary search, should be optimized later.
+// getCurTableIndexID returns the id of the index to split the chunks, which is recorded in the checkpoint,
+// so that the same index is used after resuming. It's also used for binary search.
 func getCurTableIndexID(tableIter splitter.ChunkIterator) int64 {
-	if bt, ok := tableIter.(*splitter.BucketIterator); ok {
-		return bt.GetIndexID()
+	if it, ok := tableIter.(interface{ GetIndexID() int64 }); ok {
+		return it.GetIndexID()
 	}
 	return 0
 }
+
+// getChunkingStats returns the stats of the chunks split by adaptive-chunking, which are recorded in the report.
+func getChunkingStats(tableIter splitter.ChunkIterator) *splitter.ChunkingStats {
+	if it, ok := tableIter.(interface {
+		GetChunkingStats() *splitter.ChunkingStats
+	}); ok {
+		return it.GetChunkingStats()
+	}
+	return nil
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/pingcap/tidb-tools/pkg/dbutil"
 	"github.com/pingcap/tidb-tools/sync_diff_inspector/utils"
 	"github.com/pingcap/tidb/parser"
+	"github.com/pingcap/tidb/parser/mysql"
 	"github.com/stretchr/testify/require"
 )
 
@@ -62,3 +63,58 @@ func TestRowData(t *testing.T) {
 		require.Equal(t, name, expectNames[i])
 	}
 }
+
+func TestRowDataUnsignedBigint(t *testing.T) {
+	tableInfo, err := dbutil.GetTableInfoBySQL("create table test.test(id bigint unsigned, primary key(id));", parser.New())
+	require.NoError(t, err)
+	_, orderKeyCols := dbutil.SelectUniqueOrderKey(tableInfo)
+
+	// the values near 2^64 are equal as float64.
+	ids := []string{"18446744073709551615", "18446744073709551613", "9223372036854775808", "18446744073709551614", "1"}
+	expectIDs := []string{"1", "9223372036854775808", "18446744073709551613", "18446744073709551614", "18446744073709551615"}
+	rowDatas := &RowDatas{
+		Rows:         make([]RowData, 0, len(ids)),
+		OrderKeyCols: orderKeyCols,
+	}
+	heap.Init(rowDatas)
+	for _, id := range ids {
+		heap.Push(rowDatas, RowData{Data: map[string]*dbutil.ColumnData{"id": {Data: []byte(id)}}})
+	}
+	for _, expectID := range expectIDs {
+		rowData := heap.Pop(rowDatas).(RowData)
+		require.Equal(t, expectID, string(rowData.Data["id"].Data))
+	}
+}
+
+func TestTableInfoCache(t *testing.T) {
+	require.Equal(t, "CREATE TABLE `` (\n  `a` int(11) NOT NULL AUTO_INCREMENT\n) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4",
+		NormalizeCreateTableSQL("CREATE TABLE `t_0001` (\n  `a` int(11) NOT NULL AUTO_INCREMENT\n) ENGINE=InnoDB AUTO_INCREMENT=42 DEFAULT CHARSET=utf8mb4"))
+	require.Equal(t, "CREATE TABLE `` (\n  `a` bigint(20) NOT NULL /*T![auto_rand] AUTO_RANDOM(5) */\n) ENGINE=InnoDB",
+		NormalizeCreateTableSQL("CREATE TABLE `t``1` (\n  `a` bigint(20) NOT NULL /*T![auto_rand] AUTO_RANDOM(5) */\n) ENGINE=InnoDB /*T![auto_rand_base] AUTO_RANDOM_BASE=30001 */"))
+
+	cache := NewTableInfoCache()
+	info1, err := cache.GetTableInfo("CREATE TABLE `t_0001` (\n  `a` int(11) NOT NULL,\n  `b` varchar(24)\n) ENGINE=InnoDB AUTO_INCREMENT=42", mysql.ModeNone)
+	require.NoError(t, err)
+	info2, err := cache.GetTableInfo("CREATE TABLE `t_0002` (\n  `a` int(11) NOT NULL,\n  `b` varchar(24)\n) ENGINE=InnoDB AUTO_INCREMENT=7", mysql.ModeNone)
+	require.NoError(t, err)
+	hits, misses := cache.Stats()
+	require.Equal(t, int64(1), hits)
+	require.Equal(t, int64(1), misses)
+	require.Equal(t, "t_0001", info1.Name.O)
+	require.Equal(t, "t_0002", info2.Name.O)
+	// the table infos are copies, so they can be modified separately.
+	info1.Columns = info1.Columns[:1]
+	require.Len(t, info2.Columns, 2)
+
+	// the different structures and the different sql modes are parsed separately.
+	_, err = cache.GetTableInfo("CREATE TABLE `t_0003` (\n  `a` int(11) NOT NULL\n) ENGINE=InnoDB", mysql.ModeNone)
+	require.NoError(t, err)
+	_, err = cache.GetTableInfo("CREATE TABLE `t_0004` (\n  `a` int(11) NOT NULL\n) ENGINE=InnoDB", mysql.ModeANSIQuotes)
+	require.NoError(t, err)
+	hits, misses = cache.Stats()
+	require.Equal(t, int64(1), hits)
+	require.Equal(t, int64(3), misses)
+
+	_, err = cache.GetTableInfo("CREATE TABLE `t_0005` (", mysql.ModeNone)
+	require.Error(t, err)
+}
@@ -14,8 +14,6 @@
 package common
 
 import (
-	"strconv"
-
 	"github.com/pingcap/log"
 	"github.com/pingcap/tidb-tools/pkg/dbutil"
 	"github.com/pingcap/tidb-tools/sync_diff_inspector/utils"
@@ -65,19 +63,15 @@ func (r RowDatas) Less(i, j int) bool {
 			return strData1 < strData2
 		}
 
-		num1, err1 := strconv.ParseFloat(strData1, 64)
-		if err1 != nil {
-			log.Fatal("convert string to float failed", zap.String("column", col.Name.O), zap.String("data", strData1), zap.Error(err1))
-		}
-		num2, err2 := strconv.ParseFloat(strData2, 64)
-		if err2 != nil {
-			log.Fatal("convert string to float failed", zap.String("column", col.Name.O), zap.String("data", strData2), zap.Error(err2))
+		cmp, err := utils.CompareNumbers(col, strData1, strData2)
+		if err != nil {
+			log.Fatal("convert string to number failed", zap.String("column", col.Name.O), zap.String("data1", strData1), zap.String("data2", strData2), zap.Error(err))
 		}
 
-		if num1 == num2 {
+		if cmp == 0 {
 			continue
 		}
-		return num1 < num2
+		return cmp < 0
 
 	}
 
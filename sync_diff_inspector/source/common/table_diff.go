@@ -15,6 +15,7 @@ package common
 
 import (
 	"database/sql"
+	"strings"
 
 	"github.com/pingcap/tidb/parser/model"
 )
@@ -62,7 +63,115 @@ type TableDiff struct {
 	// the table has column timestamp, which need to reset time_zone.
 	NeedUnifiedTimeZone bool `json:"-"`
 
+	// the table has no primary key or unique key, so all the columns are used
+	// as the order key to compare rows, and the chunks can't be split by binary search.
+	NoPKFallback bool `json:"-"`
+
+	// IsView means the table is an updatable view on the target whose data is compared by check-view-data.
+	IsView bool `json:"-"`
+
 	Collation string `json:"collation"`
 
 	ChunkSize int64 `json:"chunk-size"`
+
+	// the statements to fix the different rows, see `config.FixSQLModeReplace`.
+	FixSQLMode string `json:"-"`
+
+	// the number of chunks of the table compared concurrently, 0 means the global `check-thread-count`.
+	Concurrency int `json:"-"`
+
+	// log the checksum sql and the row comparison sql of each chunk.
+	LogSQL bool `json:"-"`
+
+	// the built-in transforms of the columns applied to the source values before comparison,
+	// see `config.ColumnTransformLower`.
+	ColumnTransforms map[string]string `json:"-"`
+	// the CHAR and VARCHAR columns whose trailing spaces are removed on both sides by trim-char-padding.
+	TrimmedColumns []string `json:"-"`
+	// the ENUM and SET columns whose members are in different orders on both sides, which are transformed on both
+	// sides by `config.ColumnTransformEnumValue` and `config.ColumnTransformSetValue`, or by
+	// `config.ColumnTransformEnumIndex` if EnumByIndex is true.
+	ReorderedEnumColumns []string `json:"-"`
+	EnumByIndex          bool     `json:"-"`
+	// the members of the reordered ENUM and SET columns of the first source table if EnumByIndex is true,
+	// `column` => members, by which the indexes of the source rows are converted back to the values in the fix sql.
+	SourceEnumMembers map[string][]string `json:"-"`
+	// the GEOMETRY columns transformed on both sides by `config.ColumnTransformGeometryWKB` by compare-geometry.
+	GeometryColumns []string `json:"-"`
+	// the columns whose source values are transcoded to the charset of the target by charset-map, `column` => `charset`,
+	// which are converted by `config.ColumnTransformUTF8MB4` on both sides.
+	TranscodedColumns map[string]string `json:"-"`
+	// the UUID and INET columns of the MariaDB sources, which are parsed as the string columns and converted by
+	// `config.ColumnTransformChar` on both sides.
+	StringColumns []string `json:"-"`
+
+	// the secondary indices whose checksums are compared chunk by chunk by check-index, which are read by
+	// `FORCE INDEX` to find the indices inconsistent with the data.
+	CheckIndices []*model.IndexInfo `json:"-"`
+
+	// split the chunks partition by partition, it's turned off if the table is not partitioned
+	// by the same partition names on both sides.
+	SplitByPartition bool `json:"-"`
+	// split the chunks by the sampled distribution of the split fields to hold roughly equal rows, rather than
+	// by the random values of them.
+	AdaptiveChunking bool `json:"-"`
+
+	// the size of the table estimated by the target, by which the tables are compared in the descending order by
+	// chunk-order, 0 if unknown or the tables are compared in the order of the config.
+	Size int64 `json:"-"`
+
+	// the number of the shards routed to the table, and the number of them whose structures are checked by
+	// shard-struct-sample, the structures of the other shards are assumed to be equal. Both are 0 if all are checked.
+	Shards              int `json:"-"`
+	StructCheckedShards int `json:"-"`
+}
+
+// HasRange returns true if only the rows in the range of the table config are compared, the range is "TRUE" if it
+// isn't set.
+func (t *TableDiff) HasRange() bool {
+	return len(t.Range) > 0 && !strings.EqualFold(t.Range, "TRUE")
+}
+
+// MissingTable is a table which only exists on one side after applying the filter and the route rules.
+type MissingTable struct {
+	// Schema and Table are the names of the table on the target.
+	Schema string
+	Table  string
+	// MissingOnTarget is true if the table only exists on the sources, otherwise it only exists on the target.
+	MissingOnTarget bool
+	// SourceIndex, SourceSchema and SourceTable are the first source table of the table missing on the target.
+	SourceIndex  int
+	SourceSchema string
+	SourceTable  string
+}
+
+// SkippedObject is an object matched by the filter which can't be compared, e.g. a sequence or a table of the FEDERATED
+// engine, so it's excluded from the comparison.
+type SkippedObject struct {
+	// Schema and Table are the names of the object on the target.
+	Schema string
+	Table  string
+	// OnSource is true if the object is on the sources, and OriginSchema and OriginTable are its names on the source.
+	OnSource     bool
+	OriginSchema string
+	OriginTable  string
+	// Type and Engine are the `TABLE_TYPE` and the `ENGINE` of the object in `information_schema.TABLES`.
+	Type   string
+	Engine string
+	Reason string
+}
+
+// ViewDiff saves the definitions of a view on the sources and the target, the data of the views is only compared
+// by check-view-data as a `TableDiff` with `IsView`.
+type ViewDiff struct {
+	// Schema and View are the names of the view on the target.
+	Schema string
+	View   string
+
+	// SourceDefinitions are the definitions of the views on the sources routed to the view, one for each view,
+	// which is empty if the definitions are not compared. It's empty if the view is missing on the sources.
+	SourceDefinitions []string
+	// TargetDefinition is the definition of the view on the target, empty if the definitions are not compared.
+	TargetDefinition string
+	MissingOnTarget  bool
 }
@@ -0,0 +1,113 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/utils"
+	"github.com/pingcap/tidb/parser"
+	"github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+)
+
+var (
+	// createTableNameRegexp matches the table name in the `CREATE TABLE` statement of `SHOW CREATE TABLE`.
+	createTableNameRegexp = regexp.MustCompile("^(?i)CREATE TABLE (?:`(?:[^`]|``)+`\\.)?`((?:[^`]|``)+)`")
+	// autoIDOptionRegexp matches the table options of the next auto ids, which differ between the shards.
+	autoIDOptionRegexp = regexp.MustCompile(` AUTO_INCREMENT=\d+| /\*T!\[auto_rand_base\] AUTO_RANDOM_BASE=\d+ \*/`)
+)
+
+// TableInfoCache caches the table infos parsed from the `CREATE TABLE` statements by the normalized statements,
+// so the tables with the identical structures, e.g. the shards of a shard-merge group, are parsed once.
+type TableInfoCache struct {
+	mu    sync.Mutex
+	infos map[string]*parsedTableInfo
+
+	hits   int64
+	misses int64
+}
+
+// parsedTableInfo is parsed by the first caller, and the concurrent callers wait for it.
+type parsedTableInfo struct {
+	once sync.Once
+	info *model.TableInfo
+	err  error
+}
+
+// NewTableInfoCache returns an empty TableInfoCache.
+func NewTableInfoCache() *TableInfoCache {
+	return &TableInfoCache{infos: make(map[string]*parsedTableInfo)}
+}
+
+// NormalizeCreateTableSQL returns the `CREATE TABLE` statement without the table name and the next auto ids,
+// so the statements of the tables with the identical structures are the same.
+func NormalizeCreateTableSQL(createTableSQL string) string {
+	normalized := createTableNameRegexp.ReplaceAllString(createTableSQL, "CREATE TABLE ``")
+	// the table options are in the line closing the column definitions.
+	start := strings.Index(normalized, "\n)")
+	if start < 0 {
+		return normalized
+	}
+	end := strings.Index(normalized[start+1:], "\n")
+	if end < 0 {
+		end = len(normalized)
+	} else {
+		end += start + 1
+	}
+	return normalized[:start] + autoIDOptionRegexp.ReplaceAllString(normalized[start:end], "") + normalized[end:]
+}
+
+// GetTableInfo returns the table info parsed from the `CREATE TABLE` statement in the sql mode, which is parsed once
+// for the same normalized statement. The returned table info is a copy named by the statement, so the callers can
+// modify it, e.g. `utils.ResetColumns`.
+func (c *TableInfoCache) GetTableInfo(createTableSQL string, sqlMode mysql.SQLMode) (*model.TableInfo, error) {
+	key := fmt.Sprintf("%d\n%s", sqlMode, NormalizeCreateTableSQL(createTableSQL))
+	c.mu.Lock()
+	parsed, ok := c.infos[key]
+	if !ok {
+		parsed = &parsedTableInfo{}
+		c.infos[key] = parsed
+	}
+	c.mu.Unlock()
+	if ok {
+		atomic.AddInt64(&c.hits, 1)
+	} else {
+		atomic.AddInt64(&c.misses, 1)
+	}
+	parsed.once.Do(func() {
+		// the parser isn't safe for concurrent use, so it's created for each statement.
+		p := parser.New()
+		p.SetSQLMode(sqlMode)
+		parsed.info, parsed.err = utils.GetTableInfoBySQL(createTableSQL, p)
+	})
+	if parsed.err != nil {
+		return nil, errors.Trace(parsed.err)
+	}
+	info := parsed.info.Clone()
+	if match := createTableNameRegexp.FindStringSubmatch(createTableSQL); match != nil {
+		info.Name = model.NewCIStr(strings.ReplaceAll(match[1], "``", "`"))
+	}
+	return info, nil
+}
+
+// Stats returns the number of the statements found in the cache and the ones parsed.
+func (c *TableInfoCache) Stats() (hits int64, misses int64) {
+	return atomic.LoadInt64(&c.hits), atomic.LoadInt64(&c.misses)
+}
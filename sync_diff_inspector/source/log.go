@@ -0,0 +1,48 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package source
+
+import (
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/splitter"
+	"go.uber.org/zap"
+)
+
+// ChunkLogFields returns the fields to identify the chunk in the logs followed by `fields`,
+// so that the logs of a chunk can be filtered by `chunk_id`, `schema`, `table` and `range`.
+func ChunkLogFields(table *common.TableDiff, rangeInfo *splitter.RangeInfo, fields ...zap.Field) []zap.Field {
+	chunkFields := make([]zap.Field, 0, 4+len(fields))
+	chunkFields = append(chunkFields,
+		zap.String("chunk_id", rangeInfo.ChunkRange.Index.ToString()),
+		zap.String("schema", table.Schema),
+		zap.String("table", table.Table),
+		zap.String("range", rangeInfo.ChunkRange.BoundString()),
+	)
+	return append(chunkFields, fields...)
+}
+
+// logChunkSQL logs the sql executed for the chunk with the bound values,
+// it's logged in info level if `log-sql` is set, otherwise in debug level.
+func logChunkSQL(msg string, table *common.TableDiff, rangeInfo *splitter.RangeInfo, query string, args []interface{}) {
+	if !table.LogSQL && log.GetLevel() > zap.DebugLevel {
+		return
+	}
+	fields := ChunkLogFields(table, rangeInfo, zap.String("sql", query), zap.Reflect("args", args))
+	if table.LogSQL {
+		log.Info(msg, fields...)
+	} else {
+		log.Debug(msg, fields...)
+	}
+}
@@ -48,6 +48,16 @@ func (a *MySQLTableAnalyzer) AnalyzeSplitter(ctx context.Context, table *common.
 	originTable.Schema = matchedSources[0].OriginSchema
 	originTable.Table = matchedSources[0].OriginTable
 	progressID := dbutil.TableName(table.Schema, table.Table)
+	if table.NoPKFallback {
+		return splitter.NewWholeTableIteratorWithCheckpoint(progressID, &originTable, startRange), nil
+	}
+	if table.SplitByPartition {
+		partitionIter, err := splitter.NewPartitionIteratorWithCheckpoint(ctx, progressID, &originTable, matchedSources[0].DBConn, startRange)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return partitionIter, nil
+	}
 	// use random splitter if we cannot use bucket splitter, then we can simply choose target table to generate chunks.
 	randIter, err := splitter.NewRandomIteratorWithCheckpoint(ctx, progressID, &originTable, matchedSources[0].DBConn, startRange)
 	if err != nil {
@@ -60,6 +70,23 @@ type MySQLSources struct {
 	tableDiffs []*common.TableDiff
 
 	sourceTablesMap map[string][]*common.TableShardSource
+
+	// applyColumnTransforms is true if the column transforms of the tables are applied to the values.
+	applyColumnTransforms bool
+	// tableInfoCache caches the structures of the source tables for the concurrent struct check.
+	tableInfoCache *tableInfoCache
+	// only check the structures of shardStructSample shards of each table, 0 means all the shards are checked.
+	shardStructSample int
+}
+
+func (s *MySQLSources) columnTransforms(table *common.TableDiff) map[string]string {
+	// the trailing spaces, the reordered ENUM and SET columns, the GEOMETRY columns, the transcoded columns and the
+	// UUID and INET columns of MariaDB are transformed on both sides, and the column transforms are only applied to
+	// the source.
+	if !s.applyColumnTransforms {
+		return withStringColumns(table, withTranscodedColumns(table, withGeometryColumns(table, withEnumColumns(table, withTrimmedColumns(table, nil)))))
+	}
+	return withStringColumns(table, withTranscodedColumns(table, withGeometryColumns(table, withEnumColumns(table, withTrimmedColumns(table, table.ColumnTransforms)))))
 }
 
 func getMatchedSourcesForTable(sourceTablesMap map[string][]*common.TableShardSource, table *common.TableDiff) []*common.TableShardSource {
@@ -84,6 +111,7 @@ func (s *MySQLSources) GetRangeIterator(ctx context.Context, r *splitter.RangeIn
 }
 
 func (s *MySQLSources) Close() {
+	s.tableInfoCache.logStats()
 	for _, t := range s.sourceTablesMap {
 		for _, db := range t {
 			db.DBConn.Close()
@@ -101,7 +129,10 @@ func (s *MySQLSources) GetCountAndCrc32(ctx context.Context, tableRange *splitte
 
 	for _, ms := range matchSources {
 		go func(ms *common.TableShardSource) {
-			count, checksum, err := utils.GetCountAndCRC32Checksum(ctx, ms.DBConn, ms.OriginSchema, ms.OriginTable, table.Info, chunk.Where, chunk.Args)
+			if table.LogSQL {
+				logChunkSQL("count and checksum", table, tableRange, utils.GetCountAndCRC32ChecksumSQL(ms.OriginSchema, ms.OriginTable, chunk.Partition, table.Info, s.columnTransforms(table), chunk.Where), chunk.Args)
+			}
+			count, checksum, err := utils.GetCountAndCRC32Checksum(ctx, ms.DBConn, ms.OriginSchema, ms.OriginTable, chunk.Partition, table.Info, s.columnTransforms(table), chunk.Where, chunk.Args)
 			infoCh <- &ChecksumInfo{
 				Checksum: checksum,
 				Count:    count,
@@ -136,22 +167,105 @@ func (s *MySQLSources) GetCountAndCrc32(ctx context.Context, tableRange *splitte
 	}
 }
 
+// GetIndexCountAndCrc32 gets the index checksum of each shard table merged into the table, which are combined
+// in the same way as `GetCountAndCrc32`.
+func (s *MySQLSources) GetIndexCountAndCrc32(ctx context.Context, tableRange *splitter.RangeInfo, index *model.IndexInfo) *ChecksumInfo {
+	beginTime := time.Now()
+	table := s.tableDiffs[tableRange.GetTableIndex()]
+	chunk := tableRange.GetChunk()
+
+	var (
+		err           error
+		totalCount    int64
+		totalChecksum int64
+	)
+	for _, ms := range getMatchedSourcesForTable(s.sourceTablesMap, table) {
+		if table.LogSQL {
+			logChunkSQL("index count and checksum", table, tableRange, utils.GetIndexCountAndCRC32ChecksumSQL(ms.OriginSchema, ms.OriginTable, chunk.Partition, table.Info, index, s.columnTransforms(table), chunk.Where), chunk.Args)
+		}
+		count, checksum, shardErr := utils.GetIndexCountAndCRC32Checksum(ctx, ms.DBConn, ms.OriginSchema, ms.OriginTable, chunk.Partition, table.Info, index, s.columnTransforms(table), chunk.Where, chunk.Args)
+		if shardErr != nil {
+			err = shardErr
+			break
+		}
+		totalCount += count
+		totalChecksum ^= checksum
+	}
+	return &ChecksumInfo{
+		Checksum: totalChecksum,
+		Count:    totalCount,
+		Err:      err,
+		Cost:     time.Since(beginTime),
+	}
+}
+
+// GetCount gets the total row count of the shard tables merged into the table.
+func (s *MySQLSources) GetCount(ctx context.Context, tableIndex int) (int64, error) {
+	table := s.tableDiffs[tableIndex]
+	totalCount := int64(0)
+	for _, ms := range getMatchedSourcesForTable(s.sourceTablesMap, table) {
+		count, err := dbutil.GetRowCount(ctx, ms.DBConn, ms.OriginSchema, ms.OriginTable, table.Range, nil)
+		if err != nil {
+			return 0, errors.Trace(err)
+		}
+		totalCount += count
+	}
+	return totalCount, nil
+}
+
+// GetDuplicateKeys gets the duplicate keys of each shard table merged into the table, the keys duplicate across
+// the shards are not found.
+func (s *MySQLSources) GetDuplicateKeys(ctx context.Context, tableIndex int, limit int) ([]*utils.DuplicateKey, error) {
+	table := s.tableDiffs[tableIndex]
+	_, keys := dbutil.SelectUniqueOrderKey(table.Info)
+	duplicateKeys := make([]*utils.DuplicateKey, 0)
+	for _, ms := range getMatchedSourcesForTable(s.sourceTablesMap, table) {
+		shardKeys, err := utils.GetDuplicateKeys(ctx, ms.DBConn, ms.OriginSchema, ms.OriginTable, keys, table.Range, nil, limit-len(duplicateKeys))
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		duplicateKeys = append(duplicateKeys, shardKeys...)
+		if len(duplicateKeys) >= limit {
+			break
+		}
+	}
+	return duplicateKeys, nil
+}
+
+// GetAdminChecksum isn't supported by the MySQL sources, the checksums of the shards can't be merged
+// into the one of the table merged from them.
+func (s *MySQLSources) GetAdminChecksum(ctx context.Context, tableIndex int) (*utils.TableChecksum, error) {
+	return nil, errors.NotSupportedf("ADMIN CHECKSUM TABLE of the MySQL sources")
+}
+
 func (s *MySQLSources) GetTables() []*common.TableDiff {
 	return s.tableDiffs
 }
 
-func (s *MySQLSources) GenerateFixSQL(t DMLType, upstreamData, downstreamData map[string]*dbutil.ColumnData, tableIndex int) string {
-	switch t {
-	case Insert:
-		return utils.GenerateReplaceDML(upstreamData, s.tableDiffs[tableIndex].Info, s.tableDiffs[tableIndex].Schema)
-	case Delete:
-		return utils.GenerateDeleteDML(downstreamData, s.tableDiffs[tableIndex].Info, s.tableDiffs[tableIndex].Schema)
-	case Replace:
-		return utils.GenerateReplaceDMLWithAnnotation(upstreamData, downstreamData, s.tableDiffs[tableIndex].Info, s.tableDiffs[tableIndex].Schema)
-	default:
-		log.Fatal("Don't support this type", zap.Any("dml type", t))
+// GenerateFixSQL generates the fix sql for this source, the table name is the origin name before routing.
+// It fails for the table merged from the shards, because the destination shard is ambiguous.
+func (s *MySQLSources) GenerateFixSQL(t DMLType, upstreamData, downstreamData map[string]*dbutil.ColumnData, tableIndex int) (string, error) {
+	table := s.tableDiffs[tableIndex]
+	matchSources := getMatchedSourcesForTable(s.sourceTablesMap, table)
+	if len(matchSources) > 1 {
+		return "", errors.Errorf("cannot generate fix sql on the sources for the table %s merged from %d shards, because the destination shard is ambiguous",
+			dbutil.TableName(table.Schema, table.Table), len(matchSources))
+	}
+	originSchema, originTable := matchSources[0].OriginSchema, matchSources[0].OriginTable
+	tableInfo := getOriginTableInfo(table.Info, originTable)
+	return generateFixDML(t, table.FixSQLMode, upstreamData, downstreamData, tableInfo, originSchema), nil
+}
+
+// checkFixTarget returns an error if any table is merged from more than one shard, whose fix sql can't be
+// generated on the sources by fix-target = "source".
+func (s *MySQLSources) checkFixTarget() error {
+	for _, table := range s.tableDiffs {
+		if matchSources := getMatchedSourcesForTable(s.sourceTablesMap, table); len(matchSources) > 1 {
+			return errors.Errorf("fix-target = \"source\" doesn't support the shard merge, the table %s is merged from %d source tables, "+
+				"so the destination shard of the fix sql is ambiguous", dbutil.TableName(table.Schema, table.Table), len(matchSources))
+		}
 	}
-	return ""
+	return nil
 }
 
 func (s *MySQLSources) GetRowsIterator(ctx context.Context, tableRange *splitter.RangeInfo) (RowDataIterator, error) {
@@ -165,8 +279,9 @@ func (s *MySQLSources) GetRowsIterator(ctx context.Context, tableRange *splitter
 	var rowsQuery string
 	var orderKeyCols []*model.ColumnInfo
 	for i, ms := range matchSources {
-		rowsQuery, orderKeyCols = utils.GetTableRowsQueryFormat(ms.OriginSchema, ms.OriginTable, table.Info, table.Collation)
+		rowsQuery, orderKeyCols = utils.GetTableRowsQueryFormat(ms.OriginSchema, ms.OriginTable, chunk.Partition, table.Info, s.columnTransforms(table), table.Collation)
 		query := fmt.Sprintf(rowsQuery, chunk.Where)
+		logChunkSQL("select data", table, tableRange, query, chunk.Args)
 		rows, err := ms.DBConn.QueryContext(ctx, query, chunk.Args...)
 		if err != nil {
 			return nil, errors.Trace(err)
@@ -214,6 +329,11 @@ func (s *MySQLSources) GetDB() *sql.DB {
 	return nil
 }
 
+// WithoutSnapshot returns the sources themselves, since MySQL doesn't have the snapshot.
+func (s *MySQLSources) WithoutSnapshot(ctx context.Context) (Source, error) {
+	return s, nil
+}
+
 func (s *MySQLSources) GetSnapshot() string {
 	log.Fatal("unreachable!, mysql doesn't have the snapshot")
 	return ""
@@ -222,19 +342,65 @@ func (s *MySQLSources) GetSnapshot() string {
 func (s *MySQLSources) GetSourceStructInfo(ctx context.Context, tableIndex int) ([]*model.TableInfo, error) {
 	tableDiff := s.GetTables()[tableIndex]
 	tableSources := getMatchedSourcesForTable(s.sourceTablesMap, tableDiff)
+	if s.shardStructSample > 0 && len(tableSources) > s.shardStructSample {
+		tableDiff.Shards, tableDiff.StructCheckedShards = len(tableSources), s.shardStructSample
+		tableSources = sampleShards(tableSources, s.shardStructSample)
+	}
 	sourceTableInfos := make([]*model.TableInfo, len(tableSources))
 	for i, tableSource := range tableSources {
 		sourceSchema, sourceTable := tableSource.OriginSchema, tableSource.OriginTable
-		sourceTableInfo, err := dbutil.GetTableInfo(ctx, tableSource.DBConn, sourceSchema, sourceTable)
+		sourceTableInfo, err := s.tableInfoCache.GetTableInfo(ctx, tableSource.DBConn, sourceSchema, sourceTable)
 		if err != nil {
 			return nil, errors.Trace(err)
 		}
 		sourceTableInfo, _ = utils.ResetColumns(sourceTableInfo, tableDiff.IgnoreColumns)
 		sourceTableInfos[i] = sourceTableInfo
 	}
+	stringColumns, err := s.getMariaDBStringColumns(ctx, tableDiff, tableSources)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	tableDiff.StringColumns = stringColumns
 	return sourceTableInfos, nil
 }
 
+// getMariaDBStringColumns returns the names on the target of the UUID and INET columns of the source tables on
+// MariaDB, which are compared as the strings.
+func (s *MySQLSources) getMariaDBStringColumns(ctx context.Context, tableDiff *common.TableDiff, tableSources []*common.TableShardSource) ([]string, error) {
+	var columns []string
+	seen := make(map[string]struct{})
+	for _, tableSource := range tableSources {
+		// the cached statement is returned, which has been fetched for the table info.
+		createTableSQL, err := s.tableInfoCache.GetCreateTableSQL(ctx, tableSource.DBConn, tableSource.OriginSchema, tableSource.OriginTable)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		for _, column := range utils.GetMariaDBStringColumns(createTableSQL) {
+			col := dbutil.FindColumnByName(tableDiff.Info.Columns, column)
+			if col == nil {
+				continue
+			}
+			if _, ok := seen[col.Name.O]; !ok {
+				seen[col.Name.O] = struct{}{}
+				columns = append(columns, col.Name.O)
+			}
+		}
+	}
+	if len(columns) > 0 {
+		log.Info("the UUID and INET columns of MariaDB are compared as the strings", zap.String("table", dbutil.TableName(tableDiff.Schema, tableDiff.Table)), zap.Strings("columns", columns))
+	}
+	return columns, nil
+}
+
+// sampleShards returns `sample` shards evenly spaced in the shards from the first one.
+func sampleShards(shards []*common.TableShardSource, sample int) []*common.TableShardSource {
+	sampled := make([]*common.TableShardSource, 0, sample)
+	for i := 0; i < sample; i++ {
+		sampled = append(sampled, shards[i*len(shards)/sample])
+	}
+	return sampled
+}
+
 type MultiSourceRowsIterator struct {
 	sourceRows     map[int]*sql.Rows
 	sourceRowDatas *common.RowDatas
@@ -245,7 +411,8 @@ func getRowData(rows *sql.Rows) (rowData map[string]*dbutil.ColumnData, err erro
 		rowData, err = dbutil.ScanRow(rows)
 		return
 	}
-	return
+	// the rows may be closed by canceling the context.
+	return nil, errors.Trace(rows.Err())
 }
 
 func (ms *MultiSourceRowsIterator) Next() (map[string]*dbutil.ColumnData, error) {
@@ -289,6 +456,7 @@ func NewMySQLSources(ctx context.Context, tableDiffs []*common.TableDiff, ds []*
 		uniqueMap[utils.UniqueID(tableDiff.Schema, tableDiff.Table)] = struct{}{}
 	}
 
+	withViews := hasViewDiffs(tableDiffs)
 	for i, sourceDB := range ds {
 		sourceSchemas, err := dbutil.GetSchemas(ctx, sourceDB.Conn)
 		if err != nil {
@@ -302,7 +470,7 @@ func NewMySQLSources(ctx context.Context, tableDiffs []*common.TableDiff, ds []*
 			if filter.IsSystemSchema(schema) {
 				continue
 			}
-			allTables, err := dbutil.GetTables(ctx, sourceDB.Conn, schema)
+			allTables, err := getSourceTables(ctx, sourceDB.Conn, schema, withViews)
 			if err != nil {
 				return nil, errors.Annotatef(err, "get tables from %d source %s", i, schema)
 			}
@@ -355,6 +523,7 @@ func NewMySQLSources(ctx context.Context, tableDiffs []*common.TableDiff, ds []*
 	mss := &MySQLSources{
 		tableDiffs:      tableDiffs,
 		sourceTablesMap: sourceTablesMap,
+		tableInfoCache:  newTableInfoCache(),
 	}
 	return mss, nil
 }
@@ -0,0 +1,167 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package source
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb-tools/pkg/filter"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/utils"
+)
+
+// comparableEngines are the InnoDB-like engines whose tables are compared by default, the names are in lower case.
+var comparableEngines = map[string]struct{}{
+	"innodb":  {},
+	"rocksdb": {},
+	"tokudb":  {},
+}
+
+// GetSkippedObjects returns the objects matched by `check-tables` on the target which can't be compared, and the ones
+// on the sources routed to the tables matched by `check-tables`, sorted by the names on the target, the objects on the
+// target come first. The tables of the engines in `force-include-engines` are compared.
+func GetSkippedObjects(ctx context.Context, cfg *config.Config) ([]*common.SkippedObject, error) {
+	skippedObjects := make([]*common.SkippedObject, 0)
+	targetObjects, err := getObjects(ctx, cfg.Task.TargetInstance.Conn)
+	if err != nil {
+		return nil, errors.Annotate(err, "get objects from target source")
+	}
+	for _, object := range targetObjects {
+		if !cfg.Task.TargetCheckTables.MatchTable(object.OriginSchema, object.OriginTable) {
+			continue
+		}
+		if object.Reason = getSkipReason(object.Type, object.Engine, cfg.ForceIncludeEngines); len(object.Reason) > 0 {
+			object.Schema, object.Table = object.OriginSchema, object.OriginTable
+			skippedObjects = append(skippedObjects, object)
+		}
+	}
+
+	for i, source := range cfg.Task.SourceInstances {
+		sourceObjects, err := getObjects(ctx, source.Conn)
+		if err != nil {
+			return nil, errors.Annotatef(err, "get objects from %d source", i)
+		}
+		for _, object := range sourceObjects {
+			object.Reason = getSkipReason(object.Type, object.Engine, cfg.ForceIncludeEngines)
+			if len(object.Reason) == 0 {
+				continue
+			}
+			object.Schema, object.Table = object.OriginSchema, object.OriginTable
+			if source.Router != nil {
+				object.Schema, object.Table, err = source.Router.Route(object.OriginSchema, object.OriginTable)
+				if err != nil {
+					return nil, errors.Errorf("get route result for %d source %s.%s failed, error %v", i, object.OriginSchema, object.OriginTable, err)
+				}
+			}
+			if !cfg.Task.TargetCheckTables.MatchTable(object.Schema, object.Table) {
+				continue
+			}
+			object.OnSource = true
+			skippedObjects = append(skippedObjects, object)
+		}
+	}
+
+	sort.SliceStable(skippedObjects, func(i, j int) bool {
+		ti := utils.UniqueID(skippedObjects[i].Schema, skippedObjects[i].Table)
+		tj := utils.UniqueID(skippedObjects[j].Schema, skippedObjects[j].Table)
+		if ti != tj {
+			return ti < tj
+		}
+		return !skippedObjects[i].OnSource && skippedObjects[j].OnSource
+	})
+	return skippedObjects, nil
+}
+
+// getSkippedTables returns the unique ids of the tables on the target which aren't compared because of the skipped objects,
+// i.e. the table itself or any source table routed to it can't be compared.
+func getSkippedTables(skippedObjects []*common.SkippedObject) map[string]struct{} {
+	skippedTables := make(map[string]struct{}, len(skippedObjects))
+	for _, object := range skippedObjects {
+		skippedTables[utils.UniqueID(object.Schema, object.Table)] = struct{}{}
+	}
+	return skippedTables
+}
+
+// getObjects returns the objects other than the views in the database with their types and engines,
+// the objects in the system schemas are excluded.
+func getObjects(ctx context.Context, db *sql.DB) ([]*common.SkippedObject, error) {
+	/*
+		example:
+		mysql> SELECT TABLE_SCHEMA, TABLE_NAME, TABLE_TYPE, ENGINE FROM information_schema.TABLES WHERE TABLE_TYPE <> 'VIEW';
+		+--------------+------------+------------+--------+
+		| TABLE_SCHEMA | TABLE_NAME | TABLE_TYPE | ENGINE |
+		+--------------+------------+------------+--------+
+		| test         | t          | BASE TABLE | InnoDB |
+		| test         | seq        | SEQUENCE   | InnoDB |
+		+--------------+------------+------------+--------+
+	*/
+	rows, err := db.QueryContext(ctx, "SELECT TABLE_SCHEMA, TABLE_NAME, TABLE_TYPE, ENGINE FROM information_schema.TABLES WHERE TABLE_TYPE <> 'VIEW' ORDER BY TABLE_SCHEMA, TABLE_NAME")
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer rows.Close()
+	objects := make([]*common.SkippedObject, 0)
+	for rows.Next() {
+		var schema, name, tableType string
+		var engine sql.NullString
+		if err := rows.Scan(&schema, &name, &tableType, &engine); err != nil {
+			return nil, errors.Trace(err)
+		}
+		if filter.IsSystemSchema(schema) {
+			continue
+		}
+		objects = append(objects, &common.SkippedObject{OriginSchema: schema, OriginTable: name, Type: tableType, Engine: engine.String})
+	}
+	return objects, errors.Trace(rows.Err())
+}
+
+// getSkipReason returns the reason why the object of the type and the engine can't be compared,
+// which is empty if the object can be compared.
+func getSkipReason(tableType, engine string, forceIncludeEngines []string) string {
+	switch strings.ToUpper(tableType) {
+	case "", "BASE TABLE":
+	case "SEQUENCE":
+		return "sequence can't be compared"
+	default:
+		return fmt.Sprintf("%s can't be compared", strings.ToLower(tableType))
+	}
+	// the engine is unknown, e.g. the table is broken.
+	if len(engine) == 0 {
+		return ""
+	}
+	if _, ok := comparableEngines[strings.ToLower(engine)]; ok {
+		return ""
+	}
+	for _, e := range forceIncludeEngines {
+		if strings.EqualFold(e, engine) {
+			return ""
+		}
+	}
+	switch strings.ToUpper(engine) {
+	case "FEDERATED":
+		return "the rows of FEDERATED table are on the remote server, add it to force-include-engines to compare it"
+	case "BLACKHOLE":
+		return "BLACKHOLE table stores no rows, add it to force-include-engines to compare it"
+	case "MEMORY":
+		return "the rows of MEMORY table are lost on restart, add it to force-include-engines to compare it"
+	default:
+		return fmt.Sprintf("engine %s isn't InnoDB-like, add it to force-include-engines to compare it", engine)
+	}
+}
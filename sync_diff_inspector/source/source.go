@@ -31,6 +31,7 @@ import (
 	"github.com/pingcap/tidb-tools/sync_diff_inspector/splitter"
 	"github.com/pingcap/tidb-tools/sync_diff_inspector/utils"
 	"github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
 	"go.uber.org/zap"
 )
 
@@ -80,11 +81,27 @@ type Source interface {
 	// GetCountAndCrc32 gets the crc32 result and the count from given range.
 	GetCountAndCrc32(context.Context, *splitter.RangeInfo) *ChecksumInfo
 
+	// GetIndexCountAndCrc32 gets the crc32 result and the count of the columns of the index from given range,
+	// which are read from the index, see `utils.GetIndexCountAndCRC32Checksum`.
+	GetIndexCountAndCrc32(ctx context.Context, tableRange *splitter.RangeInfo, index *model.IndexInfo) *ChecksumInfo
+
+	// GetCount gets the row count of the table in the range of the table config, without the checksum.
+	GetCount(ctx context.Context, tableIndex int) (int64, error)
+
+	// GetDuplicateKeys gets at most `limit` values of the unique key shared by more than one row in the range
+	// of the table config, see `utils.GetDuplicateKeys`.
+	GetDuplicateKeys(ctx context.Context, tableIndex int, limit int) ([]*utils.DuplicateKey, error)
+
+	// GetAdminChecksum gets the result of `ADMIN CHECKSUM TABLE` of the whole table at the snapshot,
+	// which is only supported by TiDB, see `utils.GetAdminChecksum`.
+	GetAdminChecksum(ctx context.Context, tableIndex int) (*utils.TableChecksum, error)
+
 	// GetRowsIterator gets the row data iterator from given range.
 	GetRowsIterator(context.Context, *splitter.RangeInfo) (RowDataIterator, error)
 
 	// GenerateFixSQL generates the fix sql with given type.
-	GenerateFixSQL(DMLType, map[string]*dbutil.ColumnData, map[string]*dbutil.ColumnData, int) string
+	// The schema and table in the sql are the origin names of this source.
+	GenerateFixSQL(DMLType, map[string]*dbutil.ColumnData, map[string]*dbutil.ColumnData, int) (string, error)
 
 	// GetTables represents the tableDiffs.
 	GetTables() []*common.TableDiff
@@ -95,6 +112,10 @@ type Source interface {
 	// GetDB represents the db connection.
 	GetDB() *sql.DB
 
+	// WithoutSnapshot returns the source reading the latest data rather than the snapshot by the new connections,
+	// which is the source itself if the snapshot isn't set. The returned source should be closed if it's new.
+	WithoutSnapshot(ctx context.Context) (Source, error)
+
 	// GetSnapshot represents the snapshot of source.
 	// only TiDB source has the snapshot.
 	// TODO refine the interface.
@@ -104,20 +125,75 @@ type Source interface {
 	Close()
 }
 
-func NewSources(ctx context.Context, cfg *config.Config) (downstream Source, upstream Source, err error) {
+// NewSources returns the sources of the target and the sources, the tables only exist on one side and the objects
+// which can't be compared, neither of which are compared.
+func NewSources(ctx context.Context, cfg *config.Config) (downstream Source, upstream Source, missingTables []*common.MissingTable, skippedObjects []*common.SkippedObject, err error) {
 	// init db connection for upstream / downstream.
 	err = initDBConn(ctx, cfg)
 	if err != nil {
-		return nil, nil, errors.Trace(err)
+		return nil, nil, nil, nil, errors.Trace(err)
 	}
-	tablesToBeCheck, err := initTables(ctx, cfg)
+	// the objects which can't be compared are skipped before reading their structures, e.g. the sequences.
+	skippedObjects, err = GetSkippedObjects(ctx, cfg)
 	if err != nil {
-		return nil, nil, errors.Trace(err)
+		return nil, nil, nil, nil, errors.Trace(err)
+	}
+	skippedTables := getSkippedTables(skippedObjects)
+	tablesToBeCheck, err := initTables(ctx, cfg, skippedTables)
+	if err != nil {
+		return nil, nil, nil, nil, errors.Trace(err)
 	}
 
 	tableDiffs := make([]*common.TableDiff, 0, len(tablesToBeCheck))
 	for _, tableConfig := range tablesToBeCheck {
 		newInfo, needUnifiedTimeZone := utils.ResetColumns(tableConfig.TargetTableInfo, tableConfig.IgnoreColumns)
+		noPKFallback := !utils.HasUniqueKey(newInfo)
+		fixSQLMode := tableConfig.FixSQLMode
+		if len(fixSQLMode) == 0 {
+			fixSQLMode = cfg.FixSQLMode
+		}
+		if noPKFallback && cfg.CompareNoIndexTables {
+			log.Warn("table has no primary key or unique key, the rows of the whole table are compared as multisets, which may be slow",
+				zap.String("table", dbutil.TableName(tableConfig.Schema, tableConfig.Table)))
+			// the different rows can't be located by the key, so they are fixed by `DELETE ... LIMIT 1` and `INSERT`.
+			fixSQLMode = config.FixSQLModeDeleteInsert
+		}
+		var trimmedColumns []string
+		if cfg.TrimCharPadding {
+			trimmedColumns = utils.GetPaddedCharColumns(newInfo)
+		}
+		var geometryColumns []string
+		if cfg.CompareGeometry {
+			geometryColumns = utils.GetGeometryColumns(newInfo)
+		}
+		// the range may reference the ignored columns, so it's checked against the origin structure.
+		if len(tableConfig.Range) > 0 {
+			if err := utils.CheckRangeColumns(tableConfig.Range, tableConfig.TargetTableInfo); err != nil {
+				return nil, nil, nil, nil, errors.Annotatef(err, "invalid range of table %s", dbutil.TableName(tableConfig.Schema, tableConfig.Table))
+			}
+		}
+		if err := checkColumnTransforms(newInfo, tableConfig.ColumnTransforms); err != nil {
+			return nil, nil, nil, nil, errors.Annotatef(err, "invalid column-transforms of table %s", dbutil.TableName(tableConfig.Schema, tableConfig.Table))
+		}
+		// validate the index-fields against the indices of the table.
+		if fields := utils.ParseIndexFields(strings.Join(tableConfig.Fields, ",")); len(fields) > 0 {
+			index, err := utils.FindIndexByFields(newInfo, fields)
+			if err != nil {
+				return nil, nil, nil, nil, errors.Annotatef(err, "invalid index-fields of table %s", dbutil.TableName(tableConfig.Schema, tableConfig.Table))
+			}
+			if index == nil {
+				log.Warn("the index-fields are not indexed, the chunks are split by the columns randomly, which may be slow",
+					zap.String("table", dbutil.TableName(tableConfig.Schema, tableConfig.Table)), zap.Strings("index-fields", fields))
+			}
+		}
+		checkIndices := make([]*model.IndexInfo, 0, len(tableConfig.CheckIndex))
+		for _, name := range tableConfig.CheckIndex {
+			index := utils.FindIndexByName(newInfo, name)
+			if index == nil {
+				return nil, nil, nil, nil, errors.Errorf("invalid check-index of table %s: index %s is not found or has the ignored columns", dbutil.TableName(tableConfig.Schema, tableConfig.Table), name)
+			}
+			checkIndices = append(checkIndices, index)
+		}
 		tableDiffs = append(tableDiffs, &common.TableDiff{
 			Schema: tableConfig.Schema,
 			Table:  tableConfig.Table,
@@ -127,8 +203,19 @@ func NewSources(ctx context.Context, cfg *config.Config) (downstream Source, ups
 			Fields:              strings.Join(tableConfig.Fields, ","),
 			Range:               tableConfig.Range,
 			NeedUnifiedTimeZone: needUnifiedTimeZone,
+			NoPKFallback:        noPKFallback,
 			Collation:           tableConfig.Collation,
 			ChunkSize:           tableConfig.ChunkSize,
+			FixSQLMode:          fixSQLMode,
+			Concurrency:         tableConfig.Concurrency,
+			LogSQL:              cfg.LogSQL,
+			ColumnTransforms:    tableConfig.ColumnTransforms,
+			TrimmedColumns:      trimmedColumns,
+			GeometryColumns:     geometryColumns,
+			SplitByPartition:    cfg.SplitByPartition,
+			AdaptiveChunking:    cfg.AdaptiveChunking,
+			CheckIndices:        checkIndices,
+			IsView:              tableConfig.IsView,
 		})
 
 		// When the router set case-sensitive false,
@@ -140,13 +227,37 @@ func NewSources(ctx context.Context, cfg *config.Config) (downstream Source, ups
 				TargetSchema:  tableConfig.Schema,
 				TargetTable:   tableConfig.Table,
 			}) != nil {
-				return nil, nil, errors.Errorf("set case unsensitive failed. The schema/table name cannot be parttern. [schema = %s] [table = %s]", tableConfig.Schema, tableConfig.Table)
+				return nil, nil, nil, nil, errors.Errorf("set case unsensitive failed. The schema/table name cannot be parttern. [schema = %s] [table = %s]", tableConfig.Schema, tableConfig.Table)
 			}
 		}
 	}
 
+	missingTables, err = getMissingTables(ctx, cfg, tableDiffs, skippedTables)
+	if err != nil {
+		return nil, nil, nil, nil, errors.Trace(err)
+	}
+	// the tables missing on the sources are not compared.
+	missingOnSource := make(map[string]struct{})
+	for _, table := range missingTables {
+		if !table.MissingOnTarget {
+			log.Warn("the table only exists on the target", zap.String("table", dbutil.TableName(table.Schema, table.Table)))
+			missingOnSource[utils.UniqueID(table.Schema, table.Table)] = struct{}{}
+		} else {
+			log.Warn("the table only exists on the sources", zap.String("table", dbutil.TableName(table.Schema, table.Table)))
+		}
+	}
+	if len(missingOnSource) > 0 {
+		existingTableDiffs := make([]*common.TableDiff, 0, len(tableDiffs)-len(missingOnSource))
+		for _, tableDiff := range tableDiffs {
+			if _, ok := missingOnSource[utils.UniqueID(tableDiff.Schema, tableDiff.Table)]; !ok {
+				existingTableDiffs = append(existingTableDiffs, tableDiff)
+			}
+		}
+		tableDiffs = existingTableDiffs
+	}
+
 	if len(tableDiffs) == 0 {
-		return nil, nil, errors.Errorf("no table need to be compared")
+		return nil, nil, nil, nil, errors.Errorf("no table need to be compared")
 	}
 
 	// Sort TableDiff is important!
@@ -158,25 +269,208 @@ func NewSources(ctx context.Context, cfg *config.Config) (downstream Source, ups
 	})
 	upstream, err = buildSourceFromCfg(ctx, tableDiffs, cfg.CheckThreadCount, cfg.Task.SourceInstances...)
 	if err != nil {
-		return nil, nil, errors.Annotate(err, "from upstream")
+		return nil, nil, nil, nil, errors.Annotate(err, "from upstream")
+	}
+	// the column transforms are only applied to the source values.
+	enableColumnTransforms(upstream)
+	if mss, ok := upstream.(*MySQLSources); ok {
+		mss.shardStructSample = cfg.ShardStructSample
+		if cfg.FixTarget == config.FixTargetSource {
+			if err = mss.checkFixTarget(); err != nil {
+				return nil, nil, nil, nil, errors.Trace(err)
+			}
+		}
 	}
 	downstream, err = buildSourceFromCfg(ctx, tableDiffs, cfg.CheckThreadCount, cfg.Task.TargetInstance)
 	if err != nil {
-		return nil, nil, errors.Annotate(err, "from downstream")
+		return nil, nil, nil, nil, errors.Annotate(err, "from downstream")
+	}
+	if cfg.MaxOpenConns > 0 {
+		setMaxOpenConns(cfg, cfg.MaxOpenConns)
+	}
+	setDataSourceConns(cfg)
+	return downstream, upstream, missingTables, skippedObjects, nil
+}
+
+// setMaxOpenConns overrides the connection limits of the sources and the target decided by `check-thread-count`,
+// which is set after building the sources since the shard sources raise the limits by the number of the shards.
+func setMaxOpenConns(cfg *config.Config, maxOpenConns int) {
+	log.Info("set the connection limit of each database by max-open-conns", zap.Int("max-open-conns", maxOpenConns))
+	for _, instance := range append([]*config.DataSource{cfg.Task.TargetInstance}, cfg.Task.SourceInstances...) {
+		instance.Conn.SetMaxOpenConns(maxOpenConns)
+		instance.Conn.SetMaxIdleConns(maxOpenConns)
+	}
+}
+
+// setDataSourceConns applies the connection settings of each data source, which override the limits decided by
+// `check-thread-count` and the global `max-open-conns`.
+func setDataSourceConns(cfg *config.Config) {
+	for _, instance := range append([]*config.DataSource{cfg.Task.TargetInstance}, cfg.Task.SourceInstances...) {
+		if instance.MaxOpenConns > 0 {
+			instance.Conn.SetMaxOpenConns(instance.MaxOpenConns)
+			instance.Conn.SetMaxIdleConns(instance.MaxOpenConns)
+		}
+		if instance.MaxIdleConns > 0 {
+			instance.Conn.SetMaxIdleConns(instance.MaxIdleConns)
+		}
+		// the lifetime is checked by `Valid`.
+		lifetime, _ := instance.GetConnMaxLifetime()
+		instance.Conn.SetConnMaxLifetime(lifetime)
+	}
+}
+
+// checkColumnTransforms checks the columns of the column transforms exist, and they are not the order keys,
+// because the rows are matched by the order keys.
+func checkColumnTransforms(tableInfo *model.TableInfo, columnTransforms map[string]string) error {
+	if len(columnTransforms) == 0 {
+		return nil
+	}
+	_, orderKeyCols := dbutil.SelectUniqueOrderKey(tableInfo)
+	for column := range columnTransforms {
+		if col := dbutil.FindColumnByName(tableInfo.Columns, column); col == nil || col.Name.O != column {
+			return errors.Errorf("column %s doesn't exist", column)
+		}
+		for _, col := range orderKeyCols {
+			if col.Name.O == column {
+				return errors.Errorf("column %s is the order key of the rows, which can't be transformed", column)
+			}
+		}
+	}
+	return nil
+}
+
+// withTrimmedColumns returns the column transforms with `config.ColumnTransformRTrim` applied to the trimmed columns
+// of the table after the transforms, e.g. "lower,rtrim".
+func withTrimmedColumns(table *common.TableDiff, columnTransforms map[string]string) map[string]string {
+	if len(table.TrimmedColumns) == 0 {
+		return columnTransforms
+	}
+	transforms := make(map[string]string, len(columnTransforms)+len(table.TrimmedColumns))
+	for column, transform := range columnTransforms {
+		transforms[column] = transform
+	}
+	for _, column := range table.TrimmedColumns {
+		if transform, ok := transforms[column]; ok {
+			transforms[column] = transform + "," + config.ColumnTransformRTrim
+		} else {
+			transforms[column] = config.ColumnTransformRTrim
+		}
+	}
+	return transforms
+}
+
+// withEnumColumns returns the column transforms with the transforms of the reordered ENUM and SET columns
+// applied before the others. They are compared by the member strings rather than the stored indexes, which differ
+// on both sides, unless EnumByIndex is true.
+func withEnumColumns(table *common.TableDiff, columnTransforms map[string]string) map[string]string {
+	if len(table.ReorderedEnumColumns) == 0 {
+		return columnTransforms
+	}
+	transforms := make(map[string]string, len(columnTransforms)+len(table.ReorderedEnumColumns))
+	for column, transform := range columnTransforms {
+		transforms[column] = transform
+	}
+	for _, column := range table.ReorderedEnumColumns {
+		enumTransform := config.ColumnTransformEnumIndex
+		if !table.EnumByIndex {
+			col := dbutil.FindColumnByName(table.Info.Columns, column)
+			if col == nil {
+				continue
+			}
+			enumTransform = config.ColumnTransformEnumValue
+			if col.Tp == mysql.TypeSet {
+				enumTransform = config.ColumnTransformSetValue
+			}
+		}
+		if transform, ok := transforms[column]; ok {
+			transforms[column] = enumTransform + "," + transform
+		} else {
+			transforms[column] = enumTransform
+		}
+	}
+	return transforms
+}
+
+// withGeometryColumns returns the column transforms with `config.ColumnTransformGeometryWKB` applied to
+// the GEOMETRY columns before the others.
+func withGeometryColumns(table *common.TableDiff, columnTransforms map[string]string) map[string]string {
+	if len(table.GeometryColumns) == 0 {
+		return columnTransforms
+	}
+	transforms := make(map[string]string, len(columnTransforms)+len(table.GeometryColumns))
+	for column, transform := range columnTransforms {
+		transforms[column] = transform
+	}
+	for _, column := range table.GeometryColumns {
+		if transform, ok := transforms[column]; ok {
+			transforms[column] = config.ColumnTransformGeometryWKB + "," + transform
+		} else {
+			transforms[column] = config.ColumnTransformGeometryWKB
+		}
+	}
+	return transforms
+}
+
+// withTranscodedColumns returns the column transforms with `config.ColumnTransformUTF8MB4` applied to
+// the transcoded columns before the others.
+func withTranscodedColumns(table *common.TableDiff, columnTransforms map[string]string) map[string]string {
+	if len(table.TranscodedColumns) == 0 {
+		return columnTransforms
+	}
+	transforms := make(map[string]string, len(columnTransforms)+len(table.TranscodedColumns))
+	for column, transform := range columnTransforms {
+		transforms[column] = transform
+	}
+	for column := range table.TranscodedColumns {
+		if transform, ok := transforms[column]; ok {
+			transforms[column] = config.ColumnTransformUTF8MB4 + "," + transform
+		} else {
+			transforms[column] = config.ColumnTransformUTF8MB4
+		}
+	}
+	return transforms
+}
+
+// withStringColumns returns the column transforms with `config.ColumnTransformChar` applied to the UUID and INET
+// columns of MariaDB before the others.
+func withStringColumns(table *common.TableDiff, columnTransforms map[string]string) map[string]string {
+	if len(table.StringColumns) == 0 {
+		return columnTransforms
+	}
+	transforms := make(map[string]string, len(columnTransforms)+len(table.StringColumns))
+	for column, transform := range columnTransforms {
+		transforms[column] = transform
+	}
+	for _, column := range table.StringColumns {
+		if transform, ok := transforms[column]; ok {
+			transforms[column] = config.ColumnTransformChar + "," + transform
+		} else {
+			transforms[column] = config.ColumnTransformChar
+		}
+	}
+	return transforms
+}
+
+// enableColumnTransforms makes the source apply the column transforms of the tables.
+func enableColumnTransforms(s Source) {
+	switch s := s.(type) {
+	case *TiDBSource:
+		s.applyColumnTransforms = true
+	case *MySQLSources:
+		s.applyColumnTransforms = true
 	}
-	return downstream, upstream, nil
 }
 
 func buildSourceFromCfg(ctx context.Context, tableDiffs []*common.TableDiff, checkThreadCount int, dbs ...*config.DataSource) (Source, error) {
 	if len(dbs) < 1 {
 		return nil, errors.Errorf("no db config detected")
 	}
-	ok, err := dbutil.IsTiDB(ctx, dbs[0].Conn)
+	flavor, err := utils.GetServerFlavor(ctx, dbs[0].Conn)
 	if err != nil {
 		return nil, errors.Annotatef(err, "connect to db failed")
 	}
 
-	if ok {
+	if flavor == utils.FlavorTiDB {
 		if len(dbs) == 1 {
 			return NewTiDBSource(ctx, tableDiffs, dbs[0], checkThreadCount)
 		} else {
@@ -186,21 +480,27 @@ func buildSourceFromCfg(ctx context.Context, tableDiffs []*common.TableDiff, che
 	return NewMySQLSources(ctx, tableDiffs, dbs, checkThreadCount)
 }
 
+// initDBConn connects to the sources and the target, the connections already set, e.g. by the callers embedding
+// the diff, are used as is.
 func initDBConn(ctx context.Context, cfg *config.Config) error {
 	// Unified time zone
 	vars := map[string]string{
 		"time_zone": UnifiedTimeZone,
 	}
-	// we had 3 producers and `cfg.CheckThreadCount` consumer to use db connections.
-	// so the connection count need to be cfg.CheckThreadCount + 3.
-	targetConn, err := common.CreateDB(ctx, cfg.Task.TargetInstance.ToDBConfig(), vars, cfg.CheckThreadCount+3)
-	if err != nil {
-		return errors.Trace(err)
+	if cfg.Task.TargetInstance.Conn == nil {
+		// we had 3 producers and `cfg.CheckThreadCount` consumer to use db connections.
+		// so the connection count need to be cfg.CheckThreadCount + 3.
+		targetConn, err := common.CreateDB(ctx, cfg.Task.TargetInstance.ToDBConfig(), vars, cfg.CheckThreadCount+3)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		cfg.Task.TargetInstance.Conn = targetConn
 	}
 
-	cfg.Task.TargetInstance.Conn = targetConn
-
 	for _, source := range cfg.Task.SourceInstances {
+		if source.Conn != nil {
+			continue
+		}
 		// connect source db with target db time_zone
 		conn, err := common.CreateDB(ctx, source.ToDBConfig(), vars, cfg.CheckThreadCount+1)
 		if err != nil {
@@ -211,7 +511,9 @@ func initDBConn(ctx context.Context, cfg *config.Config) error {
 	return nil
 }
 
-func initTables(ctx context.Context, cfg *config.Config) (cfgTables []*config.TableConfig, err error) {
+// initTables returns the tables on the target matched by `check-tables` with their configs,
+// the tables in `skippedTables` keyed by `utils.UniqueID` are excluded.
+func initTables(ctx context.Context, cfg *config.Config, skippedTables map[string]struct{}) (cfgTables []*config.TableConfig, err error) {
 	downStreamConn := cfg.Task.TargetInstance.Conn
 	TargetTablesList := make([]*common.TableSource, 0)
 	targetSchemas, err := dbutil.GetSchemas(ctx, downStreamConn)
@@ -228,12 +530,30 @@ func initTables(ctx context.Context, cfg *config.Config) (cfgTables []*config.Ta
 			return nil, errors.Annotatef(err, "get tables from target source %s", schema)
 		}
 		for _, t := range allTables {
+			if _, ok := skippedTables[utils.UniqueID(schema, t)]; ok {
+				continue
+			}
 			TargetTablesList = append(TargetTablesList, &common.TableSource{
 				OriginSchema: schema,
 				OriginTable:  t,
 			})
 		}
 	}
+	// `unique id` of the updatable views whose data is compared like the tables.
+	targetViews := make(map[string]struct{})
+	if cfg.CheckViewData {
+		views, err := getUpdatableViews(ctx, downStreamConn)
+		if err != nil {
+			return nil, errors.Annotatef(err, "get updatable views from target source")
+		}
+		for _, view := range views {
+			targetViews[utils.UniqueID(view[0], view[1])] = struct{}{}
+			TargetTablesList = append(TargetTablesList, &common.TableSource{
+				OriginSchema: view[0],
+				OriginTable:  view[1],
+			})
+		}
+	}
 
 	// fill the table information.
 	// will add default source information, don't worry, we will use table config's info replace this later.
@@ -242,16 +562,18 @@ func initTables(ctx context.Context, cfg *config.Config) (cfgTables []*config.Ta
 	for _, tables := range TargetTablesList {
 		if cfg.Task.TargetCheckTables.MatchTable(tables.OriginSchema, tables.OriginTable) {
 			log.Debug("match target table", zap.String("table", dbutil.TableName(tables.OriginSchema, tables.OriginTable)))
-			tableInfo, err := dbutil.GetTableInfo(ctx, downStreamConn, tables.OriginSchema, tables.OriginTable)
+			tableInfo, err := getTableInfo(ctx, downStreamConn, tables.OriginSchema, tables.OriginTable)
 			if err != nil {
 				return nil, errors.Errorf("get table %s.%s's information error %s", tables.OriginSchema, tables.OriginTable, errors.ErrorStack(err))
 			}
+			_, isView := targetViews[utils.UniqueID(tables.OriginSchema, tables.OriginTable)]
 			// Initialize all the tables that matches the `target-check-tables`[config.toml] and appears in downstream.
 			cfgTables = append(cfgTables, &config.TableConfig{
 				Schema:          tables.OriginSchema,
 				Table:           tables.OriginTable,
 				TargetTableInfo: tableInfo,
 				Range:           "TRUE",
+				IsView:          isView,
 			})
 		}
 	}
@@ -279,6 +601,10 @@ func initTables(ctx context.Context, cfg *config.Config) (cfgTables []*config.Ta
 				cfgTable.Fields = table.Fields
 				cfgTable.Collation = table.Collation
 				cfgTable.ChunkSize = table.ChunkSize
+				cfgTable.FixSQLMode = table.FixSQLMode
+				cfgTable.Concurrency = table.Concurrency
+				cfgTable.ColumnTransforms = table.ColumnTransforms
+				cfgTable.CheckIndex = table.CheckIndex
 				cfgTable.HasMatched = true
 			}
 		}
@@ -286,6 +612,97 @@ func initTables(ctx context.Context, cfg *config.Config) (cfgTables []*config.Ta
 	return cfgTables, nil
 }
 
+// getMissingTables returns the tables only exist on one side, sorted by the names on the target. A table exists
+// on the sources if any table of the sources is routed to it, e.g. one of the shards merged into it. The tables in
+// `skippedTables` keyed by `utils.UniqueID` are neither compared nor missing. The views on the sources only count
+// for the views compared by check-view-data, the other views are listed in the "Views" section.
+func getMissingTables(ctx context.Context, cfg *config.Config, tableDiffs []*common.TableDiff, skippedTables map[string]struct{}) ([]*common.MissingTable, error) {
+	// `unique id` => the table on the target with the first source table routed to it
+	sourceTables := make(map[string]*common.MissingTable)
+	comparedViews := make(map[string]struct{})
+	for _, tableDiff := range tableDiffs {
+		if tableDiff.IsView {
+			comparedViews[utils.UniqueID(tableDiff.Schema, tableDiff.Table)] = struct{}{}
+		}
+	}
+	for i, sourceDB := range cfg.Task.SourceInstances {
+		sourceSchemas, err := dbutil.GetSchemas(ctx, sourceDB.Conn)
+		if err != nil {
+			return nil, errors.Annotatef(err, "get schemas from %d source", i)
+		}
+		for _, schema := range sourceSchemas {
+			if filter.IsSystemSchema(schema) {
+				continue
+			}
+			allTables, err := dbutil.GetTables(ctx, sourceDB.Conn, schema)
+			if err != nil {
+				return nil, errors.Annotatef(err, "get tables from %d source %s", i, schema)
+			}
+			var views []string
+			if len(comparedViews) > 0 {
+				if views, err = dbutil.GetViews(ctx, sourceDB.Conn, schema); err != nil {
+					return nil, errors.Annotatef(err, "get views from %d source %s", i, schema)
+				}
+			}
+			for j, table := range append(allTables, views...) {
+				targetSchema, targetTable := schema, table
+				if sourceDB.Router != nil {
+					targetSchema, targetTable, err = sourceDB.Router.Route(schema, table)
+					if err != nil {
+						return nil, errors.Errorf("get route result for %d source %s.%s failed, error %v", i, schema, table, err)
+					}
+				}
+				if _, ok := skippedTables[utils.UniqueID(targetSchema, targetTable)]; ok {
+					continue
+				}
+				if _, ok := comparedViews[utils.UniqueID(targetSchema, targetTable)]; j >= len(allTables) && !ok {
+					continue
+				}
+				uniqueID := utils.UniqueID(targetSchema, targetTable)
+				if _, ok := sourceTables[uniqueID]; !ok && cfg.Task.TargetCheckTables.MatchTable(targetSchema, targetTable) {
+					sourceTables[uniqueID] = &common.MissingTable{
+						Schema:          targetSchema,
+						Table:           targetTable,
+						MissingOnTarget: true,
+						SourceIndex:     i,
+						SourceSchema:    schema,
+						SourceTable:     table,
+					}
+				}
+			}
+		}
+	}
+
+	missingTables := make([]*common.MissingTable, 0)
+	targetTables := make(map[string]struct{}, len(tableDiffs))
+	for _, tableDiff := range tableDiffs {
+		uniqueID := utils.UniqueID(tableDiff.Schema, tableDiff.Table)
+		targetTables[uniqueID] = struct{}{}
+		if _, ok := sourceTables[uniqueID]; !ok {
+			missingTables = append(missingTables, &common.MissingTable{Schema: tableDiff.Schema, Table: tableDiff.Table})
+		}
+	}
+	for uniqueID, table := range sourceTables {
+		if _, ok := targetTables[uniqueID]; !ok {
+			missingTables = append(missingTables, table)
+		}
+	}
+	sort.Slice(missingTables, func(i, j int) bool {
+		return utils.UniqueID(missingTables[i].Schema, missingTables[i].Table) < utils.UniqueID(missingTables[j].Schema, missingTables[j].Table)
+	})
+	return missingTables, nil
+}
+
+// getOriginTableInfo returns a shallow copy of the table info whose name is replaced by the origin table name.
+func getOriginTableInfo(tableInfo *model.TableInfo, originTable string) *model.TableInfo {
+	if tableInfo.Name.O == originTable {
+		return tableInfo
+	}
+	originInfo := *tableInfo
+	originInfo.Name = model.NewCIStr(originTable)
+	return &originInfo
+}
+
 // RangeIterator generate next chunk for the whole tables lazily.
 type RangeIterator interface {
 	// Next seeks the next chunk, return nil if seeks to end.
@@ -293,3 +710,34 @@ type RangeIterator interface {
 
 	Close()
 }
+
+// generateFixDML generates the fix sql with given type in the fix sql mode of the table.
+// The identifiers are quoted by backticks for both the source and the target, which are all MySQL compatible,
+// so the fix sql is valid on them even if ANSI_QUOTES is set.
+func generateFixDML(t DMLType, mode string, upstreamData, downstreamData map[string]*dbutil.ColumnData, tableInfo *model.TableInfo, schema string) string {
+	switch t {
+	case Insert:
+		switch mode {
+		case config.FixSQLModeInsertOnDuplicate:
+			return utils.GenerateInsertOnDuplicateDML(upstreamData, tableInfo, schema)
+		case config.FixSQLModeDeleteInsert:
+			return utils.GenerateInsertDML(upstreamData, tableInfo, schema)
+		default:
+			return utils.GenerateReplaceDML(upstreamData, tableInfo, schema)
+		}
+	case Delete:
+		return utils.GenerateDeleteDML(downstreamData, tableInfo, schema)
+	case Replace:
+		switch mode {
+		case config.FixSQLModeInsertOnDuplicate:
+			return utils.GenerateInsertOnDuplicateDMLWithAnnotation(upstreamData, downstreamData, tableInfo, schema)
+		case config.FixSQLModeDeleteInsert:
+			return utils.GenerateDeleteInsertDMLWithAnnotation(upstreamData, downstreamData, tableInfo, schema)
+		default:
+			return utils.GenerateReplaceDMLWithAnnotation(upstreamData, downstreamData, tableInfo, schema)
+		}
+	default:
+		log.Fatal("Don't support this type", zap.Any("dml type", t))
+	}
+	return ""
+}
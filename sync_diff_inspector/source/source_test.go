@@ -19,11 +19,14 @@ import (
 	"database/sql/driver"
 	"fmt"
 	"os"
+	"regexp"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pingcap/log"
 	"github.com/pingcap/tidb-tools/pkg/dbutil"
 	filter "github.com/pingcap/tidb-tools/pkg/table-filter"
 	router "github.com/pingcap/tidb-tools/pkg/table-router"
@@ -31,8 +34,12 @@ import (
 	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
 	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
 	"github.com/pingcap/tidb-tools/sync_diff_inspector/splitter"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/utils"
 	"github.com/pingcap/tidb/parser"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 
 	_ "github.com/go-sql-driver/mysql"
 )
@@ -172,6 +179,14 @@ func TestTiDBSource(t *testing.T) {
 		require.Equal(t, checksum.Checksum, int64(456))
 	}
 
+	// Test count only
+	tableDiffs[0].Range = "a > 1"
+	mock.ExpectQuery("SELECT COUNT\\(1\\) cnt FROM `source_test`.`test1` WHERE a > 1").WillReturnRows(sqlmock.NewRows([]string{"cnt"}).AddRow(123))
+	count, err := tidb.GetCount(ctx, 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(123), count)
+	tableDiffs[0].Range = ""
+
 	// Test ChunkIterator
 	iter, err := tidb.GetRangeIterator(ctx, tableCases[0].rangeInfo, &MockAnalyzer{})
 	require.NoError(t, err)
@@ -225,9 +240,9 @@ func TestTiDBSource(t *testing.T) {
 		}
 		row++
 	}
-	require.Equal(t, tidb.GenerateFixSQL(Insert, firstRow, secondRow, 0), "REPLACE INTO `source_test`.`test1`(`a`,`b`,`c`) VALUES (1,'a',1.2);")
-	require.Equal(t, tidb.GenerateFixSQL(Delete, firstRow, secondRow, 0), "DELETE FROM `source_test`.`test1` WHERE `a` = 2 AND `b` = 'b' AND `c` = 3.4 LIMIT 1;")
-	require.Equal(t, tidb.GenerateFixSQL(Replace, firstRow, secondRow, 0),
+	require.Equal(t, generateFixSQL(t, tidb, Insert, firstRow, secondRow), "REPLACE INTO `source_test`.`test1`(`a`,`b`,`c`) VALUES (1,'a',1.2);")
+	require.Equal(t, generateFixSQL(t, tidb, Delete, firstRow, secondRow), "DELETE FROM `source_test`.`test1` WHERE `a` = 2 AND `b` = 'b' AND `c` = 3.4 LIMIT 1;")
+	require.Equal(t, generateFixSQL(t, tidb, Replace, firstRow, secondRow),
 		"/*\n"+
 			"  DIFF COLUMNS ╏ `A` ╏ `B` ╏ `C`  \n"+
 			"╍╍╍╍╍╍╍╍╍╍╍╍╍╍╍╋╍╍╍╍╍╋╍╍╍╍╍╋╍╍╍╍╍╍\n"+
@@ -238,6 +253,18 @@ func TestTiDBSource(t *testing.T) {
 			"*/\n"+
 			"REPLACE INTO `source_test`.`test1`(`a`,`b`,`c`) VALUES (1,'a',1.2);")
 
+	tidb.GetTables()[0].FixSQLMode = config.FixSQLModeInsertOnDuplicate
+	require.Equal(t, generateFixSQL(t, tidb, Insert, firstRow, secondRow),
+		"INSERT INTO `source_test`.`test1`(`a`,`b`,`c`) VALUES (1,'a',1.2) ON DUPLICATE KEY UPDATE `a` = VALUES(`a`),`b` = VALUES(`b`),`c` = VALUES(`c`);")
+	require.True(t, strings.HasSuffix(generateFixSQL(t, tidb, Replace, firstRow, secondRow),
+		"*/\nINSERT INTO `source_test`.`test1`(`a`,`b`,`c`) VALUES (1,'a',1.2) ON DUPLICATE KEY UPDATE `a` = VALUES(`a`),`b` = VALUES(`b`),`c` = VALUES(`c`);"))
+	tidb.GetTables()[0].FixSQLMode = config.FixSQLModeDeleteInsert
+	require.Equal(t, generateFixSQL(t, tidb, Insert, firstRow, secondRow), "INSERT INTO `source_test`.`test1`(`a`,`b`,`c`) VALUES (1,'a',1.2);")
+	require.True(t, strings.HasSuffix(generateFixSQL(t, tidb, Replace, firstRow, secondRow),
+		"*/\nDELETE FROM `source_test`.`test1` WHERE `a` = 2 AND `b` = 'b' AND `c` = 3.4 LIMIT 1;\nINSERT INTO `source_test`.`test1`(`a`,`b`,`c`) VALUES (1,'a',1.2);"))
+	require.Equal(t, generateFixSQL(t, tidb, Delete, firstRow, secondRow), "DELETE FROM `source_test`.`test1` WHERE `a` = 2 AND `b` = 'b' AND `c` = 3.4 LIMIT 1;")
+	tidb.GetTables()[0].FixSQLMode = config.FixSQLModeReplace
+
 	rowIter.Close()
 
 	analyze := tidb.GetTableAnalyzer()
@@ -308,16 +335,18 @@ func TestMysqlShardSources(t *testing.T) {
 	shard, err := NewMySQLSources(ctx, tableDiffs, cs, 4)
 	require.NoError(t, err)
 
-	for i := 0; i < len(dbs); i++ {
-		infoRows := sqlmock.NewRows([]string{"Table", "Create Table"}).AddRow("test_t", "CREATE TABLE `source_test`.`test1` (`a` int, `b` varchar(24), `c` float, primary key(`a`, `b`))")
-		variableRows := sqlmock.NewRows([]string{"Variable_name", "Value"}).AddRow("sql_mode", "ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_ENGINE_SUBSTITUTION")
-
-		mock.ExpectQuery("SHOW CREATE TABLE.*").WillReturnRows(infoRows)
-		mock.ExpectQuery("SHOW VARIABLE.*").WillReturnRows(variableRows)
-	}
+	// the shards are the same table on the same database, so the structure is fetched once.
+	infoRows := sqlmock.NewRows([]string{"Table", "Create Table"}).AddRow("test_t", "CREATE TABLE `source_test`.`test1` (`a` int, `b` varchar(24), `c` float, primary key(`a`, `b`))")
+	variableRows := sqlmock.NewRows([]string{"Variable_name", "Value"}).AddRow("sql_mode", "ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_ENGINE_SUBSTITUTION")
+	mock.ExpectQuery("SHOW CREATE TABLE.*").WillReturnRows(infoRows)
+	mock.ExpectQuery("SHOW VARIABLE.*").WillReturnRows(variableRows)
 	info, err := shard.GetSourceStructInfo(ctx, 0)
 	require.NoError(t, err)
+	require.Len(t, info, len(dbs))
 	require.Equal(t, info[0].Name.O, "test1")
+	// the table infos are parsed for each shard, so they can be modified separately.
+	require.NotSame(t, info[0], info[1])
+	require.NoError(t, mock.ExpectationsWereMet())
 
 	for n, tableCase := range tableCases {
 		require.Equal(t, n, tableCase.rangeInfo.GetTableIndex())
@@ -334,6 +363,14 @@ func TestMysqlShardSources(t *testing.T) {
 		require.Equal(t, checksum.Checksum, resChecksum)
 	}
 
+	// Test count only, the counts of the shards are summed up.
+	for i := 0; i < len(dbs); i++ {
+		mock.ExpectQuery("SELECT COUNT\\(1\\) cnt FROM `source_test`.`test2`").WillReturnRows(sqlmock.NewRows([]string{"cnt"}).AddRow(i + 1))
+	}
+	count, err := shard.GetCount(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, int64(10), count)
+
 	// Test RowIterator
 	tableCase := tableCases[0]
 	rowNums := len(tableCase.rows) / len(dbs)
@@ -459,9 +496,10 @@ func TestMysqlRouter(t *testing.T) {
 	secondRow, err := rowIter.Next()
 	require.NoError(t, err)
 	require.NotNil(t, secondRow)
-	require.Equal(t, mysql.GenerateFixSQL(Insert, firstRow, secondRow, 0), "REPLACE INTO `source_test`.`test1`(`a`,`b`,`c`) VALUES (1,'a',1.2);")
-	require.Equal(t, mysql.GenerateFixSQL(Delete, firstRow, secondRow, 0), "DELETE FROM `source_test`.`test1` WHERE `a` = 2 AND `b` = 'b' AND `c` = 3.4 LIMIT 1;")
-	require.Equal(t, mysql.GenerateFixSQL(Replace, firstRow, secondRow, 0),
+	// the routed table uses the origin schema and table name.
+	require.Equal(t, generateFixSQL(t, mysql, Insert, firstRow, secondRow), "REPLACE INTO `source_test_t`.`test_t`(`a`,`b`,`c`) VALUES (1,'a',1.2);")
+	require.Equal(t, generateFixSQL(t, mysql, Delete, firstRow, secondRow), "DELETE FROM `source_test_t`.`test_t` WHERE `a` = 2 AND `b` = 'b' AND `c` = 3.4 LIMIT 1;")
+	require.Equal(t, generateFixSQL(t, mysql, Replace, firstRow, secondRow),
 		"/*\n"+
 			"  DIFF COLUMNS ╏ `A` ╏ `B` ╏ `C`  \n"+
 			"╍╍╍╍╍╍╍╍╍╍╍╍╍╍╍╋╍╍╍╍╍╋╍╍╍╍╍╋╍╍╍╍╍╍\n"+
@@ -470,7 +508,7 @@ func TestMysqlRouter(t *testing.T) {
 			"  target data  ╏ 2   ╏ 'b' ╏ 3.4  \n"+
 			"╍╍╍╍╍╍╍╍╍╍╍╍╍╍╍╋╍╍╍╍╍╋╍╍╍╍╍╋╍╍╍╍╍╍\n"+
 			"*/\n"+
-			"REPLACE INTO `source_test`.`test1`(`a`,`b`,`c`) VALUES (1,'a',1.2);")
+			"REPLACE INTO `source_test_t`.`test_t`(`a`,`b`,`c`) VALUES (1,'a',1.2);")
 	rowIter.Close()
 
 	mysql.Close()
@@ -668,7 +706,7 @@ func TestSource(t *testing.T) {
 	conn.Exec("CREATE TABLE IF NOT EXISTS `schema1`.`tbl` (`a` int, `b` varchar(24), `c` float, `d` datetime, primary key(`a`, `b`))")
 	// create db connections refused.
 	// TODO unit_test covers source.go
-	_, _, err = NewSources(ctx, cfg)
+	_, _, _, _, err = NewSources(ctx, cfg)
 	require.NoError(t, err)
 }
 
@@ -694,7 +732,7 @@ func TestInitTables(t *testing.T) {
 	rows = sqlmock.NewRows([]string{"col1", "col2"}).AddRow("", "")
 	mock.ExpectQuery("SHOW VARIABLES LIKE*").WillReturnRows(rows)
 
-	tablesToBeCheck, err := initTables(ctx, cfg)
+	tablesToBeCheck, err := initTables(ctx, cfg, nil)
 	require.NoError(t, err)
 
 	require.Len(t, tablesToBeCheck, 1)
@@ -705,6 +743,16 @@ func TestInitTables(t *testing.T) {
 
 	require.NoError(t, mock.ExpectationsWereMet())
 
+	// the skipped tables are excluded before reading their structures.
+	rows = sqlmock.NewRows([]string{"Database"}).AddRow("mysql").AddRow("test2")
+	mock.ExpectQuery("SHOW DATABASES").WillReturnRows(rows)
+	rows = sqlmock.NewRows([]string{"col1", "col2"}).AddRow("t1", "BASE TABLE").AddRow("t2", "SEQUENCE")
+	mock.ExpectQuery("SHOW FULL TABLES*").WillReturnRows(rows)
+	tablesToBeCheck, err = initTables(ctx, cfg, map[string]struct{}{utils.UniqueID("test2", "t2"): {}})
+	require.NoError(t, err)
+	require.Empty(t, tablesToBeCheck)
+	require.NoError(t, mock.ExpectationsWereMet())
+
 	// Test case 2: init failed due to conflict table config point to one table.
 	cfg = config.NewConfig()
 	require.NoError(t, cfg.Parse([]string{"--config", "../config/config_conflict.toml"}))
@@ -720,7 +768,548 @@ func TestInitTables(t *testing.T) {
 	rows = sqlmock.NewRows([]string{"col1", "col2"}).AddRow("", "")
 	mock.ExpectQuery("SHOW VARIABLES LIKE*").WillReturnRows(rows)
 
-	tablesToBeCheck, err = initTables(ctx, cfg)
+	tablesToBeCheck, err = initTables(ctx, cfg, nil)
 	require.Contains(t, err.Error(), "different config matched to same target table")
 	require.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestLogChunkSQL(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	defer log.ReplaceGlobals(zap.New(core), &log.ZapProperties{Core: core, Level: zap.NewAtomicLevelAt(zapcore.InfoLevel)})()
+
+	table := &common.TableDiff{Schema: "test", Table: "t"}
+	rangeInfo := &splitter.RangeInfo{ChunkRange: &chunk.Range{
+		Index:  &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 1},
+		Bounds: []*chunk.Bound{{Column: "a", Upper: "10", HasUpper: true}},
+	}}
+	// the sql is logged in debug level without `log-sql`.
+	logChunkSQL("select data", table, rangeInfo, "SELECT * FROM `test`.`t` WHERE `a` <= ?", []interface{}{"10"})
+	require.Equal(t, 0, logs.Len())
+
+	table.LogSQL = true
+	logChunkSQL("select data", table, rangeInfo, "SELECT * FROM `test`.`t` WHERE `a` <= ?", []interface{}{"10"})
+	entries := logs.FilterMessage("select data").All()
+	require.Len(t, entries, 1)
+	require.Equal(t, zapcore.InfoLevel, entries[0].Level)
+	fields := entries[0].ContextMap()
+	require.Equal(t, "0:0-0:0:1", fields["chunk_id"])
+	require.Equal(t, "(a) <= (10)", fields["range"])
+	require.Equal(t, "SELECT * FROM `test`.`t` WHERE `a` <= ?", fields["sql"])
+	require.Equal(t, []interface{}{"10"}, fields["args"])
+}
+
+func TestGetViewDiffs(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	targetConn, targetMock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer targetConn.Close()
+	sourceConn, sourceMock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sourceConn.Close()
+
+	// the views of the shards are routed to `test`.
+	router, err := router.NewTableRouter(false, []*router.TableRule{{SchemaPattern: "shard_*", TargetSchema: "test"}})
+	require.NoError(t, err)
+	cfg := &config.Config{}
+	cfg.Task.TargetInstance = &config.DataSource{Conn: targetConn}
+	cfg.Task.SourceInstances = []*config.DataSource{{Conn: sourceConn, Router: router}}
+	cfg.Task.TargetCheckTables, err = filter.Parse([]string{"test.*"})
+	require.NoError(t, err)
+
+	createView := func(schema, view, column string) string {
+		return fmt.Sprintf("CREATE ALGORITHM=UNDEFINED DEFINER=`root`@`%%` SQL SECURITY DEFINER VIEW `%s` AS select `%s`.`t`.`%s` AS `%s` from `%s`.`t`", view, schema, column, column, schema)
+	}
+	expectViews := func(mock sqlmock.Sqlmock, views ...[2]string) {
+		rows := sqlmock.NewRows([]string{"TABLE_SCHEMA", "TABLE_NAME"})
+		for _, view := range views {
+			rows.AddRow(view[0], view[1])
+		}
+		mock.ExpectQuery("SELECT TABLE_SCHEMA, TABLE_NAME FROM information_schema.TABLES WHERE TABLE_TYPE = 'VIEW'").WillReturnRows(rows)
+	}
+	expectCreateView := func(mock sqlmock.Sqlmock, schema, view, column string) {
+		rows := sqlmock.NewRows([]string{"View", "Create View", "character_set_client", "collation_connection"}).
+			AddRow(view, createView(schema, view, column), "utf8mb4", "utf8mb4_general_ci")
+		mock.ExpectQuery(fmt.Sprintf("SHOW CREATE VIEW `%s`.`%s`", schema, view)).WillReturnRows(rows)
+	}
+
+	for _, checkViews := range []bool{true, false} {
+		cfg.CheckViews = checkViews
+		expectViews(targetMock, [2]string{"other", "v"}, [2]string{"test", "v1"}, [2]string{"test", "v2"})
+		expectViews(sourceMock, [2]string{"mysql", "v"}, [2]string{"shard_1", "v1"}, [2]string{"shard_1", "v3"}, [2]string{"shard_2", "v1"})
+		if checkViews {
+			expectCreateView(targetMock, "test", "v1", "a")
+			expectCreateView(targetMock, "test", "v2", "a")
+			expectCreateView(sourceMock, "shard_1", "v1", "a")
+			expectCreateView(sourceMock, "shard_2", "v1", "b")
+		}
+		views, err := GetViewDiffs(ctx, cfg)
+		require.NoError(t, err)
+		require.NoError(t, targetMock.ExpectationsWereMet())
+		require.NoError(t, sourceMock.ExpectationsWereMet())
+
+		require.Len(t, views, 3)
+		require.Equal(t, "v1", views[0].View)
+		require.False(t, views[0].MissingOnTarget)
+		require.Len(t, views[0].SourceDefinitions, 2)
+		if checkViews {
+			require.Equal(t, "SELECT `t`.`a` AS `a` FROM `t`", views[0].TargetDefinition)
+			require.Equal(t, []string{"SELECT `t`.`a` AS `a` FROM `t`", "SELECT `t`.`b` AS `b` FROM `t`"}, views[0].SourceDefinitions)
+		} else {
+			require.Empty(t, views[0].TargetDefinition)
+		}
+		// missing on the source
+		require.Equal(t, "v2", views[1].View)
+		require.False(t, views[1].MissingOnTarget)
+		require.Empty(t, views[1].SourceDefinitions)
+		// missing on the target
+		require.Equal(t, "test", views[2].Schema)
+		require.Equal(t, "v3", views[2].View)
+		require.True(t, views[2].MissingOnTarget)
+		require.Equal(t, []string{""}, views[2].SourceDefinitions)
+	}
+}
+
+func TestGetMissingTables(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// the shards `shard_*`.`t_*` on the two sources are merged into `test`.`t`,
+	// and the other tables of the shards are routed to `test`.
+	routeRules := []*router.TableRule{
+		{SchemaPattern: "shard_*", TablePattern: "t_*", TargetSchema: "test", TargetTable: "t"},
+		{SchemaPattern: "shard_*", TargetSchema: "test"},
+	}
+	cfg := &config.Config{}
+	conns := make([]sqlmock.Sqlmock, 0, 2)
+	for i := 0; i < 2; i++ {
+		conn, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer conn.Close()
+		router, err := router.NewTableRouter(false, routeRules)
+		require.NoError(t, err)
+		cfg.Task.SourceInstances = append(cfg.Task.SourceInstances, &config.DataSource{Conn: conn, Router: router})
+		conns = append(conns, mock)
+	}
+	var err error
+	cfg.Task.TargetCheckTables, err = filter.Parse([]string{"test.*", "!test.ignored"})
+	require.NoError(t, err)
+
+	expectTables := func(mock sqlmock.Sqlmock, schema string, tables ...string) {
+		rows := sqlmock.NewRows([]string{"Tables_in_" + schema, "Table_type"})
+		for _, table := range tables {
+			rows.AddRow(table, "BASE TABLE")
+		}
+		mock.ExpectQuery(fmt.Sprintf("SHOW FULL TABLES IN `%s`", schema)).WillReturnRows(rows)
+	}
+	conns[0].ExpectQuery("SHOW DATABASES").WillReturnRows(sqlmock.NewRows([]string{"Database"}).AddRow("mysql").AddRow("shard_1"))
+	expectTables(conns[0], "shard_1", "t_1", "only_source", "ignored")
+	conns[1].ExpectQuery("SHOW DATABASES").WillReturnRows(sqlmock.NewRows([]string{"Database"}).AddRow("shard_2"))
+	expectTables(conns[1], "shard_2", "t_2", "t_3")
+
+	tableDiffs := []*common.TableDiff{
+		{Schema: "test", Table: "t"},
+		{Schema: "test", Table: "t2"},
+		{Schema: "test", Table: "only_target"},
+	}
+	missingTables, err := getMissingTables(ctx, cfg, tableDiffs, nil)
+	require.NoError(t, err)
+	for _, mock := range conns {
+		require.NoError(t, mock.ExpectationsWereMet())
+	}
+	// `test`.`t` exists on the sources if any shard is routed to it, and `test`.`ignored` is excluded by the filter.
+	// the table missing on the target carries the source table to create it by generate-struct-fix.
+	require.Equal(t, []*common.MissingTable{
+		{Schema: "test", Table: "only_source", MissingOnTarget: true, SourceSchema: "shard_1", SourceTable: "only_source"},
+		{Schema: "test", Table: "only_target"},
+		{Schema: "test", Table: "t2"},
+	}, missingTables)
+}
+
+func TestCheckViewData(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	targetConn, targetMock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer targetConn.Close()
+	sourceConn, sourceMock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sourceConn.Close()
+	cfg := &config.Config{CheckViewData: true}
+	cfg.Task.TargetInstance = &config.DataSource{Conn: targetConn}
+	cfg.Task.SourceInstances = []*config.DataSource{{Conn: sourceConn}}
+	cfg.Task.TargetCheckTables, err = filter.Parse([]string{"test.*"})
+	require.NoError(t, err)
+
+	// the updatable view `test`.`v` is compared like a table, whose structure is built by its columns.
+	targetMock.ExpectQuery("SHOW DATABASES").WillReturnRows(sqlmock.NewRows([]string{"Database"}).AddRow("test"))
+	targetMock.ExpectQuery("SHOW FULL TABLES IN `test` WHERE Table_Type != 'VIEW'").WillReturnRows(sqlmock.NewRows([]string{"Tables_in_test", "Table_type"}).AddRow("t", "BASE TABLE"))
+	targetMock.ExpectQuery("SELECT TABLE_SCHEMA, TABLE_NAME FROM information_schema.VIEWS WHERE IS_UPDATABLE = 'YES'").
+		WillReturnRows(sqlmock.NewRows([]string{"TABLE_SCHEMA", "TABLE_NAME"}).AddRow("mysql", "v").AddRow("test", "v"))
+	targetMock.ExpectQuery("SHOW CREATE TABLE `test`.`t`").WillReturnRows(sqlmock.NewRows([]string{"Table", "Create Table"}).AddRow("t", "CREATE TABLE `t` (`a` int(11) NOT NULL, PRIMARY KEY (`a`))"))
+	targetMock.ExpectQuery("SHOW VARIABLES LIKE*").WillReturnRows(sqlmock.NewRows([]string{"Variable_name", "Value"}).AddRow("sql_mode", ""))
+	targetMock.ExpectQuery("SHOW CREATE TABLE `test`.`v`").WillReturnRows(sqlmock.NewRows([]string{"View", "Create View", "character_set_client", "collation_connection"}).
+		AddRow("v", "CREATE ALGORITHM=UNDEFINED DEFINER=`root`@`%` SQL SECURITY DEFINER VIEW `v` AS select `test`.`t`.`a` AS `a` from `test`.`t`", "utf8mb4", "utf8mb4_general_ci"))
+	targetMock.ExpectQuery("SELECT COLUMN_NAME, COLUMN_TYPE, CHARACTER_SET_NAME, COLLATION_NAME FROM information_schema.COLUMNS").WithArgs("test", "v").
+		WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME", "COLUMN_TYPE", "CHARACTER_SET_NAME", "COLLATION_NAME"}).AddRow("a", "int(11)", nil, nil).AddRow("b", "varchar(10)", "utf8mb4", "utf8mb4_bin"))
+	targetMock.ExpectQuery("SHOW VARIABLES LIKE*").WillReturnRows(sqlmock.NewRows([]string{"Variable_name", "Value"}).AddRow("sql_mode", ""))
+	tablesToBeCheck, err := initTables(ctx, cfg, nil)
+	require.NoError(t, err)
+	require.NoError(t, targetMock.ExpectationsWereMet())
+	require.Len(t, tablesToBeCheck, 2)
+	require.Equal(t, "t", tablesToBeCheck[0].Table)
+	require.False(t, tablesToBeCheck[0].IsView)
+	require.Equal(t, "v", tablesToBeCheck[1].Table)
+	require.True(t, tablesToBeCheck[1].IsView)
+	viewInfo := tablesToBeCheck[1].TargetTableInfo
+	require.Len(t, viewInfo.Columns, 2)
+	require.Equal(t, "b", viewInfo.Columns[1].Name.O)
+	require.Equal(t, "utf8mb4_bin", viewInfo.Columns[1].Collate)
+	require.Empty(t, viewInfo.Indices)
+
+	// the views on the sources only count for the views compared.
+	sourceMock.ExpectQuery("SHOW DATABASES").WillReturnRows(sqlmock.NewRows([]string{"Database"}).AddRow("test"))
+	sourceMock.ExpectQuery("SHOW FULL TABLES IN `test` WHERE Table_Type != 'VIEW'").WillReturnRows(sqlmock.NewRows([]string{"Tables_in_test", "Table_type"}).AddRow("t", "BASE TABLE"))
+	sourceMock.ExpectQuery("SHOW FULL TABLES IN `test` WHERE Table_Type = 'VIEW'").
+		WillReturnRows(sqlmock.NewRows([]string{"Tables_in_test", "Table_type"}).AddRow("v", "VIEW").AddRow("not_updatable", "VIEW"))
+	tableDiffs := []*common.TableDiff{{Schema: "test", Table: "t"}, {Schema: "test", Table: "v", IsView: true}}
+	missingTables, err := getMissingTables(ctx, cfg, tableDiffs, nil)
+	require.NoError(t, err)
+	require.NoError(t, sourceMock.ExpectationsWereMet())
+	require.Empty(t, missingTables)
+}
+
+func TestCheckColumnTransforms(t *testing.T) {
+	createTableSQL := "create table `test`.`test`(`a` int, `b` varchar(10), `c` varchar(20), primary key(`a`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+
+	require.NoError(t, checkColumnTransforms(tableInfo, nil))
+	require.NoError(t, checkColumnTransforms(tableInfo, map[string]string{"b": "lower", "c": "trim"}))
+	require.Regexp(t, "column d doesn't exist", checkColumnTransforms(tableInfo, map[string]string{"d": "lower"}))
+	require.Regexp(t, "column a is the order key", checkColumnTransforms(tableInfo, map[string]string{"a": "lower"}))
+
+	// the columns are transformed only by the sources which enable it.
+	table := &common.TableDiff{ColumnTransforms: map[string]string{"b": "lower"}}
+	tidb := &TiDBSource{}
+	require.Nil(t, tidb.columnTransforms(table))
+	enableColumnTransforms(tidb)
+	require.Equal(t, table.ColumnTransforms, tidb.columnTransforms(table))
+	mysql := &MySQLSources{}
+	require.Nil(t, mysql.columnTransforms(table))
+	enableColumnTransforms(mysql)
+	require.Equal(t, table.ColumnTransforms, mysql.columnTransforms(table))
+
+	// the trimmed columns are trimmed by all the sources, after the column transforms.
+	table.TrimmedColumns = []string{"b", "c"}
+	require.Equal(t, map[string]string{"b": "lower,rtrim", "c": "rtrim"}, tidb.columnTransforms(table))
+	require.Equal(t, map[string]string{"b": "lower,rtrim", "c": "rtrim"}, mysql.columnTransforms(table))
+	require.Equal(t, map[string]string{"b": "rtrim", "c": "rtrim"}, (&TiDBSource{}).columnTransforms(table))
+	require.Equal(t, map[string]string{"b": "lower"}, table.ColumnTransforms)
+
+	// the ENUM and SET columns whose members are reordered are compared by the member strings.
+	createTableSQL = "create table `test`.`test`(`a` int, `b` varchar(10), `c` set('x','y'), `d` enum('x','y'), primary key(`a`))"
+	table.Info, err = dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+	table.TrimmedColumns = nil
+	table.ReorderedEnumColumns = []string{"c", "d"}
+	require.Equal(t, map[string]string{"b": "lower", "c": "set-value", "d": "enum-value"}, tidb.columnTransforms(table))
+	require.Equal(t, map[string]string{"c": "set-value", "d": "enum-value"}, (&MySQLSources{}).columnTransforms(table))
+	// or all of them are compared by index on both sides.
+	table.EnumByIndex = true
+	require.Equal(t, map[string]string{"b": "lower", "c": "enum-index", "d": "enum-index"}, mysql.columnTransforms(table))
+	require.Equal(t, map[string]string{"c": "enum-index", "d": "enum-index"}, (&TiDBSource{}).columnTransforms(table))
+}
+
+func TestTrimCharPadding(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	tableCases := []*tableCaseType{
+		{
+			schema:         "source_test",
+			table:          "test1",
+			createTableSQL: "CREATE TABLE `source_test`.`test1` (`a` int, `b` char(10), `c` varchar(24), `e` binary(10), primary key(`a`))",
+			rangeColumns:   []string{"a"},
+			rangeLeft:      []string{"1"},
+			rangeRight:     []string{"5"},
+		},
+	}
+	tableDiffs := prepareTiDBTables(t, tableCases)
+	tableDiffs[0].TrimmedColumns = utils.GetPaddedCharColumns(tableDiffs[0].Info)
+	require.Equal(t, []string{"b", "c"}, tableDiffs[0].TrimmedColumns)
+
+	tidb, err := NewTiDBSource(ctx, tableDiffs, &config.DataSource{Conn: conn}, 1)
+	require.NoError(t, err)
+
+	// the values differing only in the trailing spaces, e.g. "abc  " and "abc", are compared after `RTRIM`,
+	// while the trailing spaces of the binary column are significant.
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) as CNT, BIT_XOR\\(CAST\\(CRC32\\(CONCAT_WS\\(',', `a`, RTRIM\\(`b`\\), RTRIM\\(`c`\\), `e`, .*").
+		WillReturnRows(sqlmock.NewRows([]string{"CNT", "CHECKSUM"}).AddRow(1, 456))
+	checksum := tidb.GetCountAndCrc32(ctx, tableCases[0].rangeInfo)
+	require.NoError(t, checksum.Err)
+
+	mock.ExpectQuery("SELECT /\\*!40001 SQL_NO_CACHE \\*/ `a`, RTRIM\\(`b`\\) AS `b`, RTRIM\\(`c`\\) AS `c`, `e` FROM .*").
+		WillReturnRows(sqlmock.NewRows([]string{"a", "b", "c", "e"}).AddRow("1", "abc", "abc", "abc  "))
+	rowIter, err := tidb.GetRowsIterator(ctx, tableCases[0].rangeInfo)
+	require.NoError(t, err)
+	row, err := rowIter.Next()
+	require.NoError(t, err)
+	require.Equal(t, []byte("abc"), row["b"].Data)
+	require.Equal(t, []byte("abc  "), row["e"].Data)
+	rowIter.Close()
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetSkippedObjects(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	targetConn, targetMock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer targetConn.Close()
+	sourceConn, sourceMock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer sourceConn.Close()
+
+	// the shards `shard_*`.`t_*` are merged into `test`.`t`, and the other tables of the shards are routed to `test`.
+	router, err := router.NewTableRouter(false, []*router.TableRule{
+		{SchemaPattern: "shard_*", TablePattern: "t_*", TargetSchema: "test", TargetTable: "t"},
+		{SchemaPattern: "shard_*", TargetSchema: "test"},
+	})
+	require.NoError(t, err)
+	cfg := &config.Config{}
+	cfg.Task.TargetInstance = &config.DataSource{Conn: targetConn}
+	cfg.Task.SourceInstances = []*config.DataSource{{Conn: sourceConn, Router: router}}
+	cfg.Task.TargetCheckTables, err = filter.Parse([]string{"test.*"})
+	require.NoError(t, err)
+
+	expectObjects := func(mock sqlmock.Sqlmock, objects ...[4]interface{}) {
+		rows := sqlmock.NewRows([]string{"TABLE_SCHEMA", "TABLE_NAME", "TABLE_TYPE", "ENGINE"})
+		for _, object := range objects {
+			rows.AddRow(object[0], object[1], object[2], object[3])
+		}
+		mock.ExpectQuery("SELECT TABLE_SCHEMA, TABLE_NAME, TABLE_TYPE, ENGINE FROM information_schema.TABLES WHERE TABLE_TYPE <> 'VIEW'").WillReturnRows(rows)
+	}
+	expect := func() {
+		expectObjects(targetMock,
+			[4]interface{}{"mysql", "user", "BASE TABLE", "MyISAM"},
+			[4]interface{}{"other", "seq", "SEQUENCE", "InnoDB"},
+			[4]interface{}{"test", "fed", "BASE TABLE", "FEDERATED"},
+			[4]interface{}{"test", "my", "BASE TABLE", "MyISAM"},
+			[4]interface{}{"test", "seq", "SEQUENCE", "InnoDB"},
+			[4]interface{}{"test", "t", "BASE TABLE", "InnoDB"},
+			[4]interface{}{"test", "unknown", "BASE TABLE", nil},
+		)
+		expectObjects(sourceMock,
+			[4]interface{}{"shard_1", "black", "BASE TABLE", "BLACKHOLE"},
+			[4]interface{}{"shard_1", "t_1", "BASE TABLE", "InnoDB"},
+			[4]interface{}{"shard_1", "t_2", "BASE TABLE", "MEMORY"},
+			[4]interface{}{"shard_1", "tmp", "TEMPORARY", "InnoDB"},
+		)
+	}
+
+	expect()
+	objects, err := GetSkippedObjects(ctx, cfg)
+	require.NoError(t, err)
+	require.NoError(t, targetMock.ExpectationsWereMet())
+	require.NoError(t, sourceMock.ExpectationsWereMet())
+	require.Equal(t, []*common.SkippedObject{
+		{Schema: "test", Table: "black", OnSource: true, OriginSchema: "shard_1", OriginTable: "black", Type: "BASE TABLE", Engine: "BLACKHOLE",
+			Reason: "BLACKHOLE table stores no rows, add it to force-include-engines to compare it"},
+		{Schema: "test", Table: "fed", OriginSchema: "test", OriginTable: "fed", Type: "BASE TABLE", Engine: "FEDERATED",
+			Reason: "the rows of FEDERATED table are on the remote server, add it to force-include-engines to compare it"},
+		{Schema: "test", Table: "my", OriginSchema: "test", OriginTable: "my", Type: "BASE TABLE", Engine: "MyISAM",
+			Reason: "engine MyISAM isn't InnoDB-like, add it to force-include-engines to compare it"},
+		{Schema: "test", Table: "seq", OriginSchema: "test", OriginTable: "seq", Type: "SEQUENCE", Engine: "InnoDB",
+			Reason: "sequence can't be compared"},
+		{Schema: "test", Table: "t", OnSource: true, OriginSchema: "shard_1", OriginTable: "t_2", Type: "BASE TABLE", Engine: "MEMORY",
+			Reason: "the rows of MEMORY table are lost on restart, add it to force-include-engines to compare it"},
+		{Schema: "test", Table: "tmp", OnSource: true, OriginSchema: "shard_1", OriginTable: "tmp", Type: "TEMPORARY", Engine: "InnoDB",
+			Reason: "temporary can't be compared"},
+	}, objects)
+	// `test`.`t` isn't compared because one of its shards can't be compared.
+	require.Equal(t, map[string]struct{}{
+		utils.UniqueID("test", "black"): {},
+		utils.UniqueID("test", "fed"):   {},
+		utils.UniqueID("test", "my"):    {},
+		utils.UniqueID("test", "seq"):   {},
+		utils.UniqueID("test", "t"):     {},
+		utils.UniqueID("test", "tmp"):   {},
+	}, getSkippedTables(objects))
+
+	// the tables of the engines in `force-include-engines` are compared.
+	cfg.ForceIncludeEngines = []string{"myisam", "Memory"}
+	expect()
+	objects, err = GetSkippedObjects(ctx, cfg)
+	require.NoError(t, err)
+	names := make([]string, 0, len(objects))
+	for _, object := range objects {
+		names = append(names, object.OriginTable)
+	}
+	require.Equal(t, []string{"black", "fed", "seq", "tmp"}, names)
+}
+
+func TestSetDataSourceConns(t *testing.T) {
+	openDB := func(maxOpenConns int) *sql.DB {
+		// the connections are not established until they are used.
+		db, err := sql.Open("mysql", "root:@tcp(127.0.0.1:4000)/")
+		require.NoError(t, err)
+		db.SetMaxOpenConns(maxOpenConns)
+		return db
+	}
+	cfg := config.NewConfig()
+	cfg.Task.TargetInstance = &config.DataSource{Conn: openDB(7)}
+	cfg.Task.SourceInstances = []*config.DataSource{
+		{MaxOpenConns: 3, MaxIdleConns: 1, ConnMaxLifetime: "10m", Conn: openDB(5)},
+		{Conn: openDB(5)},
+	}
+	cfg.MaxOpenConns = 4
+	setMaxOpenConns(cfg, cfg.MaxOpenConns)
+	setDataSourceConns(cfg)
+	// the limit of the data source overrides the global max-open-conns.
+	require.Equal(t, 4, cfg.Task.TargetInstance.Conn.Stats().MaxOpenConnections)
+	require.Equal(t, 3, cfg.Task.SourceInstances[0].Conn.Stats().MaxOpenConnections)
+	require.Equal(t, 4, cfg.Task.SourceInstances[1].Conn.Stats().MaxOpenConnections)
+	for _, instance := range append(cfg.Task.SourceInstances, cfg.Task.TargetInstance) {
+		require.NoError(t, instance.Conn.Close())
+	}
+}
+
+func TestShardStructSample(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	tableDiffs := []*common.TableDiff{{Schema: "test", Table: "t"}}
+	shards := make([]*common.TableShardSource, 0, 8)
+	for i := 0; i < 8; i++ {
+		shards = append(shards, &common.TableShardSource{
+			TableSource: common.TableSource{OriginSchema: "test", OriginTable: fmt.Sprintf("t_%d", i)},
+			DBConn:      db,
+		})
+	}
+	s := &MySQLSources{
+		tableDiffs:        tableDiffs,
+		sourceTablesMap:   map[string][]*common.TableShardSource{utils.UniqueID("test", "t"): shards},
+		tableInfoCache:    newTableInfoCache(),
+		shardStructSample: 3,
+	}
+
+	// the shards are sampled evenly from the first one.
+	for i, table := range []string{"t_0", "t_2", "t_5"} {
+		mock.ExpectQuery(regexp.QuoteMeta(fmt.Sprintf("SHOW CREATE TABLE `test`.`%s`", table))).WillReturnRows(
+			sqlmock.NewRows([]string{"Table", "Create Table"}).AddRow(table, fmt.Sprintf("CREATE TABLE `%s` (\n  `a` int(11) NOT NULL\n) ENGINE=InnoDB", table)))
+		if i == 0 {
+			mock.ExpectQuery("SHOW VARIABLES LIKE*").WillReturnRows(sqlmock.NewRows([]string{"Variable_name", "Value"}).AddRow("sql_mode", ""))
+		}
+	}
+	infos, err := s.GetSourceStructInfo(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, infos, 3)
+	require.Equal(t, "t_5", infos[2].Name.O)
+	require.Equal(t, 8, tableDiffs[0].Shards)
+	require.Equal(t, 3, tableDiffs[0].StructCheckedShards)
+	require.NoError(t, mock.ExpectationsWereMet())
+	// the shards with the identical structures are parsed once.
+	hits, misses := s.tableInfoCache.parsed.Stats()
+	require.Equal(t, int64(2), hits)
+	require.Equal(t, int64(1), misses)
+
+	// all the shards are checked if there are no more than shard-struct-sample shards,
+	// and the structures fetched are not fetched again.
+	tableDiffs[0].Shards, tableDiffs[0].StructCheckedShards = 0, 0
+	s.shardStructSample = 8
+	for _, table := range []string{"t_1", "t_3", "t_4", "t_6", "t_7"} {
+		mock.ExpectQuery(regexp.QuoteMeta(fmt.Sprintf("SHOW CREATE TABLE `test`.`%s`", table))).WillReturnRows(
+			sqlmock.NewRows([]string{"Table", "Create Table"}).AddRow(table, fmt.Sprintf("CREATE TABLE `%s` (\n  `a` int(11) NOT NULL\n) ENGINE=InnoDB", table)))
+	}
+	infos, err = s.GetSourceStructInfo(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, infos, 8)
+	require.Equal(t, 0, tableDiffs[0].StructCheckedShards)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestShardMergeFixTarget(t *testing.T) {
+	tableInfo, err := utils.GetTableInfoBySQL("CREATE TABLE `test`.`t` (`a` int, PRIMARY KEY (`a`))", parser.New())
+	require.NoError(t, err)
+	tableDiffs := []*common.TableDiff{{Schema: "test", Table: "single", Info: tableInfo}, {Schema: "test", Table: "t", Info: tableInfo}}
+	// the shards of `test`.`t` are on the same instance.
+	s := &MySQLSources{
+		tableDiffs: tableDiffs,
+		sourceTablesMap: map[string][]*common.TableShardSource{
+			utils.UniqueID("test", "single"): {{TableSource: common.TableSource{OriginSchema: "test", OriginTable: "single"}}},
+			utils.UniqueID("test", "t"): {
+				{TableSource: common.TableSource{OriginSchema: "test", OriginTable: "t_0"}},
+				{TableSource: common.TableSource{OriginSchema: "test", OriginTable: "t_1"}},
+			},
+		},
+		tableInfoCache: newTableInfoCache(),
+	}
+	err = s.checkFixTarget()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "the table `test`.`t` is merged from 2 source tables")
+
+	row := map[string]*dbutil.ColumnData{"a": {Data: []byte("1")}}
+	sql, err := s.GenerateFixSQL(Insert, row, nil, 0)
+	require.NoError(t, err)
+	require.Equal(t, "REPLACE INTO `test`.`single`(`a`) VALUES (1);", sql)
+	_, err = s.GenerateFixSQL(Insert, row, nil, 1)
+	require.Error(t, err)
+
+	s.sourceTablesMap[utils.UniqueID("test", "t")] = s.sourceTablesMap[utils.UniqueID("test", "t")][:1]
+	require.NoError(t, s.checkFixTarget())
+	// the fix sql uses the names on the source before routing, which are quoted by backticks.
+	s.sourceTablesMap[utils.UniqueID("test", "t")][0].OriginSchema = "shard`1"
+	sql, err = s.GenerateFixSQL(Delete, nil, row, 1)
+	require.NoError(t, err)
+	require.Equal(t, "DELETE FROM `shard``1`.`t_0` WHERE `a` = 1 LIMIT 1;", sql)
+}
+
+func TestMariaDBStringColumns(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	tableInfo, err := utils.GetTableInfoBySQL("CREATE TABLE `test`.`t` (`id` char(36) NOT NULL, `ip` varchar(39), `b` int, PRIMARY KEY (`id`))", parser.New())
+	require.NoError(t, err)
+	tableDiffs := []*common.TableDiff{{Schema: "test", Table: "t", Info: tableInfo}}
+	s := &MySQLSources{
+		tableDiffs: tableDiffs,
+		sourceTablesMap: map[string][]*common.TableShardSource{utils.UniqueID("test", "t"): {{
+			TableSource: common.TableSource{OriginSchema: "test", OriginTable: "t"},
+			DBConn:      db,
+		}}},
+		tableInfoCache: newTableInfoCache(),
+	}
+
+	// the statement is fetched once for the table info and the UUID and INET columns.
+	mock.ExpectQuery(regexp.QuoteMeta("SHOW CREATE TABLE `test`.`t`")).WillReturnRows(sqlmock.NewRows([]string{"Table", "Create Table"}).AddRow("t",
+		"CREATE TABLE `t` (\n  `ID` uuid NOT NULL,\n  `ip` inet6 DEFAULT NULL,\n  `b` int(11) DEFAULT NULL,\n  PRIMARY KEY (`ID`)\n) ENGINE=InnoDB DEFAULT CHARSET=utf8mb3 PAGE_CHECKSUM=1"))
+	mock.ExpectQuery("SHOW VARIABLES LIKE*").WillReturnRows(sqlmock.NewRows([]string{"Variable_name", "Value"}).AddRow("sql_mode", ""))
+	infos, err := s.GetSourceStructInfo(ctx, 0)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.True(t, strings.HasPrefix(infos[0].Columns[0].FieldType.String(), "char(36)"))
+	// the columns are named as the target.
+	require.Equal(t, []string{"id", "ip"}, tableDiffs[0].StringColumns)
+
+	require.Contains(t, utils.GetCountAndCRC32ChecksumSQL("test", "t", "", tableInfo, s.columnTransforms(tableDiffs[0]), "TRUE"),
+		"CONCAT_WS(',', CAST(`id` AS CHAR), CAST(`ip` AS CHAR), `b`, ")
+}
+
+// generateFixSQL generates the fix sql of the first table of the source.
+func generateFixSQL(t *testing.T, s Source, dmlType DMLType, upstreamData, downstreamData map[string]*dbutil.ColumnData) string {
+	sql, err := s.GenerateFixSQL(dmlType, upstreamData, downstreamData, 0)
+	require.NoError(t, err)
+	return sql
+}
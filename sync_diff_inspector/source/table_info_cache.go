@@ -0,0 +1,144 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package source
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/utils"
+	"github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"go.uber.org/zap"
+)
+
+// tableInfoCache caches the sql modes of the databases and the `CREATE TABLE` statements of the tables, so the
+// structures of the tables can be fetched concurrently without the duplicate queries, e.g. the sql mode of a database
+// is queried once for all the shards of the shard-merge groups on it. The statements are parsed by `parsed`,
+// so the tables with the identical structures are parsed once.
+type tableInfoCache struct {
+	mu sync.Mutex
+	// `*sql.DB` => the sql mode, `tableInfoKey` => the `CREATE TABLE` statement
+	values map[interface{}]*cachedValue
+	parsed *common.TableInfoCache
+}
+
+type tableInfoKey struct {
+	db     *sql.DB
+	schema string
+	table  string
+}
+
+// cachedValue is fetched by the first caller, and the concurrent callers wait for it.
+type cachedValue struct {
+	once  sync.Once
+	value interface{}
+	err   error
+}
+
+func newTableInfoCache() *tableInfoCache {
+	return &tableInfoCache{values: make(map[interface{}]*cachedValue), parsed: common.NewTableInfoCache()}
+}
+
+func (c *tableInfoCache) load(key interface{}, fetch func() (interface{}, error)) (interface{}, error) {
+	c.mu.Lock()
+	v, ok := c.values[key]
+	if !ok {
+		v = &cachedValue{}
+		c.values[key] = v
+	}
+	c.mu.Unlock()
+	v.once.Do(func() {
+		v.value, v.err = fetch()
+	})
+	return v.value, v.err
+}
+
+// GetTableInfo returns the table info like `utils.GetTableInfo`, which is fetched without the cache if c is nil.
+func (c *tableInfoCache) GetTableInfo(ctx context.Context, db *sql.DB, schema, table string) (*model.TableInfo, error) {
+	if c == nil {
+		return getTableInfo(ctx, db, schema, table)
+	}
+	createTableSQL, err := c.GetCreateTableSQL(ctx, db, schema, table)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	sqlMode, err := c.load(db, func() (interface{}, error) {
+		var sqlMode mysql.SQLMode
+		err := utils.QueryRetryPolicy.Do(ctx, func() error {
+			var err error
+			sqlMode, err = dbutil.GetSQLMode(ctx, db)
+			return err
+		})
+		return sqlMode, err
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	tableInfo, err := c.parsed.GetTableInfo(createTableSQL, sqlMode.(mysql.SQLMode))
+	return tableInfo, errors.Trace(err)
+}
+
+// GetCreateTableSQL returns the `CREATE TABLE` statement of the table like `utils.GetCreateTableSQL`, which is fetched
+// without the cache if c is nil.
+func (c *tableInfoCache) GetCreateTableSQL(ctx context.Context, db *sql.DB, schema, table string) (string, error) {
+	if c == nil {
+		return getCreateTableSQL(ctx, db, schema, table)
+	}
+	createTableSQL, err := c.load(tableInfoKey{db: db, schema: schema, table: table}, func() (interface{}, error) {
+		return getCreateTableSQL(ctx, db, schema, table)
+	})
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	return createTableSQL.(string), nil
+}
+
+// logStats logs the hits and the misses of parsing the `CREATE TABLE` statements.
+func (c *tableInfoCache) logStats() {
+	if c == nil {
+		return
+	}
+	hits, misses := c.parsed.Stats()
+	if hits+misses > 0 {
+		log.Info("table info cache", zap.Int64("hits", hits), zap.Int64("misses", misses))
+	}
+}
+
+// getTableInfo is `utils.GetTableInfo` retried by `utils.QueryRetryPolicy`.
+func getTableInfo(ctx context.Context, db *sql.DB, schema, table string) (*model.TableInfo, error) {
+	var tableInfo *model.TableInfo
+	err := utils.QueryRetryPolicy.Do(ctx, func() error {
+		var err error
+		tableInfo, err = utils.GetTableInfo(ctx, db, schema, table)
+		return err
+	})
+	return tableInfo, errors.Trace(err)
+}
+
+// getCreateTableSQL is `utils.GetCreateTableSQL` retried by `utils.QueryRetryPolicy`.
+func getCreateTableSQL(ctx context.Context, db *sql.DB, schema, table string) (string, error) {
+	var createTableSQL string
+	err := utils.QueryRetryPolicy.Do(ctx, func() error {
+		var err error
+		createTableSQL, err = utils.GetCreateTableSQL(ctx, db, schema, table)
+		return err
+	})
+	return createTableSQL, errors.Trace(err)
+}
@@ -44,6 +44,16 @@ func (a *TiDBTableAnalyzer) AnalyzeSplitter(ctx context.Context, table *common.T
 	originTable.Schema = matchedSource.OriginSchema
 	originTable.Table = matchedSource.OriginTable
 	progressID := dbutil.TableName(table.Schema, table.Table)
+	if table.NoPKFallback {
+		return splitter.NewWholeTableIteratorWithCheckpoint(progressID, &originTable, startRange), nil
+	}
+	if table.SplitByPartition {
+		partitionIter, err := splitter.NewPartitionIteratorWithCheckpoint(ctx, progressID, &originTable, a.dbConn, startRange)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return partitionIter, nil
+	}
 	// if we decide to use bucket to split chunks
 	// we always use bucksIter even we load from checkpoint is not bucketNode
 	// TODO check whether we can use bucket for this table to split chunks.
@@ -68,6 +78,11 @@ type TiDBRowsIterator struct {
 	rows *sql.Rows
 }
 
+// NewTiDBRowsIterator returns the iterator of rows, which is closed with the iterator.
+func NewTiDBRowsIterator(rows *sql.Rows) *TiDBRowsIterator {
+	return &TiDBRowsIterator{rows: rows}
+}
+
 func (s *TiDBRowsIterator) Close() {
 	s.rows.Close()
 }
@@ -76,7 +91,8 @@ func (s *TiDBRowsIterator) Next() (map[string]*dbutil.ColumnData, error) {
 	if s.rows.Next() {
 		return dbutil.ScanRow(s.rows)
 	}
-	return nil, nil
+	// the rows may be closed by canceling the context.
+	return nil, errors.Trace(s.rows.Err())
 }
 
 type TiDBSource struct {
@@ -86,6 +102,22 @@ type TiDBSource struct {
 	// checkThreadCount is the pool size of produce chunks
 	checkThreadCount int
 	dbConn           *sql.DB
+	// dbConfig is the config of dbConn, which is used to connect without the snapshot, see `WithoutSnapshot`.
+	dbConfig *dbutil.DBConfig
+	// applyColumnTransforms is true if the column transforms of the tables are applied to the values.
+	applyColumnTransforms bool
+	// tableInfoCache caches the structures of the source tables for the concurrent struct check.
+	tableInfoCache *tableInfoCache
+}
+
+func (s *TiDBSource) columnTransforms(table *common.TableDiff) map[string]string {
+	// the trailing spaces, the reordered ENUM and SET columns, the GEOMETRY columns, the transcoded columns and the
+	// UUID and INET columns of MariaDB are transformed on both sides, and the column transforms are only applied to
+	// the source.
+	if !s.applyColumnTransforms {
+		return withStringColumns(table, withTranscodedColumns(table, withGeometryColumns(table, withEnumColumns(table, withTrimmedColumns(table, nil)))))
+	}
+	return withStringColumns(table, withTranscodedColumns(table, withGeometryColumns(table, withEnumColumns(table, withTrimmedColumns(table, table.ColumnTransforms)))))
 }
 
 func (s *TiDBSource) GetTableAnalyzer() TableAnalyzer {
@@ -113,6 +145,7 @@ func (s *TiDBSource) GetRangeIterator(ctx context.Context, r *splitter.RangeInfo
 }
 
 func (s *TiDBSource) Close() {
+	s.tableInfoCache.logStats()
 	s.dbConn.Close()
 }
 func (s *TiDBSource) GetCountAndCrc32(ctx context.Context, tableRange *splitter.RangeInfo) *ChecksumInfo {
@@ -121,7 +154,10 @@ func (s *TiDBSource) GetCountAndCrc32(ctx context.Context, tableRange *splitter.
 	chunk := tableRange.GetChunk()
 
 	matchSource := getMatchSource(s.sourceTableMap, table)
-	count, checksum, err := utils.GetCountAndCRC32Checksum(ctx, s.dbConn, matchSource.OriginSchema, matchSource.OriginTable, table.Info, chunk.Where, chunk.Args)
+	if table.LogSQL {
+		logChunkSQL("count and checksum", table, tableRange, utils.GetCountAndCRC32ChecksumSQL(matchSource.OriginSchema, matchSource.OriginTable, chunk.Partition, table.Info, s.columnTransforms(table), chunk.Where), chunk.Args)
+	}
+	count, checksum, err := utils.GetCountAndCRC32Checksum(ctx, s.dbConn, matchSource.OriginSchema, matchSource.OriginTable, chunk.Partition, table.Info, s.columnTransforms(table), chunk.Where, chunk.Args)
 
 	cost := time.Since(beginTime)
 	return &ChecksumInfo{
@@ -132,6 +168,46 @@ func (s *TiDBSource) GetCountAndCrc32(ctx context.Context, tableRange *splitter.
 	}
 }
 
+func (s *TiDBSource) GetIndexCountAndCrc32(ctx context.Context, tableRange *splitter.RangeInfo, index *model.IndexInfo) *ChecksumInfo {
+	beginTime := time.Now()
+	table := s.tableDiffs[tableRange.GetTableIndex()]
+	chunk := tableRange.GetChunk()
+
+	matchSource := getMatchSource(s.sourceTableMap, table)
+	if table.LogSQL {
+		logChunkSQL("index count and checksum", table, tableRange, utils.GetIndexCountAndCRC32ChecksumSQL(matchSource.OriginSchema, matchSource.OriginTable, chunk.Partition, table.Info, index, s.columnTransforms(table), chunk.Where), chunk.Args)
+	}
+	count, checksum, err := utils.GetIndexCountAndCRC32Checksum(ctx, s.dbConn, matchSource.OriginSchema, matchSource.OriginTable, chunk.Partition, table.Info, index, s.columnTransforms(table), chunk.Where, chunk.Args)
+	return &ChecksumInfo{
+		Checksum: checksum,
+		Count:    count,
+		Err:      err,
+		Cost:     time.Since(beginTime),
+	}
+}
+
+func (s *TiDBSource) GetCount(ctx context.Context, tableIndex int) (int64, error) {
+	table := s.tableDiffs[tableIndex]
+	matchSource := getMatchSource(s.sourceTableMap, table)
+	count, err := dbutil.GetRowCount(ctx, s.dbConn, matchSource.OriginSchema, matchSource.OriginTable, table.Range, nil)
+	return count, errors.Trace(err)
+}
+
+func (s *TiDBSource) GetDuplicateKeys(ctx context.Context, tableIndex int, limit int) ([]*utils.DuplicateKey, error) {
+	table := s.tableDiffs[tableIndex]
+	matchSource := getMatchSource(s.sourceTableMap, table)
+	_, keys := dbutil.SelectUniqueOrderKey(table.Info)
+	duplicateKeys, err := utils.GetDuplicateKeys(ctx, s.dbConn, matchSource.OriginSchema, matchSource.OriginTable, keys, table.Range, nil, limit)
+	return duplicateKeys, errors.Trace(err)
+}
+
+func (s *TiDBSource) GetAdminChecksum(ctx context.Context, tableIndex int) (*utils.TableChecksum, error) {
+	table := s.tableDiffs[tableIndex]
+	matchSource := getMatchSource(s.sourceTableMap, table)
+	checksum, err := utils.GetAdminChecksum(ctx, s.dbConn, matchSource.OriginSchema, matchSource.OriginTable)
+	return checksum, errors.Trace(err)
+}
+
 func (s *TiDBSource) GetTables() []*common.TableDiff {
 	return s.tableDiffs
 }
@@ -141,7 +217,7 @@ func (s *TiDBSource) GetSourceStructInfo(ctx context.Context, tableIndex int) ([
 	tableInfos := make([]*model.TableInfo, 1)
 	tableDiff := s.GetTables()[tableIndex]
 	source := getMatchSource(s.sourceTableMap, tableDiff)
-	tableInfos[0], err = dbutil.GetTableInfo(ctx, s.GetDB(), source.OriginSchema, source.OriginTable)
+	tableInfos[0], err = s.tableInfoCache.GetTableInfo(ctx, s.GetDB(), source.OriginSchema, source.OriginTable)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -149,18 +225,12 @@ func (s *TiDBSource) GetSourceStructInfo(ctx context.Context, tableIndex int) ([
 	return tableInfos, nil
 }
 
-func (s *TiDBSource) GenerateFixSQL(t DMLType, upstreamData, downstreamData map[string]*dbutil.ColumnData, tableIndex int) string {
-	if t == Insert {
-		return utils.GenerateReplaceDML(upstreamData, s.tableDiffs[tableIndex].Info, s.tableDiffs[tableIndex].Schema)
-	}
-	if t == Delete {
-		return utils.GenerateDeleteDML(downstreamData, s.tableDiffs[tableIndex].Info, s.tableDiffs[tableIndex].Schema)
-	}
-	if t == Replace {
-		return utils.GenerateReplaceDMLWithAnnotation(upstreamData, downstreamData, s.tableDiffs[tableIndex].Info, s.tableDiffs[tableIndex].Schema)
-	}
-	log.Fatal("Don't support this type", zap.Any("dml type", t))
-	return ""
+// GenerateFixSQL generates the fix sql for this source, the table name is the origin name before routing.
+func (s *TiDBSource) GenerateFixSQL(t DMLType, upstreamData, downstreamData map[string]*dbutil.ColumnData, tableIndex int) (string, error) {
+	table := s.tableDiffs[tableIndex]
+	matchedSource := getMatchSource(s.sourceTableMap, table)
+	tableInfo := getOriginTableInfo(table.Info, matchedSource.OriginTable)
+	return generateFixDML(t, table.FixSQLMode, upstreamData, downstreamData, tableInfo, matchedSource.OriginSchema), nil
 }
 
 func (s *TiDBSource) GetRowsIterator(ctx context.Context, tableRange *splitter.RangeInfo) (RowDataIterator, error) {
@@ -168,10 +238,10 @@ func (s *TiDBSource) GetRowsIterator(ctx context.Context, tableRange *splitter.R
 
 	table := s.tableDiffs[tableRange.GetTableIndex()]
 	matchedSource := getMatchSource(s.sourceTableMap, table)
-	rowsQuery, _ := utils.GetTableRowsQueryFormat(matchedSource.OriginSchema, matchedSource.OriginTable, table.Info, table.Collation)
+	rowsQuery, _ := utils.GetTableRowsQueryFormat(matchedSource.OriginSchema, matchedSource.OriginTable, chunk.Partition, table.Info, s.columnTransforms(table), table.Collation)
 	query := fmt.Sprintf(rowsQuery, chunk.Where)
 
-	log.Debug("select data", zap.String("sql", query), zap.Reflect("args", chunk.Args))
+	logChunkSQL("select data", table, tableRange, query, chunk.Args)
 	rows, err := s.dbConn.QueryContext(ctx, query, chunk.Args...)
 	if err != nil {
 		return nil, errors.Trace(err)
@@ -189,6 +259,26 @@ func (s *TiDBSource) GetSnapshot() string {
 	return s.snapshot
 }
 
+func (s *TiDBSource) WithoutSnapshot(ctx context.Context) (Source, error) {
+	if len(s.snapshot) == 0 {
+		return s, nil
+	}
+	dbConfig := *s.dbConfig
+	dbConfig.Snapshot = ""
+	vars := map[string]string{
+		"time_zone": UnifiedTimeZone,
+	}
+	conn, err := common.CreateDB(ctx, &dbConfig, vars, s.checkThreadCount+1)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	latest := *s
+	latest.snapshot = ""
+	latest.dbConn = conn
+	latest.tableInfoCache = newTableInfoCache()
+	return &latest, nil
+}
+
 func getSourceTableMap(ctx context.Context, tableDiffs []*common.TableDiff, ds *config.DataSource) (map[string]*common.TableSource, error) {
 	sourceTableMap := make(map[string]*common.TableSource)
 	if ds.Router != nil {
@@ -200,6 +290,7 @@ func getSourceTableMap(ctx context.Context, tableDiffs []*common.TableDiff, ds *
 			uniqueMap[utils.UniqueID(tableDiff.Schema, tableDiff.Table)] = struct{}{}
 		}
 
+		withViews := hasViewDiffs(tableDiffs)
 		// instance -> db -> table
 		allTablesMap := make(map[string]map[string]interface{})
 		sourceSchemas, err := dbutil.GetSchemas(ctx, ds.Conn)
@@ -212,7 +303,7 @@ func getSourceTableMap(ctx context.Context, tableDiffs []*common.TableDiff, ds *
 				// ignore system schema
 				continue
 			}
-			allTables, err := dbutil.GetTables(ctx, ds.Conn, schema)
+			allTables, err := getSourceTables(ctx, ds.Conn, schema, withViews)
 			if err != nil {
 				return nil, errors.Annotatef(err, "get tables from %s", schema)
 			}
@@ -258,7 +349,9 @@ func NewTiDBSource(ctx context.Context, tableDiffs []*common.TableDiff, ds *conf
 		sourceTableMap:   sourceTableMap,
 		snapshot:         ds.Snapshot,
 		dbConn:           ds.Conn,
+		dbConfig:         ds.ToDBConfig(),
 		checkThreadCount: checkThreadCount,
+		tableInfoCache:   newTableInfoCache(),
 	}
 	return ts, nil
 }
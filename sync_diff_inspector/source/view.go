@@ -0,0 +1,181 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package source
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/pkg/filter"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/utils"
+)
+
+// GetViewDiffs returns the views matched by `check-tables` on the target, and the views on the sources routed to them,
+// sorted by the names on the target. The definitions of the views are only read if `check-views` is enabled.
+func GetViewDiffs(ctx context.Context, cfg *config.Config) ([]*common.ViewDiff, error) {
+	viewDiffs := make(map[string]*common.ViewDiff)
+	targetConn := cfg.Task.TargetInstance.Conn
+	targetViews, err := getViews(ctx, targetConn)
+	if err != nil {
+		return nil, errors.Annotate(err, "get views from target source")
+	}
+	for _, view := range targetViews {
+		schema, name := view[0], view[1]
+		if !cfg.Task.TargetCheckTables.MatchTable(schema, name) {
+			continue
+		}
+		viewDiff := &common.ViewDiff{Schema: schema, View: name}
+		if cfg.CheckViews {
+			if viewDiff.TargetDefinition, err = getViewDefinition(ctx, targetConn, schema, name); err != nil {
+				return nil, errors.Annotate(err, "from target source")
+			}
+		}
+		viewDiffs[utils.UniqueID(schema, name)] = viewDiff
+	}
+
+	for _, source := range cfg.Task.SourceInstances {
+		sourceViews, err := getViews(ctx, source.Conn)
+		if err != nil {
+			return nil, errors.Annotate(err, "get views from source")
+		}
+		for _, view := range sourceViews {
+			schema, name := view[0], view[1]
+			targetSchema, targetName, err := source.Router.Route(schema, name)
+			if err != nil {
+				return nil, errors.Errorf("get route result for %s.%s failed, error %v", schema, name, err)
+			}
+			if !cfg.Task.TargetCheckTables.MatchTable(targetSchema, targetName) {
+				continue
+			}
+			uniqueID := utils.UniqueID(targetSchema, targetName)
+			viewDiff, ok := viewDiffs[uniqueID]
+			if !ok {
+				viewDiff = &common.ViewDiff{Schema: targetSchema, View: targetName, MissingOnTarget: true}
+				viewDiffs[uniqueID] = viewDiff
+			}
+			definition := ""
+			if cfg.CheckViews && !viewDiff.MissingOnTarget {
+				if definition, err = getViewDefinition(ctx, source.Conn, schema, name); err != nil {
+					return nil, errors.Annotate(err, "from source")
+				}
+			}
+			viewDiff.SourceDefinitions = append(viewDiff.SourceDefinitions, definition)
+		}
+	}
+
+	views := make([]*common.ViewDiff, 0, len(viewDiffs))
+	for _, viewDiff := range viewDiffs {
+		views = append(views, viewDiff)
+	}
+	sort.Slice(views, func(i, j int) bool {
+		return utils.UniqueID(views[i].Schema, views[i].View) < utils.UniqueID(views[j].Schema, views[j].View)
+	})
+	return views, nil
+}
+
+// getViews returns the schema and name of the views in the database, the views in the system schemas are excluded.
+func getViews(ctx context.Context, db *sql.DB) ([][2]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT TABLE_SCHEMA, TABLE_NAME FROM information_schema.TABLES WHERE TABLE_TYPE = 'VIEW' ORDER BY TABLE_SCHEMA, TABLE_NAME")
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer rows.Close()
+	views := make([][2]string, 0)
+	for rows.Next() {
+		var schema, name string
+		if err := rows.Scan(&schema, &name); err != nil {
+			return nil, errors.Trace(err)
+		}
+		if filter.IsSystemSchema(schema) {
+			continue
+		}
+		views = append(views, [2]string{schema, name})
+	}
+	return views, errors.Trace(rows.Err())
+}
+
+// getUpdatableViews returns the schema and name of the updatable views in the database, whose data is compared by
+// check-view-data, the views in the system schemas are excluded.
+func getUpdatableViews(ctx context.Context, db *sql.DB) ([][2]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT TABLE_SCHEMA, TABLE_NAME FROM information_schema.VIEWS WHERE IS_UPDATABLE = 'YES' ORDER BY TABLE_SCHEMA, TABLE_NAME")
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer rows.Close()
+	views := make([][2]string, 0)
+	for rows.Next() {
+		var schema, name string
+		if err := rows.Scan(&schema, &name); err != nil {
+			return nil, errors.Trace(err)
+		}
+		if filter.IsSystemSchema(schema) {
+			continue
+		}
+		views = append(views, [2]string{schema, name})
+	}
+	return views, errors.Trace(rows.Err())
+}
+
+// hasViewDiffs returns whether the data of any view is compared by check-view-data.
+func hasViewDiffs(tableDiffs []*common.TableDiff) bool {
+	for _, tableDiff := range tableDiffs {
+		if tableDiff.IsView {
+			return true
+		}
+	}
+	return false
+}
+
+// getSourceTables returns the tables in the schema of the source like `dbutil.GetTables`, and the views too if
+// withViews is true, so that the views compared by check-view-data are matched with the views on the sources.
+func getSourceTables(ctx context.Context, db *sql.DB, schema string, withViews bool) ([]string, error) {
+	tables, err := dbutil.GetTables(ctx, db, schema)
+	if err != nil || !withViews {
+		return tables, errors.Trace(err)
+	}
+	views, err := dbutil.GetViews(ctx, db, schema)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return append(tables, views...), nil
+}
+
+// getViewDefinition returns the definition of the view normalized by `utils.NormalizeCreateView`.
+func getViewDefinition(ctx context.Context, db *sql.DB, schema, view string) (string, error) {
+	/*
+		show create view example result:
+		mysql> SHOW CREATE VIEW `test`.`v`;
+		+------+-----------------------------------------------------------------------------------------------------------------------------+----------------------+----------------------+
+		| View | Create View                                                                                                                 | character_set_client | collation_connection |
+		+------+-----------------------------------------------------------------------------------------------------------------------------+----------------------+----------------------+
+		| v    | CREATE ALGORITHM=UNDEFINED DEFINER=`root`@`%` SQL SECURITY DEFINER VIEW `v` AS select `test`.`t`.`a` AS `a` from `test`.`t` | utf8mb4              | utf8mb4_general_ci   |
+		+------+-----------------------------------------------------------------------------------------------------------------------------+----------------------+----------------------+
+	*/
+	var name, createView, charset, collation sql.NullString
+	query := fmt.Sprintf("SHOW CREATE VIEW %s", dbutil.TableName(schema, view))
+	if err := db.QueryRowContext(ctx, query).Scan(&name, &createView, &charset, &collation); err != nil {
+		return "", errors.Trace(err)
+	}
+	definition, err := utils.NormalizeCreateView(createView.String, schema)
+	if err != nil {
+		return "", errors.Annotatef(err, "normalize the definition of view %s", dbutil.TableName(schema, view))
+	}
+	return definition, nil
+}
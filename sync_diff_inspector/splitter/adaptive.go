@@ -0,0 +1,192 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package splitter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/chunk"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/utils"
+	"github.com/pingcap/tidb/parser/model"
+	"go.uber.org/zap"
+)
+
+// adaptiveSamplesPerChunk is the number of the rows sampled for each chunk by adaptive-chunking.
+var adaptiveSamplesPerChunk = 10
+
+// ChunkingStats is how the chunks of a table are split by adaptive-chunking.
+type ChunkingStats struct {
+	// Chunks is the number of the chunks split.
+	Chunks int
+	// SkewFactor is the most sampled rows in a chunk divided by the average, 1 means the chunks hold equal rows.
+	// It's greater than 1 if a value of the split fields is shared by many rows, which can't be split.
+	SkewFactor float64
+}
+
+// merge merges the stats of the chunks split separately, e.g. partition by partition.
+func (s *ChunkingStats) merge(other *ChunkingStats) *ChunkingStats {
+	if s == nil {
+		return other
+	}
+	if other == nil {
+		return s
+	}
+	merged := &ChunkingStats{Chunks: s.Chunks + other.Chunks, SkewFactor: s.SkewFactor}
+	if other.SkewFactor > merged.SkewFactor {
+		merged.SkewFactor = other.SkewFactor
+	}
+	return merged
+}
+
+// splitRange splits the chunk into `count` chunks by adaptive-chunking if it's enabled for the table, and falls back
+// to `splitRangeByRandom` if the rows can't be sampled. The stats are nil if the chunks are split by random.
+func splitRange(ctx context.Context, db *sql.DB, chunkRange *chunk.Range, count int, table *common.TableDiff, partition string, columns []*model.ColumnInfo) ([]*chunk.Range, *ChunkingStats, error) {
+	if table.AdaptiveChunking && count > 1 {
+		chunks, stats, err := splitRangeByAdaptive(ctx, db, chunkRange, count, table.Schema, table.Table, partition, columns, table.Range, table.Collation)
+		if err == nil {
+			return chunks, stats, nil
+		}
+		log.Warn("failed to split chunks by adaptive-chunking, fall back to split by random",
+			zap.String("table", dbutil.TableName(table.Schema, table.Table)), zap.String("partition", partition), zap.Error(err))
+	}
+	chunks, err := splitRangeByRandom(db, chunkRange, count, table.Schema, table.Table, partition, columns, table.Range, table.Collation)
+	return chunks, nil, errors.Trace(err)
+}
+
+// splitRangeByAdaptive splits the chunk into at most `count` chunks holding roughly equal rows. The rows of the split
+// fields are sampled in the chunk and sorted, and every `len(samples)/count`th of them is a bound, so the chunks follow
+// the distribution of the fields rather than their values, e.g. the sequential ids with big gaps.
+func splitRangeByAdaptive(ctx context.Context, db *sql.DB, chunkRange *chunk.Range, count int, schema, table, partition string, columns []*model.ColumnInfo, limits, collation string) ([]*chunk.Range, *ChunkingStats, error) {
+	chunkLimits, args := chunkRange.ToString(collation)
+	limitRange := fmt.Sprintf("(%s) AND (%s)", chunkLimits, limits)
+	samples, err := getSampleRows(ctx, db, schema, table, partition, columns, count*adaptiveSamplesPerChunk, limitRange, args, collation)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	if len(samples) == 0 {
+		return nil, nil, errors.Errorf("no row is sampled in %s", dbutil.TableName(schema, table))
+	}
+
+	// ends are the indexes of the last samples of the chunks, the samples equal to a bound are in the same chunk.
+	ends := make([]int, 0, count)
+	for i := 1; i < count; i++ {
+		end := i*len(samples)/count - 1
+		if len(ends) > 0 && end <= ends[len(ends)-1] {
+			end = ends[len(ends)-1] + 1
+		}
+		if end < 0 {
+			end = 0
+		}
+		for end+1 < len(samples) && sameSample(samples[end], samples[end+1]) {
+			end++
+		}
+		if end >= len(samples)-1 {
+			break
+		}
+		ends = append(ends, end)
+	}
+	ends = append(ends, len(samples)-1)
+
+	chunks := make([]*chunk.Range, 0, len(ends))
+	maxSamples := 0
+	for i, end := range ends {
+		newChunk := chunkRange.Copy()
+		for j, column := range columns {
+			if i > 0 {
+				newChunk.Update(column.Name.O, samples[ends[i-1]][j], "", true, false)
+			}
+			if i < len(ends)-1 {
+				newChunk.Update(column.Name.O, "", samples[end][j], false, true)
+			}
+		}
+		chunks = append(chunks, newChunk)
+
+		begin := 0
+		if i > 0 {
+			begin = ends[i-1] + 1
+		}
+		if end-begin+1 > maxSamples {
+			maxSamples = end - begin + 1
+		}
+	}
+	stats := &ChunkingStats{
+		Chunks:     len(chunks),
+		SkewFactor: float64(maxSamples) * float64(len(chunks)) / float64(len(samples)),
+	}
+	log.Debug("split range by adaptive", zap.Stringer("origin chunk", chunkRange), zap.Int("samples", len(samples)),
+		zap.Int("split num", stats.Chunks), zap.Float64("skew factor", stats.SkewFactor))
+	return chunks, stats, nil
+}
+
+// getSampleRows returns at most `num` random rows of the columns sorted by the columns, the rows with NULL values
+// are excluded. Tips: limitArgs is the value in limitRange.
+func getSampleRows(ctx context.Context, db *sql.DB, schema, table, partition string, columns []*model.ColumnInfo, num int, limitRange string, limitArgs []interface{}, collation string) ([][]string, error) {
+	if collation != "" {
+		collation = fmt.Sprintf(" COLLATE \"%s\"", collation)
+	}
+	columnNames := make([]string, 0, len(columns))
+	orderBy := make([]string, 0, len(columns))
+	for _, column := range columns {
+		columnNames = append(columnNames, dbutil.ColumnName(column.Name.O))
+		orderBy = append(orderBy, dbutil.ColumnName(column.Name.O)+collation)
+	}
+	query := fmt.Sprintf("SELECT %[1]s FROM (SELECT %[1]s, rand() rand_value FROM %[2]s WHERE %[3]s ORDER BY rand_value LIMIT %[4]d)rand_tmp ORDER BY %[5]s",
+		strings.Join(columnNames, ", "), utils.TableNameWithPartition(schema, table, partition), limitRange, num, strings.Join(orderBy, ", "))
+	log.Debug("get sample rows", zap.String("sql", query), zap.Reflect("args", limitArgs))
+
+	rows, err := dbutil.QueryWithRetry(ctx, db, utils.QueryRetryPolicy, query, limitArgs...)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer rows.Close()
+
+	samples := make([][]string, 0, num)
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, errors.Trace(err)
+		}
+		sample := make([]string, 0, len(columns))
+		for _, value := range values {
+			if !value.Valid {
+				break
+			}
+			sample = append(sample, value.String)
+		}
+		if len(sample) == len(columns) {
+			samples = append(samples, sample)
+		}
+	}
+	return samples, errors.Trace(rows.Err())
+}
+
+func sameSample(a, b []string) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
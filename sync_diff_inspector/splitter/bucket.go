@@ -46,6 +46,8 @@ type BucketIterator struct {
 	cancel     context.CancelFunc
 	indexID    int64
 	progressID string
+	// withNullChunk is true if the NULL chunk of the table is produced before the other chunks.
+	withNullChunk bool
 
 	dbConn *sql.DB
 }
@@ -126,13 +128,35 @@ func (s *BucketIterator) Next() (*chunk.Range, error) {
 
 func (s *BucketIterator) init(startRange *RangeInfo) error {
 	s.nextChunk = 0
-	buckets, err := dbutil.GetBucketsInfo(context.Background(), s.dbConn, s.table.Schema, s.table.Table, s.table.Info)
+	var buckets map[string][]dbutil.Bucket
+	err := utils.QueryRetryPolicy.Do(context.Background(), func() error {
+		var err error
+		buckets, err = dbutil.GetBucketsInfo(context.Background(), s.dbConn, s.table.Schema, s.table.Table, s.table.Info)
+		return err
+	})
 	if err != nil {
 		return errors.Trace(err)
 	}
-	indices, err := utils.GetBetterIndex(context.Background(), s.dbConn, s.table.Schema, s.table.Table, s.table.Info)
-	if err != nil {
-		return errors.Trace(err)
+	var indices []*model.IndexInfo
+	if fields := utils.ParseIndexFields(s.table.Fields); len(fields) > 0 {
+		// only use the index specified by `index-fields`.
+		index, err := utils.FindIndexByFields(s.table.Info, fields)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if index == nil {
+			return errors.NotFoundf("index of the index-fields %v to split buckets", fields)
+		}
+		indices = []*model.IndexInfo{index}
+	} else {
+		err = utils.QueryRetryPolicy.Do(context.Background(), func() error {
+			var err error
+			indices, err = utils.GetBetterIndex(context.Background(), s.dbConn, s.table.Schema, s.table.Table, s.table.Info)
+			return err
+		})
+		if err != nil {
+			return errors.Trace(err)
+		}
 	}
 	for _, index := range indices {
 		if index == nil {
@@ -182,7 +206,7 @@ func (s *BucketIterator) Close() {
 
 func (s *BucketIterator) splitChunkForBucket(ctx context.Context, firstBucketID, lastBucketID int, beginIndex int, bucketChunkCnt int, splitChunkCnt int, chunkRange *chunk.Range) {
 	s.chunkPool.Apply(func() {
-		chunks, err := splitRangeByRandom(s.dbConn, chunkRange, splitChunkCnt, s.table.Schema, s.table.Table, s.indexColumns, s.table.Range, s.table.Collation)
+		chunks, err := splitRangeByRandom(s.dbConn, chunkRange, splitChunkCnt, s.table.Schema, s.table.Table, "", s.indexColumns, s.table.Range, s.table.Collation)
 		if err != nil {
 			select {
 			case <-ctx.Done():
@@ -191,6 +215,9 @@ func (s *BucketIterator) splitChunkForBucket(ctx context.Context, firstBucketID,
 			return
 		}
 		chunk.InitChunks(chunks, chunk.Bucket, firstBucketID, lastBucketID, beginIndex, s.table.Collation, s.table.Range, bucketChunkCnt)
+		for _, c := range chunks {
+			c.AfterNull = s.withNullChunk
+		}
 		progress.UpdateTotal(s.progressID, len(chunks), false)
 		s.chunksCh <- chunks
 	})
@@ -207,6 +234,16 @@ func (s *BucketIterator) produceChunks(ctx context.Context, startRange *RangeInf
 		latestCount              int64
 		err                      error
 	)
+	startRange, withNullChunk := nullChunkStartRange(startRange)
+	if withNullChunk && s.buckets[len(s.buckets)-1].Count >= s.chunkSize {
+		// the table is split by the buckets, whose bounds exclude the NULL values of the leading index column, so
+		// the NULL chunk is produced separately instead of being merged into the chunks of the buckets.
+		if nullChunk := newNullChunk(s.table, s.indexColumns[0], chunk.Bucket); nullChunk != nil {
+			s.withNullChunk = true
+			progress.UpdateTotal(s.progressID, 1, false)
+			s.chunksCh <- []*chunk.Range{nullChunk}
+		}
+	}
 	firstBucket := 0
 	if startRange != nil {
 		c := startRange.GetChunk()
@@ -51,7 +51,12 @@ func NewLimitIterator(ctx context.Context, progressID string, table *common.Tabl
 }
 
 func NewLimitIteratorWithCheckpoint(ctx context.Context, progressID string, table *common.TableDiff, dbConn *sql.DB, startRange *RangeInfo) (*LimitIterator, error) {
-	indices, err := utils.GetBetterIndex(ctx, dbConn, table.Schema, table.Table, table.Info)
+	var indices []*model.IndexInfo
+	err := utils.QueryRetryPolicy.Do(ctx, func() error {
+		var err error
+		indices, err = utils.GetBetterIndex(ctx, dbConn, table.Schema, table.Table, table.Info)
+		return err
+	})
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -114,7 +119,12 @@ func NewLimitIteratorWithCheckpoint(ctx context.Context, progressID string, tabl
 
 	chunkSize := table.ChunkSize
 	if chunkSize <= 0 {
-		cnt, err := dbutil.GetRowCount(ctx, dbConn, table.Schema, table.Table, "", nil)
+		var cnt int64
+		err := utils.QueryRetryPolicy.Do(ctx, func() error {
+			var err error
+			cnt, err = dbutil.GetRowCount(ctx, dbConn, table.Schema, table.Table, "", nil)
+			return err
+		})
 		if err != nil {
 			return nil, errors.Trace(err)
 		}
@@ -228,7 +238,7 @@ func (lmt *LimitIterator) produceChunks(ctx context.Context, bucketID int) {
 }
 
 func (lmt *LimitIterator) getLimitRow(ctx context.Context, query string, args []interface{}) (map[string]*dbutil.ColumnData, error) {
-	rows, err := lmt.dbConn.QueryContext(ctx, query, args...)
+	rows, err := dbutil.QueryWithRetry(ctx, lmt.dbConn, utils.QueryRetryPolicy, query, args...)
 	if err != nil {
 		return nil, err
 	}
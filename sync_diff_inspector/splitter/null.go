@@ -0,0 +1,43 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package splitter
+
+import (
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/chunk"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+)
+
+// nullChunkStartRange returns the range to resume splitting the chunks from, and whether the NULL chunk of the table
+// is produced, see `chunk.NewNullChunkRange`. The NULL chunk is only produced if the table is compared from the
+// beginning, and the other chunks are split from the beginning if the checkpoint is the NULL chunk.
+func nullChunkStartRange(startRange *RangeInfo) (*RangeInfo, bool) {
+	if startRange == nil {
+		return nil, true
+	}
+	if startRange.GetChunk().IsNullChunk() {
+		return nil, false
+	}
+	return startRange, false
+}
+
+// newNullChunk returns the chunk of the rows whose value of the leading column to split the chunks is NULL, which
+// are excluded by the bounds of the other chunks. It returns nil if the column isn't nullable.
+func newNullChunk(table *common.TableDiff, column *model.ColumnInfo, t chunk.ChunkType) *chunk.Range {
+	if mysql.HasNotNullFlag(column.Flag) {
+		return nil
+	}
+	return chunk.NewNullChunkRange(column.Name.O, t, table.Collation, table.Range)
+}
@@ -0,0 +1,263 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package splitter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/chunk"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/progress"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/utils"
+	"github.com/pingcap/tidb/parser/model"
+	"go.uber.org/zap"
+)
+
+// PartitionIterator splits the chunks of the partitioned table partition by partition, the chunks of each partition
+// are split by random, and the rows of the chunks are selected from the partition by `PARTITION (p)`.
+// The index of the partition is the bucket index of the chunk id, so the comparison can be resumed in the partition.
+type PartitionIterator struct {
+	table     *common.TableDiff
+	chunks    []*chunk.Range
+	nextChunk uint
+	// indexID is the id of the index of the split fields, 0 if the fields are not indexed.
+	indexID int64
+	// chunking is the stats of the chunks of all the partitions split by adaptive-chunking, nil if they are split
+	// by random or resumed from the checkpoint.
+	chunking *ChunkingStats
+
+	dbConn *sql.DB
+}
+
+func NewPartitionIterator(ctx context.Context, progressID string, table *common.TableDiff, dbConn *sql.DB) (*PartitionIterator, error) {
+	return NewPartitionIteratorWithCheckpoint(ctx, progressID, table, dbConn, nil)
+}
+
+func NewPartitionIteratorWithCheckpoint(ctx context.Context, progressID string, table *common.TableDiff, dbConn *sql.DB, startRange *RangeInfo) (*PartitionIterator, error) {
+	fields, indexID, err := getRandomSplitFields(table.Info, utils.ParseIndexFields(table.Fields))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if startRange != nil && startRange.IndexID != 0 && startRange.IndexID != indexID {
+		return nil, errors.Errorf("the index to split chunks of table %s is changed since the checkpoint, please use another output-dir and start over again",
+			dbutil.TableName(table.Schema, table.Table))
+	}
+	partitions, err := GetPartitions(ctx, dbConn, table.Schema, table.Table)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if len(partitions) == 0 {
+		return nil, errors.Errorf("table %s is not partitioned", dbutil.TableName(table.Schema, table.Table))
+	}
+
+	iter := &PartitionIterator{
+		table:   table,
+		indexID: indexID,
+		dbConn:  dbConn,
+	}
+	firstPartition := 0
+	var startChunk *chunk.Range
+	if startRange != nil {
+		c := startRange.GetChunk()
+		if c.IsLastChunkForTable() {
+			return iter, nil
+		}
+		partitionIndex := c.Index.BucketIndexLeft
+		if partitionIndex >= len(partitions) || partitions[partitionIndex] != c.Partition {
+			return nil, errors.Errorf("the partitions of table %s are changed since the checkpoint, please use another output-dir and start over again",
+				dbutil.TableName(table.Schema, table.Table))
+		}
+		firstPartition = partitionIndex
+		if c.IsLastChunkForBucket() {
+			firstPartition++
+		} else {
+			startChunk = c
+		}
+	}
+
+	for i := firstPartition; i < len(partitions); i++ {
+		chunks, chunking, err := splitPartition(ctx, dbConn, table, fields, i, partitions[i], startChunk)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		startChunk = nil
+		iter.chunks = append(iter.chunks, chunks...)
+		if startRange == nil {
+			iter.chunking = iter.chunking.merge(chunking)
+		}
+	}
+	if len(iter.chunks) > 0 {
+		// the bounds restart in each partition, so the first and the last chunks of the table are marked.
+		if startRange == nil {
+			iter.chunks[0].IsFirst = true
+		}
+		iter.chunks[len(iter.chunks)-1].IsLast = true
+	}
+
+	progress.StartTable(progressID, len(iter.chunks), true)
+	return iter, nil
+}
+
+// splitPartition splits the chunks of the partition by random or adaptive-chunking, `startChunk` is the chunk of the partition
+// in the checkpoint, and the chunks after it are split.
+func splitPartition(ctx context.Context, db *sql.DB, table *common.TableDiff, fields []*model.ColumnInfo, partitionIndex int, partition string, startChunk *chunk.Range) ([]*chunk.Range, *ChunkingStats, error) {
+	chunkRange := chunk.NewChunkRange()
+	beginIndex := 0
+	chunkCnt := 0
+	if startChunk != nil {
+		for _, bound := range startChunk.Bounds {
+			chunkRange.Update(bound.Column, bound.Upper, "", true, false)
+		}
+		beginIndex = startChunk.Index.ChunkIndex + 1
+		chunkCnt = startChunk.Index.ChunkCnt - beginIndex
+	} else {
+		cnt, err := getPartitionRowCount(ctx, db, table.Schema, table.Table, partition, table.Range)
+		if err != nil {
+			return nil, nil, errors.Trace(err)
+		}
+		chunkSize := table.ChunkSize
+		if chunkSize <= 0 {
+			if len(table.Info.Indices) != 0 {
+				chunkSize = utils.CalculateChunkSize(cnt)
+			} else {
+				chunkSize = cnt
+			}
+		}
+		if chunkSize > 0 {
+			chunkCnt = int((cnt + chunkSize - 1) / chunkSize)
+		}
+		log.Info("split partition by random", zap.String("table", dbutil.TableName(table.Schema, table.Table)),
+			zap.String("partition", partition), zap.Int64("row count", cnt), zap.Int("split chunk num", chunkCnt))
+	}
+
+	chunks, chunking, err := splitRange(ctx, db, chunkRange, chunkCnt, table, partition, fields)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	// the chunk count is the actual number of chunks, because the last chunk of the partition is decided by it.
+	chunk.InitChunks(chunks, chunk.Random, partitionIndex, partitionIndex, beginIndex, table.Collation, table.Range, beginIndex+len(chunks))
+	for _, c := range chunks {
+		c.Partition = partition
+	}
+	return chunks, chunking, nil
+}
+
+func (s *PartitionIterator) Next() (*chunk.Range, error) {
+	if uint(len(s.chunks)) <= s.nextChunk {
+		return nil, nil
+	}
+	c := s.chunks[s.nextChunk]
+	s.nextChunk = s.nextChunk + 1
+	return c, nil
+}
+
+// GetIndexID returns the id of the index to split chunks, which is recorded in the checkpoint.
+func (s *PartitionIterator) GetIndexID() int64 {
+	return s.indexID
+}
+
+// GetChunkingStats returns the stats of the chunks split by adaptive-chunking, nil if they are not.
+func (s *PartitionIterator) GetChunkingStats() *ChunkingStats {
+	return s.chunking
+}
+
+func (s *PartitionIterator) Close() {
+
+}
+
+// GetPartitions returns the names of the partitions of the table in order from `information_schema.PARTITIONS`,
+// which is empty if the table isn't partitioned.
+func GetPartitions(ctx context.Context, db *sql.DB, schema, table string) ([]string, error) {
+	query := "SELECT PARTITION_NAME FROM information_schema.PARTITIONS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND PARTITION_NAME IS NOT NULL ORDER BY PARTITION_ORDINAL_POSITION"
+	rows, err := dbutil.QueryWithRetry(ctx, db, utils.QueryRetryPolicy, query, schema, table)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer rows.Close()
+
+	partitions := make([]string, 0)
+	for rows.Next() {
+		var partition string
+		if err := rows.Scan(&partition); err != nil {
+			return nil, errors.Trace(err)
+		}
+		// the partition is listed for each of its subpartitions.
+		if len(partitions) > 0 && partitions[len(partitions)-1] == partition {
+			continue
+		}
+		partitions = append(partitions, partition)
+	}
+	return partitions, errors.Trace(rows.Err())
+}
+
+func getPartitionRowCount(ctx context.Context, db *sql.DB, schema, table, partition, where string) (int64, error) {
+	query := fmt.Sprintf("SELECT COUNT(1) cnt FROM %s", utils.TableNameWithPartition(schema, table, partition))
+	if len(where) > 0 {
+		query += fmt.Sprintf(" WHERE %s", where)
+	}
+	log.Debug("get row count", zap.String("sql", query))
+	var cnt sql.NullInt64
+	err := utils.QueryRetryPolicy.Do(ctx, func() error {
+		return db.QueryRowContext(ctx, query).Scan(&cnt)
+	})
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	return cnt.Int64, nil
+}
+
+// getRandomValues returns some random values of the column in the partition, see `dbutil.GetRandomValues`.
+func getRandomValues(ctx context.Context, db *sql.DB, schema, table, partition, column string, num int, limitRange string, limitArgs []interface{}, collation string) ([]string, error) {
+	if len(partition) == 0 {
+		var randomValues []string
+		err := utils.QueryRetryPolicy.Do(ctx, func() error {
+			var err error
+			randomValues, err = dbutil.GetRandomValues(ctx, db, schema, table, column, num, limitRange, limitArgs, collation)
+			return err
+		})
+		return randomValues, errors.Trace(err)
+	}
+	if limitRange == "" {
+		limitRange = "TRUE"
+	}
+	if collation != "" {
+		collation = fmt.Sprintf(" COLLATE \"%s\"", collation)
+	}
+	query := fmt.Sprintf("SELECT %[1]s FROM (SELECT %[1]s, rand() rand_value FROM %[2]s WHERE %[3]s ORDER BY rand_value LIMIT %[4]d)rand_tmp ORDER BY %[1]s%[5]s",
+		dbutil.ColumnName(column), utils.TableNameWithPartition(schema, table, partition), limitRange, num, collation)
+	log.Debug("get random values", zap.String("sql", query), zap.Reflect("args", limitArgs))
+
+	rows, err := dbutil.QueryWithRetry(ctx, db, utils.QueryRetryPolicy, query, limitArgs...)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer rows.Close()
+
+	randomValues := make([]string, 0, num)
+	for rows.Next() {
+		var value sql.NullString
+		if err := rows.Scan(&value); err != nil {
+			return nil, errors.Trace(err)
+		}
+		if value.Valid {
+			randomValues = append(randomValues, value.String)
+		}
+	}
+	return randomValues, errors.Trace(rows.Err())
+}
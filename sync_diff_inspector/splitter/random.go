@@ -36,6 +36,11 @@ type RandomIterator struct {
 	chunkSize int64
 	chunks    []*chunk.Range
 	nextChunk uint
+	// indexID is the id of the index of the split fields, 0 if the fields are not indexed.
+	indexID int64
+	// chunking is the stats of the chunks split by adaptive-chunking, nil if they are split by random or resumed
+	// from the checkpoint.
+	chunking *ChunkingStats
 
 	dbConn *sql.DB
 }
@@ -46,19 +51,15 @@ func NewRandomIterator(ctx context.Context, progressID string, table *common.Tab
 
 func NewRandomIteratorWithCheckpoint(ctx context.Context, progressID string, table *common.TableDiff, dbConn *sql.DB, startRange *RangeInfo) (*RandomIterator, error) {
 	// get the chunk count by data count and chunk size
-	var splitFieldArr []string
-	if len(table.Fields) != 0 {
-		splitFieldArr = strings.Split(table.Fields, ",")
-	}
-
-	for i := range splitFieldArr {
-		splitFieldArr[i] = strings.TrimSpace(splitFieldArr[i])
-	}
-
-	fields, err := GetSplitFields(table.Info, splitFieldArr)
+	fields, indexID, err := getRandomSplitFields(table.Info, utils.ParseIndexFields(table.Fields))
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	if startRange != nil && startRange.IndexID != 0 && startRange.IndexID != indexID {
+		return nil, errors.Errorf("the index to split chunks of table %s is changed since the checkpoint, please use another output-dir and start over again",
+			dbutil.TableName(table.Schema, table.Table))
+	}
+	startRange, withNullChunk := nullChunkStartRange(startRange)
 
 	chunkRange := chunk.NewChunkRange()
 	beginIndex := 0
@@ -73,6 +74,7 @@ func NewRandomIteratorWithCheckpoint(ctx context.Context, progressID string, tab
 				chunkSize: 0,
 				chunks:    nil,
 				nextChunk: 0,
+				indexID:   indexID,
 				dbConn:    dbConn,
 			}, nil
 		}
@@ -87,7 +89,12 @@ func NewRandomIteratorWithCheckpoint(ctx context.Context, progressID string, tab
 		// For chunk splitted by random splitter, the checkpoint chunk records the tableCnt.
 		chunkCnt = bucketChunkCnt - beginIndex
 	} else {
-		cnt, err := dbutil.GetRowCount(ctx, dbConn, table.Schema, table.Table, table.Range, nil)
+		var cnt int64
+		err := utils.QueryRetryPolicy.Do(ctx, func() error {
+			var err error
+			cnt, err = dbutil.GetRowCount(ctx, dbConn, table.Schema, table.Table, table.Range, nil)
+			return err
+		})
 		if err != nil {
 			return nil, errors.Trace(err)
 		}
@@ -115,11 +122,23 @@ func NewRandomIteratorWithCheckpoint(ctx context.Context, progressID string, tab
 		bucketChunkCnt = chunkCnt
 	}
 
-	chunks, err := splitRangeByRandom(dbConn, chunkRange, chunkCnt, table.Schema, table.Table, fields, table.Range, table.Collation)
+	chunks, chunking, err := splitRange(ctx, dbConn, chunkRange, chunkCnt, table, "", fields)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	if startRange != nil {
+		chunking = nil
+	}
 	chunk.InitChunks(chunks, chunk.Random, 0, 0, beginIndex, table.Collation, table.Range, bucketChunkCnt)
+	if withNullChunk && len(chunks) > 1 {
+		// the NULL values of the leading field are excluded by the bounds of the split chunks.
+		if nullChunk := newNullChunk(table, fields[0], chunk.Random); nullChunk != nil {
+			for _, c := range chunks {
+				c.AfterNull = true
+			}
+			chunks = append([]*chunk.Range{nullChunk}, chunks...)
+		}
+	}
 
 	failpoint.Inject("ignore-last-n-chunk-in-bucket", func(v failpoint.Value) {
 		log.Info("failpoint ignore-last-n-chunk-in-bucket injected (random splitter)", zap.Int("n", v.(int)))
@@ -135,11 +154,28 @@ func NewRandomIteratorWithCheckpoint(ctx context.Context, progressID string, tab
 		chunkSize: chunkSize,
 		chunks:    chunks,
 		nextChunk: 0,
+		indexID:   indexID,
+		chunking:  chunking,
 		dbConn:    dbConn,
 	}, nil
 
 }
 
+// NewWholeTableIteratorWithCheckpoint returns the iterator of only one chunk covering the whole table, which is used to
+// compare the tables without primary key or unique key, whose rows are compared as multisets.
+func NewWholeTableIteratorWithCheckpoint(progressID string, table *common.TableDiff, startRange *RangeInfo) *RandomIterator {
+	iter := &RandomIterator{table: table}
+	if startRange != nil {
+		// the checkpoint is saved after the only chunk is compared.
+		return iter
+	}
+	c := chunk.NewChunkRange()
+	chunk.InitChunks([]*chunk.Range{c}, chunk.Random, 0, 0, 0, table.Collation, table.Range, 1)
+	iter.chunks = []*chunk.Range{c}
+	progress.StartTable(progressID, 1, true)
+	return iter
+}
+
 func (s *RandomIterator) Next() (*chunk.Range, error) {
 	if uint(len(s.chunks)) <= s.nextChunk {
 		return nil, nil
@@ -158,10 +194,47 @@ func (s *RandomIterator) Next() (*chunk.Range, error) {
 	return c, nil
 }
 
+// GetIndexID returns the id of the index to split chunks, which is recorded in the checkpoint.
+func (s *RandomIterator) GetIndexID() int64 {
+	return s.indexID
+}
+
+// GetChunkingStats returns the stats of the chunks split by adaptive-chunking, nil if they are not.
+func (s *RandomIterator) GetChunkingStats() *ChunkingStats {
+	return s.chunking
+}
+
 func (s *RandomIterator) Close() {
 
 }
 
+// getRandomSplitFields returns the fields to split chunks and the id of their index, 0 if not indexed.
+// The `splitFields` is the name of an index or the columns, see `utils.FindIndexByFields`.
+func getRandomSplitFields(table *model.TableInfo, splitFields []string) ([]*model.ColumnInfo, int64, error) {
+	index, err := utils.FindIndexByFields(table, splitFields)
+	if err != nil {
+		return nil, 0, errors.Trace(err)
+	}
+	if index != nil && len(splitFields) == 1 && strings.EqualFold(index.Name.O, splitFields[0]) {
+		// split by the columns of the index.
+		return utils.GetColumnsFromIndex(index, table), index.ID, nil
+	}
+	if index == nil && len(splitFields) == 0 {
+		if indices := dbutil.FindAllIndex(table); len(indices) > 0 {
+			index = indices[0]
+		}
+	}
+	fields, err := GetSplitFields(table, splitFields)
+	if err != nil {
+		return nil, 0, errors.Trace(err)
+	}
+	if index == nil || len(fields) != len(index.Columns) {
+		// the leading columns of an index only.
+		return fields, 0, nil
+	}
+	return fields, index.ID, nil
+}
+
 // GetSplitFields returns fields to split chunks, order by pk, uk, index, columns.
 func GetSplitFields(table *model.TableInfo, splitFields []string) ([]*model.ColumnInfo, error) {
 	cols := make([]*model.ColumnInfo, 0, len(table.Columns))
@@ -202,7 +275,7 @@ func GetSplitFields(table *model.TableInfo, splitFields []string) ([]*model.Colu
 //		there are 3 rows(`[a: 2, b: 2]`, `[a: 3, b: 5]`, `[a: 4, b: 4]`) in the table.
 //		and finally this function might generate `[a:2,b:2]` and `[a:3,b:4]` (from `a` get random value 2,4, `b` get random value 2,4) as split points, which means
 //		chunk whose range is (`a:2,b:2`, `a:3,b:4`], so we get a empty chunk.
-func splitRangeByRandom(db *sql.DB, chunk *chunk.Range, count int, schema string, table string, columns []*model.ColumnInfo, limits, collation string) (chunks []*chunk.Range, err error) {
+func splitRangeByRandom(db *sql.DB, chunk *chunk.Range, count int, schema string, table string, partition string, columns []*model.ColumnInfo, limits, collation string) (chunks []*chunk.Range, err error) {
 	if count <= 1 {
 		chunks = append(chunks, chunk)
 		return chunks, nil
@@ -213,7 +286,7 @@ func splitRangeByRandom(db *sql.DB, chunk *chunk.Range, count int, schema string
 
 	randomValues := make([][]string, len(columns))
 	for i, column := range columns {
-		randomValues[i], err = dbutil.GetRandomValues(context.Background(), db, schema, table, column.Name.O, count-1, limitRange, args, collation)
+		randomValues[i], err = getRandomValues(context.Background(), db, schema, table, partition, column.Name.O, count-1, limitRange, args, collation)
 		if err != nil {
 			return nil, errors.Trace(err)
 		}
@@ -39,6 +39,8 @@ type RangeInfo struct {
 	IndexID int64 `json:"index-id"`
 
 	ProgressID string `json:"progress-id"`
+	// Chunking is the stats of the chunks of the table split by adaptive-chunking, nil if they are not.
+	Chunking *ChunkingStats `json:"-"`
 }
 
 // GetTableIndex return the index of table diffs.
@@ -62,6 +64,7 @@ func (r *RangeInfo) Copy() *RangeInfo {
 		ChunkRange: r.ChunkRange.Clone(),
 		IndexID:    r.IndexID,
 		ProgressID: r.ProgressID,
+		Chunking:   r.Chunking,
 	}
 }
 
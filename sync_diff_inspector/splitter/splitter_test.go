@@ -15,17 +15,24 @@ package splitter
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"math"
+	"math/rand"
 	"sort"
 	"strconv"
 	"testing"
 
 	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
 	"github.com/pingcap/tidb-tools/pkg/dbutil"
 	"github.com/pingcap/tidb-tools/sync_diff_inspector/chunk"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/progress"
 	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
 	"github.com/pingcap/tidb-tools/sync_diff_inspector/utils"
+	"github.com/pingcap/tidb/errno"
 	"github.com/pingcap/tidb/parser"
+	"github.com/pingcap/tidb/parser/model"
 	"github.com/stretchr/testify/require"
 )
 
@@ -124,7 +131,7 @@ func TestSplitRangeByRandom(t *testing.T) {
 		require.NoError(t, err)
 		createFakeResultForRandomSplit(mock, 0, testCase.randomValues)
 
-		chunks, err := splitRangeByRandom(db, testCase.originChunk, testCase.splitCount, "test", "test", splitCols, "", "")
+		chunks, err := splitRangeByRandom(db, testCase.originChunk, testCase.splitCount, "test", "test", "", splitCols, "", "")
 		require.NoError(t, err)
 		for j, chunk := range chunks {
 			chunkStr, args := chunk.ToString("")
@@ -217,6 +224,9 @@ func TestRandomSpliter(t *testing.T) {
 			},
 			[]chunkResult{
 				{
+					"`b` IS NULL",
+					nil,
+				}, {
 					"(`b` < ?) OR (`b` = ? AND `c` <= ?)",
 					[]interface{}{"a", "a", "1.1"},
 				}, {
@@ -246,6 +256,9 @@ func TestRandomSpliter(t *testing.T) {
 			},
 			[]chunkResult{
 				{
+					"`b` IS NULL",
+					nil,
+				}, {
 					"(`b` <= ?)",
 					[]interface{}{"a"},
 				}, {
@@ -275,6 +288,9 @@ func TestRandomSpliter(t *testing.T) {
 			},
 			[]chunkResult{
 				{
+					"`a` IS NULL",
+					nil,
+				}, {
 					"(`a` <= ?)",
 					[]interface{}{"1"},
 				}, {
@@ -907,3 +923,426 @@ func TestChunkSize(t *testing.T) {
 	require.NoError(t, err)
 
 }
+
+func TestRandomSplitFields(t *testing.T) {
+	tableInfo, err := dbutil.GetTableInfoBySQL("create table `test`.`t`(`a` int, `b` int, `c` int, primary key(`a`), index `idx_bc`(`b`, `c`))", parser.New())
+	require.NoError(t, err)
+	indexIDs := make(map[string]int64)
+	for _, index := range tableInfo.Indices {
+		indexIDs[index.Name.O] = index.ID
+	}
+	columnNames := func(columns []*model.ColumnInfo) []string {
+		names := make([]string, 0, len(columns))
+		for _, column := range columns {
+			names = append(names, column.Name.O)
+		}
+		return names
+	}
+
+	testCases := []struct {
+		fields  []string
+		columns []string
+		indexID int64
+	}{
+		{nil, []string{"a"}, indexIDs["PRIMARY"]},
+		{[]string{"idx_bc"}, []string{"b", "c"}, indexIDs["idx_bc"]},
+		{[]string{"b", "c"}, []string{"b", "c"}, indexIDs["idx_bc"]},
+		// the leading columns of the index only.
+		{[]string{"b"}, []string{"b"}, 0},
+		{[]string{"c"}, []string{"c"}, 0},
+	}
+	for _, tc := range testCases {
+		columns, indexID, err := getRandomSplitFields(tableInfo, tc.fields)
+		require.NoError(t, err)
+		require.Equal(t, tc.columns, columnNames(columns))
+		require.Equal(t, tc.indexID, indexID)
+	}
+	_, _, err = getRandomSplitFields(tableInfo, []string{"idx_d"})
+	require.Error(t, err)
+
+	// the index is changed since the checkpoint.
+	table := &common.TableDiff{Schema: "test", Table: "t", Info: tableInfo, Fields: "a"}
+	startRange := &RangeInfo{ChunkRange: chunk.NewChunkRange(), IndexID: indexIDs["idx_bc"]}
+	_, err = NewRandomIteratorWithCheckpoint(context.Background(), "", table, nil, startRange)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "is changed since the checkpoint")
+}
+
+func TestPartitionSpliter(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	tableInfo, err := dbutil.GetTableInfoBySQL("create table `test`.`test`(`a` int, `b` int, primary key(`a`)) partition by range (`a`) (partition p0 values less than (10), partition p1 values less than (maxvalue))", parser.New())
+	require.NoError(t, err)
+	table := &common.TableDiff{Schema: "test", Table: "test", Info: tableInfo, ChunkSize: 2, Range: "TRUE"}
+
+	expectPartitions := func() {
+		mock.ExpectQuery("SELECT PARTITION_NAME FROM information_schema.PARTITIONS").WithArgs("test", "test").WillReturnRows(sqlmock.NewRows([]string{"PARTITION_NAME"}).AddRow("p0").AddRow("p1"))
+	}
+	expectPartitions()
+	mock.ExpectQuery("SELECT COUNT\\(1\\) cnt FROM `test`.`test` PARTITION \\(`p0`\\) WHERE TRUE").WillReturnRows(sqlmock.NewRows([]string{"cnt"}).AddRow(4))
+	mock.ExpectQuery("SELECT `a` FROM \\(SELECT `a`, rand\\(\\) rand_value FROM `test`.`test` PARTITION \\(`p0`\\)").WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow("5"))
+	mock.ExpectQuery("SELECT COUNT\\(1\\) cnt FROM `test`.`test` PARTITION \\(`p1`\\) WHERE TRUE").WillReturnRows(sqlmock.NewRows([]string{"cnt"}).AddRow(2))
+
+	iter, err := NewPartitionIterator(ctx, "", table, db)
+	require.NoError(t, err)
+	chunks := make([]*chunk.Range, 0)
+	for {
+		c, err := iter.Next()
+		require.NoError(t, err)
+		if c == nil {
+			break
+		}
+		chunks = append(chunks, c)
+	}
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, chunks, 3)
+	require.Equal(t, "(((`a` <= ?)) AND (TRUE))", chunks[0].Where)
+	require.Equal(t, "(((`a` > ?)) AND (TRUE))", chunks[1].Where)
+	require.Equal(t, "((TRUE) AND (TRUE))", chunks[2].Where)
+	require.Equal(t, []string{"p0", "p0", "p1"}, []string{chunks[0].Partition, chunks[1].Partition, chunks[2].Partition})
+	require.Equal(t, &chunk.ChunkID{BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 1, ChunkCnt: 2}, chunks[1].Index)
+	require.Equal(t, &chunk.ChunkID{BucketIndexLeft: 1, BucketIndexRight: 1, ChunkIndex: 0, ChunkCnt: 1}, chunks[2].Index)
+	require.True(t, chunks[0].IsFirstChunkForTable())
+	require.False(t, chunks[1].IsLastChunkForTable())
+	require.True(t, chunks[2].IsLastChunkForTable())
+
+	// resume from the last chunk of the partition p0, and the transient error is retried.
+	retries := progress.GetState().QueryRetries
+	mock.ExpectQuery("SELECT PARTITION_NAME FROM information_schema.PARTITIONS").WillReturnError(&mysql.MySQLError{Number: errno.ErrLockWaitTimeout, Message: "Lock wait timeout exceeded; try restarting transaction"})
+	expectPartitions()
+	mock.ExpectQuery("SELECT COUNT\\(1\\) cnt FROM `test`.`test` PARTITION \\(`p1`\\)").WillReturnRows(sqlmock.NewRows([]string{"cnt"}).AddRow(2))
+	iter, err = NewPartitionIteratorWithCheckpoint(ctx, "", table, db, &RangeInfo{ChunkRange: chunks[1]})
+	require.NoError(t, err)
+	c, err := iter.Next()
+	require.NoError(t, err)
+	require.Equal(t, "p1", c.Partition)
+	c, err = iter.Next()
+	require.NoError(t, err)
+	require.Nil(t, c)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Equal(t, retries+1, progress.GetState().QueryRetries)
+
+	// the partitions are changed since the checkpoint.
+	mock.ExpectQuery("SELECT PARTITION_NAME FROM information_schema.PARTITIONS").WillReturnRows(sqlmock.NewRows([]string{"PARTITION_NAME"}).AddRow("p1"))
+	_, err = NewPartitionIteratorWithCheckpoint(ctx, "", table, db, &RangeInfo{ChunkRange: chunks[0]})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "the partitions of table `test`.`test` are changed since the checkpoint")
+
+	// the table is not partitioned.
+	mock.ExpectQuery("SELECT PARTITION_NAME FROM information_schema.PARTITIONS").WillReturnRows(sqlmock.NewRows([]string{"PARTITION_NAME"}))
+	_, err = NewPartitionIterator(ctx, "", table, db)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "is not partitioned")
+}
+
+func TestAdaptiveChunking(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	tableInfo, err := dbutil.GetTableInfoBySQL("create table `test`.`test`(`a` int, `b` int, primary key(`a`))", parser.New())
+	require.NoError(t, err)
+	table := &common.TableDiff{Schema: "test", Table: "test", Info: tableInfo, ChunkSize: 10, Range: "TRUE", AdaptiveChunking: true}
+
+	// 30 rows are sampled for 3 chunks, and 12 of them share the value 10.
+	samples := sqlmock.NewRows([]string{"a"})
+	for i := 1; i <= 9; i++ {
+		samples.AddRow(i)
+	}
+	for i := 0; i < 12; i++ {
+		samples.AddRow(10)
+	}
+	for i := 100; i <= 108; i++ {
+		samples.AddRow(i)
+	}
+	createFakeResultForCount(mock, 30)
+	mock.ExpectQuery("SELECT `a` FROM \\(SELECT `a`, rand\\(\\) rand_value FROM `test`.`test` WHERE .* LIMIT 30\\)rand_tmp ORDER BY `a`").WillReturnRows(samples)
+	iter, err := NewRandomIterator(ctx, "", table, db)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, iter.chunks, 3)
+	require.Equal(t, "10", iter.chunks[0].Bounds[0].Upper)
+	require.False(t, iter.chunks[0].Bounds[0].HasLower)
+	require.Equal(t, "10", iter.chunks[1].Bounds[0].Lower)
+	require.Equal(t, "100", iter.chunks[1].Bounds[0].Upper)
+	require.Equal(t, "100", iter.chunks[2].Bounds[0].Lower)
+	require.False(t, iter.chunks[2].Bounds[0].HasUpper)
+	require.Equal(t, &ChunkingStats{Chunks: 3, SkewFactor: 2.1}, iter.GetChunkingStats())
+
+	// fall back to split by random if the rows can't be sampled.
+	createFakeResultForCount(mock, 30)
+	mock.ExpectQuery("ORDER BY rand_value LIMIT 30").WillReturnError(&mysql.MySQLError{Number: errno.ErrTableaccessDenied, Message: "SELECT command denied"})
+	mock.ExpectQuery("ORDER BY rand_value LIMIT 2").WillReturnRows(sqlmock.NewRows([]string{"a"}).AddRow(10).AddRow(20))
+	iter, err = NewRandomIterator(ctx, "", table, db)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.Len(t, iter.chunks, 3)
+	require.Nil(t, iter.GetChunkingStats())
+}
+
+func TestWholeTableIterator(t *testing.T) {
+	tableInfo, err := dbutil.GetTableInfoBySQL("create table `test`.`test`(`a` int, `b` int, index `idx_a`(`a`))", parser.New())
+	require.NoError(t, err)
+	table := &common.TableDiff{Schema: "test", Table: "test", Info: tableInfo, Range: "TRUE"}
+
+	iter := NewWholeTableIteratorWithCheckpoint("", table, nil)
+	c, err := iter.Next()
+	require.NoError(t, err)
+	require.Equal(t, "((TRUE) AND (TRUE))", c.Where)
+	require.True(t, c.IsFirstChunkForTable())
+	require.True(t, c.IsLastChunkForTable())
+	c, err = iter.Next()
+	require.NoError(t, err)
+	require.Nil(t, c)
+
+	// the only chunk is compared before the checkpoint.
+	iter = NewWholeTableIteratorWithCheckpoint("", table, &RangeInfo{ChunkRange: chunk.NewChunkRange()})
+	c, err = iter.Next()
+	require.NoError(t, err)
+	require.Nil(t, c)
+}
+
+// countNullChunkRows returns the number of the rows of the nullable column `a` in each chunk, nil means NULL.
+func countNullChunkRows(t *testing.T, chunks []*chunk.Range, values []*int) []int {
+	counts := make([]int, len(values))
+	for _, c := range chunks {
+		for i, value := range values {
+			if c.IsNullChunk() {
+				if value == nil {
+					counts[i]++
+				}
+				continue
+			}
+			// the comparisons with NULL are never true.
+			if value == nil {
+				continue
+			}
+			matched := true
+			for _, bound := range c.Bounds {
+				require.Equal(t, "a", bound.Column)
+				if bound.HasLower {
+					lower, err := strconv.Atoi(bound.Lower)
+					require.NoError(t, err)
+					matched = matched && *value > lower
+				}
+				if bound.HasUpper {
+					upper, err := strconv.Atoi(bound.Upper)
+					require.NoError(t, err)
+					matched = matched && *value <= upper
+				}
+			}
+			if matched {
+				counts[i]++
+			}
+		}
+	}
+	return counts
+}
+
+func TestNullChunk(t *testing.T) {
+	ctx := context.Background()
+	// 30% of the values of the leading index column are NULL.
+	values := make([]*int, 0, 100)
+	notNullValues := make([]int, 0, 70)
+	for i := 0; i < 100; i++ {
+		if i%10 < 3 {
+			values = append(values, nil)
+			continue
+		}
+		value := i
+		values = append(values, &value)
+		notNullValues = append(notNullValues, value)
+	}
+	collectChunks := func(iter ChunkIterator) []*chunk.Range {
+		chunks := make([]*chunk.Range, 0)
+		for {
+			c, err := iter.Next()
+			require.NoError(t, err)
+			if c == nil {
+				return chunks
+			}
+			chunks = append(chunks, c)
+		}
+	}
+	requireAllRowsCompared := func(chunks []*chunk.Range) {
+		total := 0
+		for _, count := range countNullChunkRows(t, chunks, values) {
+			// each row is compared exactly once.
+			require.Equal(t, 1, count)
+			total += count
+		}
+		require.Equal(t, len(values), total)
+	}
+
+	// the random splitter.
+	tableInfo, err := dbutil.GetTableInfoBySQL("create table `test`.`test`(`a` int, `b` int, index `idx_a`(`a`))", parser.New())
+	require.NoError(t, err)
+	tableDiff := &common.TableDiff{Schema: "test", Table: "test", Info: tableInfo, ChunkSize: 10, Range: "TRUE"}
+	randomValues := make([]interface{}, 0, 9)
+	for i := 1; i < 10; i++ {
+		randomValues = append(randomValues, i*10-1)
+	}
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	createFakeResultForRandomSplit(mock, len(values), [][]interface{}{randomValues})
+	iter, err := NewRandomIterator(ctx, "", tableDiff, db)
+	require.NoError(t, err)
+	chunks := collectChunks(iter)
+	require.Len(t, chunks, 11)
+	require.True(t, chunks[0].IsNullChunk())
+	require.Equal(t, "((`a` IS NULL) AND (TRUE))", chunks[0].Where)
+	require.Equal(t, chunk.NullBucketIndex, chunks[0].Index.BucketIndexLeft)
+	for _, c := range chunks[1:] {
+		require.False(t, c.IsFirstChunkForTable())
+		require.Equal(t, -1, chunks[0].Index.Compare(c.Index))
+	}
+	requireAllRowsCompared(chunks)
+
+	// the chunks are split from the beginning if the checkpoint is the NULL chunk, and the NULL chunk isn't compared again.
+	createFakeResultForRandomSplit(mock, len(values), [][]interface{}{randomValues})
+	iter, err = NewRandomIteratorWithCheckpoint(ctx, "", tableDiff, db, &RangeInfo{ChunkRange: chunks[0]})
+	require.NoError(t, err)
+	resumed := collectChunks(iter)
+	require.Len(t, resumed, 10)
+	require.Equal(t, chunks[1].Where, resumed[0].Where)
+	require.Equal(t, chunks[1].Index, resumed[0].Index)
+	createFakeResultForRandomSplit(mock, 0, [][]interface{}{randomValues[3:]})
+	iter, err = NewRandomIteratorWithCheckpoint(ctx, "", tableDiff, db, &RangeInfo{ChunkRange: chunks[3]})
+	require.NoError(t, err)
+	resumed = collectChunks(iter)
+	require.Len(t, resumed, 7)
+	require.False(t, resumed[0].IsNullChunk())
+	requireAllRowsCompared(append(chunks[:4:4], resumed...))
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// the bucket splitter, the NULL values aren't in the buckets.
+	tableInfo, err = dbutil.GetTableInfoBySQL("create table `test`.`test`(`a` int, `b` int, unique key `uk_a`(`a`))", parser.New())
+	require.NoError(t, err)
+	tableDiff = &common.TableDiff{Schema: "test", Table: "test", Info: tableInfo, ChunkSize: 14, Range: "TRUE"}
+	db, mock, err = sqlmock.New()
+	require.NoError(t, err)
+	statsRows := sqlmock.NewRows([]string{"Db_name", "Table_name", "Column_name", "Is_index", "Bucket_id", "Count", "Repeats", "Lower_Bound", "Upper_Bound"})
+	for i := 0; i < 5; i++ {
+		statsRows.AddRow("test", "test", "uk_a", 1, i, (i+1)*14, 1, strconv.Itoa(notNullValues[i*14]), strconv.Itoa(notNullValues[(i+1)*14-1]))
+	}
+	mock.ExpectQuery("SHOW STATS_BUCKETS").WillReturnRows(statsRows)
+	bucketIter, err := NewBucketIterator(ctx, "", tableDiff, db)
+	require.NoError(t, err)
+	chunks = collectChunks(bucketIter)
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].Index.Compare(chunks[j].Index) < 0 })
+	require.Len(t, chunks, 7)
+	require.True(t, chunks[0].IsNullChunk())
+	require.Equal(t, chunk.Bucket, chunks[0].Type)
+	requireAllRowsCompared(chunks)
+}
+
+func TestRandomSplitUnsignedBigint(t *testing.T) {
+	ctx := context.Background()
+	tableInfo, err := dbutil.GetTableInfoBySQL("create table `test`.`test`(`a` bigint unsigned, `b` int, primary key(`a`))", parser.New())
+	require.NoError(t, err)
+	tableDiff := &common.TableDiff{Schema: "test", Table: "test", Info: tableInfo, ChunkSize: 10, Range: "TRUE"}
+	toValues := func(values []uint64) []interface{} {
+		strs := make([]interface{}, 0, len(values))
+		for _, value := range values {
+			strs = append(strs, strconv.FormatUint(value, 10))
+		}
+		return strs
+	}
+	parseBound := func(bound string) uint64 {
+		value, err := strconv.ParseUint(bound, 10, 64)
+		require.NoError(t, err)
+		return value
+	}
+	collectChunks := func(iter ChunkIterator) []*chunk.Range {
+		chunks := make([]*chunk.Range, 0)
+		for {
+			c, err := iter.Next()
+			require.NoError(t, err)
+			if c == nil {
+				return chunks
+			}
+			chunks = append(chunks, c)
+		}
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for round := 0; round < 100; round++ {
+		// the range [low, high] of the values, which is close to 2^64 in half of the rounds.
+		low, high := r.Uint64(), r.Uint64()
+		if round%2 == 0 {
+			low, high = math.MaxUint64-uint64(r.Intn(1<<20)), math.MaxUint64
+		}
+		if low > high {
+			low, high = high, low
+		}
+		valueSet := make(map[uint64]struct{})
+		for i := 1 + r.Intn(20); i > 0; i-- {
+			offset := r.Uint64()
+			if span := high - low + 1; span != 0 {
+				offset %= span
+			}
+			valueSet[low+offset] = struct{}{}
+		}
+		values := make([]uint64, 0, len(valueSet))
+		for value := range valueSet {
+			values = append(values, value)
+		}
+		sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		createFakeResultForRandomSplit(mock, (len(values)+1)*10, [][]interface{}{toValues(values)})
+		iter, err := NewRandomIterator(ctx, "", tableDiff, db)
+		require.NoError(t, err)
+		chunks := collectChunks(iter)
+		require.Len(t, chunks, len(values)+1)
+
+		// the chunks are contiguous and the bounds are kept exactly.
+		require.False(t, chunks[0].Bounds[0].HasLower)
+		require.False(t, chunks[len(chunks)-1].Bounds[0].HasUpper)
+		for i := 1; i < len(chunks); i++ {
+			require.True(t, chunks[i].Bounds[0].HasLower)
+			require.True(t, chunks[i-1].Bounds[0].HasUpper)
+			require.Equal(t, chunks[i-1].Bounds[0].Upper, chunks[i].Bounds[0].Lower)
+			require.Equal(t, values[i-1], parseBound(chunks[i].Bounds[0].Lower))
+			require.Equal(t, []interface{}{chunks[i].Bounds[0].Lower}, chunks[i].Args[:1])
+		}
+
+		// the chunks don't overlap, and cover [low, high].
+		probes := []uint64{0, low, high, math.MaxUint64}
+		for _, value := range values {
+			probes = append(probes, value, value-1, value+1)
+		}
+		for _, probe := range probes {
+			matched := 0
+			for _, c := range chunks {
+				bound := c.Bounds[0]
+				if (!bound.HasLower || probe > parseBound(bound.Lower)) && (!bound.HasUpper || probe <= parseBound(bound.Upper)) {
+					matched++
+				}
+			}
+			require.Equal(t, 1, matched, "value %d", probe)
+		}
+
+		// the bounds are kept exactly in the checkpoint, and the chunks are resumed right after it.
+		k := r.Intn(len(values))
+		data, err := json.Marshal(&RangeInfo{ChunkRange: chunks[k]})
+		require.NoError(t, err)
+		var node RangeInfo
+		require.NoError(t, json.Unmarshal(data, &node))
+		require.Equal(t, chunks[k].Bounds, node.ChunkRange.Bounds)
+		if len(values)-k >= 2 {
+			createFakeResultForRandomSplit(mock, 0, [][]interface{}{toValues(values[k+1:])})
+		}
+		iter, err = NewRandomIteratorWithCheckpoint(ctx, "", tableDiff, db, &node)
+		require.NoError(t, err)
+		resumed := collectChunks(iter)
+		require.Len(t, resumed, len(chunks)-k-1)
+		for i, c := range resumed {
+			require.Equal(t, chunks[k+1+i].Bounds, c.Bounds)
+		}
+		require.NoError(t, mock.ExpectationsWereMet())
+	}
+}
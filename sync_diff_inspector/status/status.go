@@ -0,0 +1,262 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/progress"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/report"
+	"go.uber.org/zap"
+)
+
+// The states of the data check of a table.
+const (
+	TablePending = "pending"
+	TableRunning = "running"
+	TableDone    = "done"
+)
+
+// Comparison is the running comparison whose status is served, e.g. `diff.Diff`.
+type Comparison interface {
+	Report() *report.Report
+	Pause()
+	Resume()
+	IsPaused() bool
+}
+
+// Status is the response of `GET /status`.
+type Status struct {
+	Progress *Progress     `json:"progress"`
+	Tables   []TableStatus `json:"tables"`
+	// Result is the current result, which is pass until any table fails.
+	Result string `json:"result"`
+	Paused bool   `json:"paused"`
+	// ConfigDigest is the hash of the config to check the checkpoint, see `config.TaskConfig.ComputeConfigHash`.
+	ConfigDigest string `json:"config-digest"`
+}
+
+// Progress is the overall progress of the comparison.
+type Progress struct {
+	TotalChunks     int     `json:"total-chunks"`
+	CompletedChunks int     `json:"completed-chunks"`
+	ChunksPerSecond float64 `json:"chunks-per-second"`
+	// ETA is like "1h2m3s", which is empty if it's unknown.
+	ETA           string `json:"eta,omitempty"`
+	StructChecked int    `json:"struct-checked"`
+	StructTotal   int    `json:"struct-total"`
+	// QueryRetries is the number of the failed queries retried.
+	QueryRetries int64 `json:"query-retries"`
+}
+
+// TableStatus is the state of a table, the rows to add and delete are summed over the chunks compared so far.
+type TableStatus struct {
+	Table           string `json:"table"`
+	State           string `json:"state"`
+	CompletedChunks int    `json:"completed-chunks"`
+	TotalChunks     int    `json:"total-chunks"`
+	RowsAdd         int    `json:"rows-add"`
+	RowsDelete      int    `json:"rows-delete"`
+	StructEqual     bool   `json:"struct-equal"`
+	DataEqual       bool   `json:"data-equal"`
+}
+
+// Server serves the status of a running comparison over HTTP:
+//
+//	GET  /status  returns the Status.
+//	GET  /report  returns the current report like `report.json`.
+//	POST /pause   pauses the comparison.
+//	POST /resume  resumes the comparison.
+//
+// The requests need the header `Authorization: Bearer <token>` if the token is set.
+type Server struct {
+	comparison   Comparison
+	configDigest string
+	token        string
+
+	// the progress is updated by the progress callback of the comparison, so the progress printer is
+	// only accessed by the comparison.
+	mu       sync.RWMutex
+	progress progress.State
+
+	httpServer *http.Server
+}
+
+// NewServer returns a Server of the comparison, SetProgress should be registered as its progress callback.
+func NewServer(comparison Comparison, configDigest string, token string) *Server {
+	s := &Server{
+		comparison:   comparison,
+		configDigest: configDigest,
+		token:        token,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", s.handle(http.MethodGet, s.handleStatus))
+	mux.HandleFunc("/report", s.handle(http.MethodGet, s.handleReport))
+	mux.HandleFunc("/pause", s.handle(http.MethodPost, s.handlePause))
+	mux.HandleFunc("/resume", s.handle(http.MethodPost, s.handleResume))
+	s.httpServer = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	return s
+}
+
+// SetProgress updates the progress served.
+func (s *Server) SetProgress(state progress.State) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.progress = state
+}
+
+// Handler returns the handler of the API.
+func (s *Server) Handler() http.Handler {
+	return s.httpServer.Handler
+}
+
+// Start listens on addr and serves the API in the background, the listening error is returned.
+func (s *Server) Start(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	log.Info("serve the status of the comparison", zap.String("address", listener.Addr().String()))
+	go func() {
+		if err := s.httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Warn("the status server exits", zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+// Close stops the server, the requests being served are completed in 5 seconds.
+func (s *Server) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.httpServer.Shutdown(ctx); err != nil {
+		log.Warn("fail to close the status server", zap.Error(err))
+	}
+}
+
+// handle checks the method and the token of the request before calling the handler.
+func (s *Server) handle(method string, handler func(w http.ResponseWriter)) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if len(s.token) > 0 {
+			token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+				http.Error(w, "invalid token", http.StatusUnauthorized)
+				return
+			}
+		}
+		if req.Method != method {
+			w.Header().Set("Allow", method)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handler(w)
+	}
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter) {
+	writeJSON(w, s.getStatus())
+}
+
+func (s *Server) handleReport(w http.ResponseWriter) {
+	// the report is copied under the read lock, and marshaled without holding it.
+	writeJSON(w, s.comparison.Report().Snapshot())
+}
+
+func (s *Server) handlePause(w http.ResponseWriter) {
+	s.comparison.Pause()
+	writeJSON(w, map[string]bool{"paused": true})
+}
+
+func (s *Server) handleResume(w http.ResponseWriter) {
+	s.comparison.Resume()
+	writeJSON(w, map[string]bool{"paused": false})
+}
+
+func (s *Server) getStatus() *Status {
+	s.mu.RLock()
+	state := s.progress
+	s.mu.RUnlock()
+	r := s.comparison.Report()
+	status := &Status{
+		Progress: &Progress{
+			TotalChunks:     state.TotalChunks,
+			CompletedChunks: state.CompletedChunks,
+			ChunksPerSecond: state.ChunksPerSecond,
+			StructChecked:   state.StructChecked,
+			StructTotal:     state.StructTotal,
+			QueryRetries:    state.QueryRetries,
+		},
+		Tables:       make([]TableStatus, 0),
+		Result:       r.GetResult(),
+		Paused:       s.comparison.IsPaused(),
+		ConfigDigest: s.configDigest,
+	}
+	if state.ETA > 0 {
+		status.Progress.ETA = state.ETA.String()
+	}
+	// the tables not registered after the structures are checked are completed before resuming from the checkpoint.
+	structChecked := state.StructTotal > 0 && state.StructChecked == state.StructTotal
+	for schema, tableMap := range r.SnapshotResults() {
+		for table, result := range tableMap {
+			name := dbutil.TableName(schema, table)
+			tableStatus := TableStatus{
+				Table:       name,
+				State:       TablePending,
+				StructEqual: result.StructEqual,
+				DataEqual:   result.DataEqual,
+			}
+			for _, chunkResult := range result.ChunkMap {
+				tableStatus.RowsAdd += chunkResult.RowsAdd
+				tableStatus.RowsDelete += chunkResult.RowsDelete
+			}
+			if chunks, ok := state.TableChunks[name]; ok {
+				tableStatus.CompletedChunks = chunks.Completed
+				tableStatus.TotalChunks = chunks.Total
+				if chunks.Done {
+					tableStatus.State = TableDone
+				} else if chunks.Started {
+					tableStatus.State = TableRunning
+				}
+			} else if structChecked {
+				tableStatus.State = TableDone
+			}
+			status.Tables = append(status.Tables, tableStatus)
+		}
+	}
+	sort.Slice(status.Tables, func(i, j int) bool {
+		return status.Tables[i].Table < status.Tables[j].Table
+	})
+	return status
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
@@ -0,0 +1,118 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/chunk"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/progress"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/report"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/source/common"
+	"github.com/stretchr/testify/require"
+)
+
+type mockComparison struct {
+	report *report.Report
+	paused bool
+}
+
+func (c *mockComparison) Report() *report.Report { return c.report }
+
+func (c *mockComparison) Pause() { c.paused = true }
+
+func (c *mockComparison) Resume() { c.paused = false }
+
+func (c *mockComparison) IsPaused() bool { return c.paused }
+
+func request(t *testing.T, s *Server, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if len(token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+	return w
+}
+
+func TestServer(t *testing.T) {
+	r := report.NewReport(&config.TaskConfig{OutputDir: t.TempDir()})
+	r.Init([]*common.TableDiff{{Schema: "test", Table: "t1"}, {Schema: "test", Table: "t2"}, {Schema: "test", Table: "t3"}}, nil, nil)
+	r.SetTableStructCheckResult("test", "t1", true, false)
+	r.SetTableDataCheckResult("test", "t1", false, 2, 1, &chunk.ChunkID{TableIndex: 0, BucketIndexLeft: 0, BucketIndexRight: 0, ChunkIndex: 0, ChunkCnt: 2})
+	comparison := &mockComparison{report: r}
+	s := NewServer(comparison, "digest", "secret")
+	s.SetProgress(progress.State{
+		TotalChunks:     5,
+		CompletedChunks: 3,
+		StructChecked:   3,
+		StructTotal:     3,
+		TableChunks: map[string]progress.TableChunks{
+			"`test`.`t1`": {Completed: 1, Total: 2, Started: true},
+			"`test`.`t2`": {Completed: 2, Total: 2, Started: true, Done: true},
+			"`test`.`t3`": {Total: 1},
+		},
+	})
+
+	// the token is required.
+	require.Equal(t, http.StatusUnauthorized, request(t, s, http.MethodGet, "/status", "").Code)
+	require.Equal(t, http.StatusUnauthorized, request(t, s, http.MethodGet, "/status", "wrong").Code)
+	require.Equal(t, http.StatusMethodNotAllowed, request(t, s, http.MethodGet, "/pause", "secret").Code)
+
+	w := request(t, s, http.MethodGet, "/status", "secret")
+	require.Equal(t, http.StatusOK, w.Code)
+	status := new(Status)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), status))
+	require.Equal(t, &Progress{TotalChunks: 5, CompletedChunks: 3, StructChecked: 3, StructTotal: 3}, status.Progress)
+	require.Equal(t, []TableStatus{
+		{Table: "`test`.`t1`", State: TableRunning, CompletedChunks: 1, TotalChunks: 2, RowsAdd: 2, RowsDelete: 1, StructEqual: true},
+		{Table: "`test`.`t2`", State: TableDone, CompletedChunks: 2, TotalChunks: 2, StructEqual: true, DataEqual: true},
+		{Table: "`test`.`t3`", State: TablePending, TotalChunks: 1, StructEqual: true, DataEqual: true},
+	}, status.Tables)
+	require.Equal(t, report.Fail, status.Result)
+	require.Equal(t, "digest", status.ConfigDigest)
+	require.False(t, status.Paused)
+
+	w = request(t, s, http.MethodGet, "/report", "secret")
+	require.Equal(t, http.StatusOK, w.Code)
+	snapshot := new(report.Report)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), snapshot))
+	require.Len(t, snapshot.TableResults["test"], 3)
+	require.Len(t, snapshot.TableResults["test"]["t1"].ChunkMap, 1)
+
+	require.Equal(t, http.StatusOK, request(t, s, http.MethodPost, "/pause", "secret").Code)
+	require.True(t, comparison.paused)
+	require.Equal(t, http.StatusOK, request(t, s, http.MethodPost, "/resume", "secret").Code)
+	require.False(t, comparison.paused)
+}
+
+func TestServerWithoutToken(t *testing.T) {
+	r := report.NewReport(&config.TaskConfig{OutputDir: t.TempDir()})
+	r.Init([]*common.TableDiff{{Schema: "test", Table: "t"}}, nil, nil)
+	s := NewServer(&mockComparison{report: r}, "digest", "")
+	require.NoError(t, s.Start("127.0.0.1:0"))
+	defer s.Close()
+
+	// the tables are pending before the structures are checked.
+	w := request(t, s, http.MethodGet, "/status", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	status := new(Status)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), status))
+	require.Equal(t, report.Pass, status.Result)
+	require.Equal(t, TablePending, status.Tables[0].State)
+}
@@ -0,0 +1,107 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/coreos/go-semver/semver"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb/parser/model"
+	"go.uber.org/zap"
+)
+
+// TableChecksum is the result of `ADMIN CHECKSUM TABLE`, which is computed from the key-value pairs of the rows
+// and the indices of the table in TiKV.
+type TableChecksum struct {
+	Checksum   uint64 `json:"checksum"`
+	TotalKVs   uint64 `json:"total-kvs"`
+	TotalBytes uint64 `json:"total-bytes"`
+}
+
+// GetAdminChecksum returns the result of `ADMIN CHECKSUM TABLE` of the table, which is only supported by TiDB.
+// The checksum is taken at the snapshot of the connection, e.g. `tidb_snapshot`.
+func GetAdminChecksum(ctx context.Context, db dbutil.QueryExecutor, schema, table string) (*TableChecksum, error) {
+	/*
+		mysql> ADMIN CHECKSUM TABLE `test`.`t`;
+		+---------+------------+----------------------+-----------+-------------+
+		| Db_name | Table_name | Checksum_crc64_xor   | Total_kvs | Total_bytes |
+		+---------+------------+----------------------+-----------+-------------+
+		| test    | t          | 10315712442567349224 |     20000 |      586781 |
+		+---------+------------+----------------------+-----------+-------------+
+	*/
+	query := fmt.Sprintf("ADMIN CHECKSUM TABLE %s", dbutil.TableName(schema, table))
+	log.Debug("admin checksum", zap.String("sql", query))
+	var dbName, tableName string
+	checksum := new(TableChecksum)
+	if err := db.QueryRowContext(ctx, query).Scan(&dbName, &tableName, &checksum.Checksum, &checksum.TotalKVs, &checksum.TotalBytes); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return checksum, nil
+}
+
+// GetTiDBReleaseVersion returns the release version of TiDB from the result of `SELECT VERSION()`,
+// e.g. "6.5.0" of "5.7.25-TiDB-v6.5.0", and false if the server is not TiDB or the version can't be parsed.
+func GetTiDBReleaseVersion(version string) (*semver.Version, bool) {
+	if !strings.Contains(strings.ToLower(version), "tidb") {
+		return nil, false
+	}
+	versionStr := tidbVersionRegex.FindString(version)
+	if len(versionStr) == 0 {
+		return nil, false
+	}
+	releaseVersion, err := semver.NewVersion(strings.TrimPrefix(versionStr[1:], "v"))
+	if err != nil {
+		return nil, false
+	}
+	return releaseVersion, true
+}
+
+// IsAdminChecksumCompatible returns whether the results of `ADMIN CHECKSUM TABLE` of the sources and the target
+// are comparable by the versions, i.e. they are all TiDB of the same major and minor versions, which encode the
+// key-value pairs in the same way.
+func IsAdminChecksumCompatible(sourceVersions []string, targetVersion string) bool {
+	target, ok := GetTiDBReleaseVersion(targetVersion)
+	if !ok {
+		return false
+	}
+	for _, sourceVersion := range sourceVersions {
+		source, ok := GetTiDBReleaseVersion(sourceVersion)
+		if !ok || source.Major != target.Major || source.Minor != target.Minor {
+			return false
+		}
+	}
+	return true
+}
+
+// HasSameColumnSet returns whether the source tables have the same columns as the target table by name regardless
+// of the orders. The checksums of the key-value pairs are only comparable if the column sets are the same, e.g. the
+// rows are not rewritten when a column is added, so the same key-value pairs may have different columns.
+func HasSameColumnSet(upstreamTableInfos []*model.TableInfo, downstreamTableInfo *model.TableInfo) bool {
+	for _, upstreamTableInfo := range upstreamTableInfos {
+		if len(upstreamTableInfo.Columns) != len(downstreamTableInfo.Columns) {
+			return false
+		}
+		for _, column := range upstreamTableInfo.Columns {
+			if dbutil.FindColumnByName(downstreamTableInfo.Columns, column.Name.O) == nil {
+				return false
+			}
+		}
+	}
+	return true
+}
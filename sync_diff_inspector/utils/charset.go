@@ -0,0 +1,164 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb/parser/model"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+const (
+	charsetUTF8MB4 = "utf8mb4"
+	charsetUTF8    = "utf8"
+	charsetUTF8MB3 = "utf8mb3"
+	charsetASCII   = "ascii"
+)
+
+// charsetEncodings are the encodings of the MySQL charsets besides the Unicode ones and ascii.
+var charsetEncodings = map[string]encoding.Encoding{
+	// latin1 of MySQL is actually cp1252.
+	"latin1":  charmap.Windows1252,
+	"latin2":  charmap.ISO8859_2,
+	"greek":   charmap.ISO8859_7,
+	"hebrew":  charmap.ISO8859_8,
+	"cp1250":  charmap.Windows1250,
+	"cp1251":  charmap.Windows1251,
+	"cp1256":  charmap.Windows1256,
+	"cp1257":  charmap.Windows1257,
+	"cp866":   charmap.CodePage866,
+	"koi8r":   charmap.KOI8R,
+	"koi8u":   charmap.KOI8U,
+	"gbk":     simplifiedchinese.GBK,
+	"gb18030": simplifiedchinese.GB18030,
+	"big5":    traditionalchinese.Big5,
+	"sjis":    japanese.ShiftJIS,
+	"ujis":    japanese.EUCJP,
+	"euckr":   korean.EUCKR,
+}
+
+// IsSupportedCharset returns whether the values can be transcoded to the MySQL charset by `TranscodeValue`.
+func IsSupportedCharset(charset string) bool {
+	switch strings.ToLower(charset) {
+	case charsetUTF8MB4, charsetUTF8, charsetUTF8MB3, charsetASCII:
+		return true
+	}
+	_, ok := charsetEncodings[strings.ToLower(charset)]
+	return ok
+}
+
+// TranscodeValue transcodes the UTF-8 value to the MySQL charset like `CONVERT(value USING charset)`, and returns
+// the transcoded value in UTF-8, whose characters which can't be mapped to the charset, including the invalid UTF-8
+// bytes, are replaced by "?", and the distinct ones of them in order. The value is returned as is if all the
+// characters are mapped.
+func TranscodeValue(value []byte, charset string) ([]byte, []string) {
+	charset = strings.ToLower(charset)
+	var encoder *encoding.Encoder
+	if enc, ok := charsetEncodings[charset]; ok {
+		encoder = enc.NewEncoder()
+	}
+	isMapped := func(r rune, size int) bool {
+		if r == utf8.RuneError && size <= 1 {
+			return false
+		}
+		switch charset {
+		case charsetUTF8MB4:
+			return true
+		case charsetUTF8, charsetUTF8MB3:
+			// utf8 of MySQL only has the characters in the BMP.
+			return r <= 0xFFFF
+		case charsetASCII:
+			return r < utf8.RuneSelf
+		}
+		if encoder == nil {
+			return true
+		}
+		_, err := encoder.String(string(r))
+		return err == nil
+	}
+
+	var transcoded []byte
+	var unmappable []string
+	seen := make(map[string]struct{})
+	for i := 0; i < len(value); {
+		r, size := utf8.DecodeRune(value[i:])
+		if !isMapped(r, size) {
+			if transcoded == nil {
+				transcoded = append(make([]byte, 0, len(value)), value[:i]...)
+			}
+			transcoded = append(transcoded, '?')
+			char := string(value[i : i+size])
+			if _, ok := seen[char]; !ok {
+				seen[char] = struct{}{}
+				unmappable = append(unmappable, char)
+			}
+		} else if transcoded != nil {
+			transcoded = append(transcoded, value[i:i+size]...)
+		}
+		i += size
+	}
+	if transcoded == nil {
+		return value, nil
+	}
+	return transcoded, unmappable
+}
+
+// columnCharset returns the charset of the column, which is the default charset of the table if not specified.
+func columnCharset(tableInfo *model.TableInfo, col *model.ColumnInfo) string {
+	if len(col.Charset) > 0 {
+		return strings.ToLower(col.Charset)
+	}
+	return strings.ToLower(tableInfo.Charset)
+}
+
+// TranscodedColumn is a column whose source values are transcoded from the charset From of the sources to the
+// charset To of the target.
+type TranscodedColumn struct {
+	Column string
+	From   string
+	To     string
+}
+
+// GetTranscodedColumns returns the columns of the target table whose source values are transcoded by charsetMap in
+// order, i.e. the charset of the column on the sources is mapped to the different charset of the one on the target.
+// The columns are matched by name.
+func GetTranscodedColumns(upstreamTableInfos []*model.TableInfo, downstreamTableInfo *model.TableInfo, charsetMap map[string]string) []*TranscodedColumn {
+	transcoded := make([]*TranscodedColumn, 0)
+	for _, downstreamColumn := range downstreamTableInfo.Columns {
+		targetCharset := columnCharset(downstreamTableInfo, downstreamColumn)
+		if len(targetCharset) == 0 {
+			continue
+		}
+		for _, upstreamTableInfo := range upstreamTableInfos {
+			upstreamColumn := dbutil.FindColumnByName(upstreamTableInfo.Columns, downstreamColumn.Name.O)
+			if upstreamColumn == nil {
+				continue
+			}
+			sourceCharset := columnCharset(upstreamTableInfo, upstreamColumn)
+			if sourceCharset != targetCharset && strings.EqualFold(charsetMap[sourceCharset], targetCharset) {
+				transcoded = append(transcoded, &TranscodedColumn{Column: downstreamColumn.Name.O, From: sourceCharset, To: targetCharset})
+				break
+			}
+		}
+	}
+	return transcoded
+}
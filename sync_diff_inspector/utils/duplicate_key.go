@@ -0,0 +1,84 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb/parser/model"
+	"go.uber.org/zap"
+)
+
+// DuplicateKey is the value of the unique key shared by more than one row.
+type DuplicateKey struct {
+	// Values are the values of the key columns in order.
+	Values []string `json:"values"`
+	// Rows is the number of the rows sharing the value.
+	Rows int64 `json:"rows"`
+}
+
+// GetDuplicateKeysSQL returns the sql to find the values of the key columns shared by more than one row in the range
+// of `where`, the rows whose key has NULL are excluded because they don't violate the unique key.
+func GetDuplicateKeysSQL(schema, table string, keys []*model.ColumnInfo, where string, limit int) string {
+	names := make([]string, 0, len(keys))
+	conditions := make([]string, 0, len(keys)+1)
+	for _, key := range keys {
+		name := dbutil.ColumnName(key.Name.O)
+		names = append(names, name)
+		conditions = append(conditions, fmt.Sprintf("%s IS NOT NULL", name))
+	}
+	if len(where) > 0 {
+		conditions = append(conditions, fmt.Sprintf("(%s)", where))
+	}
+	columns := strings.Join(names, ", ")
+	return fmt.Sprintf("SELECT %s, COUNT(*) AS cnt FROM %s WHERE %s GROUP BY %s HAVING COUNT(*) > 1 LIMIT %d",
+		columns, dbutil.TableName(schema, table), strings.Join(conditions, " AND "), columns, limit)
+}
+
+// GetDuplicateKeys returns at most `limit` values of the key columns shared by more than one row in the range of
+// `where`, which means the unique key isn't actually unique, e.g. the data is imported with the checks disabled.
+func GetDuplicateKeys(ctx context.Context, db dbutil.QueryExecutor, schema, table string, keys []*model.ColumnInfo, where string, args []interface{}, limit int) ([]*DuplicateKey, error) {
+	query := GetDuplicateKeysSQL(schema, table, keys, where, limit)
+	log.Debug("get duplicate keys", zap.String("sql", query), zap.Reflect("args", args))
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer rows.Close()
+
+	duplicateKeys := make([]*DuplicateKey, 0)
+	for rows.Next() {
+		values := make([]sql.NullString, len(keys))
+		dest := make([]interface{}, 0, len(keys)+1)
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		duplicateKey := &DuplicateKey{Values: make([]string, 0, len(keys))}
+		dest = append(dest, &duplicateKey.Rows)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, errors.Trace(err)
+		}
+		for _, value := range values {
+			duplicateKey.Values = append(duplicateKey.Values, value.String)
+		}
+		duplicateKeys = append(duplicateKeys, duplicateKey)
+	}
+	return duplicateKeys, errors.Trace(rows.Err())
+}
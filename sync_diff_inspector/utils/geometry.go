@@ -0,0 +1,163 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb/parser"
+	"github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tidb/parser/types"
+)
+
+var (
+	// geometryColumnRegexp matches the definition of a GEOMETRY column in a line of `SHOW CREATE TABLE`,
+	// e.g. "  `g` point NOT NULL /*!80003 SRID 4326 */,".
+	geometryColumnRegexp = regexp.MustCompile("(?i)^(\\s*`((?:[^`]|``)+)`\\s+)(geometrycollection|geomcollection|multilinestring|multipolygon|multipoint|linestring|polygon|geometry|point)\\b(.*)$")
+	sridRegexp           = regexp.MustCompile(`(?i)\s*(/\*!\d+\s+)?SRID\s+\d+(\s*\*/)?`)
+	spatialIndexRegexp   = regexp.MustCompile(`(?i)^\s*SPATIAL\s+(KEY|INDEX)\s`)
+)
+
+// GetTableInfo returns the table info like `dbutil.GetTableInfo`, and the GEOMETRY columns are parsed,
+// see `GetTableInfoBySQL`. The table info of a view is built by its columns, see `GetCreateTableSQL`.
+func GetTableInfo(ctx context.Context, db dbutil.QueryExecutor, schemaName string, tableName string) (*model.TableInfo, error) {
+	createTableSQL, err := GetCreateTableSQL(ctx, db, schemaName, tableName)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	p, err := dbutil.GetParserForDB(ctx, db)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return GetTableInfoBySQL(createTableSQL, p)
+}
+
+// GetTableInfoBySQL returns the table info like `dbutil.GetTableInfoBySQL`. The parser doesn't support the GEOMETRY
+// columns, so they are parsed as LONGBLOB then their types are set to `mysql.TypeGeometry`, and the SRID attributes
+// and the SPATIAL indexes are dropped. The sub types like POINT and POLYGON are all GEOMETRY in the table info.
+// The syntax only in MariaDB is normalized too, see `normalizeMariaDBTable`.
+// The columns are rewritten line by line, which is the format of `SHOW CREATE TABLE`.
+func GetTableInfoBySQL(createTableSQL string, p *parser.Parser) (*model.TableInfo, error) {
+	rewritten, geometryColumns := rewriteGeometryColumns(normalizeMariaDBTable(createTableSQL))
+	tableInfo, err := dbutil.GetTableInfoBySQL(rewritten, p)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if len(geometryColumns) == 0 {
+		return tableInfo, nil
+	}
+	for _, col := range tableInfo.Columns {
+		if _, ok := geometryColumns[col.Name.L]; ok {
+			col.Tp = mysql.TypeGeometry
+			col.Flen = types.UnspecifiedLength
+			col.Decimal = types.UnspecifiedLength
+		}
+	}
+	return tableInfo, nil
+}
+
+// rewriteGeometryColumns rewrites the GEOMETRY columns to LONGBLOB columns and drops the SPATIAL indexes,
+// the lower case names of the GEOMETRY columns are returned.
+func rewriteGeometryColumns(createTableSQL string) (string, map[string]struct{}) {
+	lines := strings.Split(createTableSQL, "\n")
+	rewritten := make([]string, 0, len(lines))
+	var geometryColumns map[string]struct{}
+	for _, line := range lines {
+		if spatialIndexRegexp.MatchString(line) {
+			// the comma of the previous line is dropped if the index is the last definition.
+			if !strings.HasSuffix(strings.TrimSpace(line), ",") && len(rewritten) > 0 {
+				rewritten[len(rewritten)-1] = strings.TrimSuffix(rewritten[len(rewritten)-1], ",")
+			}
+			continue
+		}
+		if match := geometryColumnRegexp.FindStringSubmatch(line); match != nil {
+			if geometryColumns == nil {
+				geometryColumns = make(map[string]struct{})
+			}
+			geometryColumns[strings.ToLower(strings.ReplaceAll(match[2], "``", "`"))] = struct{}{}
+			line = match[1] + "longblob" + sridRegexp.ReplaceAllString(match[4], "")
+		}
+		rewritten = append(rewritten, line)
+	}
+	return strings.Join(rewritten, "\n"), geometryColumns
+}
+
+// GetGeometryColumns returns the names of the GEOMETRY columns of the table.
+func GetGeometryColumns(tableInfo *model.TableInfo) []string {
+	columns := make([]string, 0)
+	for _, col := range tableInfo.Columns {
+		if col.Tp == mysql.TypeGeometry {
+			columns = append(columns, col.Name.O)
+		}
+	}
+	return columns
+}
+
+// geometryExpr returns the expression of the GEOMETRY value `name` like "4326:<WKB>", see
+// `config.ColumnTransformGeometryWKB`. The WKB doesn't contain the SRID, so it's prefixed.
+func geometryExpr(name string) string {
+	return fmt.Sprintf("CONCAT(ST_SRID(%s), ':', ST_AsBinary(%s))", name, name)
+}
+
+// parseGeometryValue splits the value of `geometryExpr` into the SRID and the WKB.
+func parseGeometryValue(data []byte) (srid string, wkb []byte, ok bool) {
+	i := bytes.IndexByte(data, ':')
+	if i <= 0 {
+		return "", nil, false
+	}
+	for _, c := range data[:i] {
+		if c < '0' || c > '9' {
+			return "", nil, false
+		}
+	}
+	return string(data[:i]), data[i+1:], true
+}
+
+// geometryValue returns the GEOMETRY value in the sql, e.g. `ST_GeomFromWKB(x'0101000000...', 4326)`.
+// The value not read by `geometryExpr` is returned as the hexadecimal literal.
+func geometryValue(data []byte) string {
+	srid, wkb, ok := parseGeometryValue(data)
+	if !ok {
+		return fmt.Sprintf("x'%x'", data)
+	}
+	return fmt.Sprintf("ST_GeomFromWKB(x'%x', %s)", wkb, srid)
+}
+
+// GetSRIDMismatchColumns returns the GEOMETRY columns whose values of the 2 rows have the same WKB but
+// the different SRIDs.
+func GetSRIDMismatchColumns(source, target map[string]*dbutil.ColumnData, table *model.TableInfo) []string {
+	var columns []string
+	for _, col := range table.Columns {
+		if col.Tp != mysql.TypeGeometry {
+			continue
+		}
+		data1, data2 := source[col.Name.O], target[col.Name.O]
+		if data1 == nil || data2 == nil || data1.IsNull || data2.IsNull {
+			continue
+		}
+		srid1, wkb1, ok1 := parseGeometryValue(data1.Data)
+		srid2, wkb2, ok2 := parseGeometryValue(data2.Data)
+		if ok1 && ok2 && srid1 != srid2 && bytes.Equal(wkb1, wkb2) {
+			columns = append(columns, col.Name.O)
+		}
+	}
+	return columns
+}
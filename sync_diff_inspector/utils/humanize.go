@@ -0,0 +1,51 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"strconv"
+	"strings"
+)
+
+// sizeUnits are the units to humanize the sizes, in the power of 1024.
+var sizeUnits = []string{"B", "KB", "MB", "GB", "TB", "PB"}
+
+// HumanizeBytes formats the size in bytes by the largest unit it reaches with at most one decimal,
+// e.g. "52GB" or "1.5MB".
+func HumanizeBytes(size int64) string {
+	value := float64(size)
+	unit := 0
+	for (value >= 1024 || value <= -1024) && unit < len(sizeUnits)-1 {
+		value /= 1024
+		unit++
+	}
+	return strings.TrimSuffix(strconv.FormatFloat(value, 'f', 1, 64), ".0") + sizeUnits[unit]
+}
+
+// HumanizeCount formats the count with the thousands separators, e.g. "1,234,567".
+func HumanizeCount(n int64) string {
+	s := strconv.FormatInt(n, 10)
+	var b strings.Builder
+	if n < 0 {
+		b.WriteByte('-')
+		s = s[1:]
+	}
+	for i := 0; i < len(s); i++ {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
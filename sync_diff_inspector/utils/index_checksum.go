@@ -0,0 +1,70 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb/parser/model"
+	"go.uber.org/zap"
+)
+
+// FindIndexByName returns the index of the table by the name case-insensitively, nil if it's not found.
+func FindIndexByName(tableInfo *model.TableInfo, name string) *model.IndexInfo {
+	for _, index := range tableInfo.Indices {
+		if strings.EqualFold(index.Name.O, name) {
+			return index
+		}
+	}
+	return nil
+}
+
+// GetIndexCountAndCRC32ChecksumSQL returns the sql to get the checksum code and count of the columns of the index
+// by given condition, which reads the index by `FORCE INDEX`. The index is only read without the table if the
+// columns in the condition are covered by the index, e.g. the chunks are split by the index or the handle.
+func GetIndexCountAndCRC32ChecksumSQL(schemaName, tableName, partition string, tbInfo *model.TableInfo, index *model.IndexInfo, columnTransforms map[string]string, limitRange string) string {
+	columns := make([]*model.ColumnInfo, 0, len(index.Columns))
+	for _, col := range index.Columns {
+		columns = append(columns, tbInfo.Columns[col.Offset])
+	}
+	columnNames, columnIsNull := checksumColumns(columns, columnTransforms)
+	return fmt.Sprintf("SELECT COUNT(*) as CNT, BIT_XOR(CAST(CRC32(CONCAT_WS(',', %s, CONCAT(%s)))AS UNSIGNED)) as CHECKSUM FROM %s FORCE INDEX (%s) WHERE %s;",
+		strings.Join(columnNames, ", "), strings.Join(columnIsNull, ", "), TableNameWithPartition(schemaName, tableName, partition), dbutil.QuoteName(index.Name.O), limitRange)
+}
+
+// GetIndexCountAndCRC32Checksum returns the checksum code and count of the columns of the index by given condition,
+// which is different from the one of `GetCountAndCRC32Checksum` on the same side if the index is inconsistent
+// with the data, see `GetIndexCountAndCRC32ChecksumSQL`.
+func GetIndexCountAndCRC32Checksum(ctx context.Context, db *sql.DB, schemaName, tableName, partition string, tbInfo *model.TableInfo, index *model.IndexInfo, columnTransforms map[string]string, limitRange string, args []interface{}) (int64, int64, error) {
+	query := GetIndexCountAndCRC32ChecksumSQL(schemaName, tableName, partition, tbInfo, index, columnTransforms, limitRange)
+	log.Debug("index count and checksum", zap.String("sql", query), zap.Reflect("args", args))
+
+	var count sql.NullInt64
+	var checksum sql.NullInt64
+	err := db.QueryRowContext(ctx, query, args...).Scan(&count, &checksum)
+	if err != nil {
+		log.Warn("execute index checksum query fail", zap.String("query", query), zap.Reflect("args", args), zap.Error(err))
+		return -1, -1, errors.Trace(err)
+	}
+	if !count.Valid || !checksum.Valid {
+		return 0, 0, nil
+	}
+	return count.Int64, checksum.Int64, nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/coreos/go-semver/semver"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+)
+
+// the flavors of the database servers parsed from the versions.
+const (
+	FlavorMySQL   = "MySQL"
+	FlavorMariaDB = "MariaDB"
+	FlavorTiDB    = "TiDB"
+
+	// mariaDBVersionPrefix is prepended to the version of MariaDB 10 and later for the replication protocol
+	// compatible with MySQL 5.5, e.g. "5.5.5-10.6.12-MariaDB-log".
+	mariaDBVersionPrefix = "5.5.5-"
+)
+
+var (
+	releaseVersionRegex = regexp.MustCompile(`^\d+\.\d+\.\d+`)
+
+	// mariaDBStringColumnRegexp matches the definition of a UUID or INET column of MariaDB in a line of
+	// `SHOW CREATE TABLE`, e.g. "  `id` uuid NOT NULL,".
+	mariaDBStringColumnRegexp = regexp.MustCompile("(?i)^(\\s*`((?:[^`]|``)+)`\\s+)(uuid|inet6|inet4)\\b(.*)$")
+	// mariaDBColumnAttributeRegexp matches the attributes of the columns only in MariaDB.
+	mariaDBColumnAttributeRegexp = regexp.MustCompile(`(?i)\s*/\*M?!\d+\s+COMPRESSED\s*\*/|\s+INVISIBLE\b|\s+WITH(OUT)?\s+SYSTEM\s+VERSIONING\b`)
+	// mariaDBTableOptionRegexp matches the table options only in MariaDB, e.g. "PAGE_CHECKSUM=1".
+	mariaDBTableOptionRegexp = regexp.MustCompile("(?i)\\s+`?(PAGE_CHECKSUM|TRANSACTIONAL|PAGE_COMPRESSED|PAGE_COMPRESSION_LEVEL|ENCRYPTED|ENCRYPTION_KEY_ID|IETF_QUOTES|SEQUENCE)`?\\s*=\\s*('[^']*'|\\w+)")
+	// systemVersioningRegexp matches the system versioning of the table, which follows the table comment.
+	systemVersioningRegexp = regexp.MustCompile(`(?i)\s+WITH\s+SYSTEM\s+VERSIONING\s*$`)
+	// utf8mb3Regexp matches the utf8mb3 charset and collations, which are named utf8 by the parser.
+	utf8mb3Regexp = regexp.MustCompile(`(?i)\b(CHARSET=|CHARACTER SET |COLLATE=|COLLATE )utf8mb3`)
+)
+
+// mariaDBStringTypes are the types of the UUID and INET columns of MariaDB parsed as, whose values are compared
+// as the strings.
+var mariaDBStringTypes = map[string]string{
+	"uuid":  "char(36)",
+	"inet6": "varchar(39)",
+	"inet4": "varchar(15)",
+}
+
+// ParseServerVersion returns the flavor of the server and the release version from the result of `SELECT VERSION()`,
+// e.g. `FlavorMariaDB` and "10.6.12" of "5.5.5-10.6.12-MariaDB-1:10.6.12+maria~ubu2004-log". The release version
+// is nil if it can't be parsed.
+func ParseServerVersion(version string) (string, *semver.Version) {
+	lower := strings.ToLower(version)
+	switch {
+	case strings.Contains(lower, "tidb"):
+		release, _ := GetTiDBReleaseVersion(version)
+		return FlavorTiDB, release
+	case strings.Contains(lower, "mariadb"):
+		// the prefix isn't the version of MariaDB.
+		return FlavorMariaDB, parseReleaseVersion(strings.TrimPrefix(version, mariaDBVersionPrefix))
+	}
+	return FlavorMySQL, parseReleaseVersion(version)
+}
+
+// parseReleaseVersion returns the release version at the beginning of the version, e.g. "8.0.32" of "8.0.32-log".
+func parseReleaseVersion(version string) *semver.Version {
+	release, err := semver.NewVersion(releaseVersionRegex.FindString(version))
+	if err != nil {
+		return nil
+	}
+	return release
+}
+
+// GetServerFlavor returns the flavor of the server by `SELECT VERSION()`, see `ParseServerVersion`.
+func GetServerFlavor(ctx context.Context, db dbutil.QueryExecutor) (string, error) {
+	version, err := dbutil.GetDBVersion(ctx, db)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	flavor, _ := ParseServerVersion(version)
+	return flavor, nil
+}
+
+// normalizeMariaDBTable rewrites the `SHOW CREATE TABLE` of MariaDB to be parsed. The UUID and INET columns are
+// rewritten to the string columns by `mariaDBStringTypes`, the attributes of the columns and the table options only
+// in MariaDB are dropped, and the utf8mb3 charset is renamed to utf8. The statements of MySQL and TiDB are returned
+// as is except the utf8mb3 charset of MySQL 8.0.
+func normalizeMariaDBTable(createTableSQL string) string {
+	lines := strings.Split(utf8mb3Regexp.ReplaceAllString(createTableSQL, "${1}utf8"), "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ")") {
+			// the table options, and the comment of the table is kept as is.
+			definition, comment := splitComment(line, " COMMENT='")
+			lines[i] = systemVersioningRegexp.ReplaceAllString(mariaDBTableOptionRegexp.ReplaceAllString(definition, "")+comment, "")
+			continue
+		}
+		if !strings.HasPrefix(strings.TrimSpace(line), "`") {
+			// not a column, e.g. the index attributes like `/*!80000 INVISIBLE */` are kept.
+			continue
+		}
+		definition, comment := splitComment(line, " COMMENT '")
+		if match := mariaDBStringColumnRegexp.FindStringSubmatch(definition); match != nil {
+			definition = match[1] + mariaDBStringTypes[strings.ToLower(match[3])] + match[4]
+		}
+		lines[i] = mariaDBColumnAttributeRegexp.ReplaceAllString(definition, "") + comment
+	}
+	return strings.Join(lines, "\n")
+}
+
+// splitComment splits the line before the comment starting with prefix, so that the comment isn't rewritten.
+func splitComment(line, prefix string) (string, string) {
+	if i := strings.Index(strings.ToUpper(line), prefix); i >= 0 {
+		return line[:i], line[i:]
+	}
+	return line, ""
+}
+
+// GetMariaDBStringColumns returns the names of the UUID and INET columns in the `SHOW CREATE TABLE` of MariaDB,
+// which are parsed as the string columns.
+func GetMariaDBStringColumns(createTableSQL string) []string {
+	columns := make([]string, 0)
+	for _, line := range strings.Split(createTableSQL, "\n") {
+		if match := mariaDBStringColumnRegexp.FindStringSubmatch(line); match != nil {
+			columns = append(columns, strings.ReplaceAll(match[2], "``", "`"))
+		}
+	}
+	return columns
+}
@@ -0,0 +1,61 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"strconv"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+)
+
+// CompareNumbers compares the values of the numeric column, and returns -1, 0 or 1 if str1 is less than, equal to or
+// greater than str2. The integers are compared as int64 or uint64 by the unsigned flag of the column, since float64
+// can't tell the big integers apart like the BIGINT UNSIGNED values near 2^64. The other values are compared as
+// float64, and so are the integers which can't be parsed, e.g. the values of the transformed columns.
+func CompareNumbers(column *model.ColumnInfo, str1, str2 string) (int, error) {
+	if dbutil.IsNumberType(column.FieldType.Tp) {
+		if mysql.HasUnsignedFlag(column.Flag) {
+			num1, err1 := strconv.ParseUint(str1, 10, 64)
+			num2, err2 := strconv.ParseUint(str2, 10, 64)
+			if err1 == nil && err2 == nil {
+				return compareOrdered(num1 < num2, num1 > num2), nil
+			}
+		} else {
+			num1, err1 := strconv.ParseInt(str1, 10, 64)
+			num2, err2 := strconv.ParseInt(str2, 10, 64)
+			if err1 == nil && err2 == nil {
+				return compareOrdered(num1 < num2, num1 > num2), nil
+			}
+		}
+	}
+	num1, err1 := strconv.ParseFloat(str1, 64)
+	num2, err2 := strconv.ParseFloat(str2, 64)
+	if err1 != nil || err2 != nil {
+		return 0, errors.Errorf("convert %s, %s to float failed, err1: %v, err2: %v", str1, str2, err1, err2)
+	}
+	return compareOrdered(num1 < num2, num1 > num2), nil
+}
+
+func compareOrdered(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
@@ -185,7 +185,7 @@ func StartGCSavepointUpdateService(ctx context.Context, pdCli pd.Client, db *sql
 		return nil
 	}
 	// get latest snapshot
-	snapshotTS, err := parseSnapshotToTSO(db, snapshot)
+	snapshotTS, err := ParseSnapshotToTSO(db, snapshot)
 	if tidbVersion.Compare(*autoGCSafePointVersion) > 0 {
 		log.Info("tidb support auto gc safepoint", zap.Stringer("version", tidbVersion))
 		if err != nil {
@@ -227,7 +227,8 @@ func updateServiceSafePoint(ctx context.Context, pdClient pd.Client, snapshotTS
 	}
 }
 
-func parseSnapshotToTSO(pool *sql.DB, snapshot string) (uint64, error) {
+// ParseSnapshotToTSO parses the snapshot of TiDB, which is a TSO or a time like "2006-01-02 15:04:05", to the TSO.
+func ParseSnapshotToTSO(pool *sql.DB, snapshot string) (uint64, error) {
 	snapshotTS, err := strconv.ParseUint(snapshot, 10, 64)
 	if err == nil {
 		return snapshotTS, nil
@@ -0,0 +1,74 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb/parser"
+	"github.com/pingcap/tidb/parser/ast"
+	"github.com/pingcap/tidb/parser/model"
+)
+
+// GetRangeColumns returns the names of the columns referenced by the `range` of the table config in the order of
+// their first references, e.g. ["created_at"] of "created_at > '2024-01-01'". It returns an error if the range isn't
+// a valid condition, e.g. it's a statement injected after the condition.
+func GetRangeColumns(rangeCond string) ([]string, error) {
+	stmt, err := parser.New().ParseOneStmt("SELECT 1 FROM t WHERE "+rangeCond, "", "")
+	if err != nil {
+		return nil, errors.Annotatef(err, "invalid range %s", rangeCond)
+	}
+	collector := &columnNameCollector{seen: make(map[string]struct{})}
+	stmt.(*ast.SelectStmt).Where.Accept(collector)
+	return collector.columns, nil
+}
+
+// CheckRangeColumns returns an error if the `range` isn't a valid condition or references the columns not in the
+// table, since the same range is applied to the source and the target.
+func CheckRangeColumns(rangeCond string, tableInfo *model.TableInfo) error {
+	columns, err := GetRangeColumns(rangeCond)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, column := range columns {
+		if dbutil.FindColumnByName(tableInfo.Columns, column) == nil {
+			return errors.Errorf("column %s in the range doesn't exist in table %s", dbutil.QuoteName(column), dbutil.QuoteName(tableInfo.Name.O))
+		}
+	}
+	return nil
+}
+
+// columnNameCollector collects the names of the columns without duplicates.
+type columnNameCollector struct {
+	columns []string
+	seen    map[string]struct{}
+}
+
+func (v *columnNameCollector) Enter(n ast.Node) (ast.Node, bool) {
+	// the columns in the subqueries belong to the other tables.
+	if _, ok := n.(*ast.SubqueryExpr); ok {
+		return n, true
+	}
+	if column, ok := n.(*ast.ColumnName); ok {
+		if _, ok := v.seen[column.Name.L]; !ok {
+			v.seen[column.Name.L] = struct{}{}
+			v.columns = append(v.columns, column.Name.O)
+		}
+	}
+	return n, false
+}
+
+func (v *columnNameCollector) Leave(n ast.Node) (ast.Node, bool) {
+	return n, true
+}
@@ -0,0 +1,260 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"go.uber.org/zap"
+)
+
+// ReplicationPosition is the replication position of a source observed from DM or TiCDC, which has caught up
+// if the checkpoint replicated to the target passes the goal.
+type ReplicationPosition struct {
+	// Source is the source id of DM, or the changefeed id of TiCDC.
+	Source     string `json:"source"`
+	Goal       string `json:"goal"`
+	Checkpoint string `json:"checkpoint"`
+	CaughtUp   bool   `json:"caught-up"`
+}
+
+// ReplicationClient gets the replication positions from DM or TiCDC.
+type ReplicationClient interface {
+	// GetPositions returns the replication positions of the sources of the replication task.
+	GetPositions(ctx context.Context) ([]*ReplicationPosition, error)
+}
+
+// httpGetJSON gets the url and decodes the json response into v.
+func httpGetJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("GET %s: %s, %s", url, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return errors.Trace(json.Unmarshal(body, v))
+}
+
+// TiCDCClient gets the checkpoint of a changefeed from the open API of TiCDC, which passes goalTS.
+type TiCDCClient struct {
+	addr       string
+	changefeed string
+	goalTS     uint64
+	client     *http.Client
+}
+
+// NewTiCDCClient returns the client to get the checkpoint of the changefeed from the TiCDC server at addr, e.g.
+// "http://127.0.0.1:8300". The replication catches up when the checkpoint passes goalTS, the TSO of the source.
+func NewTiCDCClient(addr, changefeed string, goalTS uint64) *TiCDCClient {
+	return &TiCDCClient{
+		addr:       strings.TrimRight(addr, "/"),
+		changefeed: changefeed,
+		goalTS:     goalTS,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// GetPositions implements ReplicationClient.
+func (c *TiCDCClient) GetPositions(ctx context.Context) ([]*ReplicationPosition, error) {
+	/*
+		GET /api/v1/changefeeds/{changefeed_id}
+		{"id":"test","state":"normal","checkpoint_tso":437361426425954305,"checkpoint_time":"2022-11-10 10:57:15.123",...}
+	*/
+	var changefeed struct {
+		CheckpointTSO uint64 `json:"checkpoint_tso"`
+	}
+	if err := httpGetJSON(ctx, c.client, fmt.Sprintf("%s/api/v1/changefeeds/%s", c.addr, url.PathEscape(c.changefeed)), &changefeed); err != nil {
+		return nil, errors.Annotatef(err, "failed to get the changefeed %s from TiCDC", c.changefeed)
+	}
+	return []*ReplicationPosition{{
+		Source:     c.changefeed,
+		Goal:       strconv.FormatUint(c.goalTS, 10),
+		Checkpoint: strconv.FormatUint(changefeed.CheckpointTSO, 10),
+		CaughtUp:   changefeed.CheckpointTSO >= c.goalTS,
+	}}, nil
+}
+
+// DMClient gets the binlog positions of the subtasks from the status of a DM task. The goal of each source is the
+// latest binlog position of the source when it's first got, since MySQL has no snapshot.
+type DMClient struct {
+	addr   string
+	task   string
+	client *http.Client
+	// goals are the goal binlog positions, `source id` => position.
+	goals map[string]string
+}
+
+// NewDMClient returns the client to get the status of the task from the DM-master at addr, e.g. "http://127.0.0.1:8261".
+func NewDMClient(addr, task string) *DMClient {
+	return &DMClient{
+		addr:   strings.TrimRight(addr, "/"),
+		task:   task,
+		client: &http.Client{Timeout: 10 * time.Second},
+		goals:  make(map[string]string),
+	}
+}
+
+// dmQueryStatusResponse is the part of the response of the query status of DM used.
+type dmQueryStatusResponse struct {
+	Result  bool   `json:"result"`
+	Msg     string `json:"msg"`
+	Sources []struct {
+		Result       bool   `json:"result"`
+		Msg          string `json:"msg"`
+		SourceStatus struct {
+			Source string `json:"source"`
+		} `json:"sourceStatus"`
+		SubTaskStatus []struct {
+			Name string `json:"name"`
+			Sync *struct {
+				MasterBinlog string `json:"masterBinlog"`
+				SyncerBinlog string `json:"syncerBinlog"`
+			} `json:"sync"`
+		} `json:"subTaskStatus"`
+	} `json:"sources"`
+}
+
+// GetPositions implements ReplicationClient.
+func (c *DMClient) GetPositions(ctx context.Context) ([]*ReplicationPosition, error) {
+	/*
+		GET /apis/v1alpha1/status/{task}
+		{"result":true,"msg":"","sources":[{"result":true,"msg":"","sourceStatus":{"source":"mysql-replica-01",...},
+		"subTaskStatus":[{"name":"test","stage":"Running","unit":"Sync",...,"sync":{"masterBinlog":"(mysql-bin.000001, 2022)",
+		"syncerBinlog":"(mysql-bin.000001, 1024)",...}}]}]}
+	*/
+	var status dmQueryStatusResponse
+	if err := httpGetJSON(ctx, c.client, fmt.Sprintf("%s/apis/v1alpha1/status/%s", c.addr, url.PathEscape(c.task)), &status); err != nil {
+		return nil, errors.Annotatef(err, "failed to get the status of the task %s from DM", c.task)
+	}
+	if !status.Result {
+		return nil, errors.Errorf("fail to get the status of the task %s from DM, %s", c.task, status.Msg)
+	}
+	positions := make([]*ReplicationPosition, 0, len(status.Sources))
+	for _, source := range status.Sources {
+		if !source.Result {
+			return nil, errors.Errorf("fail to get the status of the source %s from DM, %s", source.SourceStatus.Source, source.Msg)
+		}
+		for _, subTask := range source.SubTaskStatus {
+			if subTask.Name != c.task {
+				continue
+			}
+			if subTask.Sync == nil {
+				// the subtask is still dumping or loading the full data.
+				positions = append(positions, &ReplicationPosition{Source: source.SourceStatus.Source})
+				continue
+			}
+			id := source.SourceStatus.Source
+			goal, ok := c.goals[id]
+			if !ok {
+				goal = subTask.Sync.MasterBinlog
+				c.goals[id] = goal
+			}
+			caughtUp, err := binlogPositionPasses(subTask.Sync.SyncerBinlog, goal)
+			if err != nil {
+				return nil, errors.Annotatef(err, "source %s", id)
+			}
+			positions = append(positions, &ReplicationPosition{
+				Source:     id,
+				Goal:       goal,
+				Checkpoint: subTask.Sync.SyncerBinlog,
+				CaughtUp:   caughtUp,
+			})
+		}
+	}
+	if len(positions) == 0 {
+		return nil, errors.Errorf("no subtask of the task %s is found in DM", c.task)
+	}
+	return positions, nil
+}
+
+// binlogPositionRegex matches the binlog position of DM like "(mysql-bin.000001, 2022)".
+var binlogPositionRegex = regexp.MustCompile(`^\((.*)\.(\d+), (\d+)\)$`)
+
+// binlogPositionPasses returns whether the binlog position pos is not before goal, which are compared by the index of
+// the binlog file, then the offset in the file.
+func binlogPositionPasses(pos, goal string) (bool, error) {
+	parse := func(position string) (uint64, uint64, error) {
+		matches := binlogPositionRegex.FindStringSubmatch(position)
+		if matches == nil {
+			return 0, 0, errors.Errorf("invalid binlog position %s", position)
+		}
+		index, err := strconv.ParseUint(matches[2], 10, 64)
+		if err != nil {
+			return 0, 0, errors.Trace(err)
+		}
+		offset, err := strconv.ParseUint(matches[3], 10, 64)
+		return index, offset, errors.Trace(err)
+	}
+	posIndex, posOffset, err := parse(pos)
+	if err != nil {
+		return false, err
+	}
+	goalIndex, goalOffset, err := parse(goal)
+	if err != nil {
+		return false, err
+	}
+	return posIndex > goalIndex || (posIndex == goalIndex && posOffset >= goalOffset), nil
+}
+
+// WaitReplication polls the replication positions by client every interval until all of them catch up, and returns
+// the positions observed last and whether they catch up before timeout. The errors of polling are logged and retried
+// until timeout, and the positions are nil if no one succeeds.
+func WaitReplication(ctx context.Context, client ReplicationClient, timeout, interval time.Duration) ([]*ReplicationPosition, bool) {
+	deadline := time.After(timeout)
+	var positions []*ReplicationPosition
+	for {
+		newPositions, err := client.GetPositions(ctx)
+		if err != nil {
+			log.Warn("failed to get the replication positions", zap.Error(err))
+		} else {
+			positions = newPositions
+			caughtUp := true
+			for _, position := range positions {
+				caughtUp = caughtUp && position.CaughtUp
+			}
+			log.Info("the replication positions", zap.Reflect("positions", positions), zap.Bool("caught up", caughtUp))
+			if caughtUp {
+				return positions, true
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return positions, false
+		case <-deadline:
+			return positions, false
+		case <-time.After(interval):
+		}
+	}
+}
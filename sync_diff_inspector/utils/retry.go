@@ -0,0 +1,31 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/progress"
+)
+
+// QueryRetryPolicy is the policy to retry the queries of loading the table infos and splitting the chunks, so that
+// a transient error doesn't fail the whole comparison. The retries are counted in the progress.
+var QueryRetryPolicy = newQueryRetryPolicy()
+
+func newQueryRetryPolicy() *dbutil.RetryPolicy {
+	policy := dbutil.DefaultRetryPolicy()
+	policy.OnRetry = func(int, error) {
+		progress.AddQueryRetry()
+	}
+	return policy
+}
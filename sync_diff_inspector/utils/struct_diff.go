@@ -0,0 +1,363 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/pkg/structdiff"
+	"github.com/pingcap/tidb/parser"
+	"github.com/pingcap/tidb/parser/ast"
+	"github.com/pingcap/tidb/parser/format"
+	"github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tidb/parser/types"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// NormalizeCreateTable renders the table structure as a normalized `CREATE TABLE` statement named `tableName`,
+// so that the structures of the source and the target can be diffed line by line. Only the columns, the indices
+// and the charset, collation and comment of the table are rendered, and the indices other than the primary key
+// are sorted by name, the options irrelevant to the structure like `AUTO_INCREMENT` are omitted.
+func NormalizeCreateTable(tableName string, tableInfo *model.TableInfo) string {
+	return renderCreateTable(dbutil.QuoteName(tableName), tableInfo)
+}
+
+// renderCreateTable renders the table structure like `NormalizeCreateTable` with the quoted name, which may be
+// qualified by the schema.
+func renderCreateTable(quotedName string, tableInfo *model.TableInfo) string {
+	lines := make([]string, 0, len(tableInfo.Columns)+len(tableInfo.Indices)+1)
+	for _, col := range tableInfo.Columns {
+		lines = append(lines, "  "+normalizeColumn(col, tableInfo))
+	}
+	if tableInfo.PKIsHandle {
+		if pkCol := tableInfo.GetPkColInfo(); pkCol != nil {
+			lines = append(lines, fmt.Sprintf("  PRIMARY KEY (%s)", dbutil.QuoteName(pkCol.Name.O)))
+		}
+	}
+	indices := make([]*model.IndexInfo, 0, len(tableInfo.Indices))
+	for _, index := range tableInfo.Indices {
+		if index.Primary {
+			// the primary key of `PKIsHandle` may be also in the indices as a fake index.
+			if !tableInfo.PKIsHandle {
+				lines = append(lines, fmt.Sprintf("  PRIMARY KEY (%s)", structdiff.FormatIndexColumns(index)))
+			}
+			continue
+		}
+		indices = append(indices, index)
+	}
+	sort.Slice(indices, func(i, j int) bool { return indices[i].Name.L < indices[j].Name.L })
+	for _, index := range indices {
+		keyType := "KEY"
+		if index.Unique {
+			keyType = "UNIQUE KEY"
+		}
+		lines = append(lines, fmt.Sprintf("  %s %s (%s)", keyType, dbutil.QuoteName(index.Name.O), structdiff.FormatIndexColumns(index)))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "CREATE TABLE %s (\n", quotedName)
+	b.WriteString(strings.Join(lines, ",\n"))
+	b.WriteString("\n)")
+	if len(tableInfo.Charset) > 0 {
+		fmt.Fprintf(&b, " DEFAULT CHARSET=%s", tableInfo.Charset)
+	}
+	if len(tableInfo.Collate) > 0 {
+		fmt.Fprintf(&b, " COLLATE=%s", tableInfo.Collate)
+	}
+	if len(tableInfo.Comment) > 0 {
+		fmt.Fprintf(&b, " COMMENT=%s", structdiff.QuoteString(tableInfo.Comment))
+	}
+	return b.String()
+}
+
+func normalizeColumn(col *model.ColumnInfo, tableInfo *model.TableInfo) string {
+	parts := []string{dbutil.QuoteName(col.Name.O), col.GetTypeDesc()}
+	if types.IsTypeChar(col.Tp) || types.IsTypeBlob(col.Tp) {
+		// the charset and the collation are rendered only if they are different from the table's.
+		if len(col.Charset) > 0 && col.Charset != tableInfo.Charset {
+			parts = append(parts, "CHARACTER SET "+col.Charset)
+		}
+		if len(col.Collate) > 0 && col.Collate != tableInfo.Collate {
+			parts = append(parts, "COLLATE "+col.Collate)
+		}
+	}
+	if col.IsGenerated() {
+		storage := "VIRTUAL"
+		if col.GeneratedStored {
+			storage = "STORED"
+		}
+		parts = append(parts, fmt.Sprintf("GENERATED ALWAYS AS (%s) %s", col.GeneratedExprString, storage))
+	}
+	if mysql.HasNotNullFlag(col.Flag) {
+		parts = append(parts, "NOT NULL")
+	}
+	if defaultValue := structdiff.DescribeDefaultValue(col); len(defaultValue) > 0 {
+		parts = append(parts, "DEFAULT "+defaultValue)
+	}
+	if mysql.HasAutoIncrementFlag(col.Flag) {
+		parts = append(parts, "AUTO_INCREMENT")
+	}
+	if len(col.Comment) > 0 {
+		parts = append(parts, "COMMENT "+structdiff.QuoteString(col.Comment))
+	}
+	return strings.Join(parts, " ")
+}
+
+// DiffCreateTable returns the unified diff of the normalized `CREATE TABLE` statements of the source tables and
+// the target table, the source tables with the same structure as the target are omitted. The statements are all
+// named `tableName`, so that the source tables routed to the target are only diffed by the structures.
+func DiffCreateTable(tableName string, upstreamTableInfos []*model.TableInfo, downstreamTableInfo *model.TableInfo) (string, error) {
+	downstream := NormalizeCreateTable(tableName, downstreamTableInfo)
+	diffs := make([]string, 0, len(upstreamTableInfos))
+	for _, upstreamTableInfo := range upstreamTableInfos {
+		upstream := NormalizeCreateTable(tableName, upstreamTableInfo)
+		if upstream == downstream {
+			continue
+		}
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(upstream),
+			B:        difflib.SplitLines(downstream),
+			FromFile: "source " + upstreamTableInfo.Name.O,
+			ToFile:   "target " + downstreamTableInfo.Name.O,
+			Context:  3,
+		})
+		if err != nil {
+			return "", errors.Trace(err)
+		}
+		diffs = append(diffs, diff)
+	}
+	return strings.Join(diffs, ""), nil
+}
+
+// NormalizeCreateView returns the normalized definition of the view from the `CREATE VIEW` statement of
+// `SHOW CREATE VIEW`, which is the `SELECT` statement and the check option, the options like `DEFINER` and the name
+// of the view are omitted. The schema of the view `schema` is removed from the names in the definition,
+// so that the views in the different schemas can be compared.
+func NormalizeCreateView(createViewSQL string, schema string) (string, error) {
+	stmt, err := parser.New().ParseOneStmt(createViewSQL, "", "")
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	createView, ok := stmt.(*ast.CreateViewStmt)
+	if !ok {
+		return "", errors.Errorf("%s is not a create view statement", createViewSQL)
+	}
+	createView.Select.Accept(&schemaNameRemover{schema: model.NewCIStr(schema)})
+	var b strings.Builder
+	if err := createView.Select.Restore(format.NewRestoreCtx(format.DefaultRestoreFlags, &b)); err != nil {
+		return "", errors.Trace(err)
+	}
+	if createView.CheckOption != model.CheckOptionCascaded {
+		fmt.Fprintf(&b, " WITH %s CHECK OPTION", strings.ToUpper(createView.CheckOption.String()))
+	}
+	return b.String(), nil
+}
+
+// schemaNameRemover removes the schema `schema` from the table names and the column names.
+type schemaNameRemover struct {
+	schema model.CIStr
+}
+
+func (v *schemaNameRemover) Enter(n ast.Node) (ast.Node, bool) {
+	switch node := n.(type) {
+	case *ast.TableName:
+		if node.Schema.L == v.schema.L {
+			node.Schema = model.CIStr{}
+		}
+	case *ast.ColumnName:
+		if node.Schema.L == v.schema.L {
+			node.Schema = model.CIStr{}
+		}
+	}
+	return n, false
+}
+
+func (v *schemaNameRemover) Leave(n ast.Node) (ast.Node, bool) {
+	return n, true
+}
+
+// NormalizePartition renders the partition definition of the table, e.g.
+// "PARTITION BY RANGE (`a`) (PARTITION `p0` VALUES LESS THAN (10), PARTITION `p1` VALUES LESS THAN (MAXVALUE))",
+// which is empty if the table isn't partitioned.
+func NormalizePartition(tableInfo *model.TableInfo) string {
+	partition := tableInfo.Partition
+	if partition == nil {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "PARTITION BY %s ", partition.Type.String())
+	if len(partition.Columns) > 0 {
+		columns := make([]string, 0, len(partition.Columns))
+		for _, col := range partition.Columns {
+			columns = append(columns, dbutil.QuoteName(col.O))
+		}
+		fmt.Fprintf(&b, "COLUMNS(%s)", strings.Join(columns, ","))
+	} else {
+		fmt.Fprintf(&b, "(%s)", partition.Expr)
+	}
+	definitions := make([]string, 0, len(partition.Definitions))
+	for _, def := range partition.Definitions {
+		definition := "PARTITION " + dbutil.QuoteName(def.Name.O)
+		if len(def.LessThan) > 0 {
+			definition += fmt.Sprintf(" VALUES LESS THAN (%s)", strings.Join(def.LessThan, ","))
+		}
+		if len(def.InValues) > 0 {
+			values := make([]string, 0, len(def.InValues))
+			for _, value := range def.InValues {
+				values = append(values, "("+strings.Join(value, ",")+")")
+			}
+			definition += fmt.Sprintf(" VALUES IN (%s)", strings.Join(values, ","))
+		}
+		definitions = append(definitions, definition)
+	}
+	fmt.Fprintf(&b, " (%s)", strings.Join(definitions, ", "))
+	return b.String()
+}
+
+// ComparePartitions returns whether the partition definitions of the source tables are the same as the target table.
+func ComparePartitions(upstreamTableInfos []*model.TableInfo, downstreamTableInfo *model.TableInfo) bool {
+	downstream := NormalizePartition(downstreamTableInfo)
+	for _, upstreamTableInfo := range upstreamTableInfos {
+		if NormalizePartition(upstreamTableInfo) != downstream {
+			return false
+		}
+	}
+	return true
+}
+
+// GetPartitionNames returns the names of the partitions of the table in order, nil if the table isn't partitioned.
+func GetPartitionNames(tableInfo *model.TableInfo) []string {
+	if tableInfo.Partition == nil {
+		return nil
+	}
+	names := make([]string, 0, len(tableInfo.Partition.Definitions))
+	for _, def := range tableInfo.Partition.Definitions {
+		names = append(names, def.Name.O)
+	}
+	return names
+}
+
+// HasSamePartitionNames returns whether the source tables and the target table are partitioned
+// by the same partition names, so the rows can be selected partition by partition on both sides.
+func HasSamePartitionNames(upstreamTableInfos []*model.TableInfo, downstreamTableInfo *model.TableInfo) bool {
+	downstream := GetPartitionNames(downstreamTableInfo)
+	if len(downstream) == 0 {
+		return false
+	}
+	for _, upstreamTableInfo := range upstreamTableInfos {
+		upstream := GetPartitionNames(upstreamTableInfo)
+		if len(upstream) != len(downstream) {
+			return false
+		}
+		for i := range upstream {
+			if !strings.EqualFold(upstream[i], downstream[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+const (
+	// StructChangeAdded means the column or the index only exists on the target.
+	StructChangeAdded = "added"
+	// StructChangeRemoved means the column or the index only exists on the source.
+	StructChangeRemoved = "removed"
+	// StructChangeChanged means the definitions of the column or the index are different on both sides.
+	StructChangeChanged = "changed"
+	// StructChangeMoved means the column is at the different positions on both sides.
+	StructChangeMoved = "moved"
+)
+
+// StructChange is a difference of a column or an index between the source table and the target table.
+type StructChange struct {
+	// Kind is "column" or "index".
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Change string `json:"change"`
+	// Source and Target are the definitions on each side, e.g. "varchar(10) NOT NULL" and "KEY (`a`,`b`)",
+	// which are the positions of the columns for `StructChangeMoved`, and empty on the side without it.
+	Source string `json:"source,omitempty"`
+	Target string `json:"target,omitempty"`
+}
+
+// String renders the change in one line, e.g. "changed column `b`: varchar(10) NULL -> varchar(20) NOT NULL".
+func (c *StructChange) String() string {
+	switch c.Change {
+	case StructChangeAdded:
+		return fmt.Sprintf("%s %s %s: %s", c.Change, c.Kind, dbutil.QuoteName(c.Name), c.Target)
+	case StructChangeRemoved:
+		return fmt.Sprintf("%s %s %s: %s", c.Change, c.Kind, dbutil.QuoteName(c.Name), c.Source)
+	default:
+		return fmt.Sprintf("%s %s %s: %s -> %s", c.Change, c.Kind, dbutil.QuoteName(c.Name), c.Source, c.Target)
+	}
+}
+
+// GetStructChanges returns the differences of the columns and the indices of the source tables from the target
+// table by `structdiff.DiffTableInfo`, the columns and the indices are matched by name case-insensitively, and the
+// same change of the source tables is returned once. The columns are compared by the type and the nullability, and
+// the indices are compared by the uniqueness and the columns. The columns are listed before the indices, in the
+// order of the target.
+func GetStructChanges(upstreamTableInfos []*model.TableInfo, downstreamTableInfo *model.TableInfo) []*StructChange {
+	changes := make([]*StructChange, 0)
+	seen := make(map[StructChange]struct{})
+	add := func(change *StructChange) {
+		if _, ok := seen[*change]; !ok {
+			seen[*change] = struct{}{}
+			changes = append(changes, change)
+		}
+	}
+	diffs := make([][]structdiff.Difference, 0, len(upstreamTableInfos))
+	for _, upstreamTableInfo := range upstreamTableInfos {
+		diffs = append(diffs, structdiff.DiffTableInfo(upstreamTableInfo, downstreamTableInfo, structdiff.Options{IgnoreDefaultValue: true, IgnoreCharset: true}))
+	}
+	for _, kind := range []string{"column", "index"} {
+		for i, upstreamTableInfo := range upstreamTableInfos {
+			for _, diff := range diffs[i] {
+				if diff.Category.Kind() == kind {
+					add(toStructChange(diff, upstreamTableInfo, downstreamTableInfo))
+				}
+			}
+		}
+	}
+	return changes
+}
+
+// toStructChange converts the difference to the change, the differences of the definition of a column or an index
+// are converted to the same change, which are deduplicated by `GetStructChanges`.
+func toStructChange(diff structdiff.Difference, upstreamTableInfo, downstreamTableInfo *model.TableInfo) *StructChange {
+	change := &StructChange{Kind: diff.Category.Kind(), Name: diff.Name, Source: diff.A, Target: diff.B}
+	switch diff.Category {
+	case structdiff.ColumnAdded, structdiff.IndexAdded:
+		change.Change = StructChangeAdded
+	case structdiff.ColumnRemoved, structdiff.IndexRemoved:
+		change.Change = StructChangeRemoved
+	case structdiff.ColumnMoved:
+		change.Change = StructChangeMoved
+	case structdiff.IndexUniqueness, structdiff.IndexColumns:
+		change.Change = StructChangeChanged
+		change.Source = structdiff.DescribeIndex(structdiff.FindIndex(structdiff.TableIndices(upstreamTableInfo), diff.Name))
+		change.Target = structdiff.DescribeIndex(structdiff.FindIndex(structdiff.TableIndices(downstreamTableInfo), diff.Name))
+	default:
+		change.Change = StructChangeChanged
+		change.Source = structdiff.DescribeColumn(dbutil.FindColumnByName(upstreamTableInfo.Columns, diff.Name))
+		change.Target = structdiff.DescribeColumn(dbutil.FindColumnByName(downstreamTableInfo.Columns, diff.Name))
+	}
+	return change
+}
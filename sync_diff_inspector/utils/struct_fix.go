@@ -0,0 +1,163 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"github.com/pingcap/tidb-tools/pkg/structdiff"
+	"github.com/pingcap/tidb/parser/model"
+)
+
+// StructFixStatement is a statement to make the structure of the target match the source, which is only generated
+// and never applied.
+type StructFixStatement struct {
+	SQL string `json:"sql"`
+	// Warning is why the statement is destructive, e.g. it drops the data of a column, empty if it isn't.
+	Warning string `json:"warning,omitempty"`
+}
+
+// String renders the statement with the warning in a comment before it.
+func (s *StructFixStatement) String() string {
+	if len(s.Warning) == 0 {
+		return s.SQL
+	}
+	return fmt.Sprintf("-- WARNING: %s\n%s", strings.ReplaceAll(s.Warning, "\n", " "), s.SQL)
+}
+
+// GenerateStructFix returns the statements to make the structure of the target table `schema`.`table` match the
+// source tables, which are the ALTER TABLE statements in the order of dropping the indices, dropping the columns,
+// adding and modifying the columns in the order of the source, and adding the indices. The columns are modified
+// if their definitions or positions are different, and the indices are dropped and added again if they are
+// different. It returns an error if the source tables have the different structures, since the target can't match
+// all of them.
+func GenerateStructFix(schema, table string, upstreamTableInfos []*model.TableInfo, downstreamTableInfo *model.TableInfo) ([]*StructFixStatement, error) {
+	if len(upstreamTableInfos) == 0 {
+		return nil, nil
+	}
+	upstreamTableInfo := upstreamTableInfos[0]
+	for _, tableInfo := range upstreamTableInfos[1:] {
+		if NormalizeCreateTable(table, tableInfo) != NormalizeCreateTable(table, upstreamTableInfo) {
+			return nil, errors.Errorf("the structures of the source tables %s and %s are different", upstreamTableInfo.Name.O, tableInfo.Name.O)
+		}
+	}
+	tableName := dbutil.TableName(schema, table)
+	statements := make([]*StructFixStatement, 0)
+	alter := func(clause, warning string) {
+		statements = append(statements, &StructFixStatement{SQL: fmt.Sprintf("ALTER TABLE %s %s;", tableName, clause), Warning: warning})
+	}
+
+	upstreamIndices, downstreamIndices := structdiff.TableIndices(upstreamTableInfo), structdiff.TableIndices(downstreamTableInfo)
+	for _, index := range downstreamIndices {
+		if upstreamIndex := structdiff.FindIndex(upstreamIndices, index.Name.O); upstreamIndex == nil || !strings.EqualFold(structdiff.DescribeIndex(upstreamIndex), structdiff.DescribeIndex(index)) {
+			alter(dropIndexClause(index), "")
+		}
+	}
+
+	// the columns of the target in order, which are updated by the statements.
+	columns := make([]string, 0, len(downstreamTableInfo.Columns))
+	for _, col := range downstreamTableInfo.Columns {
+		if dbutil.FindColumnByName(upstreamTableInfo.Columns, col.Name.O) == nil {
+			alter("DROP COLUMN "+dbutil.QuoteName(col.Name.O), fmt.Sprintf("the column %s and its data are dropped", dbutil.QuoteName(col.Name.O)))
+			continue
+		}
+		columns = append(columns, col.Name.L)
+	}
+	for i, col := range upstreamTableInfo.Columns {
+		// the column is placed after the previous column of the source, so the first i columns are in order.
+		position := "FIRST"
+		if i > 0 {
+			position = "AFTER " + dbutil.QuoteName(upstreamTableInfo.Columns[i-1].Name.O)
+		}
+		definition := normalizeColumn(col, downstreamTableInfo)
+		downstreamCol := dbutil.FindColumnByName(downstreamTableInfo.Columns, col.Name.O)
+		if downstreamCol == nil {
+			alter(fmt.Sprintf("ADD COLUMN %s %s", definition, position), "")
+			columns = insertColumn(columns, i, col.Name.L)
+			continue
+		}
+		moved := i >= len(columns) || columns[i] != col.Name.L
+		// the names of the columns are case-insensitive, so only the definitions after the names are compared.
+		if !moved && strings.TrimPrefix(definition, dbutil.QuoteName(col.Name.O)) == strings.TrimPrefix(normalizeColumn(downstreamCol, downstreamTableInfo), dbutil.QuoteName(downstreamCol.Name.O)) {
+			continue
+		}
+		warning := ""
+		if structdiff.DescribeColumn(col) != structdiff.DescribeColumn(downstreamCol) {
+			warning = fmt.Sprintf("the column %s is changed from %s to %s, which may truncate or reject the existing values",
+				dbutil.QuoteName(col.Name.O), structdiff.DescribeColumn(downstreamCol), structdiff.DescribeColumn(col))
+		}
+		if moved {
+			alter(fmt.Sprintf("MODIFY COLUMN %s %s", definition, position), warning)
+			columns = insertColumn(removeColumn(columns, col.Name.L), i, col.Name.L)
+		} else {
+			alter("MODIFY COLUMN "+definition, warning)
+		}
+	}
+
+	for _, index := range upstreamIndices {
+		if downstreamIndex := structdiff.FindIndex(downstreamIndices, index.Name.O); downstreamIndex == nil || !strings.EqualFold(structdiff.DescribeIndex(downstreamIndex), structdiff.DescribeIndex(index)) {
+			alter(addIndexClause(index), "")
+		}
+	}
+	return statements, nil
+}
+
+// GenerateCreateTableFix returns the statement to create the table `schema`.`table` only on the source on the target.
+func GenerateCreateTableFix(schema, table string, upstreamTableInfo *model.TableInfo) *StructFixStatement {
+	return &StructFixStatement{SQL: renderCreateTable(dbutil.TableName(schema, table), upstreamTableInfo) + ";"}
+}
+
+// GenerateDropTableFix returns the statement to drop the table `schema`.`table` only on the target.
+func GenerateDropTableFix(schema, table string) *StructFixStatement {
+	return &StructFixStatement{
+		SQL:     fmt.Sprintf("DROP TABLE %s;", dbutil.TableName(schema, table)),
+		Warning: fmt.Sprintf("the table %s only on the target and its data are dropped", dbutil.TableName(schema, table)),
+	}
+}
+
+func dropIndexClause(index *model.IndexInfo) string {
+	if index.Primary {
+		return "DROP PRIMARY KEY"
+	}
+	return "DROP INDEX " + dbutil.QuoteName(index.Name.O)
+}
+
+func addIndexClause(index *model.IndexInfo) string {
+	switch {
+	case index.Primary:
+		return fmt.Sprintf("ADD PRIMARY KEY (%s)", structdiff.FormatIndexColumns(index))
+	case index.Unique:
+		return fmt.Sprintf("ADD UNIQUE KEY %s (%s)", dbutil.QuoteName(index.Name.O), structdiff.FormatIndexColumns(index))
+	}
+	return fmt.Sprintf("ADD KEY %s (%s)", dbutil.QuoteName(index.Name.O), structdiff.FormatIndexColumns(index))
+}
+
+func insertColumn(columns []string, i int, name string) []string {
+	columns = append(columns, "")
+	copy(columns[i+1:], columns[i:])
+	columns[i] = name
+	return columns
+}
+
+func removeColumn(columns []string, name string) []string {
+	for i, column := range columns {
+		if column == name {
+			return append(columns[:i], columns[i+1:]...)
+		}
+	}
+	return columns
+}
@@ -0,0 +1,220 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+	"go.uber.org/zap"
+)
+
+// tableSizeBatchSize is the most tables in the IN list of a query to get the sizes of the tables.
+var tableSizeBatchSize = 500
+
+// GetTableSizes returns the sizes of the tables in the schema from `information_schema`.`tables`, which are queried
+// in batches of at most `tableSizeBatchSize` tables. The tables not found, e.g. they're just created and
+// `information_schema` lags behind, aren't in the result. If no table has a positive size, e.g. the server never
+// fills the sizes in `information_schema`, the sizes are got by `SHOW TABLE STATUS` instead.
+func GetTableSizes(ctx context.Context, db *sql.DB, schema string, tables []string) (map[string]int64, error) {
+	sizes := make(map[string]int64, len(tables))
+	hasSize := false
+	for begin := 0; begin < len(tables); begin += tableSizeBatchSize {
+		end := begin + tableSizeBatchSize
+		if end > len(tables) {
+			end = len(tables)
+		}
+		batch := tables[begin:end]
+		query := fmt.Sprintf("select table_name, sum(data_length) as data from `information_schema`.`tables` where table_schema = ? and table_name in (%s) group by table_name;",
+			strings.TrimSuffix(strings.Repeat("?,", len(batch)), ","))
+		args := make([]interface{}, 0, len(batch)+1)
+		args = append(args, schema)
+		for _, table := range batch {
+			args = append(args, table)
+		}
+		rows, err := db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		for rows.Next() {
+			var (
+				table string
+				size  sql.NullInt64
+			)
+			if err := rows.Scan(&table, &size); err != nil {
+				rows.Close()
+				return nil, errors.Trace(err)
+			}
+			sizes[table] = size.Int64
+			hasSize = hasSize || size.Int64 > 0
+		}
+		if err := rows.Err(); err != nil {
+			rows.Close()
+			return nil, errors.Trace(err)
+		}
+		rows.Close()
+	}
+	if hasSize || len(tables) == 0 {
+		return sizes, nil
+	}
+	statusSizes, err := getTableStatusSizes(ctx, db, schema, tables)
+	if err != nil {
+		// the zero sizes may be right, e.g. the tables are empty or not analyzed.
+		log.Warn("fail to get the sizes of the tables by SHOW TABLE STATUS", zap.String("schema", schema), zap.Error(err))
+		return sizes, nil
+	}
+	return statusSizes, nil
+}
+
+// getTableStatusSizes returns the `Data_length` of the tables in the schema from `SHOW TABLE STATUS`, whose columns
+// are found by name since they vary by the servers.
+func getTableStatusSizes(ctx context.Context, db *sql.DB, schema string, tables []string) (map[string]int64, error) {
+	wanted := make(map[string]struct{}, len(tables))
+	for _, table := range tables {
+		wanted[table] = struct{}{}
+	}
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SHOW TABLE STATUS FROM %s", dbutil.QuoteName(schema)))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	nameIndex, sizeIndex := -1, -1
+	for i, column := range columns {
+		switch strings.ToLower(column) {
+		case "name":
+			nameIndex = i
+		case "data_length":
+			sizeIndex = i
+		}
+	}
+	if nameIndex < 0 || sizeIndex < 0 {
+		return nil, errors.Errorf("no Name or Data_length in the columns %v of SHOW TABLE STATUS", columns)
+	}
+	sizes := make(map[string]int64, len(tables))
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, errors.Trace(err)
+		}
+		if _, ok := wanted[values[nameIndex].String]; !ok {
+			continue
+		}
+		// the size is NULL for the views.
+		size, _ := strconv.ParseInt(values[sizeIndex].String, 10, 64)
+		sizes[values[nameIndex].String] = size
+	}
+	return sizes, errors.Trace(rows.Err())
+}
+
+// TableSizeCache caches the sizes of the tables got by `GetTableSizes` in a run. The tables are registered ahead,
+// and when the size of a table is missed, the sizes of all the registered tables in its schema not cached are got
+// together, so the sizes of many tables only cost a few queries. The failure is cached too unless the context is
+// done, so the failed queries aren't retried for each table.
+type TableSizeCache struct {
+	sync.Mutex
+	db *sql.DB
+	// tables are the registered tables by schema, which are removed once their sizes are got.
+	tables map[string]map[string]struct{}
+	sizes  map[string]int64
+	errs   map[string]error
+}
+
+// NewTableSizeCache returns the cache of the sizes of the tables in db.
+func NewTableSizeCache(db *sql.DB) *TableSizeCache {
+	return &TableSizeCache{
+		db:     db,
+		tables: make(map[string]map[string]struct{}),
+		sizes:  make(map[string]int64),
+		errs:   make(map[string]error),
+	}
+}
+
+// Register registers the tables of the schema, whose sizes are got together.
+func (c *TableSizeCache) Register(schema string, tables ...string) {
+	c.Lock()
+	defer c.Unlock()
+	for _, table := range tables {
+		id := UniqueID(schema, table)
+		if _, ok := c.sizes[id]; ok {
+			continue
+		}
+		if _, ok := c.errs[id]; ok {
+			continue
+		}
+		if _, ok := c.tables[schema]; !ok {
+			c.tables[schema] = make(map[string]struct{})
+		}
+		c.tables[schema][table] = struct{}{}
+	}
+}
+
+// GetTableSize returns the size of the table, which is 0 if the table isn't found in the statistics.
+func (c *TableSizeCache) GetTableSize(ctx context.Context, schema, table string) (int64, error) {
+	c.Lock()
+	defer c.Unlock()
+	id := UniqueID(schema, table)
+	if size, ok := c.sizes[id]; ok {
+		return size, nil
+	}
+	if err, ok := c.errs[id]; ok {
+		return 0, err
+	}
+	if _, ok := c.tables[schema]; !ok {
+		c.tables[schema] = make(map[string]struct{})
+	}
+	c.tables[schema][table] = struct{}{}
+	tables := make([]string, 0, len(c.tables[schema]))
+	for name := range c.tables[schema] {
+		tables = append(tables, name)
+	}
+	sort.Strings(tables)
+	delete(c.tables, schema)
+
+	sizes, err := GetTableSizes(ctx, c.db, schema, tables)
+	if err != nil && ctx.Err() != nil {
+		// the sizes are got again by the next caller with a live context.
+		c.tables[schema] = make(map[string]struct{}, len(tables))
+		for _, name := range tables {
+			c.tables[schema][name] = struct{}{}
+		}
+		return 0, errors.Trace(err)
+	}
+	for _, name := range tables {
+		if err != nil {
+			c.errs[UniqueID(schema, name)] = err
+		} else {
+			c.sizes[UniqueID(schema, name)] = sizes[name]
+		}
+	}
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	return sizes[table], nil
+}
@@ -28,6 +28,8 @@ import (
 	"github.com/pingcap/log"
 	"github.com/pingcap/tidb-tools/pkg/dbutil"
 	"github.com/pingcap/tidb-tools/sync_diff_inspector/chunk"
+	"github.com/pingcap/tidb-tools/sync_diff_inspector/config"
+	"github.com/pingcap/tidb/parser/charset"
 	"github.com/pingcap/tidb/parser/model"
 	"github.com/pingcap/tidb/parser/mysql"
 	"go.uber.org/zap"
@@ -116,14 +118,29 @@ func GetColumnsFromIndex(index *model.IndexInfo, tableInfo *model.TableInfo) []*
 	return indexColumns
 }
 
+// HasUniqueKey returns true if the table has a primary key or unique key.
+func HasUniqueKey(tableInfo *model.TableInfo) bool {
+	for _, index := range tableInfo.Indices {
+		if index.Primary || index.Unique {
+			return true
+		}
+	}
+	return false
+}
+
 // GetTableRowsQueryFormat returns a rowsQuerySQL template for the specific table.
 //  e.g. SELECT /*!40001 SQL_NO_CACHE */ `a`, `b` FROM `schema`.`table` WHERE %s ORDER BY `a`.
-func GetTableRowsQueryFormat(schema, table string, tableInfo *model.TableInfo, collation string) (string, []*model.ColumnInfo) {
+func GetTableRowsQueryFormat(schema, table, partition string, tableInfo *model.TableInfo, columnTransforms map[string]string, collation string) (string, []*model.ColumnInfo) {
 	orderKeys, orderKeyCols := dbutil.SelectUniqueOrderKey(tableInfo)
 
 	columnNames := make([]string, 0, len(tableInfo.Columns))
 	for _, col := range tableInfo.Columns {
-		columnNames = append(columnNames, dbutil.ColumnName(col.Name.O))
+		name := dbutil.ColumnName(col.Name.O)
+		if transform, ok := columnTransforms[col.Name.O]; ok {
+			// the transformed value is selected with the column name, so the row is read as usual.
+			name = fmt.Sprintf("%s AS %s", transformColumnOf(col, name, transform), name)
+		}
+		columnNames = append(columnNames, name)
 	}
 	columns := strings.Join(columnNames, ", ")
 	if collation != "" {
@@ -135,13 +152,13 @@ func GetTableRowsQueryFormat(schema, table string, tableInfo *model.TableInfo, c
 	}
 
 	query := fmt.Sprintf("SELECT /*!40001 SQL_NO_CACHE */ %s FROM %s WHERE %%s ORDER BY %s%s",
-		columns, dbutil.TableName(schema, table), strings.Join(orderKeys, ","), collation)
+		columns, TableNameWithPartition(schema, table, partition), strings.Join(orderKeys, ","), collation)
 
 	return query, orderKeyCols
 }
 
-// GenerateReplaceDML returns the insert SQL for the specific row values.
-func GenerateReplaceDML(data map[string]*dbutil.ColumnData, table *model.TableInfo, schema string) string {
+// generateColumnValues returns the column names and the values of the row, the generated columns are skipped.
+func generateColumnValues(data map[string]*dbutil.ColumnData, table *model.TableInfo) ([]string, []string) {
 	colNames := make([]string, 0, len(table.Columns))
 	values := make([]string, 0, len(table.Columns))
 	for _, col := range table.Columns {
@@ -150,26 +167,26 @@ func GenerateReplaceDML(data map[string]*dbutil.ColumnData, table *model.TableIn
 		}
 
 		colNames = append(colNames, dbutil.ColumnName(col.Name.O))
-		if data[col.Name.O].IsNull {
-			values = append(values, "NULL")
-			continue
-		}
-
-		if NeedQuotes(col.FieldType.Tp) {
-			values = append(values, fmt.Sprintf("'%s'", strings.Replace(string(data[col.Name.O].Data), "'", "\\'", -1)))
-		} else {
-			values = append(values, string(data[col.Name.O].Data))
-		}
+		values = append(values, columnValue(data[col.Name.O], col))
 	}
+	return colNames, values
+}
 
-	return fmt.Sprintf("REPLACE INTO %s(%s) VALUES (%s);", dbutil.TableName(schema, table.Name.O), strings.Join(colNames, ","), strings.Join(values, ","))
+func columnValue(data *dbutil.ColumnData, col *model.ColumnInfo) string {
+	if data.IsNull {
+		return "NULL"
+	}
+	if col.FieldType.Tp == mysql.TypeGeometry {
+		return geometryValue(data.Data)
+	}
+	if NeedQuotes(col.FieldType.Tp) {
+		return fmt.Sprintf("'%s'", strings.Replace(string(data.Data), "'", "\\'", -1))
+	}
+	return string(data.Data)
 }
 
-// GerateReplaceDMLWithAnnotation returns the replace SQL for the specific 2 rows.
-// And add Annotations to show the different columns.
-func GenerateReplaceDMLWithAnnotation(source, target map[string]*dbutil.ColumnData, table *model.TableInfo, schema string) string {
-	sqlColNames := make([]string, 0, len(table.Columns))
-	sqlValues := make([]string, 0, len(table.Columns))
+// generateDiffAnnotation returns the comment shows the different columns of the 2 rows.
+func generateDiffAnnotation(source, target map[string]*dbutil.ColumnData, table *model.TableInfo) string {
 	colNames := append(make([]string, 0, len(table.Columns)+1), "diff columns")
 	values1 := append(make([]string, 0, len(table.Columns)+1), "source data")
 	values2 := append(make([]string, 0, len(table.Columns)+1), "target data")
@@ -180,42 +197,16 @@ func GenerateReplaceDMLWithAnnotation(source, target map[string]*dbutil.ColumnDa
 			continue
 		}
 
-		var data1, data2 *dbutil.ColumnData
-		var value1 string
-		data1 = source[col.Name.O]
-		data2 = target[col.Name.O]
-
-		if data1.IsNull {
-			value1 = "NULL"
-		} else {
-			if NeedQuotes(col.FieldType.Tp) {
-				value1 = fmt.Sprintf("'%s'", strings.Replace(string(data1.Data), "'", "\\'", -1))
-			} else {
-				value1 = string(data1.Data)
-			}
-		}
-		colName := dbutil.ColumnName(col.Name.O)
-		sqlColNames = append(sqlColNames, colName)
-		sqlValues = append(sqlValues, value1)
-
+		data1 := source[col.Name.O]
+		data2 := target[col.Name.O]
 		// Only show different columns in annotations.
 		if (string(data1.Data) == string(data2.Data)) && (data1.IsNull == data2.IsNull) {
 			continue
 		}
 
-		colNames = append(colNames, colName)
-		values1 = append(values1, value1)
-
-		if data2.IsNull {
-			values2 = append(values2, "NULL")
-		} else {
-			if NeedQuotes(col.FieldType.Tp) {
-				values2 = append(values2, fmt.Sprintf("'%s'", strings.Replace(string(data2.Data), "'", "\\'", -1)))
-			} else {
-				values2 = append(values2, string(data2.Data))
-			}
-		}
-
+		colNames = append(colNames, dbutil.ColumnName(col.Name.O))
+		values1 = append(values1, columnValue(data1, col))
+		values2 = append(values2, columnValue(data2, col))
 	}
 
 	diffTable.SetRowLine(true)
@@ -229,7 +220,49 @@ func GenerateReplaceDMLWithAnnotation(source, target map[string]*dbutil.ColumnDa
 	diffTable.SetBorder(false)
 	diffTable.Render()
 
-	return fmt.Sprintf("/*\n%s*/\nREPLACE INTO %s(%s) VALUES (%s);", tableString.String(), dbutil.TableName(schema, table.Name.O), strings.Join(sqlColNames, ","), strings.Join(sqlValues, ","))
+	// the names and the values with "*/" would end the comment.
+	return fmt.Sprintf("/*\n%s*/\n", strings.ReplaceAll(tableString.String(), "*/", "* /"))
+}
+
+// GenerateReplaceDML returns the insert SQL for the specific row values.
+func GenerateReplaceDML(data map[string]*dbutil.ColumnData, table *model.TableInfo, schema string) string {
+	colNames, values := generateColumnValues(data, table)
+	return fmt.Sprintf("REPLACE INTO %s(%s) VALUES (%s);", dbutil.TableName(schema, table.Name.O), strings.Join(colNames, ","), strings.Join(values, ","))
+}
+
+// GerateReplaceDMLWithAnnotation returns the replace SQL for the specific 2 rows.
+// And add Annotations to show the different columns.
+func GenerateReplaceDMLWithAnnotation(source, target map[string]*dbutil.ColumnData, table *model.TableInfo, schema string) string {
+	return generateDiffAnnotation(source, target, table) + GenerateReplaceDML(source, table, schema)
+}
+
+// GenerateInsertDML returns the insert SQL for the specific row values.
+func GenerateInsertDML(data map[string]*dbutil.ColumnData, table *model.TableInfo, schema string) string {
+	colNames, values := generateColumnValues(data, table)
+	return fmt.Sprintf("INSERT INTO %s(%s) VALUES (%s);", dbutil.TableName(schema, table.Name.O), strings.Join(colNames, ","), strings.Join(values, ","))
+}
+
+// GenerateInsertOnDuplicateDML returns the `INSERT ... ON DUPLICATE KEY UPDATE` SQL for the specific row values,
+// which updates the existing row in place instead of deleting it like `REPLACE`.
+func GenerateInsertOnDuplicateDML(data map[string]*dbutil.ColumnData, table *model.TableInfo, schema string) string {
+	colNames, values := generateColumnValues(data, table)
+	updates := make([]string, 0, len(colNames))
+	for _, colName := range colNames {
+		updates = append(updates, fmt.Sprintf("%s = VALUES(%s)", colName, colName))
+	}
+	return fmt.Sprintf("INSERT INTO %s(%s) VALUES (%s) ON DUPLICATE KEY UPDATE %s;", dbutil.TableName(schema, table.Name.O), strings.Join(colNames, ","), strings.Join(values, ","), strings.Join(updates, ","))
+}
+
+// GenerateInsertOnDuplicateDMLWithAnnotation returns the `INSERT ... ON DUPLICATE KEY UPDATE` SQL for the specific 2 rows.
+// And add Annotations to show the different columns.
+func GenerateInsertOnDuplicateDMLWithAnnotation(source, target map[string]*dbutil.ColumnData, table *model.TableInfo, schema string) string {
+	return generateDiffAnnotation(source, target, table) + GenerateInsertOnDuplicateDML(source, table, schema)
+}
+
+// GenerateDeleteInsertDMLWithAnnotation returns the delete SQL of the target row and the insert SQL of the source row.
+// And add Annotations to show the different columns.
+func GenerateDeleteInsertDMLWithAnnotation(source, target map[string]*dbutil.ColumnData, table *model.TableInfo, schema string) string {
+	return generateDiffAnnotation(source, target, table) + GenerateDeleteDML(target, table, schema) + "\n" + GenerateInsertDML(source, table, schema)
 }
 
 // GerateReplaceDMLWithAnnotation returns the delete SQL for the specific row.
@@ -245,11 +278,7 @@ func GenerateDeleteDML(data map[string]*dbutil.ColumnData, table *model.TableInf
 			continue
 		}
 
-		if NeedQuotes(col.FieldType.Tp) {
-			kvs = append(kvs, fmt.Sprintf("%s = '%s'", dbutil.ColumnName(col.Name.O), strings.Replace(string(data[col.Name.O].Data), "'", "\\'", -1)))
-		} else {
-			kvs = append(kvs, fmt.Sprintf("%s = %s", dbutil.ColumnName(col.Name.O), string(data[col.Name.O].Data)))
-		}
+		kvs = append(kvs, fmt.Sprintf("%s = %s", dbutil.ColumnName(col.Name.O), columnValue(data[col.Name.O], col)))
 	}
 	return fmt.Sprintf("DELETE FROM %s WHERE %s LIMIT 1;", dbutil.TableName(schema, table.Name.O), strings.Join(kvs, " AND "))
 
@@ -317,6 +346,132 @@ func CompareStruct(upstreamTableInfos []*model.TableInfo, downstreamTableInfo *m
 		}
 	}
 
+	return compareIndices(upstreamTableInfos, downstreamTableInfo), false
+}
+
+// CompareStructByColumnSet compares the structures like `CompareStruct`, but only the different column sets
+// or the incompatible column types are breaking, which returns isPanic true. The different column orders and
+// indices are non-breaking, because the columns are compared by name, so the data can still be compared.
+func CompareStructByColumnSet(upstreamTableInfos []*model.TableInfo, downstreamTableInfo *model.TableInfo) (isEqual bool, isPanic bool) {
+	isEqual = true
+	downstreamColumns := make(map[string]*model.ColumnInfo, len(downstreamTableInfo.Columns))
+	for _, column := range downstreamTableInfo.Columns {
+		downstreamColumns[column.Name.L] = column
+	}
+	for _, upstreamTableInfo := range upstreamTableInfos {
+		if len(upstreamTableInfo.Columns) != len(downstreamTableInfo.Columns) {
+			log.Error("column num not equal", zap.String("upstream table", upstreamTableInfo.Name.O), zap.Int("column num", len(upstreamTableInfo.Columns)), zap.String("downstream table", downstreamTableInfo.Name.O), zap.Int("column num", len(downstreamTableInfo.Columns)))
+			return false, true
+		}
+		for i, column := range upstreamTableInfo.Columns {
+			downstreamColumn, ok := downstreamColumns[column.Name.L]
+			if !ok {
+				log.Error("column not found in downstream", zap.String("upstream table", upstreamTableInfo.Name.O), zap.String("column name", column.Name.O), zap.String("downstream table", downstreamTableInfo.Name.O))
+				return false, true
+			}
+			if !isCompatible(column.Tp, downstreamColumn.Tp) {
+				log.Error("column type not compatible", zap.String("upstream table", upstreamTableInfo.Name.O), zap.String("column name", column.Name.O), zap.Uint8("column type", column.Tp), zap.String("downstream table", downstreamTableInfo.Name.O), zap.Uint8("column type", downstreamColumn.Tp))
+				return false, true
+			}
+			if column.Name.O != downstreamTableInfo.Columns[i].Name.O {
+				log.Warn("column order not equal", zap.String("upstream table", upstreamTableInfo.Name.O), zap.String("column name", column.Name.O), zap.String("downstream table", downstreamTableInfo.Name.O), zap.String("column name", downstreamTableInfo.Columns[i].Name.O))
+				isEqual = false
+			}
+		}
+	}
+	return compareIndices(upstreamTableInfos, downstreamTableInfo) && isEqual, false
+}
+
+// CompareEnumMembers compares the members of the ENUM and SET columns of the source tables with the target table,
+// the columns are matched by name and the ones of the different types are left to the struct check. It returns the
+// columns of the target whose members are the same on both sides but in different orders, whose stored indexes
+// differ for the same values, and whether the members are the same regardless of the orders.
+func CompareEnumMembers(upstreamTableInfos []*model.TableInfo, downstreamTableInfo *model.TableInfo) (reorderedColumns []string, isEqual bool) {
+	isEqual = true
+	reorderedColumns = make([]string, 0)
+	for _, downstreamColumn := range downstreamTableInfo.Columns {
+		if downstreamColumn.Tp != mysql.TypeEnum && downstreamColumn.Tp != mysql.TypeSet {
+			continue
+		}
+		reordered := false
+		for _, upstreamTableInfo := range upstreamTableInfos {
+			upstreamColumn := dbutil.FindColumnByName(upstreamTableInfo.Columns, downstreamColumn.Name.O)
+			if upstreamColumn == nil || upstreamColumn.Tp != downstreamColumn.Tp {
+				continue
+			}
+			switch {
+			case equalStrings(upstreamColumn.Elems, downstreamColumn.Elems):
+			case equalStrings(sortedStrings(upstreamColumn.Elems), sortedStrings(downstreamColumn.Elems)):
+				reordered = true
+			default:
+				log.Warn("the members of the column are not equal", zap.String("upstream table", upstreamTableInfo.Name.O), zap.Strings("upstream members", upstreamColumn.Elems),
+					zap.String("downstream table", downstreamTableInfo.Name.O), zap.Strings("downstream members", downstreamColumn.Elems), zap.String("column name", downstreamColumn.Name.O))
+				isEqual = false
+			}
+		}
+		if reordered {
+			reorderedColumns = append(reorderedColumns, downstreamColumn.Name.O)
+		}
+	}
+	return reorderedColumns, isEqual
+}
+
+func sortedStrings(s []string) []string {
+	sorted := append([]string(nil), s...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+func equalStrings(s1, s2 []string) bool {
+	if len(s1) != len(s2) {
+		return false
+	}
+	for i := range s1 {
+		if s1[i] != s2[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchColumnsByName reorders the columns of the downstream table in the order of the first upstream table,
+// if they have the same column names in different orders. The offsets of the columns are reset like `ResetColumns`,
+// so that the rows are selected and compared in the same column order on both sides.
+// Returns true if the columns are reordered.
+func MatchColumnsByName(upstreamTableInfos []*model.TableInfo, downstreamTableInfo *model.TableInfo) bool {
+	if len(upstreamTableInfos) == 0 {
+		return false
+	}
+	upstreamColumns := upstreamTableInfos[0].Columns
+	if len(upstreamColumns) != len(downstreamTableInfo.Columns) {
+		return false
+	}
+	downstreamColumns := make(map[string]*model.ColumnInfo, len(downstreamTableInfo.Columns))
+	for _, column := range downstreamTableInfo.Columns {
+		downstreamColumns[column.Name.L] = column
+	}
+	reordered := false
+	columns := make([]*model.ColumnInfo, 0, len(upstreamColumns))
+	for i, column := range upstreamColumns {
+		downstreamColumn, ok := downstreamColumns[column.Name.L]
+		if !ok {
+			// the column sets are different, which is left to the struct check.
+			return false
+		}
+		reordered = reordered || downstreamTableInfo.Columns[i] != downstreamColumn
+		columns = append(columns, downstreamColumn)
+	}
+	if !reordered {
+		return false
+	}
+	downstreamTableInfo.Columns = columns
+	ResetColumns(downstreamTableInfo, nil)
+	return true
+}
+
+// compareIndices compares the indices, and removes the indices not in all the tables,
+// returns false if any index is removed.
+func compareIndices(upstreamTableInfos []*model.TableInfo, downstreamTableInfo *model.TableInfo) bool {
 	// compare indices
 	deleteIndicesSet := make(map[string]struct{})
 	unilateralIndicesSet := make(map[string]struct{})
@@ -409,7 +564,7 @@ func CompareStruct(upstreamTableInfos []*model.TableInfo, downstreamTableInfo *m
 
 	}
 
-	return len(deleteIndicesSet) == 0, false
+	return len(deleteIndicesSet) == 0
 }
 
 // NeedQuotes determines whether an escape character is required for `'`.
@@ -485,6 +640,17 @@ func CompareData(map1, map2 map[string]*dbutil.ColumnData, orderKeyCols, columns
 	}
 
 	// Not Equal. Compare orderkeycolumns.
+	cmp, err = CompareOrderKeys(map1, map2, orderKeyCols)
+	return
+}
+
+// CompareOrderKeys compares two row datas by the orderkeycolumns, and returns -1 if map1 < map2, 0 if they have the
+// same orderkeycolumns, or 1 if map1 > map2.
+func CompareOrderKeys(map1, map2 map[string]*dbutil.ColumnData, orderKeyCols []*model.ColumnInfo) (cmp int32, err error) {
+	var (
+		data1, data2 *dbutil.ColumnData
+		ok           bool
+	)
 	for _, col := range orderKeyCols {
 		if data1, ok = map1[col.Name.O]; !ok {
 			err = errors.Errorf("don't have key %s", col.Name.O)
@@ -521,22 +687,17 @@ func CompareData(map1, map2 map[string]*dbutil.ColumnData, orderKeyCols, columns
 			}
 			break
 		} else {
-			num1, err1 := strconv.ParseFloat(string(data1.Data), 64)
-			num2, err2 := strconv.ParseFloat(string(data2.Data), 64)
-			if err1 != nil || err2 != nil {
-				err = errors.Errorf("convert %s, %s to float failed, err1: %v, err2: %v", string(data1.Data), string(data2.Data), err1, err2)
+			var numCmp int
+			numCmp, err = CompareNumbers(col, string(data1.Data), string(data2.Data))
+			if err != nil {
 				return
 			}
 
-			if num1 == num2 {
+			if numCmp == 0 {
 				continue
 			}
 
-			if num1 < num2 {
-				cmp = -1
-			} else {
-				cmp = 1
-			}
+			cmp = int32(numCmp)
 			break
 		}
 	}
@@ -582,7 +743,7 @@ func SliceToMap(slice []string) map[string]interface{} {
 }
 
 // GetApproximateMidBySize return the `count`th row in rows that meet the `limitRange`.
-func GetApproximateMidBySize(ctx context.Context, db *sql.DB, schema, table string, indexColumns []*model.ColumnInfo, limitRange string, args []interface{}, count int64) (map[string]string, error) {
+func GetApproximateMidBySize(ctx context.Context, db *sql.DB, schema, table, partition string, indexColumns []*model.ColumnInfo, limitRange string, args []interface{}, count int64) (map[string]string, error) {
 	/*
 		example
 		mysql> select i_id, i_im_id, i_name from item where i_id > 0 order by i_id, i_im_id, i_name limit 5000,1;
@@ -599,7 +760,7 @@ func GetApproximateMidBySize(ctx context.Context, db *sql.DB, schema, table stri
 	}
 	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s ORDER BY %s LIMIT 1 OFFSET %d",
 		strings.Join(columnNames, ", "),
-		dbutil.TableName(schema, table),
+		TableNameWithPartition(schema, table, partition),
 		limitRange,
 		strings.Join(columnNames, ", "),
 		count/2)
@@ -630,19 +791,71 @@ func GetApproximateMidBySize(ctx context.Context, db *sql.DB, schema, table stri
 	return columnValues, nil
 }
 
-// GetTableSize loads the TableSize from `information_schema`.`tables`.
-func GetTableSize(ctx context.Context, db *sql.DB, schemaName, tableName string) (int64, error) {
-	query := "select sum(data_length) as data from `information_schema`.`tables` where table_schema=? and table_name=? GROUP BY data_length;"
-	var dataSize sql.NullInt64
-	err := db.QueryRowContext(ctx, query, schemaName, tableName).Scan(&dataSize)
+// GetServerVersion returns the result of `SELECT VERSION()`, and the result of `SELECT tidb_version()` if the server is TiDB.
+// The `tidb_version()` doesn't exist in MySQL, so it's only queried when the version contains `TiDB`.
+func GetServerVersion(ctx context.Context, db *sql.DB) (string, string, error) {
+	version, err := dbutil.GetDBVersion(ctx, db)
+	if err != nil {
+		return "", "", errors.Trace(err)
+	}
+	if !strings.Contains(strings.ToLower(version), "tidb") {
+		return version, "", nil
+	}
+	var tidbVersion sql.NullString
+	if err := db.QueryRowContext(ctx, "SELECT tidb_version()").Scan(&tidbVersion); err != nil {
+		// the version is enough to show, e.g. the server is a proxy which doesn't support `tidb_version()`.
+		log.Warn("fail to get tidb_version()", zap.String("version", version), zap.Error(err))
+		return version, "", nil
+	}
+	return version, tidbVersion.String, nil
+}
+
+// GetAutoIncrementSettings returns `auto_increment_increment` and `auto_increment_offset` of the session, which
+// decide the values generated for the AUTO_INCREMENT columns on the server.
+func GetAutoIncrementSettings(ctx context.Context, db *sql.DB) (int64, int64, error) {
+	var increment, offset int64
+	err := db.QueryRowContext(ctx, "SELECT @@auto_increment_increment, @@auto_increment_offset").Scan(&increment, &offset)
+	if err != nil {
+		return 0, 0, errors.Trace(err)
+	}
+	return increment, offset, nil
+}
+
+// AutoIncrementDisjoint returns true if the AUTO_INCREMENT values generated by the two settings never overlap, e.g.
+// increment 2 with offset 1 generates the odd values, and increment 2 with offset 2 generates the even values. The
+// values are `offset + N * increment`, and the offset is ignored by the server if it's greater than the increment.
+func AutoIncrementDisjoint(increment1, offset1, increment2, offset2 int64) bool {
+	if increment1 <= 0 || increment2 <= 0 {
+		return false
+	}
+	if offset1 > increment1 {
+		offset1 = 1
+	}
+	if offset2 > increment2 {
+		offset2 = 1
+	}
+	// the 2 arithmetic progressions intersect iff the offsets are congruent modulo the gcd of the increments.
+	a, b := increment1, increment2
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return (offset1-offset2)%a != 0
+}
+
+// GetTableRowsEstimate returns the estimated row count of the table from `information_schema` without reading the data,
+// it may be inaccurate or 0 if the table is not analyzed.
+func GetTableRowsEstimate(ctx context.Context, db *sql.DB, schemaName, tableName string) (int64, error) {
+	query := "SELECT table_rows FROM `information_schema`.`tables` WHERE table_schema=? AND table_name=?;"
+	var rows sql.NullInt64
+	err := db.QueryRowContext(ctx, query, schemaName, tableName).Scan(&rows)
 	if err != nil {
-		return int64(0), errors.Trace(err)
+		return 0, errors.Trace(err)
 	}
-	return dataSize.Int64, nil
+	return rows.Int64, nil
 }
 
 // GetCountAndCRC32Checksum returns checksum code and count of some data by given condition
-func GetCountAndCRC32Checksum(ctx context.Context, db *sql.DB, schemaName, tableName string, tbInfo *model.TableInfo, limitRange string, args []interface{}) (int64, int64, error) {
+func GetCountAndCRC32Checksum(ctx context.Context, db *sql.DB, schemaName, tableName, partition string, tbInfo *model.TableInfo, columnTransforms map[string]string, limitRange string, args []interface{}) (int64, int64, error) {
 	/*
 		calculate CRC32 checksum and count example:
 		mysql> select count(*) as CNT, BIT_XOR(CAST(CRC32(CONCAT_WS(',', id, name, age, CONCAT(ISNULL(id), ISNULL(name), ISNULL(age))))AS UNSIGNED)) as CHECKSUM from test.test where id > 0;
@@ -653,10 +866,43 @@ func GetCountAndCRC32Checksum(ctx context.Context, db *sql.DB, schemaName, table
 		+--------+------------+
 		1 row in set (0.46 sec)
 	*/
-	columnNames := make([]string, 0, len(tbInfo.Columns))
-	columnIsNull := make([]string, 0, len(tbInfo.Columns))
-	for _, col := range tbInfo.Columns {
+	query := GetCountAndCRC32ChecksumSQL(schemaName, tableName, partition, tbInfo, columnTransforms, limitRange)
+	log.Debug("count and checksum", zap.String("sql", query), zap.Reflect("args", args))
+
+	var count sql.NullInt64
+	var checksum sql.NullInt64
+	err := db.QueryRowContext(ctx, query, args...).Scan(&count, &checksum)
+	if err != nil {
+		log.Warn("execute checksum query fail", zap.String("query", query), zap.Reflect("args", args), zap.Error(err))
+		return -1, -1, errors.Trace(err)
+	}
+	if !count.Valid || !checksum.Valid {
+		// if don't have any data, the checksum will be `NULL`
+		log.Warn("get empty count or checksum", zap.String("sql", query), zap.Reflect("args", args))
+		return 0, 0, nil
+	}
+
+	return count.Int64, checksum.Int64, nil
+}
+
+// GetCountAndCRC32ChecksumSQL returns the sql to get the checksum code and count of some data by given condition,
+// the values of the columns in `columnTransforms` are transformed before calculating the checksum.
+func GetCountAndCRC32ChecksumSQL(schemaName, tableName, partition string, tbInfo *model.TableInfo, columnTransforms map[string]string, limitRange string) string {
+	columnNames, columnIsNull := checksumColumns(tbInfo.Columns, columnTransforms)
+	return fmt.Sprintf("SELECT COUNT(*) as CNT, BIT_XOR(CAST(CRC32(CONCAT_WS(',', %s, CONCAT(%s)))AS UNSIGNED)) as CHECKSUM FROM %s WHERE %s;",
+		strings.Join(columnNames, ", "), strings.Join(columnIsNull, ", "), TableNameWithPartition(schemaName, tableName, partition), limitRange)
+}
+
+// checksumColumns returns the expressions of the columns and their `ISNULL` in the checksum, the values of the
+// columns in `columnTransforms` are transformed.
+func checksumColumns(columns []*model.ColumnInfo, columnTransforms map[string]string) ([]string, []string) {
+	columnNames := make([]string, 0, len(columns))
+	columnIsNull := make([]string, 0, len(columns))
+	for _, col := range columns {
 		name := dbutil.ColumnName(col.Name.O)
+		if transform, ok := columnTransforms[col.Name.O]; ok {
+			name = transformColumnOf(col, name, transform)
+		}
 		// When col value is 0, the result is NULL.
 		// But we can use ISNULL to distinguish between null and 0.
 		if col.FieldType.Tp == mysql.TypeFloat {
@@ -667,25 +913,127 @@ func GetCountAndCRC32Checksum(ctx context.Context, db *sql.DB, schemaName, table
 		columnNames = append(columnNames, name)
 		columnIsNull = append(columnIsNull, fmt.Sprintf("ISNULL(%s)", name))
 	}
+	return columnNames, columnIsNull
+}
 
-	query := fmt.Sprintf("SELECT COUNT(*) as CNT, BIT_XOR(CAST(CRC32(CONCAT_WS(',', %s, CONCAT(%s)))AS UNSIGNED)) as CHECKSUM FROM %s WHERE %s;",
-		strings.Join(columnNames, ", "), strings.Join(columnIsNull, ", "), dbutil.TableName(schemaName, tableName), limitRange)
-	log.Debug("count and checksum", zap.String("sql", query), zap.Reflect("args", args))
+// TransformColumn returns the expression of the built-in column transform applied to the column `name`,
+// see `config.ColumnTransformLower` and so on. The transforms separated by "," are applied in order, e.g.
+// "lower,rtrim" is `RTRIM(LOWER(name))`. The name is returned as is for the unknown transform.
+func TransformColumn(name string, transform string) string {
+	for _, t := range strings.Split(transform, ",") {
+		name = transformColumn(name, t)
+	}
+	return name
+}
 
-	var count sql.NullInt64
-	var checksum sql.NullInt64
-	err := db.QueryRowContext(ctx, query, args...).Scan(&count, &checksum)
+// transformColumnOf is like TransformColumn, and the transforms depending on the definition of the column `col`
+// are also applied, e.g. `config.ColumnTransformSetValue`.
+func transformColumnOf(col *model.ColumnInfo, name string, transform string) string {
+	for _, t := range strings.Split(transform, ",") {
+		if t == config.ColumnTransformSetValue {
+			name = setValueExpr(name, col.Elems)
+			continue
+		}
+		name = transformColumn(name, t)
+	}
+	return name
+}
+
+// setValueExpr returns the expression of the SET value `name` whose members are sorted in the order of `members`,
+// so the values are the same regardless of the orders of the members in the definitions. The empty set is the
+// empty string, and NULL is kept as NULL. e.g. SET('a','b') is
+// IF(`s` IS NULL, NULL, CONCAT_WS(',', IF(FIND_IN_SET('a', `s`) > 0, 'a', NULL), IF(FIND_IN_SET('b', `s`) > 0, 'b', NULL))).
+func setValueExpr(name string, members []string) string {
+	if len(members) == 0 {
+		return name
+	}
+	values := make([]string, 0, len(members))
+	for _, member := range members {
+		member = quoteValue(member)
+		values = append(values, fmt.Sprintf("IF(FIND_IN_SET(%s, %s) > 0, %s, NULL)", member, name, member))
+	}
+	return fmt.Sprintf("IF(%s IS NULL, NULL, CONCAT_WS(',', %s))", name, strings.Join(values, ", "))
+}
+
+// EnumIndexValue converts the stored index of the ENUM or SET value selected by `config.ColumnTransformEnumIndex`
+// back to the value by the members of the column definition where it's stored. The index of ENUM is 1-based and 0 is
+// the invalid value `''`, and the index of SET is the bits of the members, e.g. 5 is 'a,c' of SET('a','b','c').
+func EnumIndexValue(tp byte, members []string, index string) (string, error) {
+	n, err := strconv.ParseUint(index, 10, 64)
 	if err != nil {
-		log.Warn("execute checksum query fail", zap.String("query", query), zap.Reflect("args", args), zap.Error(err))
-		return -1, -1, errors.Trace(err)
+		return "", errors.Annotatef(err, "invalid index %s", index)
 	}
-	if !count.Valid || !checksum.Valid {
-		// if don't have any data, the checksum will be `NULL`
-		log.Warn("get empty count or checksum", zap.String("sql", query), zap.Reflect("args", args))
-		return 0, 0, nil
+	if tp == mysql.TypeEnum {
+		if n > uint64(len(members)) {
+			return "", errors.Errorf("index %d is out of the %d members", n, len(members))
+		}
+		if n == 0 {
+			return "", nil
+		}
+		return members[n-1], nil
+	}
+	values := make([]string, 0, len(members))
+	for i, member := range members {
+		if n&(1<<uint(i)) != 0 {
+			values = append(values, member)
+			n &^= 1 << uint(i)
+		}
+	}
+	if n != 0 {
+		return "", errors.Errorf("index %s has the bits out of the %d members", index, len(members))
+	}
+	return strings.Join(values, ","), nil
+}
+
+// quoteValue quotes the string value in the sql, the backslashes and the single quotes are escaped.
+func quoteValue(s string) string {
+	return "'" + strings.ReplaceAll(strings.ReplaceAll(s, "\\", "\\\\"), "'", "\\'") + "'"
+}
+
+func transformColumn(name string, transform string) string {
+	switch transform {
+	case config.ColumnTransformLower:
+		return fmt.Sprintf("LOWER(%s)", name)
+	case config.ColumnTransformTrim:
+		return fmt.Sprintf("TRIM(%s)", name)
+	case config.ColumnTransformUnhex:
+		return fmt.Sprintf("UNHEX(%s)", name)
+	case config.ColumnTransformMaskLast4:
+		return fmt.Sprintf("CONCAT(REPEAT('*', GREATEST(CHAR_LENGTH(%s) - 4, 0)), RIGHT(%s, 4))", name, name)
+	case config.ColumnTransformRTrim:
+		return fmt.Sprintf("RTRIM(%s)", name)
+	case config.ColumnTransformEnumValue:
+		// the string of the member in the explicit charset rather than the stored index, which is also used to
+		// order the rows if the column is an order key. The rows are merged by comparing the order keys bytewise,
+		// so they are ordered by the binary collation rather than the case-insensitive default one of utf8mb4.
+		return fmt.Sprintf("CONVERT(%s USING utf8mb4) COLLATE utf8mb4_bin", name)
+	case config.ColumnTransformEnumIndex:
+		// the invalid ENUM value is 0, and the empty SET is 0.
+		return fmt.Sprintf("(%s+0)", name)
+	case config.ColumnTransformGeometryWKB:
+		return geometryExpr(name)
+	case config.ColumnTransformUTF8MB4:
+		return fmt.Sprintf("CONVERT(%s USING utf8mb4)", name)
+	case config.ColumnTransformChar:
+		return fmt.Sprintf("CAST(%s AS CHAR)", name)
+	default:
+		return name
 	}
+}
 
-	return count.Int64, checksum.Int64, nil
+// GetPaddedCharColumns returns the names of the CHAR and VARCHAR columns compared by the CHAR semantics,
+// whose trailing spaces are ignored, the binary columns like BINARY and VARBINARY are excluded.
+func GetPaddedCharColumns(tableInfo *model.TableInfo) []string {
+	columns := make([]string, 0)
+	for _, col := range tableInfo.Columns {
+		switch col.Tp {
+		case mysql.TypeString, mysql.TypeVarchar, mysql.TypeVarString:
+			if col.Charset != charset.CharsetBin {
+				columns = append(columns, col.Name.O)
+			}
+		}
+	}
+	return columns
 }
 
 // ResetColumns removes index from `tableInfo.Indices`, whose columns appear in `columns`.
@@ -743,6 +1091,15 @@ func ResetColumns(tableInfo *model.TableInfo, columns []string) (*model.TableInf
 	return tableInfo, hasTimeStampType
 }
 
+// TableNameWithPartition returns the table name with the partition selection, e.g. "`schema`.`table` PARTITION (`p0`)",
+// or the table name only if `partition` is empty.
+func TableNameWithPartition(schema, table, partition string) string {
+	if len(partition) == 0 {
+		return dbutil.TableName(schema, table)
+	}
+	return fmt.Sprintf("%s PARTITION (%s)", dbutil.TableName(schema, table), dbutil.QuoteName(partition))
+}
+
 // UniqueID returns `schema:table`
 func UniqueID(schema string, table string) string {
 	return schema + ":" + table
@@ -775,6 +1132,55 @@ func GetBetterIndex(ctx context.Context, db *sql.DB, schema, table string, table
 	return indices, nil
 }
 
+// ParseIndexFields parses the comma separated `index-fields`, the empty fields are ignored.
+func ParseIndexFields(fields string) []string {
+	parsed := make([]string, 0, 2)
+	for _, field := range strings.Split(fields, ",") {
+		if field = strings.TrimSpace(field); len(field) > 0 {
+			parsed = append(parsed, field)
+		}
+	}
+	return parsed
+}
+
+// FindIndexByFields returns the index to split the chunks specified by `index-fields`, which is the name of an index,
+// or the columns being the leading columns of an index. It returns nil if the columns are not indexed,
+// and returns error if any field is neither an index nor a column.
+func FindIndexByFields(tableInfo *model.TableInfo, fields []string) (*model.IndexInfo, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	indices := dbutil.FindAllIndex(tableInfo)
+	if len(fields) == 1 {
+		for _, index := range indices {
+			if strings.EqualFold(index.Name.O, fields[0]) {
+				return index, nil
+			}
+		}
+	}
+	for _, field := range fields {
+		if dbutil.FindColumnByName(tableInfo.Columns, field) == nil {
+			return nil, errors.NotFoundf("index or column %s in table %s", field, tableInfo.Name.O)
+		}
+	}
+	for _, index := range indices {
+		if len(index.Columns) < len(fields) {
+			continue
+		}
+		matched := true
+		for i, field := range fields {
+			if !strings.EqualFold(index.Columns[i].Name.O, field) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return index, nil
+		}
+	}
+	return nil, nil
+}
+
 // GetSelectivity returns the value of `COUNT(DISTINCT col)/COUNT(1)` SQL.
 func GetSelectivity(ctx context.Context, db *sql.DB, schemaName, tableName, columnName string, tbInfo *model.TableInfo) (float64, error) {
 	query := fmt.Sprintf("SELECT COUNT(DISTINCT %s)/COUNT(1) as SEL FROM %s;", dbutil.ColumnName(columnName), dbutil.TableName(schemaName, tableName))
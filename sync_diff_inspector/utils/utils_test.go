@@ -16,7 +16,12 @@ package utils
 import (
 	"context"
 	"database/sql/driver"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -25,6 +30,7 @@ import (
 	"github.com/pingcap/tidb-tools/sync_diff_inspector/chunk"
 	"github.com/pingcap/tidb/parser"
 	"github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
 	"github.com/stretchr/testify/require"
 )
 
@@ -83,7 +89,7 @@ func TestBasicTableUtilOperation(t *testing.T) {
 	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
 	require.NoError(t, err)
 
-	query, orderKeyCols := GetTableRowsQueryFormat("test", "test", tableInfo, "123")
+	query, orderKeyCols := GetTableRowsQueryFormat("test", "test", "", tableInfo, nil, "123")
 	require.Equal(t, query, "SELECT /*!40001 SQL_NO_CACHE */ `a`, `b`, `c`, `d` FROM `test`.`test` WHERE %s ORDER BY `a`,`b` COLLATE \"123\"")
 	expectName := []string{"a", "b"}
 	for i, col := range orderKeyCols {
@@ -148,6 +154,22 @@ func TestBasicTableUtilOperation(t *testing.T) {
 			"*/\n"+
 			"REPLACE INTO `schema`.`test`(`a`,`b`,`c`,`d`) VALUES (1,'a',1.22,'sdf');")
 	require.Equal(t, GenerateDeleteDML(data1, tableInfo, "schema"), "DELETE FROM `schema`.`test` WHERE `a` = 1 AND `b` = 'a' AND `c` = 1.22 AND `d` = 'sdf' LIMIT 1;")
+	require.Equal(t, GenerateInsertDML(data1, tableInfo, "schema"), "INSERT INTO `schema`.`test`(`a`,`b`,`c`,`d`) VALUES (1,'a',1.22,'sdf');")
+	require.Equal(t, GenerateInsertOnDuplicateDML(data1, tableInfo, "schema"),
+		"INSERT INTO `schema`.`test`(`a`,`b`,`c`,`d`) VALUES (1,'a',1.22,'sdf') ON DUPLICATE KEY UPDATE `a` = VALUES(`a`),`b` = VALUES(`b`),`c` = VALUES(`c`),`d` = VALUES(`d`);")
+	annotation := "/*\n" +
+		"  DIFF COLUMNS ╏ `B` ╏ `C`   \n" +
+		"╍╍╍╍╍╍╍╍╍╍╍╍╍╍╍╋╍╍╍╍╍╋╍╍╍╍╍╍╍\n" +
+		"  source data  ╏ 'a' ╏ 1.22  \n" +
+		"╍╍╍╍╍╍╍╍╍╍╍╍╍╍╍╋╍╍╍╍╍╋╍╍╍╍╍╍╍\n" +
+		"  target data  ╏ 'b' ╏ 2.22  \n" +
+		"╍╍╍╍╍╍╍╍╍╍╍╍╍╍╍╋╍╍╍╍╍╋╍╍╍╍╍╍╍\n" +
+		"*/\n"
+	require.Equal(t, GenerateInsertOnDuplicateDMLWithAnnotation(data1, data2, tableInfo, "schema"),
+		annotation+"INSERT INTO `schema`.`test`(`a`,`b`,`c`,`d`) VALUES (1,'a',1.22,'sdf') ON DUPLICATE KEY UPDATE `a` = VALUES(`a`),`b` = VALUES(`b`),`c` = VALUES(`c`),`d` = VALUES(`d`);")
+	require.Equal(t, GenerateDeleteInsertDMLWithAnnotation(data1, data2, tableInfo, "schema"),
+		annotation+"DELETE FROM `schema`.`test` WHERE `a` = 1 AND `b` = 'b' AND `c` = 2.22 AND `d` = 'sdf' LIMIT 1;\n"+
+			"INSERT INTO `schema`.`test`(`a`,`b`,`c`,`d`) VALUES (1,'a',1.22,'sdf');")
 
 	// same
 	equal, cmp, err := CompareData(data1, data1, orderKeyCols, columns)
@@ -243,12 +265,331 @@ func TestGetCountAndCRC32Checksum(t *testing.T) {
 
 	mock.ExpectQuery("SELECT COUNT.*FROM `test_schema`\\.`test_table` WHERE \\[23 45\\].*").WithArgs("123", "234").WillReturnRows(sqlmock.NewRows([]string{"CNT", "CHECKSUM"}).AddRow(123, 456))
 
-	count, checksum, err := GetCountAndCRC32Checksum(ctx, conn, "test_schema", "test_table", tableInfo, "[23 45]", []interface{}{"123", "234"})
+	count, checksum, err := GetCountAndCRC32Checksum(ctx, conn, "test_schema", "test_table", "", tableInfo, nil, "[23 45]", []interface{}{"123", "234"})
 	require.NoError(t, err)
 	require.Equal(t, count, int64(123))
 	require.Equal(t, checksum, int64(456))
 }
 
+func TestGetDuplicateKeys(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	createTableSQL := "create table `test`.`test`(`a` int, `b` varchar(10), `c` int, primary key(`a`, `b`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+	_, keys := dbutil.SelectUniqueOrderKey(tableInfo)
+	require.Equal(t, "SELECT `a`, `b`, COUNT(*) AS cnt FROM `test_schema`.`test_table` WHERE `a` IS NOT NULL AND `b` IS NOT NULL AND (`c` > ?) "+
+		"GROUP BY `a`, `b` HAVING COUNT(*) > 1 LIMIT 10", GetDuplicateKeysSQL("test_schema", "test_table", keys, "`c` > ?", 10))
+	require.NotContains(t, GetDuplicateKeysSQL("test_schema", "test_table", keys, "", 10), "AND (")
+
+	mock.ExpectQuery("SELECT `a`, `b`, COUNT\\(\\*\\) AS cnt FROM `test_schema`\\.`test_table` .* LIMIT 10").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"a", "b", "cnt"}).AddRow(1, "x", 2).AddRow(2, "y", 3))
+	duplicateKeys, err := GetDuplicateKeys(ctx, conn, "test_schema", "test_table", keys, "`c` > ?", []interface{}{1}, 10)
+	require.NoError(t, err)
+	require.Equal(t, []*DuplicateKey{{Values: []string{"1", "x"}, Rows: 2}, {Values: []string{"2", "y"}, Rows: 3}}, duplicateKeys)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetIndexCountAndCRC32Checksum(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	createTableSQL := "create table `test`.`test`(`a` int, `c` float, `b` varchar(10), `d` datetime, primary key(`a`, `b`), key `idx_cd`(`c`, `d`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+	index := FindIndexByName(tableInfo, "IDX_CD")
+	require.NotNil(t, index)
+	require.Nil(t, FindIndexByName(tableInfo, "idx_b"))
+	// only the columns of the index are in the checksum.
+	require.Equal(t, "SELECT COUNT(*) as CNT, BIT_XOR(CAST(CRC32(CONCAT_WS(',', round(`c`, 5-floor(log10(abs(`c`)))), `d`, "+
+		"CONCAT(ISNULL(round(`c`, 5-floor(log10(abs(`c`))))), ISNULL(`d`))))AS UNSIGNED)) as CHECKSUM "+
+		"FROM `test_schema`.`test_table` PARTITION (`p0`) FORCE INDEX (`idx_cd`) WHERE `a` > ?;",
+		GetIndexCountAndCRC32ChecksumSQL("test_schema", "test_table", "p0", tableInfo, index, nil, "`a` > ?"))
+
+	mock.ExpectQuery("SELECT COUNT.*FROM `test_schema`\\.`test_table` FORCE INDEX \\(`idx_cd`\\) WHERE `a` > \\?").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"CNT", "CHECKSUM"}).AddRow(123, 456))
+	count, checksum, err := GetIndexCountAndCRC32Checksum(ctx, conn, "test_schema", "test_table", "", tableInfo, index, nil, "`a` > ?", []interface{}{1})
+	require.NoError(t, err)
+	require.Equal(t, int64(123), count)
+	require.Equal(t, int64(456), checksum)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHumanizeBytes(t *testing.T) {
+	require.Equal(t, "0B", HumanizeBytes(0))
+	require.Equal(t, "1023B", HumanizeBytes(1023))
+	require.Equal(t, "1KB", HumanizeBytes(1024))
+	require.Equal(t, "1.5MB", HumanizeBytes(1536*1024))
+	require.Equal(t, "52GB", HumanizeBytes(52<<30))
+	require.Equal(t, "1.3TB", HumanizeBytes(1300<<30))
+	require.Equal(t, "2048PB", HumanizeBytes(2048<<50))
+	require.Equal(t, "-1.5KB", HumanizeBytes(-1536))
+}
+
+func TestHumanizeCount(t *testing.T) {
+	require.Equal(t, "0", HumanizeCount(0))
+	require.Equal(t, "999", HumanizeCount(999))
+	require.Equal(t, "1,000", HumanizeCount(1000))
+	require.Equal(t, "123,456", HumanizeCount(123456))
+	require.Equal(t, "1,234,567", HumanizeCount(1234567))
+	require.Equal(t, "-1,234", HumanizeCount(-1234))
+}
+
+func TestColumnTransforms(t *testing.T) {
+	createTableSQL := "create table `test`.`test`(`a` int, `b` varchar(10), `c` varchar(20), `d` char(10), primary key(`a`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+	columnTransforms := map[string]string{
+		"b": "lower",
+		"c": "mask_last4",
+		"d": "trim",
+	}
+
+	query, _ := GetTableRowsQueryFormat("test", "test", "", tableInfo, columnTransforms, "")
+	require.Equal(t, "SELECT /*!40001 SQL_NO_CACHE */ `a`, LOWER(`b`) AS `b`, CONCAT(REPEAT('*', GREATEST(CHAR_LENGTH(`c`) - 4, 0)), RIGHT(`c`, 4)) AS `c`, TRIM(`d`) AS `d` FROM `test`.`test` WHERE %s ORDER BY `a`", query)
+
+	query = GetCountAndCRC32ChecksumSQL("test", "test", "", tableInfo, columnTransforms, "TRUE")
+	require.Equal(t, "SELECT COUNT(*) as CNT, BIT_XOR(CAST(CRC32(CONCAT_WS(',', `a`, LOWER(`b`), CONCAT(REPEAT('*', GREATEST(CHAR_LENGTH(`c`) - 4, 0)), RIGHT(`c`, 4)), "+
+		"TRIM(`d`), CONCAT(ISNULL(`a`), ISNULL(LOWER(`b`)), ISNULL(CONCAT(REPEAT('*', GREATEST(CHAR_LENGTH(`c`) - 4, 0)), RIGHT(`c`, 4))), ISNULL(TRIM(`d`)))))AS UNSIGNED)) as CHECKSUM FROM `test`.`test` WHERE TRUE;", query)
+
+	require.Equal(t, "UNHEX(`a`)", TransformColumn("`a`", "unhex"))
+	require.Equal(t, "`a`", TransformColumn("`a`", "unknown"))
+	require.Equal(t, "RTRIM(LOWER(`a`))", TransformColumn("`a`", "lower,rtrim"))
+	require.Equal(t, "RTRIM(CAST(`a` AS CHAR))", TransformColumn("`a`", "char,rtrim"))
+
+	// the values differing only in the trailing spaces are the same after `rtrim`.
+	query, _ = GetTableRowsQueryFormat("test", "test", "", tableInfo, map[string]string{"b": "rtrim", "d": "rtrim"}, "")
+	require.Equal(t, "SELECT /*!40001 SQL_NO_CACHE */ `a`, RTRIM(`b`) AS `b`, `c`, RTRIM(`d`) AS `d` FROM `test`.`test` WHERE %s ORDER BY `a`", query)
+
+	// the SET values are sorted in the order of the members, and the ENUM and SET values are compared by index with `enum-index`.
+	createTableSQL = "create table `test`.`test`(`a` int, `b` set('x','y''s'), `c` enum('x','y'), primary key(`a`))"
+	tableInfo, err = dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+	query, _ = GetTableRowsQueryFormat("test", "test", "", tableInfo, map[string]string{"b": "set-value", "c": "enum-index"}, "")
+	require.Equal(t, "SELECT /*!40001 SQL_NO_CACHE */ `a`, IF(`b` IS NULL, NULL, CONCAT_WS(',', IF(FIND_IN_SET('x', `b`) > 0, 'x', NULL), "+
+		"IF(FIND_IN_SET('y\\'s', `b`) > 0, 'y\\'s', NULL))) AS `b`, (`c`+0) AS `c` FROM `test`.`test` WHERE %s ORDER BY `a`", query)
+	require.Equal(t, "(`b`+0)", TransformColumn("`b`", "enum-index"))
+	// the ENUM values are compared and ordered by the member strings in utf8mb4 with `enum-value`, in the binary
+	// collation which the order keys are compared by.
+	query, _ = GetTableRowsQueryFormat("test", "test", "", tableInfo, map[string]string{"b": "set-value", "c": "enum-value"}, "")
+	require.Contains(t, query, "CONVERT(`c` USING utf8mb4) COLLATE utf8mb4_bin AS `c` FROM `test`.`test` WHERE %s ORDER BY `a`")
+	require.Contains(t, GetCountAndCRC32ChecksumSQL("test", "test", "", tableInfo, map[string]string{"b": "set-value", "c": "enum-value"}, "TRUE"),
+		"IF(FIND_IN_SET('y\\'s', `b`) > 0, 'y\\'s', NULL))), CONVERT(`c` USING utf8mb4) COLLATE utf8mb4_bin, CONCAT(ISNULL(`a`)")
+	// `set-value` depends on the members of the column.
+	require.Equal(t, "`b`", TransformColumn("`b`", "set-value"))
+}
+
+func TestCompareEnumMembers(t *testing.T) {
+	newTableInfo := func(createTableSQL string) *model.TableInfo {
+		tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+		require.NoError(t, err)
+		return tableInfo
+	}
+	upstream := newTableInfo("create table `test`(`a` int, `b` enum('x','y','z'), `c` set('x','y'), `d` enum('x','y'), primary key(`a`))")
+
+	// the same members in the same order
+	downstream := newTableInfo("create table `test`(`a` int, `b` enum('x','y','z'), `c` set('x','y'), `d` enum('x','y'), primary key(`a`))")
+	reordered, isEqual := CompareEnumMembers([]*model.TableInfo{upstream}, downstream)
+	require.True(t, isEqual)
+	require.Empty(t, reordered)
+
+	// the same members in different orders
+	downstream = newTableInfo("create table `test`(`a` int, `c` set('y','x'), `b` enum('z','x','y'), `d` enum('x','y'), primary key(`a`))")
+	reordered, isEqual = CompareEnumMembers([]*model.TableInfo{upstream, upstream}, downstream)
+	require.True(t, isEqual)
+	require.Equal(t, []string{"c", "b"}, reordered)
+
+	// the different members, and the different types are left to the struct check
+	downstream = newTableInfo("create table `test`(`a` int, `b` enum('z','x'), `c` set('y','x'), `d` set('x','y'), primary key(`a`))")
+	reordered, isEqual = CompareEnumMembers([]*model.TableInfo{upstream}, downstream)
+	require.False(t, isEqual)
+	require.Equal(t, []string{"c"}, reordered)
+}
+
+func TestEnumIndexValue(t *testing.T) {
+	// the members are reordered on the target, so the same index is the different value on both sides.
+	sourceMembers, targetMembers := []string{"x", "y", "z"}, []string{"z", "x", "y"}
+	value, err := EnumIndexValue(mysql.TypeEnum, sourceMembers, "2")
+	require.NoError(t, err)
+	require.Equal(t, "y", value)
+	value, err = EnumIndexValue(mysql.TypeEnum, targetMembers, "2")
+	require.NoError(t, err)
+	require.Equal(t, "x", value)
+	// the invalid ENUM value
+	value, err = EnumIndexValue(mysql.TypeEnum, sourceMembers, "0")
+	require.NoError(t, err)
+	require.Equal(t, "", value)
+	_, err = EnumIndexValue(mysql.TypeEnum, sourceMembers, "4")
+	require.Error(t, err)
+	_, err = EnumIndexValue(mysql.TypeEnum, sourceMembers, "y")
+	require.Error(t, err)
+
+	// the SET value of multiple members is the bits of them, which are in the order of the members.
+	value, err = EnumIndexValue(mysql.TypeSet, sourceMembers, "5")
+	require.NoError(t, err)
+	require.Equal(t, "x,z", value)
+	value, err = EnumIndexValue(mysql.TypeSet, targetMembers, "7")
+	require.NoError(t, err)
+	require.Equal(t, "z,x,y", value)
+	value, err = EnumIndexValue(mysql.TypeSet, sourceMembers, "0")
+	require.NoError(t, err)
+	require.Equal(t, "", value)
+	_, err = EnumIndexValue(mysql.TypeSet, sourceMembers, "8")
+	require.Error(t, err)
+}
+
+func TestGeometryColumns(t *testing.T) {
+	createTableSQL := "CREATE TABLE `test` (\n" +
+		"  `a` int(11) NOT NULL,\n" +
+		"  `b` point DEFAULT NULL,\n" +
+		"  `c` polygon NOT NULL /*!80003 SRID 4326 */,\n" +
+		"  PRIMARY KEY (`a`),\n" +
+		"  SPATIAL KEY `c` (`c`)\n" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"
+	tableInfo, err := GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+	require.Equal(t, []string{"b", "c"}, GetGeometryColumns(tableInfo))
+	require.Len(t, tableInfo.Indices, 1)
+	require.True(t, tableInfo.Columns[2].Flag&mysql.NotNullFlag > 0)
+
+	query := GetCountAndCRC32ChecksumSQL("test", "test", "", tableInfo, map[string]string{"b": "geometry-wkb", "c": "geometry-wkb"}, "TRUE")
+	require.Equal(t, "SELECT COUNT(*) as CNT, BIT_XOR(CAST(CRC32(CONCAT_WS(',', `a`, CONCAT(ST_SRID(`b`), ':', ST_AsBinary(`b`)), CONCAT(ST_SRID(`c`), ':', ST_AsBinary(`c`)), "+
+		"CONCAT(ISNULL(`a`), ISNULL(CONCAT(ST_SRID(`b`), ':', ST_AsBinary(`b`))), ISNULL(CONCAT(ST_SRID(`c`), ':', ST_AsBinary(`c`))))))AS UNSIGNED)) as CHECKSUM FROM `test`.`test` WHERE TRUE;", query)
+
+	// POINT(1 2) and POLYGON((0 0,1 0,1 1,0 0)) in WKB
+	pointWKB, err := hex.DecodeString("0101000000000000000000f03f0000000000000040")
+	require.NoError(t, err)
+	polygonWKB, err := hex.DecodeString("010300000001000000040000000000000000000000000000000000000000000000000000000000f03f0000000000000000000000000000f03f000000000000f03f00000000000000000000000000000000")
+	require.NoError(t, err)
+	point := append([]byte("0:"), pointWKB...)
+	polygon := append([]byte("4326:"), polygonWKB...)
+	rowData := map[string]*dbutil.ColumnData{
+		"a": {Data: []byte("1"), IsNull: false},
+		"b": {Data: point, IsNull: false},
+		"c": {Data: polygon, IsNull: false},
+	}
+	require.Equal(t, "REPLACE INTO `test`.`test`(`a`,`b`,`c`) VALUES (1,ST_GeomFromWKB(x'0101000000000000000000f03f0000000000000040', 0),"+
+		"ST_GeomFromWKB(x'010300000001000000040000000000000000000000000000000000000000000000000000000000f03f0000000000000000000000000000f03f000000000000f03f00000000000000000000000000000000', 4326));",
+		GenerateReplaceDML(rowData, tableInfo, "test"))
+	rowData["b"] = &dbutil.ColumnData{Data: nil, IsNull: true}
+	rowData["c"] = &dbutil.ColumnData{Data: []byte{0x01, 0x02}, IsNull: false}
+	require.Equal(t, "REPLACE INTO `test`.`test`(`a`,`b`,`c`) VALUES (1,NULL,x'0102');", GenerateReplaceDML(rowData, tableInfo, "test"))
+
+	// the values of the same WKB but the different SRIDs
+	source := map[string]*dbutil.ColumnData{
+		"b": {Data: point},
+		"c": {Data: polygon},
+	}
+	target := map[string]*dbutil.ColumnData{
+		"b": {Data: append([]byte("4326"), point[1:]...)},
+		"c": {Data: append([]byte("0"), polygon[4:]...)},
+	}
+	require.Equal(t, []string{"b", "c"}, GetSRIDMismatchColumns(source, target, tableInfo))
+	target["c"] = &dbutil.ColumnData{Data: append([]byte("0:"), point[2:]...)}
+	require.Equal(t, []string{"b"}, GetSRIDMismatchColumns(source, target, tableInfo))
+}
+
+func TestGetPaddedCharColumns(t *testing.T) {
+	createTableSQL := "create table `test`.`test`(`a` int, `b` char(10), `c` varchar(20), `d` varchar(20) charset latin1, " +
+		"`e` binary(10), `f` varbinary(20), `g` text, `h` char(10) charset binary, primary key(`a`))"
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+	require.Equal(t, []string{"b", "c", "d"}, GetPaddedCharColumns(tableInfo))
+
+	createTableSQL = "create table `test`.`test`(`a` int, `b` blob, primary key(`a`))"
+	tableInfo, err = dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+	require.Empty(t, GetPaddedCharColumns(tableInfo))
+}
+
+func TestGetServerVersion(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	mock.ExpectQuery("SELECT version\\(\\)").WillReturnRows(sqlmock.NewRows([]string{"version()"}).AddRow("8.0.25"))
+	version, tidbVersion, err := GetServerVersion(ctx, conn)
+	require.NoError(t, err)
+	require.Equal(t, "8.0.25", version)
+	require.Equal(t, "", tidbVersion)
+
+	mock.ExpectQuery("SELECT version\\(\\)").WillReturnRows(sqlmock.NewRows([]string{"version()"}).AddRow("5.7.25-TiDB-v5.3.0"))
+	mock.ExpectQuery("SELECT tidb_version\\(\\)").WillReturnRows(sqlmock.NewRows([]string{"tidb_version()"}).AddRow("Release Version: v5.3.0\nEdition: Community"))
+	version, tidbVersion, err = GetServerVersion(ctx, conn)
+	require.NoError(t, err)
+	require.Equal(t, "5.7.25-TiDB-v5.3.0", version)
+	require.Equal(t, "Release Version: v5.3.0\nEdition: Community", tidbVersion)
+
+	// the version is still returned if `tidb_version()` fails.
+	mock.ExpectQuery("SELECT version\\(\\)").WillReturnRows(sqlmock.NewRows([]string{"version()"}).AddRow("5.7.25-TiDB-v5.3.0"))
+	mock.ExpectQuery("SELECT tidb_version\\(\\)").WillReturnError(errors.New("FUNCTION tidb_version does not exist"))
+	version, tidbVersion, err = GetServerVersion(ctx, conn)
+	require.NoError(t, err)
+	require.Equal(t, "5.7.25-TiDB-v5.3.0", version)
+	require.Equal(t, "", tidbVersion)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAutoIncrementSettings(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	mock.ExpectQuery("SELECT @@auto_increment_increment, @@auto_increment_offset").WillReturnRows(
+		sqlmock.NewRows([]string{"@@auto_increment_increment", "@@auto_increment_offset"}).AddRow(2, 1))
+	increment, offset, err := GetAutoIncrementSettings(ctx, conn)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), increment)
+	require.Equal(t, int64(1), offset)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	require.False(t, AutoIncrementDisjoint(1, 1, 1, 1))
+	require.False(t, AutoIncrementDisjoint(2, 1, 1, 1))
+	require.True(t, AutoIncrementDisjoint(2, 1, 2, 2))
+	require.False(t, AutoIncrementDisjoint(2, 1, 3, 2))
+	require.True(t, AutoIncrementDisjoint(4, 2, 6, 1))
+	require.False(t, AutoIncrementDisjoint(4, 3, 6, 1))
+	// the offset greater than the increment is ignored.
+	require.False(t, AutoIncrementDisjoint(2, 3, 2, 1))
+	require.False(t, AutoIncrementDisjoint(0, 0, 2, 2))
+}
+
+func TestGetTableRowsEstimate(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	mock.ExpectQuery("SELECT table_rows FROM `information_schema`.`tables`").WithArgs("test", "t").WillReturnRows(sqlmock.NewRows([]string{"table_rows"}).AddRow(100))
+	rows, err := GetTableRowsEstimate(ctx, conn, "test", "t")
+	require.NoError(t, err)
+	require.Equal(t, int64(100), rows)
+
+	// the table is not analyzed.
+	mock.ExpectQuery("SELECT table_rows FROM `information_schema`.`tables`").WithArgs("test", "t").WillReturnRows(sqlmock.NewRows([]string{"table_rows"}).AddRow(nil))
+	rows, err = GetTableRowsEstimate(ctx, conn, "test", "t")
+	require.NoError(t, err)
+	require.Equal(t, int64(0), rows)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestGetApproximateMid(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
@@ -264,10 +605,18 @@ func TestGetApproximateMid(t *testing.T) {
 	rows := sqlmock.NewRows([]string{"a", "b"}).AddRow("5", "10")
 	mock.ExpectQuery("SELECT `a`, `b` FROM `test`\\.`test_utils` WHERE 2222.* LIMIT 1 OFFSET 10*").WithArgs("aaaa").WillReturnRows(rows)
 
-	data, err := GetApproximateMidBySize(ctx, conn, "test", "test_utils", tableInfo.Columns, "2222", []interface{}{"aaaa"}, 20)
+	data, err := GetApproximateMidBySize(ctx, conn, "test", "test_utils", "", tableInfo.Columns, "2222", []interface{}{"aaaa"}, 20)
 	require.NoError(t, err)
 	require.Equal(t, data["a"], "5")
 	require.Equal(t, data["b"], "10")
+
+	// the rows are selected from the partition.
+	rows = sqlmock.NewRows([]string{"a", "b"}).AddRow("6", "11")
+	mock.ExpectQuery("SELECT `a`, `b` FROM `test`\\.`test_utils` PARTITION \\(`p1`\\) WHERE 2222.* LIMIT 1 OFFSET 10*").WithArgs("aaaa").WillReturnRows(rows)
+	data, err = GetApproximateMidBySize(ctx, conn, "test", "test_utils", "p1", tableInfo.Columns, "2222", []interface{}{"aaaa"}, 20)
+	require.NoError(t, err)
+	require.Equal(t, data["a"], "6")
+	require.Equal(t, data["b"], "11")
 }
 
 func TestGenerateSQLs(t *testing.T) {
@@ -344,25 +693,119 @@ func TestResetColumns(t *testing.T) {
 	require.Equal(t, len(tbInfo.Indices), 1)
 }
 
-func TestGetTableSize(t *testing.T) {
+func TestHasUniqueKey(t *testing.T) {
+	createTableSQLs := []string{
+		"CREATE TABLE `test`.`atest` (`a` int, `b` int, primary key(`a`))",
+		"CREATE TABLE `test`.`atest` (`a` varchar(10), `b` int, primary key(`a`))",
+		"CREATE TABLE `test`.`atest` (`a` int, `b` int, unique key uk(`b`))",
+		"CREATE TABLE `test`.`atest` (`a` int, `b` int, index idx(`b`))",
+		"CREATE TABLE `test`.`atest` (`a` int, `b` int)",
+	}
+	expected := []bool{true, true, true, false, false}
+	for i, createTableSQL := range createTableSQLs {
+		tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+		require.NoError(t, err)
+		require.Equal(t, expected[i], HasUniqueKey(tableInfo))
+	}
+
+	// the primary key is ignored
+	tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQLs[0], parser.New())
+	require.NoError(t, err)
+	tableInfo, _ = ResetColumns(tableInfo, []string{"a"})
+	require.False(t, HasUniqueKey(tableInfo))
+}
+
+func TestGetTableSizes(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	conn, mock, err := sqlmock.New()
 	require.NoError(t, err)
 	defer conn.Close()
-	dataRows := sqlmock.NewRows([]string{"a", "b"})
-	rowNums := 1000
-	for k := 0; k < rowNums; k++ {
-		str := fmt.Sprintf("%d", k)
-		dataRows.AddRow(str, str)
-	}
-	sizeRows := sqlmock.NewRows([]string{"data"})
-	sizeRows.AddRow("8000")
-	mock.ExpectQuery("data").WillReturnRows(sizeRows)
-	size, err := GetTableSize(ctx, conn, "test", "test")
+
+	// the tables are queried in batches, and the tables not found aren't in the result.
+	defer func(batchSize int) { tableSizeBatchSize = batchSize }(tableSizeBatchSize)
+	tableSizeBatchSize = 2
+	mock.ExpectQuery("select table_name, sum\\(data_length\\).*table_name in \\(\\?,\\?\\)").WithArgs("test", "a", "b").
+		WillReturnRows(sqlmock.NewRows([]string{"table_name", "data"}).AddRow("a", "8000").AddRow("b", nil))
+	mock.ExpectQuery("select table_name, sum\\(data_length\\).*table_name in \\(\\?\\)").WithArgs("test", "c").
+		WillReturnRows(sqlmock.NewRows([]string{"table_name", "data"}))
+	sizes, err := GetTableSizes(ctx, conn, "test", []string{"a", "b", "c"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{"a": 8000, "b": 0}, sizes)
+
+	// the sizes are got by SHOW TABLE STATUS if no table has a positive size in information_schema.
+	mock.ExpectQuery("select table_name, sum\\(data_length\\)").WithArgs("test", "a", "b").
+		WillReturnRows(sqlmock.NewRows([]string{"table_name", "data"}).AddRow("a", "0"))
+	mock.ExpectQuery("SHOW TABLE STATUS FROM `test`").
+		WillReturnRows(sqlmock.NewRows([]string{"Name", "Engine", "Data_length"}).AddRow("a", "InnoDB", "16384").AddRow("b", "InnoDB", "32768").AddRow("v", nil, nil))
+	sizes, err = GetTableSizes(ctx, conn, "test", []string{"a", "b"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{"a": 16384, "b": 32768}, sizes)
+
+	// the zero sizes are returned if SHOW TABLE STATUS fails.
+	mock.ExpectQuery("select table_name, sum\\(data_length\\)").WithArgs("test", "a").
+		WillReturnRows(sqlmock.NewRows([]string{"table_name", "data"}).AddRow("a", "0"))
+	mock.ExpectQuery("SHOW TABLE STATUS FROM `test`").WillReturnError(errors.New("access denied"))
+	sizes, err = GetTableSizes(ctx, conn, "test", []string{"a"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{"a": 0}, sizes)
+
+	mock.ExpectQuery("select table_name, sum\\(data_length\\)").WithArgs("test", "a").WillReturnError(errors.New("connection refused"))
+	_, err = GetTableSizes(ctx, conn, "test", []string{"a"})
+	require.Error(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTableSizeCache(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// the registered tables of a schema are got by one query.
+	sizes := NewTableSizeCache(conn)
+	sizes.Register("test", "b", "a")
+	sizes.Register("other", "c")
+	mock.ExpectQuery("select table_name, sum\\(data_length\\)").WithArgs("test", "a", "b").
+		WillReturnRows(sqlmock.NewRows([]string{"table_name", "data"}).AddRow("a", "8000").AddRow("b", "16384"))
+	size, err := sizes.GetTableSize(ctx, "test", "b")
 	require.NoError(t, err)
-	require.Equal(t, size, int64(8000))
+	require.Equal(t, int64(16384), size)
+	size, err = sizes.GetTableSize(ctx, "test", "a")
+	require.NoError(t, err)
+	require.Equal(t, int64(8000), size)
+
+	// the failure is cached for all the tables of the query.
+	sizes.Register("other", "d")
+	mock.ExpectQuery("select table_name, sum\\(data_length\\)").WithArgs("other", "c", "d").WillReturnError(errors.New("connection refused"))
+	_, err = sizes.GetTableSize(ctx, "other", "c")
+	require.Error(t, err)
+	_, err = sizes.GetTableSize(ctx, "other", "d")
+	require.Error(t, err)
+
+	// the table not registered is got alone.
+	mock.ExpectQuery("select table_name, sum\\(data_length\\)").WithArgs("test", "e").
+		WillReturnRows(sqlmock.NewRows([]string{"table_name", "data"}).AddRow("e", "100"))
+	size, err = sizes.GetTableSize(ctx, "test", "e")
+	require.NoError(t, err)
+	require.Equal(t, int64(100), size)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// the sizes are got again after the context is canceled.
+	canceledCtx, cancelNow := context.WithCancel(ctx)
+	cancelNow()
+	sizes.Register("canceled", "f")
+	_, err = sizes.GetTableSize(canceledCtx, "canceled", "f")
+	require.Error(t, err)
+	mock.ExpectQuery("select table_name, sum\\(data_length\\)").WithArgs("canceled", "f").
+		WillReturnRows(sqlmock.NewRows([]string{"table_name", "data"}).AddRow("f", "200"))
+	size, err = sizes.GetTableSize(ctx, "canceled", "f")
+	require.NoError(t, err)
+	require.Equal(t, int64(200), size)
+	require.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestGetBetterIndex(t *testing.T) {
@@ -569,3 +1012,618 @@ func TestCompareStruct(t *testing.T) {
 	require.Equal(t, tableInfo.Indices[0].Name.O, "c")
 
 }
+
+func TestCompareStructByColumnSet(t *testing.T) {
+	createTableSQL := "create table `test`.`test`(`a` int, `b` varchar(10), `c` float, primary key(`a`), index(`c`))"
+	newTableInfo := func(createTableSQL string) *model.TableInfo {
+		tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+		require.NoError(t, err)
+		return tableInfo
+	}
+
+	isEqual, isPanic := CompareStructByColumnSet([]*model.TableInfo{newTableInfo(createTableSQL)}, newTableInfo(createTableSQL))
+	require.True(t, isEqual)
+	require.False(t, isPanic)
+
+	// column order different, non-breaking
+	createTableSQL2 := "create table `test`(`b` varchar(10), `a` int, `c` float, primary key(`a`), index(`c`))"
+	isEqual, isPanic = CompareStructByColumnSet([]*model.TableInfo{newTableInfo(createTableSQL2)}, newTableInfo(createTableSQL))
+	require.False(t, isEqual)
+	require.False(t, isPanic)
+	isEqual, isPanic = CompareStruct([]*model.TableInfo{newTableInfo(createTableSQL2)}, newTableInfo(createTableSQL))
+	require.False(t, isEqual)
+	require.True(t, isPanic)
+
+	// index different, non-breaking
+	createTableSQL2 = "create table `test`(`a` int, `b` varchar(10), `c` float, primary key(`a`))"
+	isEqual, isPanic = CompareStructByColumnSet([]*model.TableInfo{newTableInfo(createTableSQL2)}, newTableInfo(createTableSQL))
+	require.False(t, isEqual)
+	require.False(t, isPanic)
+
+	// column set different, breaking
+	createTableSQL2 = "create table `test`(`a` int, `bb` varchar(10), `c` float, primary key(`a`), index(`c`))"
+	isEqual, isPanic = CompareStructByColumnSet([]*model.TableInfo{newTableInfo(createTableSQL2)}, newTableInfo(createTableSQL))
+	require.False(t, isEqual)
+	require.True(t, isPanic)
+
+	// column type incompatible, breaking
+	createTableSQL2 = "create table `test`(`a` int, `b` varchar(10), `c` datetime, primary key(`a`), index(`c`))"
+	isEqual, isPanic = CompareStructByColumnSet([]*model.TableInfo{newTableInfo(createTableSQL2)}, newTableInfo(createTableSQL))
+	require.False(t, isEqual)
+	require.True(t, isPanic)
+}
+
+func TestMatchColumnsByName(t *testing.T) {
+	newTableInfo := func(createTableSQL string) *model.TableInfo {
+		tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+		require.NoError(t, err)
+		return tableInfo
+	}
+	columnNames := func(tableInfo *model.TableInfo) []string {
+		names := make([]string, 0, len(tableInfo.Columns))
+		for i, column := range tableInfo.Columns {
+			require.Equal(t, i, column.Offset)
+			names = append(names, column.Name.O)
+		}
+		return names
+	}
+	upstream := newTableInfo("create table `test`(`a` int, `c` float, `b` varchar(10), primary key(`a`), index idx_bc(`b`, `c`))")
+
+	// the same order
+	downstream := newTableInfo("create table `test`(`a` int, `c` float, `b` varchar(10), primary key(`a`), index idx_bc(`b`, `c`))")
+	require.False(t, MatchColumnsByName([]*model.TableInfo{upstream}, downstream))
+
+	// the column `c` is added in the middle on the upstream, but at the end on the downstream
+	downstream = newTableInfo("create table `test`(`a` int, `b` varchar(10), `c` float, primary key(`a`), index idx_bc(`b`, `c`))")
+	require.True(t, MatchColumnsByName([]*model.TableInfo{upstream}, downstream))
+	require.Equal(t, []string{"a", "c", "b"}, columnNames(downstream))
+	index, err := FindIndexByFields(downstream, []string{"idx_bc"})
+	require.NoError(t, err)
+	require.Equal(t, 2, index.Columns[0].Offset)
+	require.Equal(t, 1, index.Columns[1].Offset)
+	isEqual, isPanic := CompareStruct([]*model.TableInfo{upstream}, downstream)
+	require.True(t, isEqual)
+	require.False(t, isPanic)
+
+	// the column sets are different
+	downstream = newTableInfo("create table `test`(`a` int, `b` varchar(10), `d` float, primary key(`a`))")
+	require.False(t, MatchColumnsByName([]*model.TableInfo{upstream}, downstream))
+	require.Equal(t, []string{"a", "b", "d"}, columnNames(downstream))
+	downstream = newTableInfo("create table `test`(`a` int, `b` varchar(10), primary key(`a`))")
+	require.False(t, MatchColumnsByName([]*model.TableInfo{upstream}, downstream))
+}
+
+func TestGetStructChanges(t *testing.T) {
+	newTableInfo := func(createTableSQL string) *model.TableInfo {
+		tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+		require.NoError(t, err)
+		return tableInfo
+	}
+	upstream := newTableInfo("create table `t_1`(`id` int not null, `name` varchar(20), `c` text, `d` int, primary key(`id`), key idx_c(`c`(5)), key idx_d(`d`))")
+	// the same structure with the different names
+	require.Empty(t, GetStructChanges([]*model.TableInfo{upstream}, newTableInfo("create table `t`(`ID` int not null, `name` varchar(20), `c` text, `d` int, primary key(`id`), key idx_c(`c`(5)), key IDX_D(`d`))")))
+
+	downstream := newTableInfo("create table `t`(`id` int not null, `d` int, `name` varchar(30) not null, `e` int, primary key(`id`), unique key idx_d(`d`), key idx_e(`e`))")
+	// the shards with the same structure are merged.
+	changes := GetStructChanges([]*model.TableInfo{upstream, upstream}, downstream)
+	lines := make([]string, 0, len(changes))
+	for _, change := range changes {
+		lines = append(lines, change.String())
+	}
+	require.Equal(t, []string{
+		"moved column `d`: position 4 -> position 2",
+		"changed column `name`: varchar(20) NULL -> varchar(30) NOT NULL",
+		"moved column `name`: position 2 -> position 3",
+		"added column `e`: int(11) NULL",
+		"removed column `c`: text NULL",
+		"changed index `idx_d`: KEY (`d`) -> UNIQUE KEY (`d`)",
+		"added index `idx_e`: KEY (`e`)",
+		"removed index `idx_c`: KEY (`c`(5))",
+	}, lines)
+	require.Equal(t, &StructChange{Kind: "column", Name: "e", Change: StructChangeAdded, Target: "int(11) NULL"}, changes[3])
+}
+
+func TestGenerateStructFix(t *testing.T) {
+	newTableInfo := func(createTableSQL string) *model.TableInfo {
+		tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+		require.NoError(t, err)
+		return tableInfo
+	}
+	upstream := newTableInfo("create table `t_1`(`id` int not null, `name` varchar(20), `c` text, `d` int, primary key(`id`), key idx_c(`c`(5)), key idx_d(`d`))")
+	// the same structure with the different names
+	statements, err := GenerateStructFix("test", "t", []*model.TableInfo{upstream}, newTableInfo("create table `t`(`ID` int not null, `name` varchar(20), `c` text, `d` int, primary key(`id`), key idx_c(`c`(5)), key IDX_D(`d`))"))
+	require.NoError(t, err)
+	require.Empty(t, statements)
+
+	downstream := newTableInfo("create table `t`(`id` int not null, `d` int, `name` varchar(30) not null, `e` int, primary key(`id`), unique key idx_d(`d`), key idx_e(`e`))")
+	statements, err = GenerateStructFix("test", "t", []*model.TableInfo{upstream, upstream}, downstream)
+	require.NoError(t, err)
+	lines := make([]string, 0, len(statements))
+	for _, statement := range statements {
+		lines = append(lines, statement.String())
+	}
+	require.Equal(t, []string{
+		"ALTER TABLE `test`.`t` DROP INDEX `idx_d`;",
+		"ALTER TABLE `test`.`t` DROP INDEX `idx_e`;",
+		"-- WARNING: the column `e` and its data are dropped\nALTER TABLE `test`.`t` DROP COLUMN `e`;",
+		"-- WARNING: the column `name` is changed from varchar(30) NOT NULL to varchar(20) NULL, which may truncate or reject the existing values\n" +
+			"ALTER TABLE `test`.`t` MODIFY COLUMN `name` varchar(20) DEFAULT NULL AFTER `id`;",
+		"ALTER TABLE `test`.`t` ADD COLUMN `c` text DEFAULT NULL AFTER `name`;",
+		"ALTER TABLE `test`.`t` ADD KEY `idx_c` (`c`(5));",
+		"ALTER TABLE `test`.`t` ADD KEY `idx_d` (`d`);",
+	}, lines)
+	require.Empty(t, statements[0].Warning)
+
+	// the column is only moved
+	statements, err = GenerateStructFix("test", "t", []*model.TableInfo{newTableInfo("create table `t`(`a` int, `b` int, `c` int)")}, newTableInfo("create table `t`(`c` int, `a` int, `b` int)"))
+	require.NoError(t, err)
+	require.Equal(t, []*StructFixStatement{
+		{SQL: "ALTER TABLE `test`.`t` MODIFY COLUMN `a` int(11) DEFAULT NULL FIRST;"},
+		{SQL: "ALTER TABLE `test`.`t` MODIFY COLUMN `b` int(11) DEFAULT NULL AFTER `a`;"},
+	}, statements)
+
+	// the primary key of the integer column is the handle
+	statements, err = GenerateStructFix("test", "t", []*model.TableInfo{newTableInfo("create table `t`(`id` bigint primary key, `a` int not null)")}, newTableInfo("create table `t`(`id` bigint not null, `a` int not null, primary key(`id`, `a`))"))
+	require.NoError(t, err)
+	require.Equal(t, []*StructFixStatement{
+		{SQL: "ALTER TABLE `test`.`t` DROP PRIMARY KEY;"},
+		{SQL: "ALTER TABLE `test`.`t` ADD PRIMARY KEY (`id`);"},
+	}, statements)
+
+	_, err = GenerateStructFix("test", "t", []*model.TableInfo{upstream, downstream}, downstream)
+	require.Error(t, err)
+
+	require.Equal(t, "CREATE TABLE `test`.`t` (\n"+
+		"  `a` int(11) DEFAULT NULL,\n"+
+		"  KEY `idx_a` (`a`)\n"+
+		") DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;", GenerateCreateTableFix("test", "t", newTableInfo("create table `t_1`(`a` int, key idx_a(`a`))")).String())
+	require.Equal(t, "-- WARNING: the table `test`.`t` only on the target and its data are dropped\nDROP TABLE `test`.`t`;", GenerateDropTableFix("test", "t").String())
+}
+
+func TestDiffCreateTable(t *testing.T) {
+	newTableInfo := func(createTableSQL string) *model.TableInfo {
+		tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+		require.NoError(t, err)
+		return tableInfo
+	}
+	upstream := newTableInfo("create table `t_1`(`id` int not null, `name` varchar(20) default 'x', `c` text, primary key(`id`), unique key uk(`name`(10)), key idx_c(`c`(5))) default charset=utf8mb4 comment='tbl'")
+	require.Equal(t, "CREATE TABLE `t` (\n"+
+		"  `id` int(11) NOT NULL,\n"+
+		"  `name` varchar(20) DEFAULT 'x',\n"+
+		"  `c` text DEFAULT NULL,\n"+
+		"  PRIMARY KEY (`id`),\n"+
+		"  KEY `idx_c` (`c`(5)),\n"+
+		"  UNIQUE KEY `uk` (`name`(10))\n"+
+		") DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin COMMENT='tbl'", NormalizeCreateTable("t", upstream))
+
+	// the same structure with the different names and index orders
+	downstream := newTableInfo("create table `t`(`id` int not null, `name` varchar(20) default 'x', `c` text, key idx_c(`c`(5)), unique key uk(`name`(10)), primary key(`id`)) default charset=utf8mb4 comment='tbl'")
+	diff, err := DiffCreateTable("t", []*model.TableInfo{upstream}, downstream)
+	require.NoError(t, err)
+	require.Equal(t, "", diff)
+
+	downstream = newTableInfo("create table `t`(`id` int not null, `name` varchar(30) default 'x', primary key(`id`), unique key uk(`name`(10))) default charset=utf8mb4")
+	diff, err = DiffCreateTable("t", []*model.TableInfo{upstream}, downstream)
+	require.NoError(t, err)
+	require.Equal(t, "--- source t_1\n"+
+		"+++ target t\n"+
+		"@@ -1,8 +1,6 @@\n"+
+		" CREATE TABLE `t` (\n"+
+		"   `id` int(11) NOT NULL,\n"+
+		"-  `name` varchar(20) DEFAULT 'x',\n"+
+		"-  `c` text DEFAULT NULL,\n"+
+		"+  `name` varchar(30) DEFAULT 'x',\n"+
+		"   PRIMARY KEY (`id`),\n"+
+		"-  KEY `idx_c` (`c`(5)),\n"+
+		"   UNIQUE KEY `uk` (`name`(10))\n"+
+		"-) DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin COMMENT='tbl'\n"+
+		"+) DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin\n", diff)
+}
+
+func TestNormalizeCreateView(t *testing.T) {
+	// the definitions of MySQL and TiDB are normalized to the same.
+	definition, err := NormalizeCreateView("CREATE ALGORITHM=UNDEFINED DEFINER=`root`@`%` SQL SECURITY DEFINER VIEW `v` AS select `test`.`t`.`a` AS `a`,`other`.`t2`.`b` AS `b` from (`test`.`t` join `other`.`t2`) where (`test`.`t`.`a` > 1)", "test")
+	require.NoError(t, err)
+	require.Equal(t, "SELECT `t`.`a` AS `a`,`other`.`t2`.`b` AS `b` FROM `t` JOIN `other`.`t2` WHERE (`t`.`a`>1)", definition)
+	definition, err = NormalizeCreateView("CREATE ALGORITHM=UNDEFINED DEFINER=`admin`@`127.0.0.1` SQL SECURITY INVOKER VIEW `v` (`a`, `b`) AS SELECT `test`.`t`.`a` AS `a`,`other`.`t2`.`b` AS `b` FROM (`test`.`t` JOIN `other`.`t2`) WHERE (`test`.`t`.`a` > 1)", "test")
+	require.NoError(t, err)
+	require.Equal(t, "SELECT `t`.`a` AS `a`,`other`.`t2`.`b` AS `b` FROM `t` JOIN `other`.`t2` WHERE (`t`.`a`>1)", definition)
+
+	definition, err = NormalizeCreateView("CREATE VIEW `v` AS SELECT `a` FROM `t` WITH LOCAL CHECK OPTION", "test")
+	require.NoError(t, err)
+	require.Equal(t, "SELECT `a` FROM `t` WITH LOCAL CHECK OPTION", definition)
+
+	_, err = NormalizeCreateView("CREATE TABLE `v`(`a` int)", "test")
+	require.Error(t, err)
+}
+
+func TestFindIndexByFields(t *testing.T) {
+	tableInfo, err := dbutil.GetTableInfoBySQL("create table `test`.`t`(`a` int, `b` int, `c` int, primary key(`a`), index `idx_bc`(`b`, `c`))", parser.New())
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"b", "c"}, ParseIndexFields(" b, c,"))
+	require.Empty(t, ParseIndexFields(","))
+
+	index, err := FindIndexByFields(tableInfo, nil)
+	require.NoError(t, err)
+	require.Nil(t, index)
+
+	// by the name of the index.
+	index, err = FindIndexByFields(tableInfo, []string{"IDX_BC"})
+	require.NoError(t, err)
+	require.Equal(t, "idx_bc", index.Name.O)
+
+	// by the leading columns of the index.
+	index, err = FindIndexByFields(tableInfo, []string{"b"})
+	require.NoError(t, err)
+	require.Equal(t, "idx_bc", index.Name.O)
+	index, err = FindIndexByFields(tableInfo, []string{"a"})
+	require.NoError(t, err)
+	require.Equal(t, "PRIMARY", index.Name.O)
+
+	// the columns are not indexed.
+	index, err = FindIndexByFields(tableInfo, []string{"c"})
+	require.NoError(t, err)
+	require.Nil(t, index)
+
+	// neither an index nor a column.
+	_, err = FindIndexByFields(tableInfo, []string{"idx_d"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "index or column idx_d")
+}
+
+func TestComparePartitions(t *testing.T) {
+	newTableInfo := func(createTableSQL string) *model.TableInfo {
+		tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+		require.NoError(t, err)
+		return tableInfo
+	}
+	rangeTable := newTableInfo("create table `t`(`a` int, `b` int) partition by range (`a`) (partition p0 values less than (10), partition p1 values less than (maxvalue))")
+	require.Equal(t, "PARTITION BY RANGE (`a`) (PARTITION `p0` VALUES LESS THAN (10), PARTITION `p1` VALUES LESS THAN (MAXVALUE))", NormalizePartition(rangeTable))
+	require.Equal(t, []string{"p0", "p1"}, GetPartitionNames(rangeTable))
+
+	plainTable := newTableInfo("create table `t`(`a` int, `b` int)")
+	require.Equal(t, "", NormalizePartition(plainTable))
+	require.Nil(t, GetPartitionNames(plainTable))
+
+	// the partitions are the same by name, but the bounds are different.
+	rangeTable2 := newTableInfo("create table `t`(`a` int, `b` int) partition by range (`a`) (partition P0 values less than (20), partition p1 values less than (maxvalue))")
+	require.True(t, ComparePartitions([]*model.TableInfo{rangeTable}, rangeTable))
+	require.False(t, ComparePartitions([]*model.TableInfo{rangeTable, rangeTable2}, rangeTable))
+	require.False(t, ComparePartitions([]*model.TableInfo{plainTable}, rangeTable))
+	require.True(t, HasSamePartitionNames([]*model.TableInfo{rangeTable, rangeTable2}, rangeTable))
+	require.False(t, HasSamePartitionNames([]*model.TableInfo{plainTable}, rangeTable))
+	require.False(t, HasSamePartitionNames([]*model.TableInfo{plainTable}, plainTable))
+
+	require.Equal(t, "`test`.`t`", TableNameWithPartition("test", "t", ""))
+	require.Equal(t, "`test`.`t` PARTITION (`p0`)", TableNameWithPartition("test", "t", "p0"))
+}
+
+func TestGetAdminChecksum(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	mock.ExpectQuery("ADMIN CHECKSUM TABLE `test_schema`\\.`test_table`").
+		WillReturnRows(sqlmock.NewRows([]string{"Db_name", "Table_name", "Checksum_crc64_xor", "Total_kvs", "Total_bytes"}).
+			AddRow("test_schema", "test_table", "10315712442567349224", 20000, 586781))
+	checksum, err := GetAdminChecksum(ctx, conn, "test_schema", "test_table")
+	require.NoError(t, err)
+	require.Equal(t, &TableChecksum{Checksum: 10315712442567349224, TotalKVs: 20000, TotalBytes: 586781}, checksum)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	for _, c := range []struct {
+		sourceVersions []string
+		targetVersion  string
+		compatible     bool
+	}{
+		{[]string{"5.7.25-TiDB-v6.5.0"}, "5.7.25-TiDB-v6.5.3", true},
+		{[]string{"5.7.25-TiDB-v6.5.0", "5.7.25-TiDB-v6.1.0"}, "5.7.25-TiDB-v6.5.0", false},
+		{[]string{"5.7.25-TiDB-v5.4.0"}, "5.7.25-TiDB-v6.5.0", false},
+		{[]string{"8.0.11"}, "5.7.25-TiDB-v6.5.0", false},
+		{[]string{"5.7.25-TiDB-v6.5.0"}, "5.7.25-TiDB-None", false},
+		{[]string{"5.7.25-TiDB-v6.5.0"}, "", false},
+	} {
+		require.Equal(t, c.compatible, IsAdminChecksumCompatible(c.sourceVersions, c.targetVersion), c)
+	}
+}
+
+func TestHasSameColumnSet(t *testing.T) {
+	getTableInfo := func(createTableSQL string) *model.TableInfo {
+		tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+		require.NoError(t, err)
+		return tableInfo
+	}
+	target := getTableInfo("create table `test`.`t`(`a` int, `b` varchar(10), primary key(`a`))")
+	// the columns in different orders are the same.
+	require.True(t, HasSameColumnSet([]*model.TableInfo{getTableInfo("create table `test`.`t`(`b` varchar(10), `A` int, primary key(`a`))")}, target))
+	require.False(t, HasSameColumnSet([]*model.TableInfo{getTableInfo("create table `test`.`t`(`a` int, `b` varchar(10), `c` int, primary key(`a`))")}, target))
+	require.False(t, HasSameColumnSet([]*model.TableInfo{getTableInfo("create table `test`.`t`(`a` int, `c` varchar(10), primary key(`a`))")}, target))
+}
+
+func TestTiCDCClient(t *testing.T) {
+	checkpointTS := uint64(100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/api/v1/changefeeds/test-cf" {
+			http.Error(w, `{"error_msg":"changefeed not exists"}`, http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, `{"id":"test-cf","state":"normal","checkpoint_tso":%d}`, checkpointTS)
+	}))
+	defer server.Close()
+
+	client := NewTiCDCClient(server.URL+"/", "test-cf", 200)
+	positions, err := client.GetPositions(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []*ReplicationPosition{{Source: "test-cf", Goal: "200", Checkpoint: "100", CaughtUp: false}}, positions)
+	checkpointTS = 200
+	positions, err = client.GetPositions(context.Background())
+	require.NoError(t, err)
+	require.True(t, positions[0].CaughtUp)
+
+	_, err = NewTiCDCClient(server.URL, "missing", 200).GetPositions(context.Background())
+	require.Error(t, err)
+}
+
+func TestDMClient(t *testing.T) {
+	masterBinlog, syncerBinlog := "(mysql-bin.000002, 100)", "(mysql-bin.000001, 4000)"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		require.Equal(t, "/apis/v1alpha1/status/test", req.URL.Path)
+		fmt.Fprintf(w, `{"result":true,"msg":"","sources":[{"result":true,"msg":"","sourceStatus":{"source":"mysql-replica-01"},
+"subTaskStatus":[{"name":"test","stage":"Running","unit":"Sync","sync":{"masterBinlog":"%s","syncerBinlog":"%s"}}]}]}`, masterBinlog, syncerBinlog)
+	}))
+	defer server.Close()
+
+	client := NewDMClient(server.URL, "test")
+	positions, err := client.GetPositions(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []*ReplicationPosition{{Source: "mysql-replica-01", Goal: "(mysql-bin.000002, 100)", Checkpoint: "(mysql-bin.000001, 4000)", CaughtUp: false}}, positions)
+	// the goal is the master binlog position got first.
+	masterBinlog, syncerBinlog = "(mysql-bin.000002, 300)", "(mysql-bin.000002, 100)"
+	positions, err = client.GetPositions(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "(mysql-bin.000002, 100)", positions[0].Goal)
+	require.True(t, positions[0].CaughtUp)
+
+	syncerBinlog = "invalid"
+	_, err = client.GetPositions(context.Background())
+	require.Error(t, err)
+}
+
+type mockReplicationClient struct {
+	// the positions caught up from the calls[caughtUpFrom].
+	caughtUpFrom int
+	calls        int
+}
+
+func (c *mockReplicationClient) GetPositions(ctx context.Context) ([]*ReplicationPosition, error) {
+	c.calls++
+	if c.calls == 1 {
+		return nil, errors.New("connection refused")
+	}
+	return []*ReplicationPosition{{Source: "test", CaughtUp: c.calls > c.caughtUpFrom}}, nil
+}
+
+func TestWaitReplication(t *testing.T) {
+	// the errors are retried.
+	client := &mockReplicationClient{caughtUpFrom: 2}
+	positions, caughtUp := WaitReplication(context.Background(), client, time.Minute, time.Millisecond)
+	require.True(t, caughtUp)
+	require.Equal(t, 3, client.calls)
+	require.Len(t, positions, 1)
+
+	client = &mockReplicationClient{caughtUpFrom: 1 << 30}
+	positions, caughtUp = WaitReplication(context.Background(), client, 50*time.Millisecond, 10*time.Millisecond)
+	require.False(t, caughtUp)
+	require.Len(t, positions, 1)
+	require.False(t, positions[0].CaughtUp)
+}
+
+func TestTranscodeValue(t *testing.T) {
+	for _, c := range []struct {
+		value      string
+		charset    string
+		transcoded string
+		unmappable []string
+	}{
+		{"héllo 😀", "utf8mb4", "héllo 😀", nil},
+		{"héllo 😀", "UTF8", "héllo ?", []string{"😀"}},
+		{"héllo €", "latin1", "héllo €", nil},
+		{"€ 😀 😀 中", "latin1", "€ ? ? ?", []string{"😀", "中"}},
+		{"中文", "gbk", "中文", nil},
+		{"中文 é", "ascii", "?? ?", []string{"中", "文", "é"}},
+		{"a\xffb", "utf8mb4", "a?b", []string{"\xff"}},
+	} {
+		transcoded, unmappable := TranscodeValue([]byte(c.value), c.charset)
+		require.Equal(t, c.transcoded, string(transcoded), c.value)
+		require.Equal(t, c.unmappable, unmappable, c.value)
+	}
+	require.True(t, IsSupportedCharset("LATIN1"))
+	require.False(t, IsSupportedCharset("binary"))
+}
+
+func TestGetTranscodedColumns(t *testing.T) {
+	getTableInfo := func(createTableSQL string) *model.TableInfo {
+		tableInfo, err := dbutil.GetTableInfoBySQL(createTableSQL, parser.New())
+		require.NoError(t, err)
+		return tableInfo
+	}
+	upstreamInfo := getTableInfo("create table `test`.`t`(`a` int, `b` varchar(10), `c` varchar(10) charset utf8mb4, `d` varchar(10) charset ascii, primary key(`a`)) charset latin1")
+	downstreamInfo := getTableInfo("create table `test`.`t`(`a` int, `b` varchar(10), `c` varchar(10), `d` varchar(10), primary key(`a`)) charset utf8mb4")
+	columns := GetTranscodedColumns([]*model.TableInfo{upstreamInfo}, downstreamInfo, map[string]string{"latin1": "utf8mb4", "ascii": "utf8"})
+	// `c` has the same charset, and ascii isn't mapped to utf8mb4.
+	require.Equal(t, []*TranscodedColumn{{Column: "b", From: "latin1", To: "utf8mb4"}}, columns)
+	require.Empty(t, GetTranscodedColumns([]*model.TableInfo{upstreamInfo}, downstreamInfo, nil))
+}
+
+func TestCompareNumbers(t *testing.T) {
+	tableInfo, err := dbutil.GetTableInfoBySQL("create table `test`.`test`(`a` bigint unsigned, `b` bigint, `c` double, `d` int, primary key(`a`, `b`))", parser.New())
+	require.NoError(t, err)
+	cases := []struct {
+		column string
+		str1   string
+		str2   string
+		cmp    int
+	}{
+		// the values can't be told apart as float64.
+		{"a", "18446744073709551615", "18446744073709551614", 1},
+		{"a", "18446744073709551614", "18446744073709551615", -1},
+		{"a", "18446744073709551615", "18446744073709551615", 0},
+		{"a", "9223372036854775808", "9223372036854775807", 1},
+		{"b", "9223372036854775807", "9223372036854775806", 1},
+		{"b", "-9223372036854775808", "-9223372036854775807", -1},
+		{"b", "-1", "1", -1},
+		{"c", "1.5", "2", -1},
+		{"c", "2.0", "2", 0},
+		// the transformed values which aren't integers are compared as float64.
+		{"d", "1.5", "1", 1},
+	}
+	for _, c := range cases {
+		cmp, err := CompareNumbers(dbutil.FindColumnByName(tableInfo.Columns, c.column), c.str1, c.str2)
+		require.NoError(t, err)
+		require.Equal(t, c.cmp, cmp, "%s %s %s", c.column, c.str1, c.str2)
+	}
+	_, err = CompareNumbers(dbutil.FindColumnByName(tableInfo.Columns, "c"), "a", "1")
+	require.Error(t, err)
+
+	// the rows are ordered exactly by the unsigned big integers.
+	_, orderKeyCols := dbutil.SelectUniqueOrderKey(tableInfo)
+	data1 := map[string]*dbutil.ColumnData{
+		"a": {Data: []byte("18446744073709551614")},
+		"b": {Data: []byte("1")},
+		"c": {Data: []byte("1")},
+		"d": {Data: []byte("1")},
+	}
+	data2 := map[string]*dbutil.ColumnData{
+		"a": {Data: []byte("18446744073709551615")},
+		"b": {Data: []byte("1")},
+		"c": {Data: []byte("1")},
+		"d": {Data: []byte("1")},
+	}
+	equal, cmp, err := CompareData(data1, data2, orderKeyCols, tableInfo.Columns)
+	require.NoError(t, err)
+	require.False(t, equal)
+	require.Equal(t, int32(-1), cmp)
+	equal, cmp, err = CompareData(data2, data1, orderKeyCols, tableInfo.Columns)
+	require.NoError(t, err)
+	require.False(t, equal)
+	require.Equal(t, int32(1), cmp)
+}
+
+func TestParseServerVersion(t *testing.T) {
+	cases := []struct {
+		version string
+		flavor  string
+		release string
+	}{
+		{"8.0.32", FlavorMySQL, "8.0.32"},
+		{"5.7.41-log", FlavorMySQL, "5.7.41"},
+		{"5.7.25-TiDB-v6.5.0", FlavorTiDB, "6.5.0"},
+		// MariaDB 10 and later are prefixed by "5.5.5-".
+		{"5.5.5-10.6.12-MariaDB-log", FlavorMariaDB, "10.6.12"},
+		{"5.5.5-10.11.2-MariaDB-1:10.11.2+maria~ubu2204", FlavorMariaDB, "10.11.2"},
+		{"11.4.2-MariaDB", FlavorMariaDB, "11.4.2"},
+		{"unknown", FlavorMySQL, ""},
+	}
+	for _, c := range cases {
+		flavor, release := ParseServerVersion(c.version)
+		require.Equal(t, c.flavor, flavor, c.version)
+		if len(c.release) == 0 {
+			require.Nil(t, release, c.version)
+			continue
+		}
+		require.NotNil(t, release, c.version)
+		require.Equal(t, c.release, release.String(), c.version)
+	}
+}
+
+func TestNormalizeMariaDBTable(t *testing.T) {
+	createTableSQL := "CREATE TABLE `t` (\n" +
+		"  `id` uuid NOT NULL,\n" +
+		"  `ip` inet6 DEFAULT NULL COMMENT 'the inet6 address',\n" +
+		"  `ip4` INET4 DEFAULT NULL,\n" +
+		"  `b` blob /*!100301 COMPRESSED*/ DEFAULT NULL,\n" +
+		"  `c` varchar(10) CHARACTER SET utf8mb3 COLLATE utf8mb3_general_ci INVISIBLE DEFAULT NULL,\n" +
+		"  `uuid` int(11) DEFAULT NULL WITHOUT SYSTEM VERSIONING,\n" +
+		"  PRIMARY KEY (`id`)\n" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb3 PAGE_CHECKSUM=1 TRANSACTIONAL=0 COMMENT='PAGE_CHECKSUM=1' WITH SYSTEM VERSIONING"
+	require.Equal(t, "CREATE TABLE `t` (\n"+
+		"  `id` char(36) NOT NULL,\n"+
+		"  `ip` varchar(39) DEFAULT NULL COMMENT 'the inet6 address',\n"+
+		"  `ip4` varchar(15) DEFAULT NULL,\n"+
+		"  `b` blob DEFAULT NULL,\n"+
+		"  `c` varchar(10) CHARACTER SET utf8 COLLATE utf8_general_ci DEFAULT NULL,\n"+
+		"  `uuid` int(11) DEFAULT NULL,\n"+
+		"  PRIMARY KEY (`id`)\n"+
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='PAGE_CHECKSUM=1'", normalizeMariaDBTable(createTableSQL))
+	// the column named `uuid` isn't a UUID column.
+	require.Equal(t, []string{"id", "ip", "ip4"}, GetMariaDBStringColumns(createTableSQL))
+
+	tableInfo, err := GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+	require.Equal(t, mysql.TypeString, tableInfo.Columns[0].Tp)
+	require.Equal(t, 36, tableInfo.Columns[0].Flen)
+	require.Equal(t, mysql.TypeVarchar, tableInfo.Columns[1].Tp)
+
+	// the statements of MySQL are kept as is.
+	mysqlSQL := "CREATE TABLE `t` (\n  `id` int NOT NULL,\n  PRIMARY KEY (`id`) /*!80000 INVISIBLE */\n) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"
+	require.Equal(t, mysqlSQL, normalizeMariaDBTable(mysqlSQL))
+	require.Empty(t, GetMariaDBStringColumns(mysqlSQL))
+}
+
+func TestHostileIdentifiers(t *testing.T) {
+	// the reserved words, the backticks, the unicode and the trailing spaces in the names are quoted as is.
+	createTableSQL := "CREATE TABLE `hostile``test`.`t``1 ` (`order` int, `a``b` varchar(20), `列 名` varchar(20), `c*/d` varchar(20), PRIMARY KEY (`order`), KEY `key``1` (`a``b`)) PARTITION BY HASH (`order`) PARTITIONS 2"
+	tableInfo, err := GetTableInfoBySQL(createTableSQL, parser.New())
+	require.NoError(t, err)
+	require.Equal(t, "t`1 ", tableInfo.Name.O)
+
+	query, _ := GetTableRowsQueryFormat("hostile`test", "t`1 ", "p`0", tableInfo, map[string]string{"a`b": "lower"}, "")
+	require.Equal(t, "SELECT /*!40001 SQL_NO_CACHE */ `order`, LOWER(`a``b`) AS `a``b`, `列 名`, `c*/d` FROM `hostile``test`.`t``1 ` PARTITION (`p``0`) WHERE %s ORDER BY `order`", query)
+	require.Equal(t, "SELECT COUNT(*) as CNT, BIT_XOR(CAST(CRC32(CONCAT_WS(',', `order`, `a``b`, `列 名`, `c*/d`, CONCAT(ISNULL(`order`), ISNULL(`a``b`), ISNULL(`列 名`), ISNULL(`c*/d`))))AS UNSIGNED)) as CHECKSUM FROM `hostile``test`.`t``1 ` WHERE TRUE;",
+		GetCountAndCRC32ChecksumSQL("hostile`test", "t`1 ", "", tableInfo, nil, "TRUE"))
+	require.Equal(t, "SELECT COUNT(*) as CNT, BIT_XOR(CAST(CRC32(CONCAT_WS(',', `a``b`, CONCAT(ISNULL(`a``b`))))AS UNSIGNED)) as CHECKSUM FROM `hostile``test`.`t``1 ` FORCE INDEX (`key``1`) WHERE TRUE;",
+		GetIndexCountAndCRC32ChecksumSQL("hostile`test", "t`1 ", "", tableInfo, FindIndexByName(tableInfo, "key`1"), nil, "TRUE"))
+
+	source := map[string]*dbutil.ColumnData{
+		"order": {Data: []byte("1")},
+		"a`b":   {Data: []byte("x`y")},
+		"列 名":   {Data: []byte("中文 ")},
+		"c*/d":  {Data: []byte("*/")},
+	}
+	target := map[string]*dbutil.ColumnData{
+		"order": {Data: []byte("1")},
+		"a`b":   {Data: []byte("x`y")},
+		"列 名":   {Data: []byte("中文 ")},
+		"c*/d":  {IsNull: true},
+	}
+	require.Equal(t, "REPLACE INTO `hostile``test`.`t``1 `(`order`,`a``b`,`列 名`,`c*/d`) VALUES (1,'x`y','中文 ','*/');", GenerateReplaceDML(source, tableInfo, "hostile`test"))
+	require.Equal(t, "DELETE FROM `hostile``test`.`t``1 ` WHERE `order` = 1 AND `a``b` = 'x`y' AND `列 名` = '中文 ' AND `c*/d` is NULL LIMIT 1;", GenerateDeleteDML(target, tableInfo, "hostile`test"))
+	require.Equal(t, "INSERT INTO `hostile``test`.`t``1 `(`order`,`a``b`,`列 名`,`c*/d`) VALUES (1,'x`y','中文 ','*/') ON DUPLICATE KEY UPDATE `order` = VALUES(`order`),`a``b` = VALUES(`a``b`),`列 名` = VALUES(`列 名`),`c*/d` = VALUES(`c*/d`);",
+		GenerateInsertOnDuplicateDML(source, tableInfo, "hostile`test"))
+	// the "*/" in the annotation doesn't end the comment.
+	annotated := GenerateReplaceDMLWithAnnotation(source, target, tableInfo, "hostile`test")
+	require.True(t, strings.HasPrefix(annotated, "/*\n"))
+	require.Equal(t, len(annotated)-len("*/\n")-len(GenerateReplaceDML(source, tableInfo, "hostile`test")), strings.Index(annotated, "*/"))
+}
+
+func TestGetRangeColumns(t *testing.T) {
+	columns, err := GetRangeColumns("created_at > '2024-01-01' AND (`a` = 1 OR A IN (2, 3)) AND t.b IS NULL AND c IN (SELECT d FROM t2)")
+	require.NoError(t, err)
+	require.Equal(t, []string{"created_at", "a", "b", "c"}, columns)
+	columns, err = GetRangeColumns("TRUE")
+	require.NoError(t, err)
+	require.Empty(t, columns)
+	_, err = GetRangeColumns("a >")
+	require.Regexp(t, "invalid range a >", err)
+	_, err = GetRangeColumns("a > 1; DROP TABLE t")
+	require.Error(t, err)
+
+	tableInfo, err := GetTableInfoBySQL("create table `t`(`a` int, `created_at` datetime, primary key(`a`))", parser.New())
+	require.NoError(t, err)
+	require.NoError(t, CheckRangeColumns("`Created_At` > '2024-01-01' AND a > 10", tableInfo))
+	require.Regexp(t, "column `b` in the range doesn't exist in table `t`", CheckRangeColumns("a > 10 OR b IS NULL", tableInfo))
+}
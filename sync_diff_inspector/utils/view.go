@@ -0,0 +1,99 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb-tools/pkg/dbutil"
+)
+
+// GetCreateTableSQL returns the `CREATE TABLE` statement of the table like `dbutil.GetCreateTableSQL`. The
+// `SHOW CREATE TABLE` of a view returns the `CREATE VIEW` statement in 4 columns instead, so the statement of a view
+// is built by its columns, see `getViewCreateTableSQL`.
+func GetCreateTableSQL(ctx context.Context, db dbutil.QueryExecutor, schemaName string, tableName string) (string, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SHOW CREATE TABLE %s", dbutil.TableName(schemaName, tableName)))
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return "", errors.Trace(err)
+		}
+		return "", errors.NotFoundf("table %s", tableName)
+	}
+	if len(columns) != 2 {
+		// the result of the view is closed before querying its columns.
+		rows.Close()
+		return getViewCreateTableSQL(ctx, db, schemaName, tableName)
+	}
+	var tbl, createTable sql.NullString
+	if err := rows.Scan(&tbl, &createTable); err != nil {
+		return "", errors.Trace(err)
+	}
+	if !tbl.Valid || !createTable.Valid {
+		return "", errors.NotFoundf("table %s", tableName)
+	}
+	return createTable.String, nil
+}
+
+// getViewCreateTableSQL returns the `CREATE TABLE` statement of the view built by its columns in
+// `information_schema.COLUMNS` in the format of `SHOW CREATE TABLE`, e.g.
+//
+//	CREATE TABLE `v` (
+//	  `a` int(11),
+//	  `b` varchar(10) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin
+//	)
+//
+// The view has no index, and the columns are nullable, which are the same on both sides.
+func getViewCreateTableSQL(ctx context.Context, db dbutil.QueryExecutor, schemaName string, viewName string) (string, error) {
+	query := "SELECT COLUMN_NAME, COLUMN_TYPE, CHARACTER_SET_NAME, COLLATION_NAME FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION"
+	rows, err := db.QueryContext(ctx, query, schemaName, viewName)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	defer rows.Close()
+	definitions := make([]string, 0)
+	for rows.Next() {
+		var name, columnType string
+		var charset, collation sql.NullString
+		if err := rows.Scan(&name, &columnType, &charset, &collation); err != nil {
+			return "", errors.Trace(err)
+		}
+		definition := fmt.Sprintf("  %s %s", dbutil.QuoteName(name), columnType)
+		if charset.Valid && len(charset.String) > 0 {
+			definition += " CHARACTER SET " + charset.String
+		}
+		if collation.Valid && len(collation.String) > 0 {
+			definition += " COLLATE " + collation.String
+		}
+		definitions = append(definitions, definition)
+	}
+	if err := rows.Err(); err != nil {
+		return "", errors.Trace(err)
+	}
+	if len(definitions) == 0 {
+		return "", errors.NotFoundf("columns of view %s", dbutil.TableName(schemaName, viewName))
+	}
+	return fmt.Sprintf("CREATE TABLE %s (\n%s\n)", dbutil.QuoteName(viewName), strings.Join(definitions, ",\n")), nil
+}
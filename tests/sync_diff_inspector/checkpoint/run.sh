@@ -16,8 +16,8 @@ echo "================test bucket checkpoint================="
 echo "---------1. chunk is in the last of the bucket---------"
 export GO_FAILPOINTS="github.com/pingcap/tidb-tools/sync_diff_inspector/splitter/check-one-bucket=return();\
 github.com/pingcap/tidb-tools/sync_diff_inspector/splitter/print-chunk-info=return();\
-main/wait-for-checkpoint=return()"
-sync_diff_inspector --config=./config.toml > $OUT_DIR/checkpoint_diff.output
+github.com/pingcap/tidb-tools/sync_diff_inspector/diff/wait-for-checkpoint=return()"
+sync_diff_inspector --yes --config=./config.toml > $OUT_DIR/checkpoint_diff.output
 check_contains "check pass!!!" $OUT_DIR/sync_diff.log
 # Save the last chunk's info, 
 # to which we will check whether the first chunk's info is next in the next running.
@@ -43,7 +43,7 @@ echo $bucket_index_right
 
 rm -f $OUT_DIR/sync_diff.log
 export GO_FAILPOINTS="github.com/pingcap/tidb-tools/sync_diff_inspector/splitter/print-chunk-info=return()"
-sync_diff_inspector --config=./config.toml > $OUT_DIR/checkpoint_diff.output
+sync_diff_inspector --yes --config=./config.toml > $OUT_DIR/checkpoint_diff.output
 first_chunk_info=$(grep 'print-chunk-info' $OUT_DIR/sync_diff.log | awk -F 'lowerBounds=' '{print $2}' | sed 's/[]["]//g' | sort -n | awk 'NR==1')
 echo $first_chunk_info | awk -F '=' '{print $1}' > $OUT_DIR/first_chunk_bound
 cat $OUT_DIR/first_chunk_bound
@@ -59,8 +59,8 @@ mkdir -p $OUT_DIR
 export GO_FAILPOINTS="github.com/pingcap/tidb-tools/sync_diff_inspector/splitter/check-one-bucket=return();\
 github.com/pingcap/tidb-tools/sync_diff_inspector/splitter/ignore-last-n-chunk-in-bucket=return(1);\
 github.com/pingcap/tidb-tools/sync_diff_inspector/splitter/print-chunk-info=return();\
-main/wait-for-checkpoint=return()"
-sync_diff_inspector --config=./config.toml > $OUT_DIR/checkpoint_diff.output
+github.com/pingcap/tidb-tools/sync_diff_inspector/diff/wait-for-checkpoint=return()"
+sync_diff_inspector --yes --config=./config.toml > $OUT_DIR/checkpoint_diff.output
 check_contains "check pass!!!" $OUT_DIR/sync_diff.log
 # Save the last chunk's info, 
 # to which we will check whether the first chunk's info is next in the next running.
@@ -87,7 +87,7 @@ echo "${bucket_index_left}-${bucket_index_right}"
 
 rm -f $OUT_DIR/sync_diff.log
 export GO_FAILPOINTS="github.com/pingcap/tidb-tools/sync_diff_inspector/splitter/print-chunk-info=return()"
-sync_diff_inspector --config=./config.toml > $OUT_DIR/checkpoint_diff.output
+sync_diff_inspector --yes --config=./config.toml > $OUT_DIR/checkpoint_diff.output
 first_chunk_info=$(grep 'print-chunk-info' $OUT_DIR/sync_diff.log | awk -F 'lowerBounds=' '{print $2}' | sed 's/[]["]//g' | sort -n | awk 'NR==1')
 echo $first_chunk_info | awk -F '=' '{print $1}' > $OUT_DIR/first_chunk_bound
 cat $OUT_DIR/first_chunk_bound
@@ -106,8 +106,8 @@ rm -rf $OUT_DIR
 mkdir -p $OUT_DIR
 export GO_FAILPOINTS="github.com/pingcap/tidb-tools/sync_diff_inspector/splitter/ignore-last-n-chunk-in-bucket=return(1);\
 github.com/pingcap/tidb-tools/sync_diff_inspector/splitter/print-chunk-info=return();\
-main/wait-for-checkpoint=return()"
-sync_diff_inspector --config=./config.toml > $OUT_DIR/checkpoint_diff.output
+github.com/pingcap/tidb-tools/sync_diff_inspector/diff/wait-for-checkpoint=return()"
+sync_diff_inspector --yes --config=./config.toml > $OUT_DIR/checkpoint_diff.output
 check_contains "check pass!!!" $OUT_DIR/sync_diff.log
 # Save the last chunk's info, 
 # to which we will check whether the first chunk's info is next in the next running.
@@ -130,7 +130,7 @@ done
 
 rm -f $OUT_DIR/sync_diff.log
 export GO_FAILPOINTS="github.com/pingcap/tidb-tools/sync_diff_inspector/splitter/print-chunk-info=return()"
-sync_diff_inspector --config=./config.toml > $OUT_DIR/checkpoint_diff.output
+sync_diff_inspector --yes --config=./config.toml > $OUT_DIR/checkpoint_diff.output
 first_chunk_info=$(grep 'print-chunk-info' $OUT_DIR/sync_diff.log | awk -F 'lowerBounds=' '{print $2}' | sed 's/[]["]//g' | sort -n | awk 'NR==1')
 echo $first_chunk_info | awk -F '=' '{print $1}' > $OUT_DIR/first_chunk_bound
 cat $OUT_DIR/first_chunk_bound
@@ -147,8 +147,8 @@ echo "================test checkpoint continous================="
 # so data-check will be skipped
 mysql -uroot -h 127.0.0.1 -P 4000 -e "create table IF NOT EXISTS diff_test.ttt(a int, aa int, primary key(a), key(aa));"
 mysql -uroot -h ${MYSQL_HOST} -P ${MYSQL_PORT} -e "create table IF NOT EXISTS diff_test.ttt(a int, b int, primary key(a), key(b));"
-export GO_FAILPOINTS="main/wait-for-checkpoint=return()"
-sync_diff_inspector --config=./config.toml > $OUT_DIR/checkpoint_diff.output || true
+export GO_FAILPOINTS="github.com/pingcap/tidb-tools/sync_diff_inspector/diff/wait-for-checkpoint=return()"
+sync_diff_inspector --yes --config=./config.toml > $OUT_DIR/checkpoint_diff.output || true
 grep 'save checkpoint' $OUT_DIR/sync_diff.log | awk 'END {print}' > $OUT_DIR/checkpoint_info
 check_not_contains 'has-upper\":true' $OUT_DIR/checkpoint_info
 
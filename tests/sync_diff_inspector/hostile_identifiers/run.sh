@@ -0,0 +1,51 @@
+#!/bin/sh
+
+set -e
+
+cd "$(dirname "$0")"
+
+OUT_DIR=/tmp/tidb_tools_test/sync_diff_inspector/output
+FIX_DIR=/tmp/tidb_tools_test/sync_diff_inspector/fixsql
+rm -rf $OUT_DIR
+rm -rf $FIX_DIR
+mkdir -p $OUT_DIR
+mkdir -p $FIX_DIR
+
+echo "prepare the tables with the hostile identifiers"
+cat > $OUT_DIR/hostile.sql <<'SQL'
+DROP DATABASE IF EXISTS `hostile``test`;
+CREATE DATABASE `hostile``test`;
+CREATE TABLE `hostile``test`.`order` (`order` int, `select` varchar(20), `a``b` varchar(20), `列 名` varchar(20), PRIMARY KEY (`order`), KEY `key``1` (`select`));
+CREATE TABLE `hostile``test`.`t``1:a/b` (`key` varchar(20), `group` int, `c*/d` varchar(20), PRIMARY KEY (`key`));
+INSERT INTO `hostile``test`.`order` VALUES (1, 'a', 'x`y', '中文'), (2, 'b', 'it''s', 'trailing  '), (3, 'c', '*/', NULL), (4, 'd', 'a\nb', '');
+INSERT INTO `hostile``test`.`t``1:a/b` VALUES ('k`1', 1, 'v1'), ('k\n2', 2, '*/'), ('k 3 ', 3, NULL);
+SQL
+mysql -uroot -h ${MYSQL_HOST} -P ${MYSQL_PORT} < $OUT_DIR/hostile.sql
+mysql -uroot -h 127.0.0.1 -P 4000 < $OUT_DIR/hostile.sql
+
+sed "s/\"127.0.0.1\"#MYSQL_HOST/\"${MYSQL_HOST}\"/g" ./config.toml | sed "s/3306#MYSQL_PORT/${MYSQL_PORT}/g" > ./config_.toml
+sync_diff_inspector --yes --config=./config_.toml > $OUT_DIR/hostile_identifiers.output || true
+check_contains "check pass!!!" $OUT_DIR/sync_diff.log
+rm -rf $OUT_DIR/*
+
+echo "change the data on the target, data should not be equal"
+cat > $OUT_DIR/change.sql <<'SQL'
+UPDATE `hostile``test`.`order` SET `a``b` = 'changed' WHERE `order` = 1;
+DELETE FROM `hostile``test`.`order` WHERE `order` = 4;
+INSERT INTO `hostile``test`.`order` VALUES (5, 'e', '`', '*/');
+UPDATE `hostile``test`.`t``1:a/b` SET `c*/d` = 'changed' WHERE `group` = 2;
+DELETE FROM `hostile``test`.`t``1:a/b` WHERE `group` = 3;
+SQL
+mysql -uroot -h 127.0.0.1 -P 4000 < $OUT_DIR/change.sql
+sync_diff_inspector --yes --config=./config_.toml > $OUT_DIR/hostile_identifiers.output || true
+check_contains "check failed" $OUT_DIR/sync_diff.log
+mv $OUT_DIR/fix-on-tidb/ $FIX_DIR/
+rm -rf $OUT_DIR/*
+
+echo "execute the fix sql, and then compare data, data should be equal"
+cat $FIX_DIR/fix-on-tidb/*.sql | mysql -uroot -h127.0.0.1 -P 4000
+sync_diff_inspector --yes --config=./config_.toml > $OUT_DIR/hostile_identifiers.output || true
+check_contains "check pass!!!" $OUT_DIR/sync_diff.log
+rm -rf $OUT_DIR/*
+
+echo "hostile_identifiers test passed"
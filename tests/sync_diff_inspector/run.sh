@@ -29,28 +29,28 @@ mysql -h ${MYSQL_HOST} -P ${MYSQL_PORT} -u root -e "select * from diff_test.test
 
 echo "use sync_diff_inspector to compare data"
 # sync diff tidb-tidb
-sync_diff_inspector --config=./config_base_tidb.toml > $OUT_DIR/diff.output
+sync_diff_inspector --yes --config=./config_base_tidb.toml > $OUT_DIR/diff.output
 check_contains "check pass!!!" $OUT_DIR/sync_diff.log
 
 echo "analyze table, and will use tidb's statistical information to split chunks"
 check_contains "split range by random" $OUT_DIR/sync_diff.log
 rm -rf $OUT_DIR/*
 mysql -uroot -h 127.0.0.1 -P 4000 -e "analyze table diff_test.test"
-sync_diff_inspector --config=./config_base_tidb.toml > $OUT_DIR/diff.output
+sync_diff_inspector --yes --config=./config_base_tidb.toml > $OUT_DIR/diff.output
 check_contains "check pass!!!" $OUT_DIR/sync_diff.log
 check_not_contains "split range by random" $OUT_DIR/sync_diff.log
 rm -rf $OUT_DIR/*
 
 echo "test 'exclude-tables' config"
 mysql -uroot -h 127.0.0.1 -P 4000 -e "create table if not exists diff_test.should_not_compare (id int)"
-sync_diff_inspector --config=./config_base_tidb.toml > $OUT_DIR/diff.log
+sync_diff_inspector --yes --config=./config_base_tidb.toml > $OUT_DIR/diff.log
 # doesn't contain the table's result in check report
 check_not_contains "[table=should_not_compare]" $OUT_DIR/sync_diff.log
 rm -rf $OUT_DIR/*
 
 # sync diff tidb-mysql
 sed "s/\"127.0.0.1\"#MYSQL_HOST/\"${MYSQL_HOST}\"/g" ./config_base_mysql.toml | sed "s/3306#MYSQL_PORT/${MYSQL_PORT}/g" > ./config_base_mysql_.toml
-sync_diff_inspector --config=./config_base_mysql_.toml #> $OUT_DIR/diff.output
+sync_diff_inspector --yes --config=./config_base_mysql_.toml #> $OUT_DIR/diff.output
 check_contains "check pass!!!" $OUT_DIR/sync_diff.log
 rm -rf $OUT_DIR/*
 
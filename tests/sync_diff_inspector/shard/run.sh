@@ -25,13 +25,13 @@ mysql -uroot -h 127.0.0.1 -P 4000 -e "insert into shard_test.test (\`table\`, aa
 sed "s/\"127.0.0.1\"#MYSQL_HOST/\"${MYSQL_HOST}\"/g" ./config_base.toml | sed "s/3306#MYSQL_PORT/${MYSQL_PORT}/g" > ./config.toml
 
 echo "compare sharding tables with one table in downstream, check result should be pass"
-sync_diff_inspector --config=./config.toml > $OUT_DIR/shard_diff.output
+sync_diff_inspector --yes --config=./config.toml > $OUT_DIR/shard_diff.output
 check_contains "check pass!!!" $OUT_DIR/sync_diff.log
 rm -rf $OUT_DIR/*
 
 echo "update data in one shard table, and data should not be equal"
 mysql -uroot -h ${MYSQL_HOST} -P ${MYSQL_PORT} -e "update shard_test.test1 set b = 'abc' limit 1"
-sync_diff_inspector --config=./config.toml > $OUT_DIR/shard_diff.output || true
+sync_diff_inspector --yes --config=./config.toml > $OUT_DIR/shard_diff.output || true
 check_contains "check failed" $OUT_DIR/sync_diff.log
 rm -rf $OUT_DIR/*
 
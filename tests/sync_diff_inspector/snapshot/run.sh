@@ -20,7 +20,7 @@ echo "get ts $ts"
 echo "delete one data, diff should not passed"
 mysql -uroot -h 127.0.0.1 -P 4000 -e "delete from diff_test.test limit 1"
 
-sync_diff_inspector --config=./config_base.toml > $OUT_DIR/snapshot_diff.log || true
+sync_diff_inspector --yes --config=./config_base.toml > $OUT_DIR/snapshot_diff.log || true
 check_contains "check failed" $OUT_DIR/sync_diff.log
 # move the fix sql file to $FIX_DIR
 mv $OUT_DIR/fix-on-tidb/ $FIX_DIR/
@@ -33,13 +33,13 @@ mysql -uroot -h 127.0.0.1 -P 4000 -e "show variables like '%sql_mode%'"
 mysql -uroot -h 127.0.0.1 -P 4000 -e "show create table diff_test.test"
 sed "s/#snapshot#/snapshot = \"${ts}\"/g" config_base.toml > config.toml
 echo "use snapshot compare data, data should be equal"
-sync_diff_inspector --config=./config.toml #> $OUT_DIR/snapshot_diff.log
+sync_diff_inspector --yes --config=./config.toml #> $OUT_DIR/snapshot_diff.log
 check_contains "check pass!!!" $OUT_DIR/sync_diff.log
 rm -rf $OUT_DIR/*
 
 echo "execute fix.sql and use base config, and then compare data, data should be equal"
 cat $FIX_DIR/fix-on-tidb/*.sql | mysql -uroot -h127.0.0.1 -P 4000
-sync_diff_inspector --config=./config_base.toml > $OUT_DIR/snapshot_diff.log
+sync_diff_inspector --yes --config=./config_base.toml > $OUT_DIR/snapshot_diff.log
 check_contains "check pass!!!" $OUT_DIR/sync_diff.log
 rm -rf $OUT_DIR/*
 
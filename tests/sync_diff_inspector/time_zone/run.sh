@@ -21,7 +21,7 @@ for port in 4000 4001; do
 done
 
 echo "check with the same time_zone, check result should be pass"
-sync_diff_inspector --config=./config.toml > $OUT_DIR/time_zone_diff.output
+sync_diff_inspector --yes --config=./config.toml > $OUT_DIR/time_zone_diff.output
 check_contains "check pass!!!" $OUT_DIR/sync_diff.log
 rm -rf $OUT_DIR/*
 
@@ -31,21 +31,21 @@ mysql -uroot -h 127.0.0.1 -P 4001 -e "SET @@global.time_zone = '+00:00'";
 sleep 5
 
 echo "check with different time_zone, check result should be pass again"
-sync_diff_inspector --config=./config.toml > $OUT_DIR/time_zone_diff.output
+sync_diff_inspector --yes --config=./config.toml > $OUT_DIR/time_zone_diff.output
 check_contains "check pass!!!" $OUT_DIR/sync_diff.log
 rm -rf $OUT_DIR/*
 
 echo "set different rows, check result should be failed"
 mysql -uroot -h 127.0.0.1 -P 4001 -e "SET @@session.time_zone = '-06:00'; insert into tz_test.diff values (4, '2020-05-17 09:12:13', '2020-05-17 09:12:13');"
 mysql -uroot -h 127.0.0.1 -P 4000 -e "SET @@session.time_zone = '-05:00'; insert into tz_test.diff values (3, '2020-05-17 10:12:13', '2020-05-17 10:12:13');"
-sync_diff_inspector --config=./config.toml > $OUT_DIR/time_zone_diff.output || true
+sync_diff_inspector --yes --config=./config.toml > $OUT_DIR/time_zone_diff.output || true
 check_contains "check failed" $OUT_DIR/sync_diff.log
 mv $OUT_DIR/fix-on-tidb/ $FIX_DIR/
 rm -rf $OUT_DIR/*
 
 echo "fix the rows, check result should be pass"
 cat $FIX_DIR/fix-on-tidb/*.sql | mysql -uroot -h127.0.0.1 -P 4000
-sync_diff_inspector --config=./config.toml > $OUT_DIR/time_zone_diff.output
+sync_diff_inspector --yes --config=./config.toml > $OUT_DIR/time_zone_diff.output
 check_contains "check pass!!!" $OUT_DIR/sync_diff.log
 rm -rf $OUT_DIR/*
 mysql -uroot -h 127.0.0.1 -P 4000 -e "SET @@session.time_zone = '-06:00'; select ts from tz_test.diff where id = 4 or id = 3;" > $OUT_DIR/tmp_sql_timezone